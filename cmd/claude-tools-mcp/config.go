@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/brwse/claude-tools-mcp/internal/tools"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// reloadDebounce is how long a reload waits after a change to the config or API keys file, so that
+// an editor's burst of writes reloads once.
+const reloadDebounce = 250 * time.Millisecond
+
+// reloadableFlags are the settings a reload applies to the running server. Changes to any other
+// setting in the config file take effect at the next restart.
+var reloadableFlags = []string{
+	"max-file-size", "max-output-tokens", "max-results",
+	"max-concurrent-commands", "max-background-shells",
+	"deny-path", "no-default-deny-paths",
+	"confirm-delete", "confirm-command", "confirm-dangerous-commands", "confirm-outside",
+	"approval-webhook", "approval-rule", "approval-timeout",
+	"allowed-shells", "slow-call-threshold",
+}
+
+// loadedConfig holds the values last read from the config file, keyed by flag name, so that a
+// reload can tell which settings changed.
+var loadedConfig map[string][]string
+
+// readConfig parses the YAML config file at path, whose keys are flag names and whose values are
+// scalars or, for repeatable flags, lists.
+func readConfig(flags *pflag.FlagSet, path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file: %w", err)
+	}
+	var raw map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("cannot parse config file %s: %w", path, err)
+	}
+	values := make(map[string][]string, len(raw))
+	for name, node := range raw {
+		if name == "config" || flags.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown setting %q in %s", name, path)
+		}
+		switch node.Kind {
+		case yaml.ScalarNode:
+			values[name] = []string{node.Value}
+		case yaml.SequenceNode:
+			items := []string{}
+			for _, item := range node.Content {
+				if item.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("invalid %s in %s: list items must be scalars", name, path)
+				}
+				items = append(items, item.Value)
+			}
+			values[name] = items
+		default:
+			return nil, fmt.Errorf("invalid %s in %s: must be a scalar or a list", name, path)
+		}
+	}
+	return values, nil
+}
+
+// setFlag sets a flag's value without marking it as given on the command line.
+func setFlag(flag *pflag.Flag, values []string) error {
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		return slice.Replace(values)
+	}
+	if len(values) != 1 {
+		return fmt.Errorf("%s takes a single value", flag.Name)
+	}
+	return flag.Value.Set(values[0])
+}
+
+// defaultValue returns a flag's default in the form setFlag takes.
+func defaultValue(flag *pflag.Flag) []string {
+	if _, ok := flag.Value.(pflag.SliceValue); ok {
+		items := strings.Trim(flag.DefValue, "[]")
+		if items == "" {
+			return nil
+		}
+		return strings.Split(items, ",")
+	}
+	return []string{flag.DefValue}
+}
+
+// applyConfigFile sets the flags named in --config that were not given on the command line, which
+// take precedence. It runs before every command, so that the file configures the doctor and tools
+// commands as it does the server.
+func applyConfigFile(cmd *cobra.Command, args []string) error {
+	if configFile == "" {
+		return nil
+	}
+	flags := cmd.Flags()
+	values, err := readConfig(flags, configFile)
+	if err != nil {
+		return err
+	}
+	for name, value := range values {
+		flag := flags.Lookup(name)
+		if flag.Changed {
+			continue
+		}
+		if err := setFlag(flag, value); err != nil {
+			return fmt.Errorf("invalid %s in %s: %w", name, configFile, err)
+		}
+	}
+	loadedConfig = values
+	return nil
+}
+
+// configureTunables applies the settings a reload can change to s. Workspaces must be set first,
+// so that the API keys' workspaces can be checked.
+func configureTunables(s *tools.State) error {
+	if err := s.LoadAPIKeys(apiKeys); err != nil {
+		return err
+	}
+	if err := s.SetMaxFileSize(maxFileSize); err != nil {
+		return err
+	}
+	if err := s.SetOutputLimits(maxOutputTokens, maxResults); err != nil {
+		return err
+	}
+	if err := s.SetConcurrencyLimits(maxCommands, maxShells); err != nil {
+		return err
+	}
+	if err := s.SetDeniedPaths(tools.DeniedPaths(denyPaths, !noDefaultDeny)); err != nil {
+		return err
+	}
+	commands := confirmCommands
+	if confirmDanger {
+		commands = append(slices.Clone(commands), tools.DangerousCommandPatterns...)
+	}
+	if err := s.SetConfirmPolicy(confirmDelete, commands, confirmOutside); err != nil {
+		return err
+	}
+	if err := s.SetApprovalWebhook(approvalURL, approvalRules, approvalTimeout, os.Getenv("APPROVAL_WEBHOOK_SECRET")); err != nil {
+		return err
+	}
+	if err := s.SetAllowedShells(allowedShells); err != nil {
+		return err
+	}
+	if err := s.SetSlowCallLog(slowCalls, os.Stderr); err != nil {
+		return err
+	}
+	return nil
+}
+
+// reloadConfig re-reads the config file and the API keys file and applies the reloadable settings
+// to the running server. Settings removed from the config file revert to their defaults. If any
+// setting is invalid, the running server keeps its configuration.
+func reloadConfig(flags *pflag.FlagSet) error {
+	values := map[string][]string{}
+	if configFile != "" {
+		var err error
+		if values, err = readConfig(flags, configFile); err != nil {
+			return err
+		}
+	}
+	for name, value := range values {
+		if !slices.Contains(reloadableFlags, name) && !flags.Lookup(name).Changed && !slices.Equal(value, loadedConfig[name]) {
+			fmt.Fprintf(os.Stderr, "Warning: %s changed in %s; restart the server to apply it\n", name, configFile)
+		}
+	}
+	for _, name := range reloadableFlags {
+		flag := flags.Lookup(name)
+		if flag.Changed {
+			continue
+		}
+		value, ok := values[name]
+		if !ok {
+			value = defaultValue(flag)
+		}
+		if err := setFlag(flag, value); err != nil {
+			return fmt.Errorf("invalid %s in %s: %w", name, configFile, err)
+		}
+	}
+	loadedConfig = values
+
+	// Validate against a scratch state first, so that a bad file changes nothing.
+	scratch := tools.NewState()
+	if err := configureFilesystem(scratch); err != nil {
+		return err
+	}
+	if err := scratch.SetWorkspaces(workspaces); err != nil {
+		return err
+	}
+	if err := configureTunables(scratch); err != nil {
+		return err
+	}
+	return configureTunables(tools.GetState())
+}
+
+// watchConfig reloads the configuration on SIGHUP, and when the config file or the API keys file
+// changes, until ctx is done. Background shells and in-flight requests carry on undisturbed.
+func watchConfig(ctx context.Context, flags *pflag.FlagSet) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var events chan fsnotify.Event
+	var errs chan error
+	watched := map[string]bool{}
+	if watcher, err := fsnotify.NewWatcher(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot watch the config file, reload with SIGHUP: %v\n", err)
+	} else {
+		defer watcher.Close()
+		events, errs = watcher.Events, watcher.Errors
+		for _, path := range []string{configFile, apiKeys} {
+			if path == "" {
+				continue
+			}
+			path, _ = filepath.Abs(path)
+			watched[path] = true
+			// Watch the directory, since editors often replace a file rather than write it.
+			if err := watcher.Add(filepath.Dir(path)); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: cannot watch %s, reload with SIGHUP: %v\n", path, err)
+			}
+		}
+	}
+
+	reload := func() {
+		if err := reloadConfig(flags); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot reload configuration: %v\n", err)
+			return
+		}
+		fmt.Println("Configuration reloaded")
+	}
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			reload()
+		case event := <-events:
+			if watched[filepath.Clean(event.Name)] {
+				debounce = time.After(reloadDebounce)
+			}
+		case err := <-errs:
+			fmt.Fprintf(os.Stderr, "Warning: watching the config file: %v\n", err)
+		case <-debounce:
+			debounce = nil
+			reload()
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/brwse/claude-tools-mcp/internal/tools"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that the environment supports the server's tools",
+	Long: "Checks for bash, ripgrep, and git, write access to the working and temp directories, and " +
+		"pseudo-terminal support, printing a hint for each problem. Server flags apply, so the configured " +
+		"spool, artifacts, snapshot, and backup directories and --allowed-shells are checked too. Exits " +
+		"non-zero when a check fails. Start the server with --strict to run the same checks before serving.",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := configureState(); err != nil {
+			return err
+		}
+		checks := tools.GetState().Doctor(cmd.Context())
+		printDoctorReport(cmd.OutOrStdout(), checks)
+		if failed := countChecks(checks, tools.CheckFail); failed > 0 {
+			return fmt.Errorf("%d checks failed", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// printDoctorReport writes one line per check, followed by its hint when it did not pass, and a
+// summary line.
+func printDoctorReport(w io.Writer, checks []tools.DoctorCheck) {
+	for _, check := range checks {
+		fmt.Fprintf(w, "%-6s %s: %s\n", "["+strings.ToUpper(check.Status)+"]", check.Name, check.Detail)
+		if check.Status != tools.CheckOK && check.Hint != "" {
+			fmt.Fprintf(w, "       hint: %s\n", check.Hint)
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed\n", countChecks(checks, tools.CheckOK), countChecks(checks, tools.CheckWarn), countChecks(checks, tools.CheckFail))
+}
+
+func countChecks(checks []tools.DoctorCheck, status string) int {
+	n := 0
+	for _, check := range checks {
+		if check.Status == status {
+			n++
+		}
+	}
+	return n
+}
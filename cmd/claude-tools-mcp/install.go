@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	installClient string
+	installName   string
+	installURL    string
+	installWrite  bool
+	installConfig string
+)
+
+// mcpClient describes how an MCP client is configured: where its config file lives and the entry
+// it needs under "mcpServers" to reach the server at a URL.
+type mcpClient struct {
+	// configPath returns the client's default config file.
+	configPath func() (string, error)
+	entry      func(url string) map[string]any
+}
+
+var mcpClients = map[string]mcpClient{
+	// Claude Desktop only launches stdio servers from its config, so it reaches the HTTP server
+	// through the mcp-remote bridge.
+	"claude-desktop": {
+		configPath: userConfigPath("Claude", "claude_desktop_config.json"),
+		entry: func(url string) map[string]any {
+			args := []string{"-y", "mcp-remote", url}
+			if strings.HasPrefix(url, "http://") && !isLocalHTTP(url) {
+				// mcp-remote refuses plain HTTP to other hosts unless told otherwise.
+				args = append(args, "--allow-http")
+			}
+			return map[string]any{"command": "npx", "args": args}
+		},
+	},
+	"cursor": {
+		configPath: homePath(".cursor", "mcp.json"),
+		entry: func(url string) map[string]any {
+			return map[string]any{"url": url}
+		},
+	},
+	"cline": {
+		configPath: userConfigPath("Code", "User", "globalStorage", "saoudrizwan.claude-dev", "settings", "cline_mcp_settings.json"),
+		entry: func(url string) map[string]any {
+			return map[string]any{"type": "streamableHttp", "url": url, "disabled": false, "autoApprove": []string{}}
+		},
+	},
+}
+
+var installCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Print or write an MCP client's configuration for this server",
+	Long: "Prints the configuration snippet that connects an MCP client (" + strings.Join(clientNames(), ", ") + ") " +
+		"to this server at the URL given by --addr, or by --url. With --write, adds it to the client's config " +
+		"file instead, keeping the servers already configured there.",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, ok := mcpClients[installClient]
+		if !ok {
+			return fmt.Errorf("unknown client %q, must be one of: %s", installClient, strings.Join(clientNames(), ", "))
+		}
+		if transport != "http" {
+			return fmt.Errorf("%s connects over streamable HTTP; install does not support --transport %s", installClient, transport)
+		}
+		url := installURL
+		if url == "" {
+			url = serverURL(addr)
+		}
+		entry := client.entry(url)
+
+		if !installWrite {
+			snippet := map[string]any{"mcpServers": map[string]any{installName: entry}}
+			jsonBytes, err := json.MarshalIndent(snippet, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(jsonBytes))
+			return nil
+		}
+
+		path := installConfig
+		if path == "" {
+			var err error
+			if path, err = client.configPath(); err != nil {
+				return err
+			}
+		}
+		replaced, backup, err := writeClientConfig(path, installName, entry)
+		if err != nil {
+			return err
+		}
+		verb := "Added"
+		if replaced {
+			verb = "Updated"
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s %s in %s. Restart %s to pick it up.\n", verb, installName, path, installClient)
+		if backup != "" {
+			fmt.Fprintf(cmd.OutOrStdout(), "The previous configuration was saved to %s.\n", backup)
+		}
+		return nil
+	},
+}
+
+func init() {
+	installCmd.Flags().StringVar(&installClient, "client", "", "MCP client to configure: "+strings.Join(clientNames(), ", "))
+	installCmd.Flags().StringVar(&installName, "name", "claude-tools", "Name of the server entry in the client's configuration")
+	installCmd.Flags().StringVar(&installURL, "url", "", "URL clients connect to; defaults to http:// plus --addr, with localhost for an unspecified host")
+	installCmd.Flags().BoolVar(&installWrite, "write", false, "Add the entry to the client's config file instead of printing it")
+	installCmd.Flags().StringVar(&installConfig, "config", "", "Config file to write; defaults to the client's usual location")
+	_ = installCmd.MarkFlagRequired("client")
+	rootCmd.AddCommand(installCmd)
+}
+
+func clientNames() []string {
+	names := make([]string, 0, len(mcpClients))
+	for name := range mcpClients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// serverURL is the URL clients use to reach a server listening on addr. A server listening on all
+// interfaces is reached through localhost.
+func serverURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr + "/"
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/"
+}
+
+// isLocalHTTP reports whether url is a plain HTTP URL on the local machine.
+func isLocalHTTP(url string) bool {
+	rest, ok := strings.CutPrefix(url, "http://")
+	if !ok {
+		return false
+	}
+	host, _, _ := strings.Cut(rest, "/")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host == "localhost" || host == "127.0.0.1" || host == "[::1]" || host == "::1"
+}
+
+// writeClientConfig sets the server entry name in the config file at path, creating the file if
+// needed and keeping its other settings, and reports whether an entry of that name was replaced.
+// An existing file is first copied to <path>.bak, whose location is returned, and the new content
+// is written through a temporary file so the client never sees a half-written config.
+func writeClientConfig(path, name string, entry map[string]any) (replaced bool, backup string, err error) {
+	// Write through a symlinked config, as dotfile managers use, rather than replacing the link.
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	config := map[string]any{}
+	perm := os.FileMode(0o600)
+	data, err := os.ReadFile(path)
+	exists := err == nil
+	switch {
+	case exists:
+		if len(strings.TrimSpace(string(data))) > 0 {
+			if err := json.Unmarshal(data, &config); err != nil {
+				return false, "", fmt.Errorf("cannot parse %s, fix or remove it first: %w", path, err)
+			}
+		}
+		if info, err := os.Stat(path); err == nil {
+			perm = info.Mode().Perm()
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return false, "", err
+	}
+
+	servers, ok := config["mcpServers"].(map[string]any)
+	if !ok {
+		if config["mcpServers"] != nil {
+			return false, "", fmt.Errorf("cannot update %s: mcpServers is not an object", path)
+		}
+		servers = map[string]any{}
+		config["mcpServers"] = servers
+	}
+	_, replaced = servers[name]
+	servers[name] = entry
+
+	jsonBytes, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return false, "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return false, "", err
+	}
+	if exists {
+		backup = path + ".bak"
+		if err := os.WriteFile(backup, data, perm); err != nil {
+			return false, "", fmt.Errorf("cannot back up %s: %w", path, err)
+		}
+	}
+	if err := writeFileAtomic(path, append(jsonBytes, '\n'), perm); err != nil {
+		return false, "", err
+	}
+	return replaced, backup, nil
+}
+
+// writeFileAtomic replaces path with data by writing a temporary file beside it and renaming it
+// into place.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// userConfigPath returns a configPath under the user's configuration directory: ~/.config on
+// Linux, ~/Library/Application Support on macOS, and %AppData% on Windows.
+func userConfigPath(elem ...string) func() (string, error) {
+	return func() (string, error) {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(append([]string{dir}, elem...)...), nil
+	}
+}
+
+// homePath returns a configPath under the user's home directory.
+func homePath(elem ...string) func() (string, error) {
+	return func() (string, error) {
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(append([]string{dir}, elem...)...), nil
+	}
+}
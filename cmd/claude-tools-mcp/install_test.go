@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteClientConfig(t *testing.T) {
+	entry := map[string]any{"url": "http://localhost:8080/"}
+
+	tests := []struct {
+		name     string
+		existing string // "" means no file
+		replaced bool
+		want     map[string]any
+	}{
+		{
+			name:     "new file",
+			existing: "",
+			want: map[string]any{
+				"mcpServers": map[string]any{"claude-tools": map[string]any{"url": "http://localhost:8080/"}},
+			},
+		},
+		{
+			name:     "merges into an existing config",
+			existing: `{"theme": "dark", "mcpServers": {"other": {"command": "other-server"}}}`,
+			want: map[string]any{
+				"theme": "dark",
+				"mcpServers": map[string]any{
+					"other":        map[string]any{"command": "other-server"},
+					"claude-tools": map[string]any{"url": "http://localhost:8080/"},
+				},
+			},
+		},
+		{
+			name:     "replaces an entry of the same name",
+			existing: `{"mcpServers": {"claude-tools": {"url": "http://old:1/"}, "other": {"url": "http://other/"}}}`,
+			replaced: true,
+			want: map[string]any{
+				"mcpServers": map[string]any{
+					"other":        map[string]any{"url": "http://other/"},
+					"claude-tools": map[string]any{"url": "http://localhost:8080/"},
+				},
+			},
+		},
+		{
+			name:     "empty file",
+			existing: "\n",
+			want: map[string]any{
+				"mcpServers": map[string]any{"claude-tools": map[string]any{"url": "http://localhost:8080/"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config", "mcp.json")
+			if tt.existing != "" {
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+				require.NoError(t, os.WriteFile(path, []byte(tt.existing), 0o640))
+			}
+
+			replaced, backup, err := writeClientConfig(path, "claude-tools", entry)
+			require.NoError(t, err)
+			assert.Equal(t, tt.replaced, replaced)
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			var got map[string]any
+			require.NoError(t, json.Unmarshal(data, &got))
+			assert.Equal(t, tt.want, got)
+
+			if tt.existing == "" {
+				assert.Empty(t, backup)
+				assert.NoFileExists(t, path+".bak")
+				return
+			}
+			assert.Equal(t, path+".bak", backup)
+			saved, err := os.ReadFile(backup)
+			require.NoError(t, err)
+			assert.Equal(t, tt.existing, string(saved))
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o640), info.Mode().Perm(), "the file keeps its permissions")
+
+			entries, err := os.ReadDir(filepath.Dir(path))
+			require.NoError(t, err)
+			assert.Len(t, entries, 2, "no temporary file is left behind")
+		})
+	}
+}
+
+func TestWriteClientConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		wantErr  string
+	}{
+		{"invalid JSON", `{"mcpServers": `, "cannot parse"},
+		{"mcpServers is not an object", `{"mcpServers": []}`, "mcpServers is not an object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "mcp.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.existing), 0o600))
+
+			_, _, err := writeClientConfig(path, "claude-tools", map[string]any{})
+			assert.ErrorContains(t, err, tt.wantErr)
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.existing, string(data), "a config that cannot be updated is left alone")
+			assert.NoFileExists(t, path+".bak")
+		})
+	}
+}
+
+func TestClientConfigPaths(t *testing.T) {
+	home := t.TempDir()
+	configDir := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	userConfig, err := os.UserConfigDir()
+	require.NoError(t, err)
+
+	tests := []struct {
+		client string
+		want   string
+	}{
+		{"claude-desktop", filepath.Join(userConfig, "Claude", "claude_desktop_config.json")},
+		{"cursor", filepath.Join(home, ".cursor", "mcp.json")},
+		{"cline", filepath.Join(userConfig, "Code", "User", "globalStorage", "saoudrizwan.claude-dev", "settings", "cline_mcp_settings.json")},
+	}
+	require.Len(t, tests, len(mcpClients), "every client has a path case")
+	for _, tt := range tests {
+		t.Run(tt.client, func(t *testing.T) {
+			path, err := mcpClients[tt.client].configPath()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, path)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+var listToolsJSON bool
+
+var listToolsCmd = &cobra.Command{
+	Use:   "tools",
+	Short: "List the tools the server exposes with the given flags",
+	Long: "Prints every tool the server would register with the given flags, with its description and " +
+		"its generated input and output JSON schemas, without starting the server. Use --json for the " +
+		"same list in the form of an MCP tools/list result.",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := configureState(); err != nil {
+			return err
+		}
+		session, _, err := connectInProcess(cmd.Context(), newMCPServer())
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+		var registered []*mcp.Tool
+		for tool, err := range session.Tools(cmd.Context(), nil) {
+			if err != nil {
+				return fmt.Errorf("cannot list tools: %w", err)
+			}
+			registered = append(registered, tool)
+		}
+		if listToolsJSON {
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(map[string]any{"tools": registered})
+		}
+		return printTools(cmd.OutOrStdout(), registered)
+	},
+}
+
+func init() {
+	listToolsCmd.Flags().BoolVar(&listToolsJSON, "json", false, "Print the tools as JSON")
+	rootCmd.AddCommand(listToolsCmd)
+}
+
+// printTools writes each tool's name, then its description and schemas indented beneath it.
+func printTools(w io.Writer, registered []*mcp.Tool) error {
+	for i, tool := range registered {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, tool.Name)
+		fmt.Fprintln(w, indent(tool.Description, "  "))
+		for _, schema := range []struct {
+			name   string
+			schema any
+		}{{"Input schema", tool.InputSchema}, {"Output schema", tool.OutputSchema}} {
+			if schema.schema == nil {
+				continue
+			}
+			jsonBytes, err := json.MarshalIndent(schema.schema, "", "  ")
+			if err != nil {
+				return fmt.Errorf("cannot format %s of %s: %w", strings.ToLower(schema.name), tool.Name, err)
+			}
+			fmt.Fprintf(w, "\n  %s:\n%s\n", schema.name, indent(string(jsonBytes), "    "))
+		}
+	}
+	fmt.Fprintf(w, "\n%d tools\n", len(registered))
+	return nil
+}
+
+// indent prefixes each non-empty line of text with prefix.
+func indent(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
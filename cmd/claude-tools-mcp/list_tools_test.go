@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runTools runs the tools command with args and returns what it printed.
+func runTools(t *testing.T, args ...string) string {
+	t.Helper()
+	defer func(json bool) { listToolsJSON = json }(listToolsJSON)
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs(append([]string{"tools"}, args...))
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs(nil)
+	})
+	require.NoError(t, rootCmd.Execute())
+	return out.String()
+}
+
+func TestListTools(t *testing.T) {
+	out := runTools(t)
+
+	// Each tool starts with its name on a line of its own, followed by its indented schemas.
+	start := strings.Index(out, "\nread\n")
+	require.GreaterOrEqual(t, start, 0, "the read tool is listed")
+	entry := out[start+1:]
+	if next := regexp.MustCompile(`\n\n\S`).FindStringIndex(entry); next != nil {
+		entry = entry[:next[0]]
+	}
+	assert.Contains(t, entry, "  Input schema:\n    {\n")
+	assert.Contains(t, entry, `      "properties": {`)
+	assert.Contains(t, entry, `"file_path": {`)
+	assert.Contains(t, entry, `"required": [`)
+	assert.Regexp(t, `\n\d+ tools\n$`, out)
+}
+
+func TestListTools_JSON(t *testing.T) {
+	out := runTools(t, "--json")
+
+	var result struct {
+		Tools []struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			InputSchema struct {
+				Type       string                     `json:"type"`
+				Properties map[string]json.RawMessage `json:"properties"`
+				Required   []string                   `json:"required"`
+			} `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &result), "the output is a tools/list result")
+	require.NotEmpty(t, result.Tools)
+
+	var found bool
+	for _, tool := range result.Tools {
+		if tool.Name != "read" {
+			continue
+		}
+		found = true
+		assert.NotEmpty(t, tool.Description)
+		assert.Equal(t, "object", tool.InputSchema.Type)
+		assert.Contains(t, tool.InputSchema.Properties, "file_path")
+		assert.Contains(t, tool.InputSchema.Required, "file_path")
+	}
+	assert.True(t, found, "the read tool is listed")
+}
@@ -3,13 +3,23 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/brwse/claude-tools-mcp/internal/middleware"
+	"github.com/brwse/claude-tools-mcp/internal/rest"
+	"github.com/brwse/claude-tools-mcp/internal/systemd"
 	"github.com/brwse/claude-tools-mcp/internal/tools"
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+	"github.com/brwse/claude-tools-mcp/internal/websocket"
+	"github.com/modelcontextprotocol/go-sdk/auth"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/spf13/cobra"
 )
@@ -23,18 +33,144 @@ const (
 )
 
 var (
-	addr    string
-	rootCmd = &cobra.Command{
+	addr            string
+	configFile      string
+	stateless       bool
+	pidFile         string
+	transport       string
+	defaultFileMode string
+	backup          bool
+	backupDir       string
+	overlay         bool
+	filesystem      string
+	snapshotDir     string
+	debugTools      bool
+	workspaces      []string
+	apiKeys         string
+	oidcIssuer      string
+	oidcAudience    string
+	oidcClaims      []string
+	oidcScopePrefix string
+	oidcScopes      []string
+	strict          bool
+	typeAdd         []string
+	maxFileSize     int64
+	maxOutputTokens int
+	maxResults      int
+	rateLimit       int
+	rateBurst       int
+	allowCIDRs      []string
+	trustedProxies  []string
+	logBodies       bool
+	logRedact       []string
+	logBodyMax      int
+	slowCalls       time.Duration
+	recordFile      string
+	replayFile      string
+	compressMin     int
+	maxCommands     int
+	maxShells       int
+	spoolOutput     bool
+	spoolDir        string
+	artifactsDir    string
+	denyPaths       []string
+	noDefaultDeny   bool
+	confirmDelete   bool
+	confirmCommands []string
+	confirmDanger   bool
+	confirmOutside  string
+	approvalURL     string
+	approvalRules   []string
+	approvalTimeout time.Duration
+	restAPI         bool
+	shellStream     bool
+	dashboard       bool
+	allowedShells   []string
+	loginShell      bool
+	initScript      string
+	embeddingURL    string
+	embeddingModel  string
+	semanticDir     string
+	languageServers []string
+	formatters      []string
+	formatOnWrite   bool
+	tokenizer       string
+	tokenizerCmd    string
+	rootCmd         = &cobra.Command{
 		Use:     "claude-tools-mcp",
 		Short:   "Claude Tools MCP Server",
 		Long:    "This server exposes the same tools available in Claude Code, allowing them to be used by other MCP clients.",
 		Version: version,
 		RunE:    runServer,
+
+		PersistentPreRunE: applyConfigFile,
 	}
 )
 
 func init() {
-	rootCmd.Flags().StringVarP(&addr, "addr", "a", defaultAddr, "Server address (host:port)")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "YAML file of settings keyed by flag name (e.g. max-results: 500, deny-path: [~/.netrc]); flags given on the command line take precedence. Limits, policies, denylists, and API keys are reloaded on SIGHUP or when the file changes")
+	rootCmd.PersistentFlags().StringVarP(&addr, "addr", "a", defaultAddr, "Server address (host:port)")
+	rootCmd.PersistentFlags().StringVar(&transport, "transport", "http", "MCP transport: http for streamable HTTP, or ws for WebSocket, for clients behind proxies that break streaming responses")
+	rootCmd.PersistentFlags().BoolVar(&stateless, "stateless", true, "Handle each request without a session; disable to let clients receive server-initiated notifications")
+	rootCmd.PersistentFlags().StringVar(&defaultFileMode, "default-file-mode", "644", "Octal permissions for files created by the write tool, before the umask is applied")
+	rootCmd.PersistentFlags().BoolVar(&backup, "backup", false, "Back up files before write, edit, and delete_file change them, unless a call sets backup to false")
+	rootCmd.PersistentFlags().StringVar(&backupDir, "backup-dir", "", "Absolute directory for backups; defaults to a .bak file next to each original")
+	rootCmd.PersistentFlags().BoolVar(&overlay, "overlay", false, "Stage the changes made by write, edit, and delete_file in a per-session overlay that only commit_changes writes to disk, for speculative multi-step refactors")
+	rootCmd.PersistentFlags().StringVar(&filesystem, "fs", "disk", "Filesystem the file tools work on: disk, or memory for an empty in-memory filesystem discarded on exit, for demos, sandboxes, and CI; with memory only the file tools are served")
+	rootCmd.PersistentFlags().StringVar(&snapshotDir, "snapshot-dir", "", "Absolute directory for files saved by the snapshot tool; defaults to claude-tools-snapshots in the system temp directory")
+	rootCmd.PersistentFlags().StringArrayVar(&workspaces, "workspace", nil, "Named project root, as name=/absolute/path, that tools accept paths under as workspace://name/relative/path and glob and grep can search by name; may be repeated")
+	rootCmd.PersistentFlags().StringVar(&apiKeys, "api-keys", "", "JSON file of API keys clients must present as bearer tokens, each with scopes (read, write, exec) and an optional workspace its paths are confined to")
+	rootCmd.PersistentFlags().StringVar(&oidcIssuer, "oidc-issuer", "", "OpenID Connect issuer URL whose JWTs clients may present as bearer tokens, alongside any --api-keys")
+	rootCmd.PersistentFlags().StringVar(&oidcAudience, "oidc-audience", "", "Audience OIDC tokens must be issued for; required with --oidc-issuer")
+	rootCmd.PersistentFlags().StringArrayVar(&oidcClaims, "oidc-claim", nil, "Claim OIDC tokens must carry, as name=value; a list claim such as groups must contain the value; may be repeated")
+	rootCmd.PersistentFlags().StringVar(&oidcScopePrefix, "oidc-scope-prefix", "", "Prefix of the read, write, and exec scopes in OIDC tokens' scope or scp claim (e.g. claude-tools:)")
+	rootCmd.PersistentFlags().StringSliceVar(&oidcScopes, "oidc-default-scopes", nil, "Comma-separated scopes granted to OIDC tokens that carry none of the server's scopes")
+	rootCmd.PersistentFlags().StringVar(&pidFile, "pid-file", "", "File to write the server's process ID to while it runs")
+	rootCmd.PersistentFlags().BoolVar(&strict, "strict", false, "Run the doctor checks before serving and refuse to start if any fails")
+	rootCmd.PersistentFlags().BoolVar(&debugTools, "debug-tools", false, "Register the debug_state tool, which dumps tracked read files, shells, locks, sessions, and resource counters for operators and integration tests")
+	rootCmd.PersistentFlags().Int64Var(&maxFileSize, "max-file-size", 10*1024*1024, "Largest file in bytes that read returns in full; larger files can still be read with offset and limit")
+	rootCmd.PersistentFlags().IntVar(&maxOutputTokens, "max-output-tokens", 25000, "Largest tool output in tokens (about 4 characters each); clients can override it per call via _meta.max_output_tokens")
+	rootCmd.PersistentFlags().IntVar(&maxResults, "max-results", 1000, "Most entries returned by glob, grep, ls, and git listings; clients can override it per call via _meta.max_results")
+	rootCmd.PersistentFlags().IntVar(&rateLimit, "rate-limit", 0, "Requests per minute allowed per client, identified by bearer token or IP; 0 disables rate limiting")
+	rootCmd.PersistentFlags().IntVar(&rateBurst, "rate-burst", 0, "Requests a client may send in a burst before the rate limit applies; defaults to the rate limit")
+	rootCmd.PersistentFlags().StringArrayVar(&allowCIDRs, "allow-cidr", nil, "CIDR range or IP address allowed to use the server (e.g. 10.0.0.0/8); others get 403; may be repeated; defaults to any address")
+	rootCmd.PersistentFlags().StringArrayVar(&trustedProxies, "trusted-proxy", nil, "CIDR range or IP address of a reverse proxy whose X-Forwarded-For header identifies the client for --allow-cidr and --rate-limit; may be repeated")
+	rootCmd.PersistentFlags().BoolVar(&logBodies, "log-bodies", false, "Log every JSON-RPC request and response of the MCP endpoint to stderr as JSON lines, for debugging client interoperability")
+	rootCmd.PersistentFlags().StringArrayVar(&logRedact, "log-redact", nil, "JSON field whose values --log-bodies hides, in addition to password, secret, token, api_key, and similar (e.g. content); may be repeated")
+	rootCmd.PersistentFlags().IntVar(&logBodyMax, "log-body-max", 4096, "Bytes of each body --log-bodies logs before truncating; 0 logs bodies whole")
+	rootCmd.PersistentFlags().DurationVar(&slowCalls, "slow-call-threshold", 0, "Log tool calls taking at least this long (e.g. 5s) to stderr as JSON lines, with their arguments and time spent queued, executing, and serializing; 0 disables")
+	rootCmd.PersistentFlags().StringVar(&recordFile, "record", "", "Record every tool call and its result to this cassette file, for --replay")
+	rootCmd.PersistentFlags().StringVar(&replayFile, "replay", "", "Answer tool calls from this cassette file, written by --record, instead of running them, for deterministic tests of MCP clients")
+	rootCmd.PersistentFlags().IntVar(&compressMin, "compress-min-size", middleware.DefaultCompressMinSize, "Smallest HTTP response in bytes compressed with gzip or deflate for clients that accept it; 0 disables compression")
+	rootCmd.PersistentFlags().IntVar(&maxCommands, "max-concurrent-commands", 0, "Most foreground bash commands running at once across all clients; 0 is unlimited")
+	rootCmd.PersistentFlags().IntVar(&maxShells, "max-background-shells", 0, "Most background shells running at once across all clients; 0 is unlimited")
+	rootCmd.PersistentFlags().BoolVar(&spoolOutput, "spool-output", false, "Write background shell output to files instead of memory, unless a call sets spool_output to false")
+	rootCmd.PersistentFlags().StringVar(&spoolDir, "spool-dir", "", "Absolute directory for spooled output; defaults to claude-tools-spool in the system temp directory")
+	rootCmd.PersistentFlags().StringVar(&artifactsDir, "artifacts-dir", "", "Absolute directory for output saved by bash calls with save_output; defaults to claude-tools-artifacts in the system temp directory")
+	rootCmd.PersistentFlags().StringArrayVar(&denyPaths, "deny-path", nil, "Path or glob that file tools must not read, search, or modify (e.g. ~/.netrc, /srv/secrets, *.key); may be repeated")
+	rootCmd.PersistentFlags().BoolVar(&noDefaultDeny, "no-default-deny-paths", false, "Drop the built-in denylist of credential paths such as ~/.ssh, ~/.aws, and *.pem")
+	rootCmd.PersistentFlags().BoolVar(&confirmDelete, "confirm-delete", false, "Ask the user to confirm every delete_file call; requires --stateless=false and a client that supports elicitation")
+	rootCmd.PersistentFlags().StringArrayVar(&confirmCommands, "confirm-command", nil, "Regular expression for bash commands, scheduled commands, and REPL code the user must confirm before they run; may be repeated")
+	rootCmd.PersistentFlags().BoolVar(&confirmDanger, "confirm-dangerous-commands", false, "Ask the user to confirm destructive bash commands such as rm -rf, git push --force, and git reset --hard")
+	rootCmd.PersistentFlags().StringVar(&confirmOutside, "confirm-outside", "", "Absolute project directory; file changes outside it must be confirmed by the user")
+	rootCmd.PersistentFlags().StringVar(&approvalURL, "approval-webhook", "", "URL that must allow tool calls matching --approval-rule before they run; set APPROVAL_WEBHOOK_SECRET to sign requests")
+	rootCmd.PersistentFlags().StringArrayVar(&approvalRules, "approval-rule", nil, "Tool calls to send for approval, as tool:regexp matched against string arguments (e.g. bash:git\\s+push, write:/\\.github/); use * for any tool; may be repeated")
+	rootCmd.PersistentFlags().DurationVar(&approvalTimeout, "approval-timeout", 5*time.Minute, "How long to wait for the approval webhook before treating a call as denied")
+	rootCmd.PersistentFlags().BoolVar(&restAPI, "rest-api", false, "Also serve each tool as a JSON endpoint at /api/v1/tools/<name>, described by /api/v1/openapi.json")
+	rootCmd.PersistentFlags().BoolVar(&shellStream, "shell-stream", false, "Stream background shell output as server-sent events at /shells/<id>/stream (also served with --ui)")
+	rootCmd.PersistentFlags().BoolVar(&dashboard, "ui", false, "Serve a web dashboard at /ui showing background shells with live output, recent tool calls, and current limits")
+	rootCmd.PersistentFlags().StringSliceVar(&allowedShells, "allowed-shells", nil, "Comma-separated shells bash calls may select (bash, sh, zsh, fish, pwsh, python); defaults to all of them")
+	rootCmd.PersistentFlags().BoolVar(&loginShell, "login-shell", false, "Run bash commands in login shells (bash -lc) so they see the PATH and environment of the user's profile, unless a call sets login to false")
+	rootCmd.PersistentFlags().StringVar(&initScript, "init-script", "", "Absolute path of a script sourced before every bash, sh, zsh, or fish command, e.g. to activate nvm, pyenv, or direnv")
+	rootCmd.PersistentFlags().StringVar(&embeddingURL, "embedding-url", "", "OpenAI-compatible embeddings endpoint (e.g. https://api.openai.com/v1/embeddings) enabling semantic_index and semantic_search; set EMBEDDING_API_KEY to authenticate")
+	rootCmd.PersistentFlags().StringVar(&embeddingModel, "embedding-model", "text-embedding-3-small", "Embedding model requested from --embedding-url")
+	rootCmd.PersistentFlags().StringVar(&semanticDir, "semantic-index-dir", "", "Absolute directory for semantic search indexes; defaults to claude-tools-semantic in the user cache directory")
+	rootCmd.PersistentFlags().StringArrayVar(&languageServers, "language-server", nil, "Language server for definition, references, hover, and rename_symbol, as ext[,ext]=command [args] (e.g. .lua=lua-language-server); overrides the built-in server for those extensions; may be repeated")
+	rootCmd.PersistentFlags().StringArrayVar(&formatters, "formatter", nil, "Formatter for format_file, as ext[,ext]=command [args] reading stdin and writing stdout, with {file} replaced by the file's path (e.g. .sql=sqlfmt -); overrides the built-in formatter for those extensions; may be repeated")
+	rootCmd.PersistentFlags().BoolVar(&formatOnWrite, "format-on-write", false, "Format files changed by write and edit with their formatter, when one is configured and installed")
+	rootCmd.PersistentFlags().StringVar(&tokenizer, "tokenizer", "", "Default tokenizer for count_tokens: heuristic, chars, or command (default heuristic, or command when --tokenizer-command is set)")
+	rootCmd.PersistentFlags().StringVar(&tokenizerCmd, "tokenizer-command", "", "Command that reads text on stdin and prints its token count, used by count_tokens' command tokenizer (e.g. \"python3 count_tokens.py\")")
+	rootCmd.PersistentFlags().StringArrayVar(&typeAdd, "type-add", nil, "Define a custom file type for grep's type filter, as name:glob (e.g. proto:*.proto3); may be repeated")
 }
 
 func main() {
@@ -57,47 +193,389 @@ func setupHTTPServer(addr string, mcpHandler http.Handler) *http.Server {
 	}
 }
 
-func runServer(cmd *cobra.Command, args []string) error {
-	// Set up graceful shutdown context that responds to SIGINT and SIGTERM,
-	// allowing in-flight requests to complete before stopping the server.
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
+// listen returns the sockets passed by systemd socket activation, if any, or else a socket bound to
+// addr. Under socket activation systemd keeps the sockets open across restarts, queueing
+// connections until the new process accepts them.
+func listen(addr string) ([]net.Listener, error) {
+	listeners, err := systemd.Listeners()
+	if err != nil {
+		return nil, err
+	}
+	if len(listeners) > 0 {
+		return listeners, nil
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on %s: %w", addr, err)
+	}
+	return []net.Listener{listener}, nil
+}
 
-	// Initialize MCP server with tool definitions.
+// writePIDFile writes the process ID to path, if set.
+func writePIDFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644); err != nil {
+		return fmt.Errorf("cannot write PID file: %w", err)
+	}
+	return nil
+}
+
+// removePIDFile removes the PID file at path unless another process has since written its own ID
+// to it, as a newer instance started during a restart would.
+func removePIDFile(path string) {
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	_ = os.Remove(path)
+}
+
+// connectInProcess opens an MCP client session to mcpServer over an in-memory transport, returning
+// it with the server's end of the session. The REST API calls tools through it so that they pass
+// through the same middleware as MCP clients' calls, and the tools command lists them through it.
+func connectInProcess(ctx context.Context, mcpServer *mcp.Server) (*mcp.ClientSession, *mcp.ServerSession, error) {
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := mcpServer.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot connect in-process client to MCP server: %w", err)
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "claude-tools-in-process", Version: version}, nil)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot connect in-process client to MCP server: %w", err)
+	}
+	return session, serverSession, nil
+}
+
+// mountShellRoutes adds the routes that expose shell output when they are enabled: the output
+// stream with --shell-stream or --ui, whose dashboard tails shells through it, and the dashboard
+// with --ui.
+func mountShellRoutes(mux *http.ServeMux, requireExec func(http.Handler) http.Handler) {
+	if shellStream || dashboard {
+		mux.Handle(tools.ShellStreamPath, requireExec(tools.GetState().ShellStreamHandler()))
+	}
+	if dashboard {
+		ui := requireExec(tools.GetState().DashboardHandler())
+		mux.Handle(tools.DashboardPath, ui)
+		mux.Handle(tools.DashboardPath+"/", ui)
+	}
+}
+
+// configureState applies the server flags to the tools' shared state.
+func configureState() error {
+	if err := tools.GetState().SetDefaultFileMode(defaultFileMode); err != nil {
+		return err
+	}
+	if err := tools.GetState().ConfigureBackups(backup, backupDir); err != nil {
+		return err
+	}
+	tools.GetState().SetOverlayMode(overlay)
+	if err := configureFilesystem(tools.GetState()); err != nil {
+		return err
+	}
+	if err := tools.GetState().SetWorkspaces(workspaces); err != nil {
+		return err
+	}
+	if err := configureTunables(tools.GetState()); err != nil {
+		return err
+	}
+	if err := tools.GetState().ConfigureOIDC(oidcIssuer, oidcAudience, oidcClaims, oidcScopePrefix, oidcScopes); err != nil {
+		return err
+	}
+	if err := tools.GetState().SetSnapshotDir(snapshotDir); err != nil {
+		return err
+	}
+	if err := tools.GetState().ConfigureSpool(spoolOutput, spoolDir); err != nil {
+		return err
+	}
+	if err := tools.GetState().SetArtifactsDir(artifactsDir); err != nil {
+		return err
+	}
+	if err := tools.GetState().SetShellEnvironment(loginShell, initScript); err != nil {
+		return err
+	}
+	if err := tools.GetState().AddSearchTypes(typeAdd); err != nil {
+		return err
+	}
+	if err := tools.GetState().SetEmbedder(embeddingURL, embeddingModel, os.Getenv("EMBEDDING_API_KEY"), semanticDir); err != nil {
+		return err
+	}
+	if err := tools.GetState().SetLanguageServers(languageServers); err != nil {
+		return err
+	}
+	if err := tools.GetState().SetFormatters(formatters, formatOnWrite); err != nil {
+		return err
+	}
+	if err := tools.GetState().SetTokenizer(tokenizer, tokenizerCmd); err != nil {
+		return err
+	}
+	if recordFile != "" && replayFile != "" {
+		return fmt.Errorf("--record and --replay cannot be used together")
+	}
+	if err := tools.GetState().RecordCalls(recordFile); err != nil {
+		return err
+	}
+	if err := tools.GetState().ReplayCalls(replayFile); err != nil {
+		return err
+	}
+	return nil
+}
+
+// configureFilesystem gives s the filesystem --fs selects. An in-memory filesystem starts out
+// holding just the workspace roots, and rules out the features that would write to the disk
+// regardless.
+func configureFilesystem(s *tools.State) error {
+	switch filesystem {
+	case "disk":
+		return nil
+	case "memory":
+	default:
+		return fmt.Errorf("invalid --fs %q: must be disk or memory", filesystem)
+	}
+	for _, feature := range []struct {
+		flag    string
+		enabled bool
+	}{{"--backup", backup}, {"--overlay", overlay}, {"--format-on-write", formatOnWrite}} {
+		if feature.enabled {
+			return fmt.Errorf("%s cannot be used with --fs memory", feature.flag)
+		}
+	}
+	fsys := vfs.NewMemory()
+	for _, spec := range workspaces {
+		if _, root, ok := strings.Cut(spec, "="); ok && filepath.IsAbs(root) {
+			if err := fsys.MkdirAll(root, 0o755); err != nil {
+				return fmt.Errorf("cannot create workspace %s: %w", spec, err)
+			}
+		}
+	}
+	s.SetFilesystem(fsys)
+	return nil
+}
+
+// addTool registers a tool, timing its handler's execution for the slow-call log. Tools that do
+// not work on the configured filesystem are left out.
+func addTool[In, Out any](server *mcp.Server, tool *mcp.Tool, handler mcp.ToolHandlerFor[In, Out]) {
+	if !tools.GetState().ToolAvailable(tool.Name) {
+		return
+	}
+	mcp.AddTool(server, tool, tools.TimeExecution(handler))
+}
+
+// newMCPServer creates the MCP server with its middleware and every tool the flags enable.
+func newMCPServer() *mcp.Server {
 	mcpServer := mcp.NewServer(&mcp.Implementation{
 		Name:    "claude-tools",
 		Version: version,
 	}, nil)
+	mcpServer.AddReceivingMiddleware(tools.GetState().CallLogMiddleware, tools.GetState().AuthMiddleware, tools.GetState().OutputLimitsMiddleware, tools.GetState().ApprovalMiddleware, tools.GetState().CassetteMiddleware)
 
 	// Register all available tools.
-	mcp.AddTool(mcpServer, &tools.BashTool, tools.Bash)
-	mcp.AddTool(mcpServer, &tools.BashOutputTool, tools.BashOutput)
-	mcp.AddTool(mcpServer, &tools.ListShellsTool, tools.ListShells)
-	mcp.AddTool(mcpServer, &tools.KillShellTool, tools.KillShell)
-	mcp.AddTool(mcpServer, &tools.ReadTool, tools.Read)
-	mcp.AddTool(mcpServer, &tools.WriteTool, tools.Write)
-	mcp.AddTool(mcpServer, &tools.EditTool, tools.Edit)
-	mcp.AddTool(mcpServer, &tools.GlobTool, tools.Glob)
-	mcp.AddTool(mcpServer, &tools.GrepTool, tools.Grep)
+	addTool(mcpServer, &tools.BashTool, tools.Bash)
+	addTool(mcpServer, &tools.BashOutputTool, tools.BashOutput)
+	addTool(mcpServer, &tools.ListShellsTool, tools.ListShells)
+	addTool(mcpServer, &tools.KillShellTool, tools.KillShell)
+	addTool(mcpServer, &tools.KillAllShellsTool, tools.KillAllShells)
+	addTool(mcpServer, &tools.ReadTool, tools.Read)
+	addTool(mcpServer, &tools.WriteTool, tools.Write)
+	addTool(mcpServer, &tools.WriteManyTool, tools.WriteMany)
+	addTool(mcpServer, &tools.RenderTemplateTool, tools.RenderTemplate)
+	addTool(mcpServer, &tools.EditTool, tools.Edit)
+	addTool(mcpServer, &tools.GlobTool, tools.Glob)
+	addTool(mcpServer, &tools.FormatFileTool, tools.FormatFile)
+	addTool(mcpServer, &tools.ConfigEditTool, tools.ConfigEdit)
+	addTool(mcpServer, &tools.DiffTool, tools.Diff)
+	addTool(mcpServer, &tools.OutlineTool, tools.Outline)
+	addTool(mcpServer, &tools.GrepTool, tools.Grep)
+	addTool(mcpServer, &tools.StructuralSearchTool, tools.StructuralSearch)
+	addTool(mcpServer, &tools.SemanticIndexTool, tools.SemanticIndex)
+	addTool(mcpServer, &tools.SemanticSearchTool, tools.SemanticSearch)
+	addTool(mcpServer, &tools.DefinitionTool, tools.Definition)
+	addTool(mcpServer, &tools.ReferencesTool, tools.References)
+	addTool(mcpServer, &tools.HoverTool, tools.Hover)
+	addTool(mcpServer, &tools.RenameSymbolTool, tools.RenameSymbol)
+	addTool(mcpServer, &tools.CountTokensTool, tools.CountTokens)
+	addTool(mcpServer, &tools.PackContextTool, tools.PackContext)
+	addTool(mcpServer, &tools.ExtractTextTool, tools.ExtractText)
+	addTool(mcpServer, &tools.LsTool, tools.Ls)
+	addTool(mcpServer, &tools.MoveFileTool, tools.MoveFile)
+	addTool(mcpServer, &tools.CopyFileTool, tools.CopyFile)
+	addTool(mcpServer, &tools.DeleteFileTool, tools.DeleteFile)
+	addTool(mcpServer, &tools.CreateDirectoryTool, tools.CreateDirectory)
+	addTool(mcpServer, &tools.ArchiveTool, tools.Archive)
+	addTool(mcpServer, &tools.DiskUsageTool, tools.DiskUsage)
+	addTool(mcpServer, &tools.SystemInfoTool, tools.SystemInfo)
+	addTool(mcpServer, &tools.CheckPortTool, tools.CheckPort)
+	addTool(mcpServer, &tools.ListeningPortsTool, tools.ListeningPorts)
+	addTool(mcpServer, &tools.TodoWriteTool, tools.TodoWrite)
+	addTool(mcpServer, &tools.TaskTool, tools.Task)
+	addTool(mcpServer, &tools.GitTool, tools.Git)
+	addTool(mcpServer, &tools.WorktreeTool, tools.Worktree)
+	addTool(mcpServer, &tools.GeneratePatchTool, tools.GeneratePatch)
+	addTool(mcpServer, &tools.RestoreBackupTool, tools.RestoreBackup)
+	addTool(mcpServer, &tools.ListEditsTool, tools.ListEdits)
+	addTool(mcpServer, &tools.ListChangesTool, tools.ListChanges)
+	addTool(mcpServer, &tools.CommitChangesTool, tools.CommitChanges)
+	addTool(mcpServer, &tools.DiscardChangesTool, tools.DiscardChanges)
+	addTool(mcpServer, &tools.SnapshotTool, tools.Snapshot)
+	addTool(mcpServer, &tools.RestoreSnapshotTool, tools.RestoreSnapshot)
+	addTool(mcpServer, &tools.UndoEditTool, tools.UndoEdit)
+	addTool(mcpServer, &tools.ListSearchTypesTool, tools.ListSearchTypes)
+	addTool(mcpServer, &tools.ReplStartTool, tools.ReplStart)
+	addTool(mcpServer, &tools.ReplSendTool, tools.ReplSend)
+	addTool(mcpServer, &tools.ReplCloseTool, tools.ReplClose)
+	addTool(mcpServer, &tools.ScheduleCommandTool, tools.ScheduleCommand)
+	addTool(mcpServer, &tools.ListScheduledJobsTool, tools.ListScheduledJobs)
+	addTool(mcpServer, &tools.CancelScheduledJobTool, tools.CancelScheduledJob)
+	addTool(mcpServer, &tools.WatchPathTool, tools.WatchPath)
+	addTool(mcpServer, &tools.WatchEventsTool, tools.WatchEvents)
+	addTool(mcpServer, &tools.UnwatchTool, tools.Unwatch)
+	if debugTools {
+		addTool(mcpServer, &tools.DebugStateTool, tools.DebugState)
+	}
+	return mcpServer
+}
+
+func runServer(cmd *cobra.Command, args []string) error {
+	// Set up graceful shutdown context that responds to SIGINT and SIGTERM,
+	// allowing in-flight requests to complete before stopping the server.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := configureState(); err != nil {
+		return err
+	}
+	if strict {
+		checks := tools.GetState().Doctor(ctx)
+		if failed := countChecks(checks, tools.CheckFail); failed > 0 {
+			printDoctorReport(os.Stderr, checks)
+			return fmt.Errorf("preflight failed: %d checks failed", failed)
+		}
+	}
+
+	// Initialize MCP server with tool definitions.
+	mcpServer := newMCPServer()
 
 	// Stateless mode allows each HTTP request to be handled independently without
 	// session state, enabling horizontal scaling and simpler request handling.
-	mcpHandler := mcp.NewStreamableHTTPHandler(func(r *http.Request) *mcp.Server {
+	// Stateful mode keeps sessions open so notifications (e.g. background shell
+	// completion) can reach the client after the originating request returns.
+	// WebSocket connections are always stateful: each connection is one session.
+	getServer := func(r *http.Request) *mcp.Server {
 		return mcpServer
-	}, &mcp.StreamableHTTPOptions{
-		Stateless: true,
-	})
-
-	server := setupHTTPServer(addr, mcpHandler)
-
-	// Run server in goroutine to allow concurrent shutdown handling via select.
-	errCh := make(chan error, 1)
-	go func() {
-		fmt.Printf("MCP server listening on http://%s\n", addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			errCh <- fmt.Errorf("HTTP server error: %w", err)
+	}
+	var mcpHandler http.Handler
+	var wsHandler *websocket.Handler
+	switch transport {
+	case "http":
+		mcpHandler = mcp.NewStreamableHTTPHandler(getServer, &mcp.StreamableHTTPOptions{
+			Stateless: stateless,
+		})
+	case "ws":
+		wsHandler = websocket.NewHandler(getServer)
+		mcpHandler = wsHandler
+	default:
+		return fmt.Errorf("unknown transport %q, must be http or ws", transport)
+	}
+
+	// With API keys or OIDC, every route needs a valid token. Shell output and the dashboard expose
+	// what commands print, so they also need the exec scope.
+	requireKey := func(h http.Handler) http.Handler { return h }
+	requireExec := requireKey
+	if tools.GetState().AuthRequired() {
+		requireKey = auth.RequireBearerToken(tools.GetState().VerifyToken, nil)
+		requireExec = auth.RequireBearerToken(tools.GetState().VerifyToken, &auth.RequireBearerTokenOptions{Scopes: []string{tools.ScopeExec}})
+	}
+
+	mux := http.NewServeMux()
+	mcpRoute := requireKey(mcpHandler)
+	if logBodies {
+		// Outside authentication, so that refused requests are logged too.
+		mcpRoute = middleware.NewBodyLogger(os.Stderr, logRedact, logBodyMax).Handler(mcpRoute)
+	}
+	mux.Handle("/", mcpRoute)
+	mountShellRoutes(mux, requireExec)
+	if restAPI {
+		session, serverSession, err := connectInProcess(ctx, mcpServer)
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+		tools.GetState().SetRESTSession(serverSession)
+		mux.Handle(rest.PathPrefix, requireKey(rest.NewHandler(session, version)))
+	}
+	var handler http.Handler = mux
+	if compressMin < 0 {
+		return fmt.Errorf("compression threshold cannot be negative")
+	}
+	if compressMin > 0 {
+		handler = middleware.NewCompressor(compressMin).Handler(handler)
+	}
+	if rateLimit < 0 {
+		return fmt.Errorf("rate limit cannot be negative")
+	}
+	if rateLimit > 0 {
+		handler = middleware.NewRateLimiter(rateLimit, rateBurst).Handler(handler)
+	}
+	if len(allowCIDRs) > 0 {
+		allowed, err := middleware.ParsePrefixes(allowCIDRs)
+		if err != nil {
+			return fmt.Errorf("invalid --allow-cidr: %w", err)
+		}
+		handler = middleware.NewIPFilter(allowed).Handler(handler)
+	}
+	if len(trustedProxies) > 0 {
+		proxies, err := middleware.ParsePrefixes(trustedProxies)
+		if err != nil {
+			return fmt.Errorf("invalid --trusted-proxy: %w", err)
+		}
+		handler = middleware.NewTrustedProxies(proxies).Handler(handler)
+	}
+
+	server := setupHTTPServer(addr, handler)
+	if wsHandler != nil {
+		server.RegisterOnShutdown(wsHandler.CloseAll)
+	}
+	server.RegisterOnShutdown(tools.GetState().ShutdownLanguageServers)
+
+	listeners, err := listen(addr)
+	if err != nil {
+		return err
+	}
+	if err := writePIDFile(pidFile); err != nil {
+		for _, listener := range listeners {
+			listener.Close()
 		}
-	}()
+		return err
+	}
+	defer removePIDFile(pidFile)
+
+	// Run server in goroutines, one per listener, to allow concurrent shutdown handling via select.
+	errCh := make(chan error, len(listeners))
+	scheme := "http"
+	if transport == "ws" {
+		scheme = "ws"
+	}
+	for _, listener := range listeners {
+		fmt.Printf("MCP server listening on %s://%s\n", scheme, listener.Addr())
+		go func() {
+			if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+				errCh <- fmt.Errorf("HTTP server error: %w", err)
+			}
+		}()
+	}
+	// Tell a Type=notify unit that the server is ready, so that units ordered after it start only
+	// once it accepts connections.
+	notifier := systemd.NewNotifier()
+	if err := notifier.Notify("READY=1", "STATUS=Serving MCP requests"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	go watchConfig(ctx, cmd.Flags())
 
 	// Wait for either server error or shutdown signal.
 	select {
@@ -105,6 +583,7 @@ func runServer(cmd *cobra.Command, args []string) error {
 		return err
 	case <-ctx.Done():
 		fmt.Println("\nShutting down server...")
+		_ = notifier.Notify("STOPPING=1", "STATUS=Shutting down")
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
 		defer cancel()
 		if err := server.Shutdown(shutdownCtx); err != nil {
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMountShellRoutes(t *testing.T) {
+	defer func(stream, ui bool) { shellStream, dashboard = stream, ui }(shellStream, dashboard)
+	noAuth := func(h http.Handler) http.Handler { return h }
+
+	tests := []struct {
+		name       string
+		stream, ui bool
+		streamed   bool
+		uiStatus   int
+	}{
+		{"off by default", false, false, false, http.StatusNotFound},
+		{"shell stream", true, false, true, http.StatusNotFound},
+		{"dashboard serves the stream too", false, true, true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shellStream, dashboard = tt.stream, tt.ui
+			mux := http.NewServeMux()
+			mountShellRoutes(mux, noAuth)
+
+			// The stream handler answers 404 for unknown shells too, so check the route itself.
+			req := httptest.NewRequest(http.MethodGet, "/shells/shell_1/stream", nil)
+			_, pattern := mux.Handler(req)
+			assert.Equal(t, tt.streamed, pattern != "")
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+
+			rec = httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui", nil))
+			assert.Equal(t, tt.uiStatus, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// redactedValue replaces the values of redacted fields and headers.
+const redactedValue = "[REDACTED]"
+
+// DefaultRedactedFields are the JSON fields whose values the body logger always hides.
+var DefaultRedactedFields = []string{"password", "secret", "token", "access_token", "refresh_token", "api_key", "authorization"}
+
+// redactedHeaders are the request headers whose values are never logged.
+var redactedHeaders = map[string]bool{"Authorization": true, "Cookie": true, "Proxy-Authorization": true}
+
+// BodyLogger logs the full JSON-RPC traffic of the MCP endpoint as JSON lines, for diagnosing
+// clients that do not interoperate: each request with its headers and body, and each response, or
+// each event of a streamed response, with its body. Values of sensitive fields are redacted and
+// bodies are truncated.
+type BodyLogger struct {
+	out      io.Writer
+	redact   map[string]bool
+	maxBytes int
+
+	mu     sync.Mutex
+	nextID atomic.Int64
+}
+
+// NewBodyLogger returns a logger writing to out that redacts the values of DefaultRedactedFields
+// and the given fields, matched case-insensitively at any depth, and truncates bodies to maxBytes.
+// A maxBytes below 1 keeps bodies whole.
+func NewBodyLogger(out io.Writer, redact []string, maxBytes int) *BodyLogger {
+	fields := make(map[string]bool)
+	for _, field := range append(append([]string(nil), DefaultRedactedFields...), redact...) {
+		fields[strings.ToLower(field)] = true
+	}
+	return &BodyLogger{out: out, redact: fields, maxBytes: maxBytes}
+}
+
+// bodyLogEntry is one logged line.
+type bodyLogEntry struct {
+	Time       time.Time         `json:"time"`
+	ID         int64             `json:"id"`
+	Direction  string            `json:"direction"`
+	Method     string            `json:"method,omitempty"`
+	Path       string            `json:"path,omitempty"`
+	Client     string            `json:"client,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Status     int               `json:"status,omitempty"`
+	DurationMs *int64            `json:"duration_ms,omitempty"`
+	Body       json.RawMessage   `json:"body,omitempty"`
+	Truncated  bool              `json:"truncated,omitempty"`
+}
+
+// Handler logs each request before passing it on, then its response once complete. Responses
+// streamed as server-sent events are logged one event at a time as they are written.
+func (l *BodyLogger) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := l.nextID.Add(1)
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, "cannot read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		entry := bodyLogEntry{Time: time.Now(), ID: id, Direction: "request", Method: r.Method, Path: r.URL.RequestURI(), Headers: l.headers(r.Header)}
+		if addr, ok := ClientIP(r); ok {
+			entry.Client = addr.String()
+		}
+		entry.Body, entry.Truncated = l.body(body)
+		l.write(entry)
+
+		start := time.Now()
+		recorder := &bodyRecorder{ResponseWriter: w, logger: l, id: id}
+		next.ServeHTTP(recorder, r)
+		if recorder.hijacked {
+			return
+		}
+		recorder.flushEvents(true)
+		duration := time.Since(start).Milliseconds()
+		response := bodyLogEntry{Time: time.Now(), ID: id, Direction: "response", Status: recorder.status(), DurationMs: &duration}
+		if !recorder.streaming {
+			response.Body, response.Truncated = l.body(recorder.body.Bytes())
+		}
+		l.write(response)
+	})
+}
+
+func (l *BodyLogger) headers(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		if redactedHeaders[name] {
+			headers[name] = redactedValue
+		} else {
+			headers[name] = strings.Join(values, ", ")
+		}
+	}
+	return headers
+}
+
+// body returns data for logging: as JSON with redacted fields if it parses and fits in maxBytes,
+// and otherwise as a JSON string of its first maxBytes bytes.
+func (l *BodyLogger) body(data []byte) (json.RawMessage, bool) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, false
+	}
+	var value any
+	if err := json.Unmarshal(data, &value); err == nil {
+		if redacted, err := json.Marshal(l.redactValue(value)); err == nil {
+			data = redacted
+			if l.maxBytes < 1 || len(data) <= l.maxBytes {
+				return data, false
+			}
+		}
+	}
+	truncated := l.maxBytes > 0 && len(data) > l.maxBytes
+	if truncated {
+		data = bytes.ToValidUTF8(data[:l.maxBytes], nil)
+	}
+	text, _ := json.Marshal(string(data))
+	return text, truncated
+}
+
+// redactValue replaces the values of redacted fields within a decoded JSON value.
+func (l *BodyLogger) redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if l.redact[strings.ToLower(key)] {
+				v[key] = redactedValue
+			} else {
+				v[key] = l.redactValue(item)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = l.redactValue(item)
+		}
+	}
+	return value
+}
+
+func (l *BodyLogger) write(entry bodyLogEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.out.Write(append(line, '\n'))
+}
+
+// bodyRecorder captures a response for the body logger while passing it through. It keeps the
+// Flusher and Hijacker of the underlying writer, which streamed responses and WebSocket upgrades
+// need.
+type bodyRecorder struct {
+	http.ResponseWriter
+	logger *BodyLogger
+	id     int64
+
+	code      int
+	body      bytes.Buffer
+	streaming bool
+	hijacked  bool
+}
+
+func (b *bodyRecorder) WriteHeader(code int) {
+	if b.code == 0 {
+		b.code = code
+		b.streaming = strings.HasPrefix(b.Header().Get("Content-Type"), "text/event-stream")
+	}
+	b.ResponseWriter.WriteHeader(code)
+}
+
+func (b *bodyRecorder) Write(p []byte) (int, error) {
+	if b.code == 0 {
+		b.WriteHeader(http.StatusOK)
+	}
+	if b.streaming {
+		b.body.Write(p)
+		b.flushEvents(false)
+	} else if b.logger.maxBytes < 1 || b.body.Len() <= 2*b.logger.maxBytes {
+		// Beyond twice the limit the body is too long to log as JSON anyway.
+		b.body.Write(p)
+	}
+	return b.ResponseWriter.Write(p)
+}
+
+// flushEvents logs each complete server-sent event in the buffer, or with final, whatever remains.
+func (b *bodyRecorder) flushEvents(final bool) {
+	if !b.streaming {
+		return
+	}
+	for {
+		data := b.body.Bytes()
+		end := bytes.Index(data, []byte("\n\n"))
+		if end < 0 {
+			if final && len(bytes.TrimSpace(data)) > 0 {
+				end = len(data)
+			} else {
+				return
+			}
+		}
+		event := data[:end]
+		b.body.Next(min(end+2, len(data)))
+		var payload []string
+		for _, line := range strings.Split(string(event), "\n") {
+			if rest, ok := strings.CutPrefix(line, "data:"); ok {
+				payload = append(payload, strings.TrimPrefix(rest, " "))
+			}
+		}
+		if len(payload) == 0 {
+			// Comments and keep-alives carry no message.
+			continue
+		}
+		entry := bodyLogEntry{Time: time.Now(), ID: b.id, Direction: "event"}
+		entry.Body, entry.Truncated = b.logger.body([]byte(strings.Join(payload, "\n")))
+		b.logger.write(entry)
+	}
+}
+
+func (b *bodyRecorder) status() int {
+	if b.code == 0 {
+		return http.StatusOK
+	}
+	return b.code
+}
+
+func (b *bodyRecorder) Flush() {
+	if flusher, ok := b.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (b *bodyRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := b.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	b.hijacked = true
+	return hijacker.Hijack()
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logEntries decodes the JSON lines written by a BodyLogger.
+func logEntries(t *testing.T, out *bytes.Buffer) []bodyLogEntry {
+	var entries []bodyLogEntry
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var entry bodyLogEntry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestBodyLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewBodyLogger(&out, []string{"Content"}, 200)
+	handler := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The wrapped handler still sees the whole request body.
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "hunter2")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"text":"` + strings.Repeat("x", 300) + `"}}`))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"write","arguments":{"content":"file body","password":"hunter2"}}}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Mcp-Session-Id", "abc")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logEntries(t, &out)
+	require.Len(t, entries, 2)
+	request := entries[0]
+	assert.Equal(t, "request", request.Direction)
+	assert.Equal(t, redactedValue, request.Headers["Authorization"])
+	assert.Equal(t, "abc", request.Headers["Mcp-Session-Id"])
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"write","arguments":{"content":"[REDACTED]","password":"[REDACTED]"}}}`, string(request.Body))
+	assert.NotContains(t, out.String(), "hunter2")
+	assert.NotContains(t, out.String(), "secret")
+
+	response := entries[1]
+	assert.Equal(t, "response", response.Direction)
+	assert.Equal(t, request.ID, response.ID)
+	assert.Equal(t, http.StatusOK, response.Status)
+	assert.True(t, response.Truncated)
+	var text string
+	require.NoError(t, json.Unmarshal(response.Body, &text))
+	assert.Len(t, text, 200)
+}
+
+func TestBodyLogger_Events(t *testing.T) {
+	var out bytes.Buffer
+	handler := NewBodyLogger(&out, nil, 0).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n: keep-alive\n\n")
+		w.(http.Flusher).Flush()
+		_, _ = io.WriteString(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":1,")
+		_, _ = io.WriteString(w, "\"result\":{\"token\":\"t\"}}\n\n")
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, rec.Flushed)
+
+	entries := logEntries(t, &out)
+	require.Len(t, entries, 4)
+	assert.Equal(t, "request", entries[0].Direction)
+	assert.Empty(t, entries[0].Body)
+	assert.Equal(t, "event", entries[1].Direction)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","method":"notifications/progress"}`, string(entries[1].Body))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{"token":"[REDACTED]"}}`, string(entries[2].Body))
+	assert.Equal(t, "response", entries[3].Direction)
+	assert.Empty(t, entries[3].Body)
+}
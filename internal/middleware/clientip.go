@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// ParsePrefixes parses CIDR ranges such as 10.0.0.0/8, or bare addresses, which match only
+// themselves.
+func ParsePrefixes(specs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(specs))
+	for _, spec := range specs {
+		if prefix, err := netip.ParsePrefix(spec); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR or IP address %q", spec)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// TrustedProxies resolves the client address of requests relayed by reverse proxies, so that the
+// allowlist and per-client limits apply to the real caller rather than the proxy.
+type TrustedProxies struct {
+	proxies []netip.Prefix
+}
+
+// NewTrustedProxies returns a resolver trusting the X-Forwarded-For header of requests from the
+// given proxies.
+func NewTrustedProxies(proxies []netip.Prefix) *TrustedProxies {
+	return &TrustedProxies{proxies: proxies}
+}
+
+// Handler records each request's client address for ClientIP. For a request from a trusted proxy,
+// that is the rightmost X-Forwarded-For entry not itself a trusted proxy: entries to its left were
+// written by the client, which could claim any address.
+func (p *TrustedProxies) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, ok := p.clientIP(r); ok {
+			r = r.WithContext(context.WithValue(r.Context(), clientIPKey{}, addr))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *TrustedProxies) clientIP(r *http.Request) (netip.Addr, bool) {
+	addr, ok := remoteIP(r)
+	if !ok || !containsAddr(p.proxies, addr) {
+		return addr, ok
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A malformed entry ends the chain that can be trusted; the last proxy is the client.
+			break
+		}
+		addr = hop.Unmap()
+		if !containsAddr(p.proxies, addr) {
+			break
+		}
+	}
+	return addr, true
+}
+
+// ClientIP returns the address of the client that sent r: the one resolved by TrustedProxies, if
+// it handled r, and otherwise the remote address of the connection.
+func ClientIP(r *http.Request) (netip.Addr, bool) {
+	if addr, ok := r.Context().Value(clientIPKey{}).(netip.Addr); ok {
+		return addr, true
+	}
+	return remoteIP(r)
+}
+
+func remoteIP(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// IPFilter admits only clients whose address lies in an allowed range.
+type IPFilter struct {
+	allowed []netip.Prefix
+}
+
+// NewIPFilter returns a filter admitting clients in the allowed ranges.
+func NewIPFilter(allowed []netip.Prefix) *IPFilter {
+	return &IPFilter{allowed: allowed}
+}
+
+// forbiddenResponse is the JSON body of a 403 response.
+type forbiddenResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// Handler rejects requests from clients outside the allowed ranges with 403 Forbidden and a JSON
+// body. Clients are identified by ClientIP, so behind a reverse proxy TrustedProxies must run
+// first.
+func (f *IPFilter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, ok := ClientIP(r); ok && containsAddr(f.allowed, addr) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		_ = json.NewEncoder(w).Encode(forbiddenResponse{
+			Error:   "forbidden",
+			Message: "This client's address is not allowed to use the server.",
+		})
+	})
+}
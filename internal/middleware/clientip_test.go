@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePrefixes(t *testing.T) {
+	prefixes, err := ParsePrefixes([]string{"10.1.2.3/8", "192.0.2.7", "2001:db8::/32", "::ffff:198.51.100.1"})
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.7/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+		netip.MustParsePrefix("198.51.100.1/32"),
+	}, prefixes)
+
+	_, err = ParsePrefixes([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+	_, err = ParsePrefixes([]string{"example.com"})
+	assert.Error(t, err)
+}
+
+func TestTrustedProxies(t *testing.T) {
+	proxies, err := ParsePrefixes([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	// resolve sends a request from remote with the given X-Forwarded-For headers and returns the
+	// client address and key seen behind the proxy handler.
+	resolve := func(remote string, forwarded ...string) (string, string) {
+		var ip, key string
+		handler := NewTrustedProxies(proxies).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr, _ := ClientIP(r)
+			ip, key = addr.String(), ClientKey(r)
+		}))
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = remote
+		for _, header := range forwarded {
+			req.Header.Add("X-Forwarded-For", header)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return ip, key
+	}
+
+	ip, key := resolve("10.0.0.5:4000", "203.0.113.9")
+	assert.Equal(t, "203.0.113.9", ip)
+	assert.Equal(t, "ip:203.0.113.9", key)
+	// Entries left of the first untrusted hop were written by the client and are ignored.
+	ip, _ = resolve("10.0.0.5:4000", "1.2.3.4, 203.0.113.9, 10.0.0.6")
+	assert.Equal(t, "203.0.113.9", ip)
+	ip, _ = resolve("10.0.0.5:4000", "1.2.3.4", "203.0.113.9")
+	assert.Equal(t, "203.0.113.9", ip)
+	// Untrusted peers cannot spoof their address.
+	ip, _ = resolve("198.51.100.2:4000", "10.0.0.1")
+	assert.Equal(t, "198.51.100.2", ip)
+	// Without a usable header the proxy itself is the client.
+	ip, _ = resolve("10.0.0.5:4000")
+	assert.Equal(t, "10.0.0.5", ip)
+	ip, _ = resolve("10.0.0.5:4000", "garbage")
+	assert.Equal(t, "10.0.0.5", ip)
+	ip, _ = resolve("[::ffff:10.0.0.5]:4000", "203.0.113.9")
+	assert.Equal(t, "203.0.113.9", ip)
+}
+
+func TestIPFilter(t *testing.T) {
+	allowed, err := ParsePrefixes([]string{"192.0.2.0/24", "2001:db8::1"})
+	require.NoError(t, err)
+	proxies, err := ParsePrefixes([]string{"10.0.0.1"})
+	require.NoError(t, err)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := NewTrustedProxies(proxies).Handler(NewIPFilter(allowed).Handler(ok))
+
+	request := func(remote, forwarded string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = remote
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, request("192.0.2.44:1000", "").Code)
+	assert.Equal(t, http.StatusOK, request("[2001:db8::1]:1000", "").Code)
+	assert.Equal(t, http.StatusOK, request("10.0.0.1:1000", "192.0.2.44").Code)
+	rec := request("10.0.0.1:1000", "198.51.100.1")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"error":"forbidden"`)
+	assert.Equal(t, http.StatusForbidden, request("198.51.100.1:1000", "192.0.2.44").Code)
+}
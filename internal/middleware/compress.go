@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCompressMinSize is the smallest response worth compressing; below it the encoding
+// overhead outweighs the savings.
+const DefaultCompressMinSize = 1024
+
+var (
+	gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	zlibWriters = sync.Pool{New: func() any { return zlib.NewWriter(io.Discard) }}
+)
+
+// Compressor compresses textual responses with gzip or deflate, as negotiated by the client's
+// Accept-Encoding header. Large read and grep results shrink severalfold, which matters to remote
+// clients on slow links.
+type Compressor struct {
+	minSize int
+}
+
+// NewCompressor returns a compressor leaving responses under minSize bytes uncompressed. A minSize
+// below 1 defaults to DefaultCompressMinSize.
+func NewCompressor(minSize int) *Compressor {
+	if minSize < 1 {
+		minSize = DefaultCompressMinSize
+	}
+	return &Compressor{minSize: minSize}
+}
+
+// Handler compresses responses for clients that accept gzip or deflate. A response is buffered
+// until it reaches the size threshold, then compressed as it is written. Streamed responses are
+// flushed through the compressor, so each server-sent event still arrives as it is sent; a stream
+// whose first flush is under the threshold is left uncompressed.
+func (c *Compressor) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		// WebSocket upgrades hijack the connection, and HEAD responses have no body.
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: c.minSize}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header, by quality and
+// preferring gzip, or returns "" if the client accepts neither.
+func negotiateEncoding(header string) string {
+	quality := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		quality[strings.ToLower(strings.TrimSpace(coding))] = q
+	}
+	best, bestQ := "", 0.0
+	for _, coding := range []string{"gzip", "deflate"} {
+		q, ok := quality[coding]
+		if !ok {
+			q, ok = quality["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether a response of the given content type is worth compressing: text,
+// JSON, and other textual formats, but not images or archives, which are compressed already.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" ||
+		mediaType == "application/javascript" ||
+		mediaType == "application/xml" ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml")
+}
+
+// compressWriter holds back a response until it knows whether to compress it: once the body
+// reaches minSize, or at the first flush or the end of the response.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	code    int
+	buf     []byte
+	decided bool
+	encoder io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.code != 0 {
+		return
+	}
+	cw.code = code
+	// Responses without a body, or that are informational, pass straight through.
+	if code < 200 || code == http.StatusNoContent || code == http.StatusNotModified {
+		cw.decided = true
+		cw.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.code == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide commits to compressing the response or not, given what has been buffered, and writes the
+// header and buffered body.
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	header := cw.Header()
+	if header.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	if compressible(header.Get("Content-Type")) {
+		header.Add("Vary", "Accept-Encoding")
+		if len(cw.buf) >= cw.minSize && header.Get("Content-Encoding") == "" {
+			header.Set("Content-Encoding", cw.encoding)
+			header.Del("Content-Length")
+			cw.encoder = newEncoder(cw.encoding, cw.ResponseWriter)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.code)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (cw *compressWriter) Flush() {
+	if cw.code == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		_ = cw.decide()
+	}
+	if flusher, ok := cw.encoder.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// close writes whatever is still held back and finishes the compressed stream.
+func (cw *compressWriter) close() {
+	if cw.code == 0 {
+		// The handler wrote nothing; net/http sends its default response.
+		return
+	}
+	if !cw.decided {
+		_ = cw.decide()
+	}
+	if cw.encoder == nil {
+		return
+	}
+	_ = cw.encoder.Close()
+	switch encoder := cw.encoder.(type) {
+	case *gzip.Writer:
+		gzipWriters.Put(encoder)
+	case *zlib.Writer:
+		zlibWriters.Put(encoder)
+	}
+}
+
+func newEncoder(encoding string, w io.Writer) io.WriteCloser {
+	if encoding == "gzip" {
+		gz := gzipWriters.Get().(*gzip.Writer)
+		gz.Reset(w)
+		return gz
+	}
+	zw := zlibWriters.Get().(*zlib.Writer)
+	zw.Reset(w)
+	return zw
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0, deflate;q=0", ""},
+		{"*", "gzip"},
+		{"br, identity", ""},
+		{"GZIP ; q=0.8", "gzip"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, negotiateEncoding(tc.header), tc.header)
+	}
+}
+
+func TestCompressor(t *testing.T) {
+	large := `{"text":"` + strings.Repeat("line of output\n", 200) + `"}`
+	serve := func(acceptEncoding, contentType, body string) *httptest.ResponseRecorder {
+		handler := NewCompressor(1024).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Length", "999")
+			_, _ = io.WriteString(w, body)
+		}))
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("gzip", "application/json", large)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	assert.Empty(t, rec.Header().Get("Content-Length"))
+	assert.Less(t, rec.Body.Len(), len(large)/4)
+	gz, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, large, string(body))
+
+	rec = serve("deflate", "application/json", large)
+	assert.Equal(t, "deflate", rec.Header().Get("Content-Encoding"))
+	zr, err := zlib.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err = io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, large, string(body))
+
+	// Small responses, clients that do not ask, and binary content pass through unchanged.
+	rec = serve("gzip", "application/json", `{"ok":true}`)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	assert.Equal(t, `{"ok":true}`, rec.Body.String())
+	rec = serve("", "application/json", large)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, rec.Body.String())
+	rec = serve("gzip", "image/png", large)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, rec.Body.String())
+}
+
+func TestCompressor_Events(t *testing.T) {
+	event := "event: message\ndata: " + strings.Repeat("x", 2000) + "\n\n"
+	flushed := make(chan struct{})
+	release := make(chan struct{})
+	handler := NewCompressor(1024).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, event)
+		w.(http.Flusher).Flush()
+		close(flushed)
+		<-release
+		_, _ = io.WriteString(w, event)
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+
+	// The first event arrives whole before the handler writes the second.
+	<-flushed
+	gz, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+	first := make([]byte, len(event))
+	_, err = io.ReadFull(gz, first)
+	require.NoError(t, err)
+	assert.Equal(t, event, string(first))
+	close(release)
+	rest, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, event, string(rest))
+}
+
+func TestCompressor_SmallStream(t *testing.T) {
+	handler := NewCompressor(1024).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		_, _ = io.WriteString(w, "data: "+strings.Repeat("x", 2000)+"\n\n")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.True(t, rec.Flushed)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Len(t, rec.Body.String(), 2008)
+}
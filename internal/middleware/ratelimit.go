@@ -0,0 +1,123 @@
+// Package middleware provides HTTP middleware that wraps the MCP endpoint.
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTrackedClients bounds the rate limiter's per-client state. Beyond it, clients whose buckets
+// have refilled completely are forgotten, which loses nothing since a new bucket starts full.
+const maxTrackedClients = 10000
+
+// RateLimiter enforces a per-client request rate with a token bucket: each client may burst up to
+// burst requests, refilled continuously at the configured rate.
+type RateLimiter struct {
+	perSecond float64
+	burst     float64
+	now       func() time.Time
+
+	mu      sync.Mutex
+	clients map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter allowing perMinute requests per minute per client, with bursts of
+// up to burst requests. A burst below 1 defaults to perMinute.
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = perMinute
+	}
+	return &RateLimiter{
+		perSecond: float64(perMinute) / 60,
+		burst:     float64(burst),
+		now:       time.Now,
+		clients:   make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from the client's bucket. When the bucket is empty it returns false and how
+// long until a token becomes available.
+func (l *RateLimiter) Allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.clients[client]
+	if !ok {
+		if len(l.clients) >= maxTrackedClients {
+			l.prune(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.clients[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.perSecond)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.perSecond * float64(time.Second))
+	return false, wait
+}
+
+func (l *RateLimiter) prune(now time.Time) {
+	for client, b := range l.clients {
+		if b.tokens+now.Sub(b.last).Seconds()*l.perSecond >= l.burst {
+			delete(l.clients, client)
+		}
+	}
+}
+
+// rateLimitedResponse is the JSON body of a 429 response.
+type rateLimitedResponse struct {
+	Error        string `json:"error"`
+	Message      string `json:"message"`
+	RetryAfterMs int64  `json:"retry_after_ms"`
+}
+
+// Handler rejects requests from clients over their rate with 429 Too Many Requests, a Retry-After
+// header, and a JSON body describing when to retry.
+func (l *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := l.Allow(ClientKey(r))
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+		w.WriteHeader(http.StatusTooManyRequests)
+		_ = json.NewEncoder(w).Encode(rateLimitedResponse{
+			Error:        "rate_limited",
+			Message:      "Too many requests from this client. Retry after the indicated delay.",
+			RetryAfterMs: wait.Milliseconds(),
+		})
+	})
+}
+
+// ClientKey identifies the caller of r for per-client limits: by bearer token when one is sent, so
+// clients sharing an address are told apart, and otherwise by client IP (see ClientIP). Tokens are
+// hashed so they are never held in memory longer than the request.
+func ClientKey(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+		sum := sha256.Sum256([]byte(token))
+		return "token:" + hex.EncodeToString(sum[:8])
+	}
+	if addr, ok := ClientIP(r); ok {
+		return "ip:" + addr.String()
+	}
+	return "ip:" + r.RemoteAddr
+}
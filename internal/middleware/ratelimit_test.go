@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := NewRateLimiter(60, 2)
+	limiter.now = func() time.Time { return now }
+
+	t.Run("burst then refill", func(t *testing.T) {
+		ok, _ := limiter.Allow("a")
+		assert.True(t, ok)
+		ok, _ = limiter.Allow("a")
+		assert.True(t, ok)
+		ok, wait := limiter.Allow("a")
+		assert.False(t, ok)
+		assert.Equal(t, time.Second, wait)
+
+		now = now.Add(time.Second)
+		ok, _ = limiter.Allow("a")
+		assert.True(t, ok)
+	})
+
+	t.Run("clients are independent", func(t *testing.T) {
+		ok, _ := limiter.Allow("b")
+		assert.True(t, ok)
+	})
+}
+
+func TestRateLimiter_Handler(t *testing.T) {
+	limiter := NewRateLimiter(1, 1)
+	handler := limiter.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, request("10.0.0.1:1234").Code)
+
+	rec := request("10.0.0.1:5678")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	var body rateLimitedResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "rate_limited", body.Error)
+	assert.Positive(t, body.RetryAfterMs)
+
+	assert.Equal(t, http.StatusOK, request("10.0.0.2:1234").Code)
+}
+
+func TestClientKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = "192.0.2.1:4000"
+	assert.Equal(t, "ip:192.0.2.1", ClientKey(req))
+
+	req.Header.Set("Authorization", "Bearer secret")
+	key := ClientKey(req)
+	assert.Contains(t, key, "token:")
+	assert.NotContains(t, key, "secret")
+}
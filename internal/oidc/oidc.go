@@ -0,0 +1,409 @@
+// Package oidc verifies JWT access tokens issued by an OpenID Connect provider, using the signing
+// keys the provider publishes at the jwks_uri of its discovery document.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultKeysTTL is how long signing keys are cached when the JWKS response does not say.
+	defaultKeysTTL = time.Hour
+	// minKeysTTL and maxKeysTTL bound the lifetime a JWKS response's Cache-Control may ask for.
+	minKeysTTL = time.Minute
+	maxKeysTTL = 24 * time.Hour
+	// minRefreshInterval is how often a token signed by an unknown key may trigger a refetch, so that
+	// forged key IDs cannot make the server hammer the provider.
+	minRefreshInterval = time.Minute
+	// clockSkew is the leeway allowed when checking exp and nbf.
+	clockSkew = time.Minute
+	// maxResponseBody bounds discovery and JWKS responses.
+	maxResponseBody = 1024 * 1024
+)
+
+// ErrInvalidToken is wrapped by every error Verify returns for a token that is malformed, badly
+// signed, expired, or fails a claim check, as opposed to a failure to reach the provider.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Config configures a Verifier.
+type Config struct {
+	// Issuer is the provider's issuer URL, which tokens must carry in iss.
+	Issuer string
+	// Audience must appear in the tokens' aud claim.
+	Audience string
+	// Claims are further claims tokens must carry: a string claim must equal the value and a list
+	// claim must contain it, e.g. {"groups": "platform"}.
+	Claims map[string]string
+	// HTTPClient fetches the discovery document and keys. Nil uses a client with a 10s timeout.
+	HTTPClient *http.Client
+}
+
+// Claims are a verified token's claims.
+type Claims map[string]any
+
+// String returns the string claim name, or "" if it is missing or not a string.
+func (c Claims) String(name string) string {
+	value, _ := c[name].(string)
+	return value
+}
+
+// Expiration returns a verified token's exp claim.
+func (c Claims) Expiration() time.Time {
+	exp, _ := c["exp"].(float64)
+	return time.Unix(int64(exp), 0)
+}
+
+// Verifier verifies tokens from one provider. It is safe for concurrent use.
+type Verifier struct {
+	config  Config
+	client  *http.Client
+	jwksURI string
+	now     func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	expires   time.Time
+	fetchedAt time.Time
+}
+
+// NewVerifier fetches the provider's discovery document and signing keys, so that a misconfigured
+// issuer is reported at startup rather than on the first request.
+func NewVerifier(ctx context.Context, config Config) (*Verifier, error) {
+	if config.Issuer == "" || config.Audience == "" {
+		return nil, errors.New("an issuer and an audience are required")
+	}
+	v := &Verifier{config: config, client: config.HTTPClient, now: time.Now}
+	if v.client == nil {
+		v.client = &http.Client{Timeout: 10 * time.Second}
+	}
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if _, err := v.getJSON(ctx, strings.TrimSuffix(config.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("cannot fetch OIDC discovery document: %w", err)
+	}
+	if discovery.Issuer != config.Issuer {
+		return nil, fmt.Errorf("discovery document names issuer %q, not %q", discovery.Issuer, config.Issuer)
+	}
+	if discovery.JWKSURI == "" {
+		return nil, errors.New("discovery document has no jwks_uri")
+	}
+	v.jwksURI = discovery.JWKSURI
+	if err := v.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Verify checks token's signature against the provider's keys, then its issuer, audience,
+// expiry, and configured claims, and returns its claims.
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a JWT", ErrInvalidToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: bad header: %s", ErrInvalidToken, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad signature encoding", ErrInvalidToken)
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: bad claims: %s", ErrInvalidToken, err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+func (v *Verifier) checkClaims(claims Claims) error {
+	now := v.now()
+	if claims.String("iss") != v.config.Issuer {
+		return fmt.Errorf("issued by %q", claims.String("iss"))
+	}
+	if !containsClaim(claims["aud"], v.config.Audience) {
+		return fmt.Errorf("not issued for audience %q", v.config.Audience)
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("no exp claim")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return errors.New("expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("not valid yet")
+	}
+	for name, want := range v.config.Claims {
+		if !containsClaim(claims[name], want) {
+			return fmt.Errorf("claim %s does not match %q", name, want)
+		}
+	}
+	return nil
+}
+
+// containsClaim reports whether a claim is the string want or a list containing it.
+func containsClaim(claim any, want string) bool {
+	switch c := claim.(type) {
+	case string:
+		return c == want
+	case []any:
+		return slices.Contains(c, any(want))
+	}
+	return false
+}
+
+// key returns the signing key kid, refetching the provider's keys when the cache has expired or,
+// at most every minRefreshInterval, when kid is unknown, as happens after a key rotation.
+func (v *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.now()
+	if now.After(v.expires) {
+		// A provider that cannot be reached leaves the cached keys in use.
+		_ = v.refreshKeysLocked(ctx)
+	}
+	key, ok := v.lookupLocked(kid)
+	if !ok && now.Sub(v.fetchedAt) >= minRefreshInterval {
+		if err := v.refreshKeysLocked(ctx); err != nil {
+			return nil, err
+		}
+		key, ok = v.lookupLocked(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown signing key %q", ErrInvalidToken, kid)
+	}
+	return key, nil
+}
+
+// lookupLocked finds kid among the cached keys. A token without a kid matches a lone key.
+func (v *Verifier) lookupLocked(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+func (v *Verifier) refreshKeys(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.refreshKeysLocked(ctx)
+}
+
+func (v *Verifier) refreshKeysLocked(ctx context.Context) error {
+	v.fetchedAt = v.now()
+	// Until a fetch succeeds, retry no more often than minRefreshInterval.
+	v.expires = v.fetchedAt.Add(minRefreshInterval)
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	header, err := v.getJSON(ctx, v.jwksURI, &jwks)
+	if err != nil {
+		return fmt.Errorf("cannot fetch OIDC signing keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// Keys of unsupported types are skipped; tokens they signed then fail as unknown keys.
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		return errors.New("the provider publishes no usable signing keys")
+	}
+	v.keys = keys
+	v.expires = v.fetchedAt.Add(keysTTL(header.Get("Cache-Control")))
+	return nil
+}
+
+// keysTTL returns how long to cache keys given the JWKS response's Cache-Control header.
+func keysTTL(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(directive), "max-age="); ok {
+			if seconds, err := strconv.Atoi(value); err == nil {
+				return min(max(time.Duration(seconds)*time.Second, minKeysTTL), maxKeysTTL)
+			}
+		}
+	}
+	return defaultKeysTTL
+}
+
+func (v *Verifier) getJSON(ctx context.Context, url string, out any) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return nil, fmt.Errorf("GET %s: %w", url, err)
+	}
+	return resp.Header, nil
+}
+
+// jwk is a JSON Web Key (RFC 7517) holding an RSA or elliptic-curve public key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("bad RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		var ecdhCurve ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		size := (curve.Params().BitSize + 7) / 8
+		if errX != nil || errY != nil || len(x) != size || len(y) != size {
+			return nil, errors.New("bad EC coordinates")
+		}
+		// Parsing the uncompressed point checks that it lies on the curve.
+		if _, err := ecdhCurve.NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// algorithm describes a JWS signing algorithm: its hash and family, and for ECDSA its curve.
+type algorithm struct {
+	hash   crypto.Hash
+	family string
+	curve  elliptic.Curve
+}
+
+// algorithms are the RSA and ECDSA algorithms providers sign access tokens with. Symmetric
+// algorithms and "none" are deliberately absent.
+var algorithms = map[string]algorithm{
+	"RS256": {crypto.SHA256, "RS", nil},
+	"RS384": {crypto.SHA384, "RS", nil},
+	"RS512": {crypto.SHA512, "RS", nil},
+	"PS256": {crypto.SHA256, "PS", nil},
+	"PS384": {crypto.SHA384, "PS", nil},
+	"PS512": {crypto.SHA512, "PS", nil},
+	"ES256": {crypto.SHA256, "ES", elliptic.P256()},
+	"ES384": {crypto.SHA384, "ES", elliptic.P384()},
+	"ES512": {crypto.SHA512, "ES", elliptic.P521()},
+}
+
+// verifySignature checks a JWS signature over signed with key.
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	algo, ok := algorithms[alg]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h := algo.hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		switch algo.family {
+		case "RS":
+			return rsa.VerifyPKCS1v15(key, algo.hash, digest, signature)
+		case "PS":
+			return rsa.VerifyPSS(key, algo.hash, digest, signature, nil)
+		}
+	case *ecdsa.PublicKey:
+		if algo.family != "ES" || key.Curve != algo.curve {
+			break
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("bad signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("signature does not match")
+		}
+		return nil
+	}
+	return fmt.Errorf("algorithm %s does not match the signing key", alg)
+}
+
+func decodeSegment(segment string, out any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
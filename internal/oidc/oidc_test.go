@@ -0,0 +1,209 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// provider is a fake OIDC provider serving a discovery document and the public halves of its keys.
+type provider struct {
+	server    *httptest.Server
+	keys      atomic.Pointer[[]map[string]any]
+	jwksCalls atomic.Int32
+}
+
+func newProvider(t *testing.T, keys ...map[string]any) *provider {
+	p := &provider{}
+	p.keys.Store(&keys)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"issuer": p.server.URL, "jwks_uri": p.server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		p.jwksCalls.Add(1)
+		w.Header().Set("Cache-Control", "public, max-age=600")
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": *p.keys.Load()})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) map[string]any {
+	return map[string]any{
+		"kty": "RSA", "kid": kid, "use": "sig",
+		"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) map[string]any {
+	return map[string]any{
+		"kty": "EC", "kid": kid, "crv": "P-256",
+		"x": base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y": base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+// sign returns a JWT with the given header fields and claims, signed with key.
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	header, err := json.Marshal(map[string]any{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p := newProvider(t, rsaJWK("rsa-1", rsaKey), ecJWK("ec-1", ecKey))
+
+	verifier, err := NewVerifier(context.Background(), Config{
+		Issuer:   p.server.URL,
+		Audience: "claude-tools",
+		Claims:   map[string]string{"groups": "platform"},
+	})
+	require.NoError(t, err)
+
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"iss":    p.server.URL,
+			"aud":    []string{"claude-tools", "other"},
+			"sub":    "user-1",
+			"email":  "dev@example.com",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"groups": []string{"eng", "platform"},
+		}
+		for name, value := range overrides {
+			if value == nil {
+				delete(c, name)
+			} else {
+				c[name] = value
+			}
+		}
+		return c
+	}
+
+	verified, err := verifier.Verify(context.Background(), sign(t, "RS256", "rsa-1", rsaKey, claims(nil)))
+	require.NoError(t, err)
+	assert.Equal(t, "dev@example.com", verified.String("email"))
+	assert.WithinDuration(t, time.Now().Add(time.Hour), verified.Expiration(), 2*time.Second)
+	_, err = verifier.Verify(context.Background(), sign(t, "ES256", "ec-1", ecKey, claims(map[string]any{"aud": "claude-tools"})))
+	require.NoError(t, err)
+
+	for name, token := range map[string]string{
+		"wrong audience": sign(t, "RS256", "rsa-1", rsaKey, claims(map[string]any{"aud": "other"})),
+		"wrong issuer":   sign(t, "RS256", "rsa-1", rsaKey, claims(map[string]any{"iss": "https://evil.example.com"})),
+		"expired":        sign(t, "RS256", "rsa-1", rsaKey, claims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"no expiry":      sign(t, "RS256", "rsa-1", rsaKey, claims(map[string]any{"exp": nil})),
+		"not yet valid":  sign(t, "RS256", "rsa-1", rsaKey, claims(map[string]any{"nbf": time.Now().Add(time.Hour).Unix()})),
+		"missing group":  sign(t, "RS256", "rsa-1", rsaKey, claims(map[string]any{"groups": []string{"eng"}})),
+		"wrong key":      sign(t, "RS256", "ec-1", rsaKey, claims(nil)),
+		"alg mismatch":   sign(t, "ES256", "rsa-1", ecKey, claims(nil)),
+		"not a jwt":      "opaque-token",
+	} {
+		_, err := verifier.Verify(context.Background(), token)
+		assert.ErrorIs(t, err, ErrInvalidToken, name)
+	}
+
+	// Tampering with the claims breaks the signature.
+	token := sign(t, "RS256", "rsa-1", rsaKey, claims(nil))
+	parts := strings.Split(token, ".")
+	forged, _ := json.Marshal(claims(map[string]any{"sub": "admin"}))
+	parts[1] = base64.RawURLEncoding.EncodeToString(forged)
+	_, err = verifier.Verify(context.Background(), strings.Join(parts, "."))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// Unsigned and symmetric tokens are refused.
+	parts[2] = ""
+	for _, alg := range []string{"none", "HS256"} {
+		header, _ := json.Marshal(map[string]any{"alg": alg, "kid": "rsa-1"})
+		parts[0] = base64.RawURLEncoding.EncodeToString(header)
+		_, err = verifier.Verify(context.Background(), strings.Join(parts, "."))
+		assert.ErrorIs(t, err, ErrInvalidToken, alg)
+	}
+}
+
+func TestVerifier_KeyRotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p := newProvider(t, rsaJWK("old", oldKey))
+	verifier, err := NewVerifier(context.Background(), Config{Issuer: p.server.URL, Audience: "claude-tools"})
+	require.NoError(t, err)
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+	claims := map[string]any{"iss": p.server.URL, "aud": "claude-tools", "exp": now.Add(time.Hour).Unix()}
+
+	// Keys are cached.
+	_, err = verifier.Verify(context.Background(), sign(t, "RS256", "old", oldKey, claims))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), p.jwksCalls.Load())
+
+	// A token from a new key refetches the keys, but not more than once a minute.
+	p.keys.Store(&[]map[string]any{rsaJWK("new", newKey)})
+	_, err = verifier.Verify(context.Background(), sign(t, "RS256", "new", newKey, claims))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Equal(t, int32(1), p.jwksCalls.Load())
+	now = now.Add(minRefreshInterval)
+	_, err = verifier.Verify(context.Background(), sign(t, "RS256", "new", newKey, claims))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), p.jwksCalls.Load())
+
+	// The cache expires after the response's max-age.
+	now = now.Add(11 * time.Minute)
+	_, err = verifier.Verify(context.Background(), sign(t, "RS256", "new", newKey, claims))
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), p.jwksCalls.Load())
+}
+
+func TestNewVerifier_Errors(t *testing.T) {
+	_, err := NewVerifier(context.Background(), Config{Issuer: "https://example.com"})
+	assert.ErrorContains(t, err, "audience")
+
+	p := newProvider(t)
+	_, err = NewVerifier(context.Background(), Config{Issuer: p.server.URL + "/", Audience: "a"})
+	assert.ErrorContains(t, err, "names issuer")
+	_, err = NewVerifier(context.Background(), Config{Issuer: p.server.URL, Audience: "a"})
+	assert.ErrorContains(t, err, "no usable signing keys")
+}
+
+func TestKeysTTL(t *testing.T) {
+	assert.Equal(t, defaultKeysTTL, keysTTL(""))
+	assert.Equal(t, 10*time.Minute, keysTTL("public, max-age=600"))
+	assert.Equal(t, minKeysTTL, keysTTL("max-age=0"))
+	assert.Equal(t, maxKeysTTL, keysTTL("max-age=999999"))
+}
@@ -0,0 +1,201 @@
+// Package rest serves the MCP tools over a plain JSON HTTP API, for automation that does not speak
+// MCP.
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// PathPrefix is where the API is mounted. Each tool is served at PathPrefix + "tools/<name>".
+const PathPrefix = "/api/v1/"
+
+// TokenMetaKey is the _meta field carrying the bearer token info of the request a call is made for,
+// as {"scopes": [...], "extra": {...}}. Calls reach the server over an in-process session that
+// carries no token of its own, so this is how the server applies the client's scopes to them.
+const TokenMetaKey = "token_info"
+
+// maxRequestBody bounds a tool call's JSON arguments; Write content is the only large argument.
+const maxRequestBody = 32 * 1024 * 1024
+
+// Caller is the part of an MCP client session the API needs. A *mcp.ClientSession connected to
+// the server satisfies it, so REST calls pass through the same middleware and validation as MCP
+// calls.
+type Caller interface {
+	ListTools(ctx context.Context, params *sdk.ListToolsParams) (*sdk.ListToolsResult, error)
+	CallTool(ctx context.Context, params *sdk.CallToolParams) (*sdk.CallToolResult, error)
+}
+
+// errorBody is the JSON body of every non-2xx response.
+type errorBody struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// toolSummary describes a tool in the GET /api/v1/tools listing.
+type toolSummary struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Path        string `json:"path"`
+}
+
+// NewHandler returns the API handler:
+//
+//	GET  /api/v1/tools          lists the tools
+//	POST /api/v1/tools/{name}   calls a tool with the JSON object body as its arguments
+//	GET  /api/v1/openapi.json   describes the API
+func NewHandler(caller Caller, version string) http.Handler {
+	h := &handler{caller: caller, version: version}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+PathPrefix+"tools", h.listTools)
+	mux.HandleFunc("POST "+PathPrefix+"tools/{name}", h.callTool)
+	mux.HandleFunc("GET "+PathPrefix+"openapi.json", h.openAPI)
+	mux.HandleFunc(PathPrefix, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not_found", fmt.Sprintf("No API route for %s %s", r.Method, r.URL.Path))
+	})
+	return mux
+}
+
+type handler struct {
+	caller  Caller
+	version string
+}
+
+// tools fetches the full tool list from the server, following pagination.
+func (h *handler) tools(ctx context.Context) ([]*sdk.Tool, error) {
+	var tools []*sdk.Tool
+	params := &sdk.ListToolsParams{}
+	for {
+		result, err := h.caller.ListTools(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		tools = append(tools, result.Tools...)
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		params.Cursor = result.NextCursor
+	}
+}
+
+func (h *handler) listTools(w http.ResponseWriter, r *http.Request) {
+	tools, err := h.tools(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "server_error", err.Error())
+		return
+	}
+	summaries := make([]toolSummary, 0, len(tools))
+	for _, tool := range tools {
+		summaries = append(summaries, toolSummary{
+			Name:        tool.Name,
+			Description: firstParagraph(tool.Description),
+			Path:        PathPrefix + "tools/" + tool.Name,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"tools": summaries})
+}
+
+func (h *handler) callTool(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	tools, err := h.tools(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "server_error", err.Error())
+		return
+	}
+	if !hasTool(tools, name) {
+		writeError(w, http.StatusNotFound, "unknown_tool", fmt.Sprintf("Unknown tool: %s", name))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeError(w, status, "invalid_request", fmt.Sprintf("Cannot read request body: %s", err))
+		return
+	}
+	// Requiring JSON keeps browsers from calling tools cross-site: a page can only send another
+	// origin form or text bodies without a preflight.
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "" || len(body) > 0 {
+		if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, "invalid_request", "Request body must have Content-Type application/json")
+			return
+		}
+	}
+	arguments := map[string]any{}
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if err := json.Unmarshal(body, &arguments); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "Request body must be a JSON object of tool arguments")
+			return
+		}
+	}
+
+	params := &sdk.CallToolParams{Name: name, Arguments: arguments}
+	if info := auth.TokenInfoFromContext(r.Context()); info != nil {
+		params.Meta = sdk.Meta{TokenMetaKey: map[string]any{"scopes": info.Scopes, "extra": info.Extra}}
+	}
+	result, err := h.caller.CallTool(r.Context(), params)
+	if err != nil {
+		// Protocol errors here are almost always arguments that failed schema validation.
+		writeError(w, http.StatusBadRequest, "invalid_arguments", err.Error())
+		return
+	}
+	if result.IsError {
+		writeError(w, http.StatusUnprocessableEntity, "tool_error", resultText(result))
+		return
+	}
+	if result.StructuredContent != nil {
+		writeJSON(w, http.StatusOK, result.StructuredContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"result": resultText(result)})
+}
+
+func hasTool(tools []*sdk.Tool, name string) bool {
+	for _, tool := range tools {
+		if tool.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resultText joins the text content of a tool result.
+func resultText(result *sdk.CallToolResult) string {
+	var parts []string
+	for _, content := range result.Content {
+		if text, ok := content.(*sdk.TextContent); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// firstParagraph shortens a tool description to its summary, ahead of the usage notes.
+func firstParagraph(description string) string {
+	summary, _, _ := strings.Cut(description, "\n\n")
+	return strings.TrimSpace(summary)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorBody{Error: code, Message: message})
+}
@@ -0,0 +1,165 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCaller stands in for an MCP client session, serving a fixed tool list and recording calls.
+type fakeCaller struct {
+	tools []*sdk.Tool
+	calls []*sdk.CallToolParams
+	reply func(params *sdk.CallToolParams) (*sdk.CallToolResult, error)
+}
+
+func (f *fakeCaller) ListTools(ctx context.Context, params *sdk.ListToolsParams) (*sdk.ListToolsResult, error) {
+	// Serve one tool per page to exercise pagination
+	i := 0
+	if params.Cursor != "" {
+		i = int(params.Cursor[0] - '0')
+	}
+	result := &sdk.ListToolsResult{Tools: f.tools[i : i+1]}
+	if i+1 < len(f.tools) {
+		result.NextCursor = string(rune('0' + i + 1))
+	}
+	return result, nil
+}
+
+func (f *fakeCaller) CallTool(ctx context.Context, params *sdk.CallToolParams) (*sdk.CallToolResult, error) {
+	f.calls = append(f.calls, params)
+	return f.reply(params)
+}
+
+func newFakeCaller() *fakeCaller {
+	return &fakeCaller{
+		tools: []*sdk.Tool{
+			{Name: "read", Description: "Reads a file.\n\nUsage:\n- details"},
+			{Name: "bash", Description: "Runs a command."},
+		},
+		reply: func(params *sdk.CallToolParams) (*sdk.CallToolResult, error) {
+			return &sdk.CallToolResult{
+				Content:           []sdk.Content{&sdk.TextContent{Text: "hello"}},
+				StructuredContent: map[string]any{"content": "hello"},
+			}, nil
+		},
+	}
+}
+
+func serve(t *testing.T, h http.Handler, method, path, body string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &decoded), rec.Body.String())
+	return rec, decoded
+}
+
+func TestHandler_ListTools(t *testing.T) {
+	h := NewHandler(newFakeCaller(), "1.0.0")
+	rec, body := serve(t, h, http.MethodGet, "/api/v1/tools", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	tools := body["tools"].([]any)
+	require.Len(t, tools, 2)
+	first := tools[0].(map[string]any)
+	assert.Equal(t, "read", first["name"])
+	assert.Equal(t, "Reads a file.", first["description"])
+	assert.Equal(t, "/api/v1/tools/read", first["path"])
+}
+
+func TestHandler_CallTool(t *testing.T) {
+	t.Run("success returns structured content", func(t *testing.T) {
+		caller := newFakeCaller()
+		h := NewHandler(caller, "1.0.0")
+		rec, body := serve(t, h, http.MethodPost, "/api/v1/tools/read", `{"file_path":"/tmp/a.txt","limit":5}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "hello", body["content"])
+
+		require.Len(t, caller.calls, 1)
+		assert.Equal(t, "read", caller.calls[0].Name)
+		assert.Equal(t, map[string]any{"file_path": "/tmp/a.txt", "limit": float64(5)}, caller.calls[0].Arguments)
+	})
+
+	t.Run("empty body calls with no arguments", func(t *testing.T) {
+		caller := newFakeCaller()
+		rec, _ := serve(t, NewHandler(caller, "1.0.0"), http.MethodPost, "/api/v1/tools/bash", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, map[string]any{}, caller.calls[0].Arguments)
+	})
+
+	t.Run("tool errors", func(t *testing.T) {
+		caller := newFakeCaller()
+		caller.reply = func(*sdk.CallToolParams) (*sdk.CallToolResult, error) {
+			return &sdk.CallToolResult{IsError: true, Content: []sdk.Content{&sdk.TextContent{Text: "file does not exist"}}}, nil
+		}
+		rec, body := serve(t, NewHandler(caller, "1.0.0"), http.MethodPost, "/api/v1/tools/read", `{}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, "tool_error", body["error"])
+		assert.Equal(t, "file does not exist", body["message"])
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		caller := newFakeCaller()
+		caller.reply = func(*sdk.CallToolParams) (*sdk.CallToolResult, error) {
+			return nil, errors.New(`invalid params: missing property "file_path"`)
+		}
+		rec, body := serve(t, NewHandler(caller, "1.0.0"), http.MethodPost, "/api/v1/tools/read", `{}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "invalid_arguments", body["error"])
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		caller := newFakeCaller()
+		rec, body := serve(t, NewHandler(caller, "1.0.0"), http.MethodPost, "/api/v1/tools/read", `["not", "an", "object"]`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "invalid_request", body["error"])
+		assert.Empty(t, caller.calls)
+	})
+
+	t.Run("non-JSON content types", func(t *testing.T) {
+		caller := newFakeCaller()
+		h := NewHandler(caller, "1.0.0")
+		for _, contentType := range []string{"text/plain", "application/x-www-form-urlencoded", ""} {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/tools/read", strings.NewReader(`{"file_path":"/tmp/a.txt"}`))
+			if contentType != "" {
+				req.Header.Set("Content-Type", contentType)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code, contentType)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tools/read", strings.NewReader(`{"file_path":"/tmp/a.txt"}`))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, caller.calls, 1)
+	})
+
+	t.Run("unknown tool", func(t *testing.T) {
+		caller := newFakeCaller()
+		rec, body := serve(t, NewHandler(caller, "1.0.0"), http.MethodPost, "/api/v1/tools/nope", `{}`)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "unknown_tool", body["error"])
+		assert.Empty(t, caller.calls)
+	})
+
+	t.Run("unknown route", func(t *testing.T) {
+		rec, body := serve(t, NewHandler(newFakeCaller(), "1.0.0"), http.MethodGet, "/api/v1/tools/read", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "not_found", body["error"])
+	})
+}
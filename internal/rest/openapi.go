@@ -0,0 +1,102 @@
+package rest
+
+import (
+	"net/http"
+	"sort"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// openAPIVersion is 3.1, whose schema objects are JSON Schema 2020-12, the dialect MCP tool
+// schemas use, so tool schemas can be embedded unchanged.
+const openAPIVersion = "3.1.0"
+
+func (h *handler) openAPI(w http.ResponseWriter, r *http.Request) {
+	tools, err := h.tools(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "server_error", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, openAPISpec(tools, h.version))
+}
+
+// openAPISpec describes the API for the given tools: one POST operation per tool, taking the tool's
+// input schema as the request body and returning its output schema.
+func openAPISpec(tools []*sdk.Tool, version string) map[string]any {
+	sorted := append([]*sdk.Tool(nil), tools...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	errorResponse := func(description string) map[string]any {
+		return map[string]any{
+			"description": description,
+			"content": map[string]any{
+				"application/json": map[string]any{
+					"schema": map[string]any{"$ref": "#/components/schemas/Error"},
+				},
+			},
+		}
+	}
+
+	paths := map[string]any{}
+	for _, tool := range sorted {
+		inputSchema := tool.InputSchema
+		if inputSchema == nil {
+			inputSchema = map[string]any{"type": "object"}
+		}
+		outputSchema := tool.OutputSchema
+		if outputSchema == nil {
+			outputSchema = map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"result": map[string]any{"type": "string"}},
+			}
+		}
+		paths[PathPrefix+"tools/"+tool.Name] = map[string]any{
+			"post": map[string]any{
+				"operationId": tool.Name,
+				"summary":     firstParagraph(tool.Description),
+				"description": tool.Description,
+				"tags":        []string{"tools"},
+				"requestBody": map[string]any{
+					"required": true,
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": inputSchema},
+					},
+				},
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "The tool's result",
+						"content": map[string]any{
+							"application/json": map[string]any{"schema": outputSchema},
+						},
+					},
+					"400": errorResponse("The request body is not valid JSON or does not match the tool's input schema"),
+					"404": errorResponse("No tool with this name exists"),
+					"415": errorResponse("The request body is not sent as application/json"),
+					"422": errorResponse("The tool ran and reported an error"),
+				},
+			},
+		}
+	}
+
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":       "Claude Tools",
+			"version":     version,
+			"description": "The tools of the claude-tools MCP server, served as plain JSON endpoints.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": map[string]any{
+				"Error": map[string]any{
+					"type":     "object",
+					"required": []string{"error", "message"},
+					"properties": map[string]any{
+						"error":   map[string]any{"type": "string", "description": "Machine-readable error code, such as tool_error or unknown_tool"},
+						"message": map[string]any{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"net/http"
+	"testing"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	inputSchema := map[string]any{
+		"type":       "object",
+		"required":   []any{"file_path"},
+		"properties": map[string]any{"file_path": map[string]any{"type": "string"}},
+	}
+	spec := openAPISpec([]*sdk.Tool{
+		{Name: "read", Description: "Reads a file.\n\nUsage:\n- details", InputSchema: inputSchema},
+		{Name: "bash", Description: "Runs a command."},
+	}, "1.2.3")
+
+	assert.Equal(t, "3.1.0", spec["openapi"])
+	assert.Equal(t, "1.2.3", spec["info"].(map[string]any)["version"])
+
+	paths := spec["paths"].(map[string]any)
+	require.Contains(t, paths, "/api/v1/tools/read")
+	require.Contains(t, paths, "/api/v1/tools/bash")
+
+	read := paths["/api/v1/tools/read"].(map[string]any)["post"].(map[string]any)
+	assert.Equal(t, "read", read["operationId"])
+	assert.Equal(t, "Reads a file.", read["summary"])
+	body := read["requestBody"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)
+	assert.Equal(t, inputSchema, body["schema"])
+	assert.Contains(t, read["responses"], "422")
+
+	// Tools without schemas still get usable ones
+	bash := paths["/api/v1/tools/bash"].(map[string]any)["post"].(map[string]any)
+	body = bash["requestBody"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "object"}, body["schema"])
+}
+
+func TestHandler_OpenAPI(t *testing.T) {
+	rec, body := serve(t, NewHandler(newFakeCaller(), "1.0.0"), http.MethodGet, "/api/v1/openapi.json", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "3.1.0", body["openapi"])
+	assert.Len(t, body["paths"], 2)
+}
@@ -0,0 +1,101 @@
+// Package systemd integrates the server with systemd: it serves on sockets passed by socket
+// activation and reports readiness and shutdown through the notification socket, so that the
+// server can run as a Type=notify unit and restart without refusing connections.
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor systemd passes; the others follow it.
+const listenFDsStart = 3
+
+// Listeners returns the listening sockets systemd passed to this process by socket activation,
+// or none if it was not socket-activated. The LISTEN_* variables are removed from the environment
+// so that commands the server runs do not mistake the sockets for their own.
+func Listeners() ([]net.Listener, error) {
+	pid, fds, names := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if pid == "" && fds == "" {
+		return nil, nil
+	}
+	// The variables are inherited by children of the process systemd started, which must ignore
+	// them.
+	if pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(fds)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", fds)
+	}
+	return listeners(listenFDsStart, count, strings.Split(names, ":"))
+}
+
+// listeners wraps count consecutive file descriptors from first as listeners, naming them for
+// errors from names, as given by LISTEN_FDNAMES.
+func listeners(first, count int, names []string) ([]net.Listener, error) {
+	var result []net.Listener
+	for i := range count {
+		fd := first + i
+		name := "fd " + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		file := os.NewFile(uintptr(fd), name)
+		listener, err := net.FileListener(file)
+		// FileListener duplicates the descriptor.
+		file.Close()
+		if err != nil {
+			for _, l := range result {
+				l.Close()
+			}
+			return nil, fmt.Errorf("socket %s passed by systemd is not a listening socket: %w", name, err)
+		}
+		result = append(result, listener)
+	}
+	return result, nil
+}
+
+// Notifier reports the service's state to systemd. Without a notification socket, as when the
+// server was not started by a Type=notify unit, it does nothing.
+type Notifier struct {
+	addr *net.UnixAddr
+}
+
+// NewNotifier returns a notifier for the socket named by NOTIFY_SOCKET, and removes the variable
+// from the environment so that commands the server runs cannot report for it.
+func NewNotifier() *Notifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	os.Unsetenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return &Notifier{}
+	}
+	// A leading @, which names a socket in the abstract namespace, is understood by package net.
+	return &Notifier{addr: &net.UnixAddr{Name: socket, Net: "unixgram"}}
+}
+
+// Notify sends state assignments such as READY=1, STOPPING=1, or STATUS=... to systemd.
+func (n *Notifier) Notify(states ...string) error {
+	if n.addr == nil {
+		return nil
+	}
+	if len(states) == 0 {
+		return errors.New("no state to notify")
+	}
+	conn, err := net.DialUnix("unixgram", nil, n.addr)
+	if err != nil {
+		return fmt.Errorf("cannot reach systemd notification socket: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return fmt.Errorf("cannot notify systemd: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListeners(t *testing.T) {
+	original, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer original.Close()
+	file, err := original.(*net.TCPListener).File()
+	require.NoError(t, err)
+
+	listeners, err := listeners(int(file.Fd()), 1, []string{"mcp"})
+	// The passed descriptor was closed once wrapped, so closing it again fails.
+	assert.Error(t, file.Close())
+	require.NoError(t, err)
+	require.Len(t, listeners, 1)
+	defer listeners[0].Close()
+	assert.Equal(t, original.Addr().String(), listeners[0].Addr().String())
+
+	conn, err := net.Dial("tcp", original.Addr().String())
+	require.NoError(t, err)
+	conn.Close()
+	accepted, err := listeners[0].Accept()
+	require.NoError(t, err)
+	accepted.Close()
+}
+
+func TestListeners_NotListening(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "not-a-socket")
+	require.NoError(t, err)
+	_, err = listeners(int(file.Fd()), 1, []string{"config"})
+	assert.Error(t, file.Close())
+	assert.ErrorContains(t, err, "socket config passed by systemd is not a listening socket")
+}
+
+func TestListeners_OtherProcess(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	listeners, err := Listeners()
+	require.NoError(t, err)
+	assert.Empty(t, listeners)
+	_, ok := os.LookupEnv("LISTEN_FDS")
+	assert.False(t, ok)
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "two")
+	_, err = Listeners()
+	assert.ErrorContains(t, err, "invalid LISTEN_FDS")
+}
+
+func TestNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	socket, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	defer socket.Close()
+
+	t.Setenv("NOTIFY_SOCKET", path)
+	notifier := NewNotifier()
+	_, ok := os.LookupEnv("NOTIFY_SOCKET")
+	assert.False(t, ok)
+
+	require.NoError(t, notifier.Notify("READY=1", "STATUS=Serving"))
+	buf := make([]byte, 256)
+	n, err := socket.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "READY=1\nSTATUS=Serving", string(buf[:n]))
+
+	// Without a notification socket, notifying does nothing.
+	assert.NoError(t, (&Notifier{}).Notify("READY=1"))
+}
@@ -0,0 +1,478 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/brwse/claude-tools-mcp/internal/rest"
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// API key scopes. read covers tools that only inspect files and server state, write covers tools
+// that change files, and exec covers tools that run commands. No scope implies another: a key that
+// edits files needs read as well, since edit requires reading the file first.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeExec  = "exec"
+)
+
+// apiKeyTokenLifetime is the expiration given to a verified key's token info, which bearer token
+// middleware requires. Keys themselves do not expire; each request is verified afresh.
+const apiKeyTokenLifetime = time.Hour
+
+// toolScopes maps each tool to the scope needed to call it. git, worktree, and archive depend on
+// their operation; see requiredScopes. Tools missing here need every scope.
+var toolScopes = map[string]string{
+	"read":              ScopeRead,
+	"glob":              ScopeRead,
+	"grep":              ScopeRead,
+	"ls":                ScopeRead,
+	"diff":              ScopeRead,
+	"outline":           ScopeRead,
+	"disk_usage":        ScopeRead,
+	"count_tokens":      ScopeRead,
+	"pack_context":      ScopeRead,
+	"extract_text":      ScopeRead,
+	"list_changes":      ScopeRead,
+	"list_edits":        ScopeRead,
+	"list_search_types": ScopeRead,
+	"structural_search": ScopeRead,
+	"semantic_index":    ScopeRead,
+	"semantic_search":   ScopeRead,
+	"definition":        ScopeRead,
+	"references":        ScopeRead,
+	"hover":             ScopeRead,
+	"system_info":       ScopeRead,
+	"check_port":        ScopeRead,
+	"listening_ports":   ScopeRead,
+	"todo_write":        ScopeRead,
+	"task":              ScopeRead,
+	"watch_path":        ScopeRead,
+	"watch_events":      ScopeRead,
+	"unwatch":           ScopeRead,
+	"debug_state":       ScopeRead,
+
+	"write":            ScopeWrite,
+	"write_many":       ScopeWrite,
+	"edit":             ScopeWrite,
+	"undo_edit":        ScopeWrite,
+	"config_edit":      ScopeWrite,
+	"format_file":      ScopeWrite,
+	"render_template":  ScopeWrite,
+	"rename_symbol":    ScopeWrite,
+	"move_file":        ScopeWrite,
+	"copy_file":        ScopeWrite,
+	"delete_file":      ScopeWrite,
+	"create_directory": ScopeWrite,
+	"restore_backup":   ScopeWrite,
+	"snapshot":         ScopeWrite,
+	"restore_snapshot": ScopeWrite,
+	"commit_changes":   ScopeWrite,
+	"discard_changes":  ScopeWrite,
+	"generate_patch":   ScopeWrite,
+
+	"bash":                 ScopeExec,
+	"bash_output":          ScopeExec,
+	"list_shells":          ScopeExec,
+	"kill_shell":           ScopeExec,
+	"kill_all_shells":      ScopeExec,
+	"repl_start":           ScopeExec,
+	"repl_send":            ScopeExec,
+	"repl_close":           ScopeExec,
+	"schedule_command":     ScopeExec,
+	"list_scheduled_jobs":  ScopeExec,
+	"cancel_scheduled_job": ScopeExec,
+}
+
+// readOperations lists the operations of git, worktree, and archive that need only the read scope;
+// their other operations need write.
+var readOperations = map[string][]string{
+	"git":      {"status", "diff", "log", "show", "blame"},
+	"worktree": {"list"},
+	"archive":  {"list", "read"},
+}
+
+// pathArguments are the tool arguments holding file paths, which a workspace-restricted key may
+// only point inside its workspace.
+var pathArguments = map[string]bool{
+	"path":          true,
+	"file_path":     true,
+	"files":         true,
+	"paths":         true,
+	"source":        true,
+	"destination":   true,
+	"old_path":      true,
+	"new_path":      true,
+	"output_path":   true,
+	"repo":          true,
+	"template_file": true,
+}
+
+// defaultPathArguments names, for tools that fall back to the working directory when it is
+// omitted, the argument a workspace-restricted key's calls get set to the workspace root instead.
+var defaultPathArguments = map[string]string{
+	"glob":              "path",
+	"grep":              "path",
+	"git":               "path",
+	"generate_patch":    "path",
+	"worktree":          "repo",
+	"structural_search": "path",
+	"semantic_search":   "path",
+	"count_tokens":      "path",
+}
+
+// APIKey is a client credential: the SHA-256 of the bearer token the client presents, the scopes
+// it grants, and optionally the workspace its file paths are confined to.
+type APIKey struct {
+	Name      string   `json:"name"`
+	KeySHA256 string   `json:"key_sha256"`
+	Scopes    []string `json:"scopes"`
+	Workspace string   `json:"workspace,omitempty"`
+
+	hash []byte
+}
+
+// LoadAPIKeys loads the API keys clients must authenticate with from a JSON array of APIKey
+// objects. An empty path disables API keys. Workspaces must be set first, so that the keys'
+// workspaces can be checked.
+func (s *State) LoadAPIKeys(path string) error {
+	if path == "" {
+		s.Mu.Lock()
+		s.APIKeys = nil
+		s.Mu.Unlock()
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Cannot read API keys: %s", err)
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("Cannot parse API keys in %s: %s", path, err)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("No API keys in %s.", path)
+	}
+	names := make(map[string]bool, len(keys))
+	hashes := make(map[string]bool, len(keys))
+	for i := range keys {
+		key := &keys[i]
+		if key.Name == "" {
+			return fmt.Errorf("API key %d in %s has no name.", i+1, path)
+		}
+		if names[key.Name] {
+			return fmt.Errorf("API key %s is defined more than once.", key.Name)
+		}
+		names[key.Name] = true
+		hash, err := hex.DecodeString(key.KeySHA256)
+		if err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("Invalid API key %s: key_sha256 must be the hex SHA-256 of the key.", key.Name)
+		}
+		if hashes[string(hash)] {
+			return fmt.Errorf("Invalid API key %s: another key has the same key_sha256.", key.Name)
+		}
+		hashes[string(hash)] = true
+		key.hash = hash
+		if len(key.Scopes) == 0 {
+			return fmt.Errorf("Invalid API key %s: it has no scopes.", key.Name)
+		}
+		for _, scope := range key.Scopes {
+			if scope != ScopeRead && scope != ScopeWrite && scope != ScopeExec {
+				return fmt.Errorf("Invalid API key %s: unknown scope %q. Must be read, write, or exec.", key.Name, scope)
+			}
+		}
+		if key.Workspace != "" {
+			if _, err := s.workspaceRoot(key.Workspace); err != nil {
+				return fmt.Errorf("Invalid API key %s: %s", key.Name, err)
+			}
+		}
+	}
+	s.Mu.Lock()
+	s.APIKeys = keys
+	s.Mu.Unlock()
+	return nil
+}
+
+// AuthRequired reports whether clients must authenticate, with an API key or an OIDC token.
+func (s *State) AuthRequired() bool {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return len(s.APIKeys) > 0 || s.OIDC != nil
+}
+
+// VerifyToken is an auth.TokenVerifier accepting the loaded API keys and, when an OIDC issuer is
+// configured, JWTs it issued. The token info it returns carries the client's scopes, with its name
+// and any workspace restriction under the "client" and "workspace" extras.
+func (s *State) VerifyToken(ctx context.Context, token string, req *http.Request) (*auth.TokenInfo, error) {
+	s.Mu.RLock()
+	verifier := s.OIDC
+	s.Mu.RUnlock()
+	if verifier != nil && strings.Count(token, ".") == 2 {
+		return s.verifyOIDCToken(ctx, verifier, token)
+	}
+	return s.verifyAPIKey(token)
+}
+
+// verifyAPIKey returns the token info of the API key token.
+func (s *State) verifyAPIKey(token string) (*auth.TokenInfo, error) {
+	sum := sha256.Sum256([]byte(token))
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	for _, key := range s.APIKeys {
+		if subtle.ConstantTimeCompare(sum[:], key.hash) == 1 {
+			return &auth.TokenInfo{
+				Scopes:     key.Scopes,
+				Expiration: time.Now().Add(apiKeyTokenLifetime),
+				Extra:      map[string]any{"client": key.Name, "workspace": key.Workspace},
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown API key", auth.ErrInvalidToken)
+}
+
+// AuthMiddleware enforces the calling client's credentials, when authentication is configured,
+// before a tool call is dispatched: they must grant the scope the tool needs, and a client
+// restricted to a workspace may only pass paths inside it. Calls without credentials are refused.
+func (s *State) AuthMiddleware(next sdk.MethodHandler) sdk.MethodHandler {
+	return func(ctx context.Context, method string, req sdk.Request) (sdk.Result, error) {
+		call, ok := req.(*sdk.CallToolRequest)
+		if !ok || call.Params == nil || !s.AuthRequired() {
+			return next(ctx, method, req)
+		}
+		if err := s.authorizeCall(call); err != nil {
+			return &sdk.CallToolResult{
+				Content: []sdk.Content{&sdk.TextContent{Text: err.Error()}},
+				IsError: true,
+			}, nil
+		}
+		return next(ctx, method, req)
+	}
+}
+
+// authorizeCall checks call against the token info it was made with, setting omitted path
+// arguments to the client's workspace root where a tool would otherwise use the working directory.
+func (s *State) authorizeCall(call *sdk.CallToolRequest) error {
+	info := s.callTokenInfo(call)
+	if info == nil {
+		return fmt.Errorf("This call carries no valid credentials.")
+	}
+	client, _ := info.Extra["client"].(string)
+	workspace, _ := info.Extra["workspace"].(string)
+	tool := call.Params.Name
+	required := requiredScopes(tool, call.Params.Arguments)
+	for _, scope := range required {
+		if !slices.Contains(info.Scopes, scope) {
+			return fmt.Errorf("Client %s cannot call %s: it needs the %s scope.", client, tool, scope)
+		}
+	}
+	// Git runs programs named in a repository's config and hooks, so changing .git is as good as
+	// running commands.
+	if slices.Contains(required, ScopeWrite) && !slices.Contains(info.Scopes, ScopeExec) {
+		if path := gitMetadataPath(call.Params.Arguments); path != "" {
+			return fmt.Errorf("Client %s cannot change %s: changes inside .git need the %s scope.", client, path, ScopeExec)
+		}
+	}
+	if workspace == "" {
+		return nil
+	}
+	arguments, err := s.confineArguments(workspace, tool, call.Params.Arguments)
+	if err != nil {
+		return fmt.Errorf("Client %s is restricted to workspace %s: %s", client, workspace, err)
+	}
+	call.Params.Arguments = arguments
+	return nil
+}
+
+// SetRESTSession marks session as the REST API's in-process session, whose calls carry the token
+// info of the HTTP request they are made for in their _meta.
+func (s *State) SetRESTSession(session *sdk.ServerSession) {
+	s.Mu.Lock()
+	s.RESTSession = session
+	s.Mu.Unlock()
+}
+
+// callTokenInfo returns the token info a call was made with. MCP clients' calls carry that of their
+// HTTP request; REST calls reach the server over an in-process session and carry it in their _meta
+// instead. _meta is ignored on any other session, since clients could put anything there.
+func (s *State) callTokenInfo(call *sdk.CallToolRequest) *auth.TokenInfo {
+	if call.Extra != nil && call.Extra.TokenInfo != nil {
+		return call.Extra.TokenInfo
+	}
+	s.Mu.RLock()
+	restSession := s.RESTSession
+	s.Mu.RUnlock()
+	if restSession == nil || call.Session != restSession {
+		return nil
+	}
+	meta, ok := call.Params.Meta[rest.TokenMetaKey]
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return nil
+	}
+	var info struct {
+		Scopes []string       `json:"scopes"`
+		Extra  map[string]any `json:"extra"`
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil
+	}
+	return &auth.TokenInfo{Scopes: info.Scopes, Extra: info.Extra}
+}
+
+// requiredScopes returns the scopes a call to tool needs.
+func requiredScopes(tool string, arguments json.RawMessage) []string {
+	if operations, ok := readOperations[tool]; ok {
+		var args struct {
+			Operation string `json:"operation"`
+		}
+		_ = json.Unmarshal(arguments, &args)
+		if slices.Contains(operations, args.Operation) {
+			return []string{ScopeRead}
+		}
+		return []string{ScopeWrite}
+	}
+	if scope, ok := toolScopes[tool]; ok {
+		return []string{scope}
+	}
+	return []string{ScopeRead, ScopeWrite, ScopeExec}
+}
+
+// gitMetadataPath returns the first path argument in arguments that lies inside a .git directory,
+// or "" if there is none.
+func gitMetadataPath(arguments json.RawMessage) string {
+	args := map[string]any{}
+	_ = json.Unmarshal(arguments, &args)
+	return findGitMetadataPath(args)
+}
+
+func findGitMetadataPath(args map[string]any) string {
+	for name, value := range args {
+		if !pathArguments[name] {
+			continue
+		}
+		values, ok := value.([]any)
+		if !ok {
+			values = []any{value}
+		}
+		for _, value := range values {
+			switch v := value.(type) {
+			case string:
+				if slices.Contains(strings.Split(filepath.ToSlash(filepath.Clean(v)), "/"), ".git") {
+					return v
+				}
+			case map[string]any:
+				if path := findGitMetadataPath(v); path != "" {
+					return path
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// confineArguments checks that every path argument of a call stays inside the named workspace,
+// and returns the arguments with an omitted default path set to the workspace root.
+func (s *State) confineArguments(workspace, tool string, arguments json.RawMessage) (json.RawMessage, error) {
+	if tool == "task" {
+		// The sub-agent's own tool calls do not pass through this middleware.
+		return nil, fmt.Errorf("task's sub-agent cannot be confined to a workspace")
+	}
+	root, err := s.workspaceRoot(workspace)
+	if err != nil {
+		return nil, err
+	}
+	args := map[string]any{}
+	if len(arguments) > 0 {
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return nil, fmt.Errorf("cannot inspect the arguments: %s", err)
+		}
+	}
+	// glob and grep resolve path against their workspace argument, which must then be this one.
+	searchWorkspace, _ := args["workspace"].(string)
+	if searchWorkspace != "" && searchWorkspace != workspace {
+		return nil, fmt.Errorf("cannot search workspace %s", searchWorkspace)
+	}
+	changed := false
+	if name, ok := defaultPathArguments[tool]; ok && searchWorkspace == "" {
+		path, _ := args[name].(string)
+		files, _ := args["files"].([]any)
+		pattern, _ := args["pattern"].(string)
+		// grep searches its files argument instead of path, and count_tokens only searches path
+		// for a pattern.
+		if path == "" && !(tool == "grep" && len(files) > 0) && !(tool == "count_tokens" && pattern == "") {
+			args[name] = root
+			changed = true
+		}
+	}
+	if err := s.checkPathArguments(root, args); err != nil {
+		return nil, err
+	}
+	if !changed {
+		return arguments, nil
+	}
+	return json.Marshal(args)
+}
+
+// checkPathArguments checks the path arguments in args, including those of objects in a path
+// argument's list, such as write_many's files.
+func (s *State) checkPathArguments(root string, args map[string]any) error {
+	for name, value := range args {
+		if !pathArguments[name] {
+			continue
+		}
+		values, ok := value.([]any)
+		if !ok {
+			values = []any{value}
+		}
+		for _, value := range values {
+			switch v := value.(type) {
+			case string:
+				if err := s.checkPath(root, v); err != nil {
+					return err
+				}
+			case map[string]any:
+				if err := s.checkPathArguments(root, v); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkPath checks that path lies inside root. Relative paths, which tools resolve against another
+// checked path argument, must not climb out of it.
+func (s *State) checkPath(root, path string) error {
+	if path == "" {
+		return nil
+	}
+	if !filepath.IsAbs(path) && !strings.HasPrefix(path, workspaceScheme) {
+		if !filepath.IsLocal(path) {
+			return fmt.Errorf("relative path %s leaves its directory", path)
+		}
+		return nil
+	}
+	resolved, err := s.resolvePath(path)
+	if err != nil {
+		return err
+	}
+	if !withinRoot(s.filesystem(), root, resolved) {
+		return fmt.Errorf("%s is outside the workspace", path)
+	}
+	return nil
+}
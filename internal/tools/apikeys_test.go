@@ -0,0 +1,214 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brwse/claude-tools-mcp/internal/rest"
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeAPIKeys writes keys to a file and returns its path.
+func writeAPIKeys(t *testing.T, keys string) string {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, []byte(keys), 0o600))
+	return path
+}
+
+func keyHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	state := NewState()
+	require.NoError(t, state.SetWorkspaces([]string{"app=" + t.TempDir()}))
+	require.NoError(t, state.LoadAPIKeys(writeAPIKeys(t, `[
+		{"name": "ci", "key_sha256": "`+keyHash("ci-token")+`", "scopes": ["read", "exec"]},
+		{"name": "bot", "key_sha256": "`+keyHash("bot-token")+`", "scopes": ["read", "write"], "workspace": "app"}
+	]`)))
+	require.Len(t, state.APIKeys, 2)
+	assert.True(t, state.AuthRequired())
+
+	info, err := state.VerifyToken(context.Background(), "bot-token", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read", "write"}, info.Scopes)
+	assert.Equal(t, "bot", info.Extra["client"])
+	assert.Equal(t, "app", info.Extra["workspace"])
+	assert.False(t, info.Expiration.IsZero())
+	_, err = state.VerifyToken(context.Background(), "wrong", nil)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	a, b := keyHash("a"), keyHash("b")
+	for _, tc := range []struct{ keys, message string }{
+		{`[]`, "No API keys"},
+		{`[{"key_sha256": "` + a + `", "scopes": ["read"]}]`, "has no name"},
+		{`[{"name": "a", "key_sha256": "abc", "scopes": ["read"]}]`, "hex SHA-256"},
+		{`[{"name": "a", "key_sha256": "` + a + `", "scopes": []}]`, "no scopes"},
+		{`[{"name": "a", "key_sha256": "` + a + `", "scopes": ["admin"]}]`, "unknown scope"},
+		{`[{"name": "a", "key_sha256": "` + a + `", "scopes": ["read"], "workspace": "web"}]`, "Unknown workspace"},
+		{`[{"name": "a", "key_sha256": "` + a + `", "scopes": ["read"]}, {"name": "a", "key_sha256": "` + b + `", "scopes": ["read"]}]`, "more than once"},
+		{`[{"name": "a", "key_sha256": "` + a + `", "scopes": ["read"]}, {"name": "b", "key_sha256": "` + a + `", "scopes": ["read"]}]`, "same key_sha256"},
+	} {
+		assert.ErrorContains(t, state.LoadAPIKeys(writeAPIKeys(t, tc.keys)), tc.message, tc.keys)
+	}
+
+	require.NoError(t, state.LoadAPIKeys(""))
+	assert.False(t, state.AuthRequired())
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	state := NewState()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	outside := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "escape")))
+	require.NoError(t, state.SetWorkspaces([]string{"app=" + root}))
+	require.NoError(t, state.LoadAPIKeys(writeAPIKeys(t, `[
+		{"name": "reader", "key_sha256": "`+keyHash("r")+`", "scopes": ["read"]},
+		{"name": "app-writer", "key_sha256": "`+keyHash("w")+`", "scopes": ["read", "write"], "workspace": "app"}
+	]`)))
+
+	// call runs a tools/call made with the given key through the middleware, returning the
+	// arguments the tool received, or the error it was refused with.
+	call := func(key, tool, arguments string) (string, string) {
+		var received json.RawMessage
+		handler := state.AuthMiddleware(func(ctx context.Context, method string, req sdk.Request) (sdk.Result, error) {
+			received = req.(*sdk.CallToolRequest).Params.Arguments
+			return &sdk.CallToolResult{}, nil
+		})
+		req := &sdk.CallToolRequest{Params: &sdk.CallToolParamsRaw{Name: tool, Arguments: json.RawMessage(arguments)}}
+		if key != "" {
+			info, err := state.VerifyToken(context.Background(), key, nil)
+			require.NoError(t, err)
+			req.Extra = &sdk.RequestExtra{TokenInfo: info}
+		}
+		result, err := handler(context.Background(), "tools/call", req)
+		require.NoError(t, err)
+		if toolResult := result.(*sdk.CallToolResult); toolResult.IsError {
+			return "", toolResult.Content[0].(*sdk.TextContent).Text
+		}
+		return string(received), ""
+	}
+
+	// Scopes
+	_, refused := call("r", "read", `{"file_path": "/etc/hosts"}`)
+	assert.Empty(t, refused)
+	_, refused = call("r", "git", `{"operation": "log"}`)
+	assert.Empty(t, refused)
+	_, refused = call("r", "git", `{"operation": "commit", "message": "x"}`)
+	assert.Contains(t, refused, "needs the write scope")
+	_, refused = call("r", "write", `{"file_path": "/tmp/x", "content": ""}`)
+	assert.Contains(t, refused, "needs the write scope")
+	_, refused = call("w", "bash", `{"command": "ls"}`)
+	assert.Contains(t, refused, "needs the exec scope")
+	_, refused = call("", "read", `{"file_path": "/etc/hosts"}`)
+	assert.Contains(t, refused, "no valid credentials")
+
+	// Changes inside .git need exec, since git runs the hooks and commands configured there.
+	_, refused = call("w", "write", `{"file_path": "workspace://app/.git/hooks/pre-commit", "content": ""}`)
+	assert.Contains(t, refused, "need the exec scope")
+	_, refused = call("w", "write_many", `{"files": [{"file_path": "workspace://app/.git/config", "content": ""}]}`)
+	assert.Contains(t, refused, "need the exec scope")
+	_, refused = call("w", "read", `{"file_path": "workspace://app/.git/config"}`)
+	assert.Empty(t, refused)
+	_, refused = call("w", "write", `{"file_path": "workspace://app/.gitignore", "content": ""}`)
+	assert.Empty(t, refused)
+
+	// Workspace restriction
+	arguments, refused := call("w", "write", `{"file_path": "workspace://app/a.txt", "content": ""}`)
+	assert.Empty(t, refused)
+	assert.JSONEq(t, `{"file_path": "workspace://app/a.txt", "content": ""}`, arguments)
+	_, refused = call("w", "write", `{"file_path": "`+filepath.Join(root, "b.txt")+`", "content": ""}`)
+	assert.Empty(t, refused)
+	_, refused = call("w", "read", `{"file_path": "/etc/hosts"}`)
+	assert.Contains(t, refused, "outside the workspace")
+	_, refused = call("w", "read", `{"file_path": "`+filepath.Join(root, "escape", "secret")+`"}`)
+	assert.Contains(t, refused, "outside the workspace")
+	_, refused = call("w", "move_file", `{"source": "`+filepath.Join(root, "a")+`", "destination": "/tmp/a"}`)
+	assert.Contains(t, refused, "outside the workspace")
+	_, refused = call("w", "write_many", `{"files": [{"file_path": "workspace://app/a", "content": ""}, {"file_path": "/tmp/b", "content": ""}]}`)
+	assert.Contains(t, refused, "outside the workspace")
+	_, refused = call("w", "git", `{"operation": "diff", "path": "workspace://app", "files": ["../other"]}`)
+	assert.Contains(t, refused, "leaves its directory")
+	_, refused = call("w", "task", `{"prompt": "find it"}`)
+	assert.Contains(t, refused, "cannot be confined")
+
+	// Tools that default to the working directory get the workspace root instead.
+	arguments, refused = call("w", "glob", `{"pattern": "**/*.go"}`)
+	assert.Empty(t, refused)
+	assert.JSONEq(t, `{"pattern": "**/*.go", "path": "`+root+`"}`, arguments)
+	arguments, _ = call("w", "glob", `{"pattern": "*.go", "workspace": "app", "path": "src"}`)
+	assert.JSONEq(t, `{"pattern": "*.go", "workspace": "app", "path": "src"}`, arguments)
+	_, refused = call("w", "grep", `{"pattern": "x", "workspace": "web"}`)
+	assert.Contains(t, refused, "cannot search workspace web")
+	arguments, refused = call("w", "structural_search", `{"pattern": "fmt.Println($X)"}`)
+	assert.Empty(t, refused)
+	assert.JSONEq(t, `{"pattern": "fmt.Println($X)", "path": "`+root+`"}`, arguments)
+	arguments, refused = call("r", "structural_search", `{"pattern": "fmt.Println($X)"}`)
+	assert.Empty(t, refused)
+	assert.JSONEq(t, `{"pattern": "fmt.Println($X)"}`, arguments, "unconfined keys keep the working directory")
+	_, refused = call("w", "structural_search", `{"pattern": "x", "path": "/etc"}`)
+	assert.Contains(t, refused, "outside the workspace")
+	arguments, refused = call("w", "semantic_search", `{"query": "auth"}`)
+	assert.Empty(t, refused)
+	assert.JSONEq(t, `{"query": "auth", "path": "`+root+`"}`, arguments)
+	_, refused = call("w", "semantic_search", `{"query": "auth", "path": "/etc"}`)
+	assert.Contains(t, refused, "outside the workspace")
+	arguments, refused = call("w", "count_tokens", `{"pattern": "**/*.go"}`)
+	assert.Empty(t, refused)
+	assert.JSONEq(t, `{"pattern": "**/*.go", "path": "`+root+`"}`, arguments)
+	arguments, refused = call("w", "count_tokens", `{"text": "hello"}`)
+	assert.Empty(t, refused)
+	assert.JSONEq(t, `{"text": "hello"}`, arguments)
+	_, refused = call("w", "count_tokens", `{"pattern": "*", "path": "/etc"}`)
+	assert.Contains(t, refused, "outside the workspace")
+
+	// REST calls carry their token info in _meta.
+	handler := state.AuthMiddleware(func(ctx context.Context, method string, req sdk.Request) (sdk.Result, error) {
+		return &sdk.CallToolResult{}, nil
+	})
+	restSession, otherSession := connectTestSession(t), connectTestSession(t)
+	state.SetRESTSession(restSession)
+	meta := sdk.Meta{rest.TokenMetaKey: map[string]any{"scopes": []any{"read"}, "extra": map[string]any{"client": "reader"}}}
+	result, err := handler(context.Background(), "tools/call", &sdk.CallToolRequest{Session: restSession, Params: &sdk.CallToolParamsRaw{
+		Name: "read", Arguments: json.RawMessage(`{"file_path": "/etc/hosts"}`), Meta: meta,
+	}})
+	require.NoError(t, err)
+	assert.False(t, result.(*sdk.CallToolResult).IsError)
+	result, err = handler(context.Background(), "tools/call", &sdk.CallToolRequest{Session: restSession, Params: &sdk.CallToolParamsRaw{
+		Name: "delete_file", Arguments: json.RawMessage(`{"file_path": "/tmp/x"}`), Meta: meta,
+	}})
+	require.NoError(t, err)
+	assert.True(t, result.(*sdk.CallToolResult).IsError)
+
+	// Other sessions cannot vouch for themselves through _meta.
+	result, err = handler(context.Background(), "tools/call", &sdk.CallToolRequest{Session: otherSession, Params: &sdk.CallToolParamsRaw{
+		Name: "read", Arguments: json.RawMessage(`{"file_path": "/etc/hosts"}`), Meta: meta,
+	}})
+	require.NoError(t, err)
+	assert.True(t, result.(*sdk.CallToolResult).IsError)
+	assert.Contains(t, result.(*sdk.CallToolResult).Content[0].(*sdk.TextContent).Text, "no valid credentials")
+}
+
+// connectTestSession connects a client to a new server over an in-memory transport and returns the
+// server's end of the session.
+func connectTestSession(t *testing.T) *sdk.ServerSession {
+	t.Helper()
+	serverTransport, clientTransport := sdk.NewInMemoryTransports()
+	server := sdk.NewServer(&sdk.Implementation{Name: "test"}, nil)
+	session, err := server.Connect(context.Background(), serverTransport, nil)
+	require.NoError(t, err)
+	client, err := sdk.NewClient(&sdk.Implementation{Name: "test"}, nil).Connect(context.Background(), clientTransport, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return session
+}
@@ -0,0 +1,231 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// defaultApprovalTimeout leaves a person time to review a call before it is treated as denied.
+const defaultApprovalTimeout = 5 * time.Minute
+
+// ApprovalRule selects tool calls that must be approved by the webhook: calls to Tool ("*" for any
+// tool) with a string argument matching Pattern. A nil Pattern matches every call to the tool.
+type ApprovalRule struct {
+	Spec    string
+	Tool    string
+	Pattern *regexp.Regexp
+}
+
+// approvalRequest is the body POSTed to the approval webhook for each matching call.
+type approvalRequest struct {
+	ID          string          `json:"id"`
+	Tool        string          `json:"tool"`
+	Arguments   json.RawMessage `json:"arguments"`
+	Rule        string          `json:"rule"`
+	SessionID   string          `json:"session_id"`
+	RequestedAt time.Time       `json:"requested_at"`
+}
+
+// approvalResponse is what the webhook must answer with; Decision is "allow" or "deny".
+type approvalResponse struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// SetApprovalWebhook configures the webhook that must allow tool calls matching rules before they
+// run. Each rule has the form tool:regexp, such as "bash:\bgit\s+push\b" or
+// "write:/\.github/workflows/". An empty webhookURL disables approval. When secret is set, each
+// request carries an HMAC-SHA256 signature of its body so the webhook can verify the sender.
+func (s *State) SetApprovalWebhook(webhookURL string, rules []string, timeout time.Duration, secret string) error {
+	var parsed []ApprovalRule
+	if webhookURL != "" {
+		u, err := url.Parse(webhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Invalid approval webhook URL: %s. Must be an http or https URL", webhookURL)
+		}
+		if len(rules) == 0 {
+			return fmt.Errorf("an approval webhook needs at least one approval rule")
+		}
+		for _, spec := range rules {
+			rule, err := parseApprovalRule(spec)
+			if err != nil {
+				return err
+			}
+			parsed = append(parsed, rule)
+		}
+	}
+	if timeout <= 0 {
+		timeout = defaultApprovalTimeout
+	}
+	s.Mu.Lock()
+	s.ApprovalURL = webhookURL
+	s.ApprovalRules = parsed
+	s.ApprovalTimeout = timeout
+	s.ApprovalSecret = secret
+	s.Mu.Unlock()
+	return nil
+}
+
+func parseApprovalRule(spec string) (ApprovalRule, error) {
+	tool, pattern, ok := strings.Cut(spec, ":")
+	if !ok || tool == "" {
+		return ApprovalRule{}, fmt.Errorf("Invalid approval rule: %s. Must be tool:regexp, e.g. bash:git\\s+push", spec)
+	}
+	rule := ApprovalRule{Spec: spec, Tool: tool}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return ApprovalRule{}, fmt.Errorf("Invalid approval rule %q: %s", spec, err)
+		}
+		rule.Pattern = re
+	}
+	return rule, nil
+}
+
+// matches reports whether the rule covers a call to tool with the given JSON arguments.
+func (r ApprovalRule) matches(tool string, arguments json.RawMessage) bool {
+	if r.Tool != "*" && r.Tool != tool {
+		return false
+	}
+	if r.Pattern == nil {
+		return true
+	}
+	var decoded any
+	if err := json.Unmarshal(arguments, &decoded); err != nil {
+		// Arguments the rule cannot inspect are sent for approval rather than waved through.
+		return true
+	}
+	return anyString(decoded, r.Pattern.MatchString)
+}
+
+// anyString reports whether match holds for any string within a decoded JSON value.
+func anyString(value any, match func(string) bool) bool {
+	switch v := value.(type) {
+	case string:
+		return match(v)
+	case []any:
+		for _, item := range v {
+			if anyString(item, match) {
+				return true
+			}
+		}
+	case map[string]any:
+		for _, item := range v {
+			if anyString(item, match) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ApprovalMiddleware holds tool calls matching an approval rule until the webhook allows them.
+// Calls the webhook denies, or that cannot be approved because the webhook fails or times out, are
+// answered with a tool error without running.
+func (s *State) ApprovalMiddleware(next sdk.MethodHandler) sdk.MethodHandler {
+	return func(ctx context.Context, method string, req sdk.Request) (sdk.Result, error) {
+		call, ok := req.(*sdk.CallToolRequest)
+		if !ok || call.Params == nil {
+			return next(ctx, method, req)
+		}
+		s.Mu.RLock()
+		webhookURL, rules, timeout, secret := s.ApprovalURL, s.ApprovalRules, s.ApprovalTimeout, s.ApprovalSecret
+		s.Mu.RUnlock()
+		if webhookURL == "" {
+			return next(ctx, method, req)
+		}
+		for _, rule := range rules {
+			if !rule.matches(call.Params.Name, call.Params.Arguments) {
+				continue
+			}
+			body := approvalRequest{
+				ID:          newApprovalID(),
+				Tool:        call.Params.Name,
+				Arguments:   call.Params.Arguments,
+				Rule:        rule.Spec,
+				SessionID:   sessionID(call),
+				RequestedAt: time.Now().UTC(),
+			}
+			start := time.Now()
+			err := requestApproval(ctx, webhookURL, secret, timeout, body)
+			addQueueTime(ctx, time.Since(start))
+			if err != nil {
+				return &sdk.CallToolResult{
+					Content: []sdk.Content{&sdk.TextContent{Text: err.Error()}},
+					IsError: true,
+				}, nil
+			}
+			break
+		}
+		return next(ctx, method, req)
+	}
+}
+
+// requestApproval posts a pending call to the webhook and returns nil only on an explicit allow.
+func requestApproval(ctx context.Context, webhookURL, secret string, timeout time.Duration, body approvalRequest) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("Cannot encode approval request: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("Cannot create approval request: %s", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(payload)
+		httpReq.Header.Set("X-Approval-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("Tool call %s was not approved within %s and did not run.", body.Tool, timeout)
+		}
+		return fmt.Errorf("Approval webhook unavailable, so tool call %s did not run: %s", body.Tool, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Approval webhook returned HTTP %d, so tool call %s did not run.", resp.StatusCode, body.Tool)
+	}
+	var decision approvalResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&decision); err != nil {
+		return fmt.Errorf("Approval webhook returned an invalid response, so tool call %s did not run: %s", body.Tool, err)
+	}
+	switch decision.Decision {
+	case "allow":
+		return nil
+	case "deny":
+		message := fmt.Sprintf("Tool call %s was denied by the approval webhook.", body.Tool)
+		if decision.Reason != "" {
+			message += " Reason: " + decision.Reason
+		}
+		return errors.New(message)
+	default:
+		return fmt.Errorf("Approval webhook returned unknown decision %q, so tool call %s did not run.", decision.Decision, body.Tool)
+	}
+}
+
+func newApprovalID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
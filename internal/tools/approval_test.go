@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetApprovalWebhook(t *testing.T) {
+	state := NewState()
+	require.NoError(t, state.SetApprovalWebhook("https://approvals.example.com/hook", []string{`bash:\bgit\s+push\b`, "*:rm -rf", "delete_file:"}, 0, ""))
+	require.Len(t, state.ApprovalRules, 3)
+	assert.Equal(t, defaultApprovalTimeout, state.ApprovalTimeout)
+	assert.Nil(t, state.ApprovalRules[2].Pattern)
+
+	require.Error(t, state.SetApprovalWebhook("ftp://example.com", []string{"bash:x"}, 0, ""))
+	require.Error(t, state.SetApprovalWebhook("https://example.com", nil, 0, ""))
+	require.Error(t, state.SetApprovalWebhook("https://example.com", []string{"no-separator"}, 0, ""))
+	require.Error(t, state.SetApprovalWebhook("https://example.com", []string{"bash:("}, 0, ""))
+
+	// Without a URL, approval is disabled and rules are ignored
+	require.NoError(t, state.SetApprovalWebhook("", []string{"bash:("}, 0, ""))
+	assert.Empty(t, state.ApprovalRules)
+}
+
+func TestApprovalRule_Matches(t *testing.T) {
+	push, err := parseApprovalRule(`bash:\bgit\s+push\b`)
+	require.NoError(t, err)
+	assert.True(t, push.matches("bash", json.RawMessage(`{"command":"git push origin main"}`)))
+	assert.False(t, push.matches("bash", json.RawMessage(`{"command":"git status"}`)))
+	assert.False(t, push.matches("write", json.RawMessage(`{"content":"git push"}`)))
+
+	ci, err := parseApprovalRule(`*:/\.github/workflows/`)
+	require.NoError(t, err)
+	assert.True(t, ci.matches("write", json.RawMessage(`{"file_path":"/repo/.github/workflows/ci.yml"}`)))
+	assert.True(t, ci.matches("move_file", json.RawMessage(`{"source":"/tmp/a","destination":"/repo/.github/workflows/a.yml"}`)))
+	assert.False(t, ci.matches("write", json.RawMessage(`{"file_path":"/repo/main.go"}`)))
+}
+
+func TestApprovalMiddleware(t *testing.T) {
+	var received approvalRequest
+	var signature string
+	decision := `{"decision":"allow"}`
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &received))
+		signature = r.Header.Get("X-Approval-Signature")
+
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(body)
+		assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), signature)
+		_, _ = w.Write([]byte(decision))
+	}))
+	defer webhook.Close()
+
+	state := NewState()
+	require.NoError(t, state.SetApprovalWebhook(webhook.URL, []string{`bash:\bgit\s+push\b`}, time.Second, "s3cret"))
+
+	// call runs a tools/call through the middleware and reports whether the tool ran
+	call := func(tool, arguments string) (bool, sdk.Result) {
+		ran := false
+		handler := state.ApprovalMiddleware(func(ctx context.Context, method string, req sdk.Request) (sdk.Result, error) {
+			ran = true
+			return &sdk.CallToolResult{}, nil
+		})
+		result, err := handler(context.Background(), "tools/call", &sdk.CallToolRequest{
+			Params: &sdk.CallToolParamsRaw{Name: tool, Arguments: json.RawMessage(arguments)},
+		})
+		require.NoError(t, err)
+		return ran, result
+	}
+
+	t.Run("unmatched calls skip the webhook", func(t *testing.T) {
+		ran, _ := call("bash", `{"command":"git status"}`)
+		assert.True(t, ran)
+		assert.Empty(t, signature)
+	})
+
+	t.Run("allowed calls run", func(t *testing.T) {
+		ran, _ := call("bash", `{"command":"git push"}`)
+		assert.True(t, ran)
+		assert.Equal(t, "bash", received.Tool)
+		assert.JSONEq(t, `{"command":"git push"}`, string(received.Arguments))
+		assert.Equal(t, `bash:\bgit\s+push\b`, received.Rule)
+		assert.NotEmpty(t, received.ID)
+	})
+
+	t.Run("denied calls return a tool error", func(t *testing.T) {
+		decision = `{"decision":"deny","reason":"release freeze"}`
+		ran, result := call("bash", `{"command":"git push --tags"}`)
+		assert.False(t, ran)
+		toolResult := result.(*sdk.CallToolResult)
+		assert.True(t, toolResult.IsError)
+		assert.Contains(t, toolResult.Content[0].(*sdk.TextContent).Text, "release freeze")
+	})
+
+	t.Run("unknown decisions deny", func(t *testing.T) {
+		decision = `{"decision":"maybe"}`
+		ran, _ := call("bash", `{"command":"git push"}`)
+		assert.False(t, ran)
+	})
+}
+
+func TestApprovalMiddleware_WebhookFailures(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer slow.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	body := approvalRequest{Tool: "bash", Arguments: json.RawMessage(`{}`)}
+
+	err := requestApproval(context.Background(), slow.URL, "", 50*time.Millisecond, body)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not approved within")
+
+	err = requestApproval(context.Background(), broken.URL, "", time.Second, body)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "HTTP 500")
+}
@@ -0,0 +1,542 @@
+package tools
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+	"github.com/gabriel-vasile/mimetype"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// maxArchiveExtractSize bounds the total bytes written by one extraction so a small archive that
+	// expands enormously cannot fill the disk.
+	maxArchiveExtractSize = 1024 * 1024 * 1024
+
+	// maxArchiveExtractEntries bounds how many entries one extraction creates.
+	maxArchiveExtractEntries = 100_000
+)
+
+// errArchiveMemberTooLarge stops reading a member once it passes the size limit, since the sizes
+// recorded in an archive's headers can't be trusted.
+var errArchiveMemberTooLarge = errors.New("archive member is too large")
+
+// errStopWalk ends a walkArchive early without reporting an error.
+var errStopWalk = errors.New("stop walking archive")
+
+type archiveEntry struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Size    int64  `json:"size"`
+	ModTime string `json:"mtime,omitempty"`
+	Mode    string `json:"permissions,omitempty"`
+	Target  string `json:"target,omitempty"`
+}
+
+type archiveListing struct {
+	Path      string         `json:"path"`
+	Format    string         `json:"format"`
+	Entries   []archiveEntry `json:"entries"`
+	Count     int            `json:"count"`
+	Truncated bool           `json:"truncated,omitempty"`
+}
+
+type archiveExtraction struct {
+	Destination string   `json:"destination"`
+	Extracted   int      `json:"extracted"`
+	Bytes       int64    `json:"bytes"`
+	Skipped     []string `json:"skipped,omitempty"`
+}
+
+// archiveMember is one entry of an archive as the walkers below see it. open is only valid during
+// the callback that receives the member.
+type archiveMember struct {
+	entry archiveEntry
+	mode  fs.FileMode
+	open  func() (io.ReadCloser, error)
+}
+
+func (s *State) executeArchive(ctx context.Context, operation, archivePath, member string, members []string, destination string, offset, limit int) (string, error) {
+	resolved, err := s.resolvePath(archivePath)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("File does not exist.")
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("path is a directory, not an archive: %s", resolved)
+	}
+	format, err := archiveFormat(resolved)
+	if err != nil {
+		return "", err
+	}
+
+	switch operation {
+	case "list":
+		return s.listArchive(ctx, resolved, format)
+	case "read":
+		return s.readArchiveMember(ctx, resolved, format, member, offset, limit)
+	case "extract":
+		return s.extractArchive(ctx, resolved, format, members, destination)
+	default:
+		return "", fmt.Errorf("Invalid operation: %s. Must be one of: list, read, extract.", operation)
+	}
+}
+
+// archiveFormat identifies a zip, tar, or gzip-compressed tar file by its content.
+func archiveFormat(path string) (string, error) {
+	mtype, err := mimetype.DetectFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Cannot read file: %s", err)
+	}
+	switch {
+	case mtype.Is("application/zip"):
+		return "zip", nil
+	case mtype.Is("application/x-tar"):
+		return "tar", nil
+	case mtype.Is("application/gzip"):
+		// Only a tar stream inside makes it an archive; a plain .gz is a single compressed file.
+		file, err := os.Open(path)
+		if err != nil {
+			return "", fmt.Errorf("Cannot read file: %s", err)
+		}
+		defer file.Close()
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return "", fmt.Errorf("Cannot decompress file: %s", err)
+		}
+		defer gz.Close()
+		if _, err := tar.NewReader(gz).Next(); err != nil && err != io.EOF {
+			return "", fmt.Errorf("Unsupported archive format: gzip file does not contain a tar archive. Use the Read tool to read it.")
+		}
+		return "tar.gz", nil
+	default:
+		return "", fmt.Errorf("Unsupported archive format: %s. Supported formats are zip, tar, and tar.gz.", mtype.String())
+	}
+}
+
+// walkArchive calls fn for each member of the archive in order, stopping early when fn returns
+// errStopWalk.
+func walkArchive(ctx context.Context, path, format string, fn func(archiveMember) error) error {
+	err := walkArchiveMembers(ctx, path, format, fn)
+	if errors.Is(err, errStopWalk) {
+		return nil
+	}
+	return err
+}
+
+func walkArchiveMembers(ctx context.Context, path, format string, fn func(archiveMember) error) error {
+	if format == "zip" {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return fmt.Errorf("Cannot open archive: %s", err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			mode := f.Mode()
+			entry := archiveEntry{
+				Name:    f.Name,
+				Type:    fileTypeName(mode),
+				Size:    int64(f.UncompressedSize64),
+				ModTime: f.Modified.Format(time.RFC3339),
+				Mode:    mode.Perm().String(),
+			}
+			if mode&fs.ModeSymlink != 0 {
+				entry.Target = readZipSymlink(f)
+			}
+			if err := fn(archiveMember{entry: entry, mode: mode, open: f.Open}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Cannot open archive: %s", err)
+	}
+	defer file.Close()
+	var stream io.Reader = file
+	if format == "tar.gz" {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("Cannot decompress archive: %s", err)
+		}
+		defer gz.Close()
+		stream = gz
+	}
+	tr := tar.NewReader(stream)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Cannot read archive: %s", err)
+		}
+		mode := hdr.FileInfo().Mode()
+		entry := archiveEntry{
+			Name:    hdr.Name,
+			Type:    fileTypeName(mode),
+			Size:    hdr.Size,
+			ModTime: hdr.ModTime.Format(time.RFC3339),
+			Mode:    mode.Perm().String(),
+			Target:  hdr.Linkname,
+		}
+		if hdr.Typeflag == tar.TypeLink {
+			entry.Type = "hardlink"
+		}
+		open := func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }
+		if err := fn(archiveMember{entry: entry, mode: mode, open: open}); err != nil {
+			return err
+		}
+	}
+}
+
+// readZipSymlink returns the target of a symlink stored in a zip file, which is kept as the
+// member's content.
+func readZipSymlink(f *zip.File) string {
+	rc, err := f.Open()
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+	target, _ := io.ReadAll(io.LimitReader(rc, 4096))
+	return string(target)
+}
+
+func (s *State) listArchive(ctx context.Context, resolved, format string) (string, error) {
+	maxResults := limitsFromContext(ctx).maxResults
+	result := archiveListing{Path: resolved, Format: format, Entries: []archiveEntry{}}
+	err := walkArchive(ctx, resolved, format, func(m archiveMember) error {
+		if len(result.Entries) == maxResults {
+			result.Truncated = true
+			return errStopWalk
+		}
+		result.Entries = append(result.Entries, m.entry)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	result.Count = len(result.Entries)
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format archive listing: %s", err)
+	}
+	output := string(jsonBytes)
+	if err := checkOutputSize(ctx, output, "archive"); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// readArchiveMember returns a text member's lines in the Read tool's cat -n format.
+func (s *State) readArchiveMember(ctx context.Context, resolved, format, member string, offset, limit int) (string, error) {
+	if member == "" {
+		return "", fmt.Errorf("member is required for the read operation")
+	}
+	s.Mu.RLock()
+	maxSize := s.MaxFileSize
+	s.Mu.RUnlock()
+
+	var content []byte
+	found := false
+	err := walkArchive(ctx, resolved, format, func(m archiveMember) error {
+		if path.Clean(m.entry.Name) != path.Clean(member) {
+			return nil
+		}
+		found = true
+		if m.entry.Type != "file" {
+			return fmt.Errorf("member %s is a %s, not a file", member, m.entry.Type)
+		}
+		if m.entry.Size > maxSize {
+			return fmt.Errorf("Member content (%d bytes) exceeds maximum allowed size (%d bytes). Extract it with the extract operation and use the Read tool with offset and limit instead.", m.entry.Size, maxSize)
+		}
+		rc, err := m.open()
+		if err != nil {
+			return fmt.Errorf("Cannot read member: %s", err)
+		}
+		defer rc.Close()
+		content, err = readAtMost(rc, maxSize)
+		if errors.Is(err, errArchiveMemberTooLarge) {
+			return fmt.Errorf("Member content exceeds maximum allowed size (%d bytes). Extract it with the extract operation and use the Read tool with offset and limit instead.", maxSize)
+		}
+		if err != nil {
+			return fmt.Errorf("Cannot read member: %s", err)
+		}
+		return errStopWalk
+	})
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("member not found in archive: %s", member)
+	}
+
+	if len(content) == 0 {
+		return "<system-reminder>Warning: the archive member exists but the contents are empty.</system-reminder>", nil
+	}
+	if mtype := mimetype.Detect(content); !isTextMIME(mtype) {
+		return fmt.Sprintf("[Binary member: %s (%s), %d bytes. Extract it with the extract operation to inspect its bytes]", member, mtype.String(), len(content)), nil
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if offset <= 0 {
+		offset = 1
+	}
+	if limit <= 0 {
+		limit = 2000
+	}
+	if offset > len(lines) {
+		return "", fmt.Errorf("offset %d is beyond the end of the member (%d lines)", offset, len(lines))
+	}
+	end := min(offset-1+limit, len(lines))
+	output := catN(lines[offset-1:end], offset)
+	if err := checkOutputSize(ctx, output, "archive"); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// readAtMost reads all of r, failing once more than limit bytes have been read.
+func readAtMost(r io.Reader, limit int64) ([]byte, error) {
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > limit {
+		return nil, errArchiveMemberTooLarge
+	}
+	return buf.Bytes(), nil
+}
+
+// extractArchive unpacks the archive, or the named members and everything beneath them, into
+// destination. Every member must land inside destination: absolute names and names climbing out
+// with ".." are rejected before anything is written, and links are skipped rather than created,
+// since a link could point later members outside the destination. Existing files are never
+// overwritten.
+func (s *State) extractArchive(ctx context.Context, resolved, format string, members []string, destination string) (string, error) {
+	if err := s.checkOverlay("archive extraction"); err != nil {
+		return "", err
+	}
+	if destination == "" {
+		return "", fmt.Errorf("destination is required for the extract operation")
+	}
+	dest, err := s.resolvePath(destination)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(dest); err != nil {
+		return "", err
+	}
+	if err := s.confirmWrite(ctx, "archive", dest); err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(dest); err == nil && !info.IsDir() {
+		return "", fmt.Errorf("destination is not a directory: %s", dest)
+	}
+
+	wanted := make([]string, 0, len(members))
+	for _, m := range members {
+		wanted = append(wanted, strings.TrimSuffix(path.Clean(m), "/"))
+	}
+	// selected reports whether a member is to be extracted, noting which requested members matched.
+	matched := make(map[string]bool)
+	selected := func(name string) bool {
+		if len(wanted) == 0 {
+			return true
+		}
+		name = path.Clean(name)
+		found := false
+		for _, w := range wanted {
+			if name == w || strings.HasPrefix(name, w+"/") {
+				matched[w] = true
+				found = true
+			}
+		}
+		return found
+	}
+
+	// Validate every selected member before writing anything, so a hostile entry late in the
+	// archive cannot leave a partial extraction behind.
+	var total int64
+	count := 0
+	err = walkArchive(ctx, resolved, format, func(m archiveMember) error {
+		if !selected(m.entry.Name) {
+			return nil
+		}
+		target, err := archiveTarget(dest, m.entry.Name)
+		if err != nil {
+			return err
+		}
+		// A symlink already inside the destination could still redirect the write elsewhere.
+		if !isWithin(vfs.OS{}, dest, target) {
+			return fmt.Errorf("refusing to extract member %s: it would be written outside the destination", m.entry.Name)
+		}
+		if err := s.checkPathAllowed(target); err != nil {
+			return err
+		}
+		if m.entry.Type == "file" {
+			if _, err := os.Lstat(target); err == nil {
+				return fmt.Errorf("destination already exists: %s", target)
+			}
+			total += m.entry.Size
+		}
+		count++
+		if count > maxArchiveExtractEntries {
+			return fmt.Errorf("archive has more than %d entries to extract. Select fewer members.", maxArchiveExtractEntries)
+		}
+		if total > maxArchiveExtractSize {
+			return fmt.Errorf("extracted content would exceed %d bytes. Select fewer members.", maxArchiveExtractSize)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, w := range wanted {
+		if !matched[w] {
+			return "", fmt.Errorf("member not found in archive: %s", w)
+		}
+	}
+
+	result := archiveExtraction{Destination: dest}
+	remaining := int64(maxArchiveExtractSize)
+	err = walkArchive(ctx, resolved, format, func(m archiveMember) error {
+		if !selected(m.entry.Name) {
+			return nil
+		}
+		target, err := archiveTarget(dest, m.entry.Name)
+		if err != nil {
+			return err
+		}
+		switch m.entry.Type {
+		case "dir":
+			if err := os.MkdirAll(target, 0o750); err != nil {
+				return fmt.Errorf("Cannot create directory: %s", err)
+			}
+		case "file":
+			if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+				return fmt.Errorf("Cannot create parent directory: %s", err)
+			}
+			s.noteChange(ctx, "archive", target)
+			written, err := extractArchiveFile(m, target, remaining)
+			if err != nil {
+				return err
+			}
+			remaining -= written
+			result.Bytes += written
+		default:
+			result.Skipped = append(result.Skipped, m.entry.Name)
+			return nil
+		}
+		result.Extracted++
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	// Extracted files hold content the caller never read, so drop any stale tracking beneath dest.
+	s.forgetPath(dest)
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format extraction result: %s", err)
+	}
+	return string(jsonBytes), nil
+}
+
+// archiveTarget maps a member name to its path beneath dest, rejecting names that would escape it.
+func archiveTarget(dest, name string) (string, error) {
+	clean := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || filepath.VolumeName(clean) != "" {
+		return "", fmt.Errorf("refusing to extract member %s: it would be written outside the destination", name)
+	}
+	return filepath.Join(dest, filepath.FromSlash(clean)), nil
+}
+
+// extractArchiveFile writes a regular member to target, which must not exist, writing at most
+// limit bytes.
+func extractArchiveFile(m archiveMember, target string, limit int64) (int64, error) {
+	rc, err := m.open()
+	if err != nil {
+		return 0, fmt.Errorf("Cannot read member %s: %s", m.entry.Name, err)
+	}
+	defer rc.Close()
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, m.mode.Perm()|0o600)
+	if err != nil {
+		return 0, fmt.Errorf("Cannot write file: %s", err)
+	}
+	written, err := io.Copy(out, io.LimitReader(rc, limit+1))
+	if err == nil && written > limit {
+		err = fmt.Errorf("extracted content would exceed %d bytes", maxArchiveExtractSize)
+	}
+	if closeErr := out.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(target)
+		return 0, fmt.Errorf("Cannot write file %s: %s", target, err)
+	}
+	return written, nil
+}
+
+var ArchiveTool = sdk.Tool{
+	Name:        "archive",
+	Description: "Inspects and unpacks zip, tar, and tar.gz archives.\n\nOperations:\n- list: every entry with name, type, size, modification time, and permissions (up to the result limit)\n- read: the text content of one member in cat -n format; use offset and limit to page through long members. Binary members are reported but not returned\n- extract: unpacks the archive, or only the given members (a directory member includes everything beneath it), into destination\n\nUsage notes:\n- path and destination must be absolute paths\n- Members are named as they appear in the list output\n- Extraction never overwrites existing files and refuses members whose names would escape the destination. Symlinks and hardlinks are skipped and reported\n- Use this tool instead of running unzip or tar via Bash to inspect release artifacts",
+}
+
+type ArchiveInput struct {
+	Operation   string   `json:"operation" jsonschema:"The archive operation: list, read, or extract"`
+	Path        string   `json:"path" jsonschema:"The absolute path to the archive file"`
+	Member      string   `json:"member,omitempty" jsonschema:"For read: the name of the member to read"`
+	Members     []string `json:"members,omitempty" jsonschema:"For extract: the members to extract. Defaults to the whole archive"`
+	Destination string   `json:"destination,omitempty" jsonschema:"For extract: the absolute path of the directory to extract into"`
+	Offset      int      `json:"offset,omitempty" jsonschema:"For read: the line number to start reading from"`
+	Limit       int      `json:"limit,omitempty" jsonschema:"For read: the number of lines to read (default 2000)"`
+}
+type ArchiveOutput struct {
+	Result string `json:"result"`
+}
+
+func Archive(ctx context.Context, req *sdk.CallToolRequest, args ArchiveInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeArchive(withSession(ctx, req), args.Operation, args.Path, args.Member, args.Members,
+		args.Destination, args.Offset, args.Limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &ArchiveOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,238 @@
+package tools
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type archiveTestMember struct {
+	name     string
+	content  string
+	dir      bool
+	linkname string
+}
+
+var archiveTestMembers = []archiveTestMember{
+	{name: "release/", dir: true},
+	{name: "release/README.md", content: "# Release\nline two\nline three\n"},
+	{name: "release/bin/tool", content: "\x7fELF\x00\x01\x02\x03"},
+	{name: "release/latest", linkname: "README.md"},
+}
+
+func writeTestTar(t *testing.T, path string, compress bool, members []archiveTestMember) {
+	t.Helper()
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var gz *gzip.Writer
+	tw := tar.NewWriter(file)
+	if compress {
+		gz = gzip.NewWriter(file)
+		tw = tar.NewWriter(gz)
+	}
+	for _, m := range members {
+		hdr := &tar.Header{Name: m.name, Mode: 0o644, Size: int64(len(m.content)), Typeflag: tar.TypeReg}
+		switch {
+		case m.dir:
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0o755
+		case m.linkname != "":
+			hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, m.linkname
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		if hdr.Typeflag == tar.TypeReg {
+			_, err := tw.Write([]byte(m.content))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	if gz != nil {
+		require.NoError(t, gz.Close())
+	}
+}
+
+func writeTestZip(t *testing.T, path string, members []archiveTestMember) {
+	t.Helper()
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	zw := zip.NewWriter(file)
+	for _, m := range members {
+		if m.linkname != "" {
+			continue
+		}
+		w, err := zw.Create(m.name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(m.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+}
+
+func TestArchive_List(t *testing.T) {
+	dir := t.TempDir()
+	state := NewState()
+	tgz := filepath.Join(dir, "release.tar.gz")
+	writeTestTar(t, tgz, true, archiveTestMembers)
+	zipPath := filepath.Join(dir, "release.zip")
+	writeTestZip(t, zipPath, archiveTestMembers)
+
+	for _, tc := range []struct {
+		path, format string
+		count        int
+	}{{tgz, "tar.gz", 4}, {zipPath, "zip", 3}} {
+		t.Run(tc.format, func(t *testing.T) {
+			result, err := state.executeArchive(context.Background(), "list", tc.path, "", nil, "", 0, 0)
+			require.NoError(t, err)
+			var listing archiveListing
+			require.NoError(t, json.Unmarshal([]byte(result), &listing))
+			assert.Equal(t, tc.format, listing.Format)
+			assert.Equal(t, tc.count, listing.Count)
+			assert.Equal(t, "release/", listing.Entries[0].Name)
+			assert.Equal(t, "dir", listing.Entries[0].Type)
+			assert.Equal(t, "release/README.md", listing.Entries[1].Name)
+			assert.Equal(t, int64(30), listing.Entries[1].Size)
+		})
+	}
+
+	t.Run("reports symlink targets", func(t *testing.T) {
+		result, err := state.executeArchive(context.Background(), "list", tgz, "", nil, "", 0, 0)
+		require.NoError(t, err)
+		var listing archiveListing
+		require.NoError(t, json.Unmarshal([]byte(result), &listing))
+		assert.Equal(t, "symlink", listing.Entries[3].Type)
+		assert.Equal(t, "README.md", listing.Entries[3].Target)
+	})
+
+	t.Run("rejects non-archives", func(t *testing.T) {
+		plain := filepath.Join(dir, "notes.txt")
+		require.NoError(t, os.WriteFile(plain, []byte("hello\n"), 0o644))
+		_, err := state.executeArchive(context.Background(), "list", plain, "", nil, "", 0, 0)
+		assert.ErrorContains(t, err, "Unsupported archive format")
+	})
+}
+
+func TestArchive_Read(t *testing.T) {
+	dir := t.TempDir()
+	state := NewState()
+	tarPath := filepath.Join(dir, "release.tar")
+	writeTestTar(t, tarPath, false, archiveTestMembers)
+
+	t.Run("returns text in cat -n format", func(t *testing.T) {
+		result, err := state.executeArchive(context.Background(), "read", tarPath, "release/README.md", nil, "", 0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "     1→# Release\n     2→line two\n     3→line three", result)
+	})
+	t.Run("pages with offset and limit", func(t *testing.T) {
+		result, err := state.executeArchive(context.Background(), "read", tarPath, "release/README.md", nil, "", 2, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "     2→line two", result)
+	})
+	t.Run("reports binary members", func(t *testing.T) {
+		result, err := state.executeArchive(context.Background(), "read", tarPath, "release/bin/tool", nil, "", 0, 0)
+		require.NoError(t, err)
+		assert.Contains(t, result, "[Binary member: release/bin/tool")
+	})
+	t.Run("enforces the size limit", func(t *testing.T) {
+		limited := NewState()
+		require.NoError(t, limited.SetMaxFileSize(8))
+		_, err := limited.executeArchive(context.Background(), "read", tarPath, "release/README.md", nil, "", 0, 0)
+		assert.ErrorContains(t, err, "exceeds maximum allowed size")
+	})
+	t.Run("missing member", func(t *testing.T) {
+		_, err := state.executeArchive(context.Background(), "read", tarPath, "nope.txt", nil, "", 0, 0)
+		assert.ErrorContains(t, err, "member not found")
+	})
+	t.Run("directory member", func(t *testing.T) {
+		_, err := state.executeArchive(context.Background(), "read", tarPath, "release", nil, "", 0, 0)
+		assert.ErrorContains(t, err, "is a dir, not a file")
+	})
+}
+
+func TestArchive_Extract(t *testing.T) {
+	t.Run("extracts everything and skips links", func(t *testing.T) {
+		dir := t.TempDir()
+		state := NewState()
+		tgz := filepath.Join(dir, "release.tgz")
+		writeTestTar(t, tgz, true, archiveTestMembers)
+		dest := filepath.Join(dir, "out")
+
+		result, err := state.executeArchive(context.Background(), "extract", tgz, "", nil, dest, 0, 0)
+		require.NoError(t, err)
+		var extraction archiveExtraction
+		require.NoError(t, json.Unmarshal([]byte(result), &extraction))
+		assert.Equal(t, 3, extraction.Extracted)
+		assert.Equal(t, []string{"release/latest"}, extraction.Skipped)
+
+		content, err := os.ReadFile(filepath.Join(dest, "release", "README.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "# Release\nline two\nline three\n", string(content))
+		_, err = os.Lstat(filepath.Join(dest, "release", "latest"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("extracts selected members", func(t *testing.T) {
+		dir := t.TempDir()
+		state := NewState()
+		zipPath := filepath.Join(dir, "release.zip")
+		writeTestZip(t, zipPath, archiveTestMembers)
+		dest := filepath.Join(dir, "out")
+
+		_, err := state.executeArchive(context.Background(), "extract", zipPath, "", []string{"release/bin"}, dest, 0, 0)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dest, "release", "bin", "tool"))
+		assert.NoFileExists(t, filepath.Join(dest, "release", "README.md"))
+
+		_, err = state.executeArchive(context.Background(), "extract", zipPath, "", []string{"missing"}, dest, 0, 0)
+		assert.ErrorContains(t, err, "member not found")
+	})
+
+	t.Run("rejects path traversal before writing", func(t *testing.T) {
+		dir := t.TempDir()
+		state := NewState()
+		tarPath := filepath.Join(dir, "evil.tar")
+		writeTestTar(t, tarPath, false, []archiveTestMember{
+			{name: "ok.txt", content: "fine"},
+			{name: "../escape.txt", content: "gotcha"},
+		})
+		dest := filepath.Join(dir, "out")
+
+		_, err := state.executeArchive(context.Background(), "extract", tarPath, "", nil, dest, 0, 0)
+		assert.ErrorContains(t, err, "outside the destination")
+		assert.NoFileExists(t, filepath.Join(dest, "ok.txt"))
+		assert.NoFileExists(t, filepath.Join(dir, "escape.txt"))
+	})
+
+	t.Run("never overwrites existing files", func(t *testing.T) {
+		dir := t.TempDir()
+		state := NewState()
+		tarPath := filepath.Join(dir, "release.tar")
+		writeTestTar(t, tarPath, false, archiveTestMembers)
+		dest := filepath.Join(dir, "out")
+		existing := filepath.Join(dest, "release", "README.md")
+		require.NoError(t, os.MkdirAll(filepath.Dir(existing), 0o755))
+		require.NoError(t, os.WriteFile(existing, []byte("mine"), 0o644))
+
+		_, err := state.executeArchive(context.Background(), "extract", tarPath, "", nil, dest, 0, 0)
+		assert.ErrorContains(t, err, "destination already exists")
+		content, err := os.ReadFile(existing)
+		require.NoError(t, err)
+		assert.Equal(t, "mine", string(content))
+	})
+
+	t.Run("requires a destination", func(t *testing.T) {
+		dir := t.TempDir()
+		tarPath := filepath.Join(dir, "release.tar")
+		writeTestTar(t, tarPath, false, archiveTestMembers)
+		_, err := NewState().executeArchive(context.Background(), "extract", tarPath, "", nil, "", 0, 0)
+		assert.ErrorContains(t, err, "destination is required")
+	})
+}
@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// artifactPreviewSize bounds the preview of saved output returned in place of the full output.
+const artifactPreviewSize = 4000
+
+// SetArtifactsDir sets where bash calls with save_output write their output. An empty dir uses a
+// directory under the system temp directory.
+func (s *State) SetArtifactsDir(dir string) error {
+	if dir != "" {
+		resolved, err := resolvePath(dir)
+		if err != nil {
+			return fmt.Errorf("artifacts directory must be absolute, not relative")
+		}
+		dir = resolved
+	}
+	s.Mu.Lock()
+	s.ArtifactsDir = dir
+	s.Mu.Unlock()
+	return nil
+}
+
+// saveArtifact writes a command's output to a new file in the artifacts directory and returns its
+// path. Names are prefixed like spool files, so runs of different servers never collide.
+func (s *State) saveArtifact(output string) (string, error) {
+	s.Mu.RLock()
+	dir := s.ArtifactsDir
+	s.Mu.RUnlock()
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "claude-tools-artifacts")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("Failed to create artifacts directory: %s", err)
+	}
+	f, err := os.CreateTemp(dir, spoolRunID+"-bash-*.log")
+	if err != nil {
+		return "", fmt.Errorf("Failed to save output: %s", err)
+	}
+	if _, err := f.WriteString(output); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("Failed to save output: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("Failed to save output: %s", err)
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBash_SaveOutput(t *testing.T) {
+	state := NewState()
+	dir := t.TempDir()
+	require.NoError(t, state.SetArtifactsDir(dir))
+	ctx := context.Background()
+
+	t.Run("large output is saved with a preview", func(t *testing.T) {
+		result, err := state.executeBashCommand(ctx, "seq 1 5000", "", 0, false, bashOptions{SaveOutput: true})
+		require.NoError(t, err)
+		require.NotEmpty(t, result.OutputFile)
+		assert.Equal(t, dir, filepath.Dir(result.OutputFile))
+		assert.True(t, result.Truncated)
+		assert.Equal(t, 23893, result.OutputBytes)
+		assert.LessOrEqual(t, len(result.Stdout), artifactPreviewSize)
+		assert.True(t, strings.HasPrefix(result.Result, "1\n2\n"))
+		assert.Contains(t, result.Result, "4999\n5000\n")
+		assert.Contains(t, result.Result, "saved to "+result.OutputFile)
+
+		data, err := os.ReadFile(result.OutputFile)
+		require.NoError(t, err)
+		assert.Len(t, data, 23893)
+	})
+
+	t.Run("small output is saved and returned in full", func(t *testing.T) {
+		result, err := state.executeBashCommand(ctx, "echo hello; exit 1", "", 0, false, bashOptions{SaveOutput: true})
+		require.NoError(t, err)
+		assert.False(t, result.Truncated)
+		assert.Equal(t, 1, result.ExitCode)
+		assert.Equal(t, "hello\n", result.Stdout)
+		data, err := os.ReadFile(result.OutputFile)
+		require.NoError(t, err)
+		assert.Equal(t, "hello\n", string(data))
+	})
+
+	t.Run("not for background commands", func(t *testing.T) {
+		_, err := state.executeBashCommand(ctx, "true", "", 0, true, bashOptions{SaveOutput: true})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "use spool_output")
+	})
+
+	require.Error(t, state.SetArtifactsDir("relative/dir"))
+}
@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+)
+
+// tempFileCounter distinguishes temp files created concurrently by this process.
+var tempFileCounter atomic.Uint64
+
+// writeFileAtomic replaces path on fsys with data by writing a temp file in the same directory,
+// syncing it, and renaming it into place. A crash or full disk mid-write leaves the original untouched, and
+// concurrent readers see either the old content or the new, never a partial file.
+//
+// The temp file is created with perm so the umask applies as it would for a direct write; when
+// exact is set, perm is then applied verbatim (used to carry over an existing file's mode).
+func writeFileAtomic(fsys vfs.FS, path string, data []byte, perm os.FileMode, exact bool) (err error) {
+	dir, base := filepath.Split(path)
+	var tmp vfs.File
+	for {
+		name := filepath.Join(dir, "."+base+".tmp-"+strconv.Itoa(os.Getpid())+"-"+strconv.FormatUint(tempFileCounter.Add(1), 10))
+		f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("Cannot write file: %s", err)
+		}
+		tmp = f
+		break
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			fsys.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("Cannot write file: %s", err)
+	}
+	if exact {
+		if err := tmp.Chmod(perm); err != nil {
+			return fmt.Errorf("Cannot set file mode: %s", err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("Cannot write file: %s", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Cannot write file: %s", err)
+	}
+	if err := fsys.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("Cannot write file: %s", err)
+	}
+
+	// Persist the rename itself. Not every platform supports syncing a directory, so this is best
+	// effort; the file content is already durable.
+	if d, err := fsys.Open(filepath.Clean(dir)); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// createFileExclusive writes data to path on fsys only if nothing exists there yet, failing with
+// an error wrapping os.ErrExist otherwise, even when the file appears between a caller's check and
+// this call. The file is removed again if writing it fails, so it is never left partial.
+func createFileExclusive(fsys vfs.FS, path string, data []byte, perm os.FileMode, exact bool) (err error) {
+	f, err := fsys.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return err
+		}
+		return fmt.Errorf("Cannot write file: %s", err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			fsys.Remove(path)
+		}
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("Cannot write file: %s", err)
+	}
+	if exact {
+		if err := f.Chmod(perm); err != nil {
+			return fmt.Errorf("Cannot set file mode: %s", err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("Cannot write file: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("Cannot write file: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Run("replaces content without leaving temp files", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "file.txt")
+		require.NoError(t, os.WriteFile(path, []byte("old"), 0o644))
+		require.NoError(t, writeFileAtomic(vfs.OS{}, path, []byte("new"), 0o644, true))
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(content))
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("failed rename leaves original and cleans up", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "target")
+		require.NoError(t, os.Mkdir(path, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(path, "child"), []byte("x"), 0o644))
+		assert.Error(t, writeFileAtomic(vfs.OS{}, path, []byte("new"), 0o644, false))
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+}
+
+func TestWrite_ThroughSymlink(t *testing.T) {
+	state := NewState()
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	link := filepath.Join(dir, "link.txt")
+	require.NoError(t, os.WriteFile(target, []byte("old"), 0o644))
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip("symlinks not supported")
+	}
+	require.NoError(t, state.writeFile(link, []byte("new"), 0))
+	info, err := os.Lstat(link)
+	require.NoError(t, err)
+	assert.True(t, info.Mode()&os.ModeSymlink != 0, "link should remain a symlink")
+	content, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+}
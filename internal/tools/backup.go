@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ConfigureBackups sets whether Write, Edit, and delete_file back up files by default, and where
+// backups are kept. An empty dir stores each backup next to its file as <file>.bak.
+func (s *State) ConfigureBackups(enabled bool, dir string) error {
+	if dir != "" {
+		resolved, err := resolvePath(dir)
+		if err != nil {
+			return fmt.Errorf("backup directory must be absolute, not relative")
+		}
+		dir = resolved
+	}
+	s.Mu.Lock()
+	s.BackupByDefault = enabled
+	s.BackupDir = dir
+	s.Mu.Unlock()
+	return nil
+}
+
+// useBackup resolves a per-call backup option against the server-wide default.
+func (s *State) useBackup(requested *bool) bool {
+	if requested != nil {
+		return *requested
+	}
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.BackupByDefault
+}
+
+// backupPath returns where the backup of resolved is kept. With a backup directory configured, the
+// file's absolute path is mirrored beneath it so backups of same-named files cannot collide.
+func (s *State) backupPath(resolved string) string {
+	s.Mu.RLock()
+	dir := s.BackupDir
+	s.Mu.RUnlock()
+	if dir == "" {
+		return resolved + ".bak"
+	}
+	return filepath.Join(dir, strings.ReplaceAll(resolved, ":", ""))
+}
+
+// backupFile saves the current state of resolved, replacing any earlier backup, and returns the
+// backup location. Nothing is saved when resolved does not exist yet. Write and Edit set
+// followLinks so the backup holds the content they are about to change rather than the symlink.
+// Backups are kept on disk, so they cannot be taken of an in-memory filesystem.
+func (s *State) backupFile(ctx context.Context, resolved string, followLinks bool) (string, error) {
+	if !s.onDisk() {
+		return "", errNeedsDisk("Backup")
+	}
+	src := resolved
+	if followLinks {
+		if real, err := filepath.EvalSymlinks(resolved); err == nil {
+			src = real
+		}
+	}
+	if _, err := os.Lstat(src); err != nil {
+		return "", nil
+	}
+
+	backup := s.backupPath(resolved)
+	if err := os.RemoveAll(backup); err != nil {
+		return "", fmt.Errorf("Cannot replace previous backup: %s", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(backup), 0o750); err != nil {
+		return "", fmt.Errorf("Cannot create backup directory: %s", err)
+	}
+	if err := copyTree(ctx, src, backup); err != nil {
+		_ = os.RemoveAll(backup)
+		return "", fmt.Errorf("Cannot back up file: %s", err)
+	}
+	s.Mu.Lock()
+	s.Backups[resolved] = backup
+	s.Mu.Unlock()
+	return backup, nil
+}
+
+func (s *State) executeRestoreBackup(ctx context.Context, filePath string) (string, error) {
+	if err := s.checkOverlay("restore_backup"); err != nil {
+		return "", err
+	}
+	resolved, err := s.resolvePath(filePath)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+	defer s.lockPaths(ctx, resolved)()
+	// Only backups this server took are restored: with the default <file>.bak naming, a .bak file
+	// the user made for themselves would otherwise be copied over the original.
+	s.Mu.RLock()
+	backup, ok := s.Backups[resolved]
+	s.Mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("no backup found for %s", resolved)
+	}
+	info, err := os.Lstat(backup)
+	if err != nil {
+		return "", fmt.Errorf("no backup found for %s", resolved)
+	}
+
+	var change *FileChange
+	if info.Mode().IsRegular() {
+		if current, err := os.Stat(resolved); err == nil && current.IsDir() {
+			return "", fmt.Errorf("cannot restore a file backup over a directory: %s", resolved)
+		}
+		// A symlink is written through, so its target must be allowed too.
+		target := resolved
+		if real, err := filepath.EvalSymlinks(resolved); err == nil {
+			target = real
+		}
+		if err := s.checkPathAllowed(target); err != nil {
+			return "", err
+		}
+		if err := s.validateFileForWrite(resolved); err != nil {
+			return "", err
+		}
+		if err := s.confirmWrite(ctx, "restore_backup", resolved); err != nil {
+			return "", err
+		}
+		data, err := os.ReadFile(backup)
+		if err != nil {
+			return "", fmt.Errorf("Cannot read backup: %s", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(resolved), 0o750); err != nil {
+			return "", fmt.Errorf("Cannot create parent directory: %s", err)
+		}
+		change = s.snapshotChange(resolved)
+		if err := writeFileAtomic(vfs.OS{}, target, data, info.Mode().Perm(), true); err != nil {
+			return "", err
+		}
+	} else {
+		// Directories and symlinks are copied back only into an empty slot, never merged.
+		if _, err := os.Lstat(resolved); err == nil {
+			return "", fmt.Errorf("destination already exists: %s. Delete it first to restore this backup", resolved)
+		}
+		if err := s.confirmWrite(ctx, "restore_backup", resolved); err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(filepath.Dir(resolved), 0o750); err != nil {
+			return "", fmt.Errorf("Cannot create parent directory: %s", err)
+		}
+		change = s.snapshotChange(resolved)
+		if err := copyTree(ctx, backup, resolved); err != nil {
+			return "", err
+		}
+	}
+	s.recordChange(ctx, "restore_backup", change)
+
+	// The restored content was not what the client last read, so require a fresh read before any
+	// further edits.
+	s.forgetPath(resolved)
+
+	return fmt.Sprintf("Restored %s from backup %s", resolved, backup), nil
+}
+
+var RestoreBackupTool = sdk.Tool{
+	Name:        "restore_backup",
+	Description: "Restores a file or directory from the backup saved by a previous write, edit, or delete_file call made with backup enabled.\n\nUsage:\n- The file_path must be the absolute path of the original file, not of the backup.\n- Only the most recent backup of each path is kept.\n- A file backup overwrites the current file. Directory backups are only restored when nothing exists at the path.\n- The backup itself is kept, so a restore can be repeated.\n- Only backups taken since the server started can be restored; .bak files made any other way are ignored.\n- An existing file must have been read first, as with write.\n- Read the file again before editing it after a restore.",
+}
+
+type RestoreBackupInput struct {
+	FilePath string `json:"file_path" jsonschema:"The absolute path of the file or directory to restore"`
+}
+type RestoreBackupOutput struct {
+	Message string `json:"message"`
+}
+
+func RestoreBackup(ctx context.Context, req *sdk.CallToolRequest, args RestoreBackupInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeRestoreBackup(withSession(ctx, req), args.FilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &RestoreBackupOutput{Message: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
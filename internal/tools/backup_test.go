@@ -0,0 +1,171 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestBackup_WriteAndRestore(t *testing.T) {
+	state := NewState()
+	path := filepath.Join(t.TempDir(), "file.txt")
+	require.NoError(t, os.WriteFile(path, []byte("original"), 0o644))
+	_, err := state.executeRead(context.Background(), path, 0, 0)
+	require.NoError(t, err)
+
+	result, err := callWrite(t, state, WriteInput{FilePath: path, Content: "changed", Backup: boolPtr(true)})
+	require.NoError(t, err)
+	assert.Contains(t, result, path+".bak")
+	backup, err := os.ReadFile(path + ".bak")
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(backup))
+
+	result, err = state.executeRestoreBackup(context.Background(), path)
+	require.NoError(t, err)
+	assert.Contains(t, result, "Restored")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(content))
+
+	// A restore invalidates the earlier read.
+	_, err = callEdit(t, state, EditInput{FilePath: path, OldString: "original", NewString: "x"})
+	assert.Error(t, err)
+}
+
+func TestBackup_EditWithBackupDir(t *testing.T) {
+	backupDir := t.TempDir()
+	state, path := setupFileForEdit(t, "hello world")
+	require.NoError(t, state.ConfigureBackups(true, backupDir))
+
+	_, err := callEdit(t, state, EditInput{FilePath: path, OldString: "world", NewString: "there"})
+	require.NoError(t, err)
+	backup, err := os.ReadFile(filepath.Join(backupDir, path))
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(backup))
+	assert.NoFileExists(t, path+".bak")
+}
+
+func TestBackup_DefaultOff(t *testing.T) {
+	state, path := setupFileForEdit(t, "hello world")
+	_, err := callEdit(t, state, EditInput{FilePath: path, OldString: "world", NewString: "there"})
+	require.NoError(t, err)
+	assert.NoFileExists(t, path+".bak")
+}
+
+func TestBackup_DeleteAndRestoreDirectory(t *testing.T) {
+	state := NewState()
+	dir := filepath.Join(t.TempDir(), "project")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "a.txt"), []byte("a"), 0o644))
+
+	_, err := state.executeDeleteFile(context.Background(), dir, true, true)
+	require.NoError(t, err)
+	assert.NoDirExists(t, dir)
+
+	_, err = state.executeRestoreBackup(context.Background(), dir)
+	require.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(dir, "sub", "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(content))
+
+	// Directory backups never merge into an existing path.
+	_, err = state.executeRestoreBackup(context.Background(), dir)
+	assert.Error(t, err)
+}
+
+func TestBackup_Errors(t *testing.T) {
+	state := NewState()
+
+	t.Run("no backup", func(t *testing.T) {
+		_, err := state.executeRestoreBackup(context.Background(), filepath.Join(t.TempDir(), "missing.txt"))
+		assert.Error(t, err)
+	})
+
+	t.Run("relative backup dir", func(t *testing.T) {
+		assert.Error(t, state.ConfigureBackups(true, "backups"))
+	})
+
+	t.Run("MCP integration", func(t *testing.T) {
+		_, _, err := RestoreBackup(context.Background(), &sdk.CallToolRequest{}, RestoreBackupInput{FilePath: "relative.txt"})
+		assert.Error(t, err)
+	})
+}
+
+func TestBackup_RestoreChecks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ignores backups the server did not take", func(t *testing.T) {
+		state := NewState()
+		path := filepath.Join(t.TempDir(), "notes.txt")
+		require.NoError(t, os.WriteFile(path, []byte("current"), 0o644))
+		require.NoError(t, os.WriteFile(path+".bak", []byte("the user's own copy"), 0o644))
+
+		_, err := state.executeRestoreBackup(ctx, path)
+		assert.ErrorContains(t, err, "no backup found")
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "current", string(content))
+	})
+
+	// backedUp writes "changed" over "original" with a backup and returns the file's path.
+	backedUp := func(t *testing.T, state *State) string {
+		path := filepath.Join(t.TempDir(), "file.txt")
+		require.NoError(t, os.WriteFile(path, []byte("original"), 0o644))
+		_, err := state.executeRead(ctx, path, 0, 0)
+		require.NoError(t, err)
+		_, err = callWrite(t, state, WriteInput{FilePath: path, Content: "changed", Backup: boolPtr(true)})
+		require.NoError(t, err)
+		return path
+	}
+
+	t.Run("requires a fresh read of the current file", func(t *testing.T) {
+		state := NewState()
+		path := backedUp(t, state)
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.WriteFile(path, []byte("edited elsewhere"), 0o644))
+		require.NoError(t, os.Chtimes(path, later, later))
+
+		_, err := state.executeRestoreBackup(ctx, path)
+		assert.ErrorContains(t, err, "modified since last read")
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "edited elsewhere", string(content))
+	})
+
+	t.Run("asks before restoring outside the project", func(t *testing.T) {
+		state := NewState()
+		path := backedUp(t, state)
+		state.ConfirmOutside = t.TempDir()
+
+		_, err := state.executeRestoreBackup(ctx, path)
+		assert.ErrorContains(t, err, "requires user confirmation")
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "changed", string(content))
+		assert.NotContains(t, state.Changes[defaultSessionID][path].Tools, "restore_backup", "a refused restore is not a change")
+	})
+
+	t.Run("refuses a symlink to a denied path", func(t *testing.T) {
+		state := NewState()
+		path := backedUp(t, state)
+		secret := filepath.Join(t.TempDir(), "secret.key")
+		require.NoError(t, os.WriteFile(secret, []byte("key"), 0o600))
+		require.NoError(t, state.SetDeniedPaths([]string{secret}))
+		require.NoError(t, os.Remove(path))
+		require.NoError(t, os.Symlink(secret, path))
+
+		_, err := state.executeRestoreBackup(ctx, path)
+		assert.ErrorContains(t, err, "Access denied by policy")
+		content, err := os.ReadFile(secret)
+		require.NoError(t, err)
+		assert.Equal(t, "key", string(content))
+	})
+}
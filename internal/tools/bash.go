@@ -3,6 +3,7 @@ package tools
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -24,6 +25,9 @@ const (
 	maxTimeout = 600000
 )
 
+// errCommandTimeout is returned when a foreground command runs past its timeout.
+var errCommandTimeout = errors.New("Command timed out. Consider increasing the timeout parameter or running in background.")
+
 // BackgroundShell represents a long-running command executing asynchronously.
 // LastStdoutReadAt and LastStderrReadAt track byte positions to support
 // fetching only new output on subsequent reads, avoiding re-transmission
@@ -36,124 +40,309 @@ type BackgroundShell struct {
 	Stdout           *SyncBuffer
 	Stderr           *SyncBuffer
 	StartTime        time.Time
+	EndTime          time.Time
 	Done             chan struct{}
 	Err              error
 	ExitCode         int
 	LastStdoutReadAt int
 	LastStderrReadAt int
+
+	// Label is an optional name for the shell, and After lists the IDs of the shells that must
+	// complete successfully before it starts.
+	Label string
+	After []string
+	// SkipReason explains why the shell never ran, when a dependency failed or it was killed while
+	// waiting. Guarded by State.Mu.
+	SkipReason string
+	// StdoutFile and StderrFile are where the output is spooled, when it is kept on disk.
+	StdoutFile string
+	StderrFile string
+	// abort is set while the shell waits for its dependencies or a slot, and closed to cancel it;
+	// queued marks the wait for a slot. Both are guarded by State.Mu.
+	abort  chan struct{}
+	queued bool
 }
 
-func (s *State) executeBashCommand(ctx context.Context, command, description string, timeout int64, runInBackground bool) (string, error) {
+func (s *State) executeBashCommand(ctx context.Context, command, description string, timeout int64, runInBackground bool, opts bashOptions) (*BashResult, error) {
 	if command == "" {
-		return "", fmt.Errorf("Command cannot be empty.")
+		return nil, fmt.Errorf("Command cannot be empty.")
 	}
 
 	timeoutMs := defaultTimeout
 	if timeout > 0 {
 		if timeout > maxTimeout {
-			return "", fmt.Errorf("Timeout cannot exceed %d milliseconds (10 minutes).", maxTimeout)
+			return nil, fmt.Errorf("Timeout cannot exceed %d milliseconds (10 minutes).", maxTimeout)
 		}
 		timeoutMs = int(timeout)
 	}
+	shellArgs, err := s.shellArgs(opts, command)
+	if err != nil {
+		return nil, err
+	}
+	if (opts.Label != "" || len(opts.After) > 0 || opts.QueueIfBusy) && !runInBackground {
+		return nil, fmt.Errorf("label, after, and queue_if_busy require run_in_background.")
+	}
+	var expectSteps []compiledExpectStep
+	if len(opts.Expect) > 0 {
+		if runInBackground {
+			return nil, fmt.Errorf("expect cannot be combined with run_in_background.")
+		}
+		if expectSteps, err = compileExpectSteps(opts.Expect); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Retries < 0 || opts.Retries > maxRetries {
+		return nil, fmt.Errorf("retries must be between 0 and %d.", maxRetries)
+	}
+	if opts.RetryBackoffMs < 0 || opts.RetryBackoffMs > maxRetryBackoff {
+		return nil, fmt.Errorf("retry_backoff_ms must be between 0 and %d.", maxRetryBackoff)
+	}
+	if opts.Retries > 0 && runInBackground {
+		return nil, fmt.Errorf("retries cannot be combined with run_in_background.")
+	}
+	if opts.SaveOutput && runInBackground {
+		return nil, fmt.Errorf("save_output cannot be combined with run_in_background; use spool_output to keep background output on disk.")
+	}
+	if err := s.confirmCommand(ctx, command); err != nil {
+		return nil, err
+	}
 
 	// Background commands don't use context timeout because they run asynchronously
 	// and their output is retrieved later via BashOutput. Foreground commands use
 	// context timeout to enforce synchronous execution limits.
-	var cmd *exec.Cmd
 	if runInBackground {
-		cmd = exec.Command("bash", "-c", command)
-	} else {
-		cmdCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
-		defer cancel()
-		cmd = exec.CommandContext(cmdCtx, "bash", "-c", command)
+		cmd := exec.Command(shellArgs[0], shellArgs[1:]...)
+		cmd.Dir = s.workDir(ctx)
+		message, err := s.executeBackground(ctx, cmd, command, description, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &BashResult{Result: message}, nil
 	}
-
-	if wd, err := os.Getwd(); err == nil {
-		cmd.Dir = wd
+	run := func() (*BashResult, error) {
+		return s.runForeground(ctx, shellArgs, command, timeoutMs, expectSteps, opts.SaveOutput)
+	}
+	if opts.Retries > 0 {
+		return s.executeWithRetries(ctx, run, opts.Retries, opts.RetryBackoffMs)
 	}
+	return run()
+}
 
-	if runInBackground {
-		return s.executeBackground(cmd, command, description)
+// runForeground runs one foreground attempt of a command, with the timeout applying to it alone.
+func (s *State) runForeground(ctx context.Context, shellArgs []string, command string, timeoutMs int, expectSteps []compiledExpectStep, saveOutput bool) (*BashResult, error) {
+	cmdCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+	defer cancel()
+	cmd := exec.CommandContext(cmdCtx, shellArgs[0], shellArgs[1:]...)
+	cmd.Dir = s.workDir(ctx)
+
+	if err := s.acquireCommandSlot(); err != nil {
+		return nil, err
+	}
+	defer s.releaseCommandSlot()
+	if expectSteps != nil {
+		return s.executeExpect(ctx, cmd, command, expectSteps, saveOutput)
 	}
-	return s.executeForeground(ctx, cmd, command)
+	return s.executeForeground(ctx, cmd, command, saveOutput)
 }
 
-func (s *State) executeForeground(ctx context.Context, cmd *exec.Cmd, command string) (string, error) {
-	output, err := cmd.CombinedOutput()
+// executeForeground runs cmd to completion, capturing stdout and stderr separately while also
+// recording them interleaved in the order they were produced. A non-zero exit is reported through
+// the returned BashResult rather than as an error so clients can branch on the exit code; errors
+// are reserved for timeouts and failures to run the command at all.
+func (s *State) executeForeground(ctx context.Context, cmd *exec.Cmd, command string, saveOutput bool) (*BashResult, error) {
+	// exec copies stdout and stderr on separate goroutines, so the shared combined buffer must be
+	// synchronized while the per-stream buffers are each written by a single goroutine.
+	var stdout, stderr bytes.Buffer
+	combined := &SyncBuffer{}
+	cmd.Stdout = io.MultiWriter(&stdout, combined)
+	cmd.Stderr = io.MultiWriter(&stderr, combined)
+
+	start := time.Now()
+	err := cmd.Run()
+	duration := time.Since(start)
+
+	exitCode := 0
 	if err != nil {
 		if strings.Contains(err.Error(), "context deadline exceeded") {
-			return "", fmt.Errorf("Command timed out. Consider increasing the timeout parameter or running in background.")
+			return nil, errCommandTimeout
 		}
 
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitCode := exitErr.ExitCode()
-			// On Unix/Linux, a killed process (e.g., by timeout signal) returns exit code -1
-			// rather than the actual signal number. Detect this to provide clearer error messaging.
-			if exitCode == -1 && strings.Contains(err.Error(), "signal: killed") {
-				return "", fmt.Errorf("Command timed out. Consider increasing the timeout parameter or running in background.")
-			}
-
-			return "", fmt.Errorf(
-				"Command exited with code %d:\n%s\n\nCommand: %s",
-				exitCode,
-				string(output),
-				command,
-			)
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return nil, fmt.Errorf("Failed to execute command: %s\n\nCommand: %s", err, command)
 		}
+		exitCode = exitErr.ExitCode()
+		// On Unix/Linux, a killed process (e.g., by timeout signal) returns exit code -1
+		// rather than the actual signal number. Detect this to provide clearer error messaging.
+		if exitCode == -1 && strings.Contains(err.Error(), "signal: killed") {
+			return nil, errCommandTimeout
+		}
+	}
 
-		return "", fmt.Errorf("Failed to execute command: %s\n\nCommand: %s", err, command)
+	result := &BashResult{
+		ExitCode:   exitCode,
+		DurationMs: duration.Milliseconds(),
+		Usage:      resourceUsage(cmd.ProcessState, duration),
+	}
+	if err := s.setBashOutput(ctx, result, combined.String(), stdout.String(), stderr.String(), saveOutput); err != nil {
+		return nil, err
 	}
+	return result, nil
+}
 
-	result := string(output)
-	if err := checkOutputSize(ctx, result, "bash"); err != nil {
-		return "", err
+// setBashOutput fills in a finished command's output. Output over the size limit keeps its head
+// and tail, and the result records the full sizes, since partial output is more useful than an
+// error. Saved output is written to an artifact file and only previewed.
+func (s *State) setBashOutput(ctx context.Context, r *BashResult, combined, stdout, stderr string, save bool) error {
+	limit := limitsFromContext(ctx).maxOutputSize
+	if save {
+		path, err := s.saveArtifact(combined)
+		if err != nil {
+			return err
+		}
+		r.OutputFile = path
+		r.OutputBytes = len(combined)
+		limit = min(limit, artifactPreviewSize)
 	}
+	if len(combined) > limit {
+		r.Truncated = true
+		r.OutputBytes = len(combined)
+		r.StdoutBytes = len(stdout)
+		r.StderrBytes = len(stderr)
+		stdoutMax, stderrMax := splitBudget(len(stdout), len(stderr), limit)
+		combined = truncateMiddle(combined, limit)
+		stdout = truncateMiddle(stdout, stdoutMax)
+		stderr = truncateMiddle(stderr, stderrMax)
+	}
+	r.Result = combined
+	if r.ExitCode != 0 {
+		r.Result = fmt.Sprintf("Command exited with code %d:\n%s", r.ExitCode, combined)
+	}
+	switch {
+	case r.OutputFile != "":
+		r.Result = fmt.Sprintf("%s\n(Full output, %d bytes, saved to %s. Use Read or Grep on it for details.)", r.Result, r.OutputBytes, r.OutputFile)
+	case r.Truncated:
+		r.Result = fmt.Sprintf("%s\n(Output was %d bytes and has been truncated to its start and end. Set save_output, or run in background and page through it with bash_output, to see all of it.)", r.Result, r.OutputBytes)
+	}
+	r.Stdout, r.Stderr = stdout, stderr
+	return nil
+}
 
-	return result, nil
+func (s *State) executeBackground(ctx context.Context, cmd *exec.Cmd, command, description string, opts bashOptions) (string, error) {
+	shell := newBackgroundShell(cmd, command, description, opts.Label)
+	session := sessionFromContext(ctx)
+
+	spool := s.useSpool(opts.Spool)
+
+	var message string
+	queue := len(opts.After) > 0
+	if !queue {
+		err := s.launchShell(shell)
+		var busy *busyError
+		switch {
+		case opts.QueueIfBusy && errors.As(err, &busy):
+			queue = true
+		case err != nil:
+			return "", err
+		default:
+			s.Mu.Lock()
+			s.addShell(shell)
+			s.Mu.Unlock()
+			s.monitorShell(shell, session)
+			message = fmt.Sprintf("Command running in background with ID: %s", shell.ID)
+		}
+	}
+	if queue {
+		var err error
+		if message, err = s.queueShell(shell, opts.After, session); err != nil {
+			return "", err
+		}
+	}
+
+	if spool {
+		// Spooling starts once the shell has its ID, which names the files; anything written
+		// before then is copied over.
+		if err := s.spoolShell(shell); err != nil {
+			return message + "\n" + err.Error() + "; output is kept in memory instead.", nil
+		}
+		message += fmt.Sprintf("\nOutput is spooled to %s and %s", shell.StdoutFile, shell.StderrFile)
+	}
+	return message, nil
 }
 
-func (s *State) executeBackground(cmd *exec.Cmd, command, description string) (string, error) {
+// newBackgroundShell prepares a shell record for cmd, capturing its output.
+func newBackgroundShell(cmd *exec.Cmd, command, description, label string) *BackgroundShell {
 	// SyncBuffer is needed because both the subprocess and the BashOutput
 	// goroutine will read from stdout/stderr concurrently
 	stdout := &SyncBuffer{}
 	stderr := &SyncBuffer{}
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
-
-	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("Failed to start background command: %s", err)
-	}
-
-	s.Mu.Lock()
-	shellID := fmt.Sprintf("shell_%d", s.NextShellID)
-	s.NextShellID++
-	shell := &BackgroundShell{
-		ID:          shellID,
+	return &BackgroundShell{
 		Command:     command,
 		Description: description,
+		Label:       label,
 		Cmd:         cmd,
 		Stdout:      stdout,
 		Stderr:      stderr,
-		StartTime:   time.Now(),
 		Done:        make(chan struct{}),
 	}
-	s.BackgroundShells[shellID] = shell
+}
+
+// addShell assigns the shell the next ID and records it. Callers must hold s.Mu.
+func (s *State) addShell(shell *BackgroundShell) {
+	shell.ID = fmt.Sprintf("shell_%d", s.NextShellID)
+	s.NextShellID++
+	s.BackgroundShells[shell.ID] = shell
+}
+
+// launchShell starts the shell's process in one of the background shell slots.
+func (s *State) launchShell(shell *BackgroundShell) error {
+	if err := s.acquireShellSlot(); err != nil {
+		return err
+	}
+	return s.startShell(shell)
+}
+
+// startShell starts the process of a shell that holds a slot, releasing the slot if it fails.
+func (s *State) startShell(shell *BackgroundShell) error {
+	start := time.Now()
+	if err := shell.Cmd.Start(); err != nil {
+		s.releaseShellSlot()
+		return fmt.Errorf("Failed to start background command: %s", err)
+	}
+	s.Mu.Lock()
+	shell.StartTime = start
 	s.Mu.Unlock()
+	return nil
+}
 
+// monitorShell waits for a launched shell's process to exit, recording its exit code and
+// notifying the session that started it.
+func (s *State) monitorShell(shell *BackgroundShell, session *sdk.ServerSession) {
 	// Monitor process completion in a separate goroutine to avoid blocking
 	// and to capture exit code/error for later retrieval
+	cmd := shell.Cmd
 	go func() {
 		err := cmd.Wait()
+		s.releaseShellSlot()
 		s.Mu.Lock()
-		defer s.Mu.Unlock()
 		shell.Err = err
+		shell.EndTime = time.Now()
 		if cmd.ProcessState != nil {
 			shell.ExitCode = cmd.ProcessState.ExitCode()
 		}
 		close(shell.Done)
-	}()
+		s.Mu.Unlock()
+		_ = shell.Stdout.Close()
+		_ = shell.Stderr.Close()
 
-	return fmt.Sprintf("Command running in background with ID: %s", shellID), nil
+		status := "completed"
+		if shell.ExitCode != 0 {
+			status = "failed"
+		}
+		notifyShellDone(session, shell, status, shell.EndTime.Sub(shell.StartTime))
+	}()
 }
 
 // SyncBuffer wraps bytes.Buffer with a mutex to allow safe concurrent reads
@@ -163,18 +352,89 @@ func (s *State) executeBackground(cmd *exec.Cmd, command, description string) (s
 type SyncBuffer struct {
 	mu  sync.Mutex
 	buf bytes.Buffer
+	// changed is closed and cleared on the next Write, waking every caller blocked in
+	// a receive from Changed(). It is lazily created so idle buffers carry no channel.
+	changed chan struct{}
+
+	// spool, once set by SpoolTo, is the file output is kept in instead of buf. spoolSize counts
+	// the bytes written to it, and spoolFile is open for writing until Close. closed records a
+	// Close that came first, so a later SpoolTo does not leave its file open.
+	spool     string
+	spoolFile *os.File
+	spoolSize int
+	closed    bool
 }
 
 func (sb *SyncBuffer) Write(p []byte) (n int, err error) {
 	sb.mu.Lock()
 	defer sb.mu.Unlock()
-	return sb.buf.Write(p)
+	if sb.spool != "" {
+		if sb.spoolFile == nil {
+			return 0, os.ErrClosed
+		}
+		n, err = sb.spoolFile.Write(p)
+		sb.spoolSize += n
+	} else {
+		n, err = sb.buf.Write(p)
+	}
+	if n > 0 && sb.changed != nil {
+		close(sb.changed)
+		sb.changed = nil
+	}
+	return n, err
 }
 
 func (sb *SyncBuffer) String() string {
+	return sb.From(0)
+}
+
+// From returns the output written after the first offset bytes.
+func (sb *SyncBuffer) From(offset int) string {
+	return sb.Range(offset, -1)
+}
+
+// Range returns at most n bytes of the output written after the first offset bytes, or all of it
+// when n is negative. Spooled output is read from its file after releasing the buffer's lock, so a
+// large read does not hold up the shell writing more.
+func (sb *SyncBuffer) Range(offset, n int) string {
+	sb.mu.Lock()
+	size := sb.buf.Len()
+	if sb.spool != "" {
+		size = sb.spoolSize
+	}
+	offset = min(offset, size)
+	end := size
+	if n >= 0 {
+		end = offset + min(n, size-offset)
+	}
+	if sb.spool == "" {
+		defer sb.mu.Unlock()
+		return string(sb.buf.Bytes()[offset:end])
+	}
+	path := sb.spool
+	sb.mu.Unlock()
+	return readSpool(path, offset, end)
+}
+
+// Len returns the number of bytes written to the buffer so far.
+func (sb *SyncBuffer) Len() int {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if sb.spool != "" {
+		return sb.spoolSize
+	}
+	return sb.buf.Len()
+}
+
+// Changed returns a channel that is closed the next time data is written to the buffer,
+// allowing callers to block until new output arrives instead of polling.
+func (sb *SyncBuffer) Changed() <-chan struct{} {
 	sb.mu.Lock()
 	defer sb.mu.Unlock()
-	return sb.buf.String()
+	if sb.changed == nil {
+		sb.changed = make(chan struct{})
+	}
+	return sb.changed
 }
 
 var (
@@ -182,31 +442,95 @@ var (
 
 	BashTool = sdk.Tool{
 		Name:        "bash",
-		Description: "Executes a given bash command in a persistent shell session with optional timeout, ensuring proper handling and security measures.\n\nIMPORTANT: This tool is for terminal operations like git, npm, docker, etc. DO NOT use it for file operations (reading, writing, editing, searching, finding files) - use the specialized tools for this instead.\n\nBefore executing the command, please follow these steps:\n\n1. Directory Verification:\n   - If the command will create new directories or files, first use `ls` to verify the parent directory exists and is the correct location\n   - For example, before running \"mkdir foo/bar\", first use `ls foo` to check that \"foo\" exists and is the intended parent directory\n\n2. Command Execution:\n   - Always quote file paths that contain spaces with double quotes (e.g., cd \"path with spaces/file.txt\")\n   - Examples of proper quoting:\n     - cd \"/Users/name/My Documents\" (correct)\n     - cd /Users/name/My Documents (incorrect - will fail)\n     - python \"/path/with spaces/script.py\" (correct)\n     - python /path/with spaces/script.py (incorrect - will fail)\n   - After ensuring proper quoting, execute the command.\n   - Capture the output of the command.\n\nUsage notes:\n  - The command argument is required.\n  - You can specify an optional timeout in milliseconds (up to 600000ms / 10 minutes). If not specified, commands will timeout after 120000ms (2 minutes).\n  - It is very helpful if you write a clear, concise description of what this command does in 5-10 words.\n  - You can use the `run_in_background` parameter to run the command in the background, which allows you to continue working while the command runs. You can monitor the output using the Bash tool as it becomes available. Never use `run_in_background` to run 'sleep' as it will return immediately. You do not need to use '&' at the end of the command when using this parameter.\n  \n  - Avoid using Bash with the `find`, `grep`, `cat`, `head`, `tail`, `sed`, `awk`, or `echo` commands, unless explicitly instructed or when these commands are truly necessary for the task. Instead, always prefer using the dedicated tools for these commands:\n    - File search: Use Glob (NOT find or ls)\n    - Content search: Use Grep (NOT grep or rg)\n    - Read files: Use Read (NOT cat/head/tail)\n    - Edit files: Use Edit (NOT sed/awk)\n    - Write files: Use Write (NOT echo >/cat <<EOF)\n    - Communication: Output text directly (NOT echo/printf)\n  - When issuing multiple commands:\n    - If the commands are independent and can run in parallel, make multiple Bash tool calls in a single message. For example, if you need to run \"git status\" and \"git diff\", send a single message with two Bash tool calls in parallel.\n    - If the commands depend on each other and must run sequentially, use a single Bash call with '&&' to chain them together (e.g., `git add . && git commit -m \"message\" && git push`). For instance, if one operation must complete before another starts (like mkdir before cp, Write before Bash for git operations, or git add before git commit), run these operations sequentially instead.\n    - Use ';' only when you need to run commands sequentially but don't care if earlier commands fail\n    - DO NOT use newlines to separate commands (newlines are ok in quoted strings)\n  - Try to maintain your current working directory throughout the session by using absolute paths and avoiding usage of `cd`. You may use `cd` if the User explicitly requests it.\n    <good-example>\n    pytest /foo/bar/tests\n    </good-example>\n    <bad-example>\n    cd /foo/bar && pytest tests\n    </bad-example>",
+		Description: "Executes a given bash command in a persistent shell session with optional timeout, ensuring proper handling and security measures.\n\nIMPORTANT: This tool is for terminal operations like git, npm, docker, etc. DO NOT use it for file operations (reading, writing, editing, searching, finding files) - use the specialized tools for this instead.\n\nBefore executing the command, please follow these steps:\n\n1. Directory Verification:\n   - If the command will create new directories or files, first use `ls` to verify the parent directory exists and is the correct location\n   - For example, before running \"mkdir foo/bar\", first use `ls foo` to check that \"foo\" exists and is the intended parent directory\n\n2. Command Execution:\n   - Always quote file paths that contain spaces with double quotes (e.g., cd \"path with spaces/file.txt\")\n   - Examples of proper quoting:\n     - cd \"/Users/name/My Documents\" (correct)\n     - cd /Users/name/My Documents (incorrect - will fail)\n     - python \"/path/with spaces/script.py\" (correct)\n     - python /path/with spaces/script.py (incorrect - will fail)\n   - After ensuring proper quoting, execute the command.\n   - Capture the output of the command.\n\nUsage notes:\n  - The command argument is required.\n  - You can specify an optional timeout in milliseconds (up to 600000ms / 10 minutes). If not specified, commands will timeout after 120000ms (2 minutes).\n  - It is very helpful if you write a clear, concise description of what this command does in 5-10 words.\n  - Commands run under bash unless you set `shell` to sh, zsh, fish, pwsh, or python (the command is then Python source, run with python3 -c). Only use another shell when the command depends on its syntax.\n  - If a tool installed through the user's profile (nvm, pyenv, asdf) is missing from PATH, retry with `login` set to true.\n  - To answer interactive prompts (host key confirmations, installers, CLI logins), pass `expect` steps such as [{\"expect_regex\": \"\\\\(yes/no.*\\\\)\\\\?\", \"send\": \"yes\\n\"}]. The command then runs in a terminal; if a prompt never appears, the call fails with the output so far.\n  - You can use the `run_in_background` parameter to run the command in the background, which allows you to continue working while the command runs. You can monitor the output using the Bash tool as it becomes available. When the shell exits, clients that enabled logging receive a notifications/message with the shell ID, exit code, and duration. Never use `run_in_background` to run 'sleep' as it will return immediately. You do not need to use '&' at the end of the command when using this parameter.\n  - To run a pipeline of background commands, give each a `label` and list the steps it depends on in `after` (e.g. build, then test with after: [\"build\"]). A command waits until its dependencies complete successfully and is skipped if any of them fails.\n  \n  - Avoid using Bash with the `find`, `grep`, `cat`, `head`, `tail`, `sed`, `awk`, or `echo` commands, unless explicitly instructed or when these commands are truly necessary for the task. Instead, always prefer using the dedicated tools for these commands:\n    - File search: Use Glob (NOT find or ls)\n    - Content search: Use Grep (NOT grep or rg)\n    - Read files: Use Read (NOT cat/head/tail)\n    - Edit files: Use Edit (NOT sed/awk)\n    - Write files: Use Write (NOT echo >/cat <<EOF)\n    - Communication: Output text directly (NOT echo/printf)\n  - When issuing multiple commands:\n    - If the commands are independent and can run in parallel, make multiple Bash tool calls in a single message. For example, if you need to run \"git status\" and \"git diff\", send a single message with two Bash tool calls in parallel.\n    - If the commands depend on each other and must run sequentially, use a single Bash call with '&&' to chain them together (e.g., `git add . && git commit -m \"message\" && git push`). For instance, if one operation must complete before another starts (like mkdir before cp, Write before Bash for git operations, or git add before git commit), run these operations sequentially instead.\n    - Use ';' only when you need to run commands sequentially but don't care if earlier commands fail\n    - DO NOT use newlines to separate commands (newlines are ok in quoted strings)\n  - Try to maintain your current working directory throughout the session by using absolute paths and avoiding usage of `cd`. You may use `cd` if the User explicitly requests it.\n    <good-example>\n    pytest /foo/bar/tests\n    </good-example>\n    <bad-example>\n    cd /foo/bar && pytest tests\n    </bad-example>",
 	}
 )
 
 type BashInput struct {
-	Command         string `json:"command" jsonschema:"The command to execute"`
-	Description     string `json:"description,omitempty" jsonschema:"Clear, concise description of what this command does in 5-10 words, in active voice. Examples:\nInput: ls\nOutput: List files in current directory\n\nInput: git status\nOutput: Show working tree status\n\nInput: npm install\nOutput: Install package dependencies\n\nInput: mkdir foo\nOutput: Create directory 'foo'"`
-	RunInBackground bool   `json:"run_in_background,omitempty" jsonschema:"Set to true to run this command in the background. Use BashOutput to read the output later."`
-	Timeout         int64  `json:"timeout,omitempty" jsonschema:"Optional timeout in milliseconds (max 600000)"`
+	Command         string       `json:"command" jsonschema:"The command to execute"`
+	Description     string       `json:"description,omitempty" jsonschema:"Clear, concise description of what this command does in 5-10 words, in active voice. Examples:\nInput: ls\nOutput: List files in current directory\n\nInput: git status\nOutput: Show working tree status\n\nInput: npm install\nOutput: Install package dependencies\n\nInput: mkdir foo\nOutput: Create directory 'foo'"`
+	RunInBackground bool         `json:"run_in_background,omitempty" jsonschema:"Set to true to run this command in the background. Use BashOutput to read the output later."`
+	Timeout         int64        `json:"timeout,omitempty" jsonschema:"Optional timeout in milliseconds (max 600000)"`
+	Shell           string       `json:"shell,omitempty" jsonschema:"The shell that runs the command: bash (default), sh, zsh, fish, pwsh, or python. The server may allow only some of them"`
+	Login           *bool        `json:"login,omitempty" jsonschema:"Run as a login shell (bash -lc), loading the user's profile so PATH matches their terminal. Defaults to the server setting"`
+	Label           string       `json:"label,omitempty" jsonschema:"A name for a background shell, which other commands can list in after"`
+	After           []string     `json:"after,omitempty" jsonschema:"IDs or labels of background shells that must complete successfully before this background command starts. If any fails, this command is skipped"`
+	SpoolOutput     *bool        `json:"spool_output,omitempty" jsonschema:"Write a background command's output to files on disk instead of keeping it in memory, for very large output. Defaults to the server setting"`
+	Expect          []ExpectStep `json:"expect,omitempty" jsonschema:"Prompts to answer, in order: each step waits for expect_regex to appear in the output and then types send. The command runs in a terminal, so stdout and stderr are combined"`
+	QueueIfBusy     bool         `json:"queue_if_busy,omitempty" jsonschema:"If the server's limit on running background shells is reached, queue this background command to start when a slot frees up instead of failing"`
+	SaveOutput      bool         `json:"save_output,omitempty" jsonschema:"Save the full output of a foreground command to a file and return its path with a short preview of the start and end, for huge logs to Read or Grep afterwards"`
+	Retries         int          `json:"retries,omitempty" jsonschema:"Run the command again up to this many times (max 10) while it exits non-zero or times out. Only for idempotent commands, such as network fetches"`
+	RetryBackoffMs  int64        `json:"retry_backoff_ms,omitempty" jsonschema:"Milliseconds to wait before the first retry, doubling for each later one (default 1000, max 60000)"`
 }
 
+// BashResult is the structured result of a bash invocation. Result holds the combined, interleaved
+// output used for the text content; for foreground commands the remaining fields expose the exit
+// code, wall-clock duration, resource usage, and the individual streams so clients can act on
+// failures directly.
 type BashResult struct {
-	Result string `json:"result"`
+	Result     string         `json:"result"`
+	ExitCode   int            `json:"exit_code"`
+	DurationMs int64          `json:"duration_ms,omitempty"`
+	Stdout     string         `json:"stdout,omitempty"`
+	Stderr     string         `json:"stderr,omitempty"`
+	Usage      *ResourceUsage `json:"usage,omitempty"`
+	// Truncated reports that the output exceeded the size limit, or the preview of saved output,
+	// and only its start and end are included; OutputBytes, StdoutBytes, and StderrBytes are then
+	// the full sizes. OutputFile is where saved output was written.
+	Truncated   bool   `json:"truncated,omitempty"`
+	OutputBytes int    `json:"output_bytes,omitempty"`
+	StdoutBytes int    `json:"stdout_bytes,omitempty"`
+	StderrBytes int    `json:"stderr_bytes,omitempty"`
+	OutputFile  string `json:"output_file,omitempty"`
+	// Attempts describes the failed attempts that came before this one, when the command was run
+	// with retries.
+	Attempts []BashAttempt `json:"attempts,omitempty"`
+}
+
+// ResourceUsage is what an exited command consumed, including the children it waited for, so
+// clients can spot slow or memory-hungry steps.
+type ResourceUsage struct {
+	WallTimeMs   int64 `json:"wall_time_ms"`
+	UserTimeMs   int64 `json:"user_time_ms"`
+	SystemTimeMs int64 `json:"system_time_ms"`
+	MaxRSSBytes  int64 `json:"max_rss_bytes,omitempty"`
+}
+
+// resourceUsage reads the rusage of an exited process; it returns nil if the process never ran.
+func resourceUsage(state *os.ProcessState, wall time.Duration) *ResourceUsage {
+	if state == nil {
+		return nil
+	}
+	return &ResourceUsage{
+		WallTimeMs:   wall.Milliseconds(),
+		UserTimeMs:   state.UserTime().Milliseconds(),
+		SystemTimeMs: state.SystemTime().Milliseconds(),
+		MaxRSSBytes:  maxRSS(state),
+	}
 }
 
 func Bash(ctx context.Context, req *sdk.CallToolRequest, args BashInput) (*sdk.CallToolResult, any, error) {
 	server := GetState()
-	result, err := server.executeBashCommand(ctx, args.Command, args.Description, args.Timeout, args.RunInBackground)
+	output, err := server.executeBashCommand(withSession(ctx, req), args.Command, args.Description, args.Timeout, args.RunInBackground, bashOptions{
+		Shell:  args.Shell,
+		Login:  args.Login,
+		Expect: args.Expect,
+		Label:  args.Label,
+		After:  args.After,
+		Spool:  args.SpoolOutput,
+
+		QueueIfBusy:    args.QueueIfBusy,
+		SaveOutput:     args.SaveOutput,
+		Retries:        args.Retries,
+		RetryBackoffMs: args.RetryBackoffMs,
+	})
 	if err != nil {
 		return nil, nil, err
 	}
 
-	output := &BashResult{Result: result}
 	return &sdk.CallToolResult{
-		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		Content:           []sdk.Content{&sdk.TextContent{Text: output.Result}},
 		StructuredContent: output,
+		IsError:           output.ExitCode != 0,
 	}, output, nil
 }
@@ -7,22 +7,54 @@ import (
 	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 type bashOutputResult struct {
-	Status    string `json:"status"`
-	ExitCode  int    `json:"exit_code,omitempty"`
-	Stdout    string `json:"stdout,omitempty"`
-	Stderr    string `json:"stderr,omitempty"`
-	Timestamp string `json:"timestamp"`
+	Status    string         `json:"status"`
+	ExitCode  int            `json:"exit_code,omitempty"`
+	Stdout    string         `json:"stdout,omitempty"`
+	Stderr    string         `json:"stderr,omitempty"`
+	Usage     *ResourceUsage `json:"usage,omitempty"`
+	Reason    string         `json:"reason,omitempty"`
+	Timestamp string         `json:"timestamp"`
+	// Truncated reports that more output is available past the next byte offsets than this
+	// response could hold.
+	Truncated bool `json:"truncated,omitempty"`
+	// StdoutNextByte and StderrNextByte are the offsets just past the returned output, from which
+	// a later call can resume even if this response is lost.
+	StdoutNextByte int `json:"stdout_next_byte"`
+	StderrNextByte int `json:"stderr_next_byte"`
 }
 
-func (s *State) executeBashOutput(ctx context.Context, shellID, filter string) (string, error) {
+// bashOutputOptions holds the optional settings of a bash_output call that choose which output
+// it returns.
+type bashOutputOptions struct {
+	// Peek returns output without advancing the shell's read positions.
+	Peek bool
+
+	// StdoutFrom and StderrFrom read a stream from this byte offset rather than from where the
+	// previous call stopped, so earlier output can be fetched again.
+	StdoutFrom *int
+	StderrFrom *int
+
+	// MaxLines and MaxBytes cap the output returned from each stream; zero means no cap.
+	MaxLines int
+	MaxBytes int
+}
+
+func (s *State) executeBashOutput(ctx context.Context, shellID, filter string, waitMs int64, waitForRegex string, opts bashOutputOptions) (string, error) {
 	if shellID == "" {
 		return "", fmt.Errorf("bash_id is required.")
 	}
+	if (opts.StdoutFrom != nil && *opts.StdoutFrom < 0) || (opts.StderrFrom != nil && *opts.StderrFrom < 0) {
+		return "", fmt.Errorf("Byte offsets cannot be negative.")
+	}
+	if opts.MaxLines < 0 || opts.MaxBytes < 0 {
+		return "", fmt.Errorf("max_lines and max_bytes cannot be negative.")
+	}
 
 	// Check shell existence with minimal lock duration before accessing its data.
 	// We release early to avoid holding the lock during stdout/stderr reads on SyncBuffer.
@@ -33,37 +65,58 @@ func (s *State) executeBashOutput(ctx context.Context, shellID, filter string) (
 		return "", fmt.Errorf("Background shell with ID '%s' not found.", shellID)
 	}
 
+	// Reads start where the previous call stopped unless the caller picks an offset.
+	s.Mu.RLock()
+	stdoutFrom, stderrFrom := shell.LastStdoutReadAt, shell.LastStderrReadAt
+	s.Mu.RUnlock()
+	if opts.StdoutFrom != nil {
+		stdoutFrom = *opts.StdoutFrom
+	}
+	if opts.StderrFrom != nil {
+		stderrFrom = *opts.StderrFrom
+	}
+
+	if waitMs > 0 || waitForRegex != "" {
+		if err := s.waitForShellOutput(ctx, shell, waitMs, waitForRegex, stdoutFrom, stderrFrom); err != nil {
+			return "", err
+		}
+	}
+
 	timestamp := time.Now().Format(time.RFC3339Nano)
 
-	// Re-acquire lock for reading and updating the shell's output position markers.
-	// This ensures thread-safe updates to LastStdoutReadAt and LastStderrReadAt.
-	s.Mu.Lock()
-	defer s.Mu.Unlock()
+	// Extract only new output since the last read position, and no more than the response can
+	// hold, so the read positions only move past output the caller actually receives and the rest
+	// is picked up by the next call.
+	stdoutLen, stderrLen := shell.Stdout.Len(), shell.Stderr.Len()
+	stdoutFrom, stderrFrom = min(stdoutFrom, stdoutLen), min(stderrFrom, stderrLen)
+	available := stdoutLen - stdoutFrom + stderrLen - stderrFrom
+	stdoutMax, stderrMax := splitBudget(stdoutLen-stdoutFrom, stderrLen-stderrFrom, limitsFromContext(ctx).maxOutputSize)
+	if opts.MaxBytes > 0 {
+		stdoutMax, stderrMax = min(stdoutMax, opts.MaxBytes), min(stderrMax, opts.MaxBytes)
+	}
+	newStdout := readLimited(shell.Stdout, stdoutFrom, opts.MaxLines, stdoutMax)
+	newStderr := readLimited(shell.Stderr, stderrFrom, opts.MaxLines, stderrMax)
+	truncated := len(newStdout)+len(newStderr) < available
+	stdoutNext, stderrNext := stdoutFrom+len(newStdout), stderrFrom+len(newStderr)
 
-	// Extract only new output since the last read position.
+	// Re-acquire lock for updating the shell's output position markers and reading its status.
 	// These position markers ensure API consumers always see new data since their last call,
-	// preventing duplicate output in streaming scenarios.
-	stdoutContent := shell.Stdout.String()
-	stderrContent := shell.Stderr.String()
-	newStdout := stdoutContent[shell.LastStdoutReadAt:]
-	newStderr := stderrContent[shell.LastStderrReadAt:]
-	shell.LastStdoutReadAt = len(stdoutContent)
-	shell.LastStderrReadAt = len(stderrContent)
+	// preventing duplicate output in streaming scenarios. Peeking leaves them where they are.
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	if !opts.Peek {
+		shell.LastStdoutReadAt = stdoutNext
+		shell.LastStderrReadAt = stderrNext
+	}
 
 	// Determine shell status without blocking on channel receive.
-	// Non-blocking select returns "running" if Done channel is not yet closed.
 	var exitCode int
-	var statusStr string
-	select {
-	case <-shell.Done:
+	var usage *ResourceUsage
+	statusStr := shellStatus(shell)
+	switch statusStr {
+	case "completed", "failed":
 		exitCode = shell.ExitCode
-		if shell.ExitCode != 0 {
-			statusStr = "failed"
-		} else {
-			statusStr = "completed"
-		}
-	default:
-		statusStr = "running"
+		usage = resourceUsage(shell.Cmd.ProcessState, shell.EndTime.Sub(shell.StartTime))
 	}
 
 	// Apply regex filter only to new output if provided.
@@ -81,17 +134,18 @@ func (s *State) executeBashOutput(ctx context.Context, shellID, filter string) (
 		newStderr = filteredStderr
 	}
 
-	// Log output size for debugging; errors are ignored to ensure output is still returned
-	// even if size checks fail. This supports monitoring of potentially excessive output.
-	_ = checkOutputSize(ctx, newStdout, "bash")
-	_ = checkOutputSize(ctx, newStderr, "bash")
-
 	output := bashOutputResult{
 		Status:    statusStr,
 		ExitCode:  exitCode,
 		Stdout:    newStdout,
 		Stderr:    newStderr,
+		Usage:     usage,
+		Reason:    shell.SkipReason,
 		Timestamp: timestamp,
+		Truncated: truncated,
+
+		StdoutNextByte: stdoutNext,
+		StderrNextByte: stderrNext,
 	}
 	jsonBytes, err := json.MarshalIndent(output, "", "  ")
 	if err != nil {
@@ -100,6 +154,115 @@ func (s *State) executeBashOutput(ctx context.Context, shellID, filter string) (
 	return string(jsonBytes), nil
 }
 
+// waitForShellOutput blocks until the shell has output past the given offsets (matching waitForRegex, if given),
+// the shell exits, the wait deadline passes, or ctx is cancelled. Reaching the deadline is not an
+// error: the caller simply returns whatever output is available, mirroring a poll with a timeout.
+func (s *State) waitForShellOutput(ctx context.Context, shell *BackgroundShell, waitMs int64, waitForRegex string, stdoutFrom, stderrFrom int) error {
+	if waitMs <= 0 {
+		waitMs = defaultTimeout
+	}
+	if waitMs > maxTimeout {
+		return fmt.Errorf("wait_ms cannot exceed %d milliseconds (10 minutes).", maxTimeout)
+	}
+
+	var regex *regexp.Regexp
+	if waitForRegex != "" {
+		var err error
+		regex, err = regexp.Compile(waitForRegex)
+		if err != nil {
+			return fmt.Errorf("Invalid wait_for_regex: %s", err)
+		}
+	}
+
+	timer := time.NewTimer(time.Duration(waitMs) * time.Millisecond)
+	defer timer.Stop()
+
+	// The regex is matched against no more output than a response can return.
+	window := limitsFromContext(ctx).maxOutputSize
+	for {
+		// Subscribe to buffer changes before inspecting the content so a write landing between
+		// the check and the select still wakes us up.
+		stdoutChanged := shell.Stdout.Changed()
+		stderrChanged := shell.Stderr.Changed()
+
+		hasOutput := shell.Stdout.Len() > stdoutFrom || shell.Stderr.Len() > stderrFrom
+		if regex == nil && hasOutput {
+			return nil
+		}
+		if regex != nil && hasOutput &&
+			(regex.MatchString(shell.Stdout.Range(stdoutFrom, window)) || regex.MatchString(shell.Stderr.Range(stderrFrom, window))) {
+			return nil
+		}
+
+		select {
+		case <-shell.Done:
+			return nil
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stdoutChanged:
+		case <-stderrChanged:
+		}
+	}
+}
+
+// splitBudget divides a byte budget between stdout and stderr lengths a and b. A stream that needs
+// less than half leaves the rest to the other.
+func splitBudget(a, b, budget int) (int, int) {
+	half := budget / 2
+	switch {
+	case a+b <= budget:
+		return a, b
+	case a <= half:
+		return a, budget - a
+	case b <= half:
+		return budget - b, b
+	}
+	return half, budget - half
+}
+
+// readLimited returns the start of buf's output after offset, cut by limitOutput to maxLines lines
+// and maxBytes bytes, where maxBytes is exact: zero returns nothing. Only the bytes that can be
+// kept are read, which matters for spooled output; the extra byte lets limitOutput see that a cut
+// is needed and end it on a line boundary.
+func readLimited(buf *SyncBuffer, offset, maxLines, maxBytes int) string {
+	if maxBytes <= 0 {
+		return ""
+	}
+	return limitOutput(buf.Range(offset, maxBytes+1), maxLines, maxBytes)
+}
+
+// limitOutput returns the start of output holding at most maxLines lines and maxBytes bytes, where
+// zero means no limit. A byte cut ends on a line boundary unless the first line alone is too long,
+// and never splits a UTF-8 character.
+func limitOutput(output string, maxLines, maxBytes int) string {
+	if maxLines > 0 {
+		end := 0
+		for n := 0; n < maxLines && end < len(output); n++ {
+			i := strings.IndexByte(output[end:], '\n')
+			if i < 0 {
+				end = len(output)
+				break
+			}
+			end += i + 1
+		}
+		output = output[:end]
+	}
+	if maxBytes > 0 && len(output) > maxBytes {
+		cut := maxBytes
+		if i := strings.LastIndexByte(output[:maxBytes], '\n'); i >= 0 {
+			cut = i + 1
+		} else {
+			for cut > 0 && !utf8.RuneStart(output[cut]) {
+				cut--
+			}
+		}
+		output = output[:cut]
+	}
+	return output
+}
+
 func filterOutput(output, pattern string) (string, error) {
 	if pattern == "" {
 		return output, nil
@@ -133,12 +296,19 @@ func filterOutput(output, pattern string) (string, error) {
 
 var BashOutputTool = sdk.Tool{
 	Name:        "bash_output",
-	Description: "- Retrieves output from a running or completed background bash shell\n- Takes a shell_id parameter identifying the shell\n- Always returns only new output since the last check\n- Returns stdout and stderr output along with shell status\n- Supports optional regex filtering to show only lines matching a pattern\n- Use this tool when you need to monitor or check the output of a long-running shell",
+	Description: "- Retrieves output from a running or completed background bash shell\n- Takes a shell_id parameter identifying the shell\n- Returns only new output since the last check, along with stdout_next_byte and stderr_next_byte offsets\n- Set peek to read without consuming, or stdout_from_byte/stderr_from_byte to re-read output from an earlier offset (for example after a lost response)\n- Returns stdout and stderr output along with shell status, and once the shell has exited its wall time, user/system CPU time, and peak memory\n- Supports optional regex filtering to show only lines matching a pattern\n- Use wait_ms (and optionally wait_for_regex) to block until new output, a matching line, or shell exit instead of polling with sleeps\n- Output larger than the response size limit, or than max_lines/max_bytes, is cut at a line boundary and marked truncated; the next call continues from where it stopped\n- Use this tool when you need to monitor or check the output of a long-running shell",
 }
 
 type BashOutputInput struct {
-	ShellID string `json:"shell_id" jsonschema:"The ID of the background shell to retrieve output from"`
-	Filter  string `json:"filter,omitempty" jsonschema:"Optional regular expression to filter the output lines. Only lines matching this regex will be included in the result. Any lines that do not match will no longer be available to read."`
+	ShellID        string `json:"shell_id" jsonschema:"The ID of the background shell to retrieve output from"`
+	Filter         string `json:"filter,omitempty" jsonschema:"Optional regular expression to filter the output lines. Only lines matching this regex will be included in the result. Lines that do not match are skipped by later calls but can still be fetched with stdout_from_byte or stderr_from_byte"`
+	WaitMs         int64  `json:"wait_ms,omitempty" jsonschema:"Optional time in milliseconds to block until new output arrives or the shell exits (max 600000). Returns immediately with whatever is available when the deadline passes"`
+	Peek           bool   `json:"peek,omitempty" jsonschema:"Return the output without marking it as read, so the next call returns it again"`
+	StdoutFromByte *int   `json:"stdout_from_byte,omitempty" jsonschema:"Return stdout starting at this byte offset instead of where the last call stopped, e.g. to re-fetch output from a lost response using its stdout_next_byte, or 0 for all output"`
+	StderrFromByte *int   `json:"stderr_from_byte,omitempty" jsonschema:"Return stderr starting at this byte offset instead of where the last call stopped"`
+	MaxLines       int    `json:"max_lines,omitempty" jsonschema:"Return at most this many lines from each of stdout and stderr. The rest is returned by the next call"`
+	MaxBytes       int    `json:"max_bytes,omitempty" jsonschema:"Return at most this many bytes from each of stdout and stderr. The rest is returned by the next call"`
+	WaitForRegex   string `json:"wait_for_regex,omitempty" jsonschema:"Optional regular expression to wait for. Blocks until unread output matches it, the shell exits, or wait_ms elapses (default 120000)"`
 }
 type BashOutputOutput struct {
 	Output string `json:"output"`
@@ -146,7 +316,13 @@ type BashOutputOutput struct {
 
 func BashOutput(ctx context.Context, req *sdk.CallToolRequest, args BashOutputInput) (*sdk.CallToolResult, any, error) {
 	server := GetState()
-	result, err := server.executeBashOutput(ctx, args.ShellID, args.Filter)
+	result, err := server.executeBashOutput(ctx, args.ShellID, args.Filter, args.WaitMs, args.WaitForRegex, bashOutputOptions{
+		Peek:       args.Peek,
+		StdoutFrom: args.StdoutFromByte,
+		StderrFrom: args.StderrFromByte,
+		MaxLines:   args.MaxLines,
+		MaxBytes:   args.MaxBytes,
+	})
 	if err != nil {
 		return nil, nil, err
 	}
@@ -2,7 +2,9 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -14,7 +16,11 @@ import (
 
 func callBash(t *testing.T, state *State, input BashInput) (string, error) {
 	t.Helper()
-	return state.executeBashCommand(context.Background(), input.Command, input.Description, input.Timeout, input.RunInBackground)
+	result, err := state.executeBashCommand(context.Background(), input.Command, input.Description, input.Timeout, input.RunInBackground, bashOptions{})
+	if err != nil {
+		return "", err
+	}
+	return result.Result, nil
 }
 
 // extractShellID parses the background shell ID from the command output.
@@ -40,11 +46,30 @@ func TestBash_BasicFunctionality(t *testing.T) {
 		assert.Equal(t, "Hello, World!\n", result)
 	})
 	t.Run("command with exit code", func(t *testing.T) {
-		_, err := callBash(t, state, BashInput{
-			Command: "exit 1",
-		})
-		require.Error(t, err)
-		assert.Contains(t, err.Error(), "exited with code 1")
+		result, err := state.executeBashCommand(context.Background(), "echo 'partial' && exit 1", "", 0, false, bashOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.ExitCode)
+		assert.Equal(t, "partial\n", result.Stdout)
+		assert.Contains(t, result.Result, "exited with code 1")
+	})
+	t.Run("separates stdout and stderr", func(t *testing.T) {
+		result, err := state.executeBashCommand(context.Background(), "echo out && echo err >&2", "", 0, false, bashOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.ExitCode)
+		assert.Equal(t, "out\n", result.Stdout)
+		assert.Equal(t, "err\n", result.Stderr)
+		assert.Contains(t, result.Result, "out\n")
+		assert.Contains(t, result.Result, "err\n")
+	})
+	t.Run("reports resource usage", func(t *testing.T) {
+		result, err := state.executeBashCommand(context.Background(), "i=0; while [ $i -lt 20000 ]; do i=$((i+1)); done; sleep 0.1", "", 0, false, bashOptions{})
+		require.NoError(t, err)
+		require.NotNil(t, result.Usage)
+		assert.GreaterOrEqual(t, result.Usage.WallTimeMs, int64(100))
+		assert.Greater(t, result.Usage.UserTimeMs+result.Usage.SystemTimeMs, int64(0))
+		if runtime.GOOS != "windows" {
+			assert.Greater(t, result.Usage.MaxRSSBytes, int64(0))
+		}
 	})
 	t.Run("empty command rejected", func(t *testing.T) {
 		_, err := callBash(t, state, BashInput{
@@ -152,17 +177,26 @@ func TestBashOutput(t *testing.T) {
 		// Sleep to ensure the background goroutine has finished writing output
 		// before we attempt to read it.
 		time.Sleep(200 * time.Millisecond)
-		output, err := state.executeBashOutput(context.Background(), shellID, "")
+		output, err := state.executeBashOutput(context.Background(), shellID, "", 0, "", bashOutputOptions{})
 		require.NoError(t, err)
 		assert.Contains(t, output, "test output")
+
+		// Usage is reported once the shell has exited
+		<-state.BackgroundShells[shellID].Done
+		output, err = state.executeBashOutput(context.Background(), shellID, "", 0, "", bashOutputOptions{})
+		require.NoError(t, err)
+		var parsed bashOutputResult
+		require.NoError(t, json.Unmarshal([]byte(output), &parsed))
+		require.NotNil(t, parsed.Usage)
+		assert.GreaterOrEqual(t, parsed.Usage.WallTimeMs, int64(100))
 	})
 	t.Run("nonexistent shell error", func(t *testing.T) {
-		_, err := state.executeBashOutput(context.Background(), "nonexistent_shell", "")
+		_, err := state.executeBashOutput(context.Background(), "nonexistent_shell", "", 0, "", bashOutputOptions{})
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "not found")
 	})
 	t.Run("empty shell_id error", func(t *testing.T) {
-		_, err := state.executeBashOutput(context.Background(), "", "")
+		_, err := state.executeBashOutput(context.Background(), "", "", 0, "", bashOutputOptions{})
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "bash_id is required")
 	})
@@ -176,12 +210,62 @@ func TestBashOutput(t *testing.T) {
 		// Sleep ensures the shell completes execution before we query its output with filtering.
 		// This tests that the filter regex is properly applied to the captured output.
 		time.Sleep(200 * time.Millisecond)
-		output, err := state.executeBashOutput(context.Background(), shellID, "ERROR:")
+		output, err := state.executeBashOutput(context.Background(), shellID, "ERROR:", 0, "", bashOutputOptions{})
 		require.NoError(t, err)
 		assert.Contains(t, output, "ERROR: something failed")
 		assert.Contains(t, output, "ERROR: another issue")
 		assert.NotContains(t, output, "INFO: all good")
 	})
+	t.Run("wait blocks until output arrives", func(t *testing.T) {
+		result, err := callBash(t, state, BashInput{
+			Command:         "sleep 0.2 && echo 'late output' && sleep 5",
+			RunInBackground: true,
+		})
+		require.NoError(t, err)
+		shellID := extractShellID(result)
+		output, err := state.executeBashOutput(context.Background(), shellID, "", 3000, "", bashOutputOptions{})
+		require.NoError(t, err)
+		assert.Contains(t, output, "late output")
+		assert.Contains(t, output, `"status": "running"`)
+		_, _ = state.executeKillShell(context.Background(), shellID)
+	})
+	t.Run("wait for regex", func(t *testing.T) {
+		result, err := callBash(t, state, BashInput{
+			Command:         "echo 'starting' && sleep 0.2 && echo 'server ready'",
+			RunInBackground: true,
+		})
+		require.NoError(t, err)
+		shellID := extractShellID(result)
+		output, err := state.executeBashOutput(context.Background(), shellID, "", 3000, "ready", bashOutputOptions{})
+		require.NoError(t, err)
+		assert.Contains(t, output, "starting")
+		assert.Contains(t, output, "server ready")
+	})
+	t.Run("wait returns at deadline without output", func(t *testing.T) {
+		result, err := callBash(t, state, BashInput{
+			Command:         "sleep 5",
+			RunInBackground: true,
+		})
+		require.NoError(t, err)
+		shellID := extractShellID(result)
+		start := time.Now()
+		output, err := state.executeBashOutput(context.Background(), shellID, "", 200, "", bashOutputOptions{})
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+		assert.Contains(t, output, `"status": "running"`)
+		_, _ = state.executeKillShell(context.Background(), shellID)
+	})
+	t.Run("invalid wait regex", func(t *testing.T) {
+		result, err := callBash(t, state, BashInput{
+			Command:         "echo 'test'",
+			RunInBackground: true,
+		})
+		require.NoError(t, err)
+		shellID := extractShellID(result)
+		_, err = state.executeBashOutput(context.Background(), shellID, "", 100, "[invalid(regex", bashOutputOptions{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid wait_for_regex")
+	})
 	t.Run("invalid filter regex", func(t *testing.T) {
 		result, err := callBash(t, state, BashInput{
 			Command:         "echo 'test'",
@@ -189,10 +273,98 @@ func TestBashOutput(t *testing.T) {
 		})
 		require.NoError(t, err)
 		shellID := extractShellID(result)
-		_, err = state.executeBashOutput(context.Background(), shellID, "[invalid(regex")
+		_, err = state.executeBashOutput(context.Background(), shellID, "[invalid(regex", 0, "", bashOutputOptions{})
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "Invalid filter regex")
 	})
+	t.Run("peek and re-read from an offset", func(t *testing.T) {
+		result, err := callBash(t, state, BashInput{
+			Command:         "echo INFO: one; echo ERROR: two; echo oops >&2",
+			RunInBackground: true,
+		})
+		require.NoError(t, err)
+		shellID := extractShellID(result)
+		<-state.BackgroundShells[shellID].Done
+
+		read := func(filter string, opts bashOutputOptions) bashOutputResult {
+			t.Helper()
+			output, err := state.executeBashOutput(context.Background(), shellID, filter, 0, "", opts)
+			require.NoError(t, err)
+			var parsed bashOutputResult
+			require.NoError(t, json.Unmarshal([]byte(output), &parsed))
+			return parsed
+		}
+
+		peeked := read("", bashOutputOptions{Peek: true})
+		assert.Equal(t, "INFO: one\nERROR: two\n", peeked.Stdout)
+		assert.Equal(t, 21, peeked.StdoutNextByte)
+		assert.Equal(t, 5, peeked.StderrNextByte)
+
+		// A filtered read consumes everything, but the skipped lines can still be fetched
+		filtered := read("ERROR", bashOutputOptions{})
+		assert.Equal(t, "ERROR: two\n", filtered.Stdout)
+		assert.Empty(t, read("", bashOutputOptions{}).Stdout)
+
+		zero, ten := 0, 10
+		again := read("", bashOutputOptions{StdoutFrom: &zero, Peek: true})
+		assert.Equal(t, "INFO: one\nERROR: two\n", again.Stdout)
+		assert.Empty(t, again.Stderr, "stderr keeps its own position")
+
+		tail := read("", bashOutputOptions{StdoutFrom: &ten})
+		assert.Equal(t, "ERROR: two\n", tail.Stdout)
+
+		past := 1000
+		assert.Equal(t, 21, read("", bashOutputOptions{StdoutFrom: &past}).StdoutNextByte)
+
+		negative := -1
+		_, err = state.executeBashOutput(context.Background(), shellID, "", 0, "", bashOutputOptions{StdoutFrom: &negative})
+		require.Error(t, err)
+	})
+	t.Run("max lines and bytes continue where they stop", func(t *testing.T) {
+		result, err := callBash(t, state, BashInput{
+			Command:         "for i in 1 2 3 4 5; do echo line$i; done",
+			RunInBackground: true,
+		})
+		require.NoError(t, err)
+		shellID := extractShellID(result)
+		<-state.BackgroundShells[shellID].Done
+
+		read := func(opts bashOutputOptions) bashOutputResult {
+			t.Helper()
+			output, err := state.executeBashOutput(context.Background(), shellID, "", 0, "", opts)
+			require.NoError(t, err)
+			var parsed bashOutputResult
+			require.NoError(t, json.Unmarshal([]byte(output), &parsed))
+			return parsed
+		}
+
+		first := read(bashOutputOptions{MaxLines: 2})
+		assert.Equal(t, "line1\nline2\n", first.Stdout)
+		assert.True(t, first.Truncated)
+		assert.Equal(t, 12, first.StdoutNextByte)
+
+		// A byte cap ends on a line boundary
+		second := read(bashOutputOptions{MaxBytes: 10})
+		assert.Equal(t, "line3\n", second.Stdout)
+		assert.True(t, second.Truncated)
+
+		rest := read(bashOutputOptions{})
+		assert.Equal(t, "line4\nline5\n", rest.Stdout)
+		assert.False(t, rest.Truncated)
+
+		// The response size limit applies without any caps
+		ctx := context.WithValue(context.Background(), outputLimitsKey{}, outputLimits{maxOutputSize: 14})
+		zero := 0
+		output, err := state.executeBashOutput(ctx, shellID, "", 0, "", bashOutputOptions{StdoutFrom: &zero})
+		require.NoError(t, err)
+		var limited bashOutputResult
+		require.NoError(t, json.Unmarshal([]byte(output), &limited))
+		assert.Equal(t, "line1\nline2\n", limited.Stdout)
+		assert.True(t, limited.Truncated)
+
+		_, err = state.executeBashOutput(context.Background(), shellID, "", 0, "", bashOutputOptions{MaxLines: -1})
+		require.Error(t, err)
+	})
 }
 
 func TestKillShell(t *testing.T) {
@@ -212,7 +384,7 @@ func TestKillShell(t *testing.T) {
 		assert.Contains(t, killResult, "Successfully killed shell")
 		assert.Contains(t, killResult, shellID)
 		// Verify the shell is removed from tracking after being killed.
-		_, err = state.executeBashOutput(context.Background(), shellID, "")
+		_, err = state.executeBashOutput(context.Background(), shellID, "", 0, "", bashOutputOptions{})
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "not found")
 	})
@@ -249,6 +421,15 @@ func TestBash_MCPIntegration(t *testing.T) {
 		})
 		require.NoError(t, err)
 		assert.NotNil(t, result)
+		assert.False(t, result.IsError)
+	})
+	t.Run("bash tool non-zero exit", func(t *testing.T) {
+		result, output, err := Bash(context.Background(), &sdk.CallToolRequest{}, BashInput{
+			Command: "exit 3",
+		})
+		require.NoError(t, err)
+		assert.True(t, result.IsError)
+		assert.Equal(t, 3, output.(*BashResult).ExitCode)
 	})
 	t.Run("bash output tool", func(t *testing.T) {
 		result, _, err := Bash(context.Background(), &sdk.CallToolRequest{}, BashInput{
@@ -295,6 +476,22 @@ func TestBash_EdgeCases(t *testing.T) {
 		require.NoError(t, err)
 		assert.Contains(t, result, "line")
 	})
+	t.Run("oversized output keeps its start and end", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), outputLimitsKey{}, outputLimits{maxOutputSize: 200})
+		result, err := state.executeBashCommand(ctx, "seq 1 1000; echo done >&2; exit 3", "", 0, false, bashOptions{})
+		require.NoError(t, err)
+		assert.True(t, result.Truncated)
+		assert.Equal(t, 3, result.ExitCode)
+		assert.Equal(t, 3898, result.OutputBytes)
+		assert.Equal(t, 3893, result.StdoutBytes)
+		assert.Equal(t, 5, result.StderrBytes)
+		assert.True(t, strings.HasPrefix(result.Result, "Command exited with code 3:\n1\n2\n"))
+		assert.Contains(t, result.Result, "bytes truncated]")
+		assert.Contains(t, result.Result, "1000\ndone\n")
+		assert.Contains(t, result.Result, "Output was 3898 bytes")
+		assert.LessOrEqual(t, len(result.Stdout), 200)
+		assert.Equal(t, "done\n", result.Stderr)
+	})
 	t.Run("special characters in command", func(t *testing.T) {
 		result, err := callBash(t, state, BashInput{
 			// Bash metacharacters must be properly quoted in single quotes to prevent
@@ -317,3 +514,23 @@ func TestBash_EdgeCases(t *testing.T) {
 		assert.Contains(t, result, "line3")
 	})
 }
+
+func TestLimitOutput(t *testing.T) {
+	assert.Equal(t, "a\nb\n", limitOutput("a\nb\nc\n", 2, 0))
+	assert.Equal(t, "a\nb", limitOutput("a\nb", 5, 0))
+	assert.Equal(t, "a\n", limitOutput("a\nbbbb\n", 0, 4))
+	assert.Equal(t, "abc", limitOutput("abcdef", 0, 3), "a single long line is cut mid-line")
+	assert.Equal(t, "h", limitOutput("hé", 0, 2), "a cut never splits a character")
+	assert.Equal(t, "a\n", limitOutput("a\nb\n", 1, 3))
+}
+
+func TestSplitBudget(t *testing.T) {
+	a, b := splitBudget(3, 4, 10)
+	assert.Equal(t, []int{3, 4}, []int{a, b})
+	a, b = splitBudget(2, 20, 10)
+	assert.Equal(t, []int{2, 8}, []int{a, b})
+	a, b = splitBudget(20, 2, 10)
+	assert.Equal(t, []int{8, 2}, []int{a, b})
+	a, b = splitBudget(20, 20, 10)
+	assert.Equal(t, []int{5, 5}, []int{a, b})
+}
@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// maxRecentCalls bounds the tool calls kept in State.RecentCalls.
+	maxRecentCalls = 200
+	// maxCallErrorLen bounds the error message kept for a failed call.
+	maxCallErrorLen = 300
+)
+
+// CallRecord describes a finished tool call.
+type CallRecord struct {
+	Tool       string    `json:"tool"`
+	SessionID  string    `json:"session_id"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	// QueueMs, ExecMs, and SerializationMs break DurationMs down: time spent waiting for approval,
+	// confirmation, or file locks; running the tool; and the rest, chiefly decoding and validating
+	// its arguments and encoding its result.
+	QueueMs         int64 `json:"queue_ms"`
+	ExecMs          int64 `json:"exec_ms"`
+	SerializationMs int64 `json:"serialization_ms"`
+	// Error is the protocol error or tool error the call returned, if any, shortened to
+	// maxCallErrorLen bytes.
+	Error string `json:"error,omitempty"`
+}
+
+// CallLogMiddleware records each tool call, with its duration and any error, in s.RecentCalls.
+// Arguments and results are not kept there, but calls slower than the slow-call threshold are
+// logged with a summary of their arguments. See SetSlowCallLog.
+func (s *State) CallLogMiddleware(next sdk.MethodHandler) sdk.MethodHandler {
+	return func(ctx context.Context, method string, req sdk.Request) (sdk.Result, error) {
+		call, ok := req.(*sdk.CallToolRequest)
+		if !ok || call.Params == nil {
+			return next(ctx, method, req)
+		}
+		timing := &callTiming{}
+		start := time.Now()
+		result, err := next(context.WithValue(ctx, callTimingKey{}, timing), method, req)
+		duration := time.Since(start)
+		queue, exec := time.Duration(timing.queue.Load()), time.Duration(timing.exec.Load())
+		serialization := duration - queue - exec
+		if serialization < 0 {
+			serialization = 0
+		}
+		record := CallRecord{
+			Tool:            call.Params.Name,
+			SessionID:       sessionID(call),
+			StartedAt:       start,
+			DurationMs:      duration.Milliseconds(),
+			QueueMs:         queue.Milliseconds(),
+			ExecMs:          exec.Milliseconds(),
+			SerializationMs: serialization.Milliseconds(),
+		}
+		if err != nil {
+			record.Error = err.Error()
+		} else if toolResult, ok := result.(*sdk.CallToolResult); ok && toolResult.IsError {
+			record.Error = "tool error"
+			for _, content := range toolResult.Content {
+				if text, ok := content.(*sdk.TextContent); ok {
+					record.Error = text.Text
+					break
+				}
+			}
+		}
+		if len(record.Error) > maxCallErrorLen {
+			record.Error = strings.ToValidUTF8(record.Error[:maxCallErrorLen], "") + "..."
+		}
+		s.recordCall(record)
+		s.logSlowCall(record, duration, call.Params.Arguments)
+		return result, err
+	}
+}
+
+// recordCall appends record to s.RecentCalls, dropping the oldest calls past maxRecentCalls.
+func (s *State) recordCall(record CallRecord) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	s.RecentCalls = append(s.RecentCalls, record)
+	if len(s.RecentCalls) > maxRecentCalls {
+		s.RecentCalls = append([]CallRecord(nil), s.RecentCalls[len(s.RecentCalls)-maxRecentCalls:]...)
+	}
+}
@@ -0,0 +1,43 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallLogMiddleware(t *testing.T) {
+	state := NewState()
+	results := map[string]func() (sdk.Result, error){
+		"read": func() (sdk.Result, error) { return &sdk.CallToolResult{}, nil },
+		"edit": func() (sdk.Result, error) {
+			return &sdk.CallToolResult{Content: []sdk.Content{&sdk.TextContent{Text: "String not found"}}, IsError: true}, nil
+		},
+		"bash": func() (sdk.Result, error) { return nil, errors.New(strings.Repeat("x", 1000)) },
+	}
+	handler := state.CallLogMiddleware(func(ctx context.Context, method string, req sdk.Request) (sdk.Result, error) {
+		return results[req.(*sdk.CallToolRequest).Params.Name]()
+	})
+	for _, name := range []string{"read", "edit", "bash"} {
+		_, _ = handler(context.Background(), "tools/call", &sdk.CallToolRequest{Params: &sdk.CallToolParamsRaw{Name: name}})
+	}
+
+	require.Len(t, state.RecentCalls, 3)
+	assert.Equal(t, "read", state.RecentCalls[0].Tool)
+	assert.Equal(t, defaultSessionID, state.RecentCalls[0].SessionID)
+	assert.Empty(t, state.RecentCalls[0].Error)
+	assert.Equal(t, "String not found", state.RecentCalls[1].Error)
+	assert.Len(t, state.RecentCalls[2].Error, maxCallErrorLen+len("..."))
+
+	for i := range maxRecentCalls {
+		state.recordCall(CallRecord{Tool: fmt.Sprintf("tool_%d", i)})
+	}
+	require.Len(t, state.RecentCalls, maxRecentCalls)
+	assert.Equal(t, "tool_0", state.RecentCalls[0].Tool)
+}
@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func (s *State) executeCancelScheduledJob(ctx context.Context, jobID string) (string, error) {
+	if jobID == "" {
+		return "", fmt.Errorf("job_id is required.")
+	}
+
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	job, exists := s.ScheduledJobs[jobID]
+	if !exists {
+		return "", fmt.Errorf("Scheduled job with ID '%s' not found.", jobID)
+	}
+	// A timer that has already fired finds the job gone and does nothing.
+	if job.timer != nil {
+		job.timer.Stop()
+	}
+	delete(s.ScheduledJobs, jobID)
+
+	return fmt.Sprintf("Cancelled scheduled job: %s (%s)", jobID, job.Command), nil
+}
+
+var CancelScheduledJobTool = sdk.Tool{
+	Name:        "cancel_scheduled_job",
+	Description: "- Cancels a job created with schedule_command so it does not run again\n- A run already in progress keeps going; stop it with kill_shell\n- Use this tool to stop polling once the condition you were waiting for is met",
+}
+
+type CancelScheduledJobInput struct {
+	JobID string `json:"job_id" jsonschema:"The ID of the scheduled job to cancel"`
+}
+type CancelScheduledJobOutput struct {
+	Message string `json:"message"`
+}
+
+func CancelScheduledJob(ctx context.Context, req *sdk.CallToolRequest, args CancelScheduledJobInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeCancelScheduledJob(ctx, args.JobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &CancelScheduledJobOutput{Message: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,161 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// cassetteEntry is one tool call in a cassette file, which holds one entry per line: the call's
+// tool and arguments, and the result or protocol error it returned.
+type cassetteEntry struct {
+	Tool      string              `json:"tool"`
+	Arguments json.RawMessage     `json:"arguments,omitempty"`
+	Result    *sdk.CallToolResult `json:"result,omitempty"`
+	Error     string              `json:"error,omitempty"`
+}
+
+// cassetteRecorder appends every tool call to a cassette file.
+type cassetteRecorder struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// cassettePlayer answers tool calls from a cassette. Calls are matched on their tool and
+// arguments; repeats of a call get its recorded results in order, the last one being served again
+// once they run out.
+type cassettePlayer struct {
+	mu      sync.Mutex
+	entries map[string][]cassetteEntry
+	served  map[string]int
+}
+
+// RecordCalls makes CassetteMiddleware record every tool call and its result to a new cassette
+// file at path, for ReplayCalls to serve later. An empty path records nothing.
+func (s *State) RecordCalls(path string) error {
+	if path == "" {
+		return nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Cannot create cassette: %s", err)
+	}
+	s.Mu.Lock()
+	s.recorder = &cassetteRecorder{file: file}
+	s.Mu.Unlock()
+	return nil
+}
+
+// ReplayCalls makes CassetteMiddleware answer tool calls from the cassette file at path, written
+// by RecordCalls, instead of running them: no files are read or written and no processes are
+// started. Calls the cassette does not hold fail with a tool error. An empty path replays nothing.
+func (s *State) ReplayCalls(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Cannot read cassette: %s", err)
+	}
+	player := &cassettePlayer{entries: make(map[string][]cassetteEntry), served: make(map[string]int)}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for i := 1; ; i++ {
+		var entry cassetteEntry
+		if err := decoder.Decode(&entry); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("Cannot parse cassette %s: entry %d: %s", path, i, err)
+		}
+		if entry.Tool == "" || (entry.Result == nil && entry.Error == "") {
+			return fmt.Errorf("Invalid cassette %s: entry %d needs a tool and a result or error.", path, i)
+		}
+		key := cassetteKey(entry.Tool, entry.Arguments)
+		player.entries[key] = append(player.entries[key], entry)
+	}
+	s.Mu.Lock()
+	s.player = player
+	s.Mu.Unlock()
+	return nil
+}
+
+// cassetteKey identifies a call by its tool and arguments, with the arguments in canonical form so
+// that key order and spacing do not matter.
+func cassetteKey(tool string, arguments json.RawMessage) string {
+	var value any
+	if len(arguments) > 0 {
+		if err := json.Unmarshal(arguments, &value); err != nil {
+			return tool + "\x00" + string(arguments)
+		}
+	}
+	canonical, _ := json.Marshal(value)
+	return tool + "\x00" + string(canonical)
+}
+
+// CassetteMiddleware records tool calls or replays recorded results, as configured by RecordCalls
+// or ReplayCalls. It runs innermost, so that calls refused by authentication or approval are
+// neither recorded nor replayed.
+func (s *State) CassetteMiddleware(next sdk.MethodHandler) sdk.MethodHandler {
+	return func(ctx context.Context, method string, req sdk.Request) (sdk.Result, error) {
+		call, ok := req.(*sdk.CallToolRequest)
+		if !ok || call.Params == nil {
+			return next(ctx, method, req)
+		}
+		s.Mu.RLock()
+		recorder, player := s.recorder, s.player
+		s.Mu.RUnlock()
+		if player != nil {
+			return player.replay(call.Params.Name, call.Params.Arguments)
+		}
+		result, err := next(ctx, method, req)
+		if recorder != nil {
+			entry := cassetteEntry{Tool: call.Params.Name, Arguments: call.Params.Arguments}
+			if err != nil {
+				entry.Error = err.Error()
+			} else if toolResult, ok := result.(*sdk.CallToolResult); ok {
+				entry.Result = toolResult
+			}
+			recorder.record(entry)
+		}
+		return result, err
+	}
+}
+
+func (r *cassetteRecorder) record(entry cassetteEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, _ = r.file.Write(append(line, '\n'))
+}
+
+func (p *cassettePlayer) replay(tool string, arguments json.RawMessage) (sdk.Result, error) {
+	key := cassetteKey(tool, arguments)
+	p.mu.Lock()
+	entries := p.entries[key]
+	served := p.served[key]
+	if served < len(entries)-1 {
+		p.served[key]++
+	}
+	p.mu.Unlock()
+	if len(entries) == 0 {
+		return &sdk.CallToolResult{
+			Content: []sdk.Content{&sdk.TextContent{Text: fmt.Sprintf("The cassette holds no %s call with these arguments.", tool)}},
+			IsError: true,
+		}, nil
+	}
+	entry := entries[served]
+	if entry.Error != "" {
+		return nil, errors.New(entry.Error)
+	}
+	result := *entry.Result
+	return &result, nil
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cassetteCall sends a tools/call through handler.
+func cassetteCall(handler sdk.MethodHandler, tool, arguments string) (*sdk.CallToolResult, error) {
+	result, err := handler(context.Background(), "tools/call", &sdk.CallToolRequest{Params: &sdk.CallToolParamsRaw{Name: tool, Arguments: json.RawMessage(arguments)}})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*sdk.CallToolResult), nil
+}
+
+func resultText(result *sdk.CallToolResult) string {
+	return result.Content[0].(*sdk.TextContent).Text
+}
+
+func TestCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calls.jsonl")
+
+	recording := NewState()
+	require.NoError(t, recording.RecordCalls(path))
+	runs := 0
+	handler := recording.CassetteMiddleware(func(ctx context.Context, method string, req sdk.Request) (sdk.Result, error) {
+		runs++
+		call := req.(*sdk.CallToolRequest)
+		if call.Params.Name == "fail" {
+			return nil, errors.New("unknown tool")
+		}
+		text := fmt.Sprintf("%s run %d", call.Params.Name, runs)
+		return &sdk.CallToolResult{Content: []sdk.Content{&sdk.TextContent{Text: text}}, StructuredContent: map[string]any{"runs": runs}}, nil
+	})
+	_, err := cassetteCall(handler, "read", `{"file_path":"/a","limit":5}`)
+	require.NoError(t, err)
+	_, err = cassetteCall(handler, "bash", `{"command":"date"}`)
+	require.NoError(t, err)
+	_, err = cassetteCall(handler, "bash", `{"command":"date"}`)
+	require.NoError(t, err)
+	_, err = cassetteCall(handler, "fail", `{}`)
+	require.Error(t, err)
+	assert.Equal(t, 4, runs)
+
+	replaying := NewState()
+	require.NoError(t, replaying.ReplayCalls(path))
+	handler = replaying.CassetteMiddleware(func(ctx context.Context, method string, req sdk.Request) (sdk.Result, error) {
+		t.Fatal("replayed calls must not run")
+		return nil, nil
+	})
+
+	// Arguments match regardless of key order and spacing.
+	result, err := cassetteCall(handler, "read", `{ "limit": 5, "file_path": "/a" }`)
+	require.NoError(t, err)
+	assert.Equal(t, "read run 1", resultText(result))
+	assert.Equal(t, map[string]any{"runs": 1.0}, result.StructuredContent)
+
+	// Repeated calls get their results in order, then the last one again.
+	for _, want := range []string{"bash run 2", "bash run 3", "bash run 3"} {
+		result, err = cassetteCall(handler, "bash", `{"command":"date"}`)
+		require.NoError(t, err)
+		assert.Equal(t, want, resultText(result))
+	}
+
+	_, err = cassetteCall(handler, "fail", `{}`)
+	assert.EqualError(t, err, "unknown tool")
+
+	result, err = cassetteCall(handler, "read", `{"file_path":"/b"}`)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Contains(t, resultText(result), "no read call")
+}
+
+func TestReplayCalls_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	state := NewState()
+	assert.Error(t, state.ReplayCalls(filepath.Join(dir, "missing.jsonl")))
+
+	path := filepath.Join(dir, "bad.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{\"tool\":\"read\",\"result\":{\"content\":[]}}\n{\"tool\":\n"), 0o644))
+	assert.ErrorContains(t, state.ReplayCalls(path), "entry 2")
+	require.NoError(t, os.WriteFile(path, []byte(`{"tool":"read"}`), 0o644))
+	assert.ErrorContains(t, state.ReplayCalls(path), "needs a tool and a result or error")
+	assert.Nil(t, state.player)
+}
@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"time"
+)
+
+// maxChangeSnapshotBytes bounds the original content kept for one file in a session's change
+// manifest. Larger files are still listed, without a diff.
+const maxChangeSnapshotBytes = 1024 * 1024
+
+// FileChange is an entry in a session's change manifest: the state of a path from before the
+// session first changed it, against which its current state is compared when listing changes.
+type FileChange struct {
+	Path  string
+	Tools []string
+	First time.Time
+	Last  time.Time
+
+	Existed bool
+	Dir     bool
+	// Original is the content before the first change; it is nil for directories and for files
+	// larger than maxChangeSnapshotBytes, which set Unknown.
+	Original []byte
+	Unknown  bool
+
+	// MovedFrom is the source of a move_file that put this path in place.
+	MovedFrom string
+}
+
+// noteChange adds path to the calling session's change manifest before tool changes it, taking its
+// original state from disk. Call it after the change has been confirmed and before it is made.
+func (s *State) noteChange(ctx context.Context, tool, path string) {
+	s.recordChange(ctx, tool, s.snapshotChange(path))
+}
+
+// snapshotChange captures path's current state for the change manifest without recording it, for
+// tools that add the change with recordChange only once it has succeeded.
+func (s *State) snapshotChange(path string) *FileChange {
+	change := &FileChange{Path: path}
+	fsys := s.filesystem()
+	if info, err := fsys.Lstat(path); err == nil {
+		change.Existed = true
+		if info.IsDir() {
+			change.Dir = true
+		} else if info.Size() > maxChangeSnapshotBytes {
+			change.Unknown = true
+		} else if change.Original, err = fsys.ReadFile(path); err != nil {
+			change.Original, change.Unknown = nil, true
+		}
+	}
+	return change
+}
+
+// recordChange adds a change taken by snapshotChange to the calling session's manifest.
+func (s *State) recordChange(ctx context.Context, tool string, change *FileChange) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	s.addChange(sessionIDFromContext(ctx), tool, change)
+}
+
+// noteMove marks dst, already in the manifest, as having been moved from src.
+func (s *State) noteMove(ctx context.Context, src, dst string) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	if change := s.Changes[sessionIDFromContext(ctx)][dst]; change != nil {
+		change.MovedFrom = src
+	}
+}
+
+// addChange records change in session's manifest unless its path is already there, in which case
+// only the tool and time are added: the manifest keeps each path's state from before the session's
+// first change. The caller must hold s.Mu.
+func (s *State) addChange(session, tool string, change *FileChange) {
+	now := time.Now()
+	manifest := s.Changes[session]
+	if manifest == nil {
+		manifest = make(map[string]*FileChange)
+		s.Changes[session] = manifest
+	}
+	s.useSession(session)
+	if existing := manifest[change.Path]; existing != nil {
+		if !slices.Contains(existing.Tools, tool) {
+			existing.Tools = append(existing.Tools, tool)
+		}
+		existing.Last = now
+		return
+	}
+	// Originals share the edit history's budget; past it, further files are listed without diffs.
+	total := len(change.Original)
+	for _, c := range manifest {
+		total += len(c.Original)
+	}
+	if len(change.Original) > maxChangeSnapshotBytes || total > maxEditHistoryBytes {
+		change.Original, change.Unknown = nil, true
+	}
+	change.Original = bytes.Clone(change.Original)
+	change.Tools = []string{tool}
+	change.First, change.Last = now, now
+	manifest[change.Path] = change
+}
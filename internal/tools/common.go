@@ -2,6 +2,7 @@ package tools
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -16,6 +17,16 @@ func resolvePath(filePath string) (string, error) {
 	return filepath.Clean(filePath), nil
 }
 
+// parseFileMode parses an octal permission string such as "755" or "0644". Only permission bits
+// are accepted; setuid/setgid/sticky bits must be applied explicitly through Bash if ever needed.
+func parseFileMode(mode string) (os.FileMode, error) {
+	perm, err := strconv.ParseUint(strings.TrimPrefix(mode, "0o"), 8, 32)
+	if err != nil || perm > 0o777 {
+		return 0, fmt.Errorf("Invalid mode: %s. Must be an octal permission string such as \"755\" or \"0644\".", mode)
+	}
+	return os.FileMode(perm), nil
+}
+
 // catN formats lines with line numbers in the style of `cat -n`, using a dynamically-sized
 // column width to align numbers. This ensures proper alignment even for files with thousands
 // of lines. Each line is truncated to 2000 characters to prevent excessively large output.
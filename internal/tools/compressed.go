@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// compressionFormats maps the MIME types of compressed files that Read decompresses to the name
+// of their format.
+var compressionFormats = map[string]string{
+	"application/gzip": "gzip",
+	"application/zstd": "zstd",
+}
+
+// errDecompressedTooLarge stops an unbounded read once the decompressed content passes the read
+// size limit, so a small file that expands enormously cannot exhaust memory.
+var errDecompressedTooLarge = errors.New("decompressed content is too large")
+
+// readCompressed reads the text inside a gzip or zstd file as executeRead does for plain files,
+// noting the compressed size. header holds the bytes already read from file. Content that is not
+// text after decompression is reported as binary.
+func (s *State) readCompressed(ctx context.Context, resolved, format string, file vfs.File, header []byte, fileInfo os.FileInfo, offset, limit int64) (string, error) {
+	compressed := io.MultiReader(bytes.NewReader(header), file)
+	var content io.Reader
+	// checkEnd reports a decompressor failure once its output has ended, since a failing zstd
+	// simply stops writing.
+	checkEnd := func() error { return nil }
+	switch format {
+	case "gzip":
+		gz, err := gzip.NewReader(compressed)
+		if err != nil {
+			return "", fmt.Errorf("Cannot decompress file: %s", err)
+		}
+		defer gz.Close()
+		content = gz
+	case "zstd":
+		if _, err := exec.LookPath("zstd"); err != nil {
+			return "", fmt.Errorf("Reading zstd files requires the zstd command, which was not found. Use format \"hex\" or \"base64\" to inspect the raw bytes.")
+		}
+		zstdCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		cmd := exec.CommandContext(zstdCtx, "zstd", "-dc")
+		cmd.Stdin = compressed
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return "", fmt.Errorf("Cannot decompress file: %s", err)
+		}
+		if err := cmd.Start(); err != nil {
+			return "", fmt.Errorf("Cannot decompress file: %s", err)
+		}
+		eof := &eofReader{r: stdout}
+		content = eof
+		waited := false
+		checkEnd = func() error {
+			if !eof.done || waited {
+				return nil
+			}
+			waited = true
+			if err := cmd.Wait(); err != nil {
+				return fmt.Errorf("Cannot decompress file: %s", strings.TrimSpace(stderr.String()))
+			}
+			return nil
+		}
+		defer func() {
+			// Stop zstd if the read ended before the content did
+			if !waited {
+				cancel()
+				_ = cmd.Wait()
+			}
+		}()
+	}
+
+	// As for plain files, the size limit applies only to unbounded reads
+	s.Mu.RLock()
+	maxSize := s.MaxFileSize
+	s.Mu.RUnlock()
+	if limit <= 0 {
+		content = &cappedReader{r: content, remaining: maxSize}
+	}
+
+	textHeader := make([]byte, mimeHeaderBytes)
+	n, err := io.ReadFull(content, textHeader)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", decompressError(err, maxSize)
+	}
+	textHeader = textHeader[:n]
+	if err := checkEnd(); err != nil {
+		return "", err
+	}
+	note := fmt.Sprintf("[Decompressed from %s; compressed size %d bytes]", format, fileInfo.Size())
+	if n == 0 {
+		return "<system-reminder>Warning: the file exists but the decompressed contents are empty.</system-reminder>\n" + note, nil
+	}
+	mtype := mimetype.Detect(textHeader)
+	if !mtype.Is("text/plain") && !mtype.Parent().Is("text/plain") {
+		return fmt.Sprintf("[Compressed binary file: %s (%s containing %s), %d bytes. Use format \"hex\" or \"base64\" to inspect its bytes]", resolved, format, mtype.String(), fileInfo.Size()), nil
+	}
+
+	lines, totalLines, err := readLines(io.MultiReader(bytes.NewReader(textHeader), content), int(offset), int(limit))
+	if err != nil {
+		return "", decompressError(err, maxSize)
+	}
+	if err := checkEnd(); err != nil {
+		return "", err
+	}
+	startLine, endLine := calculateLineRange(totalLines, int(offset), int(limit))
+	if offset > 0 && (startLine < 1 || startLine > totalLines) {
+		return fmt.Sprintf(
+			"<system-reminder>Warning: the file exists but is shorter than the provided offset (%d). The decompressed file has %d lines.</system-reminder>",
+			startLine,
+			totalLines,
+		), nil
+	}
+
+	result := catN(lines[:endLine-startLine+1], startLine) + "\n\n" + note
+	if err := checkOutputSize(ctx, result, "read"); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+func decompressError(err error, maxSize int64) error {
+	if errors.Is(err, errDecompressedTooLarge) {
+		return fmt.Errorf("Decompressed content exceeds maximum allowed size (%d bytes). Please use the offset and limit parameters to read specific portions of the file.", maxSize)
+	}
+	return fmt.Errorf("Cannot decompress file: %s", err)
+}
+
+// cappedReader returns errDecompressedTooLarge if its reader holds more than remaining bytes.
+type cappedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	if c.remaining <= 0 {
+		var probe [1]byte
+		n, err := c.r.Read(probe[:])
+		if n > 0 {
+			return 0, errDecompressedTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	return n, err
+}
+
+// eofReader records whether its reader was read to the end, and returns io.EOF from then on
+// without touching the reader, which may have been closed.
+type eofReader struct {
+	r    io.Reader
+	done bool
+}
+
+func (e *eofReader) Read(p []byte) (int, error) {
+	if e.done {
+		return 0, io.EOF
+	}
+	n, err := e.r.Read(p)
+	if err == io.EOF {
+		e.done = true
+	}
+	return n, err
+}
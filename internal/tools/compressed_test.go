@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeGzip(t *testing.T, content string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	path := filepath.Join(t.TempDir(), "app.log.gz")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+	return path
+}
+
+func TestRead_Compressed(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("gzip text", func(t *testing.T) {
+		path := writeGzip(t, "first line\nsecond line\nthird line\n")
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+
+		result, err := NewState().executeRead(ctx, path, 0, 0)
+		require.NoError(t, err)
+		assert.Contains(t, result, "1→first line")
+		assert.Contains(t, result, "3→third line")
+		assert.Contains(t, result, fmt.Sprintf("[Decompressed from gzip; compressed size %d bytes]", info.Size()))
+
+		result, err = NewState().executeRead(ctx, path, 2, 1)
+		require.NoError(t, err)
+		assert.Contains(t, result, "2→second line")
+		assert.NotContains(t, result, "third line")
+	})
+
+	t.Run("gzip binary stays binary", func(t *testing.T) {
+		path := writeGzip(t, "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+		result, err := NewState().executeRead(ctx, path, 0, 0)
+		require.NoError(t, err)
+		assert.Contains(t, result, "[Compressed binary file:")
+	})
+
+	t.Run("decompressed size limit", func(t *testing.T) {
+		path := writeGzip(t, strings.Repeat("0123456789\n", 1000))
+		state := NewState()
+		require.NoError(t, state.SetMaxFileSize(5000))
+
+		_, err := state.executeRead(ctx, path, 0, 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Decompressed content exceeds maximum allowed size (5000 bytes)")
+
+		result, err := state.executeRead(ctx, path, 900, 1)
+		require.NoError(t, err, "bounded reads may go past the limit")
+		assert.Contains(t, result, "900→0123456789")
+	})
+
+	t.Run("corrupt gzip", func(t *testing.T) {
+		path := writeGzip(t, strings.Repeat("some log line\n", 100))
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, data[:len(data)/2], 0o644))
+
+		_, err = NewState().executeRead(ctx, path, 0, 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Cannot decompress file")
+	})
+
+	t.Run("zstd text", func(t *testing.T) {
+		if _, err := exec.LookPath("zstd"); err != nil {
+			t.Skip("zstd is not installed")
+		}
+		dir := t.TempDir()
+		plain := filepath.Join(dir, "app.log")
+		require.NoError(t, os.WriteFile(plain, []byte("hello from zstd\n"), 0o644))
+		require.NoError(t, exec.Command("zstd", "-q", plain).Run())
+
+		result, err := NewState().executeRead(ctx, plain+".zst", 0, 0)
+		require.NoError(t, err)
+		assert.Contains(t, result, "1→hello from zstd")
+		assert.Contains(t, result, "Decompressed from zstd")
+
+		// A truncated frame fails rather than returning partial content silently
+		data, err := os.ReadFile(plain + ".zst")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(plain+".zst", data[:len(data)-4], 0o644))
+		_, err = NewState().executeRead(ctx, plain+".zst", 0, 0)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// busyError reports that a concurrency cap was reached. Its message is JSON so that clients can
+// recognize the condition and back off instead of treating it as a failed command.
+type busyError struct {
+	Resource string `json:"resource"`
+	Limit    int    `json:"limit"`
+	Message  string `json:"message"`
+}
+
+func (e *busyError) Error() string {
+	data, _ := json.Marshal(struct {
+		Error string `json:"error"`
+		*busyError
+	}{"busy", e})
+	return string(data)
+}
+
+// SetConcurrencyLimits caps how many foreground bash commands may run at once across all clients,
+// and how many background shells may be running. Zero leaves a limit off. Lowering a limit stops
+// nothing already running; raising one starts queued shells in the new slots.
+func (s *State) SetConcurrencyLimits(maxCommands, maxBackgroundShells int) error {
+	if maxCommands < 0 || maxBackgroundShells < 0 {
+		return fmt.Errorf("concurrency limits cannot be negative")
+	}
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	s.MaxConcurrentCommands = maxCommands
+	s.MaxBackgroundShells = maxBackgroundShells
+	for len(s.shellQueue) > 0 && (maxBackgroundShells == 0 || s.RunningShells < maxBackgroundShells) {
+		close(s.shellQueue[0])
+		s.shellQueue = s.shellQueue[1:]
+		s.RunningShells++
+	}
+	return nil
+}
+
+// acquireCommandSlot reserves one of the foreground command slots, returning a busyError when all
+// are taken. Callers must call releaseCommandSlot when the command finishes.
+func (s *State) acquireCommandSlot() error {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	if s.MaxConcurrentCommands > 0 && s.RunningCommands >= s.MaxConcurrentCommands {
+		return &busyError{
+			Resource: "bash",
+			Limit:    s.MaxConcurrentCommands,
+			Message:  fmt.Sprintf("%d bash commands are already running. Retry when one finishes.", s.RunningCommands),
+		}
+	}
+	s.RunningCommands++
+	return nil
+}
+
+func (s *State) releaseCommandSlot() {
+	s.Mu.Lock()
+	s.RunningCommands--
+	s.Mu.Unlock()
+}
+
+// acquireShellSlot reserves a slot for a new background shell, returning a busyError when the cap
+// is reached. The slot is released by releaseShellSlot once the shell's process exits.
+func (s *State) acquireShellSlot() error {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	if s.MaxBackgroundShells > 0 && s.RunningShells >= s.MaxBackgroundShells {
+		return &busyError{
+			Resource: "background_shells",
+			Limit:    s.MaxBackgroundShells,
+			Message:  fmt.Sprintf("%d background shells are already running. Kill one with kill_shell, wait for one to finish, or set queue_if_busy to start when one does.", s.RunningShells),
+		}
+	}
+	s.RunningShells++
+	return nil
+}
+
+// requestShellSlot reserves a slot for a background shell like acquireShellSlot, but when the cap
+// is reached it queues the shell until a running one exits and hands over its slot. Slots go to
+// shells in the order they queued. The returned channel is closed once the shell holds a slot.
+// Callers must hold s.Mu, and must pass the channel to awaitShellSlot or cancelShellSlot.
+func (s *State) requestShellSlot(shell *BackgroundShell) <-chan struct{} {
+	ready := make(chan struct{})
+	if s.MaxBackgroundShells == 0 || s.RunningShells < s.MaxBackgroundShells {
+		s.RunningShells++
+		close(ready)
+		return ready
+	}
+	s.shellQueue = append(s.shellQueue, ready)
+	shell.queued = true
+	return ready
+}
+
+// awaitShellSlot waits for a slot requested with requestShellSlot. It reports false, holding no
+// slot, if abort is closed first.
+func (s *State) awaitShellSlot(shell *BackgroundShell, ready <-chan struct{}, abort <-chan struct{}) bool {
+	select {
+	case <-ready:
+		s.Mu.Lock()
+		shell.queued = false
+		s.Mu.Unlock()
+		return true
+	case <-abort:
+		s.cancelShellSlot(shell, ready)
+		return false
+	}
+}
+
+// cancelShellSlot gives up a slot requested with requestShellSlot, whether or not it was granted.
+func (s *State) cancelShellSlot(shell *BackgroundShell, ready <-chan struct{}) {
+	s.Mu.Lock()
+	shell.queued = false
+	for i, ch := range s.shellQueue {
+		if ch == ready {
+			s.shellQueue = append(s.shellQueue[:i], s.shellQueue[i+1:]...)
+			s.Mu.Unlock()
+			return
+		}
+	}
+	s.Mu.Unlock()
+	// The slot was granted, so pass it on.
+	s.releaseShellSlot()
+}
+
+// releaseShellSlot frees a background shell slot, handing it straight to the first queued shell
+// if there is one.
+func (s *State) releaseShellSlot() {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	if len(s.shellQueue) > 0 {
+		close(s.shellQueue[0])
+		s.shellQueue = s.shellQueue[1:]
+		return
+	}
+	s.RunningShells--
+}
@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimits(t *testing.T) {
+	t.Run("foreground commands", func(t *testing.T) {
+		state := NewState()
+		require.NoError(t, state.SetConcurrencyLimits(1, 0))
+
+		// Occupy the only slot, as a long-running command would
+		require.NoError(t, state.acquireCommandSlot())
+		_, err := callBash(t, state, BashInput{Command: "echo hi"})
+		require.Error(t, err)
+
+		var busy map[string]any
+		require.NoError(t, json.Unmarshal([]byte(err.Error()), &busy))
+		assert.Equal(t, "busy", busy["error"])
+		assert.Equal(t, "bash", busy["resource"])
+
+		state.releaseCommandSlot()
+		result, err := callBash(t, state, BashInput{Command: "echo hi"})
+		require.NoError(t, err)
+		assert.Contains(t, result, "hi")
+		assert.Equal(t, 0, state.RunningCommands)
+	})
+
+	t.Run("background shells", func(t *testing.T) {
+		state := NewState()
+		require.NoError(t, state.SetConcurrencyLimits(0, 1))
+
+		result, err := callBash(t, state, BashInput{Command: "sleep 0.2", RunInBackground: true})
+		require.NoError(t, err)
+		shellID := extractShellID(result)
+
+		_, err = callBash(t, state, BashInput{Command: "sleep 0.2", RunInBackground: true})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `"resource":"background_shells"`)
+
+		// The slot frees up once the first shell exits
+		state.Mu.RLock()
+		done := state.BackgroundShells[shellID].Done
+		state.Mu.RUnlock()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("background shell did not finish")
+		}
+		_, err = callBash(t, state, BashInput{Command: "true", RunInBackground: true})
+		require.NoError(t, err)
+	})
+
+	t.Run("queued background shells start in order as slots free up", func(t *testing.T) {
+		state := NewState()
+		require.NoError(t, state.SetConcurrencyLimits(0, 1))
+		dir := t.TempDir()
+
+		first := startBackground(t, state, "sleep 0.2", bashOptions{})
+		second := startBackground(t, state, "echo second >> "+dir+"/order", bashOptions{QueueIfBusy: true})
+		third := startBackground(t, state, "echo third >> "+dir+"/order", bashOptions{QueueIfBusy: true})
+		state.Mu.RLock()
+		secondShell, thirdShell := state.BackgroundShells[second], state.BackgroundShells[third]
+		state.Mu.RUnlock()
+		assert.Equal(t, "queued", statusOf(state, secondShell))
+
+		waitShell(t, state, first)
+		waitShell(t, state, second)
+		waitShell(t, state, third)
+		assert.Equal(t, "completed", statusOf(state, thirdShell))
+		data, err := os.ReadFile(filepath.Join(dir, "order"))
+		require.NoError(t, err)
+		assert.Equal(t, "second\nthird\n", string(data))
+		assert.Equal(t, 0, state.RunningShells)
+	})
+
+	t.Run("killing a queued shell cancels it", func(t *testing.T) {
+		state := NewState()
+		require.NoError(t, state.SetConcurrencyLimits(0, 1))
+
+		running := startBackground(t, state, "sleep 0.2", bashOptions{})
+		queued := startBackground(t, state, "true", bashOptions{QueueIfBusy: true})
+		state.Mu.RLock()
+		queuedShell := state.BackgroundShells[queued]
+		state.Mu.RUnlock()
+		require.Eventually(t, func() bool { return statusOf(state, queuedShell) == "queued" }, time.Second, 10*time.Millisecond)
+
+		message, err := state.executeKillShell(context.Background(), queued)
+		require.NoError(t, err)
+		assert.Contains(t, message, "cancelled")
+		waitShell(t, state, running)
+		<-queuedShell.Done
+		assert.Equal(t, "killed while queued for a background shell slot", queuedShell.SkipReason)
+
+		state.Mu.RLock()
+		defer state.Mu.RUnlock()
+		assert.Equal(t, 0, state.RunningShells)
+		assert.Empty(t, state.shellQueue)
+	})
+
+	t.Run("raising the limit starts queued shells", func(t *testing.T) {
+		state := NewState()
+		require.NoError(t, state.SetConcurrencyLimits(0, 1))
+
+		running := startBackground(t, state, "sleep 5", bashOptions{})
+		queued := startBackground(t, state, "true", bashOptions{QueueIfBusy: true})
+		state.Mu.RLock()
+		runningShell, queuedShell := state.BackgroundShells[running], state.BackgroundShells[queued]
+		state.Mu.RUnlock()
+		require.Eventually(t, func() bool { return statusOf(state, queuedShell) == "queued" }, time.Second, 10*time.Millisecond)
+
+		require.NoError(t, state.SetConcurrencyLimits(0, 2))
+		waitShell(t, state, queued)
+		assert.Equal(t, "completed", statusOf(state, queuedShell))
+		_, err := state.executeKillShell(context.Background(), running)
+		require.NoError(t, err)
+		<-runningShell.Done
+		state.Mu.RLock()
+		defer state.Mu.RUnlock()
+		assert.Equal(t, 0, state.RunningShells)
+	})
+
+	t.Run("queue_if_busy requires run_in_background", func(t *testing.T) {
+		_, err := NewState().executeBashCommand(context.Background(), "true", "", 0, false, bashOptions{QueueIfBusy: true})
+		require.Error(t, err)
+	})
+
+	t.Run("negative limits rejected", func(t *testing.T) {
+		require.Error(t, NewState().SetConcurrencyLimits(-1, 0))
+	})
+}
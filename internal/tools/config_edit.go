@@ -0,0 +1,450 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// configKey is one step of a config_edit key path: an object key, or an index into an array.
+type configKey struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// configPathString renders path the way parseConfigPath reads it, for messages.
+func configPathString(path []configKey) string {
+	var b strings.Builder
+	for i, k := range path {
+		switch {
+		case k.isIndex:
+			fmt.Fprintf(&b, "[%d]", k.index)
+		case k.key == "" || strings.ContainsAny(k.key, ".[]\"'"):
+			fmt.Fprintf(&b, "[%s]", strconv.Quote(k.key))
+		default:
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(k.key)
+		}
+	}
+	return b.String()
+}
+
+// configPathName names the value at path in messages.
+func configPathName(path []configKey) string {
+	if len(path) == 0 {
+		return "the document"
+	}
+	return configPathString(path)
+}
+
+// parseConfigPath parses a key path such as server.ports[0].name. Keys containing dots or
+// brackets are quoted in brackets: labels["app.kubernetes.io/name"].
+func parseConfigPath(path string) ([]configKey, error) {
+	if path == "" {
+		return nil, fmt.Errorf("key is required")
+	}
+	var keys []configKey
+	for i := 0; i < len(path); {
+		switch {
+		case path[i] == '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("Invalid key %q: unclosed [", path)
+			}
+			inner := path[i+1 : i+end]
+			if len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') {
+				quote := inner[0]
+				// A quoted key may itself contain ], so find its closing quote first.
+				close := strings.IndexByte(path[i+2:], quote)
+				if close < 0 || !strings.HasPrefix(path[i+2+close+1:], "]") {
+					return nil, fmt.Errorf("Invalid key %q: unclosed quote", path)
+				}
+				keys = append(keys, configKey{key: path[i+2 : i+2+close]})
+				i += 2 + close + 2
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("Invalid key %q: [%s] must be an array index or a quoted key", path, inner)
+				}
+				keys = append(keys, configKey{index: index, isIndex: true})
+				i += end + 1
+			}
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("Invalid key %q: empty key segment", path)
+			}
+			keys = append(keys, configKey{key: path[i : i+end]})
+			i += end
+		}
+		if i < len(path) && path[i] == '.' {
+			i++
+			if i == len(path) || path[i] == '.' {
+				return nil, fmt.Errorf("Invalid key %q: empty key segment", path)
+			}
+		} else if i < len(path) && path[i] != '[' {
+			return nil, fmt.Errorf("Invalid key %q: expected . or [ after %s", path, configPathString(keys))
+		}
+	}
+	return keys, nil
+}
+
+// configValue is a JSON value with the order of object keys preserved, so that a value set in a
+// config file keeps the key order it was given in.
+type configValue struct {
+	// kind is one of object, array, string, number, bool, or null. scalar holds a string's
+	// content, a number's literal, or "true"/"false".
+	kind   string
+	scalar string
+	keys   []string
+	items  []*configValue
+}
+
+func parseConfigValue(raw string) (*configValue, error) {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	value, err := decodeConfigValue(dec)
+	if err == nil {
+		if _, extra := dec.Token(); extra != io.EOF {
+			err = fmt.Errorf("unexpected data after the value")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("value must be JSON, e.g. 8080, true, \"text\", [1, 2], or {\"key\": \"value\"}: %s", err)
+	}
+	return value, nil
+}
+
+func decodeConfigValue(dec *json.Decoder) (*configValue, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		value := &configValue{kind: "array"}
+		if t == '{' {
+			value.kind = "object"
+		}
+		for dec.More() {
+			if value.kind == "object" {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value.keys = append(value.keys, key.(string))
+			}
+			item, err := decodeConfigValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			value.items = append(value.items, item)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return value, nil
+	case string:
+		return &configValue{kind: "string", scalar: t}, nil
+	case json.Number:
+		return &configValue{kind: "number", scalar: t.String()}, nil
+	case bool:
+		return &configValue{kind: "bool", scalar: strconv.FormatBool(t)}, nil
+	default:
+		return &configValue{kind: "null"}, nil
+	}
+}
+
+// json renders the value as compact JSON, or indented with unit below a line indented by prefix.
+func (v *configValue) json(prefix, unit string, compact bool) string {
+	var b strings.Builder
+	v.writeJSON(&b, prefix, unit, compact)
+	return b.String()
+}
+
+func (v *configValue) writeJSON(b *strings.Builder, prefix, unit string, compact bool) {
+	switch v.kind {
+	case "object", "array":
+		open, close := "[", "]"
+		if v.kind == "object" {
+			open, close = "{", "}"
+		}
+		b.WriteString(open)
+		for i, item := range v.items {
+			if i > 0 {
+				b.WriteString(",")
+				if compact {
+					b.WriteString(" ")
+				}
+			}
+			if !compact {
+				b.WriteString("\n" + prefix + unit)
+			}
+			if v.kind == "object" {
+				b.WriteString(jsonString(v.keys[i]) + ": ")
+			}
+			item.writeJSON(b, prefix+unit, unit, compact)
+		}
+		if !compact && len(v.items) > 0 {
+			b.WriteString("\n" + prefix)
+		}
+		b.WriteString(close)
+	case "string":
+		b.WriteString(jsonString(v.scalar))
+	case "null":
+		b.WriteString("null")
+	default:
+		b.WriteString(v.scalar)
+	}
+}
+
+// jsonString quotes s as a JSON string without the HTML escaping of json.Marshal.
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// nestConfigValue wraps value in the objects and arrays that path describes, for setting a key
+// whose parents do not exist yet. New arrays can only start at index 0.
+func nestConfigValue(path []configKey, value *configValue) (*configValue, error) {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].isIndex {
+			if path[i].index != 0 {
+				return nil, fmt.Errorf("cannot create %s: a new array starts at index 0", configPathString(path[:i+1]))
+			}
+			value = &configValue{kind: "array", items: []*configValue{value}}
+		} else {
+			value = &configValue{kind: "object", keys: []string{path[i].key}, items: []*configValue{value}}
+		}
+	}
+	return value, nil
+}
+
+// configFormat returns the config format of a file from its extension.
+func configFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".jsonc":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	}
+	return ""
+}
+
+func checkConfigSyntax(format, content string) error {
+	var err error
+	switch format {
+	case "json":
+		_, err = parseJSONConfig(content)
+	case "yaml":
+		_, err = parseYAMLConfig(content)
+	case "toml":
+		_, err = parseTOMLConfig(content)
+	}
+	return err
+}
+
+// detectIndentUnit returns the smallest indentation step used in content, or def when no line is
+// indented. Comment lines are ignored.
+func detectIndentUnit(content, def string) string {
+	unit := ""
+	for _, line := range strings.Split(content, "\n") {
+		indent := leadingWhitespace(line)
+		rest := strings.TrimSpace(line)
+		if indent == "" || rest == "" || strings.HasPrefix(rest, "#") || strings.HasPrefix(rest, "//") {
+			continue
+		}
+		if indent[0] == '\t' {
+			return "\t"
+		}
+		if unit == "" || len(indent) < len(unit) {
+			unit = indent
+		}
+	}
+	if unit == "" {
+		return def
+	}
+	return unit
+}
+
+// lineStartOf returns the offset of the start of the line containing offset.
+func lineStartOf(content string, offset int) int {
+	return strings.LastIndexByte(content[:offset], '\n') + 1
+}
+
+// lineEndOf returns the offset just past the newline ending the line containing offset, or the
+// end of content.
+func lineEndOf(content string, offset int) int {
+	if i := strings.IndexByte(content[offset:], '\n'); i >= 0 {
+		return offset + i + 1
+	}
+	return len(content)
+}
+
+func (s *State) executeConfigEdit(ctx context.Context, filePath, key, value string, remove, dryRun, backup bool) (string, error) {
+	if !dryRun {
+		if err := s.checkOverlay("config_edit"); err != nil {
+			return "", err
+		}
+	}
+	path, err := parseConfigPath(key)
+	if err != nil {
+		return "", err
+	}
+	var newValue *configValue
+	switch {
+	case remove && value != "":
+		return "", fmt.Errorf("value cannot be combined with delete")
+	case !remove && value == "":
+		return "", fmt.Errorf("value is required unless delete is set")
+	case !remove:
+		if newValue, err = parseConfigValue(value); err != nil {
+			return "", err
+		}
+	}
+
+	resolved, err := s.resolvePath(filePath)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+	if !dryRun {
+		defer s.lockPaths(ctx, resolved)()
+	}
+	format := configFormat(resolved)
+	if format == "" {
+		return "", fmt.Errorf("config_edit supports JSON (.json, .jsonc), YAML (.yaml, .yml), and TOML (.toml) files, not %s", filepath.Base(resolved))
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("File does not exist.")
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("path is a directory, not a file: %s", resolved)
+	}
+	if err := s.checkFileSize(ctx, info.Size(), "config_edit"); err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(resolved)
+	if err != nil {
+		return "", fmt.Errorf("Cannot read file: %s", err)
+	}
+	oldContent := string(content)
+
+	// Like edit, work on LF-normalized content and restore the file's line endings afterwards.
+	lineEnding := detectLineEnding(oldContent)
+	edited := oldContent
+	if lineEnding == lineEndingCRLF {
+		edited = convertLineEndings(edited, lineEndingLF)
+	}
+	switch format {
+	case "json":
+		edited, err = editJSONConfig(edited, path, newValue)
+	case "yaml":
+		edited, err = editYAMLConfig(edited, path, newValue)
+	case "toml":
+		edited, err = editTOMLConfig(edited, path, newValue)
+	}
+	if err != nil {
+		return "", err
+	}
+	// A safety net for layouts the editors misjudge: never write a file that no longer parses.
+	if err := checkConfigSyntax(format, edited); err != nil {
+		return "", fmt.Errorf("The change would leave the file invalid (%s); make it with Edit instead.", err)
+	}
+	if !strings.HasSuffix(oldContent, "\n") {
+		edited = strings.TrimSuffix(edited, "\n")
+	}
+	if lineEnding == lineEndingCRLF {
+		edited = convertLineEndings(edited, lineEndingCRLF)
+	}
+
+	action := "Set"
+	if remove {
+		action = "Deleted"
+	}
+	if edited == oldContent {
+		return fmt.Sprintf("%s already has %s set to that value; nothing to change.", resolved, key), nil
+	}
+	diff := renderUnified(resolved, resolved, diffHunks(diffLines(splitDiffLines(content), splitDiffLines([]byte(edited))), 3))
+	if err := checkOutputSize(ctx, diff, "config_edit"); err != nil {
+		return "", err
+	}
+	if dryRun {
+		return fmt.Sprintf("%s %s would change %s:\n%s", action, key, resolved, diff), nil
+	}
+
+	if err := s.confirmWrite(ctx, "config_edit", resolved); err != nil {
+		return "", err
+	}
+	if backup {
+		if _, err := s.backupFile(ctx, resolved, true); err != nil {
+			return "", err
+		}
+	}
+	if err := s.writeFile(resolved, []byte(edited), 0); err != nil {
+		return "", err
+	}
+	s.recordEdit(ctx, "config_edit", resolved, true, content, []byte(edited))
+
+	// Files already read stay editable without being read again, as after an edit.
+	s.Mu.Lock()
+	if _, tracked := s.ReadFiles[resolved]; tracked {
+		if info, err := os.Stat(resolved); err == nil {
+			s.ReadFiles[resolved] = info.ModTime()
+		}
+	}
+	s.Mu.Unlock()
+	return fmt.Sprintf("%s %s in %s:\n%s", action, key, resolved, diff), nil
+}
+
+var ConfigEditTool = sdk.Tool{
+	Name:        "config_edit",
+	Description: "Sets or deletes a key in a JSON, YAML, or TOML file by its path, changing only the lines involved so the file's comments, indentation, and key order are kept. Prefer it over Edit for config files, where string replacements easily break indentation or duplicate keys.\n\nUsage:\n- key is a path of keys and array indexes, e.g. server.port, services.web.ports[0], or dependencies[\"@types/node\"] for keys containing dots or brackets.\n- value is the new value as JSON: 8080, true, \"a string\", [\"a\", \"b\"], or {\"key\": \"value\"}. Missing parent keys are created; an index one past the end of an array appends to it.\n- Set delete to remove the key instead.\n- JSON files may contain comments and trailing commas. TOML has no null, and arrays, inline tables, and YAML flow collections ([a, b], {a: 1}) can only be set as a whole.\n- Set dry_run to see the change as a diff without writing it.\n- Set backup to true to save the previous content so it can be reverted with restore_backup.",
+}
+
+type ConfigEditInput struct {
+	FilePath string `json:"file_path" jsonschema:"The absolute path to the JSON, YAML, or TOML file to modify"`
+	Key      string `json:"key" jsonschema:"The path of the key to set or delete, e.g. server.ports[0]"`
+	Value    string `json:"value,omitempty" jsonschema:"The new value as JSON, e.g. 8080, \"text\", or {\"a\": 1}"`
+	Delete   bool   `json:"delete,omitempty" jsonschema:"Delete the key instead of setting it"`
+	DryRun   bool   `json:"dry_run,omitempty" jsonschema:"Show the change as a diff without writing it"`
+	Backup   *bool  `json:"backup,omitempty" jsonschema:"Save the file's content before editing, restorable with restore_backup. Defaults to the server setting"`
+}
+type ConfigEditOutput struct {
+	Result string `json:"result"`
+}
+
+func ConfigEdit(ctx context.Context, req *sdk.CallToolRequest, args ConfigEditInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeConfigEdit(withSession(ctx, req), args.FilePath, args.Key, args.Value, args.Delete, args.DryRun, server.useBackup(args.Backup))
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &ConfigEditOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
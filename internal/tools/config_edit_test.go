@@ -0,0 +1,240 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfigPath(t *testing.T) {
+	path, err := parseConfigPath(`spec.containers[0].env["app.kubernetes.io/name"]`)
+	require.NoError(t, err)
+	assert.Equal(t, []configKey{
+		{key: "spec"}, {key: "containers"}, {index: 0, isIndex: true}, {key: "env"}, {key: "app.kubernetes.io/name"},
+	}, path)
+	assert.Equal(t, `spec.containers[0].env["app.kubernetes.io/name"]`, configPathString(path))
+
+	for _, bad := range []string{"", "a..b", "a.", "a[x]", "a[-1]", "a[0", `a["b]`, "a[0]b"} {
+		_, err := parseConfigPath(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+// editConfig applies one config_edit change to src; an empty value deletes the key.
+func editConfig(t *testing.T, edit func(string, []configKey, *configValue) (string, error), src, key, value string) (string, error) {
+	t.Helper()
+	path, err := parseConfigPath(key)
+	require.NoError(t, err)
+	var v *configValue
+	if value != "" {
+		v, err = parseConfigValue(value)
+		require.NoError(t, err)
+	}
+	return edit(src, path, v)
+}
+
+func TestEditJSONConfig(t *testing.T) {
+	src := "{\n  // comment\n  \"name\": \"app\", // trailing\n  \"deps\": {\n    \"a\": \"1.0\"\n  },\n  \"list\": [1, 2],\n  \"empty\": {}\n}\n"
+	tests := []struct {
+		name, key, value, want string
+	}{
+		{"replace", "name", `"other"`, "{\n  // comment\n  \"name\": \"other\", // trailing\n  \"deps\": {\n    \"a\": \"1.0\"\n  },\n  \"list\": [1, 2],\n  \"empty\": {}\n}\n"},
+		{"add key", "deps.b", `"2.0"`, "{\n  // comment\n  \"name\": \"app\", // trailing\n  \"deps\": {\n    \"a\": \"1.0\",\n    \"b\": \"2.0\"\n  },\n  \"list\": [1, 2],\n  \"empty\": {}\n}\n"},
+		{"append to one-line array", "list[2]", `3`, "{\n  // comment\n  \"name\": \"app\", // trailing\n  \"deps\": {\n    \"a\": \"1.0\"\n  },\n  \"list\": [1, 2, 3],\n  \"empty\": {}\n}\n"},
+		{"create parents", "empty.x.y", `true`, "{\n  // comment\n  \"name\": \"app\", // trailing\n  \"deps\": {\n    \"a\": \"1.0\"\n  },\n  \"list\": [1, 2],\n  \"empty\": {\n    \"x\": {\n      \"y\": true\n    }\n  }\n}\n"},
+		{"delete keeps comments", "name", "", "{\n  // comment\n  \"deps\": {\n    \"a\": \"1.0\"\n  },\n  \"list\": [1, 2],\n  \"empty\": {}\n}\n"},
+		{"delete last key", "empty", "", "{\n  // comment\n  \"name\": \"app\", // trailing\n  \"deps\": {\n    \"a\": \"1.0\"\n  },\n  \"list\": [1, 2]\n}\n"},
+		{"delete only key", "deps.a", "", "{\n  // comment\n  \"name\": \"app\", // trailing\n  \"deps\": {},\n  \"list\": [1, 2],\n  \"empty\": {}\n}\n"},
+		{"delete from one-line array", "list[0]", "", "{\n  // comment\n  \"name\": \"app\", // trailing\n  \"deps\": {\n    \"a\": \"1.0\"\n  },\n  \"list\": [2],\n  \"empty\": {}\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := editConfig(t, editJSONConfig, src, tt.key, tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("layouts", func(t *testing.T) {
+		got, err := editConfig(t, editJSONConfig, "{\n\t\"a\": 1,\n}\n", "b", `{"c": [1]}`)
+		require.NoError(t, err)
+		assert.Equal(t, "{\n\t\"a\": 1,\n\t\"b\": {\n\t\t\"c\": [\n\t\t\t1\n\t\t]\n\t},\n}\n", got)
+
+		got, err = editConfig(t, editJSONConfig, `{"a": {"b": 1, "c": 2}}`, "a.b", `{"x": 1}`)
+		require.NoError(t, err)
+		assert.Equal(t, `{"a": {"b": {"x": 1}, "c": 2}}`, got)
+
+		got, err = editConfig(t, editJSONConfig, "", "a", `1`)
+		require.NoError(t, err)
+		assert.Equal(t, "{\n  \"a\": 1\n}\n", got)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := editConfig(t, editJSONConfig, "[1, 2]", "a", `1`)
+		assert.ErrorContains(t, err, "the document is an array, not an object")
+		_, err = editConfig(t, editJSONConfig, src, "list[5]", `1`)
+		assert.ErrorContains(t, err, "the array has 2 items")
+		_, err = editConfig(t, editJSONConfig, src, "missing", "")
+		assert.ErrorContains(t, err, "missing not found")
+		_, err = editConfig(t, editJSONConfig, `{"a": }`, "a", `1`)
+		assert.ErrorContains(t, err, "Cannot parse the file as JSON")
+	})
+}
+
+func TestEditYAMLConfig(t *testing.T) {
+	src := "# top\nname: app # the name\nserver:\n  port: 8080\n  hosts:\n  - a\n  - b\n\n# about list\nlist:\n  - name: x\n    v: 1\n  - name: y\nempty:\n"
+	tests := []struct {
+		name, key, value, want string
+	}{
+		{"replace keeps comment", "name", `"my app: v2"`, "# top\nname: 'my app: v2' # the name\nserver:\n  port: 8080\n  hosts:\n  - a\n  - b\n\n# about list\nlist:\n  - name: x\n    v: 1\n  - name: y\nempty:\n"},
+		{"append to indentless list", "server.hosts[2]", `"c"`, "# top\nname: app # the name\nserver:\n  port: 8080\n  hosts:\n  - a\n  - b\n  - c\n\n# about list\nlist:\n  - name: x\n    v: 1\n  - name: y\nempty:\n"},
+		{"add mapping", "server.tls", `{"enabled": true, "cert": "x.pem"}`, "# top\nname: app # the name\nserver:\n  port: 8080\n  hosts:\n  - a\n  - b\n  tls:\n    enabled: true\n    cert: x.pem\n\n# about list\nlist:\n  - name: x\n    v: 1\n  - name: y\nempty:\n"},
+		{"replace block with scalar", "server", `"none"`, "# top\nname: app # the name\nserver: none\n\n# about list\nlist:\n  - name: x\n    v: 1\n  - name: y\nempty:\n"},
+		{"multi-line string", "server.port", `"line1\nline2"`, "# top\nname: app # the name\nserver:\n  port: |-\n    line1\n    line2\n  hosts:\n  - a\n  - b\n\n# about list\nlist:\n  - name: x\n    v: 1\n  - name: y\nempty:\n"},
+		{"fill null", "empty.a", `1`, "# top\nname: app # the name\nserver:\n  port: 8080\n  hosts:\n  - a\n  - b\n\n# about list\nlist:\n  - name: x\n    v: 1\n  - name: y\nempty:\n  a: 1\n"},
+		{"quote YAML 1.1 booleans", "name", `"yes"`, "# top\nname: \"yes\" # the name\nserver:\n  port: 8080\n  hosts:\n  - a\n  - b\n\n# about list\nlist:\n  - name: x\n    v: 1\n  - name: y\nempty:\n"},
+		{"delete list", "server.hosts", "", "# top\nname: app # the name\nserver:\n  port: 8080\n\n# about list\nlist:\n  - name: x\n    v: 1\n  - name: y\nempty:\n"},
+		{"delete first key of item", "list[0].name", "", "# top\nname: app # the name\nserver:\n  port: 8080\n  hosts:\n  - a\n  - b\n\n# about list\nlist:\n  - v: 1\n  - name: y\nempty:\n"},
+		{"delete only key of item", "list[1].name", "", "# top\nname: app # the name\nserver:\n  port: 8080\n  hosts:\n  - a\n  - b\n\n# about list\nlist:\n  - name: x\n    v: 1\n  - {}\nempty:\n"},
+		{"delete item", "list[0]", "", "# top\nname: app # the name\nserver:\n  port: 8080\n  hosts:\n  - a\n  - b\n\n# about list\nlist:\n  - name: y\nempty:\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := editConfig(t, editYAMLConfig, src, tt.key, tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("layouts", func(t *testing.T) {
+		got, err := editConfig(t, editYAMLConfig, "a: {x: 1}\n", "a", `{"y": 2}`)
+		require.NoError(t, err)
+		assert.Equal(t, "a:\n  y: 2\n", got)
+
+		got, err = editConfig(t, editYAMLConfig, "a: \"quoted # not a comment\" # real\n", "a", `5`)
+		require.NoError(t, err)
+		assert.Equal(t, "a: 5 # real\n", got)
+
+		got, err = editConfig(t, editYAMLConfig, "- a\n- b\n", "[2]", `{"x": 1}`)
+		require.NoError(t, err)
+		assert.Equal(t, "- a\n- b\n- x: 1\n", got)
+
+		got, err = editConfig(t, editYAMLConfig, "# only a comment\n", "a.b", `1`)
+		require.NoError(t, err)
+		assert.Equal(t, "# only a comment\na:\n  b: 1\n", got)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := editConfig(t, editYAMLConfig, "a: {x: 1}\n", "a.x", `2`)
+		assert.ErrorContains(t, err, "flow-style collection")
+		_, err = editConfig(t, editYAMLConfig, "a: &base 1\nb: *base\n", "b.c", `2`)
+		assert.ErrorContains(t, err, "alias")
+		_, err = editConfig(t, editYAMLConfig, "a: &base 1\nb: *base\n", "a", `2`)
+		assert.ErrorContains(t, err, "anchor &base")
+		_, err = editConfig(t, editYAMLConfig, "k: v\n---\nk: w\n", "k", `1`)
+		assert.ErrorContains(t, err, "more than one document")
+		_, err = editConfig(t, editYAMLConfig, src, "server.port.x", `1`)
+		assert.ErrorContains(t, err, "server.port is a scalar, not a mapping")
+	})
+}
+
+func TestEditTOMLConfig(t *testing.T) {
+	src := "# Cargo\n[package]\nname = \"app\" # name\nversion = \"0.1.0\"\n\n[dependencies]\nserde = { version = \"1\", features = [\"derive\"] }\ntokio = \"1\"\n\n[[bin]]\nname = \"a\"\n\n[[bin]]\nname = \"b\"\n"
+	tests := []struct {
+		name, key, value, want string
+	}{
+		{"replace", "package.version", `"0.2.0"`, "# Cargo\n[package]\nname = \"app\" # name\nversion = \"0.2.0\"\n\n[dependencies]\nserde = { version = \"1\", features = [\"derive\"] }\ntokio = \"1\"\n\n[[bin]]\nname = \"a\"\n\n[[bin]]\nname = \"b\"\n"},
+		{"add key", "dependencies.rand", `{"version": "0.8", "default-features": false}`, "# Cargo\n[package]\nname = \"app\" # name\nversion = \"0.1.0\"\n\n[dependencies]\nserde = { version = \"1\", features = [\"derive\"] }\ntokio = \"1\"\nrand = { version = \"0.8\", default-features = false }\n\n[[bin]]\nname = \"a\"\n\n[[bin]]\nname = \"b\"\n"},
+		{"array of tables", "bin[1].path", `"src/b.rs"`, "# Cargo\n[package]\nname = \"app\" # name\nversion = \"0.1.0\"\n\n[dependencies]\nserde = { version = \"1\", features = [\"derive\"] }\ntokio = \"1\"\n\n[[bin]]\nname = \"a\"\n\n[[bin]]\nname = \"b\"\npath = \"src/b.rs\"\n"},
+		{"new table", "dev-dependencies.x", `"1"`, "# Cargo\n[package]\nname = \"app\" # name\nversion = \"0.1.0\"\n\n[dependencies]\nserde = { version = \"1\", features = [\"derive\"] }\ntokio = \"1\"\n\n[[bin]]\nname = \"a\"\n\n[[bin]]\nname = \"b\"\n\n[dev-dependencies]\nx = \"1\"\n"},
+		{"top-level key", "top", `1`, "# Cargo\ntop = 1\n\n[package]\nname = \"app\" # name\nversion = \"0.1.0\"\n\n[dependencies]\nserde = { version = \"1\", features = [\"derive\"] }\ntokio = \"1\"\n\n[[bin]]\nname = \"a\"\n\n[[bin]]\nname = \"b\"\n"},
+		{"delete key", "dependencies.tokio", "", "# Cargo\n[package]\nname = \"app\" # name\nversion = \"0.1.0\"\n\n[dependencies]\nserde = { version = \"1\", features = [\"derive\"] }\n\n[[bin]]\nname = \"a\"\n\n[[bin]]\nname = \"b\"\n"},
+		{"delete table", "dependencies", "", "# Cargo\n[package]\nname = \"app\" # name\nversion = \"0.1.0\"\n\n[[bin]]\nname = \"a\"\n\n[[bin]]\nname = \"b\"\n"},
+		{"delete array table", "bin[0]", "", "# Cargo\n[package]\nname = \"app\" # name\nversion = \"0.1.0\"\n\n[dependencies]\nserde = { version = \"1\", features = [\"derive\"] }\ntokio = \"1\"\n\n[[bin]]\nname = \"b\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := editConfig(t, editTOMLConfig, src, tt.key, tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("layouts", func(t *testing.T) {
+		got, err := editConfig(t, editTOMLConfig, "[t]\nx.y = 1\n", "t.x.z", `2`)
+		require.NoError(t, err)
+		assert.Equal(t, "[t]\nx.y = 1\nx.z = 2\n", got)
+
+		got, err = editConfig(t, editTOMLConfig, "d = 1979-05-27 07:32:00Z # c\n", "d", `"now"`)
+		require.NoError(t, err)
+		assert.Equal(t, "d = \"now\" # c\n", got)
+
+		got, err = editConfig(t, editTOMLConfig, "s = \"\"\"\nmulti\n\"\"\"\nn = 1\n", "s", "")
+		require.NoError(t, err)
+		assert.Equal(t, "n = 1\n", got)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := editConfig(t, editTOMLConfig, src, "dependencies.serde.version", `"2"`)
+		assert.ErrorContains(t, err, "set dependencies.serde as a whole")
+		_, err = editConfig(t, editTOMLConfig, src, "package", `1`)
+		assert.ErrorContains(t, err, "package is a table")
+		_, err = editConfig(t, editTOMLConfig, src, "package.x", `null`)
+		assert.ErrorContains(t, err, "TOML has no null")
+		_, err = editConfig(t, editTOMLConfig, src, "bin[2].name", `"c"`)
+		assert.ErrorContains(t, err, "cannot add bin[2]")
+		_, err = editConfig(t, editTOMLConfig, "a = \n", "a", `1`)
+		assert.ErrorContains(t, err, "Cannot parse the file as TOML")
+	})
+}
+
+func TestConfigEdit(t *testing.T) {
+	state := NewState()
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "settings.json")
+	require.NoError(t, os.WriteFile(path, []byte("{\r\n  \"a\": 1\r\n}"), 0o644))
+
+	result, err := state.executeConfigEdit(ctx, path, "b", `"x"`, false, true, false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "Set b would change")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\r\n  \"a\": 1\r\n}", string(content))
+
+	// Line endings and the missing final newline are kept.
+	result, err = state.executeConfigEdit(ctx, path, "b", `"x"`, false, false, false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "Set b in "+path)
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\r\n  \"a\": 1,\r\n  \"b\": \"x\"\r\n}", string(content))
+
+	result, err = state.executeConfigEdit(ctx, path, "b", `"x"`, false, false, false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "nothing to change")
+
+	result, err = state.executeConfigEdit(ctx, path, "a", "", true, false, false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "Deleted a in "+path)
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\r\n  \"b\": \"x\"\r\n}", string(content))
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := state.executeConfigEdit(ctx, path, "a", "", false, false, false)
+		assert.ErrorContains(t, err, "value is required")
+		_, err = state.executeConfigEdit(ctx, path, "a", "1", true, false, false)
+		assert.ErrorContains(t, err, "cannot be combined with delete")
+		_, err = state.executeConfigEdit(ctx, path, "a", "bare words", false, false, false)
+		assert.ErrorContains(t, err, "value must be JSON")
+		_, err = state.executeConfigEdit(ctx, filepath.Join(dir, "notes.ini"), "a", "1", false, false, false)
+		assert.ErrorContains(t, err, "not notes.ini")
+		_, err = state.executeConfigEdit(ctx, filepath.Join(dir, "missing.yaml"), "a", "1", false, false, false)
+		assert.ErrorContains(t, err, "does not exist")
+	})
+}
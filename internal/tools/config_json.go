@@ -0,0 +1,375 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonNode is a value in a JSON document with its position, so that config_edit can change it in
+// place. Objects record their members and arrays their items.
+type jsonNode struct {
+	start, end int
+	kind       byte // '{', '[', or 0 for a scalar
+	members    []jsonMember
+	items      []*jsonNode
+}
+
+type jsonMember struct {
+	key      string
+	keyStart int
+	value    *jsonNode
+}
+
+// jsonScanner parses JSON leniently enough for the config files people write by hand: comments
+// and trailing commas (JSONC, as in tsconfig.json and VS Code settings) are accepted.
+type jsonScanner struct {
+	src string
+	pos int
+}
+
+func parseJSONConfig(src string) (*jsonNode, error) {
+	p := &jsonScanner{src: src}
+	p.skip()
+	if p.pos == len(src) {
+		return nil, nil
+	}
+	node, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	p.skip()
+	if p.pos < len(src) {
+		return nil, p.errorf("unexpected %q after the document", src[p.pos])
+	}
+	return node, nil
+}
+
+func (p *jsonScanner) errorf(format string, args ...any) error {
+	line := strings.Count(p.src[:p.pos], "\n") + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// skip moves past whitespace and comments.
+func (p *jsonScanner) skip() {
+	for p.pos < len(p.src) {
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])):
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "//"):
+			p.pos = lineEndOf(p.src, p.pos)
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			end := strings.Index(p.src[p.pos+2:], "*/")
+			if end < 0 {
+				p.pos = len(p.src)
+				return
+			}
+			p.pos += end + 4
+		default:
+			return
+		}
+	}
+}
+
+func (p *jsonScanner) value() (*jsonNode, error) {
+	if p.pos == len(p.src) {
+		return nil, p.errorf("unexpected end of file")
+	}
+	node := &jsonNode{start: p.pos}
+	switch c := p.src[p.pos]; c {
+	case '{', '[':
+		node.kind = c
+		close := byte('}')
+		if c == '[' {
+			close = ']'
+		}
+		p.pos++
+		for {
+			p.skip()
+			if p.pos < len(p.src) && p.src[p.pos] == close {
+				p.pos++
+				break
+			}
+			if c == '{' {
+				keyStart := p.pos
+				key, err := p.string()
+				if err != nil {
+					return nil, err
+				}
+				p.skip()
+				if p.pos == len(p.src) || p.src[p.pos] != ':' {
+					return nil, p.errorf("expected : after key %s", jsonString(key))
+				}
+				p.pos++
+				p.skip()
+				value, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				node.members = append(node.members, jsonMember{key: key, keyStart: keyStart, value: value})
+			} else {
+				item, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				node.items = append(node.items, item)
+			}
+			p.skip()
+			if p.pos < len(p.src) && p.src[p.pos] == ',' {
+				p.pos++
+			} else if p.pos == len(p.src) || p.src[p.pos] != close {
+				return nil, p.errorf("expected , or %c", close)
+			}
+		}
+	case '"':
+		if _, err := p.string(); err != nil {
+			return nil, err
+		}
+	default:
+		for p.pos < len(p.src) && !strings.ContainsRune(" \t\r\n,:]}/", rune(p.src[p.pos])) {
+			p.pos++
+		}
+		literal := p.src[node.start:p.pos]
+		if literal == "" || !json.Valid([]byte(literal)) {
+			return nil, p.errorf("invalid value %q", literal)
+		}
+	}
+	node.end = p.pos
+	return node, nil
+}
+
+func (p *jsonScanner) string() (string, error) {
+	if p.pos == len(p.src) || p.src[p.pos] != '"' {
+		return "", p.errorf("expected a string")
+	}
+	start := p.pos
+	for p.pos++; p.pos < len(p.src) && p.src[p.pos] != '"'; p.pos++ {
+		if p.src[p.pos] == '\\' {
+			p.pos++
+		} else if p.src[p.pos] == '\n' {
+			break
+		}
+	}
+	if p.pos >= len(p.src) || p.src[p.pos] != '"' {
+		return "", p.errorf("unterminated string")
+	}
+	p.pos++
+	var s string
+	if err := json.Unmarshal([]byte(p.src[start:p.pos]), &s); err != nil {
+		return "", p.errorf("invalid string %s", p.src[start:p.pos])
+	}
+	return s, nil
+}
+
+func (n *jsonNode) kindName() string {
+	switch n.kind {
+	case '{':
+		return "an object"
+	case '[':
+		return "an array"
+	}
+	return "a scalar"
+}
+
+// editJSONConfig sets path to value in a JSON document, or deletes it when value is nil.
+func editJSONConfig(content string, path []configKey, value *configValue) (string, error) {
+	root, err := parseJSONConfig(content)
+	if err != nil {
+		return "", fmt.Errorf("Cannot parse the file as JSON: %s", err)
+	}
+	unit := detectIndentUnit(content, "  ")
+	if root == nil {
+		if value == nil {
+			return "", fmt.Errorf("%s not found: the file is empty", configPathString(path))
+		}
+		nested, err := nestConfigValue(path, value)
+		if err != nil {
+			return "", err
+		}
+		return content + nested.json("", unit, false) + "\n", nil
+	}
+
+	var parent *jsonNode
+	node := root
+	for i, k := range path {
+		last := i == len(path)-1
+		if k.isIndex {
+			if node.kind != '[' {
+				return "", fmt.Errorf("%s is %s, not an array", configPathName(path[:i]), node.kindName())
+			}
+			if k.index < len(node.items) {
+				if last && value == nil {
+					return deleteJSONEntry(content, node, k.index), nil
+				}
+				parent, node = node, node.items[k.index]
+				continue
+			}
+			if value == nil || k.index > len(node.items) {
+				return "", fmt.Errorf("%s not found: the array has %d items", configPathString(path[:i+1]), len(node.items))
+			}
+			nested, err := nestConfigValue(path[i+1:], value)
+			if err != nil {
+				return "", err
+			}
+			return insertJSONEntry(content, node, "", nested, unit), nil
+		}
+
+		if node.kind != '{' {
+			return "", fmt.Errorf("%s is %s, not an object", configPathName(path[:i]), node.kindName())
+		}
+		found := -1
+		for j, m := range node.members {
+			if m.key == k.key {
+				found = j
+			}
+		}
+		if found < 0 {
+			if value == nil {
+				return "", fmt.Errorf("%s not found", configPathString(path[:i+1]))
+			}
+			nested, err := nestConfigValue(path[i+1:], value)
+			if err != nil {
+				return "", err
+			}
+			return insertJSONEntry(content, node, jsonString(k.key)+": ", nested, unit), nil
+		}
+		if last && value == nil {
+			return deleteJSONEntry(content, node, found), nil
+		}
+		parent, node = node, node.members[found].value
+	}
+
+	// Replace the value in place, indented below the line it starts on. Values inside a container
+	// written on one line, or replacing one, stay on one line.
+	compact := parent != nil && !jsonMultiline(content, parent) || jsonEntryCount(node) > 0 && !jsonMultiline(content, node)
+	prefix := leadingWhitespace(content[lineStartOf(content, node.start):])
+	return content[:node.start] + value.json(prefix, unit, compact) + content[node.end:], nil
+}
+
+// jsonMultiline reports whether the entries of container are on lines of their own.
+func jsonMultiline(content string, container *jsonNode) bool {
+	return jsonEntryCount(container) > 0 && strings.Contains(content[container.start:jsonEntryStart(container, 0)], "\n")
+}
+
+// jsonEntryStart returns where member or item i of container starts.
+func jsonEntryStart(container *jsonNode, i int) int {
+	if container.kind == '{' {
+		return container.members[i].keyStart
+	}
+	return container.items[i].start
+}
+
+func jsonEntryValue(container *jsonNode, i int) *jsonNode {
+	if container.kind == '{' {
+		return container.members[i].value
+	}
+	return container.items[i]
+}
+
+func jsonEntryCount(container *jsonNode) int {
+	if container.kind == '{' {
+		return len(container.members)
+	}
+	return len(container.items)
+}
+
+// insertJSONEntry adds an entry, the member key prefix (empty for arrays) followed by value, at the
+// end of container. Multi-line containers get the entry on its own line, indented like the
+// entries before it; containers written on one line keep it on that line.
+func insertJSONEntry(content string, container *jsonNode, key string, value *configValue, unit string) string {
+	containerIndent := leadingWhitespace(content[lineStartOf(content, container.start):])
+	n := jsonEntryCount(container)
+	if n == 0 {
+		inner := content[container.start+1 : container.end-1]
+		if !strings.Contains(inner, "\n") && strings.TrimSpace(inner) == "" && value.kind != "object" && value.kind != "array" {
+			return content[:container.start+1] + key + value.json("", unit, true) + content[container.end-1:]
+		}
+		entryIndent := containerIndent + unit
+		entry := "\n" + entryIndent + key + value.json(entryIndent, unit, false) + "\n" + containerIndent
+		return content[:container.start+1] + entry + content[container.end-1:]
+	}
+
+	lastStart := jsonEntryStart(container, n-1)
+	lastEnd := jsonEntryValue(container, n-1).end
+	if !jsonMultiline(content, container) {
+		return content[:lastEnd] + ", " + key + value.json("", unit, true) + content[lastEnd:]
+	}
+
+	// A trailing comma may already follow the last entry; otherwise one is added after it. The new
+	// entry goes after the rest of that line, keeping a trailing comment where it was.
+	entryIndent := leadingWhitespace(content[lineStartOf(content, lastStart):])
+	entry := entryIndent + key + value.json(entryIndent, unit, false)
+	p := &jsonScanner{src: content, pos: lastEnd}
+	p.skip()
+	hasTrailingComma := p.pos < len(content) && content[p.pos] == ','
+	if hasTrailingComma {
+		entry += ","
+	}
+	comma := lastEnd
+	if hasTrailingComma {
+		comma = p.pos + 1
+	}
+	rest := content[comma:lineEndOf(content, comma)]
+	trimmed := strings.TrimSpace(rest)
+	if trimmed == "" || (strings.HasPrefix(trimmed, "//") && strings.HasSuffix(rest, "\n")) {
+		insertAt := comma + len(rest)
+		before := content[:comma]
+		if !hasTrailingComma {
+			before += ","
+		}
+		if !strings.HasSuffix(rest, "\n") {
+			entry = "\n" + entry
+		} else {
+			entry += "\n"
+		}
+		return before + content[comma:insertAt] + entry + content[insertAt:]
+	}
+	// Something else follows on the same line, such as the closing bracket.
+	if hasTrailingComma {
+		return content[:comma] + "\n" + entry + content[comma:]
+	}
+	return content[:lastEnd] + ",\n" + strings.TrimSuffix(entry, ",") + content[lastEnd:]
+}
+
+// deleteJSONEntry removes member or item i of container together with its comma. An entry on
+// lines of its own takes those lines with it.
+func deleteJSONEntry(content string, container *jsonNode, i int) string {
+	n := jsonEntryCount(container)
+	if n == 1 {
+		return content[:container.start+1] + content[container.end-1:]
+	}
+	start := jsonEntryStart(container, i)
+	end := jsonEntryValue(container, i).end
+	p := &jsonScanner{src: content, pos: end}
+	p.skip()
+	hasComma := p.pos < len(content) && content[p.pos] == ','
+	ownLine := strings.TrimSpace(content[lineStartOf(content, start):start]) == ""
+
+	if i == n-1 && !hasComma {
+		// The last entry: drop the comma before it instead of one after it.
+		prevEnd := jsonEntryValue(container, i-1).end
+		if !ownLine {
+			return content[:prevEnd] + content[end:]
+		}
+		q := &jsonScanner{src: content, pos: prevEnd}
+		q.skip()
+		content = content[:q.pos] + content[q.pos+1:]
+		start--
+		end--
+	} else if hasComma {
+		end = p.pos + 1
+	}
+
+	if !ownLine {
+		for end < len(content) && content[end] == ' ' {
+			end++
+		}
+		return content[:start] + content[end:]
+	}
+	rest := content[end:lineEndOf(content, end)]
+	if trimmed := strings.TrimSpace(rest); trimmed == "" || strings.HasPrefix(trimmed, "//") {
+		end += len(rest)
+		start = lineStartOf(content, start)
+	}
+	return content[:start] + content[end:]
+}
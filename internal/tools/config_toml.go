@@ -0,0 +1,468 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// tomlEntry is a key/value line or a table header in a TOML document.
+type tomlEntry struct {
+	path []configKey
+	// start and end span the entry's lines; valueStart and valueEnd its value.
+	start, end           int
+	valueStart, valueEnd int
+	header               bool
+	// tableLen is the length of the path of the table a key/value line belongs to; sectionEnd is
+	// where a header's section ends, at the next header.
+	tableLen   int
+	sectionEnd int
+}
+
+var (
+	tomlBareKey   = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	tomlLocalDate = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+type tomlScanner struct {
+	src string
+	pos int
+}
+
+func (p *tomlScanner) errorf(format string, args ...any) error {
+	line := strings.Count(p.src[:min(p.pos, len(p.src))], "\n") + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlScanner) skipSpaces() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// skipLineEnd moves past trailing spaces, a comment, and the newline ending a line.
+func (p *tomlScanner) skipLineEnd() error {
+	p.skipSpaces()
+	if p.pos < len(p.src) && p.src[p.pos] == '#' {
+		p.pos = lineEndOf(p.src, p.pos)
+		return nil
+	}
+	if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+		p.pos++
+	}
+	if p.pos < len(p.src) && p.src[p.pos] != '\n' {
+		return p.errorf("unexpected %q after value", p.src[p.pos])
+	}
+	p.pos = lineEndOf(p.src, p.pos)
+	return nil
+}
+
+// skipBlank moves past whitespace, newlines, and comments inside arrays and inline tables.
+func (p *tomlScanner) skipBlank() {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+		case '#':
+			p.pos = lineEndOf(p.src, p.pos)
+		default:
+			return
+		}
+	}
+}
+
+// keys parses a dotted key such as a."b.c".d.
+func (p *tomlScanner) keys() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpaces()
+		if p.pos == len(p.src) {
+			return nil, p.errorf("expected a key")
+		}
+		switch p.src[p.pos] {
+		case '"', '\'':
+			start := p.pos
+			end, err := p.stringEnd()
+			if err != nil {
+				return nil, err
+			}
+			key := p.src[start+1 : end-1]
+			if p.src[start] == '"' {
+				if err := json.Unmarshal([]byte(p.src[start:end]), &key); err != nil {
+					return nil, p.errorf("invalid key %s", p.src[start:end])
+				}
+			}
+			keys = append(keys, key)
+			p.pos = end
+		default:
+			start := p.pos
+			for p.pos < len(p.src) && tomlBareKey.MatchString(p.src[p.pos:p.pos+1]) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("expected a key")
+			}
+			keys = append(keys, p.src[start:p.pos])
+		}
+		p.skipSpaces()
+		if p.pos == len(p.src) || p.src[p.pos] != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+// stringEnd returns the offset just past the string starting at p.pos, without moving.
+func (p *tomlScanner) stringEnd() (int, error) {
+	quote := p.src[p.pos]
+	if triple := strings.Repeat(string(quote), 3); strings.HasPrefix(p.src[p.pos:], triple) {
+		for i := p.pos + 3; i < len(p.src); i++ {
+			if quote == '"' && p.src[i] == '\\' {
+				i++
+			} else if strings.HasPrefix(p.src[i:], triple) {
+				// Up to two more quotes may end the string's content.
+				end := i + 3
+				for n := 0; n < 2 && end < len(p.src) && p.src[end] == quote; n++ {
+					end++
+				}
+				return end, nil
+			}
+		}
+		return 0, p.errorf("unterminated multi-line string")
+	}
+	for i := p.pos + 1; i < len(p.src) && p.src[i] != '\n'; i++ {
+		if quote == '"' && p.src[i] == '\\' {
+			i++
+		} else if p.src[i] == quote {
+			return i + 1, nil
+		}
+	}
+	return 0, p.errorf("unterminated string")
+}
+
+// value moves past the value starting at p.pos.
+func (p *tomlScanner) value() error {
+	if p.pos == len(p.src) {
+		return p.errorf("expected a value")
+	}
+	switch c := p.src[p.pos]; c {
+	case '"', '\'':
+		end, err := p.stringEnd()
+		if err != nil {
+			return err
+		}
+		p.pos = end
+	case '[', '{':
+		close := byte(']')
+		if c == '{' {
+			close = '}'
+		}
+		p.pos++
+		for {
+			p.skipBlank()
+			if p.pos < len(p.src) && p.src[p.pos] == close {
+				p.pos++
+				return nil
+			}
+			if c == '{' {
+				if _, err := p.keys(); err != nil {
+					return err
+				}
+				if p.pos == len(p.src) || p.src[p.pos] != '=' {
+					return p.errorf("expected = after key")
+				}
+				p.pos++
+				p.skipSpaces()
+			}
+			if err := p.value(); err != nil {
+				return err
+			}
+			p.skipBlank()
+			if p.pos < len(p.src) && p.src[p.pos] == ',' {
+				p.pos++
+			} else if p.pos == len(p.src) || p.src[p.pos] != close {
+				return p.errorf("expected , or %c", close)
+			}
+		}
+	default:
+		start := p.pos
+		for p.pos < len(p.src) && !strings.ContainsRune(" \t\r\n,]}#", rune(p.src[p.pos])) {
+			p.pos++
+		}
+		// A date and time may be separated by a space: 1979-05-27 07:32:00Z.
+		if tomlLocalDate.MatchString(p.src[start:p.pos]) && len(p.src) > p.pos+3 && p.src[p.pos] == ' ' && p.src[p.pos+3] == ':' {
+			for p.pos++; p.pos < len(p.src) && !strings.ContainsRune(" \t\r\n,]}#", rune(p.src[p.pos])); p.pos++ {
+			}
+		}
+		if start == p.pos {
+			return p.errorf("expected a value")
+		}
+	}
+	return nil
+}
+
+// parseTOMLConfig lists the key/value lines and table headers of a TOML document. Arrays of tables
+// ([[name]]) are numbered, so their tables have paths like name[0].key.
+func parseTOMLConfig(src string) ([]*tomlEntry, error) {
+	p := &tomlScanner{src: src}
+	var entries []*tomlEntry
+	var table []configKey
+	arrayTables := map[string]int{}
+	// tablePath resolves the keys of a header, numbering the arrays of tables among its parents.
+	tablePath := func(keys []string) []configKey {
+		var path []configKey
+		for _, key := range keys {
+			path = append(path, configKey{key: key})
+			if n, ok := arrayTables[configPathString(path)]; ok {
+				path = append(path, configKey{index: n - 1, isIndex: true})
+			}
+		}
+		return path
+	}
+
+	for p.pos < len(src) {
+		start := p.pos
+		p.skipSpaces()
+		if p.pos == len(src) {
+			break
+		}
+		switch src[p.pos] {
+		case '\n', '\r', '#':
+			if err := p.skipLineEnd(); err != nil {
+				return nil, err
+			}
+			continue
+		case '[':
+			double := strings.HasPrefix(src[p.pos:], "[[")
+			p.pos++
+			if double {
+				p.pos++
+			}
+			keys, err := p.keys()
+			if err != nil {
+				return nil, err
+			}
+			closing := "]"
+			if double {
+				closing = "]]"
+			}
+			if !strings.HasPrefix(src[p.pos:], closing) {
+				return nil, p.errorf("expected %s", closing)
+			}
+			p.pos += len(closing)
+			if double {
+				parent := tablePath(keys[:len(keys)-1])
+				name := configPathString(append(parent, configKey{key: keys[len(keys)-1]}))
+				arrayTables[name]++
+			}
+			table = tablePath(keys)
+			entries = append(entries, &tomlEntry{path: table, start: start, header: true})
+		default:
+			keys, err := p.keys()
+			if err != nil {
+				return nil, err
+			}
+			if p.pos == len(src) || src[p.pos] != '=' {
+				return nil, p.errorf("expected = after key")
+			}
+			p.pos++
+			p.skipSpaces()
+			entry := &tomlEntry{path: slices.Clone(table), start: start, valueStart: p.pos, tableLen: len(table)}
+			for _, key := range keys {
+				entry.path = append(entry.path, configKey{key: key})
+			}
+			if err := p.value(); err != nil {
+				return nil, err
+			}
+			entry.valueEnd = p.pos
+			entries = append(entries, entry)
+		}
+		if err := p.skipLineEnd(); err != nil {
+			return nil, err
+		}
+		entries[len(entries)-1].end = p.pos
+	}
+
+	sectionEnd := len(src)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].header {
+			entries[i].sectionEnd = sectionEnd
+			sectionEnd = entries[i].start
+		}
+	}
+	return entries, nil
+}
+
+func configPathHasPrefix(path, prefix []configKey) bool {
+	return len(path) >= len(prefix) && slices.Equal(path[:len(prefix)], prefix)
+}
+
+// editTOMLConfig sets path to value in a TOML document, or deletes it when value is nil.
+func editTOMLConfig(content string, path []configKey, value *configValue) (string, error) {
+	entries, err := parseTOMLConfig(content)
+	if err != nil {
+		return "", fmt.Errorf("Cannot parse the file as TOML: %s", err)
+	}
+	name := configPathString(path)
+	var inside []*tomlEntry
+	for _, e := range entries {
+		switch {
+		case !e.header && slices.Equal(e.path, path):
+			if value == nil {
+				return content[:e.start] + content[e.end:], nil
+			}
+			rendered, err := tomlValue(value)
+			if err != nil {
+				return "", err
+			}
+			return content[:e.valueStart] + rendered + content[e.valueEnd:], nil
+		case !e.header && configPathHasPrefix(path, e.path):
+			return "", fmt.Errorf("%s is inside the value of %s on line %d; set %s as a whole", name, configPathString(e.path), strings.Count(content[:e.start], "\n")+1, configPathString(e.path))
+		case configPathHasPrefix(e.path, path):
+			inside = append(inside, e)
+		}
+	}
+
+	if len(inside) > 0 {
+		if value != nil {
+			return "", fmt.Errorf("%s is a table; set its keys one at a time", name)
+		}
+		// Delete the table's sections and any of its keys set from other tables. Entries are in file
+		// order, so a key inside a section being deleted starts before that section ends.
+		var b strings.Builder
+		last := 0
+		for _, e := range inside {
+			end := e.end
+			if e.header {
+				end = e.sectionEnd
+			}
+			if e.start >= last {
+				b.WriteString(content[last:e.start])
+			}
+			last = max(last, end)
+		}
+		b.WriteString(content[last:])
+		return b.String(), nil
+	}
+	if value == nil {
+		return "", fmt.Errorf("%s not found", name)
+	}
+	rendered, err := tomlValue(value)
+	if err != nil {
+		return "", err
+	}
+	isIndex := func(k configKey) bool { return k.isIndex }
+	if i := slices.IndexFunc(path, isIndex); i >= 0 && !slices.ContainsFunc(entries, func(e *tomlEntry) bool { return e.header && configPathHasPrefix(e.path, path[:i+1]) }) {
+		return "", fmt.Errorf("cannot add %s: arrays can only be set as a whole, and arrays of tables extended with Edit", configPathString(path[:i+1]))
+	}
+
+	// Add the key after its last sibling, written relative to that sibling's table.
+	parent := path[:len(path)-1]
+	var sibling *tomlEntry
+	for _, e := range entries {
+		if !e.header && slices.Equal(e.path[:len(e.path)-1], parent) {
+			sibling = e
+		}
+	}
+	if sibling != nil {
+		return insertTOMLLine(content, sibling.end, tomlKeyPath(path[sibling.tableLen:])+" = "+rendered), nil
+	}
+
+	// Otherwise add it to the innermost table holding it, after the table's keys.
+	var table *tomlEntry
+	for _, e := range entries {
+		if e.header && configPathHasPrefix(parent, e.path) && (table == nil || len(e.path) > len(table.path)) {
+			table = e
+		}
+	}
+	if table == nil && len(path) > 1 {
+		// A new table at the end of the file rather than a dotted key among the top-level keys.
+		text := "[" + tomlKeyPath(parent) + "]\n" + tomlKeyPath(path[len(parent):]) + " = " + rendered
+		if strings.TrimSpace(content) != "" {
+			text = "\n" + text
+		}
+		return insertTOMLLine(content, len(content), text), nil
+	}
+	sectionStart, sectionEnd, tableLen := 0, len(content), 0
+	if table != nil {
+		sectionStart, sectionEnd, tableLen = table.end, table.sectionEnd, len(table.path)
+	} else if i := slices.IndexFunc(entries, func(e *tomlEntry) bool { return e.header }); i >= 0 {
+		sectionEnd = entries[i].start
+	}
+	line := tomlKeyPath(path[tableLen:]) + " = " + rendered
+	at := -1
+	for _, e := range entries {
+		if !e.header && e.start >= sectionStart && e.end <= sectionEnd {
+			at = e.end
+		}
+	}
+	switch {
+	case at >= 0:
+	case table != nil:
+		at = table.end
+	case sectionEnd < len(content):
+		// The first top-level key goes before the first table.
+		return content[:sectionEnd] + line + "\n\n" + content[sectionEnd:], nil
+	default:
+		at = len(content)
+	}
+	return insertTOMLLine(content, at, line), nil
+}
+
+// insertTOMLLine inserts line at offset at, which is the start of a line or the end of content.
+func insertTOMLLine(content string, at int, line string) string {
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	if at > 0 && content[at-1] != '\n' {
+		line = "\n" + line
+	}
+	return content[:at] + line + content[at:]
+}
+
+func tomlKey(key string) string {
+	if tomlBareKey.MatchString(key) {
+		return key
+	}
+	return jsonString(key)
+}
+
+func tomlKeyPath(path []configKey) string {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		keys[i] = tomlKey(k.key)
+	}
+	return strings.Join(keys, ".")
+}
+
+// tomlValue renders value as TOML, with objects as inline tables.
+func tomlValue(value *configValue) (string, error) {
+	switch value.kind {
+	case "null":
+		return "", fmt.Errorf("TOML has no null; set delete to remove the key instead")
+	case "string":
+		return jsonString(value.scalar), nil
+	case "array", "object":
+		parts := make([]string, len(value.items))
+		for i, item := range value.items {
+			rendered, err := tomlValue(item)
+			if err != nil {
+				return "", err
+			}
+			if value.kind == "object" {
+				rendered = tomlKey(value.keys[i]) + " = " + rendered
+			}
+			parts[i] = rendered
+		}
+		if value.kind == "array" {
+			return "[" + strings.Join(parts, ", ") + "]", nil
+		}
+		if len(parts) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(parts, ", ") + " }", nil
+	}
+	return value.scalar, nil
+}
@@ -0,0 +1,434 @@
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlConfig locates nodes of a parsed YAML document in its source. yaml.v3 reports where a node
+// starts but not where it ends, so entries end where the indentation returns to their level.
+type yamlConfig struct {
+	src        string
+	lineStarts []int
+	unit       int
+}
+
+// yamlEntry is a mapping entry or sequence item: the way a value is held by its container.
+type yamlEntry struct {
+	container *yaml.Node
+	key       *yaml.Node // nil for sequence items
+	value     *yaml.Node
+}
+
+func parseYAMLConfig(src string) (*yaml.Node, error) {
+	dec := yaml.NewDecoder(strings.NewReader(src))
+	var doc yaml.Node
+	if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var next yaml.Node
+	if err := dec.Decode(&next); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("the file holds more than one document")
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return doc.Content[0], nil
+}
+
+// editYAMLConfig sets path to value in a YAML document, or deletes it when value is nil.
+func editYAMLConfig(content string, path []configKey, value *configValue) (string, error) {
+	root, err := parseYAMLConfig(content)
+	if err != nil {
+		return "", fmt.Errorf("Cannot parse the file as YAML: %s", err)
+	}
+	y := &yamlConfig{src: content, lineStarts: []int{0}, unit: len(detectIndentUnit(content, "  "))}
+	for i, c := range content {
+		if c == '\n' {
+			y.lineStarts = append(y.lineStarts, i+1)
+		}
+	}
+
+	if yamlIsEmpty(root) {
+		if value == nil {
+			return "", fmt.Errorf("%s not found: the document is empty", configPathString(path))
+		}
+		nested, err := nestConfigValue(path, value)
+		if err != nil {
+			return "", err
+		}
+		if root == nil || root.Kind != yaml.MappingNode && root.Kind != yaml.SequenceNode {
+			// Nothing but comments, or an explicit null: add the value after them.
+			if root != nil {
+				start := y.offset(root)
+				content = content[:start] + content[lineEndOf(content, start):]
+			}
+			if content != "" && !strings.HasSuffix(content, "\n") {
+				content += "\n"
+			}
+			return content + y.render(nested), nil
+		}
+		// An empty flow collection: {} or [].
+		start := y.offset(root)
+		end := yamlInlineEnd(content, start)
+		if end < 0 {
+			return "", fmt.Errorf("the document is an empty collection spanning several lines; use Edit")
+		}
+		return content[:start] + strings.TrimSuffix(y.render(nested), "\n") + content[end:], nil
+	}
+
+	// holder is the entry holding node, for emptying node when its last entry is deleted; the root
+	// has none.
+	var holder *yamlEntry
+	node := root
+	for i, k := range path {
+		last := i == len(path)-1
+		if err := yamlEditable(node, path[:i]); err != nil {
+			return "", err
+		}
+		if holder != nil && yamlIsEmpty(node) {
+			if value == nil {
+				return "", fmt.Errorf("%s not found: %s is empty", configPathString(path[:i+1]), configPathName(path[:i]))
+			}
+			nested, err := nestConfigValue(path[i:], value)
+			if err != nil {
+				return "", err
+			}
+			return y.replace(holder, nested), nil
+		}
+
+		var child *yamlEntry
+		if k.isIndex {
+			if node.Kind != yaml.SequenceNode {
+				return "", fmt.Errorf("%s is %s, not a list", configPathName(path[:i]), yamlKindName(node))
+			}
+			if k.index < len(node.Content) {
+				child = &yamlEntry{container: node, value: node.Content[k.index]}
+			} else if value == nil || k.index > len(node.Content) {
+				return "", fmt.Errorf("%s not found: the list has %d items", configPathString(path[:i+1]), len(node.Content))
+			}
+		} else {
+			if node.Kind != yaml.MappingNode {
+				return "", fmt.Errorf("%s is %s, not a mapping", configPathName(path[:i]), yamlKindName(node))
+			}
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				if node.Content[j].Value == k.key {
+					child = &yamlEntry{container: node, key: node.Content[j], value: node.Content[j+1]}
+				}
+			}
+			if child == nil && value == nil {
+				return "", fmt.Errorf("%s not found", configPathString(path[:i+1]))
+			}
+		}
+		if child == nil {
+			nested, err := nestConfigValue(path[i+1:], value)
+			if err != nil {
+				return "", err
+			}
+			return y.insert(node, k.key, nested), nil
+		}
+		if last {
+			if child.value.Anchor != "" {
+				return "", fmt.Errorf("%s defines the anchor &%s; edit it with Edit so the aliases to it stay intact", configPathString(path), child.value.Anchor)
+			}
+			if value == nil {
+				return y.delete(child, holder), nil
+			}
+			return y.replace(child, value), nil
+		}
+		holder = child
+		node = child.value
+	}
+	return "", nil
+}
+
+// yamlEditable reports why the entries of node cannot be edited in place, if they cannot.
+func yamlEditable(node *yaml.Node, path []configKey) error {
+	name := configPathName(path)
+	switch {
+	case node.Kind == yaml.AliasNode:
+		return fmt.Errorf("%s is an alias (*%s); edit the anchored value instead", name, node.Value)
+	case node.Style&yaml.FlowStyle != 0 && len(node.Content) > 0:
+		return fmt.Errorf("%s is a flow-style collection; set it as a whole", name)
+	}
+	return nil
+}
+
+func yamlIsEmpty(node *yaml.Node) bool {
+	return node == nil || node.Kind == yaml.ScalarNode && node.Tag == "!!null" ||
+		(node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode) && len(node.Content) == 0
+}
+
+func yamlKindName(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	return "a scalar"
+}
+
+// offset returns the byte offset of node in the source; yaml.v3 counts columns in characters.
+func (y *yamlConfig) offset(node *yaml.Node) int {
+	offset := y.lineStarts[node.Line-1]
+	for i := 1; i < node.Column && offset < len(y.src); i++ {
+		_, size := utf8.DecodeRuneInString(y.src[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// head returns the offset an entry starts at: its key, or the dash of a sequence item.
+func (y *yamlConfig) head(e *yamlEntry) int {
+	if e.key != nil {
+		return y.offset(e.key)
+	}
+	i := y.offset(e.value) - 1
+	for i >= 0 && strings.ContainsRune(" \t\n", rune(y.src[i])) {
+		i--
+	}
+	return max(i, 0)
+}
+
+// end returns the offset just past the last line of an entry: the lines after its head that are
+// indented deeper than it, and for a key holding a list written at the key's own indentation, the
+// items of that list. Trailing blank lines are left out.
+func (y *yamlConfig) end(e *yamlEntry) int {
+	head := y.head(e)
+	indent := head - lineStartOf(y.src, head)
+	dashes := e.key != nil && e.value.Kind == yaml.SequenceNode && e.value.Style&yaml.FlowStyle == 0 && e.value.Column-1 == indent
+	end := lineEndOf(y.src, head)
+	for start := end; start < len(y.src); {
+		lineEnd := lineEndOf(y.src, start)
+		line := y.src[start:lineEnd]
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" {
+			lineIndent := len(line) - len(strings.TrimLeft(line, " \t"))
+			if lineIndent < indent || lineIndent == indent && !(dashes && strings.HasPrefix(trimmed, "-")) {
+				break
+			}
+			end = lineEnd
+		}
+		start = lineEnd
+	}
+	return end
+}
+
+// render renders value as YAML at the left margin.
+func (y *yamlConfig) render(value *configValue) string {
+	return yamlEncode(yamlNode(value), y.unit)
+}
+
+func yamlEncode(node *yaml.Node, unit int) string {
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(unit)
+	_ = enc.Encode(node)
+	_ = enc.Close()
+	return b.String()
+}
+
+func yamlNode(value *configValue) *yaml.Node {
+	switch value.kind {
+	case "object":
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for i, key := range value.keys {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, yamlNode(value.items[i]))
+		}
+		return node
+	case "array":
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range value.items {
+			node.Content = append(node.Content, yamlNode(item))
+		}
+		return node
+	case "string":
+		node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value.scalar}
+		// yaml.v3 writes these plain, but YAML 1.1 parsers, still common, read them as booleans.
+		switch strings.ToLower(value.scalar) {
+		case "y", "yes", "n", "no", "on", "off":
+			node.Style = yaml.DoubleQuotedStyle
+		}
+		return node
+	case "number":
+		tag := "!!int"
+		if strings.ContainsAny(value.scalar, ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value.scalar}
+	case "bool":
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: value.scalar}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+}
+
+// renderEntry renders key: value, or a list item holding value when key is nil, indented so its
+// first line can follow prefix and the rest line up below it.
+func (y *yamlConfig) renderEntry(key *string, value *configValue, prefix string) string {
+	var node *yaml.Node
+	if key != nil {
+		node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: *key}, yamlNode(value),
+		}}
+	} else {
+		node = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{yamlNode(value)}}
+	}
+	lines := strings.SplitAfter(yamlEncode(node, y.unit), "\n")
+	indent := strings.Repeat(" ", utf8.RuneCountInString(prefix))
+	var b strings.Builder
+	for i, line := range lines {
+		switch {
+		case line == "":
+		case i == 0:
+			b.WriteString(prefix + line)
+		case line == "\n":
+			b.WriteString(line)
+		default:
+			b.WriteString(indent + line)
+		}
+	}
+	return b.String()
+}
+
+// replace sets the value of an entry. A scalar on the entry's own line is replaced where it stands,
+// keeping any comment after it; anything else re-renders the entry.
+func (y *yamlConfig) replace(e *yamlEntry, value *configValue) string {
+	head := y.head(e)
+	end := y.end(e)
+	inline := len(value.items) == 0
+	if item := yamlEncode(&yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{yamlNode(value)}}, y.unit); inline && strings.Count(item, "\n") == 1 && end == lineEndOf(y.src, head) {
+		start := y.offset(e.value)
+		if start > head && start < end {
+			if valueEnd := yamlInlineEnd(y.src, start); valueEnd >= 0 {
+				return y.src[:start] + strings.TrimPrefix(strings.TrimSuffix(item, "\n"), "- ") + y.src[valueEnd:]
+			}
+		}
+	}
+	var key *string
+	if e.key != nil {
+		key = &e.key.Value
+	}
+	// An entry last in a file without a final newline is replaced by one that has it; the caller
+	// restores the file's trailing newline.
+	prefix := y.src[lineStartOf(y.src, head):head]
+	return y.src[:head] + y.renderEntry(key, value, prefix)[len(prefix):] + y.src[end:]
+}
+
+// insert adds key: value (or a list item when container is a sequence) after the container's last
+// entry, indented like it.
+func (y *yamlConfig) insert(container *yaml.Node, key string, value *configValue) string {
+	last := &yamlEntry{container: container, value: container.Content[len(container.Content)-1]}
+	var keyPtr *string
+	if container.Kind == yaml.MappingNode {
+		last.key = container.Content[len(container.Content)-2]
+		keyPtr = &key
+	}
+	head := y.head(last)
+	end := y.end(last)
+	indent := strings.Repeat(" ", utf8.RuneCountInString(y.src[lineStartOf(y.src, head):head]))
+	entry := y.renderEntry(keyPtr, value, indent)
+	if end > 0 && y.src[end-1] != '\n' {
+		entry = "\n" + entry
+	}
+	return y.src[:end] + entry + y.src[end:]
+}
+
+// delete removes an entry. The last entry of a nested collection leaves it empty, as {} or [].
+func (y *yamlConfig) delete(e *yamlEntry, holder *yamlEntry) string {
+	head := y.head(e)
+	end := y.end(e)
+	pairs := len(e.container.Content)
+	if e.key != nil {
+		pairs /= 2
+	}
+	if pairs == 1 && holder != nil {
+		empty := &configValue{kind: "object"}
+		if e.container.Kind == yaml.SequenceNode {
+			empty.kind = "array"
+		}
+		return y.replace(holder, empty)
+	}
+	start := lineStartOf(y.src, head)
+	if strings.TrimSpace(y.src[start:head]) != "" && e.key != nil && e.key == e.container.Content[0] {
+		// The first key of a mapping that is a list item, as in "- name: x": the next key moves up
+		// to take its place after the dash.
+		next := &yamlEntry{container: e.container, key: e.container.Content[2], value: e.container.Content[3]}
+		return y.src[:head] + y.src[y.head(next):]
+	}
+	return y.src[:start] + y.src[end:]
+}
+
+// yamlInlineEnd returns where the scalar or flow collection starting at start ends, when it is
+// written on that one line; otherwise -1. A trailing comment is not part of it.
+func yamlInlineEnd(src string, start int) int {
+	line := src[start:lineEndOf(src, start)]
+	line = strings.TrimSuffix(line, "\n")
+	if line == "" {
+		return -1
+	}
+	end := -1
+	switch line[0] {
+	case '|', '>', '&', '*', '!':
+		return -1
+	case '"', '\'':
+		quote := line[0]
+		for i := 1; i < len(line); i++ {
+			if quote == '"' && line[i] == '\\' {
+				i++
+			} else if line[i] == quote {
+				if quote == '\'' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				end = i + 1
+				break
+			}
+		}
+	case '[', '{':
+		depth := 0
+		var quote byte
+		for i := 0; i < len(line) && end < 0; i++ {
+			switch c := line[i]; {
+			case quote != 0:
+				if c == '\\' && quote == '"' {
+					i++
+				} else if c == quote {
+					quote = 0
+				}
+			case c == '"' || c == '\'':
+				quote = c
+			case c == '[' || c == '{':
+				depth++
+			case c == ']' || c == '}':
+				if depth--; depth == 0 {
+					end = i + 1
+				}
+			}
+		}
+	default:
+		end = len(line)
+		if i := strings.Index(line, " #"); i >= 0 {
+			end = i
+		}
+		if i := strings.Index(line, "\t#"); i >= 0 && i < end {
+			end = i
+		}
+		end = len(strings.TrimRight(line[:end], " \t"))
+	}
+	if end < 0 {
+		return -1
+	}
+	if rest := strings.TrimSpace(line[end:]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return -1
+	}
+	return start + end
+}
@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// DangerousCommandPatterns match bash commands that are hard to undo, offered as a starting point
+// for the confirmation policy.
+var DangerousCommandPatterns = []string{
+	`\brm\s+(-[a-zA-Z]*[rRf][a-zA-Z]*\s+)+`,
+	`\bgit\s+push\b.*(\s--force\b|\s-f\b)`,
+	`\bgit\s+(reset\s+--hard|clean\s+-[a-zA-Z]*f)`,
+	`\b(mkfs|fdisk|parted)\b`,
+	`\bdd\b.*\bof=`,
+	`\b(shutdown|reboot|halt|poweroff)\b`,
+	`\bchmod\s+-R\b`,
+	`\b(curl|wget)\b.*\|\s*(ba|z)?sh\b`,
+}
+
+// confirmSchema asks the user for a single yes/no answer.
+var confirmSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"confirm": map[string]any{
+			"type":        "boolean",
+			"title":       "Proceed",
+			"description": "Allow this operation to run",
+		},
+	},
+	"required": []string{"confirm"},
+}
+
+// SetConfirmPolicy configures which operations must be confirmed by the user before they run:
+// every delete_file call when deletes is set, bash commands matching any of commandPatterns, and
+// file changes outside projectDir when it is non-empty.
+func (s *State) SetConfirmPolicy(deletes bool, commandPatterns []string, projectDir string) error {
+	var commands []*regexp.Regexp
+	for _, pattern := range commandPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("Invalid confirmation command pattern %q: %s", pattern, err)
+		}
+		commands = append(commands, re)
+	}
+	if projectDir != "" {
+		resolved, err := resolvePath(projectDir)
+		if err != nil {
+			return fmt.Errorf("project directory must be absolute, not relative")
+		}
+		projectDir = resolved
+	}
+	s.Mu.Lock()
+	s.ConfirmDeletes = deletes
+	s.ConfirmCommands = commands
+	s.ConfirmOutside = projectDir
+	s.Mu.Unlock()
+	return nil
+}
+
+// confirmDelete asks before delete_file removes resolved, when the policy requires it.
+func (s *State) confirmDelete(ctx context.Context, resolved string, isDir bool) error {
+	s.Mu.RLock()
+	required := s.ConfirmDeletes
+	s.Mu.RUnlock()
+	if !required {
+		return nil
+	}
+	what := "file"
+	if isDir {
+		what = "directory"
+	}
+	return s.requestConfirmation(ctx, fmt.Sprintf("Delete the %s %s?", what, resolved))
+}
+
+// confirmCommand asks before bash, schedule_command, or repl_send runs a command or snippet
+// matching one of the policy's patterns.
+func (s *State) confirmCommand(ctx context.Context, command string) error {
+	s.Mu.RLock()
+	patterns := s.ConfirmCommands
+	s.Mu.RUnlock()
+	for _, re := range patterns {
+		if re.MatchString(command) {
+			return s.requestConfirmation(ctx, "Run this command? "+command)
+		}
+	}
+	return nil
+}
+
+// confirmWrite asks before tool changes any of paths that lies outside the project directory.
+func (s *State) confirmWrite(ctx context.Context, tool string, paths ...string) error {
+	s.Mu.RLock()
+	project := s.ConfirmOutside
+	s.Mu.RUnlock()
+	if project == "" {
+		return nil
+	}
+	var outside []string
+	fsys := s.filesystem()
+	for _, path := range paths {
+		if !isWithin(fsys, project, path) {
+			outside = append(outside, path)
+		}
+	}
+	if len(outside) == 0 {
+		return nil
+	}
+	return s.requestConfirmation(ctx, fmt.Sprintf("Allow %s to change %s, outside the project directory %s?", tool, strings.Join(outside, ", "), project))
+}
+
+// isWithin reports whether path is dir or lies beneath it, following symlinks on fsys where they
+// resolve so that a link inside the project cannot be used to reach outside it.
+func isWithin(fsys vfs.FS, dir, path string) bool {
+	if real, err := fsys.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	path = resolveExisting(fsys, path)
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolveExisting evaluates symlinks in the longest existing prefix of path on fsys, keeping the
+// rest of a path that is yet to be created as given.
+func resolveExisting(fsys vfs.FS, path string) string {
+	var missing []string
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if real, err := fsys.EvalSymlinks(dir); err == nil {
+			return filepath.Join(append([]string{real}, missing...)...)
+		}
+		if dir == filepath.Dir(dir) {
+			return path
+		}
+		missing = append([]string{filepath.Base(dir)}, missing...)
+	}
+}
+
+// requestConfirmation asks the calling client's user to approve an operation via elicitation. The
+// operation is refused when the user declines or when no user can be asked, such as in stateless
+// mode or with clients that don't support elicitation.
+func (s *State) requestConfirmation(ctx context.Context, message string) error {
+	session := sessionFromContext(ctx)
+	if session == nil || !supportsElicitation(session) {
+		return fmt.Errorf("This operation requires user confirmation, but the client cannot ask the user (elicitation is unsupported or the server is stateless): %s", message)
+	}
+	start := time.Now()
+	defer func() { addQueueTime(ctx, time.Since(start)) }()
+	return confirmWith(ctx, session.Elicit, message)
+}
+
+func supportsElicitation(session *sdk.ServerSession) bool {
+	params := session.InitializeParams()
+	return params != nil && params.Capabilities != nil && params.Capabilities.Elicitation != nil
+}
+
+// confirmWith sends the confirmation prompt through elicit and turns anything other than an
+// explicit approval into an error.
+func confirmWith(ctx context.Context, elicit func(context.Context, *sdk.ElicitParams) (*sdk.ElicitResult, error), message string) error {
+	result, err := elicit(ctx, &sdk.ElicitParams{
+		Message:         message,
+		RequestedSchema: confirmSchema,
+	})
+	if err != nil {
+		return fmt.Errorf("Could not get user confirmation: %s", err)
+	}
+	if result.Action == "accept" {
+		if confirmed, _ := result.Content["confirm"].(bool); confirmed {
+			return nil
+		}
+	}
+	return fmt.Errorf("Operation cancelled: the user did not confirm: %s", message)
+}
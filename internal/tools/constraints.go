@@ -3,27 +3,129 @@ package tools
 import (
 	"context"
 	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 const (
-	// 10MB hard limit prevents reading extremely large files that would cause excessive
-	// memory usage and API token consumption when returned to the client.
+	// 10MB default limit prevents reading extremely large files in full, which would cause excessive
+	// memory usage and API token consumption when returned to the client. Reads with a limit may
+	// still take a portion of a larger file. Configurable with State.SetMaxFileSize.
 	absoluteMaxFileSize = 10 * 1024 * 1024
 
 	// ~100k tokens expressed as character count (Claude tokenizes at roughly 4 chars per token).
-	// This prevents responses from consuming excessive tokens and hitting API limits.
+	// This prevents responses from consuming excessive tokens and hitting API limits. This and
+	// absoluteMaxResults are defaults, configurable with State.SetOutputLimits.
 	absoluteMaxOutputSize = 25_000 * 4
 
 	// Maximum lines to return from grep/glob results. This truncates outputs from commands
 	// that match thousands of files, preventing massive responses and prompt bloat.
 	absoluteMaxResults = 1000
+
+	// Ceilings for configured and per-request limits, well beyond any client's context window.
+	hardMaxOutputSize = 250_000 * 4
+	hardMaxResults    = 10_000
 )
 
-func checkFileSize(ctx context.Context, size int64, toolName string) error {
-	effectiveMax := int64(absoluteMaxFileSize)
+// outputLimits are the output bounds in effect for a request.
+type outputLimits struct {
+	maxOutputSize int
+	maxResults    int
+}
+
+type outputLimitsKey struct{}
+
+// SetOutputLimits configures the default output bounds: the largest tool output in tokens, and
+// the most entries returned by listing and search tools.
+func (s *State) SetOutputLimits(maxOutputTokens, maxResults int) error {
+	if maxOutputTokens <= 0 || maxOutputTokens*4 > hardMaxOutputSize {
+		return fmt.Errorf("maximum output tokens must be between 1 and %d, got %d", hardMaxOutputSize/4, maxOutputTokens)
+	}
+	if maxResults <= 0 || maxResults > hardMaxResults {
+		return fmt.Errorf("maximum results must be between 1 and %d, got %d", hardMaxResults, maxResults)
+	}
+	s.Mu.Lock()
+	s.MaxOutputSize = maxOutputTokens * 4
+	s.MaxResults = maxResults
+	s.Mu.Unlock()
+	return nil
+}
+
+// OutputLimitsMiddleware stores the output bounds for each tool call in its context. A client may
+// raise or lower them for a single call through the request's _meta fields "max_output_tokens" and
+// "max_results", up to hard ceilings, to match its own context budget.
+func (s *State) OutputLimitsMiddleware(next sdk.MethodHandler) sdk.MethodHandler {
+	return func(ctx context.Context, method string, req sdk.Request) (sdk.Result, error) {
+		if call, ok := req.(*sdk.CallToolRequest); ok {
+			s.Mu.RLock()
+			limits := outputLimits{maxOutputSize: s.MaxOutputSize, maxResults: s.MaxResults}
+			s.Mu.RUnlock()
+			if call.Params != nil {
+				if tokens, ok := metaInt(call.Params.Meta, "max_output_tokens"); ok {
+					limits.maxOutputSize = min(tokens*4, hardMaxOutputSize)
+				}
+				if results, ok := metaInt(call.Params.Meta, "max_results"); ok {
+					limits.maxResults = min(results, hardMaxResults)
+				}
+			}
+			ctx = context.WithValue(ctx, outputLimitsKey{}, limits)
+		}
+		return next(ctx, method, req)
+	}
+}
+
+// metaInt reads a positive integer from a request's _meta, where JSON numbers arrive as float64.
+func metaInt(meta sdk.Meta, key string) (int, bool) {
+	value, ok := meta[key].(float64)
+	if !ok || value < 1 {
+		return 0, false
+	}
+	if value > math.MaxInt32 {
+		value = math.MaxInt32
+	}
+	return int(value), true
+}
+
+// limitsFromContext returns the output bounds for the current call, falling back to the built-in
+// defaults for calls that did not pass through OutputLimitsMiddleware.
+func limitsFromContext(ctx context.Context) outputLimits {
+	if limits, ok := ctx.Value(outputLimitsKey{}).(outputLimits); ok {
+		return limits
+	}
+	return outputLimits{maxOutputSize: absoluteMaxOutputSize, maxResults: absoluteMaxResults}
+}
+
+// resultLimit resolves a requested page size against the result limit, treating zero as "as many
+// as allowed".
+func resultLimit(ctx context.Context, requested int) int {
+	maxResults := limitsFromContext(ctx).maxResults
+	if requested <= 0 || requested > maxResults {
+		return maxResults
+	}
+	return requested
+}
+
+// SetMaxFileSize configures the largest file, in bytes, that Read returns in full.
+func (s *State) SetMaxFileSize(size int64) error {
+	if size <= 0 {
+		return fmt.Errorf("maximum file size must be positive, got %d", size)
+	}
+	s.Mu.Lock()
+	s.MaxFileSize = size
+	s.Mu.Unlock()
+	return nil
+}
+
+func (s *State) checkFileSize(ctx context.Context, size int64, toolName string) error {
+	s.Mu.RLock()
+	effectiveMax := s.MaxFileSize
+	s.Mu.RUnlock()
 	if size > effectiveMax {
 		return fmt.Errorf(
-			"File content (%d bytes) exceeds maximum allowed size (%d bytes). Please use offset and limit parameters to read specific portions of the file, or use the Grep tool to search for specific content.",
+			"File content (%d bytes) exceeds maximum allowed size (%d bytes). Please use the offset and limit parameters to read specific portions of the file, or use the Grep tool to search for specific content.",
 			size,
 			effectiveMax,
 		)
@@ -36,7 +138,7 @@ func checkFileSize(ctx context.Context, size int64, toolName string) error {
 // to search" for read tool, "use head_limit" for grep tool). Token count is estimated using the
 // common approximation of 4 characters per token.
 func checkOutputSize(ctx context.Context, output, toolName string) error {
-	effectiveMax := absoluteMaxOutputSize
+	effectiveMax := limitsFromContext(ctx).maxOutputSize
 	if len(output) > effectiveMax {
 		var suggestion string
 		switch toolName {
@@ -47,11 +149,51 @@ func checkOutputSize(ctx context.Context, output, toolName string) error {
 		case "edit":
 			suggestion = "Consider editing smaller sections of the file."
 		case "grep":
-			suggestion = "Consider using the head_limit parameter to restrict results, adding more specific patterns, or using glob/type filters to narrow the search."
+			suggestion = "Consider using the head_limit and offset parameters to page through results, adding more specific patterns, or using glob/type filters to narrow the search."
 		case "glob":
-			suggestion = "Consider using more specific glob patterns to narrow the search scope."
-		case "bash":
-			suggestion = "Consider using background execution with BashOutput to stream results, or redirect output to a file and read specific portions."
+			suggestion = "Consider lowering the limit parameter and paging with offset, or using more specific glob patterns to narrow the search scope."
+		case "git":
+			suggestion = "Consider restricting the operation to specific files, lowering max_count, or limiting the blame line range."
+		case "list_changes":
+			suggestion = "Consider summary_only to leave out the diffs, or path to narrow the listing."
+		case "generate_patch":
+			suggestion = "Consider output_path to write the patch to a file, or restricting it with files or session_only."
+		case "worktree":
+			suggestion = "Consider pruning stale worktrees with git worktree prune."
+		case "archive":
+			suggestion = "Consider reading the member in portions with offset and limit, or extracting it and using the Read tool."
+		case "disk_usage":
+			suggestion = "Consider reducing the depth parameter or raising the threshold."
+		case "listening_ports":
+			suggestion = "Consider setting the port parameter to show only the listeners on one port."
+		case "diff":
+			suggestion = "Consider lowering the context parameter or comparing smaller files."
+		case "structural_search":
+			suggestion = "Consider using head_limit and offset to page through matches, or narrowing the path or globs."
+		case "semantic_search":
+			suggestion = "Consider lowering top_k or narrowing the path."
+		case "outline":
+			suggestion = "Consider outlining a smaller file, or using the Grep tool to find the symbol you need."
+		case "references":
+			suggestion = "Consider looking up a more specific symbol, or using the Grep tool limited to the directories you need."
+		case "hover":
+			suggestion = "Consider reading the symbol's declaration directly, found with the definition tool."
+		case "rename_symbol":
+			suggestion = "Consider running the rename without dry_run and reviewing the changed files individually."
+		case "format_file":
+			suggestion = "Consider formatting without dry_run and reviewing the file with the Read tool."
+		case "config_edit":
+			suggestion = "Consider making the change without dry_run and reading the changed lines with the Read tool."
+		case "count_tokens":
+			suggestion = "Consider a more specific pattern or a narrower path."
+		case "pack_context":
+			suggestion = "Consider lowering token_budget or max_file_lines, or narrowing include."
+		case "render_template":
+			suggestion = "Consider writing the rendered text to a file instead of using dry_run."
+		case "extract_text":
+			suggestion = "Consider offset and limit to read the text in portions."
+		case "ls":
+			suggestion = "Consider listing a narrower directory or reducing the depth parameter."
 		default:
 			suggestion = "Consider breaking down the operation into smaller parts or using more specific parameters to limit output."
 		}
@@ -65,23 +207,35 @@ func checkOutputSize(ctx context.Context, output, toolName string) error {
 	return nil
 }
 
-// limitLines truncates output to at most absoluteMaxResults lines. Used by grep and glob to prevent
-// catastrophic output when patterns match thousands of results. Returns the substring up to and
-// including the Nth newline character (not just a count) to preserve complete lines.
-func limitLines(ctx context.Context, s string) string {
-	if s == "" {
-		return s
+// truncateMiddle shortens output that exceeds limit bytes to its head and tail around a marker
+// saying how much was left out, since the start and end of a command's output usually matter most.
+// Cuts fall on line boundaries where that keeps most of the budget, and never split a UTF-8
+// character.
+func truncateMiddle(output string, limit int) string {
+	if len(output) <= limit {
+		return output
 	}
-	effectiveMax := absoluteMaxResults
-	count := 0
-	for i := 0; i < len(s); i++ {
-		if s[i] == '\n' {
-			count++
-			if count >= effectiveMax {
-				// Return substring including the Nth newline to keep the final line complete.
-				return s[:i+1]
-			}
-		}
+	// The marker is sized for the full output; what is omitted can only be less.
+	budget := max(limit-len(fmt.Sprintf(truncationMarker, len(output))), 0)
+	headLen, tailLen := budget/2, budget-budget/2
+
+	head := output[:headLen]
+	if i := strings.LastIndexByte(head, '\n'); i >= headLen/2 {
+		head = head[:i+1]
+	}
+	for len(head) > 0 && !utf8.RuneStart(output[len(head)]) {
+		head = head[:len(head)-1]
 	}
-	return s
+
+	start := len(output) - tailLen
+	if i := strings.IndexByte(output[start:], '\n'); i >= 0 && i < tailLen/2 {
+		start += i + 1
+	}
+	for start < len(output) && !utf8.RuneStart(output[start]) {
+		start++
+	}
+	return head + fmt.Sprintf(truncationMarker, start-len(head)) + output[start:]
 }
+
+// truncationMarker replaces the middle of truncated output.
+const truncationMarker = "\n... [%d bytes truncated] ...\n"
@@ -1,9 +1,15 @@
 package tools
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCatN(t *testing.T) {
@@ -81,3 +87,72 @@ func TestMax(t *testing.T) {
 		})
 	}
 }
+
+func TestOutputLimits(t *testing.T) {
+	t.Run("defaults without middleware", func(t *testing.T) {
+		ctx := context.Background()
+		assert.Equal(t, absoluteMaxResults, resultLimit(ctx, 0))
+		assert.Equal(t, 10, resultLimit(ctx, 10))
+		assert.Equal(t, absoluteMaxResults, resultLimit(ctx, absoluteMaxResults+1))
+		require.NoError(t, checkOutputSize(ctx, strings.Repeat("x", absoluteMaxOutputSize), "read"))
+		require.Error(t, checkOutputSize(ctx, strings.Repeat("x", absoluteMaxOutputSize+1), "read"))
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		state := NewState()
+		require.Error(t, state.SetOutputLimits(0, 10))
+		require.Error(t, state.SetOutputLimits(hardMaxOutputSize/4+1, 10))
+		require.Error(t, state.SetOutputLimits(1000, hardMaxResults+1))
+		require.NoError(t, state.SetOutputLimits(1000, 50))
+	})
+
+	// captureLimits runs the middleware around a handler that records the limits it sees
+	captureLimits := func(state *State, meta sdk.Meta) outputLimits {
+		var limits outputLimits
+		handler := state.OutputLimitsMiddleware(func(ctx context.Context, method string, req sdk.Request) (sdk.Result, error) {
+			limits = limitsFromContext(ctx)
+			return nil, nil
+		})
+		_, err := handler(context.Background(), "tools/call", &sdk.CallToolRequest{Params: &sdk.CallToolParamsRaw{Meta: meta}})
+		require.NoError(t, err)
+		return limits
+	}
+
+	t.Run("server configuration", func(t *testing.T) {
+		state := NewState()
+		require.NoError(t, state.SetOutputLimits(1000, 50))
+		assert.Equal(t, outputLimits{maxOutputSize: 4000, maxResults: 50}, captureLimits(state, nil))
+	})
+
+	t.Run("per-call overrides", func(t *testing.T) {
+		state := NewState()
+		limits := captureLimits(state, sdk.Meta{"max_output_tokens": float64(200000), "max_results": float64(5)})
+		assert.Equal(t, outputLimits{maxOutputSize: 800000, maxResults: 5}, limits)
+
+		// Overrides are capped, and invalid values ignored
+		limits = captureLimits(state, sdk.Meta{"max_output_tokens": float64(1e9), "max_results": "all"})
+		assert.Equal(t, outputLimits{maxOutputSize: hardMaxOutputSize, maxResults: absoluteMaxResults}, limits)
+	})
+}
+
+func TestTruncateMiddle(t *testing.T) {
+	assert.Equal(t, "short", truncateMiddle("short", 100))
+
+	var lines strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&lines, "line %02d\n", i)
+	}
+	truncated := truncateMiddle(lines.String(), 100)
+	assert.LessOrEqual(t, len(truncated), 100)
+	assert.True(t, strings.HasPrefix(truncated, "line 00\n"))
+	assert.True(t, strings.HasSuffix(truncated, "line 99\n"))
+	head, tail, found := strings.Cut(truncated, "\n... [")
+	require.True(t, found)
+	assert.True(t, strings.HasSuffix(head, "\n"), "the head ends on a line boundary")
+	_, tail, _ = strings.Cut(tail, "] ...\n")
+	assert.True(t, strings.HasPrefix(tail, "line "), "the tail starts on a line boundary")
+
+	// Multi-byte characters are never split
+	truncated = truncateMiddle(strings.Repeat("é", 100), 50)
+	assert.True(t, utf8.ValidString(truncated))
+}
@@ -0,0 +1,203 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func (s *State) executeCopyFile(ctx context.Context, source, destination string, recursive bool) (string, error) {
+	if err := s.checkOverlay("copy_file"); err != nil {
+		return "", err
+	}
+	src, dst, err := s.resolveSourceAndDestination(source, destination)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(src, dst); err != nil {
+		return "", err
+	}
+	defer s.lockPaths(ctx, dst)()
+	if err := s.confirmWrite(ctx, "copy_file", dst); err != nil {
+		return "", err
+	}
+
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return "", fmt.Errorf("source does not exist")
+	}
+
+	if srcInfo.IsDir() {
+		if !recursive {
+			return "", fmt.Errorf("source is a directory, set recursive to true to copy it")
+		}
+		// Refuse to merge into an existing directory: the per-file read-before-overwrite check
+		// can't meaningfully be applied to an entire tree of potential collisions.
+		if _, err := os.Lstat(dst); err == nil {
+			return "", fmt.Errorf("destination already exists: %s", dst)
+		}
+		if strings.HasPrefix(dst, src+string(filepath.Separator)) {
+			return "", fmt.Errorf("cannot copy a directory into itself")
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+			return "", fmt.Errorf("Cannot create parent directory: %s", err)
+		}
+		s.noteChange(ctx, "copy_file", dst)
+		if err := copyTree(ctx, src, dst); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Directory copied successfully from %s to %s", src, dst), nil
+	}
+
+	if err := s.validateDestination(dst); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return "", fmt.Errorf("Cannot create parent directory: %s", err)
+	}
+	s.noteChange(ctx, "copy_file", dst)
+	if err := copyEntry(src, dst, srcInfo); err != nil {
+		return "", err
+	}
+
+	// The destination now holds content the caller never read, so drop any stale tracking
+	// instead of letting an earlier read of the old content authorize future overwrites.
+	s.forgetPath(dst)
+	return fmt.Sprintf("File copied successfully from %s to %s", src, dst), nil
+}
+
+// resolveSourceAndDestination validates both paths of a two-path operation.
+func (s *State) resolveSourceAndDestination(source, destination string) (src, dst string, err error) {
+	src, err = s.resolvePath(source)
+	if err != nil {
+		return "", "", err
+	}
+	dst, err = s.resolvePath(destination)
+	if err != nil {
+		return "", "", err
+	}
+	if src == dst {
+		return "", "", fmt.Errorf("source and destination are the same path")
+	}
+	return src, dst, nil
+}
+
+// validateDestination applies overwrite protection to the target of a copy or move: an existing
+// directory can never be replaced by a file, and an existing file must have been read (and not
+// modified since) just as if it were being overwritten by the Write tool.
+func (s *State) validateDestination(dst string) error {
+	info, err := s.filesystem().Lstat(dst)
+	if err != nil {
+		return nil
+	}
+	if info.IsDir() {
+		return fmt.Errorf("destination is an existing directory: %s", dst)
+	}
+	if err := s.validateFileForWrite(dst); err != nil {
+		return fmt.Errorf("destination %s", err)
+	}
+	return nil
+}
+
+// copyTree recursively copies the directory src to dst, preserving permissions and recreating
+// symlinks rather than following them.
+func copyTree(ctx context.Context, src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("Cannot copy %s: %s", path, err)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("Cannot copy %s: %s", path, err)
+		}
+		return copyEntry(path, filepath.Join(dst, rel), info)
+	})
+}
+
+// copyEntry copies a single directory entry, file, or symlink from src to dst.
+func copyEntry(src, dst string, info fs.FileInfo) error {
+	switch {
+	case info.IsDir():
+		if err := os.Mkdir(dst, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("Cannot create directory: %s", err)
+		}
+		return nil
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return fmt.Errorf("Cannot read symlink: %s", err)
+		}
+		_ = os.Remove(dst)
+		if err := os.Symlink(target, dst); err != nil {
+			return fmt.Errorf("Cannot create symlink: %s", err)
+		}
+		return nil
+	case info.Mode().IsRegular():
+		return copyFileContents(src, dst, info.Mode().Perm())
+	default:
+		return fmt.Errorf("Cannot copy %s: unsupported file type", src)
+	}
+}
+
+func copyFileContents(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("Cannot read file: %s", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("Cannot write file: %s", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("Cannot write file: %s", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("Cannot write file: %s", err)
+	}
+	// OpenFile only applies perm when creating; make overwritten files match the source too.
+	_ = os.Chmod(dst, perm)
+	return nil
+}
+
+var CopyFileTool = sdk.Tool{
+	Name:        "copy_file",
+	Description: "Copies a file or directory.\n\nUsage:\n- Both source and destination must be absolute paths.\n- Parent directories of the destination are created as needed.\n- If the destination file already exists, you MUST use the Read tool on it first. This tool will fail if you did not read the existing destination.\n- To copy a directory, set recursive to true. Directory copies never merge into an existing destination.\n- File permissions are preserved and symlinks are copied as links.",
+}
+
+type CopyFileInput struct {
+	Source      string `json:"source" jsonschema:"The absolute path of the file or directory to copy"`
+	Destination string `json:"destination" jsonschema:"The absolute path to copy to"`
+	Recursive   bool   `json:"recursive,omitempty" jsonschema:"Copy directories recursively. Required when source is a directory"`
+}
+type CopyFileOutput struct {
+	Message string `json:"message"`
+}
+
+func CopyFile(ctx context.Context, req *sdk.CallToolRequest, args CopyFileInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeCopyFile(withSession(ctx, req), args.Source, args.Destination, args.Recursive)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &CopyFileOutput{Message: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
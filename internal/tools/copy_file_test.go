@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyFile_BasicFunctionality(t *testing.T) {
+	t.Run("copies file and preserves mode", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "run.sh")
+		require.NoError(t, os.WriteFile(src, []byte("#!/bin/sh\necho hi\n"), 0o755))
+		dst := filepath.Join(dir, "nested", "copy.sh")
+		state := NewState()
+		result, err := state.executeCopyFile(context.Background(), src, dst, false)
+		require.NoError(t, err)
+		assert.Contains(t, result, "copied successfully")
+		content, err := os.ReadFile(dst)
+		require.NoError(t, err)
+		assert.Equal(t, "#!/bin/sh\necho hi\n", string(content))
+		info, err := os.Stat(dst)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o755), info.Mode().Perm()&0o755)
+	})
+	t.Run("copies directory recursively", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src")
+		require.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(src, "sub", "a.txt"), []byte("a"), 0o644))
+		dst := filepath.Join(dir, "dst")
+		state := NewState()
+		_, err := state.executeCopyFile(context.Background(), src, dst, true)
+		require.NoError(t, err)
+		content, err := os.ReadFile(filepath.Join(dst, "sub", "a.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "a", string(content))
+	})
+}
+
+func TestCopyFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	require.NoError(t, os.WriteFile(src, []byte("new"), 0o644))
+	existing := filepath.Join(dir, "existing.txt")
+	require.NoError(t, os.WriteFile(existing, []byte("old"), 0o644))
+	state := NewState()
+
+	t.Run("unread destination", func(t *testing.T) {
+		_, err := state.executeCopyFile(context.Background(), src, existing, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "read it first")
+	})
+	t.Run("read destination can be overwritten", func(t *testing.T) {
+		_, err := state.executeRead(context.Background(), existing, 0, 0)
+		require.NoError(t, err)
+		_, err = state.executeCopyFile(context.Background(), src, existing, false)
+		require.NoError(t, err)
+		content, err := os.ReadFile(existing)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(content))
+	})
+	t.Run("directory without recursive", func(t *testing.T) {
+		_, err := state.executeCopyFile(context.Background(), dir, filepath.Join(t.TempDir(), "x"), false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "recursive")
+	})
+	t.Run("missing source", func(t *testing.T) {
+		_, err := state.executeCopyFile(context.Background(), filepath.Join(dir, "missing"), filepath.Join(dir, "x"), false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not exist")
+	})
+	t.Run("relative path", func(t *testing.T) {
+		_, err := state.executeCopyFile(context.Background(), "rel.txt", filepath.Join(dir, "x"), false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be absolute")
+	})
+}
@@ -0,0 +1,205 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/gabriel-vasile/mimetype"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type tokenCountFile struct {
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+	Lines int    `json:"lines,omitempty"`
+	// Tokens counts the file's content; ReadTokens counts the Read tool's output for the whole
+	// file, which adds line numbers. ReadExceedsLimit means a Read without offset and limit would
+	// be refused as too large.
+	Tokens           int    `json:"tokens"`
+	ReadTokens       int    `json:"read_tokens,omitempty"`
+	ReadExceedsLimit bool   `json:"read_exceeds_limit,omitempty"`
+	Skipped          string `json:"skipped,omitempty"`
+}
+
+type tokenCountResult struct {
+	Tokenizer       string           `json:"tokenizer"`
+	TotalTokens     int              `json:"total_tokens"`
+	Bytes           int64            `json:"bytes,omitempty"`
+	Lines           int              `json:"lines,omitempty"`
+	Files           []tokenCountFile `json:"files,omitempty"`
+	TotalFiles      int              `json:"total_files,omitempty"`
+	Truncated       bool             `json:"truncated,omitempty"`
+	ReadLimitTokens int              `json:"read_limit_tokens"`
+}
+
+func (s *State) executeCountTokens(ctx context.Context, filePath, pattern, path, text, tokenizer string) (string, error) {
+	given := 0
+	for _, input := range []string{filePath, pattern, text} {
+		if input != "" {
+			given++
+		}
+	}
+	if given != 1 {
+		return "", fmt.Errorf("Provide exactly one of file_path, pattern, or text.")
+	}
+	name, count, err := s.tokenCounter(tokenizer)
+	if err != nil {
+		return "", err
+	}
+	limits := limitsFromContext(ctx)
+	result := tokenCountResult{Tokenizer: name, ReadLimitTokens: limits.maxOutputSize / 4}
+
+	switch {
+	case text != "":
+		if result.TotalTokens, err = count(ctx, text); err != nil {
+			return "", err
+		}
+		result.Bytes = int64(len(text))
+		result.Lines = strings.Count(text, "\n") + 1
+	case filePath != "":
+		resolved, err := s.resolvePath(filePath)
+		if err != nil {
+			return "", err
+		}
+		if err := s.checkPathAllowed(resolved); err != nil {
+			return "", err
+		}
+		info, err := s.filesystem().Stat(resolved)
+		if err != nil {
+			return "", fmt.Errorf("File does not exist.")
+		}
+		if info.IsDir() {
+			return "", fmt.Errorf("path is a directory, not a file: %s. Use pattern to count the files in a directory.", resolved)
+		}
+		if err := s.checkFileSize(ctx, info.Size(), "count_tokens"); err != nil {
+			return "", err
+		}
+		file, err := s.countFileTokens(ctx, resolved, info.Size(), limits.maxOutputSize, count)
+		if err != nil {
+			return "", err
+		}
+		result.Files = []tokenCountFile{file}
+		result.TotalTokens = file.Tokens
+		result.TotalFiles = 1
+	default:
+		if strings.Contains(pattern, "\x00") || !doublestar.ValidatePattern(pattern) {
+			return "", fmt.Errorf("Invalid glob pattern.")
+		}
+		searchDir := s.workDir(ctx)
+		if path != "" {
+			resolved, err := s.resolvePath(path)
+			if err != nil {
+				return "", err
+			}
+			if err := s.checkPathAllowed(resolved); err != nil {
+				return "", err
+			}
+			searchDir = resolved
+		}
+		walker := newGlobWalker(s.filesystem(), searchDir, pattern, maxGlobMatches, false)
+		s.Mu.RLock()
+		walker.denied = s.DeniedPaths
+		maxFileSize := s.MaxFileSize
+		s.Mu.RUnlock()
+		matches, _ := walker.run(ctx)
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if len(matches) == 0 {
+			return "No files found", nil
+		}
+		sort.Slice(matches, func(i, j int) bool { return matches[i].path < matches[j].path })
+
+		// Every file counts towards the total; only the first page is listed.
+		result.TotalFiles = len(matches)
+		for i, match := range matches {
+			matchPath := filepath.Join(searchDir, match.path)
+			file := tokenCountFile{Path: matchPath, Bytes: match.size}
+			if match.size > int64(maxFileSize) {
+				file.Skipped = "too large"
+			} else if file, err = s.countFileTokens(ctx, matchPath, match.size, limits.maxOutputSize, count); err != nil {
+				return "", err
+			}
+			result.TotalTokens += file.Tokens
+			result.Bytes += file.Bytes
+			if i < limits.maxResults {
+				result.Files = append(result.Files, file)
+			} else {
+				result.Truncated = true
+			}
+		}
+	}
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format token counts: %s", err)
+	}
+	output := string(jsonBytes)
+	if err := checkOutputSize(ctx, output, "count_tokens"); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// countFileTokens counts the tokens of a file and of the Read tool's output for it. Binary files,
+// which Read does not display, are skipped.
+func (s *State) countFileTokens(ctx context.Context, path string, size int64, maxOutputSize int, count func(context.Context, string) (int, error)) (tokenCountFile, error) {
+	file := tokenCountFile{Path: path, Bytes: size}
+	content, err := s.filesystem().ReadFile(path)
+	if err != nil {
+		file.Skipped = "unreadable"
+		return file, nil
+	}
+	if len(content) == 0 {
+		return file, nil
+	}
+	if !isTextMIME(mimetype.Detect(content)) {
+		file.Skipped = "binary"
+		return file, nil
+	}
+	if file.Tokens, err = count(ctx, string(content)); err != nil {
+		return file, err
+	}
+	lines := strings.Split(string(content), "\n")
+	file.Lines = len(lines)
+	readOutput := catN(lines, 1)
+	if file.ReadTokens, err = count(ctx, readOutput); err != nil {
+		return file, err
+	}
+	file.ReadExceedsLimit = len(readOutput) > maxOutputSize
+	return file, nil
+}
+
+var CountTokensTool = sdk.Tool{
+	Name:        "count_tokens",
+	Description: "Estimates how many tokens a file, the files matching a glob pattern, or a piece of text would take up, so you can decide before a Read whether to read a file whole, in portions, or not at all.\n\nUsage:\n- Give exactly one of file_path, pattern (with an optional path to search in, as for the Glob tool), or text.\n- For files, tokens counts the content and read_tokens counts what the Read tool would return, which adds line numbers. read_exceeds_limit marks files a Read without offset and limit would refuse as larger than read_limit_tokens.\n- Binary files and files over the server's size limit are skipped.\n- tokenizer selects how tokens are counted: \"heuristic\" (the default, an approximation of real tokenizers), \"chars\" (4 characters per token, the estimate the server's output limits use), or \"command\" (an exact count from a tokenizer the server is configured with, when available).",
+}
+
+type CountTokensInput struct {
+	FilePath  string `json:"file_path,omitempty" jsonschema:"The absolute path to a file to count"`
+	Pattern   string `json:"pattern,omitempty" jsonschema:"A glob pattern selecting the files to count, e.g. src/**/*.ts"`
+	Path      string `json:"path,omitempty" jsonschema:"The directory to match pattern in. Defaults to the working directory"`
+	Text      string `json:"text,omitempty" jsonschema:"Text to count"`
+	Tokenizer string `json:"tokenizer,omitempty" jsonschema:"How to count tokens: heuristic, chars, or command. Defaults to the server setting"`
+}
+type CountTokensOutput struct {
+	Result string `json:"result"`
+}
+
+func CountTokens(ctx context.Context, req *sdk.CallToolRequest, args CountTokensInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeCountTokens(ctx, args.FilePath, args.Pattern, args.Path, args.Text, args.Tokenizer)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &CountTokensOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
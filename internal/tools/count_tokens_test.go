@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeuristicTokens(t *testing.T) {
+	tests := []struct {
+		text   string
+		tokens int
+	}{
+		{"", 0},
+		{"hello world", 2},
+		{"internationalization", 3},
+		{"parseHTTPRequest", 3},
+		{"snake_case_name", 5},
+		{"x := 1234567", 5},
+		{"if (a == b) {\n\treturn\n}", 12},
+		{"你好世界", 4},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.tokens, heuristicTokens(tt.text), tt.text)
+	}
+}
+
+func TestSetTokenizer(t *testing.T) {
+	state := NewState()
+	require.NoError(t, state.SetTokenizer("", ""))
+	assert.Equal(t, "heuristic", state.Tokenizer)
+	require.NoError(t, state.SetTokenizer("", "wc -w"))
+	assert.Equal(t, "command", state.Tokenizer)
+	assert.Equal(t, []string{"wc", "-w"}, state.TokenizerCommand)
+	require.NoError(t, state.SetTokenizer("chars", "wc -w"))
+	assert.Equal(t, "chars", state.Tokenizer)
+
+	assert.Error(t, state.SetTokenizer("tiktoken", ""))
+	assert.Error(t, state.SetTokenizer("command", ""))
+}
+
+func TestCountTokens(t *testing.T) {
+	state := NewState()
+	ctx := context.Background()
+	count := func(filePath, pattern, path, text, tokenizer string) tokenCountResult {
+		t.Helper()
+		result, err := state.executeCountTokens(ctx, filePath, pattern, path, text, tokenizer)
+		require.NoError(t, err)
+		var parsed tokenCountResult
+		require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+		return parsed
+	}
+
+	t.Run("text", func(t *testing.T) {
+		result := count("", "", "", "hello world", "")
+		assert.Equal(t, "heuristic", result.Tokenizer)
+		assert.Equal(t, 2, result.TotalTokens)
+		assert.Equal(t, int64(11), result.Bytes)
+		assert.Equal(t, 25000, result.ReadLimitTokens)
+
+		assert.Equal(t, 3, count("", "", "", "hello world", "chars").TotalTokens)
+	})
+
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small.go")
+	require.NoError(t, os.WriteFile(small, []byte("package main\n\nfunc main() {}\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0o755))
+	big := filepath.Join(dir, "sub", "big.txt")
+	require.NoError(t, os.WriteFile(big, []byte(strings.Repeat("lorem ipsum dolor sit amet\n", 5000)), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "image.png"), []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), 0o644))
+
+	t.Run("file", func(t *testing.T) {
+		result := count(small, "", "", "", "")
+		require.Len(t, result.Files, 1)
+		file := result.Files[0]
+		assert.Equal(t, small, file.Path)
+		assert.Equal(t, 4, file.Lines)
+		assert.Equal(t, result.TotalTokens, file.Tokens)
+		assert.Greater(t, file.ReadTokens, file.Tokens)
+		assert.False(t, file.ReadExceedsLimit)
+
+		result = count(big, "", "", "", "chars")
+		assert.True(t, result.Files[0].ReadExceedsLimit)
+		assert.Equal(t, 135000/4, result.TotalTokens)
+	})
+
+	t.Run("pattern", func(t *testing.T) {
+		result := count("", "**/*", dir, "", "")
+		assert.Equal(t, 3, result.TotalFiles)
+		require.Len(t, result.Files, 3)
+		assert.Equal(t, "binary", result.Files[0].Skipped)
+		assert.Equal(t, result.Files[1].Tokens+result.Files[2].Tokens, result.TotalTokens)
+
+		output, err := state.executeCountTokens(ctx, "", "*.rs", dir, "", "")
+		require.NoError(t, err)
+		assert.Equal(t, "No files found", output)
+
+		// Without a path, the pattern is matched in the session's working directory.
+		session := NewState()
+		session.setWorkDir(ctx, dir)
+		output, err = session.executeCountTokens(ctx, "", "*.go", "", "", "")
+		require.NoError(t, err)
+		assert.Contains(t, output, `"total_files": 1`)
+	})
+
+	t.Run("command tokenizer", func(t *testing.T) {
+		_, err := state.executeCountTokens(ctx, "", "", "", "one two", "command")
+		assert.ErrorContains(t, err, "--tokenizer-command")
+
+		require.NoError(t, state.SetTokenizer("", "wc -w"))
+		defer func() { require.NoError(t, state.SetTokenizer("", "")) }()
+		result := count("", "", "", "one two three", "")
+		assert.Equal(t, "command", result.Tokenizer)
+		assert.Equal(t, 3, result.TotalTokens)
+
+		require.NoError(t, state.SetTokenizer("", "echo many"))
+		_, err = state.executeCountTokens(ctx, "", "", "", "one", "")
+		assert.ErrorContains(t, err, "must print the token count")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := state.executeCountTokens(ctx, "", "", "", "", "")
+		assert.ErrorContains(t, err, "exactly one of")
+		_, err = state.executeCountTokens(ctx, small, "", "", "text", "")
+		assert.ErrorContains(t, err, "exactly one of")
+		_, err = state.executeCountTokens(ctx, dir, "", "", "", "")
+		assert.ErrorContains(t, err, "Use pattern")
+		_, err = state.executeCountTokens(ctx, "", "", "", "text", "bpe")
+		assert.ErrorContains(t, err, "Invalid tokenizer")
+	})
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// defaultDirMode is used when no mode is requested; the process umask is applied on top of it,
+// matching what `mkdir` would produce.
+const defaultDirMode = 0o755
+
+func (s *State) executeCreateDirectory(ctx context.Context, path string, recursive bool, mode string) (string, error) {
+	if err := s.checkOverlay("create_directory"); err != nil {
+		return "", err
+	}
+	resolved, err := s.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+
+	perm := os.FileMode(defaultDirMode)
+	if mode != "" {
+		perm, err = parseFileMode(mode)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	fsys := s.filesystem()
+	if info, err := fsys.Stat(resolved); err == nil {
+		if !info.IsDir() {
+			return "", fmt.Errorf("a file already exists at %s", resolved)
+		}
+		return "Directory already exists: " + resolved, nil
+	}
+	if err := s.confirmWrite(ctx, "create_directory", resolved); err != nil {
+		return "", err
+	}
+
+	s.noteChange(ctx, "create_directory", resolved)
+	if recursive {
+		err = fsys.MkdirAll(resolved, perm)
+	} else {
+		err = fsys.Mkdir(resolved, perm)
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("parent directory does not exist, set recursive to true to create it")
+		}
+		return "", fmt.Errorf("Cannot create directory: %s", err)
+	}
+
+	// Mkdir applies the umask; an explicit mode is a request for exactly those bits.
+	if mode != "" {
+		if err := fsys.Chmod(resolved, perm); err != nil {
+			return "", fmt.Errorf("Cannot set directory mode: %s", err)
+		}
+	}
+
+	return "Directory created successfully at: " + resolved, nil
+}
+
+var CreateDirectoryTool = sdk.Tool{
+	Name:        "create_directory",
+	Description: "Creates a directory.\n\nUsage:\n- The path must be absolute.\n- Missing parent directories are created by default; set recursive to false to require the parent to exist.\n- Succeeds without changes if the directory already exists.\n- Optionally set mode to an octal permission string such as \"700\"; otherwise the directory gets 755 minus the process umask.\n- Use this tool instead of running mkdir via Bash.",
+}
+
+type CreateDirectoryInput struct {
+	Path      string `json:"path" jsonschema:"The absolute path of the directory to create"`
+	Recursive *bool  `json:"recursive,omitempty" jsonschema:"Create missing parent directories (default true)"`
+	Mode      string `json:"mode,omitempty" jsonschema:"Octal permission bits for the new directory, e.g. \"755\" or \"0700\""`
+}
+type CreateDirectoryOutput struct {
+	Message string `json:"message"`
+}
+
+func CreateDirectory(ctx context.Context, req *sdk.CallToolRequest, args CreateDirectoryInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	recursive := args.Recursive == nil || *args.Recursive
+	result, err := server.executeCreateDirectory(withSession(ctx, req), args.Path, recursive, args.Mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &CreateDirectoryOutput{Message: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
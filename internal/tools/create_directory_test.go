@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateDirectory_BasicFunctionality(t *testing.T) {
+	state := NewState()
+	t.Run("creates nested directories", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "a", "b", "c")
+		result, err := state.executeCreateDirectory(context.Background(), dir, true, "")
+		require.NoError(t, err)
+		assert.Contains(t, result, "created successfully")
+		info, err := os.Stat(dir)
+		require.NoError(t, err)
+		assert.True(t, info.IsDir())
+	})
+	t.Run("applies explicit mode", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "private")
+		_, err := state.executeCreateDirectory(context.Background(), dir, true, "700")
+		require.NoError(t, err)
+		info, err := os.Stat(dir)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+	})
+	t.Run("existing directory is not an error", func(t *testing.T) {
+		dir := t.TempDir()
+		result, err := state.executeCreateDirectory(context.Background(), dir, true, "")
+		require.NoError(t, err)
+		assert.Contains(t, result, "already exists")
+	})
+}
+
+func TestCreateDirectory_Errors(t *testing.T) {
+	state := NewState()
+	t.Run("missing parent without recursive", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "missing", "child")
+		_, err := state.executeCreateDirectory(context.Background(), dir, false, "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "parent directory does not exist")
+	})
+	t.Run("file in the way", func(t *testing.T) {
+		_, path := setupTestFile(t, "content")
+		_, err := state.executeCreateDirectory(context.Background(), path, true, "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "file already exists")
+	})
+	t.Run("invalid mode", func(t *testing.T) {
+		_, err := state.executeCreateDirectory(context.Background(), filepath.Join(t.TempDir(), "x"), true, "999")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid mode")
+	})
+	t.Run("relative path", func(t *testing.T) {
+		_, err := state.executeCreateDirectory(context.Background(), "relative", true, "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be absolute")
+	})
+}
+
+func TestCreateDirectory_MCPIntegration(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "defaults", "to", "recursive")
+	result, _, err := CreateDirectory(context.Background(), &sdk.CallToolRequest{}, CreateDirectoryInput{Path: dir})
+	require.NoError(t, err)
+	assert.NotNil(t, result)
+	_, err = os.Stat(dir)
+	assert.NoError(t, err)
+}
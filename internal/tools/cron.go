@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed five-field cron expression: minute, hour, day of month, month, and day
+// of week. Each field is a bitmask of the values it allows.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record an unrestricted day field. As in Vixie cron, when both day fields
+	// are restricted a time matches if either one does.
+	domStar, dowStar bool
+}
+
+// cronField describes the allowed range of one field.
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// cronDescriptors are the @-shorthands cron accepts in place of five fields.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSearchLimit bounds the search for the next matching minute. Five years covers every
+// satisfiable expression, including February 29th.
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// parseCron parses a standard cron expression. Each field is *, a value, a range a-b, or a list of
+// those separated by commas, optionally with a /step. Day of week 7 is Sunday, like 0.
+func parseCron(expr string) (*cronSchedule, error) {
+	if descriptor, ok := cronDescriptors[strings.TrimSpace(expr)]; ok {
+		expr = descriptor
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("Invalid cron expression %q: expected 5 fields (minute hour day-of-month month day-of-week), got %d", expr, len(parts))
+	}
+	masks := make([]uint64, len(parts))
+	for i, part := range parts {
+		mask, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid cron expression %q: %s", expr, err)
+		}
+		masks[i] = mask
+	}
+	schedule := &cronSchedule{
+		minute:  masks[0],
+		hour:    masks[1],
+		dom:     masks[2],
+		month:   masks[3],
+		dow:     masks[4],
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+	return schedule, nil
+}
+
+func parseCronField(field string, spec cronField) (uint64, error) {
+	var mask uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepPart, spec.name)
+			}
+			step = n
+		}
+
+		lo, hi := spec.min, spec.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = cronValue(a, spec); err != nil {
+				return 0, err
+			}
+			if hi, err = cronValue(b, spec); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("range %q in %s field is backwards", rangePart, spec.name)
+			}
+		default:
+			value, err := cronValue(rangePart, spec)
+			if err != nil {
+				return 0, err
+			}
+			lo = value
+			// A single value with a step, like 5/15, runs from that value to the end of the range.
+			if !hasStep {
+				hi = value
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			mask |= 1 << uint(v)
+		}
+	}
+	return mask, nil
+}
+
+func cronValue(s string, spec cronField) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q in %s field", s, spec.name)
+	}
+	if n < spec.min || n > spec.max {
+		return 0, fmt.Errorf("%s value %d is out of range %d-%d", spec.name, n, spec.min, spec.max)
+	}
+	return n, nil
+}
+
+// Next returns the first matching minute strictly after t, or the zero time if none comes within
+// cronSearchLimit.
+func (c *cronSchedule) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+	for next.Before(limit) {
+		if c.month&(1<<uint(next.Month())) == 0 {
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+			continue
+		}
+		if !c.matchesDay(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+			continue
+		}
+		if c.hour&(1<<uint(next.Hour())) == 0 {
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+			continue
+		}
+		if c.minute&(1<<uint(next.Minute())) == 0 {
+			next = next.Add(time.Minute)
+			continue
+		}
+		return next
+	}
+	return time.Time{}
+}
+
+func (c *cronSchedule) matchesDay(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
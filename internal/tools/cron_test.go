@@ -0,0 +1,46 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron(t *testing.T) {
+	from := time.Date(2026, time.March, 10, 14, 7, 30, 0, time.UTC) // a Tuesday
+
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2026, time.March, 10, 14, 8, 0, 0, time.UTC)},
+		{"*/5 * * * *", time.Date(2026, time.March, 10, 14, 10, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2026, time.March, 11, 9, 0, 0, 0, time.UTC)},
+		{"30 8-10,16 * * *", time.Date(2026, time.March, 10, 16, 30, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 */3 *", time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 13 * 5", time.Date(2026, time.March, 13, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"10/20 * * * *", time.Date(2026, time.March, 10, 14, 10, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, time.March, 10, 15, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			schedule, err := parseCron(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.next, schedule.Next(from))
+		})
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		_, err := parseCron(expr)
+		assert.Error(t, err, expr)
+	}
+
+	never, err := parseCron("0 0 31 2 *")
+	require.NoError(t, err)
+	assert.True(t, never.Next(from).IsZero())
+}
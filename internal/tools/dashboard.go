@@ -0,0 +1,103 @@
+package tools
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+)
+
+// DashboardPath is where DashboardHandler is mounted.
+const DashboardPath = "/ui"
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// dashboardState is the JSON served at /ui/state.
+type dashboardState struct {
+	Shells []shellInfo     `json:"shells"`
+	Calls  []CallRecord    `json:"calls"`
+	Config dashboardConfig `json:"config"`
+}
+
+// dashboardConfig reports the server's limits and policies, with current usage next to each limit.
+type dashboardConfig struct {
+	MaxFileSize           int64    `json:"max_file_size"`
+	MaxOutputTokens       int      `json:"max_output_tokens"`
+	MaxResults            int      `json:"max_results"`
+	MaxConcurrentCommands int      `json:"max_concurrent_commands"`
+	RunningCommands       int      `json:"running_commands"`
+	MaxBackgroundShells   int      `json:"max_background_shells"`
+	RunningShells         int      `json:"running_shells"`
+	QueuedShells          int      `json:"queued_shells"`
+	OverlayMode           bool     `json:"overlay_mode"`
+	BackupByDefault       bool     `json:"backup_by_default"`
+	SpoolByDefault        bool     `json:"spool_by_default"`
+	LoginShell            bool     `json:"login_shell"`
+	AllowedShells         []string `json:"allowed_shells"`
+	DeniedPaths           int      `json:"denied_paths"`
+	ConfirmDeletes        bool     `json:"confirm_deletes"`
+	ConfirmCommands       int      `json:"confirm_commands"`
+	ApprovalRules         int      `json:"approval_rules"`
+}
+
+// DashboardHandler serves a web UI at /ui showing background shells, whose output it tails from
+// the ShellStreamHandler endpoint, recent tool calls, and the server's limits. The page polls
+// /ui/state for its data.
+func (s *State) DashboardHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+DashboardPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write(dashboardHTML)
+	})
+	mux.HandleFunc("GET "+DashboardPath+"/state", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(s.dashboardState())
+	})
+	return mux
+}
+
+// dashboardState collects the shells, newest first, the recent calls, newest first, and the
+// configuration shown by the dashboard.
+func (s *State) dashboardState() dashboardState {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	state := dashboardState{
+		Shells: make([]shellInfo, 0, len(s.BackgroundShells)),
+		Calls:  slices.Clone(s.RecentCalls),
+		Config: dashboardConfig{
+			MaxFileSize:           s.MaxFileSize,
+			MaxOutputTokens:       s.MaxOutputSize / 4,
+			MaxResults:            s.MaxResults,
+			MaxConcurrentCommands: s.MaxConcurrentCommands,
+			RunningCommands:       s.RunningCommands,
+			MaxBackgroundShells:   s.MaxBackgroundShells,
+			RunningShells:         s.RunningShells,
+			QueuedShells:          len(s.shellQueue),
+			OverlayMode:           s.OverlayMode,
+			BackupByDefault:       s.BackupByDefault,
+			SpoolByDefault:        s.SpoolByDefault,
+			LoginShell:            s.LoginShell,
+			AllowedShells:         s.AllowedShells,
+			DeniedPaths:           len(s.DeniedPaths),
+			ConfirmDeletes:        s.ConfirmDeletes,
+			ConfirmCommands:       len(s.ConfirmCommands),
+			ApprovalRules:         len(s.ApprovalRules),
+		},
+	}
+	if state.Calls == nil {
+		state.Calls = []CallRecord{}
+	}
+	slices.Reverse(state.Calls)
+	if state.Config.AllowedShells == nil {
+		state.Config.AllowedShells = supportedShells()
+	}
+	for _, shell := range s.BackgroundShells {
+		state.Shells = append(state.Shells, describeShell(shell, shellStatus(shell)))
+	}
+	sort.Slice(state.Shells, func(i, j int) bool { return state.Shells[i].seq > state.Shells[j].seq })
+	return state
+}
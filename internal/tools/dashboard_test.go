@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboard(t *testing.T) {
+	state := NewState()
+	state.MaxBackgroundShells = 4
+	server := httptest.NewServer(state.DashboardHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/ui")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+	assert.Contains(t, string(body), "/ui/state")
+
+	_, err = state.executeBashCommand(context.Background(), "echo hi", "Say hi", 0, true, bashOptions{})
+	require.NoError(t, err)
+	state.recordCall(CallRecord{Tool: "read"})
+	state.recordCall(CallRecord{Tool: "bash"})
+
+	resp, err = http.Get(server.URL + "/ui/state")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var parsed dashboardState
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&parsed))
+	require.Len(t, parsed.Shells, 1)
+	assert.Equal(t, "shell_1", parsed.Shells[0].ID)
+	assert.Equal(t, "Say hi", parsed.Shells[0].Description)
+	// Newest calls come first.
+	require.Len(t, parsed.Calls, 2)
+	assert.Equal(t, "bash", parsed.Calls[0].Tool)
+	assert.Equal(t, 4, parsed.Config.MaxBackgroundShells)
+	assert.NotEmpty(t, parsed.Config.AllowedShells)
+}
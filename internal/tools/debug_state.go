@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sort"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type debugReadFile struct {
+	Path      string `json:"path"`
+	ModTime   string `json:"mtime"`
+	MergeBase bool   `json:"merge_base,omitempty"`
+}
+
+type debugLock struct {
+	Path string `json:"path"`
+	// Waiters counts the calls holding or waiting for the lock.
+	Waiters int `json:"waiters"`
+}
+
+type debugSession struct {
+	ID          string `json:"id"`
+	Todos       int    `json:"todos,omitempty"`
+	Edits       int    `json:"edits,omitempty"`
+	Changes     int    `json:"changes,omitempty"`
+	StagedFiles int    `json:"staged_files,omitempty"`
+	WorkDir     string `json:"work_dir,omitempty"`
+	Watches     int    `json:"watches,omitempty"`
+}
+
+type debugCounters struct {
+	RunningCommands       int    `json:"running_commands"`
+	MaxConcurrentCommands int    `json:"max_concurrent_commands"`
+	RunningShells         int    `json:"running_shells"`
+	MaxBackgroundShells   int    `json:"max_background_shells"`
+	QueuedShells          int    `json:"queued_shells"`
+	Shells                int    `json:"shells"`
+	Repls                 int    `json:"repls"`
+	Watches               int    `json:"watches"`
+	ScheduledJobs         int    `json:"scheduled_jobs"`
+	Snapshots             int    `json:"snapshots"`
+	LanguageServers       int    `json:"language_servers"`
+	LineIndexes           int    `json:"line_indexes"`
+	SemanticIndexes       int    `json:"semantic_indexes"`
+	NextShellID           int    `json:"next_shell_id"`
+	NextEditID            int    `json:"next_edit_id"`
+	Goroutines            int    `json:"goroutines"`
+	HeapBytes             uint64 `json:"heap_bytes"`
+}
+
+type debugStateResult struct {
+	ReadFiles   []debugReadFile `json:"read_files"`
+	Shells      []shellInfo     `json:"shells"`
+	Locks       []debugLock     `json:"locks"`
+	Sessions    []debugSession  `json:"sessions"`
+	Counters    debugCounters   `json:"counters"`
+	OverlayMode bool            `json:"overlay_mode,omitempty"`
+}
+
+// executeDebugState describes the server's tracked state: read files, background shells, held path
+// locks, per-session state, and resource counters.
+func (s *State) executeDebugState(ctx context.Context) (string, error) {
+	result := debugStateResult{ReadFiles: []debugReadFile{}, Shells: []shellInfo{}, Locks: []debugLock{}, Sessions: []debugSession{}}
+	sessions := map[string]*debugSession{}
+	session := func(id string) *debugSession {
+		if sessions[id] == nil {
+			sessions[id] = &debugSession{ID: id}
+		}
+		return sessions[id]
+	}
+
+	s.Mu.RLock()
+	for path, modTime := range s.ReadFiles {
+		content, ok := s.ReadContents[path]
+		result.ReadFiles = append(result.ReadFiles, debugReadFile{Path: path, ModTime: modTime.Format(time.RFC3339Nano), MergeBase: ok && content.modTime.Equal(modTime)})
+	}
+	for _, shell := range s.BackgroundShells {
+		result.Shells = append(result.Shells, describeShell(shell, shellStatus(shell)))
+	}
+	for id, todos := range s.Todos {
+		session(id).Todos = len(todos)
+	}
+	for id, edits := range s.EditHistory {
+		session(id).Edits = len(edits)
+	}
+	for id, changes := range s.Changes {
+		session(id).Changes = len(changes)
+	}
+	for id, overlay := range s.Overlays {
+		if len(overlay) > 0 {
+			session(id).StagedFiles = len(overlay)
+		}
+	}
+	for id, dir := range s.WorkDirs {
+		session(id).WorkDir = dir
+	}
+	for _, watch := range s.Watches {
+		session(watch.SessionID).Watches++
+	}
+	result.OverlayMode = s.OverlayMode
+	result.Counters = debugCounters{
+		RunningCommands:       s.RunningCommands,
+		MaxConcurrentCommands: s.MaxConcurrentCommands,
+		RunningShells:         s.RunningShells,
+		MaxBackgroundShells:   s.MaxBackgroundShells,
+		QueuedShells:          len(s.shellQueue),
+		Shells:                len(s.BackgroundShells),
+		Repls:                 len(s.Repls),
+		Watches:               len(s.Watches),
+		ScheduledJobs:         len(s.ScheduledJobs),
+		Snapshots:             len(s.Snapshots),
+		LineIndexes:           len(s.LineIndexes),
+		SemanticIndexes:       len(s.SemanticIndexes),
+		NextShellID:           s.NextShellID,
+		NextEditID:            s.NextEditID,
+	}
+	s.Mu.RUnlock()
+
+	s.pathLocksMu.Lock()
+	for path, lock := range s.pathLocks {
+		result.Locks = append(result.Locks, debugLock{Path: path, Waiters: lock.refs})
+	}
+	s.pathLocksMu.Unlock()
+	s.lspMu.Lock()
+	result.Counters.LanguageServers = len(s.lspClients)
+	s.lspMu.Unlock()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	result.Counters.Goroutines = runtime.NumGoroutine()
+	result.Counters.HeapBytes = mem.HeapAlloc
+
+	for _, sess := range sessions {
+		result.Sessions = append(result.Sessions, *sess)
+	}
+	sort.Slice(result.ReadFiles, func(i, j int) bool { return result.ReadFiles[i].Path < result.ReadFiles[j].Path })
+	sort.Slice(result.Shells, func(i, j int) bool { return result.Shells[i].seq < result.Shells[j].seq })
+	sort.Slice(result.Locks, func(i, j int) bool { return result.Locks[i].Path < result.Locks[j].Path })
+	sort.Slice(result.Sessions, func(i, j int) bool { return result.Sessions[i].ID < result.Sessions[j].ID })
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format server state: %s", err)
+	}
+	output := string(jsonBytes)
+	if err := checkOutputSize(ctx, output, "debug_state"); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+var DebugStateTool = sdk.Tool{
+	Name:        "debug_state",
+	Description: "Dumps the server's internal state as JSON, for operators and integration tests checking server behavior.\n\nUsage:\n- Only available when the server runs with --debug-tools.\n- Lists the files tracked as read with their recorded modification times, background shells, path locks currently held or waited for, per-session state (todos, edit history, changes, staged overlay files, working directory, watches), and resource counters such as running commands and shells, queued shells, REPLs, language servers, goroutines, and heap size.",
+}
+
+type DebugStateInput struct{}
+type DebugStateOutput struct {
+	Result string `json:"result"`
+}
+
+func DebugState(ctx context.Context, req *sdk.CallToolRequest, args DebugStateInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeDebugState(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &DebugStateOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
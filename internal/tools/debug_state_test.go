@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugState(t *testing.T) {
+	state := NewState()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(path, []byte("hello\n"), 0o644))
+	_, err := state.executeRead(ctx, path, 0, 0)
+	require.NoError(t, err)
+	_, err = state.executeEdit(ctx, path, editItem{OldString: "hello", NewString: "bye"}, false, false)
+	require.NoError(t, err)
+	unlock := state.lockPaths(context.Background(), path)
+	defer unlock()
+
+	result, err := state.executeDebugState(ctx)
+	require.NoError(t, err)
+	var parsed debugStateResult
+	require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+
+	require.Len(t, parsed.ReadFiles, 1)
+	assert.Equal(t, path, parsed.ReadFiles[0].Path)
+	assert.True(t, parsed.ReadFiles[0].MergeBase)
+	require.Len(t, parsed.Locks, 1)
+	assert.Equal(t, path, parsed.Locks[0].Path)
+	assert.Equal(t, 1, parsed.Locks[0].Waiters)
+	require.Len(t, parsed.Sessions, 1)
+	assert.Equal(t, defaultSessionID, parsed.Sessions[0].ID)
+	assert.Equal(t, 1, parsed.Sessions[0].Edits)
+	assert.Empty(t, parsed.Shells)
+	assert.Positive(t, parsed.Counters.Goroutines)
+}
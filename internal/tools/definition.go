@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func (s *State) executeDefinition(ctx context.Context, filePath string, line int, symbol string, column int) (string, error) {
+	client, target, err := s.lspTarget(ctx, filePath, line, symbol, column)
+	if err != nil {
+		return "", err
+	}
+	var raw json.RawMessage
+	if err := client.call(ctx, "textDocument/definition", target, &raw); err != nil {
+		return "", err
+	}
+	links, err := parseDefinitionResult(raw)
+	if err != nil {
+		return "", fmt.Errorf("%s returned an invalid definition response: %s", client.name, err)
+	}
+	locations, err := s.lspLocations(links, client.encoding)
+	if err != nil {
+		return "", err
+	}
+	if len(locations) == 0 {
+		return "No definition found.", nil
+	}
+
+	jsonBytes, err := json.MarshalIndent(map[string]any{"definitions": locations}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format definitions: %s", err)
+	}
+	return string(jsonBytes), nil
+}
+
+// parseDefinitionResult normalizes the shapes a definition response may take: null, a Location, a
+// list of Locations, or a list of LocationLinks, whose target selection range is the name of the
+// definition.
+func parseDefinitionResult(raw json.RawMessage) ([]lspRawLocation, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if raw[0] != '[' {
+		var single lspRawLocation
+		if err := json.Unmarshal(raw, &single); err != nil {
+			return nil, err
+		}
+		return []lspRawLocation{single}, nil
+	}
+	var items []struct {
+		lspRawLocation
+		TargetURI            string   `json:"targetUri"`
+		TargetSelectionRange lspRange `json:"targetSelectionRange"`
+	}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	locations := make([]lspRawLocation, len(items))
+	for i, item := range items {
+		locations[i] = item.lspRawLocation
+		if item.TargetURI != "" {
+			locations[i] = lspRawLocation{URI: item.TargetURI, Range: item.TargetSelectionRange}
+		}
+	}
+	return locations, nil
+}
+
+var DefinitionTool = sdk.Tool{
+	Name:        "definition",
+	Description: "Finds where a symbol is defined, using the project's language server, so you can jump to the exact declaration instead of grepping for its name.\n\nUsage:\n- Give the file, the 1-based line where the symbol is used, and the symbol's name as it appears on that line (or a 1-based column instead).\n- Returns each definition's file, line, column, and the text of its first line; read around that line to see the full declaration.\n- Language servers are started on demand for the file's project: gopls for Go, typescript-language-server for JavaScript and TypeScript, pyright for Python, rust-analyzer for Rust, and clangd for C and C++. The first call for a project may take a while as the server loads it.",
+}
+
+// LSPPositionInput identifies a symbol for the language server tools.
+type LSPPositionInput struct {
+	FilePath string `json:"file_path" jsonschema:"The absolute path to the file containing the symbol"`
+	Line     int    `json:"line" jsonschema:"The 1-based line number where the symbol appears"`
+	Symbol   string `json:"symbol,omitempty" jsonschema:"The symbol's name as it appears on the line; its first occurrence is used"`
+	Column   int    `json:"column,omitempty" jsonschema:"The 1-based character column of the symbol, used when symbol is not given"`
+}
+type DefinitionOutput struct {
+	Result string `json:"result"`
+}
+
+func Definition(ctx context.Context, req *sdk.CallToolRequest, args LSPPositionInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeDefinition(ctx, args.FilePath, args.Line, args.Symbol, args.Column)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &DefinitionOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
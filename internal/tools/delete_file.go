@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func (s *State) executeDeleteFile(ctx context.Context, path string, recursive, backup bool) (string, error) {
+	resolved, err := s.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	defer s.lockPaths(ctx, resolved)()
+
+	// Guard against catastrophic deletes that no workflow legitimately needs.
+	if resolved == filepath.Dir(resolved) {
+		return "", fmt.Errorf("refusing to delete the filesystem root")
+	}
+	if home, err := os.UserHomeDir(); err == nil && resolved == filepath.Clean(home) {
+		return "", fmt.Errorf("refusing to delete the home directory")
+	}
+	if err := s.checkTreeAllowed(resolved); err != nil {
+		return "", err
+	}
+	if s.overlayEnabled() {
+		return s.stageDelete(ctx, resolved)
+	}
+
+	fsys := s.filesystem()
+	info, err := fsys.Lstat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("file does not exist")
+	}
+
+	if info.IsDir() && !recursive {
+		if entries, err := fsys.ReadDir(resolved); err == nil && len(entries) > 0 {
+			return "", fmt.Errorf("directory is not empty, set recursive to true to delete it and its contents")
+		}
+	}
+
+	if err := s.confirmDelete(ctx, resolved, info.IsDir()); err != nil {
+		return "", err
+	}
+
+	var backupPath string
+	if backup {
+		if backupPath, err = s.backupFile(ctx, resolved, false); err != nil {
+			return "", err
+		}
+	}
+	s.noteChange(ctx, "delete_file", resolved)
+
+	if info.IsDir() {
+		if recursive {
+			err = fsys.RemoveAll(resolved)
+		} else {
+			// os.Remove only succeeds on empty directories, which is exactly the safe case.
+			err = fsys.Remove(resolved)
+			if err != nil {
+				return "", fmt.Errorf("directory is not empty, set recursive to true to delete it and its contents")
+			}
+		}
+	} else {
+		err = fsys.Remove(resolved)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Cannot delete: %s", err)
+	}
+
+	s.forgetPath(resolved)
+
+	message := "File deleted successfully: " + resolved
+	if info.IsDir() {
+		message = "Directory deleted successfully: " + resolved
+	}
+	if backupPath != "" {
+		message += " (backed up to " + backupPath + ")"
+	}
+	return message, nil
+}
+
+var DeleteFileTool = sdk.Tool{
+	Name:        "delete_file",
+	Description: "Deletes a file or directory.\n\nUsage:\n- The path must be absolute.\n- Symlinks are removed without touching their targets.\n- Empty directories can be deleted directly; set recursive to true to delete a directory and everything in it.\n- Deleting the filesystem root or home directory is always refused.\n- The server may be configured to ask the user to confirm deletions; a declined deletion returns an error and must not be retried another way.\n- Set backup to true to keep a copy that restore_backup can bring back.\n- Use this tool instead of running rm via Bash.",
+}
+
+type DeleteFileInput struct {
+	Path      string `json:"path" jsonschema:"The absolute path of the file or directory to delete"`
+	Recursive bool   `json:"recursive,omitempty" jsonschema:"Delete a non-empty directory and all of its contents"`
+	Backup    *bool  `json:"backup,omitempty" jsonschema:"Save a copy before deleting, restorable with restore_backup. Defaults to the server setting"`
+}
+type DeleteFileOutput struct {
+	Message string `json:"message"`
+}
+
+func DeleteFile(ctx context.Context, req *sdk.CallToolRequest, args DeleteFileInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeDeleteFile(withSession(ctx, req), args.Path, args.Recursive, server.useBackup(args.Backup))
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &DeleteFileOutput{Message: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
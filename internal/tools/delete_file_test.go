@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteFile_BasicFunctionality(t *testing.T) {
+	t.Run("deletes file", func(t *testing.T) {
+		state, path := setupTestFile(t, "content")
+		_, err := state.executeRead(context.Background(), path, 0, 0)
+		require.NoError(t, err)
+		result, err := state.executeDeleteFile(context.Background(), path, false, false)
+		require.NoError(t, err)
+		assert.Contains(t, result, "File deleted successfully")
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+		state.Mu.RLock()
+		_, tracked := state.ReadFiles[path]
+		state.Mu.RUnlock()
+		assert.False(t, tracked)
+	})
+	t.Run("deletes empty directory", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "empty")
+		require.NoError(t, os.Mkdir(dir, 0o755))
+		result, err := NewState().executeDeleteFile(context.Background(), dir, false, false)
+		require.NoError(t, err)
+		assert.Contains(t, result, "Directory deleted successfully")
+	})
+	t.Run("deletes directory recursively", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "full")
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "f.txt"), []byte("x"), 0o644))
+		_, err := NewState().executeDeleteFile(context.Background(), dir, true, false)
+		require.NoError(t, err)
+		_, err = os.Stat(dir)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestDeleteFile_Errors(t *testing.T) {
+	state := NewState()
+	t.Run("non-empty directory without recursive", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "f.txt"), []byte("x"), 0o644))
+		_, err := state.executeDeleteFile(context.Background(), dir, false, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not empty")
+	})
+	t.Run("missing file", func(t *testing.T) {
+		_, err := state.executeDeleteFile(context.Background(), filepath.Join(t.TempDir(), "missing"), false, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not exist")
+	})
+	t.Run("filesystem root", func(t *testing.T) {
+		_, err := state.executeDeleteFile(context.Background(), "/", true, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "refusing")
+	})
+	t.Run("relative path", func(t *testing.T) {
+		_, err := state.executeDeleteFile(context.Background(), "file.txt", false, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be absolute")
+	})
+}
@@ -0,0 +1,176 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// defaultDeniedPaths holds credentials and secrets that file tools refuse to touch unless the
+// server is configured otherwise. A leading ~/ refers to the home directory of the server process.
+var defaultDeniedPaths = []string{
+	"~/.ssh",
+	"~/.aws",
+	"~/.gnupg",
+	"~/.kube",
+	"~/.docker/config.json",
+	"/etc/shadow",
+	"/etc/gshadow",
+	"/etc/sudoers",
+	"*.pem",
+}
+
+// DeniedPaths returns the denylist made of extra patterns, following the built-in defaults when
+// includeDefaults is set.
+func DeniedPaths(extra []string, includeDefaults bool) []string {
+	var patterns []string
+	if includeDefaults {
+		patterns = append(patterns, defaultDeniedPaths...)
+	}
+	return append(patterns, extra...)
+}
+
+// SetDeniedPaths replaces the path denylist. Patterns are absolute paths or globs, which also deny
+// everything beneath a matching directory, or slash-free globs such as "*.pem", which match a file
+// or directory name at any depth. A leading ~/ is expanded to the home directory.
+func (s *State) SetDeniedPaths(patterns []string) error {
+	expanded, err := expandDeniedPaths(patterns)
+	if err != nil {
+		return err
+	}
+	s.Mu.Lock()
+	s.DeniedPaths = expanded
+	s.Mu.Unlock()
+	return nil
+}
+
+func expandDeniedPaths(patterns []string) ([]string, error) {
+	var expanded []string
+	for _, pattern := range patterns {
+		if rest, ok := strings.CutPrefix(pattern, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				// Without a home directory there is nothing for the pattern to protect.
+				continue
+			}
+			pattern = filepath.Join(home, rest)
+		}
+		if strings.Contains(pattern, "/") && !filepath.IsAbs(pattern) {
+			return nil, fmt.Errorf("Invalid denied path pattern: %s. Must be absolute, start with ~/, or be a file name pattern without slashes", pattern)
+		}
+		if !doublestar.ValidatePattern(filepath.ToSlash(pattern)) {
+			return nil, fmt.Errorf("Invalid denied path pattern: %s", pattern)
+		}
+		expanded = append(expanded, filepath.Clean(pattern))
+	}
+	return expanded, nil
+}
+
+// checkPathAllowed returns a policy error when any of paths, or the file a symlink among them
+// points to, is covered by the denylist.
+func (s *State) checkPathAllowed(paths ...string) error {
+	s.Mu.RLock()
+	patterns, fsys := s.DeniedPaths, s.FS
+	s.Mu.RUnlock()
+	if len(patterns) == 0 {
+		return nil
+	}
+	for _, path := range paths {
+		candidates := []string{path}
+		if real, err := fsys.EvalSymlinks(path); err == nil && real != path {
+			candidates = append(candidates, real)
+		}
+		for _, candidate := range candidates {
+			if pattern, denied := deniedBy(patterns, candidate); denied {
+				return fmt.Errorf("Access denied by policy: %s matches the denied path pattern %q", path, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// checkTreeAllowed is checkPathAllowed for root and, when it is a directory, everything beneath
+// it, for operations such as recursive deletes that act on a whole tree. Symlinks inside the tree
+// are judged by their own path, since removing a link leaves its target alone.
+func (s *State) checkTreeAllowed(root string) error {
+	if err := s.checkPathAllowed(root); err != nil {
+		return err
+	}
+	s.Mu.RLock()
+	patterns, fsys := s.DeniedPaths, s.FS
+	s.Mu.RUnlock()
+	if len(patterns) == 0 {
+		return nil
+	}
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := fsys.ReadDir(dir)
+		if err != nil {
+			return nil
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if pattern, denied := deniedBy(patterns, path); denied {
+				return fmt.Errorf("Access denied by policy: %s contains %s, which matches the denied path pattern %q", root, path, pattern)
+			}
+			if entry.IsDir() {
+				if err := walk(path); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if info, err := fsys.Lstat(root); err == nil && info.IsDir() {
+		return walk(root)
+	}
+	return nil
+}
+
+// deniedBy reports the first pattern that covers target, either directly or through one of its
+// ancestor directories.
+func deniedBy(patterns []string, target string) (string, bool) {
+	target = filepath.ToSlash(target)
+	for _, pattern := range patterns {
+		slashPattern := filepath.ToSlash(pattern)
+		for p := target; ; p = path.Dir(p) {
+			var match bool
+			if strings.Contains(slashPattern, "/") {
+				match, _ = doublestar.Match(slashPattern, p)
+			} else {
+				match, _ = doublestar.Match(slashPattern, path.Base(p))
+			}
+			if match {
+				return pattern, true
+			}
+			if p == "/" || p == "." {
+				break
+			}
+		}
+	}
+	return "", false
+}
+
+// ripgrepDenyGlobs translates the denylist into ripgrep exclusion globs. Ripgrep matches globs
+// relative to its working directory cwd when a file lies beneath it and against the full path
+// otherwise, so absolute patterns are anchored accordingly.
+func ripgrepDenyGlobs(patterns []string, cwd string) []string {
+	var globs []string
+	for _, pattern := range patterns {
+		slashPattern := filepath.ToSlash(pattern)
+		switch {
+		case !strings.Contains(slashPattern, "/"):
+			globs = append(globs, "!"+slashPattern)
+		case cwd != "" && strings.HasPrefix(pattern, cwd+string(filepath.Separator)):
+			rel, _ := filepath.Rel(cwd, pattern)
+			globs = append(globs, "!/"+filepath.ToSlash(rel))
+		default:
+			globs = append(globs, "!**"+slashPattern)
+		}
+	}
+	return globs
+}
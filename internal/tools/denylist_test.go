@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeniedBy(t *testing.T) {
+	patterns := []string{"/home/user/.ssh", "/etc/shadow", "*.pem", "/srv/**/secrets"}
+	tests := []struct {
+		path   string
+		denied bool
+	}{
+		{"/home/user/.ssh", true},
+		{"/home/user/.ssh/id_ed25519", true},
+		{"/home/user/.sshd/config", false},
+		{"/etc/shadow", true},
+		{"/etc/passwd", false},
+		{"/opt/tls/server.pem", true},
+		{"/opt/certs.pem/readme", true},
+		{"/opt/tls/server.crt", false},
+		{"/srv/app/config/secrets/db.txt", true},
+		{"/srv/app/config/public.txt", false},
+	}
+	for _, tt := range tests {
+		_, denied := deniedBy(patterns, tt.path)
+		assert.Equal(t, tt.denied, denied, tt.path)
+	}
+}
+
+func TestSetDeniedPaths(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	state := NewState()
+	require.NoError(t, state.SetDeniedPaths([]string{"~/.netrc", "*.key"}))
+	assert.Equal(t, []string{filepath.Join(home, ".netrc"), "*.key"}, state.DeniedPaths)
+
+	require.Error(t, state.SetDeniedPaths([]string{"relative/path"}))
+
+	assert.Equal(t, []string{"extra"}, DeniedPaths([]string{"extra"}, false))
+	assert.Equal(t, len(defaultDeniedPaths)+1, len(DeniedPaths([]string{"extra"}, true)))
+}
+
+func TestDenylist_FileTools(t *testing.T) {
+	dir := t.TempDir()
+	secrets := filepath.Join(dir, "secrets")
+	require.NoError(t, os.MkdirAll(secrets, 0o755))
+	secret := filepath.Join(secrets, "token.txt")
+	require.NoError(t, os.WriteFile(secret, []byte("hunter2"), 0o644))
+	public := filepath.Join(dir, "public.txt")
+	require.NoError(t, os.WriteFile(public, []byte("hello"), 0o644))
+	link := filepath.Join(dir, "link.txt")
+	require.NoError(t, os.Symlink(secret, link))
+
+	state := NewState()
+	require.NoError(t, state.SetDeniedPaths([]string{secrets}))
+	ctx := context.Background()
+
+	t.Run("read", func(t *testing.T) {
+		_, err := state.executeRead(ctx, secret, 0, 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Access denied by policy")
+
+		// Symlinks into a denied directory are refused too
+		_, err = state.executeRead(ctx, link, 0, 0)
+		require.Error(t, err)
+
+		_, err = state.executeRead(ctx, public, 0, 0)
+		require.NoError(t, err)
+	})
+
+	t.Run("write and edit", func(t *testing.T) {
+		_, err := state.executeWrite(ctx, filepath.Join(secrets, "new.txt"), "x", writeOptions{})
+		require.Error(t, err)
+		assert.NoFileExists(t, filepath.Join(secrets, "new.txt"))
+
+		_, err = state.executeEdit(ctx, secret, editItem{OldString: "hunter2", NewString: "x"}, false, false)
+		require.Error(t, err)
+	})
+
+	t.Run("copy out of a denied directory", func(t *testing.T) {
+		_, err := state.executeCopyFile(ctx, secret, filepath.Join(dir, "copy.txt"), false)
+		require.Error(t, err)
+	})
+
+	t.Run("glob skips denied files", func(t *testing.T) {
+		result, err := state.executeGlob(ctx, "**/*.txt", dir, 0, 0, false)
+		require.NoError(t, err)
+		assert.Contains(t, result, "public.txt")
+		assert.NotContains(t, result, "token.txt")
+
+		_, err = state.executeGlob(ctx, "*", secrets, 0, 0, false)
+		require.Error(t, err)
+	})
+
+	t.Run("ls skips denied entries", func(t *testing.T) {
+		result, err := state.executeLs(ctx, dir, false, 2, "", false)
+		require.NoError(t, err)
+		assert.Contains(t, result, "public.txt")
+		assert.NotContains(t, result, "secrets")
+
+		_, err = state.executeLs(ctx, secrets, false, 1, "", false)
+		assert.ErrorContains(t, err, "Access denied by policy")
+	})
+
+	t.Run("create_directory", func(t *testing.T) {
+		_, err := state.executeCreateDirectory(ctx, filepath.Join(secrets, "sub"), false, "")
+		assert.ErrorContains(t, err, "Access denied by policy")
+		assert.NoDirExists(t, filepath.Join(secrets, "sub"))
+	})
+
+	t.Run("restore_backup", func(t *testing.T) {
+		_, err := state.executeRestoreBackup(ctx, secret)
+		assert.ErrorContains(t, err, "Access denied by policy")
+	})
+
+	t.Run("delete_file", func(t *testing.T) {
+		_, err := state.executeDeleteFile(ctx, secret, false, false)
+		assert.ErrorContains(t, err, "Access denied by policy")
+
+		// Deleting a directory that contains a denied path is refused as a whole.
+		_, err = state.executeDeleteFile(ctx, dir, true, false)
+		assert.ErrorContains(t, err, "Access denied by policy")
+		assert.FileExists(t, secret)
+		assert.FileExists(t, public)
+	})
+}
+
+func TestDenylist_Git(t *testing.T) {
+	state, dir := setupGitRepo(t)
+	ctx := context.Background()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "server.pem"), []byte("key\n"), 0o644))
+	runGitCmd(t, dir, "add", "server.pem")
+	runGitCmd(t, dir, "commit", "-q", "-m", "Add key")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "server.pem"), []byte("new key\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("changed\n"), 0o644))
+	require.NoError(t, state.SetDeniedPaths([]string{"*.pem"}))
+
+	_, err := state.executeGit(ctx, "blame", dir, []string{"server.pem"}, "", false, 0, 0, 0, "", false, false)
+	assert.ErrorContains(t, err, "Access denied by policy")
+	_, err = state.executeGit(ctx, "diff", dir, []string{"server.pem"}, "", false, 0, 0, 0, "", false, false)
+	assert.ErrorContains(t, err, "Access denied by policy")
+
+	var show gitShowResult
+	callGit(t, state, GitInput{Operation: "show", Path: dir}, &show)
+	assert.Equal(t, "Add key", show.Commit.Subject)
+	assert.Empty(t, show.Files)
+
+	var diff []gitDiffFile
+	callGit(t, state, GitInput{Operation: "diff", Path: dir}, &diff)
+	require.Len(t, diff, 1)
+	assert.Equal(t, "hello.txt", diff[0].Path)
+}
+
+func TestRipgrepDenyGlobs(t *testing.T) {
+	globs := ripgrepDenyGlobs([]string{"*.pem", "/work/project/.env", "/home/user/.ssh"}, "/work")
+	assert.Equal(t, []string{"!*.pem", "!/project/.env", "!**/home/user/.ssh"}, globs)
+}
@@ -0,0 +1,478 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gabriel-vasile/mimetype"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultDiffContext = 3
+	maxDiffContext     = 100
+
+	// maxDiffEdits bounds the edit distance the diff search explores, since its memory grows with
+	// the square of the distance. Inputs that differ more are reported as one replacement of the
+	// differing region, which is what a reader would make of such a diff anyway.
+	maxDiffEdits = 2000
+
+	defaultSideBySideWidth = 160
+	minSideBySideWidth     = 40
+)
+
+// diffOp is one line of an edit script: kept (' '), deleted ('-'), or inserted ('+').
+type diffOp struct {
+	kind byte
+	text string
+}
+
+type diffResult struct {
+	OldPath   string    `json:"old_path"`
+	NewPath   string    `json:"new_path"`
+	Identical bool      `json:"identical"`
+	Binary    bool      `json:"binary,omitempty"`
+	Additions int       `json:"additions"`
+	Deletions int       `json:"deletions"`
+	Hunks     []gitHunk `json:"hunks"`
+	Diff      string    `json:"diff,omitempty"`
+}
+
+func (s *State) executeDiff(ctx context.Context, oldPath, newPath string, content *string, contextLines int, format string, width int) (string, error) {
+	if (newPath == "") == (content == nil) {
+		return "", fmt.Errorf("Specify exactly one of new_path or content.")
+	}
+	if contextLines < 0 || contextLines > maxDiffContext {
+		return "", fmt.Errorf("context must be between 0 and %d", maxDiffContext)
+	}
+	switch format {
+	case "", "unified", "side_by_side":
+	default:
+		return "", fmt.Errorf("Invalid format: %s. Must be one of: unified, side_by_side.", format)
+	}
+	if width == 0 {
+		width = defaultSideBySideWidth
+	}
+	if width < minSideBySideWidth {
+		return "", fmt.Errorf("width must be at least %d", minSideBySideWidth)
+	}
+
+	oldName, oldData, err := s.readDiffInput(ctx, oldPath)
+	if err != nil {
+		return "", err
+	}
+	newName, newData := "(content)", []byte(nil)
+	if content != nil {
+		newData = []byte(*content)
+	} else if newName, newData, err = s.readDiffInput(ctx, newPath); err != nil {
+		return "", err
+	}
+
+	result := diffResult{OldPath: oldName, NewPath: newName, Hunks: []gitHunk{}}
+	if bytes.Equal(oldData, newData) {
+		result.Identical = true
+	} else if isBinaryContent(oldData) || isBinaryContent(newData) {
+		result.Binary = true
+	} else {
+		ops := diffLines(splitDiffLines(oldData), splitDiffLines(newData))
+		result.Hunks = diffHunks(ops, contextLines)
+		for _, op := range ops {
+			switch op.kind {
+			case '+':
+				result.Additions++
+			case '-':
+				result.Deletions++
+			}
+		}
+		if format == "side_by_side" {
+			result.Diff = renderSideBySide(result.Hunks, width)
+		} else {
+			result.Diff = renderUnified(oldName, newName, result.Hunks)
+		}
+	}
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format diff: %s", err)
+	}
+	output := string(jsonBytes)
+	if err := checkOutputSize(ctx, output, "diff"); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// readDiffInput reads one side of a diff, applying the same path and size checks as Read.
+func (s *State) readDiffInput(ctx context.Context, path string) (string, []byte, error) {
+	resolved, err := s.resolvePath(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", nil, err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", nil, fmt.Errorf("File does not exist: %s", resolved)
+	}
+	if info.IsDir() {
+		return "", nil, fmt.Errorf("path is a directory, not a file: %s", resolved)
+	}
+	if err := s.checkFileSize(ctx, info.Size(), "diff"); err != nil {
+		return "", nil, err
+	}
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		return "", nil, fmt.Errorf("Cannot read file: %s", err)
+	}
+	return resolved, data, nil
+}
+
+// isBinaryContent reports whether data is something other than text, by the same rule Read uses.
+func isBinaryContent(data []byte) bool {
+	return len(data) > 0 && !isTextMIME(mimetype.Detect(data))
+}
+
+// isTextMIME reports whether mtype is plain text or a text format derived from it. The root type,
+// application/octet-stream, has no parent.
+func isTextMIME(mtype *mimetype.MIME) bool {
+	return mtype.Is("text/plain") || (mtype.Parent() != nil && mtype.Parent().Is("text/plain"))
+}
+
+// noNewlineMarker follows a last line that lacks a newline, as in diff(1) output.
+const noNewlineMarker = "\\ No newline at end of file"
+
+// splitDiffLines splits content into lines without their terminators. A last line without a
+// newline carries noNewlineMarker after one, so it differs from the same line with a newline.
+func splitDiffLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	text := string(data)
+	trimmed := strings.TrimSuffix(text, "\n")
+	lines := strings.Split(trimmed, "\n")
+	if trimmed == text {
+		lines[len(lines)-1] += "\n" + noNewlineMarker
+	}
+	return lines
+}
+
+// diffLines returns a shortest edit script turning a into b. Common leading and trailing lines are
+// matched directly, and the rest with Myers' algorithm.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// myersDiff implements the greedy algorithm from Myers' "An O(ND) Difference Algorithm and Its
+// Variations", recording the frontier of each step so the path can be traced back.
+func myersDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxDiffEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	// trace[d] holds the frontier for diagonals -d..d as it was before step d.
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return myersBacktrack(a, b, trace, d)
+			}
+		}
+	}
+
+	// Too many edits to search: replace the whole region.
+	ops := make([]diffOp, 0, n+m)
+	for _, line := range a {
+		ops = append(ops, diffOp{'-', line})
+	}
+	for _, line := range b {
+		ops = append(ops, diffOp{'+', line})
+	}
+	return ops
+}
+
+func myersBacktrack(a, b []string, trace [][]int, depth int) []diffOp {
+	var reversed []diffOp
+	x, y := len(a), len(b)
+	for d := depth; d > 0; d-- {
+		frontier := trace[d]
+		at := func(k int) int { return frontier[k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, diffOp{' ', a[x]})
+		}
+		if prevK == k+1 {
+			reversed = append(reversed, diffOp{'+', b[prevY]})
+		} else {
+			reversed = append(reversed, diffOp{'-', a[prevX]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		reversed = append(reversed, diffOp{' ', a[x]})
+	}
+
+	ops := make([]diffOp, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+// diffHunks groups an edit script into hunks with up to contextLines unchanged lines around each
+// change, merging changes whose context would overlap.
+func diffHunks(ops []diffOp, contextLines int) []gitHunk {
+	hunks := []gitHunk{}
+	oldLine, newLine := 1, 1
+	// oldAt and newAt record the line numbers before each op.
+	oldAt := make([]int, len(ops)+1)
+	newAt := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldAt[i], newAt[i] = oldLine, newLine
+		if op.kind != '+' {
+			oldLine++
+		}
+		if op.kind != '-' {
+			newLine++
+		}
+	}
+	oldAt[len(ops)], newAt[len(ops)] = oldLine, newLine
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		start := max(i-contextLines, 0)
+		end := i
+		// Extend the hunk while the next change is close enough for the contexts to touch.
+		for {
+			for end < len(ops) && ops[end].kind != ' ' {
+				end++
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next < len(ops) && next-end <= 2*contextLines {
+				end = next
+				continue
+			}
+			end = min(end+contextLines, len(ops))
+			break
+		}
+
+		hunk := gitHunk{
+			OldStart: oldAt[start],
+			OldLines: oldAt[end] - oldAt[start],
+			NewStart: newAt[start],
+			NewLines: newAt[end] - newAt[start],
+			Lines:    make([]string, 0, end-start),
+		}
+		// As in diff(1), an empty side starts at the line before the hunk.
+		if hunk.OldLines == 0 {
+			hunk.OldStart--
+		}
+		if hunk.NewLines == 0 {
+			hunk.NewStart--
+		}
+		for _, op := range ops[start:end] {
+			text, marker, _ := strings.Cut(op.text, "\n")
+			hunk.Lines = append(hunk.Lines, string(op.kind)+text)
+			if marker != "" {
+				hunk.Lines = append(hunk.Lines, marker)
+			}
+		}
+		hunks = append(hunks, hunk)
+		i = end
+	}
+	return hunks
+}
+
+func renderUnified(oldName, newName string, hunks []gitHunk) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, hunk := range hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(hunk.OldStart, hunk.OldLines), hunkRange(hunk.NewStart, hunk.NewLines))
+		for _, line := range hunk.Lines {
+			sb.WriteString(line)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// renderSideBySide lays each hunk out in two columns, old on the left and new on the right, with
+// a gutter marking changed (|), deleted (<), and inserted (>) lines as sdiff does. Deletions are
+// paired with the insertions that follow them.
+func renderSideBySide(hunks []gitHunk, width int) string {
+	// Each side has a line number column of 6 characters and a space; the gutter takes 3.
+	column := (width-3)/2 - 7
+	var sb strings.Builder
+	row := func(oldNum int, oldText string, gutter byte, newNum int, newText string) {
+		left, right := "      ", "      "
+		if oldNum > 0 {
+			left = fmt.Sprintf("%6d", oldNum)
+		}
+		if newNum > 0 {
+			right = fmt.Sprintf("%6d", newNum)
+		}
+		fmt.Fprintf(&sb, "%s %s %c %s %s\n", left, padColumn(oldText, column), gutter, right, strings.TrimRight(fitColumn(newText, column), " "))
+	}
+
+	for i, hunk := range hunks {
+		if i > 0 {
+			sb.WriteString(strings.Repeat("-", width) + "\n")
+		}
+		oldNum, newNum := hunk.OldStart, hunk.NewStart
+		if hunk.OldLines == 0 {
+			oldNum++
+		}
+		if hunk.NewLines == 0 {
+			newNum++
+		}
+		lines := hunk.Lines
+		for j := 0; j < len(lines); {
+			if lines[j][0] == '\\' {
+				j++
+				continue
+			}
+			if lines[j][0] == ' ' {
+				row(oldNum, lines[j][1:], ' ', newNum, lines[j][1:])
+				oldNum++
+				newNum++
+				j++
+				continue
+			}
+			var deleted, inserted []string
+			for ; j < len(lines) && (lines[j][0] == '-' || lines[j][0] == '\\'); j++ {
+				if lines[j][0] == '-' {
+					deleted = append(deleted, lines[j][1:])
+				}
+			}
+			for ; j < len(lines) && (lines[j][0] == '+' || lines[j][0] == '\\'); j++ {
+				if lines[j][0] == '+' {
+					inserted = append(inserted, lines[j][1:])
+				}
+			}
+			for k := 0; k < max(len(deleted), len(inserted)); k++ {
+				switch {
+				case k < len(deleted) && k < len(inserted):
+					row(oldNum, deleted[k], '|', newNum, inserted[k])
+					oldNum++
+					newNum++
+				case k < len(deleted):
+					row(oldNum, deleted[k], '<', 0, "")
+					oldNum++
+				default:
+					row(0, "", '>', newNum, inserted[k])
+					newNum++
+				}
+			}
+		}
+	}
+	return sb.String()
+}
+
+// fitColumn truncates text to width characters, expanding tabs so columns stay aligned.
+func fitColumn(text string, width int) string {
+	text = strings.ReplaceAll(text, "\t", "    ")
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:width])
+}
+
+func padColumn(text string, width int) string {
+	text = fitColumn(text, width)
+	return text + strings.Repeat(" ", width-utf8.RuneCountInString(text))
+}
+
+var DiffTool = sdk.Tool{
+	Name:        "diff",
+	Description: "Compares two files, or a file against provided content, and returns the differences as JSON.\n\nUsage:\n- old_path must be an absolute path. Give either new_path (absolute) or content to compare against.\n- Returns structured hunks (start lines, line counts, and lines prefixed with ' ', '-', or '+'), addition and deletion counts, and a rendered diff.\n- format \"unified\" (default) renders a unified diff; \"side_by_side\" renders old and new in two columns of the given total width (default 160), marking changed (|), deleted (<), and inserted (>) lines.\n- context sets how many unchanged lines surround each change (default 3).\n- Binary files are only reported as identical or different.\n- Use this tool instead of running diff via Bash. To see uncommitted changes in a git repository, use the git tool.",
+}
+
+type DiffInput struct {
+	OldPath string  `json:"old_path" jsonschema:"The absolute path of the original file"`
+	NewPath string  `json:"new_path,omitempty" jsonschema:"The absolute path of the file to compare against"`
+	Content *string `json:"content,omitempty" jsonschema:"Content to compare the original file against, instead of new_path"`
+	Context *int    `json:"context,omitempty" jsonschema:"Number of unchanged lines to show around each change (default 3)"`
+	Format  string  `json:"format,omitempty" jsonschema:"Rendering of the diff: 'unified' (default) or 'side_by_side'"`
+	Width   int     `json:"width,omitempty" jsonschema:"Total line width of side_by_side output (default 160)"`
+}
+type DiffOutput struct {
+	Result string `json:"result"`
+}
+
+func Diff(ctx context.Context, req *sdk.CallToolRequest, args DiffInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	contextLines := defaultDiffContext
+	if args.Context != nil {
+		contextLines = *args.Context
+	}
+	result, err := server.executeDiff(ctx, args.OldPath, args.NewPath, args.Content, contextLines, args.Format, args.Width)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &DiffOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func callDiff(t *testing.T, state *State, oldPath, newPath string, content *string, contextLines int, format string) diffResult {
+	t.Helper()
+	result, err := state.executeDiff(context.Background(), oldPath, newPath, content, contextLines, format, 0)
+	require.NoError(t, err)
+	var parsed diffResult
+	require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+	return parsed
+}
+
+func writeDiffFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestDiff_Unified(t *testing.T) {
+	state := NewState()
+	dir := t.TempDir()
+	oldPath := writeDiffFile(t, dir, "old.txt", "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n")
+	newPath := writeDiffFile(t, dir, "new.txt", "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n")
+
+	result := callDiff(t, state, oldPath, newPath, nil, 3, "")
+	assert.False(t, result.Identical)
+	assert.Equal(t, 2, result.Additions)
+	assert.Equal(t, 1, result.Deletions)
+	require.Len(t, result.Hunks, 2)
+	assert.Equal(t, gitHunk{OldStart: 1, OldLines: 5, NewStart: 1, NewLines: 5, Lines: []string{" a", "-b", "+B", " c", " d", " e"}}, result.Hunks[0])
+	assert.Equal(t, gitHunk{OldStart: 8, OldLines: 3, NewStart: 8, NewLines: 4, Lines: []string{" h", " i", " j", "+k"}}, result.Hunks[1])
+	assert.Equal(t, fmt.Sprintf("--- %s\n+++ %s\n@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n@@ -8,3 +8,4 @@\n h\n i\n j\n+k\n", oldPath, newPath), result.Diff)
+
+	t.Run("overlapping context merges hunks", func(t *testing.T) {
+		result := callDiff(t, state, oldPath, newPath, nil, 5, "")
+		require.Len(t, result.Hunks, 1)
+		assert.Equal(t, 1, result.Hunks[0].OldStart)
+		assert.Equal(t, 10, result.Hunks[0].OldLines)
+	})
+
+	t.Run("zero context", func(t *testing.T) {
+		result := callDiff(t, state, oldPath, newPath, nil, 0, "")
+		require.Len(t, result.Hunks, 2)
+		assert.Equal(t, gitHunk{OldStart: 10, OldLines: 0, NewStart: 11, NewLines: 1, Lines: []string{"+k"}}, result.Hunks[1])
+	})
+}
+
+func TestDiff_Content(t *testing.T) {
+	state := NewState()
+	dir := t.TempDir()
+	path := writeDiffFile(t, dir, "file.go", "package main\n\nfunc main() {}\n")
+
+	t.Run("identical", func(t *testing.T) {
+		content := "package main\n\nfunc main() {}\n"
+		result := callDiff(t, state, path, "", &content, 3, "")
+		assert.True(t, result.Identical)
+		assert.Empty(t, result.Hunks)
+		assert.Empty(t, result.Diff)
+	})
+
+	t.Run("missing final newline", func(t *testing.T) {
+		content := "package main\n\nfunc main() {}"
+		result := callDiff(t, state, path, "", &content, 3, "")
+		assert.Equal(t, "(content)", result.NewPath)
+		require.Len(t, result.Hunks, 1)
+		assert.Equal(t, []string{" package main", " ", "-func main() {}", "+func main() {}", noNewlineMarker}, result.Hunks[0].Lines)
+	})
+
+	t.Run("empty content", func(t *testing.T) {
+		content := ""
+		result := callDiff(t, state, path, "", &content, 3, "")
+		assert.Equal(t, 3, result.Deletions)
+		assert.Equal(t, gitHunk{OldStart: 1, OldLines: 3, NewStart: 0, NewLines: 0, Lines: []string{"-package main", "-", "-func main() {}"}}, result.Hunks[0])
+	})
+}
+
+func TestDiff_SideBySide(t *testing.T) {
+	state := NewState()
+	dir := t.TempDir()
+	oldPath := writeDiffFile(t, dir, "old.txt", "keep\nchange me\ndrop\n")
+	newPath := writeDiffFile(t, dir, "new.txt", "keep\nchanged\nadded\n")
+
+	result, err := state.executeDiff(context.Background(), oldPath, newPath, nil, 3, "side_by_side", 60)
+	require.NoError(t, err)
+	var parsed diffResult
+	require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+	lines := strings.Split(strings.TrimSuffix(parsed.Diff, "\n"), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "     1 keep                         1 keep", lines[0])
+	assert.Contains(t, lines[1], "change me")
+	assert.Contains(t, lines[1], " | ")
+	assert.Contains(t, lines[1], "changed")
+	assert.Contains(t, lines[2], " | ")
+}
+
+func TestDiff_LargeInputs(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 500; i++ {
+		a = append(a, fmt.Sprintf("line %d", i))
+		if i%7 != 0 {
+			b = append(b, fmt.Sprintf("line %d", i))
+		}
+		if i%11 == 0 {
+			b = append(b, fmt.Sprintf("new %d", i))
+		}
+	}
+	ops := diffLines(a, b)
+	// Replaying the script must turn a into b.
+	var oldSide, newSide []string
+	for _, op := range ops {
+		if op.kind != '+' {
+			oldSide = append(oldSide, op.text)
+		}
+		if op.kind != '-' {
+			newSide = append(newSide, op.text)
+		}
+	}
+	assert.Equal(t, a, oldSide)
+	assert.Equal(t, b, newSide)
+}
+
+func TestDiff_Validation(t *testing.T) {
+	state := NewState()
+	dir := t.TempDir()
+	path := writeDiffFile(t, dir, "a.txt", "a\n")
+	binary := writeDiffFile(t, dir, "a.bin", "\x00\x01\x02\x03")
+	content := "x"
+
+	_, err := state.executeDiff(context.Background(), path, "", nil, 3, "", 0)
+	assert.ErrorContains(t, err, "exactly one of new_path or content")
+	_, err = state.executeDiff(context.Background(), path, path, &content, 3, "", 0)
+	assert.ErrorContains(t, err, "exactly one of new_path or content")
+	_, err = state.executeDiff(context.Background(), "relative", path, nil, 3, "", 0)
+	assert.ErrorContains(t, err, "must be absolute")
+	_, err = state.executeDiff(context.Background(), path, filepath.Join(dir, "missing"), nil, 3, "", 0)
+	assert.ErrorContains(t, err, "does not exist")
+	_, err = state.executeDiff(context.Background(), path, binary, nil, 3, "html", 0)
+	assert.ErrorContains(t, err, "Invalid format")
+
+	result := callDiff(t, state, path, binary, nil, 3, "")
+	assert.True(t, result.Binary)
+	assert.False(t, result.Identical)
+	assert.Empty(t, result.Diff)
+}
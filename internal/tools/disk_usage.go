@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type duEntry struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Size  int64  `json:"size"`
+	Files int    `json:"files"`
+}
+
+type duResult struct {
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	Files      int       `json:"files"`
+	Entries    []duEntry `json:"entries"`
+	Count      int       `json:"count"`
+	Truncated  bool      `json:"truncated,omitempty"`
+	Unreadable int       `json:"unreadable,omitempty"`
+}
+
+func (s *State) executeDiskUsage(ctx context.Context, path string, depth int, threshold int64) (string, error) {
+	resolved, err := s.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+	info, err := os.Lstat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("path does not exist")
+	}
+
+	if depth <= 0 {
+		depth = 1
+	}
+	if depth > maxLsDepth {
+		return "", fmt.Errorf("depth cannot exceed %d.", maxLsDepth)
+	}
+	if threshold < 0 {
+		return "", fmt.Errorf("threshold cannot be negative")
+	}
+
+	result := duResult{Path: resolved, Entries: []duEntry{}}
+	if !info.IsDir() {
+		result.Size = info.Size()
+		result.Files = 1
+	} else {
+		// Every file's size is added to the root and to each of its ancestors down to depth, so a
+		// single walk yields the total of every reported entry.
+		totals := make(map[string]*duEntry)
+		err := filepath.WalkDir(resolved, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// Unreadable subdirectories are counted rather than failing the whole walk.
+				if p != resolved {
+					result.Unreadable++
+					return nil
+				}
+				return fmt.Errorf("Cannot read directory: %s", err)
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if p == resolved {
+				return nil
+			}
+			rel, err := filepath.Rel(resolved, p)
+			if err != nil {
+				return err
+			}
+			parts := strings.Split(filepath.ToSlash(rel), "/")
+			if len(parts) <= depth {
+				totals[rel] = &duEntry{Name: filepath.ToSlash(rel), Type: fileTypeName(d.Type())}
+			}
+			if d.IsDir() {
+				return nil
+			}
+			fileInfo, err := d.Info()
+			if err != nil {
+				result.Unreadable++
+				return nil
+			}
+			size := fileInfo.Size()
+			result.Size += size
+			result.Files++
+			for i := 1; i <= min(len(parts), depth); i++ {
+				if entry, ok := totals[strings.Join(parts[:i], string(filepath.Separator))]; ok {
+					entry.Size += size
+					entry.Files++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+		for _, entry := range totals {
+			if entry.Size >= threshold {
+				result.Entries = append(result.Entries, *entry)
+			}
+		}
+	}
+
+	sort.Slice(result.Entries, func(i, j int) bool {
+		if result.Entries[i].Size != result.Entries[j].Size {
+			return result.Entries[i].Size > result.Entries[j].Size
+		}
+		return result.Entries[i].Name < result.Entries[j].Name
+	})
+	if maxResults := limitsFromContext(ctx).maxResults; len(result.Entries) > maxResults {
+		result.Entries = result.Entries[:maxResults]
+		result.Truncated = true
+	}
+	result.Count = len(result.Entries)
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format disk usage: %s", err)
+	}
+	output := string(jsonBytes)
+	if err := checkOutputSize(ctx, output, "disk_usage"); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+var DiskUsageTool = sdk.Tool{
+	Name:        "disk_usage",
+	Description: "- Reports how much space the files and directories under a path take up, largest first\n- The path parameter must be an absolute path to a directory or file\n- Each entry has its name relative to path, its type, its total size in bytes, and the number of files it holds; the result also gives the total for path itself\n- Use depth to break down subdirectories (default 1, max 10)\n- Set threshold to omit entries smaller than that many bytes\n- Sizes are apparent file sizes; symlinks are counted but not followed\n- Use this tool instead of running du via Bash",
+}
+
+type DiskUsageInput struct {
+	Path      string `json:"path" jsonschema:"The absolute path to the directory or file to measure"`
+	Depth     int    `json:"depth,omitempty" jsonschema:"How many directory levels to report. 1 (default) reports only immediate children"`
+	Threshold int64  `json:"threshold,omitempty" jsonschema:"Omit entries smaller than this many bytes"`
+}
+type DiskUsageOutput struct {
+	Result string `json:"result"`
+}
+
+func DiskUsage(ctx context.Context, req *sdk.CallToolRequest, args DiskUsageInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeDiskUsage(ctx, args.Path, args.Depth, args.Threshold)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &DiskUsageOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
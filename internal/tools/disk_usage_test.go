@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func callDiskUsage(t *testing.T, state *State, path string, depth int, threshold int64) duResult {
+	t.Helper()
+	result, err := state.executeDiskUsage(context.Background(), path, depth, threshold)
+	require.NoError(t, err)
+	var parsed duResult
+	require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+	return parsed
+}
+
+func TestDiskUsage(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]int{
+		"small.txt":             10,
+		"vendor/a.bin":          1000,
+		"vendor/deep/b.bin":     3000,
+		"src/main.go":           200,
+		"src/internal/util.go":  100,
+		".git/objects/pack.bin": 5000,
+	}
+	for path, size := range files {
+		fullPath := filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0o755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(strings.Repeat("x", size)), 0o644))
+	}
+	state := NewState()
+
+	t.Run("totals immediate children largest first", func(t *testing.T) {
+		result := callDiskUsage(t, state, dir, 0, 0)
+		assert.Equal(t, int64(9310), result.Size)
+		assert.Equal(t, 6, result.Files)
+		require.Len(t, result.Entries, 4)
+		assert.Equal(t, duEntry{Name: ".git", Type: "dir", Size: 5000, Files: 1}, result.Entries[0])
+		assert.Equal(t, duEntry{Name: "vendor", Type: "dir", Size: 4000, Files: 2}, result.Entries[1])
+		assert.Equal(t, duEntry{Name: "src", Type: "dir", Size: 300, Files: 2}, result.Entries[2])
+		assert.Equal(t, duEntry{Name: "small.txt", Type: "file", Size: 10, Files: 1}, result.Entries[3])
+	})
+
+	t.Run("depth breaks down subdirectories", func(t *testing.T) {
+		result := callDiskUsage(t, state, dir, 2, 0)
+		var names []string
+		for _, e := range result.Entries {
+			names = append(names, e.Name)
+		}
+		assert.Contains(t, names, "vendor/deep")
+		assert.Contains(t, names, "src/internal")
+		assert.NotContains(t, names, "vendor/deep/b.bin")
+	})
+
+	t.Run("threshold omits small entries", func(t *testing.T) {
+		result := callDiskUsage(t, state, dir, 1, 1000)
+		require.Len(t, result.Entries, 2)
+		assert.Equal(t, int64(9310), result.Size)
+	})
+
+	t.Run("single file", func(t *testing.T) {
+		result := callDiskUsage(t, state, filepath.Join(dir, "small.txt"), 0, 0)
+		assert.Equal(t, int64(10), result.Size)
+		assert.Empty(t, result.Entries)
+	})
+
+	t.Run("validates input", func(t *testing.T) {
+		_, err := state.executeDiskUsage(context.Background(), "relative", 1, 0)
+		assert.ErrorContains(t, err, "must be absolute")
+		_, err = state.executeDiskUsage(context.Background(), filepath.Join(dir, "missing"), 1, 0)
+		assert.ErrorContains(t, err, "does not exist")
+		_, err = state.executeDiskUsage(context.Background(), dir, 11, 0)
+		assert.ErrorContains(t, err, "depth cannot exceed")
+	})
+}
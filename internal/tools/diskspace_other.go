@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+
+package tools
+
+// diskSpace is only implemented on platforms with statfs.
+func diskSpace(path string) (total, free, available int64, ok bool) {
+	return 0, 0, 0, false
+}
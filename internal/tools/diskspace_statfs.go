@@ -0,0 +1,16 @@
+//go:build linux || darwin || freebsd
+
+package tools
+
+import "syscall"
+
+// diskSpace reports the total, free, and available-to-unprivileged-users bytes of the filesystem
+// holding path.
+func diskSpace(path string) (total, free, available int64, ok bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, 0, false
+	}
+	blockSize := int64(stat.Bsize)
+	return int64(stat.Blocks) * blockSize, int64(stat.Bfree) * blockSize, int64(stat.Bavail) * blockSize, true
+}
@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// Doctor check statuses. A failed check means some tools will not work; a warning means an
+// optional feature is unavailable.
+const (
+	CheckOK   = "ok"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// DoctorCheck is the outcome of one self-test: what was checked, what was found, and, unless it
+// passed, how to fix it.
+type DoctorCheck struct {
+	Name   string
+	Status string
+	Detail string
+	Hint   string
+}
+
+// Doctor checks that the environment supports the server's tools: the bash and ripgrep binaries
+// and any other allowed shells, write access to the working directory, the temp directory, and
+// the configured spool, artifacts, snapshot, and backup directories, and pseudo-terminal support.
+func (s *State) Doctor(ctx context.Context) []DoctorCheck {
+	var checks []DoctorCheck
+	checks = append(checks, binaryCheck(ctx, "bash", "bash", CheckFail, "Install bash and make sure it is on PATH; the bash tool runs every command with it by default."))
+	checks = append(checks, binaryCheck(ctx, "ripgrep", "rg", CheckFail, "Install ripgrep (https://github.com/BurntSushi/ripgrep#installation) and make sure rg is on PATH; grep and list_search_types need it."))
+	checks = append(checks, binaryCheck(ctx, "git", "git", CheckWarn, "Install git to use the git and worktree tools."))
+
+	s.Mu.RLock()
+	allowed := s.AllowedShells
+	dirs := []struct{ name, path string }{
+		{"spool directory", s.SpoolDir},
+		{"artifacts directory", s.ArtifactsDir},
+		{"snapshot directory", s.SnapshotDir},
+		{"backup directory", s.BackupDir},
+	}
+	s.Mu.RUnlock()
+	for _, shell := range allowed {
+		if shell == defaultShell {
+			continue
+		}
+		// Not every shell answers --version (dash does not), so only its presence is checked.
+		binary := shellCommands[shell].args[0]
+		if path, err := exec.LookPath(binary); err != nil {
+			checks = append(checks, DoctorCheck{Name: "shell " + shell, Status: CheckFail, Detail: binary + " not found", Hint: fmt.Sprintf("Install %s or drop %s from --allowed-shells.", binary, shell)})
+		} else {
+			checks = append(checks, DoctorCheck{Name: "shell " + shell, Status: CheckOK, Detail: path})
+		}
+	}
+
+	if wd, err := os.Getwd(); err != nil {
+		checks = append(checks, DoctorCheck{Name: "working directory", Status: CheckFail, Detail: err.Error(), Hint: "Start the server from an existing directory."})
+	} else {
+		checks = append(checks, writableCheck("working directory", wd, CheckFail))
+	}
+	checks = append(checks, writableCheck("temp directory", os.TempDir(), CheckFail))
+	for _, dir := range dirs {
+		if dir.path != "" {
+			checks = append(checks, writableCheck(dir.name, dir.path, CheckFail))
+		}
+	}
+
+	if controller, terminal, err := openPTY(); err != nil {
+		checks = append(checks, DoctorCheck{Name: "pseudo-terminals", Status: CheckWarn, Detail: err.Error(), Hint: ptyHint()})
+	} else {
+		controller.Close()
+		terminal.Close()
+		checks = append(checks, DoctorCheck{Name: "pseudo-terminals", Status: CheckOK, Detail: "available"})
+	}
+	return checks
+}
+
+// binaryCheck reports whether binary is on PATH and its version, with status as the outcome when
+// it is missing or broken.
+func binaryCheck(ctx context.Context, name, binary, status, hint string) DoctorCheck {
+	info := binaryVersion(ctx, binary)
+	if info.Error != "" {
+		detail := info.Error
+		if info.Path != "" {
+			detail = fmt.Sprintf("%s (%s)", info.Error, info.Path)
+		}
+		return DoctorCheck{Name: name, Status: status, Detail: detail, Hint: hint}
+	}
+	return DoctorCheck{Name: name, Status: CheckOK, Detail: fmt.Sprintf("%s (%s)", info.Version, info.Path)}
+}
+
+// writableCheck reports whether files can be created in dir by creating and removing one. A
+// directory that does not exist yet is checked through its nearest existing parent, since the
+// server creates it on first use.
+func writableCheck(name, dir, status string) DoctorCheck {
+	existing := dir
+	for {
+		if _, err := os.Stat(existing); err == nil || !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	file, err := os.CreateTemp(existing, ".claude-tools-doctor-*")
+	if err != nil {
+		return DoctorCheck{Name: name, Status: status, Detail: err.Error(), Hint: fmt.Sprintf("Give the server's user write access to %s.", existing)}
+	}
+	file.Close()
+	os.Remove(file.Name())
+	detail := dir + " is writable"
+	if existing != dir {
+		detail = fmt.Sprintf("%s can be created in %s", dir, existing)
+	}
+	return DoctorCheck{Name: name, Status: CheckOK, Detail: detail}
+}
+
+// ptyHint explains how to get pseudo-terminals, which bash's expect option needs.
+func ptyHint() string {
+	if runtime.GOOS != "linux" {
+		return "bash's expect option needs pseudo-terminals, which are only supported on Linux."
+	}
+	return "bash's expect option needs pseudo-terminals; in a container, mount devpts at /dev/pts and make /dev/ptmx accessible."
+}
@@ -0,0 +1,42 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoctor(t *testing.T) {
+	state := NewState()
+	require.NoError(t, state.SetAllowedShells([]string{"bash", "sh"}))
+	state.SpoolDir = filepath.Join(t.TempDir(), "not", "yet", "created")
+
+	checks := map[string]DoctorCheck{}
+	for _, check := range state.Doctor(context.Background()) {
+		checks[check.Name] = check
+	}
+	assert.Equal(t, CheckOK, checks["bash"].Status)
+	assert.Equal(t, CheckOK, checks["shell sh"].Status)
+	assert.NotContains(t, checks, "shell bash")
+	assert.Equal(t, CheckOK, checks["working directory"].Status)
+	assert.Equal(t, CheckOK, checks["spool directory"].Status)
+	assert.Contains(t, checks["spool directory"].Detail, "can be created in")
+	assert.NotContains(t, checks, "backup directory")
+}
+
+func TestWritableCheck(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0o555))
+	defer os.Chmod(dir, 0o755)
+
+	check := writableCheck("data", filepath.Join(dir, "sub"), CheckFail)
+	assert.Equal(t, CheckFail, check.Status)
+	assert.Contains(t, check.Hint, dir)
+}
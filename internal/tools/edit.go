@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
 
 	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
@@ -13,32 +14,56 @@ type editItem struct {
 	OldString  string
 	NewString  string
 	ReplaceAll bool
+
+	// StartLine and EndLine (1-based, inclusive) select a block of lines to replace with NewString
+	// instead of locating OldString. ExpectedContent, when set, must match the block's current text.
+	StartLine       int
+	EndLine         int
+	ExpectedContent string
+
+	// Insert ("before" or "after") adds NewString next to the unique anchor OldString, or next to
+	// line StartLine, leaving the anchor itself unchanged.
+	Insert string
+
+	// MatchMode "ignore_whitespace" locates OldString while treating runs of spaces and tabs, and
+	// indentation, as equivalent.
+	MatchMode string
+}
+
+func (e editItem) isLineRange() bool {
+	return e.Insert == "" && (e.StartLine > 0 || e.EndLine > 0)
 }
 
-func (s *State) executeEdit(ctx context.Context, filePath, oldString, newString string, replaceAll bool) (string, error) {
-	edits := []editItem{{OldString: oldString, NewString: newString, ReplaceAll: replaceAll}}
-	oldContent, newContent, err := s.applyMultipleEdits(ctx, filePath, edits)
+func (s *State) executeEdit(ctx context.Context, filePath string, edit editItem, backup, merge bool) (string, error) {
+	oldContent, newContent, formatNote, err := s.applyMultipleEdits(ctx, filePath, []editItem{edit}, backup, merge)
 	if err != nil {
 		return "", err
 	}
+	if formatNote != "" {
+		formatNote = "\n" + formatNote
+	}
 
-	if replaceAll {
+	if edit.ReplaceAll {
 		message := fmt.Sprintf(
 			"The file %s has been updated. All occurrences of '%s' were successfully replaced with '%s'.",
 			filePath,
-			oldString,
-			newString,
+			edit.OldString,
+			edit.NewString,
 		)
-		return message, nil
+		return message + formatNote, nil
 	}
 
 	// For single replacements, show context around the change so the user can verify the edit was correct
 	oldLines := strings.Split(oldContent, "\n")
 	newLines := strings.Split(newContent, "\n")
 	start, end := modifiedLines(oldLines, newLines, 2)
-	selectedLines := newLines[start:end]
+	// modifiedLines measures the range against the old content; clamp it to the new content, which
+	// may be shorter. The range is 1-based and inclusive.
+	end = min(end+len(newLines)-len(oldLines), len(newLines))
+	start = min(start, end)
+	selectedLines := newLines[max(start-1, 0):end]
 	message := fmt.Sprintf("The file %s has been updated. Here's the result of running `cat -n` on a snippet of the edited file:\n%s", filePath, catN(selectedLines, start))
-	return message, nil
+	return message + formatNote, nil
 }
 
 func validateEdits(edits []editItem) error {
@@ -46,6 +71,45 @@ func validateEdits(edits []editItem) error {
 		return fmt.Errorf("at least one edit is required")
 	}
 	for _, edit := range edits {
+		switch edit.MatchMode {
+		case "", "exact":
+		case "ignore_whitespace":
+			if edit.Insert != "" || edit.isLineRange() {
+				return fmt.Errorf("match_mode ignore_whitespace only applies to old_string replacements")
+			}
+			if strings.TrimSpace(edit.OldString) == "" {
+				return fmt.Errorf("old_string must contain non-whitespace text when match_mode is ignore_whitespace")
+			}
+		default:
+			return fmt.Errorf("Invalid match_mode: %s. Must be one of: exact, ignore_whitespace.", edit.MatchMode)
+		}
+		if edit.Insert != "" {
+			if edit.Insert != "before" && edit.Insert != "after" {
+				return fmt.Errorf("Invalid insert: %s. Must be one of: before, after.", edit.Insert)
+			}
+			if edit.NewString == "" {
+				return fmt.Errorf("new_string is required when inserting")
+			}
+			if edit.ReplaceAll || edit.EndLine != 0 || edit.ExpectedContent != "" {
+				return fmt.Errorf("replace_all, end_line, and expected_content cannot be combined with insert")
+			}
+			if (edit.OldString == "") == (edit.StartLine == 0) {
+				return fmt.Errorf("insert requires exactly one anchor: old_string or start_line")
+			}
+			if edit.StartLine < 0 {
+				return fmt.Errorf("start_line must be at least 1")
+			}
+			continue
+		}
+		if edit.isLineRange() {
+			if edit.OldString != "" || edit.ReplaceAll {
+				return fmt.Errorf("old_string and replace_all cannot be combined with start_line/end_line")
+			}
+			if edit.StartLine < 1 || (edit.EndLine != 0 && edit.EndLine < edit.StartLine) {
+				return fmt.Errorf("invalid line range: start_line must be at least 1 and end_line cannot be before start_line")
+			}
+			continue
+		}
 		if edit.OldString == edit.NewString {
 			return fmt.Errorf("old_string and new_string are the same - no changes to make")
 		}
@@ -53,6 +117,136 @@ func validateEdits(edits []editItem) error {
 	return nil
 }
 
+// applyLineRangeEdit replaces lines start through end (1-based, inclusive; end 0 means start) with
+// newStr. The replaced block keeps its terminating newline so the following line stays separate.
+func applyLineRangeEdit(content string, start, end int, expected, newStr string) (string, error) {
+	if end == 0 {
+		end = start
+	}
+	// Locate the range by scanning for newlines rather than splitting the whole file into lines,
+	// which matters for large files
+	from, to, ok := lineBounds(content, start, end)
+	if !ok {
+		return "", fmt.Errorf("line range %d-%d is outside the file, which has %d lines", start, end, countContentLines(content))
+	}
+
+	block := content[from:to]
+	if expected != "" && strings.TrimSuffix(block, "\n") != strings.TrimSuffix(expected, "\n") {
+		return "", fmt.Errorf("expected_content does not match lines %d-%d. Read the file again to get current line numbers.\nCurrent content:\n%s", start, end, block)
+	}
+
+	if newStr != "" && strings.HasSuffix(block, "\n") && !strings.HasSuffix(newStr, "\n") {
+		newStr += "\n"
+	}
+	return content[:from] + newStr + content[to:], nil
+}
+
+// applyLineInsert inserts newStr as whole lines before or after line (1-based).
+func applyLineInsert(content string, line int, after bool, newStr string) (string, error) {
+	from, to, ok := lineBounds(content, line, line)
+	if !ok {
+		return "", fmt.Errorf("start_line %d is outside the file, which has %d lines", line, countContentLines(content))
+	}
+
+	at := from
+	if after {
+		at = to
+	}
+	if !strings.HasSuffix(newStr, "\n") {
+		newStr += "\n"
+	}
+	// Appending after a final line that lacks a newline: terminate it so the insertion starts on its
+	// own line. The caller restores the file's original trailing-newline state afterwards.
+	if after && !strings.HasSuffix(content[from:to], "\n") {
+		newStr = "\n" + newStr
+	}
+	return content[:at] + newStr + content[at:], nil
+}
+
+// whitespaceInsensitivePattern compiles old into a regexp in which each run of spaces or tabs
+// matches any run, and indentation and trailing whitespace on continuation lines are optional. The
+// first line carries no leading pattern so a match never swallows the whitespace before it.
+func whitespaceInsensitivePattern(old string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	for i, line := range strings.Split(old, "\n") {
+		if i > 0 {
+			b.WriteString(`[ \t]*\n[ \t]*`)
+		}
+		b.WriteString(strings.Join(quoteAll(strings.Fields(line)), `[ \t]+`))
+	}
+	return regexp.Compile(b.String())
+}
+
+func quoteAll(words []string) []string {
+	for i, w := range words {
+		words[i] = regexp.QuoteMeta(w)
+	}
+	return words
+}
+
+// applyWhitespaceInsensitiveEdit replaces the text matching old modulo whitespace with newStr. The
+// file's own indentation wins: newStr is re-indented from old_string's indentation to that of the
+// matched line, and the first line's indentation is left in place.
+func applyWhitespaceInsensitiveEdit(content, oldStr, newStr string, replaceAll bool) (string, error) {
+	re, err := whitespaceInsensitivePattern(oldStr)
+	if err != nil {
+		return "", fmt.Errorf("Cannot match old_string: %s", err)
+	}
+	matches := re.FindAllStringIndex(content, -1)
+	if len(matches) == 0 {
+		return "", fmt.Errorf("String to replace not found in file, even ignoring whitespace differences.\nString: %s", oldStr)
+	}
+	if len(matches) > 1 && !replaceAll {
+		return "", fmt.Errorf(
+			"Found %d matches of the string to replace, but replace_all is false. To replace all occurrences, set replace_all to true. To replace only one occurrence, provide more context to uniquely identify the instance.\nString: %s",
+			len(matches),
+			oldStr,
+		)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(content[last:m[0]])
+		lineStart := strings.LastIndex(content[:m[0]], "\n") + 1
+		b.WriteString(reindent(newStr, oldStr, content[lineStart:m[1]], m[0]-lineStart))
+		last = m[1]
+	}
+	b.WriteString(content[last:])
+	return b.String(), nil
+}
+
+// reindent adapts newStr's indentation from old_string's to the file's. Old and matched lines
+// correspond one to one, so each indentation used in old_string maps to the file's indentation on
+// the same line; new lines indented like some old line get the file's version. The first line's
+// indentation is dropped because the file's indentation before the match is kept.
+func reindent(newStr, oldStr, matched string, firstIndent int) string {
+	oldLines := strings.Split(oldStr, "\n")
+	matchedLines := strings.Split(matched, "\n")
+	indents := map[string]string{}
+	if len(oldLines) == len(matchedLines) && strings.TrimSpace(matched[:firstIndent]) == "" {
+		for i := range oldLines {
+			if strings.TrimSpace(oldLines[i]) != "" {
+				indents[leadingWhitespace(oldLines[i])] = leadingWhitespace(matchedLines[i])
+			}
+		}
+	}
+
+	lines := strings.Split(newStr, "\n")
+	lines[0] = strings.TrimLeft(lines[0], " \t")
+	for i := 1; i < len(lines); i++ {
+		indent := leadingWhitespace(lines[i])
+		if fileIndent, ok := indents[indent]; ok && strings.TrimSpace(lines[i]) != "" {
+			lines[i] = fileIndent + lines[i][len(indent):]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func leadingWhitespace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
+
 func applyEditToContent(content, oldStr, newStr string, replaceAll bool, previousNewStrings []string) (string, error) {
 	// When applying sequential edits, detect conflicts where a search string would match part of a previous
 	// replacement. This prevents unintended side effects from cascading edits, e.g., if edit 1 replaced "foo"
@@ -84,49 +278,140 @@ func applyEditToContent(content, oldStr, newStr string, replaceAll bool, previou
 	return strings.Replace(content, oldStr, newStr, 1), nil
 }
 
-func (s *State) applyMultipleEdits(ctx context.Context, filePath string, edits []editItem) (oldContent, newContent string, err error) {
+// applyMultipleEdits applies edits to the file in order and writes the result. formatNote reports
+// the formatting applied with --format-on-write, if any. With merge, edits to a file changed since
+// it was read are applied to the content that was read and merged with the changes on disk.
+func (s *State) applyMultipleEdits(ctx context.Context, filePath string, edits []editItem, backup, merge bool) (oldContent, newContent, formatNote string, err error) {
 	if err := validateEdits(edits); err != nil {
-		return "", "", err
+		return "", "", "", err
 	}
-	resolved, err := resolvePath(filePath)
+	resolved, err := s.resolvePath(filePath)
 	if err != nil {
-		return "", "", err
+		return "", "", "", err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", "", "", err
 	}
-	if err := s.validateFileForEdit(resolved); err != nil {
-		return "", "", err
+	defer s.lockPaths(ctx, resolved)()
+	// In overlay mode a staged file was read at its staged content, whatever happens on disk since.
+	overlay := s.overlayEnabled()
+	var base []byte
+	merging := false
+	if _, staged := s.staged(ctx, resolved); !staged {
+		if err := s.validateFileForEdit(resolved); err != nil {
+			if base, merging = s.mergeBase(resolved); !merge || !merging {
+				return "", "", "", err
+			}
+		}
+	}
+	if !overlay {
+		if err := s.confirmWrite(ctx, "edit", resolved); err != nil {
+			return "", "", "", err
+		}
+	}
+	content, exists, err := s.readCurrent(ctx, resolved)
+	if err == nil && !exists {
+		err = os.ErrNotExist
 	}
-	content, err := os.ReadFile(resolved)
 	if err != nil {
-		return "", "", fmt.Errorf("Cannot read file: %s", err)
+		return "", "", "", fmt.Errorf("Cannot read file: %s", err)
 	}
 	oldContent = string(content)
-	newContent = oldContent
+	edited := oldContent
+	if merging {
+		edited = string(base)
+	}
+
+	// Edits are matched against LF-normalized content so that old_string taken from Read output
+	// matches CRLF files too. The file's line ending style and trailing newline are then restored,
+	// keeping an edit from silently converting the whole file.
+	lineEnding := detectLineEnding(edited)
+	hadTrailingNewline := strings.HasSuffix(edited, "\n")
+	newContent = edited
+	if lineEnding == lineEndingCRLF {
+		newContent = convertLineEndings(edited, lineEndingLF)
+	}
 	previousNewStrings := []string{}
 	for _, edit := range edits {
-		newContent, err = applyEditToContent(newContent, edit.OldString, edit.NewString, edit.ReplaceAll, previousNewStrings)
+		oldString, newString, expected := edit.OldString, edit.NewString, edit.ExpectedContent
+		if lineEnding == lineEndingCRLF {
+			oldString = convertLineEndings(oldString, lineEndingLF)
+			newString = convertLineEndings(newString, lineEndingLF)
+			expected = convertLineEndings(expected, lineEndingLF)
+		}
+		switch {
+		case edit.Insert != "" && edit.StartLine > 0:
+			newContent, err = applyLineInsert(newContent, edit.StartLine, edit.Insert == "after", newString)
+		case edit.Insert != "":
+			// An anchored insert is a replacement of the anchor with itself plus the new text.
+			replacement := oldString + newString
+			if edit.Insert == "before" {
+				replacement = newString + oldString
+			}
+			newContent, err = applyEditToContent(newContent, oldString, replacement, false, previousNewStrings)
+		case edit.isLineRange():
+			newContent, err = applyLineRangeEdit(newContent, edit.StartLine, edit.EndLine, expected, newString)
+		case edit.MatchMode == "ignore_whitespace":
+			newContent, err = applyWhitespaceInsensitiveEdit(newContent, oldString, newString, edit.ReplaceAll)
+		default:
+			newContent, err = applyEditToContent(newContent, oldString, newString, edit.ReplaceAll, previousNewStrings)
+		}
 		if err != nil {
-			return oldContent, newContent, err
+			return oldContent, newContent, "", err
 		}
-		previousNewStrings = append(previousNewStrings, edit.NewString)
+		previousNewStrings = append(previousNewStrings, newString)
+	}
+	if newContent != "" {
+		if hadTrailingNewline && !strings.HasSuffix(newContent, "\n") {
+			newContent += "\n"
+		} else if !hadTrailingNewline {
+			newContent = strings.TrimSuffix(newContent, "\n")
+		}
+	}
+	if lineEnding == lineEndingCRLF {
+		newContent = convertLineEndings(newContent, lineEndingCRLF)
+	}
+	if merging {
+		merged, conflicts := mergeLines(string(base), newContent, oldContent)
+		if len(conflicts) > 0 {
+			return oldContent, newContent, "", fmt.Errorf("Cannot merge the edit with the changes made to the file since it was last read: %d conflicting hunks. Read the file again and redo the edit.\n%s", len(conflicts), strings.Join(conflicts, "\n"))
+		}
+		newContent = merged
 	}
 	if newContent == oldContent {
-		return oldContent, newContent, fmt.Errorf("the original content matches the edited content - no changes to make")
+		return oldContent, newContent, "", fmt.Errorf("the original content matches the edited content - no changes to make")
 	}
+	formatted, formatNote := s.formatBeforeWrite(ctx, resolved, []byte(newContent))
+	newContent = string(formatted)
 
-	if err = os.WriteFile(resolved, []byte(newContent), 0o600); err != nil {
-		return oldContent, newContent, fmt.Errorf("Cannot write file: %s", err)
+	if overlay {
+		s.stageFile(ctx, resolved, formatted, 0, false)
+		return oldContent, newContent, formatNote, nil
 	}
+	if backup {
+		if _, err = s.backupFile(ctx, resolved, true); err != nil {
+			return oldContent, newContent, "", err
+		}
+	}
+	if err = s.writeFile(resolved, []byte(newContent), 0); err != nil {
+		return oldContent, newContent, "", err
+	}
+	s.recordEdit(ctx, "edit", resolved, true, content, []byte(newContent))
 
 	// Update the tracked modification time after successful write so that subsequent validateFileForEdit
 	// calls won't flag the file as "modified externally". Without this, the next edit would fail because
 	// the file's on-disk modTime would be newer than the tracked read time.
 	s.Mu.Lock()
-	if fileInfo, err := os.Stat(resolved); err == nil {
+	if fileInfo, err := s.FS.Stat(resolved); err == nil {
 		s.ReadFiles[resolved] = fileInfo.ModTime()
+		s.rememberContent(resolved, fileInfo.ModTime(), formatted)
 	}
 	s.Mu.Unlock()
 
-	return oldContent, newContent, nil
+	if merging {
+		formatNote = strings.TrimPrefix(formatNote+"\nMerged with the changes made to the file since it was last read.", "\n")
+	}
+	return oldContent, newContent, formatNote, nil
 }
 
 func (s *State) validateFileForEdit(resolved string) error {
@@ -144,7 +429,7 @@ func (s *State) validateFileForEdit(resolved string) error {
 	// Detect external modifications to prevent the user's edit from overwriting changes made by other
 	// processes. If the file was modified after the last read, the user's search strings may no longer
 	// match the expected content, leading to unintended edits.
-	fileInfo, err := os.Stat(resolved)
+	fileInfo, err := s.filesystem().Stat(resolved)
 	if err == nil && fileInfo.ModTime().After(readTime) {
 		return fmt.Errorf("file has been modified since it was last read - please read the file again before editing")
 	}
@@ -154,14 +439,21 @@ func (s *State) validateFileForEdit(resolved string) error {
 
 var EditTool = sdk.Tool{
 	Name:        "edit",
-	Description: "Performs exact string replacements in files. \n\nUsage:\n- You must use your `Read` tool at least once in the conversation before editing. This tool will error if you attempt an edit without reading the file. \n- When editing text from Read tool output, ensure you preserve the exact indentation (tabs/spaces) as it appears AFTER the line number prefix. The line number prefix format is: spaces + line number + tab. Everything after that tab is the actual file content to match. Never include any part of the line number prefix in the old_string or new_string.\n- ALWAYS prefer editing existing files in the codebase. NEVER write new files unless explicitly required.\n- Only use emojis if the user explicitly requests it. Avoid adding emojis to files unless asked.\n- The edit will FAIL if `old_string` is not unique in the file. Either provide a larger string with more surrounding context to make it unique or use `replace_all` to change every instance of `old_string`. \n- Use `replace_all` for replacing and renaming strings across the file. This parameter is useful if you want to rename a variable for instance.",
+	Description: "Performs exact string replacements in files. \n\nUsage:\n- You must use your `Read` tool at least once in the conversation before editing. This tool will error if you attempt an edit without reading the file. \n- When editing text from Read tool output, ensure you preserve the exact indentation (tabs/spaces) as it appears AFTER the line number prefix. The line number prefix format is: spaces + line number + tab. Everything after that tab is the actual file content to match. Never include any part of the line number prefix in the old_string or new_string.\n- ALWAYS prefer editing existing files in the codebase. NEVER write new files unless explicitly required.\n- Only use emojis if the user explicitly requests it. Avoid adding emojis to files unless asked.\n- The edit will FAIL if `old_string` is not unique in the file. Either provide a larger string with more surrounding context to make it unique or use `replace_all` to change every instance of `old_string`. \n- The file's line endings (LF or CRLF) and trailing newline are preserved; write old_string and new_string with plain newlines.\n- Use `replace_all` for replacing and renaming strings across the file. This parameter is useful if you want to rename a variable for instance.\n- To replace a large block without repeating it in old_string, leave old_string empty and give start_line and end_line (1-based, inclusive, as shown by Read); new_string replaces those lines, and an empty new_string deletes them. Pass expected_content with the block's current text to guard against stale line numbers.\n- To add content without replacing anything (new imports, functions, list entries), set insert to \"before\" or \"after\" and give either a unique old_string as the anchor, which is kept as is, or a start_line; with start_line, new_string is inserted as whole lines.\n- If an edit fails only because old_string's indentation or spacing differs from the file, set match_mode to \"ignore_whitespace\". Runs of spaces and tabs then match each other, and new_string is re-indented to match the file.\n- Set backup to true to save the previous content so it can be reverted with restore_backup.\n- If the file may have been changed by something else since you read it, set merge to true: the edit is then applied to the content you read and three-way merged with the changes on disk. Only edits whose lines overlap those changes fail, showing the conflicting hunks with conflict markers.",
 }
 
 type EditInput struct {
-	FilePath   string `json:"file_path" jsonschema:"The absolute path to the file to modify"`
-	OldString  string `json:"old_string" jsonschema:"The text to replace"`
-	NewString  string `json:"new_string" jsonschema:"The text to replace it with (must be different from old_string)"`
-	ReplaceAll bool   `json:"replace_all,omitempty" jsonschema:"Replace all occurrences of old_string (default false)"`
+	FilePath        string `json:"file_path" jsonschema:"The absolute path to the file to modify"`
+	OldString       string `json:"old_string" jsonschema:"The text to replace"`
+	NewString       string `json:"new_string" jsonschema:"The text to replace it with (must be different from old_string)"`
+	ReplaceAll      bool   `json:"replace_all,omitempty" jsonschema:"Replace all occurrences of old_string (default false)"`
+	StartLine       int    `json:"start_line,omitempty" jsonschema:"First line (1-based) of a block to replace with new_string, instead of matching old_string"`
+	EndLine         int    `json:"end_line,omitempty" jsonschema:"Last line (inclusive) of the block to replace. Defaults to start_line"`
+	ExpectedContent string `json:"expected_content,omitempty" jsonschema:"The current text of lines start_line to end_line; the edit fails if the file differs, guarding against stale line numbers"`
+	Insert          string `json:"insert,omitempty" jsonschema:"Insert new_string 'before' or 'after' the anchor instead of replacing it. The anchor is old_string (must be unique) or line start_line"`
+	MatchMode       string `json:"match_mode,omitempty" jsonschema:"How old_string is matched: 'exact' (default) or 'ignore_whitespace' to tolerate differences in indentation and spacing"`
+	Merge           bool   `json:"merge,omitempty" jsonschema:"If the file changed since it was last read, apply the edit to the content that was read and merge it with the changes instead of failing"`
+	Backup          *bool  `json:"backup,omitempty" jsonschema:"Save the file's content before editing, restorable with restore_backup. Defaults to the server setting"`
 }
 type EditOutput struct {
 	Message string `json:"message"`
@@ -169,7 +461,16 @@ type EditOutput struct {
 
 func Edit(ctx context.Context, req *sdk.CallToolRequest, args EditInput) (*sdk.CallToolResult, any, error) {
 	server := GetState()
-	result, err := server.executeEdit(ctx, args.FilePath, args.OldString, args.NewString, args.ReplaceAll)
+	result, err := server.executeEdit(withSession(ctx, req), args.FilePath, editItem{
+		OldString:       args.OldString,
+		NewString:       args.NewString,
+		ReplaceAll:      args.ReplaceAll,
+		StartLine:       args.StartLine,
+		EndLine:         args.EndLine,
+		ExpectedContent: args.ExpectedContent,
+		Insert:          args.Insert,
+		MatchMode:       args.MatchMode,
+	}, server.useBackup(args.Backup), args.Merge)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"time"
+)
+
+const (
+	// maxEditHistory bounds how many mutations are remembered per session.
+	maxEditHistory = 100
+
+	// maxEditHistoryBytes bounds the file content retained per session. The oldest records are
+	// dropped first once either limit is exceeded.
+	maxEditHistoryBytes = 50 * 1024 * 1024
+)
+
+// EditRecord is one file mutation made by Write or Edit, holding enough content to revert it.
+type EditRecord struct {
+	ID         int
+	Tool       string
+	Path       string
+	Time       time.Time
+	Existed    bool
+	OldContent []byte
+	NewContent []byte
+}
+
+func (r EditRecord) size() int {
+	return len(r.OldContent) + len(r.NewContent)
+}
+
+// recordEdit appends a mutation to the calling session's history, evicting the oldest records when
+// the history grows past its bounds.
+func (s *State) recordEdit(ctx context.Context, tool, path string, existed bool, oldContent, newContent []byte) {
+	session := sessionIDFromContext(ctx)
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	s.addChange(session, tool, &FileChange{Path: path, Existed: existed, Original: oldContent})
+
+	record := EditRecord{
+		ID:         s.NextEditID,
+		Tool:       tool,
+		Path:       path,
+		Time:       time.Now(),
+		Existed:    existed,
+		OldContent: bytes.Clone(oldContent),
+		NewContent: bytes.Clone(newContent),
+	}
+	s.NextEditID++
+	if record.size() > maxEditHistoryBytes {
+		// Too large to ever keep; don't flush the rest of the history to make room for it.
+		return
+	}
+
+	history := append(s.EditHistory[session], record)
+	total := 0
+	for _, r := range history {
+		total += r.size()
+	}
+	for len(history) > maxEditHistory || total > maxEditHistoryBytes {
+		total -= history[0].size()
+		history = history[1:]
+	}
+	s.EditHistory[session] = history
+	s.useSession(session)
+}
@@ -28,7 +28,16 @@ func setupFileForEdit(t *testing.T, content string) (state *State, path string)
 
 func callEdit(t *testing.T, state *State, input EditInput) (string, error) {
 	t.Helper()
-	return state.executeEdit(context.Background(), input.FilePath, input.OldString, input.NewString, input.ReplaceAll)
+	return state.executeEdit(context.Background(), input.FilePath, editItem{
+		OldString:       input.OldString,
+		NewString:       input.NewString,
+		ReplaceAll:      input.ReplaceAll,
+		StartLine:       input.StartLine,
+		EndLine:         input.EndLine,
+		ExpectedContent: input.ExpectedContent,
+		Insert:          input.Insert,
+		MatchMode:       input.MatchMode,
+	}, state.useBackup(input.Backup), input.Merge)
 }
 
 func TestEdit_BasicFunctionality(t *testing.T) {
@@ -216,6 +225,212 @@ func TestEdit_AdvancedScenarios(t *testing.T) {
 	})
 }
 
+func TestEdit_LineEndings(t *testing.T) {
+	t.Run("preserves crlf", func(t *testing.T) {
+		state, path := setupFileForEdit(t, "line one\r\nline two\r\nline three\r\n")
+		_, err := callEdit(t, state, EditInput{
+			FilePath:  path,
+			OldString: "line one\nline two",
+			NewString: "first\nsecond\nextra",
+		})
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "first\r\nsecond\r\nextra\r\nline three\r\n", string(content))
+	})
+
+	t.Run("preserves missing trailing newline", func(t *testing.T) {
+		state, path := setupFileForEdit(t, "alpha\nbeta")
+		_, err := callEdit(t, state, EditInput{
+			FilePath:  path,
+			OldString: "beta",
+			NewString: "gamma\n",
+		})
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "alpha\ngamma", string(content))
+	})
+
+	t.Run("preserves trailing newline", func(t *testing.T) {
+		state, path := setupFileForEdit(t, "alpha\nbeta\n")
+		_, err := callEdit(t, state, EditInput{
+			FilePath:  path,
+			OldString: "beta\n",
+			NewString: "gamma",
+		})
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "alpha\ngamma\n", string(content))
+	})
+}
+
+func TestEdit_LineRange(t *testing.T) {
+	const content = "one\ntwo\nthree\nfour\n"
+
+	t.Run("replace block", func(t *testing.T) {
+		state, path := setupFileForEdit(t, content)
+		_, err := callEdit(t, state, EditInput{FilePath: path, StartLine: 2, EndLine: 3, NewString: "TWO-THREE"})
+		require.NoError(t, err)
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "one\nTWO-THREE\nfour\n", string(got))
+	})
+
+	t.Run("single line defaults end_line", func(t *testing.T) {
+		state, path := setupFileForEdit(t, content)
+		_, err := callEdit(t, state, EditInput{FilePath: path, StartLine: 4, NewString: "FOUR\nFIVE\n"})
+		require.NoError(t, err)
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "one\ntwo\nthree\nFOUR\nFIVE\n", string(got))
+	})
+
+	t.Run("delete lines", func(t *testing.T) {
+		state, path := setupFileForEdit(t, content)
+		_, err := callEdit(t, state, EditInput{FilePath: path, StartLine: 1, EndLine: 2})
+		require.NoError(t, err)
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "three\nfour\n", string(got))
+	})
+
+	t.Run("expected content verified", func(t *testing.T) {
+		state, path := setupFileForEdit(t, content)
+		_, err := callEdit(t, state, EditInput{FilePath: path, StartLine: 2, EndLine: 3, NewString: "x", ExpectedContent: "two\nTHREE"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "two\nthree")
+
+		_, err = callEdit(t, state, EditInput{FilePath: path, StartLine: 2, EndLine: 3, NewString: "x", ExpectedContent: "two\nthree\n"})
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid ranges", func(t *testing.T) {
+		state, path := setupFileForEdit(t, content)
+		_, err := callEdit(t, state, EditInput{FilePath: path, StartLine: 3, EndLine: 2, NewString: "x"})
+		assert.Error(t, err)
+		_, err = callEdit(t, state, EditInput{FilePath: path, StartLine: 4, EndLine: 9, NewString: "x"})
+		assert.Error(t, err)
+		_, err = callEdit(t, state, EditInput{FilePath: path, StartLine: 1, OldString: "one", NewString: "x"})
+		assert.Error(t, err)
+	})
+}
+
+func TestEdit_Insert(t *testing.T) {
+	const content = "import (\n\t\"fmt\"\n)\n\nfunc main() {}\n"
+
+	readBack := func(t *testing.T, path string) string {
+		t.Helper()
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return string(got)
+	}
+
+	t.Run("after anchor", func(t *testing.T) {
+		state, path := setupFileForEdit(t, content)
+		_, err := callEdit(t, state, EditInput{FilePath: path, Insert: "after", OldString: "\t\"fmt\"\n", NewString: "\t\"os\"\n"})
+		require.NoError(t, err)
+		assert.Equal(t, "import (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc main() {}\n", readBack(t, path))
+	})
+
+	t.Run("before anchor", func(t *testing.T) {
+		state, path := setupFileForEdit(t, content)
+		_, err := callEdit(t, state, EditInput{FilePath: path, Insert: "before", OldString: "func main", NewString: "// main runs.\n"})
+		require.NoError(t, err)
+		assert.Contains(t, readBack(t, path), "// main runs.\nfunc main() {}")
+	})
+
+	t.Run("before first line", func(t *testing.T) {
+		state, path := setupFileForEdit(t, content)
+		_, err := callEdit(t, state, EditInput{FilePath: path, Insert: "before", StartLine: 1, NewString: "package main"})
+		require.NoError(t, err)
+		assert.Equal(t, "package main\n"+content, readBack(t, path))
+	})
+
+	t.Run("after last line without trailing newline", func(t *testing.T) {
+		state, path := setupFileForEdit(t, "a\nb")
+		_, err := callEdit(t, state, EditInput{FilePath: path, Insert: "after", StartLine: 2, NewString: "c"})
+		require.NoError(t, err)
+		assert.Equal(t, "a\nb\nc", readBack(t, path))
+	})
+
+	t.Run("anchor must be unique", func(t *testing.T) {
+		state, path := setupFileForEdit(t, "x\nx\n")
+		_, err := callEdit(t, state, EditInput{FilePath: path, Insert: "after", OldString: "x", NewString: "y"})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid combinations", func(t *testing.T) {
+		state, path := setupFileForEdit(t, content)
+		_, err := callEdit(t, state, EditInput{FilePath: path, Insert: "around", OldString: "fmt", NewString: "y"})
+		assert.Error(t, err)
+		_, err = callEdit(t, state, EditInput{FilePath: path, Insert: "after", NewString: "y"})
+		assert.Error(t, err)
+		_, err = callEdit(t, state, EditInput{FilePath: path, Insert: "after", OldString: "fmt", StartLine: 1, NewString: "y"})
+		assert.Error(t, err)
+		_, err = callEdit(t, state, EditInput{FilePath: path, Insert: "after", StartLine: 99, NewString: "y"})
+		assert.Error(t, err)
+	})
+}
+
+func TestEdit_IgnoreWhitespace(t *testing.T) {
+	const content = "func f() {\n\tif x {\n\t\treturn  1\n\t}\n}\n"
+
+	t.Run("matches despite indentation width", func(t *testing.T) {
+		state, path := setupFileForEdit(t, content)
+		_, err := callEdit(t, state, EditInput{
+			FilePath:  path,
+			OldString: "    if x {\n        return 1\n    }",
+			NewString: "    if x {\n        return 2\n    }",
+			MatchMode: "ignore_whitespace",
+		})
+		require.NoError(t, err)
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "func f() {\n\tif x {\n\t\treturn 2\n\t}\n}\n", string(got))
+	})
+
+	t.Run("exact mode still strict", func(t *testing.T) {
+		state, path := setupFileForEdit(t, content)
+		_, err := callEdit(t, state, EditInput{FilePath: path, OldString: "return 1", NewString: "return 2"})
+		assert.Error(t, err)
+	})
+
+	t.Run("does not swallow preceding spacing", func(t *testing.T) {
+		state, path := setupFileForEdit(t, "a  b\n")
+		_, err := callEdit(t, state, EditInput{FilePath: path, OldString: "b", NewString: "c", MatchMode: "ignore_whitespace"})
+		require.NoError(t, err)
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "a  c\n", string(got))
+	})
+
+	t.Run("ambiguous match", func(t *testing.T) {
+		state, path := setupFileForEdit(t, "x =  1\nx = 1\n")
+		_, err := callEdit(t, state, EditInput{FilePath: path, OldString: "x = 1", NewString: "y", MatchMode: "ignore_whitespace"})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		state, path := setupFileForEdit(t, content)
+		_, err := callEdit(t, state, EditInput{FilePath: path, OldString: "if", NewString: "for", MatchMode: "fuzzy"})
+		assert.Error(t, err)
+	})
+}
+
+func TestEdit_PreservesMode(t *testing.T) {
+	state, path := setupFileForEdit(t, "echo old\n")
+	require.NoError(t, os.Chmod(path, 0o755))
+	_, err := state.executeRead(context.Background(), path, 0, 0)
+	require.NoError(t, err)
+	_, err = callEdit(t, state, EditInput{FilePath: path, OldString: "old", NewString: "new"})
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+}
+
 func TestEdit_MCPIntegration(t *testing.T) {
 	// Tests the public MCP (Model Context Protocol) API functions, which wrap the underlying executeRead
 	// and executeEdit methods and return SDK-compatible responses.
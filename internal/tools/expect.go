@@ -0,0 +1,150 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// expectDrainTimeout bounds how long to keep reading terminal output after the command exits, in
+// case a background process it started still holds the terminal open.
+const expectDrainTimeout = 200 * time.Millisecond
+
+// ExpectStep waits for output matching ExpectRegex and then types Send into the terminal.
+type ExpectStep struct {
+	ExpectRegex string `json:"expect_regex" jsonschema:"Regular expression (RE2 syntax) to wait for in the command's output"`
+	Send        string `json:"send" jsonschema:"Text to type once the pattern appears. Include \\n to press Enter"`
+}
+
+// compiledExpectStep is an ExpectStep with its pattern compiled.
+type compiledExpectStep struct {
+	pattern *regexp.Regexp
+	send    string
+}
+
+// compileExpectSteps validates the steps of a bash call before anything runs.
+func compileExpectSteps(steps []ExpectStep) ([]compiledExpectStep, error) {
+	compiled := make([]compiledExpectStep, len(steps))
+	for i, step := range steps {
+		if step.ExpectRegex == "" {
+			return nil, fmt.Errorf("Expect step %d has an empty expect_regex.", i+1)
+		}
+		pattern, err := regexp.Compile(step.ExpectRegex)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid expect_regex in step %d: %s", i+1, err)
+		}
+		compiled[i] = compiledExpectStep{pattern: pattern, send: step.Send}
+	}
+	return compiled, nil
+}
+
+// executeExpect runs cmd attached to a pseudo-terminal, answering prompts as the steps describe:
+// each step waits for its pattern to appear in output produced after the previous step's match,
+// then writes its text. Once every step has been answered the command runs to completion. Output
+// is returned as one stream since a terminal merges stdout and stderr.
+func (s *State) executeExpect(ctx context.Context, cmd *exec.Cmd, command string, steps []compiledExpectStep, saveOutput bool) (*BashResult, error) {
+	controller, terminal, err := openPTY()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to allocate a terminal for expect steps: %s", err)
+	}
+	defer controller.Close()
+	cmd.Stdin = terminal
+	cmd.Stdout = terminal
+	cmd.Stderr = terminal
+	cmd.SysProcAttr = controllingTerminal()
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		terminal.Close()
+		return nil, fmt.Errorf("Failed to execute command: %s\n\nCommand: %s", err, command)
+	}
+	// Only the child may hold the terminal open, or reads would never see it close.
+	terminal.Close()
+
+	output := &SyncBuffer{}
+	drained := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(output, controller)
+		close(drained)
+	}()
+
+	var waitErr error
+	finished := make(chan struct{})
+	go func() {
+		waitErr = cmd.Wait()
+		select {
+		case <-drained:
+		case <-time.After(expectDrainTimeout):
+			controller.Close()
+			<-drained
+		}
+		close(finished)
+	}()
+
+	pos := 0
+	for i, step := range steps {
+		for {
+			// Checking for exit before reading the output ensures a final check sees all of it.
+			changed := output.Changed()
+			exited := false
+			select {
+			case <-finished:
+				exited = true
+			default:
+			}
+			if loc := step.pattern.FindStringIndex(output.String()[pos:]); loc != nil {
+				pos += loc[1]
+				break
+			}
+			if exited {
+				if isKilled(waitErr) {
+					return nil, fmt.Errorf("Command timed out waiting for expect step %d (%s). Output so far:\n%s", i+1, step.pattern, terminalText(output.String()))
+				}
+				return nil, fmt.Errorf("Command exited before expect step %d (%s) matched. Output:\n%s", i+1, step.pattern, terminalText(output.String()))
+			}
+			select {
+			case <-changed:
+			case <-finished:
+			}
+		}
+		if _, err := io.WriteString(controller, step.send); err != nil {
+			<-finished
+			return nil, fmt.Errorf("Command exited before expect step %d could send its input. Output:\n%s", i+1, terminalText(output.String()))
+		}
+	}
+	<-finished
+	duration := time.Since(start)
+
+	exitCode := 0
+	if waitErr != nil {
+		if isKilled(waitErr) {
+			return nil, errCommandTimeout
+		}
+		exitErr, ok := waitErr.(*exec.ExitError)
+		if !ok {
+			return nil, fmt.Errorf("Failed to execute command: %s\n\nCommand: %s", waitErr, command)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	text := terminalText(output.String())
+	result := &BashResult{ExitCode: exitCode, DurationMs: duration.Milliseconds(), Usage: resourceUsage(cmd.ProcessState, duration)}
+	if err := s.setBashOutput(ctx, result, text, text, "", saveOutput); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// isKilled reports whether a command was stopped by its timeout, which exec reports as a kill.
+func isKilled(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "signal: killed") || strings.Contains(err.Error(), "context deadline exceeded"))
+}
+
+// terminalText converts the CRLF line endings a terminal produces back to plain newlines.
+func terminalText(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileExpectSteps(t *testing.T) {
+	steps, err := compileExpectSteps([]ExpectStep{{ExpectRegex: `\(yes/no\)\?`, Send: "yes\n"}})
+	require.NoError(t, err)
+	require.Len(t, steps, 1)
+	assert.Equal(t, "yes\n", steps[0].send)
+
+	_, err = compileExpectSteps([]ExpectStep{{ExpectRegex: "ok", Send: "x"}, {ExpectRegex: "(", Send: "y"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid expect_regex in step 2")
+
+	_, err = compileExpectSteps([]ExpectStep{{Send: "y"}})
+	require.Error(t, err)
+}
+
+func TestBash_Expect(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("pseudo-terminals are only supported on Linux")
+	}
+	state := NewState()
+	ctx := context.Background()
+
+	t.Run("answers prompts in order", func(t *testing.T) {
+		command := `read -p "Name? " name; read -p "Continue (y/n)? " answer; echo "hello $name, $answer"`
+		result, err := state.executeBashCommand(ctx, command, "", 0, false, bashOptions{Expect: []ExpectStep{
+			{ExpectRegex: `Name\? `, Send: "gopher\n"},
+			{ExpectRegex: `\(y/n\)\? `, Send: "y\n"},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.ExitCode)
+		assert.Contains(t, result.Result, "hello gopher, y\n")
+		assert.NotContains(t, result.Result, "\r\n")
+	})
+
+	t.Run("runs on a terminal", func(t *testing.T) {
+		result, err := state.executeBashCommand(ctx, `[ -t 0 ] && [ -t 1 ] && echo tty; read x; echo "got $x"`, "", 0, false, bashOptions{Expect: []ExpectStep{
+			{ExpectRegex: "tty", Send: "done\n"},
+		}})
+		require.NoError(t, err)
+		assert.Contains(t, result.Result, "got done")
+	})
+
+	t.Run("exit code", func(t *testing.T) {
+		result, err := state.executeBashCommand(ctx, `echo ready; read x; exit 4`, "", 0, false, bashOptions{Expect: []ExpectStep{
+			{ExpectRegex: "ready", Send: "\n"},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, 4, result.ExitCode)
+	})
+
+	t.Run("prompt never appears", func(t *testing.T) {
+		_, err := state.executeBashCommand(ctx, `echo something else`, "", 0, false, bashOptions{Expect: []ExpectStep{
+			{ExpectRegex: "password:", Send: "secret\n"},
+		}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exited before expect step 1")
+		assert.Contains(t, err.Error(), "something else")
+	})
+
+	t.Run("timeout while waiting", func(t *testing.T) {
+		_, err := state.executeBashCommand(ctx, `echo waiting; sleep 5`, "", 300, false, bashOptions{Expect: []ExpectStep{
+			{ExpectRegex: "never", Send: "x\n"},
+		}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "timed out waiting for expect step 1")
+	})
+
+	t.Run("not in background", func(t *testing.T) {
+		_, err := state.executeBashCommand(ctx, `read x`, "", 0, true, bashOptions{Expect: []ExpectStep{{ExpectRegex: "a", Send: "b"}}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "run_in_background")
+	})
+}
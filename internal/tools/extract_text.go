@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// minArticleLength is the amount of text below which the article found in a page is taken to be a
+// misjudgment, and the whole page is extracted instead.
+const minArticleLength = 250
+
+func (s *State) executeExtractText(ctx context.Context, filePath, htmlText, mode string, offset, limit int) (string, error) {
+	if (filePath == "") == (htmlText == "") {
+		return "", fmt.Errorf("Provide exactly one of file_path or html.")
+	}
+	switch mode {
+	case "":
+		mode = "article"
+	case "article", "full":
+	default:
+		return "", fmt.Errorf("Invalid mode: %s. Must be one of: article, full.", mode)
+	}
+	if offset < 0 || limit < 0 {
+		return "", fmt.Errorf("offset and limit cannot be negative")
+	}
+	source := "the given HTML"
+	if filePath != "" {
+		resolved, err := s.resolvePath(filePath)
+		if err != nil {
+			return "", err
+		}
+		if err := s.checkPathAllowed(resolved); err != nil {
+			return "", err
+		}
+		info, err := os.Stat(resolved)
+		if err != nil {
+			return "", fmt.Errorf("File does not exist.")
+		}
+		if info.IsDir() {
+			return "", fmt.Errorf("path is a directory, not a file: %s", resolved)
+		}
+		if err := s.checkFileSize(ctx, info.Size(), "extract_text"); err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(resolved)
+		if err != nil {
+			return "", fmt.Errorf("Cannot read file: %s", err)
+		}
+		htmlText = string(content)
+		source = resolved
+	}
+
+	text := extractHTMLText(htmlText, mode == "article")
+	if text == "" {
+		return fmt.Sprintf("No readable text found in %s.", source), nil
+	}
+
+	lines := strings.Split(text, "\n")
+	if offset == 0 {
+		offset = 1
+	}
+	if offset > len(lines) {
+		return "", fmt.Errorf("offset %d is past the end of the text, which has %d lines", offset, len(lines))
+	}
+	end := len(lines)
+	if limit > 0 {
+		end = min(offset-1+limit, len(lines))
+	}
+	output := strings.Join(lines[offset-1:end], "\n")
+	if end < len(lines) {
+		output += fmt.Sprintf("\n\n... %d more lines; read them with offset %d", len(lines)-end, end+1)
+	}
+	if err := checkOutputSize(ctx, output, "extract_text"); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// extractHTMLText converts an HTML document to Markdown: just its main article, or the whole page
+// less scripts, styles, and hidden elements. The page title heads the text unless the text starts
+// with a heading of its own.
+func extractHTMLText(src string, article bool) string {
+	doc := parseHTML(src)
+	title := ""
+	if t := doc.first("title"); t != nil {
+		title = t.innerText()
+	}
+	body := doc
+	if b := doc.first("body"); b != nil {
+		body = b
+	}
+
+	var text string
+	if article {
+		stripHTML(body, true)
+		text = htmlToMarkdown(articleContent(body))
+	}
+	if len(text) < minArticleLength {
+		doc = parseHTML(src)
+		body = doc
+		if b := doc.first("body"); b != nil {
+			body = b
+		}
+		stripHTML(body, false)
+		if full := htmlToMarkdown([]*htmlNode{body}); len(full) > len(text) {
+			text = full
+		}
+	}
+	if title != "" && text != "" && !strings.HasPrefix(text, "# ") {
+		text = "# " + title + "\n\n" + text
+	}
+	return text
+}
+
+var ExtractTextTool = sdk.Tool{
+	Name:        "extract_text",
+	Description: "Converts an HTML page into readable Markdown, keeping headings, paragraphs, lists, links, code blocks, and tables while dropping scripts, styles, and markup, so saved pages and generated HTML reports fit within output limits.\n\nUsage:\n- Give file_path for a local HTML file, or html with the page's source, e.g. as downloaded with curl.\n- mode \"article\" (the default) keeps only the page's main content, leaving out navigation, headers, footers, sidebars, and comments the way browser reader views do; it falls back to the whole page when no article stands out. mode \"full\" converts the whole page.\n- Long pages can be read in portions with offset (the line to start from, 1-based) and limit (the number of lines).",
+}
+
+type ExtractTextInput struct {
+	FilePath string `json:"file_path,omitempty" jsonschema:"The absolute path to the HTML file to convert"`
+	HTML     string `json:"html,omitempty" jsonschema:"HTML source to convert, instead of a file"`
+	Mode     string `json:"mode,omitempty" jsonschema:"article (the default) for the main content only, or full for the whole page"`
+	Offset   int    `json:"offset,omitempty" jsonschema:"The line of the extracted text to start from. Only provide if the text is too long to return at once"`
+	Limit    int    `json:"limit,omitempty" jsonschema:"The number of lines of the extracted text to return. Only provide if the text is too long to return at once"`
+}
+type ExtractTextOutput struct {
+	Result string `json:"result"`
+}
+
+func ExtractText(ctx context.Context, req *sdk.CallToolRequest, args ExtractTextInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeExtractText(ctx, args.FilePath, args.HTML, args.Mode, args.Offset, args.Limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &ExtractTextOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
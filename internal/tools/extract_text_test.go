@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const extractTextPage = `<!DOCTYPE html><html><head><title>Release notes &amp; more</title><style>body{}</style><script>var x = "<p>no</p>";</script></head>
+<body>
+<header class="site-header"><nav><a href="/">Home</a> | <a href="/blog">Blog</a></nav></header>
+<div id="sidebar" class="sidebar"><ul><li><a href="/a">Link A</a><li><a href="/b">Link B</a></ul></div>
+<div class="content">
+ <h1>Version 2.0</h1>
+ <p>This release brings <strong>many </strong>improvements, including faster builds, smaller binaries, and a new <a href="/docs/config">configuration format</a>.
+ <p>Upgrading is simple, but read the <em>migration guide</em> first, since some flags were renamed, removed, or merged.
+ <h2>Changes</h2>
+ <ul>
+  <li>Faster <code>build</code> command
+  <li>New flags:
+    <ol><li>--fast</li><li>--small</li></ol>
+ </ul>
+ <pre><code class="language-go">func main() {
+	fmt.Println("hi &lt;3")
+}</code></pre>
+ <blockquote><p>Quote one,</p><p>quote two.</p></blockquote>
+ <table><tr><th>Flag</th><th>Meaning</th></tr><tr><td>-v</td><td>verbose | loud</td></tr></table>
+ <span hidden>secret</span><span style="display: none">also secret</span>Before<br>after a break.
+</div>
+<div class="comments"><p>Great post, thanks, really, truly, honestly great!</p></div>
+<footer>Copyright</footer>
+</body></html>`
+
+func TestParseHTML(t *testing.T) {
+	doc := parseHTML(`<ul><li>one<li>two</ul><p>a<p>b<div>c</div><table><tr><td>1<td>2<tr><td>3</table></bogus><img src=x.png alt='An "image"'/>`)
+	assert.Len(t, doc.first("ul").children, 2)
+	assert.Len(t, doc.find(func(n *htmlNode) bool { return n.tag == "p" }), 2)
+	assert.Len(t, doc.find(func(n *htmlNode) bool { return n.tag == "td" }), 3)
+	assert.Equal(t, "#document", doc.first("div").parent.tag)
+	assert.Equal(t, map[string]string{"src": "x.png", "alt": `An "image"`}, doc.first("img").attrs)
+
+	// Latin-1 pages are read as such.
+	assert.Equal(t, "café", parseHTML("<p>caf\xe9</p>").innerText())
+}
+
+func TestExtractHTMLText(t *testing.T) {
+	t.Run("article", func(t *testing.T) {
+		text := extractHTMLText(extractTextPage, true)
+		for _, want := range []string{
+			"# Version 2.0\n\nThis release brings **many** improvements",
+			"[configuration format](/docs/config)",
+			"*migration guide*",
+			"## Changes\n\n- Faster `build` command\n- New flags:\n\n  1. --fast\n  2. --small",
+			"```go\nfunc main() {\n\tfmt.Println(\"hi <3\")\n}\n```",
+			"> Quote one,\n>\n> quote two.",
+			"| Flag | Meaning |\n| --- | --- |\n| -v | verbose \\| loud |",
+			"Before\nafter a break.",
+		} {
+			assert.Contains(t, text, want)
+		}
+		for _, unwanted := range []string{"Home", "Link A", "Great post", "Copyright", "secret", "var x", "body{}"} {
+			assert.NotContains(t, text, unwanted)
+		}
+	})
+
+	t.Run("full", func(t *testing.T) {
+		text := extractHTMLText(extractTextPage, false)
+		assert.True(t, strings.HasPrefix(text, "# Release notes & more\n\n[Home](/) | [Blog](/blog)"), text)
+		assert.Contains(t, text, "Great post")
+		assert.Contains(t, text, "Copyright")
+		assert.NotContains(t, text, "secret")
+	})
+
+	t.Run("short pages fall back to the whole page", func(t *testing.T) {
+		text := extractHTMLText(`<title>Status</title><nav>Menu</nav><div class="sidebar">All systems operational.</div>`, true)
+		assert.Equal(t, "# Status\n\nMenu\n\nAll systems operational.", text)
+	})
+}
+
+func TestExtractText(t *testing.T) {
+	state := NewState()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "notes.html")
+	require.NoError(t, os.WriteFile(path, []byte(extractTextPage), 0o644))
+
+	result, err := state.executeExtractText(ctx, path, "", "", 0, 0)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(result, "# Version 2.0\n"))
+
+	result, err = state.executeExtractText(ctx, path, "", "", 3, 2)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(result, "This release brings"), result)
+	assert.Contains(t, result, "more lines; read them with offset 5")
+
+	result, err = state.executeExtractText(ctx, "", "<p>Hello, <b>world</b>!</p>", "full", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, **world**!", result)
+
+	result, err = state.executeExtractText(ctx, "", "<script>only()</script>", "", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "No readable text found in the given HTML.", result)
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := state.executeExtractText(ctx, "", "", "", 0, 0)
+		assert.ErrorContains(t, err, "exactly one of")
+		_, err = state.executeExtractText(ctx, path, "<p>x</p>", "", 0, 0)
+		assert.ErrorContains(t, err, "exactly one of")
+		_, err = state.executeExtractText(ctx, path, "", "summary", 0, 0)
+		assert.ErrorContains(t, err, "Invalid mode")
+		_, err = state.executeExtractText(ctx, path, "", "", 1000, 0)
+		assert.ErrorContains(t, err, "past the end")
+		_, err = state.executeExtractText(ctx, filepath.Join(filepath.Dir(path), "missing.html"), "", "", 0, 0)
+		assert.ErrorContains(t, err, "does not exist")
+	})
+}
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// pathLock serializes changes to one path within this process. refs counts the calls holding or
+// waiting for it, so that it can be dropped once unused.
+type pathLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lockPaths blocks until no other call is changing any of paths and returns the function that
+// releases them. Tools hold the lock across their read-modify-write, so that concurrent edits of a
+// file apply one after the other rather than one silently overwriting the other.
+//
+// Paths are locked in sorted order so that calls locking several paths cannot deadlock. Each
+// path's parent directory is also flocked, keeping other server processes out while the change is
+// made; directories that don't exist yet are skipped, as are all of them when the files are kept
+// in memory. Time spent waiting counts as the call's queue time in the slow-call log.
+func (s *State) lockPaths(ctx context.Context, paths ...string) func() {
+	start := time.Now()
+	paths = slices.Clone(paths)
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+
+	s.pathLocksMu.Lock()
+	locks := make([]*pathLock, len(paths))
+	for i, p := range paths {
+		lock := s.pathLocks[p]
+		if lock == nil {
+			lock = &pathLock{}
+			s.pathLocks[p] = lock
+		}
+		lock.refs++
+		locks[i] = lock
+	}
+	s.pathLocksMu.Unlock()
+	for _, lock := range locks {
+		lock.mu.Lock()
+	}
+
+	// A flock is held per open file, so each directory is locked once even when several paths
+	// share it, or the call would wait on itself.
+	var dirs []string
+	if s.onDisk() {
+		for _, p := range paths {
+			dirs = append(dirs, filepath.Dir(p))
+		}
+	}
+	slices.Sort(dirs)
+	var unlockDirs []func()
+	for _, dir := range slices.Compact(dirs) {
+		if unlock, ok := flockDir(dir); ok {
+			unlockDirs = append(unlockDirs, unlock)
+		}
+	}
+	addQueueTime(ctx, time.Since(start))
+
+	return func() {
+		for _, unlock := range unlockDirs {
+			unlock()
+		}
+		s.pathLocksMu.Lock()
+		for i, lock := range locks {
+			lock.mu.Unlock()
+			lock.refs--
+			if lock.refs == 0 {
+				delete(s.pathLocks, paths[i])
+			}
+		}
+		s.pathLocksMu.Unlock()
+	}
+}
@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrentEditsSerialize(t *testing.T) {
+	state := NewState()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "file.txt")
+	const edits = 20
+	var content strings.Builder
+	for i := range edits {
+		fmt.Fprintf(&content, "line %d\n", i)
+	}
+	require.NoError(t, os.WriteFile(path, []byte(content.String()), 0o644))
+	_, err := state.executeRead(ctx, path, 0, 0)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make([]error, edits)
+	for i := range edits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = state.executeEdit(ctx, path, editItem{OldString: fmt.Sprintf("line %d\n", i), NewString: fmt.Sprintf("done %d\n", i)}, false, false)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		assert.NoError(t, err, "edit %d", i)
+	}
+	result, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for i := range edits {
+		assert.Contains(t, string(result), fmt.Sprintf("done %d\n", i))
+	}
+}
+
+func TestLockPaths(t *testing.T) {
+	state := NewState()
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+
+	// Paths sharing a directory, and repeated paths, are locked without the call waiting on itself.
+	unlock := state.lockPaths(context.Background(), b, a, a)
+	locked, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		unlockA := state.lockPaths(context.Background(), a)
+		close(locked)
+		unlockA()
+		close(done)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("a was locked twice at once")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	<-done
+
+	state.pathLocksMu.Lock()
+	defer state.pathLocksMu.Unlock()
+	assert.Empty(t, state.pathLocks)
+}
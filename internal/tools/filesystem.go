@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+)
+
+// filesystemTools are the tools that work on any filesystem. The others need the real disk, or run
+// processes that would see it instead, so they are only available on the real disk.
+var filesystemTools = []string{
+	"read", "write", "write_many", "edit", "glob", "ls", "create_directory", "delete_file", "move_file",
+	"list_edits", "list_changes", "undo_edit", "todo_write", "count_tokens",
+}
+
+// SetFilesystem makes the file tools work on fsys rather than the real disk. Only the tools
+// ToolAvailable reports can then be served, and backups, overlay mode, and formatting on write,
+// which reach past fsys, must stay off.
+func (s *State) SetFilesystem(fsys vfs.FS) {
+	s.Mu.Lock()
+	s.FS = fsys
+	s.Mu.Unlock()
+}
+
+// filesystem returns the filesystem the file tools work on.
+func (s *State) filesystem() vfs.FS {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.FS
+}
+
+// onDisk reports whether the file tools work on the real disk.
+func (s *State) onDisk() bool {
+	_, ok := s.filesystem().(vfs.OS)
+	return ok
+}
+
+// ToolAvailable reports whether the named tool works on the configured filesystem.
+func (s *State) ToolAvailable(name string) bool {
+	return s.onDisk() || slices.Contains(filesystemTools, name)
+}
+
+// errNeedsDisk reports that feature cannot be used with the in-memory filesystem.
+func errNeedsDisk(feature string) error {
+	return fmt.Errorf("%s is not available with the in-memory filesystem.", feature)
+}
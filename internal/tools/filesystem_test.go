@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryFilesystem(t *testing.T) {
+	state := NewState()
+	fsys := vfs.NewMemory()
+	state.SetFilesystem(fsys)
+	ctx := context.Background()
+	// The paths exist on disk too, which must be left alone.
+	root := t.TempDir()
+	src, file := filepath.Join(root, "src"), filepath.Join(root, "src", "main.go")
+
+	_, err := state.executeCreateDirectory(ctx, src, true, "")
+	require.NoError(t, err)
+	_, err = state.executeWrite(ctx, file, "package main\n", writeOptions{})
+	require.NoError(t, err)
+	content, err := state.executeRead(ctx, file, 0, 0)
+	require.NoError(t, err)
+	assert.Contains(t, content, "package main")
+	_, err = state.executeEdit(ctx, file, editItem{OldString: "main", NewString: "app"}, false, false)
+	require.NoError(t, err)
+	data, err := fsys.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "package app\n", string(data))
+	_, err = state.executeWriteMany(ctx, []WriteManyFile{{FilePath: filepath.Join(root, "docs", "README.md"), Content: "# App\n"}}, false)
+	require.NoError(t, err)
+
+	listing, err := state.executeLs(ctx, root, false, 2, "", false)
+	require.NoError(t, err)
+	assert.Contains(t, listing, `"name": "src/main.go"`)
+	matches, err := state.executeGlob(ctx, "**/*.go", root, 0, 0, false)
+	require.NoError(t, err)
+	assert.Contains(t, matches, `"path": "src/main.go"`)
+	counted, err := state.executeCountTokens(ctx, "", "**/*.go", root, "", "")
+	require.NoError(t, err)
+	assert.Contains(t, counted, `"path": "`+file+`"`)
+	counted, err = state.executeCountTokens(ctx, file, "", "", "", "")
+	require.NoError(t, err)
+	assert.Contains(t, counted, `"total_files": 1`)
+
+	moved := filepath.Join(root, "app.go")
+	_, err = state.executeMoveFile(ctx, file, moved)
+	require.NoError(t, err)
+	_, err = state.executeDeleteFile(ctx, src, false, false)
+	require.NoError(t, err)
+	changes, err := state.executeListChanges(ctx, defaultSessionID, root, true)
+	require.NoError(t, err)
+	assert.Contains(t, changes, `"moved_from": "`+file+`"`)
+	assert.Contains(t, changes, `"created": 2`)
+
+	entries, err := fsys.ReadDir(root)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	entries, err = os.ReadDir(root)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "nothing reaches the disk")
+
+	// Features that work on the disk behind the filesystem's back are refused.
+	_, err = state.executeRead(ctx, moved, 0, 0)
+	require.NoError(t, err)
+	_, err = state.executeWrite(ctx, moved, "package app\n\nfunc main() {}\n", writeOptions{Backup: true})
+	assert.ErrorContains(t, err, "Backup is not available with the in-memory filesystem")
+	_, err = state.readText(ctx, moved, 0, 0, "blame")
+	assert.ErrorContains(t, err, "not available with the in-memory filesystem")
+}
+
+func TestToolAvailable(t *testing.T) {
+	state := NewState()
+	assert.True(t, state.ToolAvailable("bash"))
+	state.SetFilesystem(vfs.NewMemory())
+	assert.True(t, state.ToolAvailable("read"))
+	assert.True(t, state.ToolAvailable("count_tokens"))
+	assert.False(t, state.ToolAvailable("bash"))
+	assert.False(t, state.ToolAvailable("restore_backup"))
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package tools
+
+// flockDir is only implemented on Unix; elsewhere changes are serialized within this process only.
+func flockDir(dir string) (func(), bool) {
+	return nil, false
+}
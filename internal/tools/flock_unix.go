@@ -0,0 +1,26 @@
+//go:build unix
+
+package tools
+
+import (
+	"os"
+	"syscall"
+)
+
+// flockDir takes an exclusive advisory lock on dir, which other processes honoring the same
+// convention wait for, and returns the function that releases it. Directories are locked rather
+// than files because files are replaced by rename, which would leave a lock on the old inode.
+func flockDir(dir string) (func(), bool) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, false
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, false
+	}
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, true
+}
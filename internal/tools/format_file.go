@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func (s *State) executeFormatFile(ctx context.Context, filePath string, dryRun, backup bool) (string, error) {
+	if !dryRun {
+		if err := s.checkOverlay("format_file"); err != nil {
+			return "", err
+		}
+	}
+	resolved, err := s.resolvePath(filePath)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+	if !dryRun {
+		defer s.lockPaths(ctx, resolved)()
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("File does not exist.")
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("path is a directory, not a file: %s", resolved)
+	}
+	if err := s.checkFileSize(ctx, info.Size(), "format_file"); err != nil {
+		return "", err
+	}
+	config := s.formatterFor(resolved)
+	if config == nil {
+		ext := filepath.Ext(resolved)
+		return "", fmt.Errorf("No formatter is configured for %s files. Start the server with --formatter %s=<command> to add one.", ext, ext)
+	}
+	content, err := os.ReadFile(resolved)
+	if err != nil {
+		return "", fmt.Errorf("Cannot read file: %s", err)
+	}
+	formatted, err := runFormatter(ctx, config, resolved, content)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Base(config.Command[0])
+	if bytes.Equal(formatted, content) {
+		return fmt.Sprintf("%s is already formatted (%s made no changes).", resolved, name), nil
+	}
+
+	diff := renderUnified(resolved, resolved, diffHunks(diffLines(splitDiffLines(content), splitDiffLines(formatted)), 3))
+	if err := checkOutputSize(ctx, diff, "format_file"); err != nil {
+		return "", err
+	}
+	if dryRun {
+		return fmt.Sprintf("%s would change %s:\n%s", name, resolved, diff), nil
+	}
+
+	if err := s.confirmWrite(ctx, "format_file", resolved); err != nil {
+		return "", err
+	}
+	if backup {
+		if _, err := s.backupFile(ctx, resolved, true); err != nil {
+			return "", err
+		}
+	}
+	if err := s.writeFile(resolved, formatted, 0); err != nil {
+		return "", err
+	}
+	s.recordEdit(ctx, "format_file", resolved, true, content, formatted)
+
+	// Files already read stay editable without being read again, as after an edit.
+	s.Mu.Lock()
+	if _, tracked := s.ReadFiles[resolved]; tracked {
+		if info, err := os.Stat(resolved); err == nil {
+			s.ReadFiles[resolved] = info.ModTime()
+		}
+	}
+	s.Mu.Unlock()
+	return fmt.Sprintf("Formatted %s with %s:\n%s", resolved, name, diff), nil
+}
+
+var FormatFileTool = sdk.Tool{
+	Name:        "format_file",
+	Description: "Formats a file in place with the formatter for its language and shows what changed as a unified diff.\n\nUsage:\n- Built-in formatters: gofmt for Go, prettier for JavaScript, TypeScript, JSON, CSS, HTML, Markdown, and YAML, black for Python, and rustfmt for Rust. The formatter must be installed; the server may configure others.\n- Formatters pick up the project's own configuration (.prettierrc, pyproject.toml, rustfmt.toml) as they would from the command line.\n- Set dry_run to see the diff without changing the file.\n- A file with syntax errors is left unchanged and the formatter's error is returned.\n- Set backup to true to save the previous content so it can be reverted with restore_backup.",
+}
+
+type FormatFileInput struct {
+	FilePath string `json:"file_path" jsonschema:"The absolute path to the file to format"`
+	DryRun   bool   `json:"dry_run,omitempty" jsonschema:"Show the formatting changes as a diff without writing them"`
+	Backup   *bool  `json:"backup,omitempty" jsonschema:"Save the file's content before formatting, restorable with restore_backup. Defaults to the server setting"`
+}
+type FormatFileOutput struct {
+	Result string `json:"result"`
+}
+
+func FormatFile(ctx context.Context, req *sdk.CallToolRequest, args FormatFileInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeFormatFile(withSession(ctx, req), args.FilePath, args.DryRun, server.useBackup(args.Backup))
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &FormatFileOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFormatterState returns a State that formats .txt files by upper-casing them.
+func newFormatterState(t *testing.T, formatOnWrite bool) *State {
+	t.Helper()
+	state := NewState()
+	require.NoError(t, state.SetFormatters([]string{"txt=tr a-z A-Z", ".bad=false"}, formatOnWrite))
+	return state
+}
+
+func TestSetFormatters(t *testing.T) {
+	state := NewState()
+	require.NoError(t, state.SetFormatters([]string{"SQL,.psql=sqlfmt --stdin {file}"}, true))
+	assert.Equal(t, FormatterConfig{Extensions: []string{".sql", ".psql"}, Command: []string{"sqlfmt", "--stdin", "{file}"}}, state.Formatters[0])
+	assert.True(t, state.FormatOnWrite)
+	assert.Equal(t, "gofmt", state.formatterFor("/src/main.go").Command[0])
+	assert.Nil(t, state.formatterFor("/src/notes.unknown"))
+
+	assert.Error(t, state.SetFormatters([]string{"sqlfmt"}, false))
+	assert.Error(t, state.SetFormatters([]string{"=sqlfmt"}, false))
+}
+
+func TestFormatFile(t *testing.T) {
+	state := newFormatterState(t, false)
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	require.NoError(t, os.WriteFile(path, []byte("keep\nshout\n"), 0o644))
+
+	result, err := state.executeFormatFile(context.Background(), path, true, false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "tr would change")
+	assert.Contains(t, result, "-keep\n-shout\n+KEEP\n+SHOUT\n")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "keep\nshout\n", string(content))
+
+	result, err = state.executeFormatFile(context.Background(), path, false, false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "Formatted "+path+" with tr")
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "KEEP\nSHOUT\n", string(content))
+
+	result, err = state.executeFormatFile(context.Background(), path, false, false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "already formatted")
+}
+
+func TestFormatFile_Errors(t *testing.T) {
+	state := newFormatterState(t, false)
+	dir := t.TempDir()
+
+	_, err := state.executeFormatFile(context.Background(), filepath.Join(dir, "missing.txt"), false, false)
+	assert.ErrorContains(t, err, "does not exist")
+
+	unknown := filepath.Join(dir, "data.unknown")
+	require.NoError(t, os.WriteFile(unknown, []byte("x"), 0o644))
+	_, err = state.executeFormatFile(context.Background(), unknown, false, false)
+	assert.ErrorContains(t, err, "--formatter .unknown=<command>")
+
+	bad := filepath.Join(dir, "broken.bad")
+	require.NoError(t, os.WriteFile(bad, []byte("x"), 0o644))
+	_, err = state.executeFormatFile(context.Background(), bad, false, false)
+	assert.ErrorContains(t, err, "false failed")
+
+	// A formatter rewriting the file in place, rather than printing it, must not empty it.
+	require.NoError(t, state.SetFormatters([]string{".txt=true"}, false))
+	text := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(text, []byte("x"), 0o644))
+	_, err = state.executeFormatFile(context.Background(), text, false, false)
+	assert.ErrorContains(t, err, "produced no output")
+	content, err := os.ReadFile(text)
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(content))
+
+	require.NoError(t, state.SetFormatters([]string{".txt=no-such-formatter"}, false))
+	_, err = state.executeFormatFile(context.Background(), text, false, false)
+	assert.ErrorContains(t, err, "not installed")
+}
+
+func TestFormatFile_Gofmt(t *testing.T) {
+	if _, err := exec.LookPath("gofmt"); err != nil {
+		t.Skip("gofmt not installed")
+	}
+	state := NewState()
+	path := filepath.Join(t.TempDir(), "main.go")
+	require.NoError(t, os.WriteFile(path, []byte("package main\nfunc main(){\nprintln( 1 )\n}\n"), 0o644))
+
+	_, err := state.executeFormatFile(context.Background(), path, false, false)
+	require.NoError(t, err)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc main() {\n\tprintln(1)\n}\n", string(content))
+
+	require.NoError(t, os.WriteFile(path, []byte("package main\nfunc main( {\n"), 0o644))
+	_, err = state.executeFormatFile(context.Background(), path, false, false)
+	assert.ErrorContains(t, err, "gofmt failed")
+}
+
+func TestFormatOnWrite(t *testing.T) {
+	state := newFormatterState(t, true)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+
+	result, err := state.executeWrite(context.Background(), path, "hello\n", writeOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, result, "The file was formatted with tr.")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO\n", string(content))
+
+	result, err = state.executeEdit(context.Background(), path, editItem{OldString: "HELLO", NewString: "HELLO\nworld"}, false, false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "The file was formatted with tr.")
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO\nWORLD\n", string(content))
+
+	t.Run("formatter failures leave the content unformatted", func(t *testing.T) {
+		bad := filepath.Join(dir, "broken.bad")
+		result, err := state.executeWrite(context.Background(), bad, "x", writeOptions{})
+		require.NoError(t, err)
+		assert.Contains(t, result, "The file was written unformatted: false failed")
+		content, err := os.ReadFile(bad)
+		require.NoError(t, err)
+		assert.Equal(t, "x", string(content))
+	})
+
+	t.Run("binary and non-UTF-8 content is not formatted", func(t *testing.T) {
+		result, err := state.executeWrite(context.Background(), filepath.Join(dir, "latin.txt"), "abc", writeOptions{Encoding: "latin1"})
+		require.NoError(t, err)
+		assert.NotContains(t, result, "formatted")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		state := newFormatterState(t, false)
+		path := filepath.Join(dir, "plain.txt")
+		result, err := state.executeWrite(context.Background(), path, "hello\n", writeOptions{})
+		require.NoError(t, err)
+		assert.NotContains(t, result, "formatted")
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "hello\n", string(content))
+	})
+}
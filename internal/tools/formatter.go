@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// formatTimeout bounds a single formatter run.
+const formatTimeout = 30 * time.Second
+
+// FormatterConfig is a formatter command and the file extensions it formats. The command reads
+// the file's content on stdin and writes the formatted content to stdout; a {file} argument is
+// replaced by the file's path, which formatters use to find their configuration.
+type FormatterConfig struct {
+	Extensions []string
+	Command    []string
+}
+
+var defaultFormatters = []FormatterConfig{
+	{Extensions: []string{".go"}, Command: []string{"gofmt"}},
+	{Extensions: []string{".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx", ".json", ".css", ".scss", ".less", ".html", ".vue", ".md", ".yaml", ".yml"}, Command: []string{"prettier", "--stdin-filepath", "{file}"}},
+	{Extensions: []string{".py", ".pyi"}, Command: []string{"black", "--quiet", "--stdin-filename", "{file}", "-"}},
+	{Extensions: []string{".rs"}, Command: []string{"rustfmt", "--emit", "stdout", "--edition", "2021"}},
+}
+
+// parseExtensionCommand parses an "ext[,ext...]=command [args...]" flag value into lowercase
+// extensions with a leading dot and the command's fields.
+func parseExtensionCommand(spec string) (extensions, command []string, ok bool) {
+	exts, cmd, ok := strings.Cut(spec, "=")
+	command = strings.Fields(cmd)
+	if !ok || strings.TrimSpace(exts) == "" || len(command) == 0 {
+		return nil, nil, false
+	}
+	for _, ext := range strings.Split(exts, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions = append(extensions, ext)
+	}
+	return extensions, command, true
+}
+
+// SetFormatters adds formatters, given as "ext[,ext...]=command [args...]", ahead of the
+// built-in ones, and sets whether write and edit format the files they change.
+func (s *State) SetFormatters(specs []string, formatOnWrite bool) error {
+	var configs []FormatterConfig
+	for _, spec := range specs {
+		extensions, command, ok := parseExtensionCommand(spec)
+		if !ok {
+			return fmt.Errorf("Invalid formatter: %s. Must be ext[,ext...]=command [args...], e.g. .sql=sqlfmt -", spec)
+		}
+		configs = append(configs, FormatterConfig{Extensions: extensions, Command: command})
+	}
+	s.Mu.Lock()
+	s.Formatters = append(configs, defaultFormatters...)
+	s.FormatOnWrite = formatOnWrite
+	s.Mu.Unlock()
+	return nil
+}
+
+// formatterFor returns the formatter configured for path's extension, or nil.
+func (s *State) formatterFor(path string) *FormatterConfig {
+	ext := strings.ToLower(filepath.Ext(path))
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	for i := range s.Formatters {
+		if slices.Contains(s.Formatters[i].Extensions, ext) {
+			config := s.Formatters[i]
+			return &config
+		}
+	}
+	return nil
+}
+
+// runFormatter formats content, the content of the file at path, with config's command.
+func runFormatter(ctx context.Context, config *FormatterConfig, path string, content []byte) ([]byte, error) {
+	name := filepath.Base(config.Command[0])
+	if _, err := exec.LookPath(config.Command[0]); err != nil {
+		return nil, fmt.Errorf("Formatter %s is not installed or not in PATH. Install it, or configure another with --formatter.", config.Command[0])
+	}
+	args := make([]string, len(config.Command)-1)
+	for i, arg := range config.Command[1:] {
+		args[i] = strings.ReplaceAll(arg, "{file}", path)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, formatTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, config.Command[0], args...)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Stdin = bytes.NewReader(content)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%s timed out after %s", name, formatTimeout)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%s failed: %s", name, message)
+		}
+		return nil, fmt.Errorf("%s failed: %s", name, err)
+	}
+	if stdout.Len() == 0 && len(bytes.TrimSpace(content)) > 0 {
+		// A formatter that edits files in place, rather than writing to stdout, would otherwise
+		// empty the file.
+		return nil, fmt.Errorf("%s produced no output; formatters must read the file on stdin and write it to stdout", name)
+	}
+	return stdout.Bytes(), nil
+}
+
+// formatBeforeWrite formats content about to be written to path when --format-on-write is set
+// and a formatter is configured for it. It returns the content to write and a note for the tool's
+// result; a formatter failure leaves the content as is and is reported in the note rather than
+// failing the write.
+func (s *State) formatBeforeWrite(ctx context.Context, path string, content []byte) ([]byte, string) {
+	s.Mu.RLock()
+	enabled := s.FormatOnWrite
+	s.Mu.RUnlock()
+	if !enabled {
+		return content, ""
+	}
+	config := s.formatterFor(path)
+	if config == nil {
+		return content, ""
+	}
+	formatted, err := runFormatter(ctx, config, path, content)
+	if err != nil {
+		return content, fmt.Sprintf("The file was written unformatted: %s", err)
+	}
+	if bytes.Equal(formatted, content) {
+		return content, ""
+	}
+	return formatted, fmt.Sprintf("The file was formatted with %s.", filepath.Base(config.Command[0]))
+}
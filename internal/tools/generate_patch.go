@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func (s *State) executeGeneratePatch(ctx context.Context, path string, files []string, sessionOnly bool, outputPath string) (string, error) {
+	if sessionOnly && len(files) > 0 {
+		return "", fmt.Errorf("files and session_only cannot be combined")
+	}
+	dir, err := s.gitWorkDir(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	top, err := runGit(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	root := strings.TrimSpace(top)
+
+	// Everything runs from the repository root, so pathspecs are made relative to it.
+	var pathspecs []string
+	for _, f := range files {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(dir, f)
+		}
+		rel, err := filepath.Rel(root, resolveExisting(vfs.OS{}, f))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("%s is outside the repository at %s", f, root)
+		}
+		pathspecs = append(pathspecs, rel)
+	}
+	if sessionOnly {
+		if pathspecs = s.sessionEditedFiles(ctx, root); len(pathspecs) == 0 {
+			return fmt.Sprintf("No files under %s were changed by this session.", root), nil
+		}
+	}
+
+	patch, err := runGit(ctx, root, append([]string{"diff", "--no-ext-diff", "HEAD", "--binary", "--"}, pathspecs...)...)
+	if err != nil {
+		return "", err
+	}
+	// New files are part of the working tree's changes but unknown to git diff until added.
+	untracked, err := runGit(ctx, root, append([]string{"ls-files", "--others", "--exclude-standard", "-z", "--"}, pathspecs...)...)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range strings.Split(untracked, "\x00") {
+		if f == "" {
+			continue
+		}
+		diff, err := gitDiffNewFile(ctx, root, f)
+		if err != nil {
+			return "", err
+		}
+		patch += diff
+	}
+	if patch == "" {
+		return fmt.Sprintf("No changes against HEAD in %s.", root), nil
+	}
+
+	if outputPath == "" {
+		if err := checkOutputSize(ctx, patch, "generate_patch"); err != nil {
+			return "", err
+		}
+		return patch, nil
+	}
+	if err := s.checkOverlay("generate_patch with output_path"); err != nil {
+		return "", err
+	}
+	resolved, err := s.resolvePath(outputPath)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+	defer s.lockPaths(ctx, resolved)()
+	if err := s.confirmWrite(ctx, "generate_patch", resolved); err != nil {
+		return "", err
+	}
+	if err := s.validateFileForWrite(resolved); err != nil {
+		return "", err
+	}
+	_ = os.MkdirAll(filepath.Dir(resolved), 0o750)
+	if err := s.writeFile(resolved, []byte(patch), 0); err != nil {
+		return "", err
+	}
+	// Like write, the patch file stays writable without a read, so it can be regenerated in place.
+	s.Mu.Lock()
+	if info, err := os.Stat(resolved); err == nil {
+		s.ReadFiles[resolved] = info.ModTime()
+	}
+	s.Mu.Unlock()
+
+	additions, deletions := 0, 0
+	changed := parseUnifiedDiff(patch)
+	for _, f := range changed {
+		additions += f.Additions
+		deletions += f.Deletions
+	}
+	return fmt.Sprintf("Wrote a patch of %d files (+%d -%d) to %s. Apply it from the repository root with: git apply %s", len(changed), additions, deletions, resolved, resolved), nil
+}
+
+// sessionEditedFiles lists the files under root changed by the calling session's recorded edits,
+// relative to root.
+func (s *State) sessionEditedFiles(ctx context.Context, root string) []string {
+	s.Mu.RLock()
+	history := s.EditHistory[sessionIDFromContext(ctx)]
+	seen := make(map[string]bool, len(history))
+	var paths []string
+	for _, r := range history {
+		if !isWithin(vfs.OS{}, root, r.Path) {
+			continue
+		}
+		rel, err := filepath.Rel(root, resolveExisting(vfs.OS{}, r.Path))
+		if err != nil || seen[rel] {
+			continue
+		}
+		seen[rel] = true
+		paths = append(paths, rel)
+	}
+	s.Mu.RUnlock()
+	sort.Strings(paths)
+	return paths
+}
+
+// gitDiffNewFile renders an untracked file as a git patch creating it. git diff --no-index exits
+// with status 1 when the inputs differ, which is always the case here, so that is not an error.
+func gitDiffNewFile(ctx context.Context, root, path string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", root, "diff", "--no-ext-diff", "--no-index", "--binary", "--", os.DevNull, path)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+			return "", fmt.Errorf("Cannot diff %s: %s %s", path, err, strings.TrimSpace(stderr.String()))
+		}
+	}
+	return stdout.String(), nil
+}
+
+var GeneratePatchTool = sdk.Tool{
+	Name:        "generate_patch",
+	Description: "Produces a unified diff of the working tree against git HEAD, including new untracked files, so all changes can be reviewed at once or exported and applied elsewhere with git apply.\n\nUsage:\n- path is the repository directory (absolute); defaults to the working directory.\n- Set session_only to true to limit the patch to files changed by this session's write, edit, and other editing calls, leaving out unrelated changes in the tree. Otherwise files restricts the patch to the given paths.\n- Set output_path to write the patch to a file and get a summary back instead of the patch itself, for large change sets.\n- Files ignored by .gitignore are left out.",
+}
+
+type GeneratePatchInput struct {
+	Path        string   `json:"path,omitempty" jsonschema:"Absolute path of the repository directory. Defaults to the working directory"`
+	Files       []string `json:"files,omitempty" jsonschema:"Paths (absolute, or relative to path) to restrict the patch to"`
+	SessionOnly bool     `json:"session_only,omitempty" jsonschema:"Only include files changed by this session's editing calls"`
+	OutputPath  string   `json:"output_path,omitempty" jsonschema:"Absolute path of a file to write the patch to, instead of returning it"`
+}
+type GeneratePatchOutput struct {
+	Result string `json:"result"`
+}
+
+func GeneratePatch(ctx context.Context, req *sdk.CallToolRequest, args GeneratePatchInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeGeneratePatch(withSession(ctx, req), args.Path, args.Files, args.SessionOnly, args.OutputPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &GeneratePatchOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratePatch(t *testing.T) {
+	state, dir := setupGitRepo(t)
+	ctx := context.Background()
+
+	result, err := state.executeGeneratePatch(ctx, dir, nil, false, "")
+	require.NoError(t, err)
+	assert.Contains(t, result, "No changes against HEAD")
+
+	// One change through edit, one made behind the session's back, and a new file from write.
+	hello := filepath.Join(dir, "hello.txt")
+	_, err = state.executeRead(ctx, hello, 0, 0)
+	require.NoError(t, err)
+	_, err = state.executeEdit(ctx, hello, editItem{OldString: "line 2", NewString: "line two"}, false, false)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.txt"), []byte("other\n"), 0o644))
+	runGitCmd(t, dir, "add", "other.txt")
+	runGitCmd(t, dir, "commit", "-q", "-m", "Add other")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.txt"), []byte("changed elsewhere\n"), 0o644))
+	_, err = callWrite(t, state, WriteInput{FilePath: filepath.Join(dir, "pkg", "new.go"), Content: "package pkg\n"})
+	require.NoError(t, err)
+
+	t.Run("whole tree", func(t *testing.T) {
+		result, err := state.executeGeneratePatch(ctx, dir, nil, false, "")
+		require.NoError(t, err)
+		assert.Contains(t, result, "diff --git a/hello.txt b/hello.txt")
+		assert.Contains(t, result, "-line 2\n+line two\n")
+		assert.Contains(t, result, "diff --git a/other.txt b/other.txt")
+		assert.Contains(t, result, "+++ b/pkg/new.go\n@@ -0,0 +1 @@\n+package pkg\n")
+	})
+
+	t.Run("session only", func(t *testing.T) {
+		result, err := state.executeGeneratePatch(ctx, dir, nil, true, "")
+		require.NoError(t, err)
+		assert.Contains(t, result, "a/hello.txt")
+		assert.Contains(t, result, "b/pkg/new.go")
+		assert.NotContains(t, result, "other.txt")
+
+		fresh := NewState()
+		result, err = fresh.executeGeneratePatch(ctx, dir, nil, true, "")
+		require.NoError(t, err)
+		assert.Contains(t, result, "No files under")
+	})
+
+	t.Run("files", func(t *testing.T) {
+		result, err := state.executeGeneratePatch(ctx, dir, []string{"other.txt"}, false, "")
+		require.NoError(t, err)
+		assert.Equal(t, 1, strings.Count(result, "diff --git"))
+		_, err = state.executeGeneratePatch(ctx, dir, []string{"../outside.txt"}, false, "")
+		assert.ErrorContains(t, err, "outside the repository")
+		_, err = state.executeGeneratePatch(ctx, dir, []string{"other.txt"}, true, "")
+		assert.ErrorContains(t, err, "cannot be combined")
+	})
+
+	t.Run("output file applies cleanly", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "changes.patch")
+		result, err := state.executeGeneratePatch(ctx, dir, nil, true, out)
+		require.NoError(t, err)
+		assert.Contains(t, result, "Wrote a patch of 2 files (+2 -1)")
+
+		// Regenerating in place is allowed without reading the patch first.
+		_, err = state.executeGeneratePatch(ctx, dir, nil, true, out)
+		require.NoError(t, err)
+
+		clone := filepath.Join(t.TempDir(), "clone")
+		runGitCmd(t, dir, "clone", "-q", dir, clone)
+		runGitCmd(t, clone, "apply", out)
+		content, err := os.ReadFile(filepath.Join(clone, "pkg", "new.go"))
+		require.NoError(t, err)
+		assert.Equal(t, "package pkg\n", string(content))
+		status, err := exec.Command("git", "-C", clone, "status", "--porcelain").Output()
+		require.NoError(t, err)
+		assert.Contains(t, string(status), " M hello.txt")
+	})
+}
@@ -0,0 +1,642 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// defaultGitLogCount limits log output when the caller doesn't ask for a specific number of commits.
+const defaultGitLogCount = 20
+
+// gitCommitFormat separates fields with the ASCII unit separator and records with the record
+// separator, neither of which can appear in commit metadata, so parsing never has to guess.
+const gitCommitFormat = "%H%x1f%h%x1f%an%x1f%ae%x1f%aI%x1f%s%x1f%b%x1e"
+
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+type gitStatusEntry struct {
+	Path           string `json:"path"`
+	OrigPath       string `json:"orig_path,omitempty"`
+	IndexStatus    string `json:"index_status"`
+	WorktreeStatus string `json:"worktree_status"`
+}
+
+type gitStatusResult struct {
+	Branch string           `json:"branch"`
+	Files  []gitStatusEntry `json:"files"`
+	Clean  bool             `json:"clean"`
+}
+
+type gitHunk struct {
+	OldStart int      `json:"old_start"`
+	OldLines int      `json:"old_lines"`
+	NewStart int      `json:"new_start"`
+	NewLines int      `json:"new_lines"`
+	Header   string   `json:"header,omitempty"`
+	Lines    []string `json:"lines"`
+}
+
+type gitDiffFile struct {
+	Path      string    `json:"path"`
+	OldPath   string    `json:"old_path,omitempty"`
+	Status    string    `json:"status"`
+	Binary    bool      `json:"binary,omitempty"`
+	Additions int       `json:"additions"`
+	Deletions int       `json:"deletions"`
+	Hunks     []gitHunk `json:"hunks"`
+}
+
+type gitCommit struct {
+	Hash      string `json:"hash"`
+	ShortHash string `json:"short_hash"`
+	Author    string `json:"author"`
+	Email     string `json:"email"`
+	Date      string `json:"date"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body,omitempty"`
+}
+
+type gitShowResult struct {
+	Commit gitCommit     `json:"commit"`
+	Files  []gitDiffFile `json:"files"`
+}
+
+type gitBlameLine struct {
+	Line    int    `json:"line"`
+	Commit  string `json:"commit"`
+	Author  string `json:"author"`
+	Date    string `json:"date"`
+	Content string `json:"content"`
+}
+
+func (s *State) executeGit(ctx context.Context, operation, path string, files []string, ref string, staged bool,
+	maxCount, startLine, endLine int, message string, create, force bool,
+) (string, error) {
+	dir, err := s.gitWorkDir(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(dir); err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		if err := s.checkPathAllowed(file); err != nil {
+			return "", err
+		}
+	}
+
+	var result any
+	switch operation {
+	case "status":
+		result, err = gitStatus(ctx, dir)
+	case "diff":
+		var diff []gitDiffFile
+		if diff, err = gitDiff(ctx, dir, ref, staged, files); err == nil {
+			result, err = s.withoutDeniedFiles(ctx, dir, diff)
+		}
+	case "log":
+		result, err = gitLog(ctx, dir, ref, maxCount, files)
+	case "show":
+		var show *gitShowResult
+		if show, err = gitShow(ctx, dir, ref); err == nil {
+			show.Files, err = s.withoutDeniedFiles(ctx, dir, show.Files)
+			result = show
+		}
+	case "blame":
+		result, err = gitBlame(ctx, dir, ref, files, startLine, endLine)
+	case "commit":
+		result, err = gitCommitChanges(ctx, dir, message, files)
+	case "switch":
+		result, err = gitSwitch(ctx, dir, ref, create, force)
+	default:
+		return "", fmt.Errorf("Invalid operation: %s. Must be one of: status, diff, log, show, blame, commit, switch.", operation)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format git output: %s", err)
+	}
+	output := string(jsonBytes)
+	if err := checkOutputSize(ctx, output, "git"); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// gitWorkDir resolves the directory git commands run in, defaulting to the session's working
+// directory.
+func (s *State) gitWorkDir(ctx context.Context, path string) (string, error) {
+	if path == "" {
+		wd := s.workDir(ctx)
+		if wd == "" {
+			return "", fmt.Errorf("Cannot determine working directory")
+		}
+		return wd, nil
+	}
+	resolved, err := s.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("directory does not exist")
+	}
+	return resolved, nil
+}
+
+// withoutDeniedFiles drops the files matching the path denylist from a diff of the repository
+// containing dir, whose paths are relative to its top level.
+func (s *State) withoutDeniedFiles(ctx context.Context, dir string, files []gitDiffFile) ([]gitDiffFile, error) {
+	s.Mu.RLock()
+	denied := s.DeniedPaths
+	s.Mu.RUnlock()
+	if len(denied) == 0 || len(files) == 0 {
+		return files, nil
+	}
+	top, err := runGit(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	top = strings.TrimSpace(top)
+	kept := []gitDiffFile{}
+	for _, file := range files {
+		if s.checkPathAllowed(filepath.Join(top, file.Path)) != nil {
+			continue
+		}
+		if file.OldPath != "" && s.checkPathAllowed(filepath.Join(top, file.OldPath)) != nil {
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept, nil
+}
+
+// runGit executes git in dir and returns stdout. Stderr is folded into the error on failure
+// since that's where git explains what went wrong. Hooks and the fsmonitor are disabled: both run
+// programs named by the repository, which a client allowed to write files could have planted.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir, "-c", "core.fsmonitor=false", "-c", "core.hooksPath=" + os.DevNull}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("git exited with code %d:\n%s", exitErr.ExitCode(), strings.TrimSpace(stderr.String()))
+		}
+		return "", fmt.Errorf("Failed to execute git: %s", err)
+	}
+	return stdout.String(), nil
+}
+
+func gitStatus(ctx context.Context, dir string) (*gitStatusResult, error) {
+	output, err := runGit(ctx, dir, "status", "--porcelain=v1", "--branch", "-z")
+	if err != nil {
+		return nil, err
+	}
+	result := &gitStatusResult{Files: []gitStatusEntry{}}
+	records := strings.Split(output, "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if record == "" {
+			continue
+		}
+		if strings.HasPrefix(record, "## ") {
+			result.Branch = strings.TrimPrefix(record, "## ")
+			continue
+		}
+		if len(record) < 4 {
+			continue
+		}
+		entry := gitStatusEntry{
+			IndexStatus:    statusName(record[0]),
+			WorktreeStatus: statusName(record[1]),
+			Path:           record[3:],
+		}
+		// With -z, renames and copies are followed by a separate record holding the source path.
+		if (record[0] == 'R' || record[0] == 'C') && i+1 < len(records) {
+			entry.OrigPath = records[i+1]
+			i++
+		}
+		result.Files = append(result.Files, entry)
+	}
+	result.Clean = len(result.Files) == 0
+	return result, nil
+}
+
+func statusName(code byte) string {
+	switch code {
+	case ' ':
+		return "unmodified"
+	case 'M':
+		return "modified"
+	case 'T':
+		return "type_changed"
+	case 'A':
+		return "added"
+	case 'D':
+		return "deleted"
+	case 'R':
+		return "renamed"
+	case 'C':
+		return "copied"
+	case 'U':
+		return "unmerged"
+	case '?':
+		return "untracked"
+	case '!':
+		return "ignored"
+	default:
+		return string(code)
+	}
+}
+
+// checkRef refuses refs that git would parse as options, such as --output=<file>, since they are
+// passed before the "--" that ends option parsing.
+func checkRef(ref string) error {
+	if strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("invalid ref: %s", ref)
+	}
+	return nil
+}
+
+func gitDiff(ctx context.Context, dir, ref string, staged bool, files []string) ([]gitDiffFile, error) {
+	if err := checkRef(ref); err != nil {
+		return nil, err
+	}
+	args := []string{"diff", "--no-color", "--no-ext-diff"}
+	if staged {
+		args = append(args, "--cached")
+	}
+	if ref != "" {
+		args = append(args, ref)
+	}
+	args = append(args, "--")
+	args = append(args, files...)
+	output, err := runGit(ctx, dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseUnifiedDiff(output), nil
+}
+
+// parseUnifiedDiff converts git's unified diff output into per-file hunks. Header lines are only
+// interpreted before a file's first hunk, so content lines that happen to begin with "--- " or
+// "+++ " are never mistaken for headers.
+func parseUnifiedDiff(diff string) []gitDiffFile {
+	files := []gitDiffFile{}
+	var file *gitDiffFile
+	var hunk *gitHunk
+
+	flush := func() {
+		if file == nil {
+			return
+		}
+		if hunk != nil {
+			file.Hunks = append(file.Hunks, *hunk)
+			hunk = nil
+		}
+		files = append(files, *file)
+		file = nil
+	}
+
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+			file = &gitDiffFile{Status: "modified", Hunks: []gitHunk{}}
+			if a, b, ok := strings.Cut(strings.TrimPrefix(line, "diff --git "), " b/"); ok {
+				file.OldPath = strings.TrimPrefix(a, "a/")
+				file.Path = b
+			}
+			continue
+		}
+		if file == nil {
+			continue
+		}
+		if m := hunkHeaderRegex.FindStringSubmatch(line); m != nil {
+			if hunk != nil {
+				file.Hunks = append(file.Hunks, *hunk)
+			}
+			hunk = &gitHunk{
+				OldStart: atoiDefault(m[1], 0),
+				OldLines: atoiDefault(m[2], 1),
+				NewStart: atoiDefault(m[3], 0),
+				NewLines: atoiDefault(m[4], 1),
+				Header:   m[5],
+				Lines:    []string{},
+			}
+			continue
+		}
+		if hunk != nil {
+			if line == "" {
+				continue
+			}
+			switch line[0] {
+			case '+':
+				file.Additions++
+			case '-':
+				file.Deletions++
+			case ' ', '\\':
+			default:
+				continue
+			}
+			hunk.Lines = append(hunk.Lines, line)
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "new file mode"):
+			file.Status = "added"
+		case strings.HasPrefix(line, "deleted file mode"):
+			file.Status = "deleted"
+		case strings.HasPrefix(line, "rename from "):
+			file.Status = "renamed"
+			file.OldPath = strings.TrimPrefix(line, "rename from ")
+		case strings.HasPrefix(line, "rename to "):
+			file.Path = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "Binary files "):
+			file.Binary = true
+		}
+	}
+	flush()
+
+	// Only renames need both paths; for everything else OldPath just repeats Path.
+	for i := range files {
+		if files[i].Status != "renamed" {
+			files[i].OldPath = ""
+		}
+	}
+	return files
+}
+
+func atoiDefault(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func gitLog(ctx context.Context, dir, ref string, maxCount int, files []string) ([]gitCommit, error) {
+	if maxCount <= 0 {
+		maxCount = defaultGitLogCount
+	}
+	maxCount = resultLimit(ctx, maxCount)
+	if err := checkRef(ref); err != nil {
+		return nil, err
+	}
+	args := []string{"log", "--no-color", fmt.Sprintf("--max-count=%d", maxCount), "--format=" + gitCommitFormat}
+	if ref != "" {
+		args = append(args, ref)
+	}
+	args = append(args, "--")
+	args = append(args, files...)
+	output, err := runGit(ctx, dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseGitCommits(output), nil
+}
+
+func parseGitCommits(output string) []gitCommit {
+	commits := []gitCommit{}
+	for _, record := range strings.Split(output, "\x1e") {
+		record = strings.TrimLeft(record, "\n")
+		if record == "" {
+			continue
+		}
+		fields := strings.SplitN(record, "\x1f", 7)
+		if len(fields) < 7 {
+			continue
+		}
+		commits = append(commits, gitCommit{
+			Hash:      fields[0],
+			ShortHash: fields[1],
+			Author:    fields[2],
+			Email:     fields[3],
+			Date:      fields[4],
+			Subject:   fields[5],
+			Body:      strings.TrimSpace(fields[6]),
+		})
+	}
+	return commits
+}
+
+func gitShow(ctx context.Context, dir, ref string) (*gitShowResult, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if err := checkRef(ref); err != nil {
+		return nil, err
+	}
+	meta, err := runGit(ctx, dir, "show", "--no-color", "--no-patch", "--format="+gitCommitFormat, ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	commits := parseGitCommits(meta)
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("no commit found for ref %s", ref)
+	}
+	diff, err := runGit(ctx, dir, "show", "--no-color", "--no-ext-diff", "--format=", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	return &gitShowResult{Commit: commits[0], Files: parseUnifiedDiff(diff)}, nil
+}
+
+func gitBlame(ctx context.Context, dir, ref string, files []string, startLine, endLine int) ([]gitBlameLine, error) {
+	if len(files) != 1 {
+		return nil, fmt.Errorf("blame requires exactly one file in files")
+	}
+	if err := checkRef(ref); err != nil {
+		return nil, err
+	}
+	args := []string{"blame", "--line-porcelain"}
+	if startLine > 0 || endLine > 0 {
+		start := max(startLine, 1)
+		if endLine > 0 {
+			args = append(args, fmt.Sprintf("-L%d,%d", start, endLine))
+		} else {
+			args = append(args, fmt.Sprintf("-L%d,", start))
+		}
+	}
+	if ref != "" {
+		args = append(args, ref)
+	}
+	args = append(args, "--", files[0])
+	output, err := runGit(ctx, dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	lines := parseBlamePorcelain(output)
+	if maxResults := limitsFromContext(ctx).maxResults; len(lines) > maxResults {
+		lines = lines[:maxResults]
+	}
+	return lines, nil
+}
+
+// parseBlamePorcelain parses `git blame --line-porcelain`, which repeats the full commit header
+// before every line, so each entry can be decoded without remembering earlier commits.
+func parseBlamePorcelain(output string) []gitBlameLine {
+	result := []gitBlameLine{}
+	var current gitBlameLine
+	var authorTime int64
+	var authorTZ string
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			current.Content = line[1:]
+			current.Date = formatGitTime(authorTime, authorTZ)
+			result = append(result, current)
+			current = gitBlameLine{}
+		case strings.HasPrefix(line, "author "):
+			current.Author = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-time "):
+			authorTime, _ = strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64)
+		case strings.HasPrefix(line, "author-tz "):
+			authorTZ = strings.TrimPrefix(line, "author-tz ")
+		default:
+			// Header line: "<40-hex sha> <orig line> <final line> [<group size>]".
+			fields := strings.Fields(line)
+			if len(fields) >= 3 && len(fields[0]) == 40 {
+				current.Commit = fields[0]
+				current.Line, _ = strconv.Atoi(fields[2])
+			}
+		}
+	}
+	return result
+}
+
+// formatGitTime renders a porcelain author-time/author-tz pair (e.g. 1700000000, "+0130") as
+// RFC 3339 in the author's own time zone, matching the dates log and show report.
+func formatGitTime(unix int64, tz string) string {
+	offset := 0
+	if len(tz) == 5 {
+		hours, errH := strconv.Atoi(tz[1:3])
+		minutes, errM := strconv.Atoi(tz[3:5])
+		if errH == nil && errM == nil {
+			offset = hours*3600 + minutes*60
+			if tz[0] == '-' {
+				offset = -offset
+			}
+		}
+	}
+	return time.Unix(unix, 0).In(time.FixedZone(tz, offset)).Format(time.RFC3339)
+}
+
+// gitSwitch checks out branch in dir, creating it from the current commit when create is set. Local
+// changes to tracked files make it refuse unless force is set, in which case they are discarded;
+// untracked files are left alone either way. It returns the status after the switch.
+func gitSwitch(ctx context.Context, dir, branch string, create, force bool) (*gitStatusResult, error) {
+	if branch == "" {
+		return nil, fmt.Errorf("switch requires ref, the branch to switch to")
+	}
+	if strings.HasPrefix(branch, "-") {
+		return nil, fmt.Errorf("invalid branch name: %s", branch)
+	}
+	status, err := gitStatus(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	if changed := trackedChanges(status); len(changed) > 0 && !force {
+		return nil, fmt.Errorf("the working tree has uncommitted changes to %s; commit or stash them first, or set force to discard them", strings.Join(changed, ", "))
+	}
+	args := []string{"switch"}
+	if force {
+		args = append(args, "--discard-changes")
+	}
+	if create {
+		args = append(args, "-c")
+	}
+	if _, err := runGit(ctx, dir, append(args, branch)...); err != nil {
+		return nil, err
+	}
+	return gitStatus(ctx, dir)
+}
+
+// trackedChanges lists the paths in status that have uncommitted changes, leaving out untracked
+// files, which switching branches does not touch.
+func trackedChanges(status *gitStatusResult) []string {
+	var changed []string
+	for _, f := range status.Files {
+		if f.IndexStatus != "untracked" {
+			changed = append(changed, f.Path)
+		}
+	}
+	return changed
+}
+
+func gitCommitChanges(ctx context.Context, dir, message string, files []string) (*gitCommit, error) {
+	if strings.TrimSpace(message) == "" {
+		return nil, fmt.Errorf("message is required for commit")
+	}
+	if len(files) > 0 {
+		if _, err := runGit(ctx, dir, append([]string{"add", "--"}, files...)...); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := runGit(ctx, dir, "commit", "--no-verify", "-m", message); err != nil {
+		return nil, err
+	}
+	commits, err := gitLog(ctx, dir, "HEAD", 1, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("commit succeeded but HEAD could not be read")
+	}
+	return &commits[0], nil
+}
+
+var GitTool = sdk.Tool{
+	Name:        "git",
+	Description: "Runs structured git operations and returns machine-readable JSON.\n\nOperations:\n- status: current branch and changed files with index/worktree status\n- diff: per-file hunks of unstaged changes (staged: true for the index, ref to compare against a commit); restrict with files\n- log: recent commits (hash, author, date, subject, body); max_count defaults to 20; optionally filter by ref and files\n- show: metadata and per-file hunks of a commit (ref defaults to HEAD)\n- blame: per-line commit, author, and date for exactly one file in files; optionally limit with start_line/end_line\n- commit: stages the given files (if any) and commits already-staged changes with message\n- switch: checks out the branch named by ref (create: true to create it first) and returns the new status; refuses when tracked files have uncommitted changes unless force is true, which DISCARDS those changes\n\nUsage notes:\n- path is the repository directory (absolute); defaults to the working directory, or the one set with the worktree tool\n- Prefer this tool over running git via Bash for these operations. Use Bash for anything else (push, rebase, stash, etc.)\n- NEVER commit unless the user explicitly asks you to",
+}
+
+type GitInput struct {
+	Operation string   `json:"operation" jsonschema:"The git operation: status, diff, log, show, blame, commit, or switch"`
+	Path      string   `json:"path,omitempty" jsonschema:"Absolute path of the repository directory. Defaults to the working directory"`
+	Files     []string `json:"files,omitempty" jsonschema:"Paths (relative to the repository directory) to restrict diff/log to, to blame (exactly one), or to stage before commit"`
+	Ref       string   `json:"ref,omitempty" jsonschema:"Commit, branch, or range to operate on (diff base, log start, show target, blame revision, switch branch)"`
+	Staged    bool     `json:"staged,omitempty" jsonschema:"For diff: show staged changes instead of unstaged ones"`
+	MaxCount  int      `json:"max_count,omitempty" jsonschema:"For log: maximum number of commits to return (default 20)"`
+	StartLine int      `json:"start_line,omitempty" jsonschema:"For blame: first line to annotate"`
+	EndLine   int      `json:"end_line,omitempty" jsonschema:"For blame: last line to annotate"`
+	Message   string   `json:"message,omitempty" jsonschema:"For commit: the commit message"`
+	Create    bool     `json:"create,omitempty" jsonschema:"For switch: create the branch from the current commit"`
+	Force     bool     `json:"force,omitempty" jsonschema:"For switch: switch even with uncommitted changes, discarding them"`
+}
+type GitOutput struct {
+	Result string `json:"result"`
+}
+
+func Git(ctx context.Context, req *sdk.CallToolRequest, args GitInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeGit(withSession(ctx, req), args.Operation, args.Path, args.Files, args.Ref, args.Staged,
+		args.MaxCount, args.StartLine, args.EndLine, args.Message, args.Create, args.Force)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &GitOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,203 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupGitRepo creates a repository with a single commit containing hello.txt. Identity is set
+// in the repo config so commits work regardless of the machine's global git configuration.
+func setupGitRepo(t *testing.T) (state *State, dir string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir = t.TempDir()
+	runGitCmd(t, dir, "init", "-q", "-b", "main")
+	runGitCmd(t, dir, "config", "user.name", "Test User")
+	runGitCmd(t, dir, "config", "user.email", "test@example.com")
+	runGitCmd(t, dir, "config", "commit.gpgsign", "false")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("line 1\nline 2\nline 3\n"), 0o644))
+	runGitCmd(t, dir, "add", "hello.txt")
+	runGitCmd(t, dir, "commit", "-q", "-m", "Initial commit")
+	return NewState(), dir
+}
+
+func runGitCmd(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+}
+
+func callGit(t *testing.T, state *State, input GitInput, out any) {
+	t.Helper()
+	result, err := state.executeGit(context.Background(), input.Operation, input.Path, input.Files, input.Ref,
+		input.Staged, input.MaxCount, input.StartLine, input.EndLine, input.Message, input.Create, input.Force)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(result), out))
+}
+
+func TestGit_Status(t *testing.T) {
+	state, dir := setupGitRepo(t)
+	t.Run("clean tree", func(t *testing.T) {
+		var status gitStatusResult
+		callGit(t, state, GitInput{Operation: "status", Path: dir}, &status)
+		assert.True(t, status.Clean)
+		assert.Contains(t, status.Branch, "main")
+	})
+	t.Run("modified and untracked", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("changed\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new\n"), 0o644))
+		var status gitStatusResult
+		callGit(t, state, GitInput{Operation: "status", Path: dir}, &status)
+		assert.False(t, status.Clean)
+		require.Len(t, status.Files, 2)
+		byPath := map[string]gitStatusEntry{}
+		for _, f := range status.Files {
+			byPath[f.Path] = f
+		}
+		assert.Equal(t, "modified", byPath["hello.txt"].WorktreeStatus)
+		assert.Equal(t, "untracked", byPath["new.txt"].IndexStatus)
+	})
+}
+
+func TestGit_DiffAndCommit(t *testing.T) {
+	state, dir := setupGitRepo(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("line 1\nline two\nline 3\n"), 0o644))
+
+	t.Run("diff hunks", func(t *testing.T) {
+		var files []gitDiffFile
+		callGit(t, state, GitInput{Operation: "diff", Path: dir}, &files)
+		require.Len(t, files, 1)
+		assert.Equal(t, "hello.txt", files[0].Path)
+		assert.Equal(t, "modified", files[0].Status)
+		assert.Equal(t, 1, files[0].Additions)
+		assert.Equal(t, 1, files[0].Deletions)
+		require.Len(t, files[0].Hunks, 1)
+		assert.Contains(t, files[0].Hunks[0].Lines, "-line 2")
+		assert.Contains(t, files[0].Hunks[0].Lines, "+line two")
+	})
+	t.Run("commit", func(t *testing.T) {
+		var commit gitCommit
+		callGit(t, state, GitInput{Operation: "commit", Path: dir, Files: []string{"hello.txt"}, Message: "Update line 2"}, &commit)
+		assert.Equal(t, "Update line 2", commit.Subject)
+		assert.Equal(t, "Test User", commit.Author)
+		assert.Len(t, commit.Hash, 40)
+	})
+	t.Run("log", func(t *testing.T) {
+		var commits []gitCommit
+		callGit(t, state, GitInput{Operation: "log", Path: dir}, &commits)
+		require.Len(t, commits, 2)
+		assert.Equal(t, "Update line 2", commits[0].Subject)
+		assert.Equal(t, "Initial commit", commits[1].Subject)
+	})
+	t.Run("show", func(t *testing.T) {
+		var show gitShowResult
+		callGit(t, state, GitInput{Operation: "show", Path: dir}, &show)
+		assert.Equal(t, "Update line 2", show.Commit.Subject)
+		require.Len(t, show.Files, 1)
+		assert.Equal(t, "hello.txt", show.Files[0].Path)
+	})
+	t.Run("blame", func(t *testing.T) {
+		var lines []gitBlameLine
+		callGit(t, state, GitInput{Operation: "blame", Path: dir, Files: []string{"hello.txt"}, StartLine: 2, EndLine: 3}, &lines)
+		require.Len(t, lines, 2)
+		assert.Equal(t, 2, lines[0].Line)
+		assert.Equal(t, "line two", lines[0].Content)
+		assert.Equal(t, "Test User", lines[0].Author)
+		assert.NotEmpty(t, lines[0].Date)
+	})
+}
+
+func TestGit_Switch(t *testing.T) {
+	state, dir := setupGitRepo(t)
+	ctx := context.Background()
+
+	var status gitStatusResult
+	callGit(t, state, GitInput{Operation: "switch", Path: dir, Ref: "feature", Create: true}, &status)
+	assert.Contains(t, status.Branch, "feature")
+
+	// Untracked files do not block a switch; changes to tracked files do, unless forced.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("scratch"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("changed\n"), 0o644))
+	_, err := state.executeGit(ctx, "switch", dir, nil, "main", false, 0, 0, 0, "", false, false)
+	assert.ErrorContains(t, err, "uncommitted changes to hello.txt")
+
+	callGit(t, state, GitInput{Operation: "switch", Path: dir, Ref: "main", Force: true}, &status)
+	assert.Contains(t, status.Branch, "main")
+	content, err := os.ReadFile(filepath.Join(dir, "hello.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "line 1\nline 2\nline 3\n", string(content))
+	assert.FileExists(t, filepath.Join(dir, "notes.txt"))
+
+	_, err = state.executeGit(ctx, "switch", dir, nil, "", false, 0, 0, 0, "", false, false)
+	assert.ErrorContains(t, err, "requires ref")
+}
+
+func TestGit_IgnoresRepositoryHooks(t *testing.T) {
+	state, dir := setupGitRepo(t)
+	marker := filepath.Join(t.TempDir(), "ran")
+	script := "#!/bin/sh\ntouch " + marker + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "hooks", "pre-commit"), []byte(script), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fsmonitor.sh"), []byte(script), 0o755))
+	runGitCmd(t, dir, "config", "core.fsmonitor", filepath.Join(dir, "fsmonitor.sh"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("changed\n"), 0o644))
+	var status gitStatusResult
+	callGit(t, state, GitInput{Operation: "status", Path: dir}, &status)
+	var commit gitCommit
+	callGit(t, state, GitInput{Operation: "commit", Path: dir, Files: []string{"hello.txt"}, Message: "Change"}, &commit)
+	assert.NoFileExists(t, marker)
+}
+
+func TestGit_ParseUnifiedDiff(t *testing.T) {
+	diff := "diff --git a/old.txt b/new.txt\nsimilarity index 90%\nrename from old.txt\nrename to new.txt\n" +
+		"diff --git a/added.go b/added.go\nnew file mode 100644\n--- /dev/null\n+++ b/added.go\n@@ -0,0 +1,2 @@\n+package x\n+--- not a header\n"
+	files := parseUnifiedDiff(diff)
+	require.Len(t, files, 2)
+	assert.Equal(t, "renamed", files[0].Status)
+	assert.Equal(t, "old.txt", files[0].OldPath)
+	assert.Equal(t, "new.txt", files[0].Path)
+	assert.Equal(t, "added", files[1].Status)
+	assert.Equal(t, 2, files[1].Additions)
+	require.Len(t, files[1].Hunks, 1)
+	assert.Equal(t, 1, files[1].Hunks[0].NewStart)
+	assert.Equal(t, 2, files[1].Hunks[0].NewLines)
+}
+
+func TestGit_Errors(t *testing.T) {
+	state, dir := setupGitRepo(t)
+	t.Run("invalid operation", func(t *testing.T) {
+		_, err := state.executeGit(context.Background(), "push", dir, nil, "", false, 0, 0, 0, "", false, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid operation")
+	})
+	t.Run("commit without message", func(t *testing.T) {
+		_, err := state.executeGit(context.Background(), "commit", dir, nil, "", false, 0, 0, 0, "", false, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "message is required")
+	})
+	t.Run("refs that look like options", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "out.txt")
+		for _, op := range []string{"diff", "log", "show", "blame"} {
+			_, err := state.executeGit(context.Background(), op, dir, []string{"hello.txt"}, "--output="+out, false, 0, 0, 0, "", false, false)
+			assert.ErrorContains(t, err, "invalid ref", op)
+		}
+		_, err := state.executeGit(context.Background(), "blame", dir, []string{"hello.txt"}, "--contents=/etc/passwd", false, 0, 0, 0, "", false, false)
+		assert.ErrorContains(t, err, "invalid ref")
+		assert.NoFileExists(t, out)
+	})
+	t.Run("not a repository", func(t *testing.T) {
+		_, err := state.executeGit(context.Background(), "status", t.TempDir(), nil, "", false, 0, 0, 0, "", false, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "git exited with code")
+	})
+}
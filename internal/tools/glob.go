@@ -2,75 +2,88 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"io/fs"
-	"os"
+	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
 	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 // fileInfo holds file path and modification time for sorting
 type fileInfo struct {
 	path    string
+	size    int64
 	modTime time.Time
 }
 
-func (s *State) executeGlob(ctx context.Context, pattern, path string) (string, error) {
+type globEntry struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime string `json:"mtime"`
+}
+
+type globResult struct {
+	Files        []globEntry `json:"files"`
+	TotalMatched int         `json:"total_matched"`
+	Offset       int         `json:"offset"`
+	Returned     int         `json:"returned"`
+	NextOffset   *int        `json:"next_offset,omitempty"`
+	Truncated    bool        `json:"truncated,omitempty"`
+}
+
+// maxGlobMatches stops the directory walk once this many files have matched, so a pattern like
+// "**/*" over a huge tree returns promptly. The newest-first order then covers only the files found.
+const maxGlobMatches = 100000
+
+// globSkipDirs are dependency, build, and VCS directories that rarely hold files worth finding and
+// can dwarf the rest of a tree. They are skipped unless named in the pattern itself.
+var globSkipDirs = []string{".git", ".hg", ".svn", "node_modules", "target", "__pycache__"}
+
+func (s *State) executeGlob(ctx context.Context, pattern, path string, limit, offset int, includeSkipped bool) (string, error) {
 	// Reject patterns containing null bytes to prevent potential security issues
-	if strings.Contains(pattern, "\x00") {
+	if strings.Contains(pattern, "\x00") || !doublestar.ValidatePattern(pattern) {
 		return "", fmt.Errorf("Invalid glob pattern.")
 	}
+	if offset < 0 {
+		return "", fmt.Errorf("offset cannot be negative.")
+	}
+	if limit < 0 {
+		return "", fmt.Errorf("limit cannot be negative.")
+	}
+	limit = resultLimit(ctx, limit)
 
 	searchDir := "."
 	if path != "" {
-		resolved, err := resolvePath(path)
+		resolved, err := s.resolvePath(path)
 		if err != nil {
 			return "", err
 		}
+		if err := s.checkPathAllowed(resolved); err != nil {
+			return "", err
+		}
 		searchDir = resolved
 	}
 
 	// Check if searchDir exists and is accessible
-	if _, err := os.Stat(searchDir); err != nil {
+	fsys := s.filesystem()
+	if _, err := fsys.Stat(searchDir); err != nil {
 		return "No files found", nil
 	}
 
-	var matches []fileInfo
-
-	// Use doublestar for proper glob matching with ** support
-	fsys := os.DirFS(searchDir)
-	err := doublestar.GlobWalk(fsys, pattern, func(path string, d fs.DirEntry) error {
-		// Check context cancellation
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-
-		// Only match files, not directories
-		if d.IsDir() {
-			return nil
-		}
-
-		info, err := d.Info()
-		if err != nil {
-			// Skip files we can't stat
-			return nil
-		}
-
-		matches = append(matches, fileInfo{
-			path:    path,
-			modTime: info.ModTime(),
-		})
-
-		return nil
-	})
-
-	if err != nil && err != context.Canceled {
+	walker := newGlobWalker(fsys, searchDir, pattern, maxGlobMatches, includeSkipped)
+	s.Mu.RLock()
+	walker.denied = s.DeniedPaths
+	s.Mu.RUnlock()
+	matches, truncated := walker.run(ctx)
+	if err := ctx.Err(); err != nil {
 		return "", err
 	}
 
@@ -78,22 +91,36 @@ func (s *State) executeGlob(ctx context.Context, pattern, path string) (string,
 		return "No files found", nil
 	}
 
-	// Sort by modification time (most recent first)
+	// Sort by modification time (most recent first), breaking ties by path so that pages are
+	// stable across calls.
 	sort.Slice(matches, func(i, j int) bool {
-		return matches[i].modTime.After(matches[j].modTime)
+		if !matches[i].modTime.Equal(matches[j].modTime) {
+			return matches[i].modTime.After(matches[j].modTime)
+		}
+		return matches[i].path < matches[j].path
 	})
 
-	// Build result string
-	var result strings.Builder
-	for i, match := range matches {
-		if i > 0 {
-			result.WriteByte('\n')
+	result := globResult{TotalMatched: len(matches), Offset: offset, Truncated: truncated, Files: []globEntry{}}
+	if offset < len(matches) {
+		end := min(offset+limit, len(matches))
+		for _, match := range matches[offset:end] {
+			result.Files = append(result.Files, globEntry{
+				Path:    match.path,
+				Size:    match.size,
+				ModTime: match.modTime.Format(time.RFC3339),
+			})
+		}
+		if end < len(matches) {
+			result.NextOffset = &end
 		}
-		result.WriteString(match.path)
 	}
+	result.Returned = len(result.Files)
 
-	resultStr := result.String()
-	resultStr = limitLines(ctx, resultStr)
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format glob results: %s", err)
+	}
+	resultStr := string(jsonBytes)
 	if err := checkOutputSize(ctx, resultStr, "glob"); err != nil {
 		return "", err
 	}
@@ -101,14 +128,179 @@ func (s *State) executeGlob(ctx context.Context, pattern, path string) (string,
 	return resultStr, nil
 }
 
+// globWalker matches files against a pattern by walking the tree with a bounded pool of goroutines,
+// pruning directories the pattern cannot reach into.
+type globWalker struct {
+	fsys     vfs.FS
+	root     string
+	pattern  string
+	segments []string
+	skip     map[string]bool
+	limit    int
+	// denied holds denylist patterns; matching files and directories are left out of the walk.
+	denied []string
+
+	wg      sync.WaitGroup
+	sem     chan struct{}
+	mu      sync.Mutex
+	matches []fileInfo
+	full    atomic.Bool
+}
+
+func newGlobWalker(fsys vfs.FS, root, pattern string, limit int, includeSkipped bool) *globWalker {
+	w := &globWalker{
+		fsys:     fsys,
+		root:     root,
+		pattern:  pattern,
+		segments: splitGlobPattern(pattern),
+		skip:     map[string]bool{},
+		limit:    limit,
+		sem:      make(chan struct{}, 4*runtime.GOMAXPROCS(0)),
+	}
+	if !includeSkipped {
+		for _, dir := range globSkipDirs {
+			w.skip[dir] = true
+		}
+		// A pattern that names a skipped directory, like "node_modules/react/**", clearly wants it.
+		for _, segment := range w.segments {
+			delete(w.skip, segment)
+		}
+	}
+	return w
+}
+
+// run walks the tree and returns the matching files, and whether the walk stopped at the limit.
+func (w *globWalker) run(ctx context.Context) ([]fileInfo, bool) {
+	w.wg.Add(1)
+	w.walkDir(ctx, w.root, "")
+	w.wg.Wait()
+	return w.matches, w.full.Load()
+}
+
+func (w *globWalker) walkDir(ctx context.Context, dir, rel string) {
+	defer w.wg.Done()
+	if ctx.Err() != nil || w.full.Load() {
+		return
+	}
+	entries, err := w.fsys.ReadDir(dir)
+	if err != nil {
+		// Skip directories we can't read
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		relPath := name
+		if rel != "" {
+			relPath = rel + "/" + name
+		}
+
+		child := filepath.Join(dir, name)
+		if _, denied := deniedBy(w.denied, child); denied {
+			continue
+		}
+		if entry.IsDir() {
+			if w.skip[name] || !w.canDescend(relPath) {
+				continue
+			}
+			w.wg.Add(1)
+			// Hand the subdirectory to a new goroutine while the pool has room; otherwise walk it
+			// here, which keeps the pool bounded without ever blocking on it.
+			select {
+			case w.sem <- struct{}{}:
+				go func() {
+					defer func() { <-w.sem }()
+					w.walkDir(ctx, child, relPath)
+				}()
+			default:
+				w.walkDir(ctx, child, relPath)
+			}
+			continue
+		}
+
+		if match, _ := doublestar.Match(w.pattern, relPath); !match {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// Skip files we can't stat
+			continue
+		}
+		if !w.add(fileInfo{path: relPath, size: info.Size(), modTime: info.ModTime()}) {
+			return
+		}
+	}
+}
+
+// add records a match and reports whether the walk should continue.
+func (w *globWalker) add(match fileInfo) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.matches) >= w.limit {
+		w.full.Store(true)
+		return false
+	}
+	w.matches = append(w.matches, match)
+	return true
+}
+
+// canDescend reports whether files beneath the directory rel could match the pattern, by matching
+// the directory's path segments against the pattern's leading segments.
+func (w *globWalker) canDescend(rel string) bool {
+	dirSegments := strings.Split(rel, "/")
+	for i, dirSegment := range dirSegments {
+		// The last pattern segment names the file, so deeper directories can't match
+		if i >= len(w.segments)-1 {
+			return false
+		}
+		segment := w.segments[i]
+		if strings.Contains(segment, "**") || strings.Contains(segment, "/") {
+			// Recursive wildcards, and alternatives spanning directories, can match any depth
+			return true
+		}
+		if match, _ := doublestar.Match(segment, dirSegment); !match {
+			return false
+		}
+	}
+	return true
+}
+
+// splitGlobPattern splits pattern at the slashes outside {} alternatives.
+func splitGlobPattern(pattern string) []string {
+	var segments []string
+	depth, start := 0, 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case '/':
+			if depth == 0 {
+				segments = append(segments, pattern[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segments, pattern[start:])
+}
+
 var GlobTool = sdk.Tool{
 	Name:        "glob",
-	Description: "- Fast file pattern matching tool that works with any codebase size\n- Supports glob patterns like \"**/*.js\" or \"src/**/*.ts\"\n- Returns matching file paths sorted by modification time\n- Use this tool when you need to find files by name patterns\n- When you are doing an open ended search that may require multiple rounds of globbing and grepping, use the Agent tool instead\n- You can call multiple tools in a single response. It is always better to speculatively perform multiple searches in parallel if they are potentially useful.",
+	Description: "- Fast file pattern matching tool that works with any codebase size\n- Supports glob patterns like \"**/*.js\" or \"src/**/*.ts\"\n- Returns matching files as JSON with path, size, and mtime, sorted by modification time (newest first)\n- Dependency, build, and VCS directories (node_modules, target, .git, and similar) are skipped unless the pattern names them or include_skipped_dirs is set\n- Very large result sets are cut off at 100000 matches; truncated is then set and the pattern or path should be narrowed\n- Results are paged: use limit (default and max 1000) and offset to fetch further pages; total_matched reports the full match count and next_offset is set when more results remain\n- Set workspace to search one of the server's named workspaces, with path relative to its root; paths of the form workspace://name/relative/path also work here and in every other file tool\n- Use this tool when you need to find files by name patterns\n- When you are doing an open ended search that may require multiple rounds of globbing and grepping, use the Agent tool instead\n- You can call multiple tools in a single response. It is always better to speculatively perform multiple searches in parallel if they are potentially useful.",
 }
 
 type GlobInput struct {
-	Pattern string `json:"pattern" jsonschema:"The glob pattern to match files against"`
-	Path    string `json:"path,omitempty" jsonschema:"The directory to search in. If not specified, the working directory will be used"`
+	Pattern            string `json:"pattern" jsonschema:"The glob pattern to match files against"`
+	Path               string `json:"path,omitempty" jsonschema:"The directory to search in. If not specified, the working directory will be used"`
+	Workspace          string `json:"workspace,omitempty" jsonschema:"Name of a workspace configured on the server to search instead of the working directory; path is then relative to its root"`
+	Limit              int    `json:"limit,omitempty" jsonschema:"Maximum number of files to return (default and max 1000, unless the server sets another limit)"`
+	Offset             int    `json:"offset,omitempty" jsonschema:"Number of matching files to skip, for fetching subsequent pages. Use next_offset from the previous result"`
+	IncludeSkippedDirs bool   `json:"include_skipped_dirs,omitempty" jsonschema:"Also search node_modules, target, .git, and other directories skipped by default"`
 }
 type GlobOutput struct {
 	Files string `json:"files"`
@@ -116,7 +308,11 @@ type GlobOutput struct {
 
 func Glob(ctx context.Context, req *sdk.CallToolRequest, args GlobInput) (*sdk.CallToolResult, any, error) {
 	server := GetState()
-	result, err := server.executeGlob(ctx, args.Pattern, args.Path)
+	path, err := workspacePath(args.Workspace, args.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := server.executeGlob(ctx, args.Pattern, path, args.Limit, args.Offset, args.IncludeSkippedDirs)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -2,10 +2,13 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
 	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -50,7 +53,14 @@ func callGlob(t *testing.T, state *State, input GlobInput) (string, error) {
 		path = wd
 	}
 
-	return state.executeGlob(context.Background(), input.Pattern, path)
+	return state.executeGlob(context.Background(), input.Pattern, path, input.Limit, input.Offset, input.IncludeSkippedDirs)
+}
+
+func decodeGlob(t *testing.T, result string) globResult {
+	t.Helper()
+	var decoded globResult
+	require.NoError(t, json.Unmarshal([]byte(result), &decoded))
+	return decoded
 }
 
 func TestGlob_BasicFunctionality(t *testing.T) {
@@ -136,6 +146,58 @@ func TestGlob_Patterns(t *testing.T) {
 	})
 }
 
+func TestGlob_Metadata(t *testing.T) {
+	state, dir := setupGlobTestFiles(t)
+
+	result, err := callGlob(t, state, GlobInput{Pattern: "README.md", Path: dir})
+	require.NoError(t, err)
+	decoded := decodeGlob(t, result)
+	require.Len(t, decoded.Files, 1)
+	assert.Equal(t, "README.md", decoded.Files[0].Path)
+	assert.Equal(t, int64(len("# README")), decoded.Files[0].Size)
+	assert.NotEmpty(t, decoded.Files[0].ModTime)
+	assert.Equal(t, 1, decoded.TotalMatched)
+	assert.Nil(t, decoded.NextOffset)
+}
+
+func TestGlob_Pagination(t *testing.T) {
+	state, dir := setupGlobTestFiles(t)
+
+	t.Run("pages cover all matches", func(t *testing.T) {
+		seen := map[string]bool{}
+		offset := 0
+		for {
+			result, err := callGlob(t, state, GlobInput{Pattern: "**/*", Path: dir, Limit: 4, Offset: offset})
+			require.NoError(t, err)
+			decoded := decodeGlob(t, result)
+			assert.Equal(t, 6, decoded.TotalMatched)
+			assert.LessOrEqual(t, decoded.Returned, 4)
+			for _, f := range decoded.Files {
+				assert.False(t, seen[f.Path], "duplicate %s", f.Path)
+				seen[f.Path] = true
+			}
+			if decoded.NextOffset == nil {
+				break
+			}
+			offset = *decoded.NextOffset
+		}
+		assert.Len(t, seen, 6)
+	})
+
+	t.Run("offset past end", func(t *testing.T) {
+		result, err := callGlob(t, state, GlobInput{Pattern: "**/*", Path: dir, Offset: 100})
+		require.NoError(t, err)
+		decoded := decodeGlob(t, result)
+		assert.Empty(t, decoded.Files)
+		assert.Equal(t, 6, decoded.TotalMatched)
+	})
+
+	t.Run("negative offset", func(t *testing.T) {
+		_, err := callGlob(t, state, GlobInput{Pattern: "**/*", Path: dir, Offset: -1})
+		assert.Error(t, err)
+	})
+}
+
 func TestGlob_Errors(t *testing.T) {
 	state := NewState()
 
@@ -180,3 +242,70 @@ func TestGlob_MCPIntegration(t *testing.T) {
 	require.NoError(t, err)
 	assert.NotNil(t, result)
 }
+
+func TestGlob_SkippedDirs(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{"src/app.js", "node_modules/react/index.js", ".git/hooks/pre-commit.js", "src/target/gen.js"} {
+		fullPath := filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0o755))
+		require.NoError(t, os.WriteFile(fullPath, []byte("x"), 0o644))
+	}
+	state := NewState()
+
+	t.Run("skipped by default", func(t *testing.T) {
+		result, err := callGlob(t, state, GlobInput{Pattern: "**/*.js", Path: dir})
+		require.NoError(t, err)
+		decoded := decodeGlob(t, result)
+		require.Len(t, decoded.Files, 1)
+		assert.Equal(t, "src/app.js", decoded.Files[0].Path)
+	})
+
+	t.Run("included on request", func(t *testing.T) {
+		result, err := callGlob(t, state, GlobInput{Pattern: "**/*.js", Path: dir, IncludeSkippedDirs: true})
+		require.NoError(t, err)
+		assert.Equal(t, 4, decodeGlob(t, result).TotalMatched)
+	})
+
+	t.Run("named in pattern", func(t *testing.T) {
+		result, err := callGlob(t, state, GlobInput{Pattern: "node_modules/**/*.js", Path: dir})
+		require.NoError(t, err)
+		decoded := decodeGlob(t, result)
+		require.Len(t, decoded.Files, 1)
+		assert.Equal(t, "node_modules/react/index.js", decoded.Files[0].Path)
+	})
+}
+
+func TestGlobWalker(t *testing.T) {
+	dir := t.TempDir()
+	for i := range 20 {
+		fullPath := filepath.Join(dir, fmt.Sprintf("d%d", i%4), fmt.Sprintf("f%d.go", i))
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0o755))
+		require.NoError(t, os.WriteFile(fullPath, []byte("x"), 0o644))
+	}
+
+	t.Run("finds every match", func(t *testing.T) {
+		matches, truncated := newGlobWalker(vfs.OS{}, dir, "**/*.go", 100, false).run(context.Background())
+		assert.Len(t, matches, 20)
+		assert.False(t, truncated)
+	})
+
+	t.Run("stops at the limit", func(t *testing.T) {
+		matches, truncated := newGlobWalker(vfs.OS{}, dir, "**/*.go", 5, false).run(context.Background())
+		assert.Len(t, matches, 5)
+		assert.True(t, truncated)
+	})
+
+	t.Run("prunes unreachable directories", func(t *testing.T) {
+		w := newGlobWalker(vfs.OS{}, dir, "d1/*.go", 100, false)
+		assert.True(t, w.canDescend("d1"))
+		assert.False(t, w.canDescend("d2"))
+		assert.False(t, w.canDescend("d1/nested"))
+		matches, _ := w.run(context.Background())
+		assert.Len(t, matches, 5)
+	})
+
+	t.Run("alternatives spanning directories", func(t *testing.T) {
+		assert.Equal(t, []string{"{a/b,c}", "*.go"}, splitGlobPattern("{a/b,c}/*.go"))
+		assert.True(t, newGlobWalker(vfs.OS{}, dir, "{a/b,c}/*.go", 100, false).canDescend("a"))
+	})
+}
@@ -1,47 +1,155 @@
 package tools
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
-func (s *State) executeGrep(ctx context.Context, pattern, path, outputMode, glob, typeFilter string,
-	caseInsensitive, multiline, lineNumber bool, contextAfter, contextBefore, contextAround, headLimit int,
-) (string, error) {
-	rgArgs, err := buildRipgrepArgs(outputMode, glob, typeFilter, caseInsensitive, multiline, lineNumber,
-		int64(contextAfter), int64(contextBefore), int64(contextAround))
+// grepOptions holds the ripgrep flags selected by a Grep call.
+type grepOptions struct {
+	OutputMode      string
+	Glob            string
+	Type            string
+	CaseInsensitive bool
+	SmartCase       bool
+	Engine          string
+	Multiline       bool
+	LineNumber      bool
+	ContextAfter    int
+	ContextBefore   int
+	ContextAround   int
+	FixedStrings    bool
+	InvertMatch     bool
+	WordRegexp      bool
+	Hidden          bool
+	NoIgnore        bool
+	FollowSymlinks  bool
+	MaxDepth        int
+	TimeoutMs       int64
+	TypeAdd         []string
+
+	// Files lists the files to search instead of walking a directory.
+	Files []string
+
+	// Progress, when set, receives content-mode matches as ripgrep finds them.
+	Progress *progressReporter
+}
+
+const (
+	// Streamed matches are sent in batches of up to grepStreamBatchLines lines, or sooner once
+	// grepStreamInterval has passed since the previous batch.
+	grepStreamBatchLines = 100
+	grepStreamInterval   = 250 * time.Millisecond
+
+	// maxGrepFiles bounds the files a Grep call may list, keeping ripgrep's command line well
+	// within the operating system's argument limit.
+	maxGrepFiles = 10000
+)
+
+func (s *State) executeGrep(ctx context.Context, patterns []string, path string, opts grepOptions, headLimit, offset int) (string, error) {
+	if offset < 0 {
+		return "", fmt.Errorf("offset cannot be negative.")
+	}
+	if len(patterns) == 0 {
+		return "", fmt.Errorf("at least one pattern is required")
+	}
+	if opts.TimeoutMs < 0 || opts.TimeoutMs > maxTimeout {
+		return "", fmt.Errorf("timeout_ms must be between 0 and %d milliseconds (10 minutes).", maxTimeout)
+	}
+	if path != "" && len(opts.Files) > 0 {
+		return "", fmt.Errorf("Specify either path or files, not both.")
+	}
+	if len(opts.Files) > maxGrepFiles {
+		return "", fmt.Errorf("files cannot list more than %d files.", maxGrepFiles)
+	}
+
+	headLimit = resultLimit(ctx, headLimit)
+	opts.TypeAdd = s.searchTypes()
+	rgArgs, err := buildRipgrepArgs(opts)
 	if err != nil {
 		return "", err
 	}
 
-	// Pattern must come after "--" to prevent it from being interpreted as a flag by ripgrep
-	rgArgs = append(rgArgs, "--", pattern)
+	// Exclusions come after any caller glob so that they take precedence over it
+	s.Mu.RLock()
+	denied := s.DeniedPaths
+	s.Mu.RUnlock()
+	cwd, _ := os.Getwd()
+	for _, glob := range ripgrepDenyGlobs(denied, cwd) {
+		rgArgs = append(rgArgs, "--glob", glob)
+	}
+
+	// Matches in listed files are always labelled with their file, even when only one is listed
+	if len(opts.Files) > 0 {
+		rgArgs = append(rgArgs, "--with-filename")
+	}
+
+	// Each pattern is passed with -e so that patterns starting with "-" are never read as flags, and
+	// multiple patterns are matched with OR semantics
+	for _, pattern := range patterns {
+		rgArgs = append(rgArgs, "-e", pattern)
+	}
+	rgArgs = append(rgArgs, "--")
 	if path != "" {
-		searchPath, err := resolvePath(path)
+		searchPath, err := s.resolvePath(path)
 		if err != nil {
 			return "", err
 		}
+		if err := s.checkPathAllowed(searchPath); err != nil {
+			return "", err
+		}
 		rgArgs = append(rgArgs, searchPath)
 	}
+	for _, file := range opts.Files {
+		filePath, err := s.resolvePath(file)
+		if err != nil {
+			return "", err
+		}
+		if err := s.checkPathAllowed(filePath); err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(filePath); err != nil {
+			return "", fmt.Errorf("file does not exist: %s", filePath)
+		}
+		rgArgs = append(rgArgs, filePath)
+	}
 
-	output, err := execRipgrep(ctx, rgArgs...)
+	searchCtx := ctx
+	if opts.TimeoutMs > 0 {
+		var cancel context.CancelFunc
+		searchCtx, cancel = context.WithTimeout(ctx, time.Duration(opts.TimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+	var output string
+	if opts.OutputMode == "content" && opts.Progress != nil {
+		output, err = streamRipgrep(searchCtx, grepPageStreamer(ctx, opts.Progress, offset, headLimit), rgArgs...)
+	} else {
+		output, err = execRipgrep(searchCtx, rgArgs...)
+	}
 	if err != nil {
+		if searchCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return "", fmt.Errorf("Search timed out after %dms. Narrow the path, add a glob or type filter, set max_depth, or increase timeout_ms.", opts.TimeoutMs)
+		}
 		return "", err
 	}
 
-	// Apply user-requested headLimit first, then system-wide constraints (limitLines, checkOutputSize)
-	output = applyHeadLimit(output, int(headLimit))
 	output = strings.TrimSpace(output)
 	if output == "" {
 		return "No matches found", nil
 	}
 
-	// limitLines enforces absolute max result count; checkOutputSize enforces max token output
-	output = limitLines(ctx, output)
+	// paginateGrepOutput enforces the max result count; checkOutputSize enforces max token output
+	output = paginateGrepOutput(output, opts.OutputMode, offset, headLimit)
 	if err := checkOutputSize(ctx, output, "grep"); err != nil {
 		return "", err
 	}
@@ -49,10 +157,9 @@ func (s *State) executeGrep(ctx context.Context, pattern, path, outputMode, glob
 	return output, nil
 }
 
-func buildRipgrepArgs(outputMode, glob, typeFilter string, caseInsensitive, multiline, lineNumber bool,
-	contextAfter, contextBefore, contextAround int64,
-) ([]string, error) {
+func buildRipgrepArgs(opts grepOptions) ([]string, error) {
 	rgArgs := []string{}
+	outputMode := opts.OutputMode
 	if outputMode == "" {
 		// Default to files_with_matches when user doesn't specify output mode
 		outputMode = "files_with_matches"
@@ -65,17 +172,20 @@ func buildRipgrepArgs(outputMode, glob, typeFilter string, caseInsensitive, mult
 	case "count":
 		rgArgs = append(rgArgs, "--count")
 	case "content":
+		// Sorting keeps content pages stable across calls. Other modes are sorted after the fact,
+		// which is cheaper than giving up ripgrep's parallel search.
+		rgArgs = append(rgArgs, "--sort", "path")
 		// Context flags only apply in content mode; they're ignored by ripgrep in other modes
-		if contextAfter > 0 {
-			rgArgs = append(rgArgs, fmt.Sprintf("-A%d", contextAfter))
+		if opts.ContextAfter > 0 {
+			rgArgs = append(rgArgs, fmt.Sprintf("-A%d", opts.ContextAfter))
 		}
-		if contextBefore > 0 {
-			rgArgs = append(rgArgs, fmt.Sprintf("-B%d", contextBefore))
+		if opts.ContextBefore > 0 {
+			rgArgs = append(rgArgs, fmt.Sprintf("-B%d", opts.ContextBefore))
 		}
-		if contextAround > 0 {
-			rgArgs = append(rgArgs, fmt.Sprintf("-C%d", contextAround))
+		if opts.ContextAround > 0 {
+			rgArgs = append(rgArgs, fmt.Sprintf("-C%d", opts.ContextAround))
 		}
-		if lineNumber {
+		if opts.LineNumber {
 			rgArgs = append(rgArgs, "--line-number")
 		}
 	default:
@@ -83,21 +193,69 @@ func buildRipgrepArgs(outputMode, glob, typeFilter string, caseInsensitive, mult
 	}
 
 	// Apply global filter options
-	if caseInsensitive {
+	if opts.CaseInsensitive && opts.SmartCase {
+		return nil, fmt.Errorf("Set at most one of -i and smart_case.")
+	}
+	if opts.CaseInsensitive {
 		rgArgs = append(rgArgs, "--ignore-case")
 	}
+	// Smart case ignores case unless the pattern has an uppercase letter
+	if opts.SmartCase {
+		rgArgs = append(rgArgs, "--smart-case")
+	}
+
+	// PCRE2 adds lookaround and backreferences, at some cost in speed
+	switch opts.Engine {
+	case "", "default":
+	case "pcre2":
+		rgArgs = append(rgArgs, "--pcre2")
+	default:
+		return nil, fmt.Errorf("Invalid engine: %s. Must be one of: default, pcre2.", opts.Engine)
+	}
 
 	// Multiline matching requires both flags: --multiline enables cross-line patterns,
 	// --multiline-dotall makes . match newlines
-	if multiline {
+	if opts.Multiline {
 		rgArgs = append(rgArgs, "--multiline", "--multiline-dotall")
 	}
 
-	if typeFilter != "" {
-		rgArgs = append(rgArgs, "--type", typeFilter)
+	// Matching behavior: literal patterns avoid escaping regex metacharacters in code searches
+	if opts.FixedStrings {
+		rgArgs = append(rgArgs, "--fixed-strings")
 	}
-	if glob != "" {
-		rgArgs = append(rgArgs, "--glob", glob)
+	if opts.InvertMatch {
+		rgArgs = append(rgArgs, "--invert-match")
+	}
+	if opts.WordRegexp {
+		rgArgs = append(rgArgs, "--word-regexp")
+	}
+
+	// File selection: by default rg skips dotfiles, ignored files, and symlinks
+	if opts.Hidden {
+		rgArgs = append(rgArgs, "--hidden")
+	}
+	if opts.NoIgnore {
+		rgArgs = append(rgArgs, "--no-ignore")
+	}
+	if opts.FollowSymlinks {
+		rgArgs = append(rgArgs, "--follow")
+	}
+	if opts.MaxDepth < 0 {
+		return nil, fmt.Errorf("max_depth cannot be negative.")
+	}
+	if opts.MaxDepth > 0 {
+		rgArgs = append(rgArgs, "--max-depth", strconv.Itoa(opts.MaxDepth))
+	}
+
+	// Custom types must be defined before --type can refer to them
+	for _, def := range opts.TypeAdd {
+		rgArgs = append(rgArgs, "--type-add", def)
+	}
+	if opts.Type != "" {
+		rgArgs = append(rgArgs, "--type", opts.Type)
+	}
+	if opts.Glob != "" {
+		rgArgs = append(rgArgs, "--glob", opts.Glob)
 	}
 
 	return rgArgs, nil
@@ -107,55 +265,182 @@ func execRipgrep(ctx context.Context, args ...string) (string, error) {
 	cmd := exec.CommandContext(ctx, "rg", args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			// Ripgrep exit codes: 1 = no matches found (normal), 2 = no files searched (error),
-			// other codes = actual failures
-			if exitErr.ExitCode() == 1 {
-				// No matches is not an error; return empty string with nil error
-				return "", nil
-			}
-			if exitErr.ExitCode() == 2 {
-				return "", fmt.Errorf("No files were searched. This usually means ripgrep applied a filter that excluded all files.")
+		// ripgrepError returns nil when ripgrep simply found no matches
+		return "", ripgrepError(err, output)
+	}
+	return string(output), nil
+}
+
+// ripgrepError converts a failed ripgrep run into the error reported to the client, or nil when
+// ripgrep merely found no matches.
+func ripgrepError(err error, output []byte) error {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		// Ripgrep exit codes: 1 = no matches found (normal), 2 = no files searched (error),
+		// other codes = actual failures
+		if exitErr.ExitCode() == 1 {
+			return nil
+		}
+		if exitErr.ExitCode() == 2 {
+			if bytes.Contains(output, []byte("PCRE2 is not available")) {
+				return fmt.Errorf("This ripgrep was built without PCRE2 support, so engine pcre2 cannot be used.")
 			}
-			return "", fmt.Errorf("rg exited with code %d:\n%s", exitErr.ExitCode(), output)
+			return fmt.Errorf("No files were searched. This usually means ripgrep applied a filter that excluded all files.")
 		}
+		return fmt.Errorf("rg exited with code %d:\n%s", exitErr.ExitCode(), output)
+	}
+	return fmt.Errorf("Failed to execute rg: %s", err)
+}
+
+// streamRipgrep runs ripgrep like execRipgrep, but also hands each batch of output lines to emit as
+// soon as ripgrep produces it, so callers can forward early matches before the search finishes.
+func streamRipgrep(ctx context.Context, emit func(lines []string), args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "rg", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
 		return "", fmt.Errorf("Failed to execute rg: %s", err)
 	}
-	return string(output), nil
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("Failed to execute rg: %s", err)
+	}
+
+	var output strings.Builder
+	var batch []string
+	lastFlush := time.Now()
+	// bufio.Reader rather than Scanner: matched lines in minified files can exceed any fixed limit.
+	reader := bufio.NewReader(stdout)
+	for {
+		line, readErr := reader.ReadString('\n')
+		if line != "" {
+			output.WriteString(line)
+			batch = append(batch, strings.TrimSuffix(line, "\n"))
+			if len(batch) >= grepStreamBatchLines || time.Since(lastFlush) >= grepStreamInterval {
+				emit(batch)
+				batch = nil
+				lastFlush = time.Now()
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	if len(batch) > 0 {
+		emit(batch)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if err := ripgrepError(err, stderr.Bytes()); err != nil {
+			return "", err
+		}
+	}
+	return output.String(), nil
 }
 
-func applyHeadLimit(output string, limit int) string {
+// grepPageStreamer returns an emit function for streamRipgrep that forwards the lines falling on
+// the requested page as progress notifications. Progress counts the lines seen so far.
+func grepPageStreamer(ctx context.Context, progress *progressReporter, offset, limit int) func([]string) {
 	if limit <= 0 {
-		return output
+		limit = absoluteMaxResults
 	}
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-	if len(lines) > limit {
-		lines = lines[:limit]
+	seen := 0
+	return func(lines []string) {
+		var page []string
+		for _, line := range lines {
+			if seen >= offset && seen < offset+limit {
+				page = append(page, line)
+			}
+			seen++
+		}
+		if len(page) > 0 {
+			progress.report(ctx, float64(seen), strings.Join(page, "\n"))
+		}
+	}
+}
+
+// paginateGrepOutput returns the page of output lines selected by offset and limit, followed by a
+// summary of how many lines (and, for count mode, matches) the search produced in total, so
+// clients can tell when results were cut short and request the next page.
+func paginateGrepOutput(output, outputMode string, offset, limit int) string {
+	lines := strings.Split(output, "\n")
+	if outputMode != "content" {
+		sort.Strings(lines)
+	}
+	if limit <= 0 {
+		limit = absoluteMaxResults
+	}
+
+	unit := "lines"
+	if outputMode == "" || outputMode == "files_with_matches" || outputMode == "count" {
+		unit = "files"
+	}
+	total := len(lines)
+	if offset >= total {
+		return fmt.Sprintf("No results at offset %d (%d %s matched in total).", offset, total, unit)
+	}
+	end := min(offset+limit, total)
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "[Showing %s %d-%d of %d", unit, offset+1, end, total)
+	if outputMode == "count" {
+		fmt.Fprintf(&summary, "; %d matches in total", sumGrepCounts(lines))
+	}
+	summary.WriteString("]")
+	if end < total {
+		fmt.Fprintf(&summary, " Use offset=%d to see more.", end)
+	}
+
+	return strings.Join(lines[offset:end], "\n") + "\n\n" + summary.String()
+}
+
+// sumGrepCounts totals the per-file counts from ripgrep's --count output ("path:N", or just "N"
+// when a single file was searched).
+func sumGrepCounts(lines []string) int {
+	total := 0
+	for _, line := range lines {
+		n, err := strconv.Atoi(line[strings.LastIndex(line, ":")+1:])
+		if err == nil {
+			total += n
+		}
 	}
-	return strings.Join(lines, "\n")
+	return total
 }
 
 var GrepTool = sdk.Tool{
 	Name:        "grep",
-	Description: "A powerful search tool built on ripgrep\n\n  Usage:\n  - ALWAYS use Grep for search tasks. NEVER invoke `grep` or `rg` as a Bash command. The Grep tool has been optimized for correct permissions and access.\n  - Supports full regex syntax (e.g., \"log.*Error\", \"function\\\\s+\\\\w+\")\n  - Filter files with glob parameter (e.g., \"*.js\", \"**/*.tsx\") or type parameter (e.g., \"js\", \"py\", \"rust\")\n  - Output modes: \"content\" shows matching lines, \"files_with_matches\" shows only file paths (default), \"count\" shows match counts\n  - Use Task tool for open-ended searches requiring multiple rounds\n  - Pattern syntax: Uses ripgrep (not grep) - literal braces need escaping (use `interface\\\\{\\\\}` to find `interface{}` in Go code)\n  - Multiline matching: By default patterns match within single lines only. For cross-line patterns like `struct \\\\{[\\\\s\\\\S]*?field`, use `multiline: true`\n",
+	Description: "A powerful search tool built on ripgrep\n\n  Usage:\n  - ALWAYS use Grep for search tasks. NEVER invoke `grep` or `rg` as a Bash command. The Grep tool has been optimized for correct permissions and access.\n  - Supports full regex syntax (e.g., \"log.*Error\", \"function\\\\s+\\\\w+\")\n  - Filter files with glob parameter (e.g., \"*.js\", \"**/*.tsx\") or type parameter (e.g., \"js\", \"py\", \"rust\")\n  - Output modes: \"content\" shows matching lines, \"files_with_matches\" shows only file paths (default), \"count\" shows match counts\n  - Results are paged: head_limit sets the page size (default and max 1000) and offset skips earlier results. A summary line reports how many files or lines matched in total and the offset of the next page\n  - Use Task tool for open-ended searches requiring multiple rounds\n  - Pattern syntax: Uses ripgrep (not grep) - literal braces need escaping (use `interface\\\\{\\\\}` to find `interface{}` in Go code), or set fixed_strings to search for the literal text\n  - To find several related identifiers in one pass, list them in patterns; lines matching any of them are reported\n  - Set smart_case to ignore case unless the pattern contains an uppercase letter, and engine to \"pcre2\" for lookaround (e.g. `foo(?!bar)`) and backreferences (e.g. `(\\w+) \\1`)\n  - Use word_regexp to match whole words only and invert_match to list non-matching lines\n  - Hidden files, files ignored by .gitignore, and symlinks are skipped by default; set hidden, no_ignore, or follow_symlinks to include them\n  - In content mode, clients that send a progress token receive matches as progress notifications while the search runs; the final result is unchanged\n  - Set workspace to search one of the server's named workspaces, with path relative to its root; paths of the form workspace://name/relative/path are also accepted\n  - To search exactly the files found by an earlier Glob or Grep call, pass them in files instead of a path; no directory is walked\n  - Searches over very large trees or network mounts can be bounded with max_depth and timeout_ms\n  - Multiline matching: By default patterns match within single lines only. For cross-line patterns like `struct \\\\{[\\\\s\\\\S]*?field`, use `multiline: true`\n",
 }
 
 // GrepInput represents parameters for the grep/ripgrep search.
 // JSON tag names for A, B, C, N, I follow ripgrep CLI conventions (-A, -B, -C, -n, -i)
 // to provide familiar naming to users familiar with ripgrep/grep command-line tools.
 type GrepInput struct {
-	Pattern    string `json:"pattern" jsonschema:"The regular expression pattern to search for in file contents"`
-	Path       string `json:"path,omitempty" jsonschema:"File or directory to search in. Defaults to working directory"`
-	Glob       string `json:"glob,omitempty" jsonschema:"Glob pattern to filter files (e.g. *.go)"`
-	Type       string `json:"type,omitempty" jsonschema:"File type to search (e.g. go, py). More efficient than include for standard file types"`
-	OutputMode string `json:"output_mode,omitempty" jsonschema:"Output mode: 'content' shows matching lines, 'files_with_matches' shows file paths (default), 'count' shows match counts"`
-	A          int    `json:"-A,omitempty" jsonschema:"Number of lines to show after each match. Requires output_mode: content"`
-	B          int    `json:"-B,omitempty" jsonschema:"Number of lines to show before each match. Requires output_mode: content"`
-	C          int    `json:"-C,omitempty" jsonschema:"Number of lines to show before and after each match. Requires output_mode: content"`
-	N          bool   `json:"-n,omitempty" jsonschema:"Show line numbers in output. Requires output_mode: content"`
-	I          bool   `json:"-i,omitempty" jsonschema:"Case insensitive search"`
-	Multiline  bool   `json:"multiline,omitempty" jsonschema:"Enable multiline mode where patterns can span lines. Default: false"`
-	HeadLimit  int    `json:"head_limit,omitempty" jsonschema:"Limit output to first N lines/entries"`
+	Pattern        string   `json:"pattern,omitempty" jsonschema:"The regular expression pattern to search for in file contents"`
+	Patterns       []string `json:"patterns,omitempty" jsonschema:"Additional patterns to search for in the same pass; lines matching any pattern are reported"`
+	Path           string   `json:"path,omitempty" jsonschema:"File or directory to search in. Defaults to working directory"`
+	Workspace      string   `json:"workspace,omitempty" jsonschema:"Name of a workspace configured on the server to search instead of the working directory; path is then relative to its root"`
+	Files          []string `json:"files,omitempty" jsonschema:"Absolute paths of the files to search, such as the results of an earlier Glob or Grep call, instead of a path"`
+	Glob           string   `json:"glob,omitempty" jsonschema:"Glob pattern to filter files (e.g. *.go)"`
+	Type           string   `json:"type,omitempty" jsonschema:"File type to search (e.g. go, py). More efficient than include for standard file types. Use list_search_types to see the available types"`
+	OutputMode     string   `json:"output_mode,omitempty" jsonschema:"Output mode: 'content' shows matching lines, 'files_with_matches' shows file paths (default), 'count' shows match counts"`
+	A              int      `json:"-A,omitempty" jsonschema:"Number of lines to show after each match. Requires output_mode: content"`
+	B              int      `json:"-B,omitempty" jsonschema:"Number of lines to show before each match. Requires output_mode: content"`
+	C              int      `json:"-C,omitempty" jsonschema:"Number of lines to show before and after each match. Requires output_mode: content"`
+	N              bool     `json:"-n,omitempty" jsonschema:"Show line numbers in output. Requires output_mode: content"`
+	I              bool     `json:"-i,omitempty" jsonschema:"Case insensitive search"`
+	SmartCase      bool     `json:"smart_case,omitempty" jsonschema:"Search case insensitively unless the pattern contains an uppercase letter (rg -S)"`
+	Engine         string   `json:"engine,omitempty" jsonschema:"Regex engine: 'default' (fast, no lookaround or backreferences) or 'pcre2' for lookaround and backreferences (rg -P)"`
+	Multiline      bool     `json:"multiline,omitempty" jsonschema:"Enable multiline mode where patterns can span lines. Default: false"`
+	FixedStrings   bool     `json:"fixed_strings,omitempty" jsonschema:"Treat the pattern as a literal string instead of a regex (rg -F)"`
+	InvertMatch    bool     `json:"invert_match,omitempty" jsonschema:"Select lines that do not match the pattern (rg -v)"`
+	WordRegexp     bool     `json:"word_regexp,omitempty" jsonschema:"Only match the pattern at word boundaries (rg -w)"`
+	Hidden         bool     `json:"hidden,omitempty" jsonschema:"Also search hidden files and directories such as .github (rg --hidden)"`
+	NoIgnore       bool     `json:"no_ignore,omitempty" jsonschema:"Also search files excluded by .gitignore and other ignore files (rg --no-ignore)"`
+	FollowSymlinks bool     `json:"follow_symlinks,omitempty" jsonschema:"Follow symbolic links while searching (rg -L)"`
+	MaxDepth       int      `json:"max_depth,omitempty" jsonschema:"Descend at most this many directory levels below path; 1 searches only the files directly inside it (rg --max-depth)"`
+	TimeoutMs      int64    `json:"timeout_ms,omitempty" jsonschema:"Abort the search after this many milliseconds (max 600000). Default: no limit"`
+	HeadLimit      int      `json:"head_limit,omitempty" jsonschema:"Limit output to first N lines/entries"`
+	Offset         int      `json:"offset,omitempty" jsonschema:"Skip the first N lines/entries, for fetching subsequent pages"`
 }
 type GrepOutput struct {
 	Results string `json:"results"`
@@ -163,9 +448,40 @@ type GrepOutput struct {
 
 func Grep(ctx context.Context, req *sdk.CallToolRequest, args GrepInput) (*sdk.CallToolResult, any, error) {
 	server := GetState()
-	result, err := server.executeGrep(ctx, args.Pattern, args.Path, args.OutputMode, args.Glob, args.Type,
-		args.I, args.Multiline, args.N,
-		args.A, args.B, args.C, args.HeadLimit)
+	patterns := args.Patterns
+	if args.Pattern != "" {
+		patterns = append([]string{args.Pattern}, patterns...)
+	}
+	if args.Workspace != "" && len(args.Files) > 0 {
+		return nil, nil, fmt.Errorf("workspace cannot be combined with files; name files by absolute path or workspace:// URI instead.")
+	}
+	path, err := workspacePath(args.Workspace, args.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := server.executeGrep(ctx, patterns, path, grepOptions{
+		OutputMode:      args.OutputMode,
+		Glob:            args.Glob,
+		Type:            args.Type,
+		CaseInsensitive: args.I,
+		SmartCase:       args.SmartCase,
+		Engine:          args.Engine,
+		Multiline:       args.Multiline,
+		LineNumber:      args.N,
+		ContextAfter:    args.A,
+		ContextBefore:   args.B,
+		ContextAround:   args.C,
+		FixedStrings:    args.FixedStrings,
+		InvertMatch:     args.InvertMatch,
+		WordRegexp:      args.WordRegexp,
+		Hidden:          args.Hidden,
+		NoIgnore:        args.NoIgnore,
+		FollowSymlinks:  args.FollowSymlinks,
+		MaxDepth:        args.MaxDepth,
+		TimeoutMs:       args.TimeoutMs,
+		Files:           args.Files,
+		Progress:        newProgressReporter(req),
+	}, args.HeadLimit, args.Offset)
 	if err != nil {
 		return nil, nil, err
 	}
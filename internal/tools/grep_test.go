@@ -137,6 +137,20 @@ func TestGrep_Patterns(t *testing.T) {
 		})
 		require.NoError(t, err)
 	})
+
+	t.Run("multiple patterns", func(t *testing.T) {
+		// Patterns are ORed together, so one call finds files matching any of them
+		result, _, err := Grep(context.Background(), &sdk.CallToolRequest{}, GrepInput{
+			Pattern:  "Hello",
+			Patterns: []string{"TestSomething", "-leading-dash"},
+			Path:     dir,
+		})
+		require.NoError(t, err)
+		text := result.Content[0].(*sdk.TextContent).Text
+		assert.Contains(t, text, "file1.go")
+		assert.Contains(t, text, "file2.go")
+		assert.NotContains(t, text, "file3.txt")
+	})
 }
 
 func TestGrep_OutputModes(t *testing.T) {
@@ -190,6 +204,45 @@ func TestGrep_Errors(t *testing.T) {
 		require.Error(t, err)
 	})
 
+	t.Run("timeout out of range", func(t *testing.T) {
+		_, _, err := Grep(context.Background(), &sdk.CallToolRequest{}, GrepInput{
+			Pattern:   "pattern",
+			Path:      t.TempDir(),
+			TimeoutMs: maxTimeout + 1,
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("no pattern", func(t *testing.T) {
+		_, _, err := Grep(context.Background(), &sdk.CallToolRequest{}, GrepInput{
+			Path: t.TempDir(),
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("files", func(t *testing.T) {
+		dir := setupGrepTestFiles(t)
+		_, _, err := Grep(context.Background(), &sdk.CallToolRequest{}, GrepInput{
+			Pattern: "pattern",
+			Path:    dir,
+			Files:   []string{filepath.Join(dir, "file1.go")},
+		})
+		require.Error(t, err, "path and files are exclusive")
+
+		_, _, err = Grep(context.Background(), &sdk.CallToolRequest{}, GrepInput{
+			Pattern: "pattern",
+			Files:   []string{"file1.go"},
+		})
+		require.Error(t, err, "listed files must be absolute")
+
+		_, _, err = Grep(context.Background(), &sdk.CallToolRequest{}, GrepInput{
+			Pattern: "pattern",
+			Files:   []string{filepath.Join(dir, "missing.go")},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "file does not exist")
+	})
+
 	t.Run("relative path rejected", func(t *testing.T) {
 		// resolvePath enforces absolute paths only for security; relative paths could access
 		// unintended directories depending on where ripgrep is invoked from
@@ -200,3 +253,161 @@ func TestGrep_Errors(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+func TestGrep_Files(t *testing.T) {
+	dir := setupGrepTestFiles(t)
+
+	t.Run("searches only the listed files", func(t *testing.T) {
+		result, _, err := Grep(context.Background(), &sdk.CallToolRequest{}, GrepInput{
+			Pattern: "package",
+			Files:   []string{filepath.Join(dir, "file2.go"), filepath.Join(dir, "file3.txt")},
+		})
+		require.NoError(t, err)
+		text := result.Content[0].(*sdk.TextContent).Text
+		assert.Contains(t, text, "file2.go")
+		assert.NotContains(t, text, "file1.go")
+	})
+
+	t.Run("single file matches are labelled with the file", func(t *testing.T) {
+		result, _, err := Grep(context.Background(), &sdk.CallToolRequest{}, GrepInput{
+			Pattern:    "Hello",
+			Files:      []string{filepath.Join(dir, "file1.go")},
+			OutputMode: "content",
+		})
+		require.NoError(t, err)
+		assert.Contains(t, result.Content[0].(*sdk.TextContent).Text, filepath.Join(dir, "file1.go")+":")
+	})
+}
+
+func TestGrep_Streaming(t *testing.T) {
+	dir := setupGrepTestFiles(t)
+
+	var batches [][]string
+	output, err := streamRipgrep(context.Background(), func(lines []string) {
+		batches = append(batches, lines)
+	}, "--sort", "path", "-e", "package", "--", dir)
+	require.NoError(t, err)
+
+	// Every streamed line appears in the final output, in the same order
+	var streamed []string
+	for _, batch := range batches {
+		streamed = append(streamed, batch...)
+	}
+	assert.Equal(t, strings.Split(strings.TrimSpace(output), "\n"), streamed)
+
+	t.Run("no matches", func(t *testing.T) {
+		output, err := streamRipgrep(context.Background(), func([]string) {
+			t.Fatal("nothing should be streamed")
+		}, "-e", "no-such-text", "--", dir)
+		require.NoError(t, err)
+		assert.Empty(t, output)
+	})
+}
+
+func TestGrep_Pagination(t *testing.T) {
+	output := "c.go\na.go\nb.go\nd.go\ne.go"
+
+	t.Run("first page reports total and next offset", func(t *testing.T) {
+		result := paginateGrepOutput(output, "files_with_matches", 0, 2)
+		assert.True(t, strings.HasPrefix(result, "a.go\nb.go\n\n"), result)
+		assert.Contains(t, result, "[Showing files 1-2 of 5]")
+		assert.Contains(t, result, "offset=2")
+	})
+
+	t.Run("last page has no next offset", func(t *testing.T) {
+		result := paginateGrepOutput(output, "files_with_matches", 4, 2)
+		assert.True(t, strings.HasPrefix(result, "e.go\n\n"), result)
+		assert.Contains(t, result, "[Showing files 5-5 of 5]")
+		assert.NotContains(t, result, "offset=")
+	})
+
+	t.Run("offset past end", func(t *testing.T) {
+		result := paginateGrepOutput(output, "files_with_matches", 10, 0)
+		assert.Contains(t, result, "No results at offset 10")
+	})
+
+	t.Run("content mode keeps ripgrep order", func(t *testing.T) {
+		result := paginateGrepOutput("z.go:1:x\na.go:2:y", "content", 0, 0)
+		assert.True(t, strings.HasPrefix(result, "z.go:1:x\na.go:2:y"), result)
+		assert.Contains(t, result, "[Showing lines 1-2 of 2]")
+	})
+
+	t.Run("count mode sums matches", func(t *testing.T) {
+		result := paginateGrepOutput("a.go:3\nb.go:4", "count", 0, 1)
+		assert.Contains(t, result, "[Showing files 1-1 of 2; 7 matches in total]")
+	})
+
+	t.Run("negative offset rejected", func(t *testing.T) {
+		_, _, err := Grep(context.Background(), &sdk.CallToolRequest{}, GrepInput{
+			Pattern: "x",
+			Path:    t.TempDir(),
+			Offset:  -1,
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestBuildRipgrepArgs(t *testing.T) {
+	t.Run("defaults to files_with_matches", func(t *testing.T) {
+		args, err := buildRipgrepArgs(grepOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"--files-with-matches"}, args)
+	})
+
+	t.Run("matching flags", func(t *testing.T) {
+		args, err := buildRipgrepArgs(grepOptions{FixedStrings: true, InvertMatch: true, WordRegexp: true})
+		require.NoError(t, err)
+		assert.Contains(t, args, "--fixed-strings")
+		assert.Contains(t, args, "--invert-match")
+		assert.Contains(t, args, "--word-regexp")
+	})
+
+	t.Run("case and engine flags", func(t *testing.T) {
+		args, err := buildRipgrepArgs(grepOptions{SmartCase: true, Engine: "pcre2"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"--files-with-matches", "--smart-case", "--pcre2"}, args)
+
+		args, err = buildRipgrepArgs(grepOptions{Engine: "default"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"--files-with-matches"}, args)
+
+		_, err = buildRipgrepArgs(grepOptions{Engine: "pcre"})
+		require.Error(t, err)
+		_, err = buildRipgrepArgs(grepOptions{SmartCase: true, CaseInsensitive: true})
+		require.Error(t, err)
+	})
+
+	t.Run("file selection flags", func(t *testing.T) {
+		args, err := buildRipgrepArgs(grepOptions{Hidden: true, NoIgnore: true, FollowSymlinks: true})
+		require.NoError(t, err)
+		assert.Contains(t, args, "--hidden")
+		assert.Contains(t, args, "--no-ignore")
+		assert.Contains(t, args, "--follow")
+	})
+
+	t.Run("context flags only in content mode", func(t *testing.T) {
+		args, err := buildRipgrepArgs(grepOptions{OutputMode: "content", ContextAfter: 2, LineNumber: true})
+		require.NoError(t, err)
+		assert.Contains(t, args, "-A2")
+		assert.Contains(t, args, "--line-number")
+
+		args, err = buildRipgrepArgs(grepOptions{OutputMode: "count", ContextAfter: 2})
+		require.NoError(t, err)
+		assert.NotContains(t, args, "-A2")
+	})
+
+	t.Run("custom types precede the type filter", func(t *testing.T) {
+		args, err := buildRipgrepArgs(grepOptions{Type: "proto", TypeAdd: []string{"proto:*.proto3"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"--files-with-matches", "--type-add", "proto:*.proto3", "--type", "proto"}, args)
+	})
+
+	t.Run("max depth", func(t *testing.T) {
+		args, err := buildRipgrepArgs(grepOptions{MaxDepth: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"--files-with-matches", "--max-depth", "2"}, args)
+
+		_, err = buildRipgrepArgs(grepOptions{MaxDepth: -1})
+		require.Error(t, err)
+	})
+}
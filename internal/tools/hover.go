@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func (s *State) executeHover(ctx context.Context, filePath string, line int, symbol string, column int) (string, error) {
+	client, target, err := s.lspTarget(ctx, filePath, line, symbol, column)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if err := client.call(ctx, "textDocument/hover", target, &result); err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(hoverText(result.Contents))
+	if text == "" {
+		return "No hover information available.", nil
+	}
+	if err := checkOutputSize(ctx, text, "hover"); err != nil {
+		return "", err
+	}
+	return text, nil
+}
+
+// hoverText flattens hover contents, which may be a string, a MarkupContent, a MarkedString with a
+// language, or a list of these, into markdown.
+func hoverText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	var list []json.RawMessage
+	if json.Unmarshal(raw, &list) == nil {
+		parts := make([]string, 0, len(list))
+		for _, item := range list {
+			if part := strings.TrimSpace(hoverText(item)); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	var content struct {
+		Kind     string `json:"kind"`
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	}
+	if json.Unmarshal(raw, &content) != nil {
+		return ""
+	}
+	if content.Language != "" {
+		return "```" + content.Language + "\n" + content.Value + "\n```"
+	}
+	return content.Value
+}
+
+var HoverTool = sdk.Tool{
+	Name:        "hover",
+	Description: "Shows a symbol's type, signature, and documentation, using the project's language server, without reading the file that declares it.\n\nUsage:\n- Give the file, the 1-based line where the symbol appears, and the symbol's name as it appears on that line (or a 1-based column instead).\n- Returns the language server's hover text, usually markdown with the declaration in a code block.\n- Language servers are started on demand for the file's project; the first call may take a while as the server loads it.",
+}
+
+type HoverOutput struct {
+	Result string `json:"result"`
+}
+
+func Hover(ctx context.Context, req *sdk.CallToolRequest, args LSPPositionInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeHover(ctx, args.FilePath, args.Line, args.Symbol, args.Column)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &HoverOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
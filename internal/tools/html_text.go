@@ -0,0 +1,644 @@
+package tools
+
+import (
+	"html"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// htmlNode is an element or, with an empty tag, a text node of a parsed HTML document.
+type htmlNode struct {
+	tag      string
+	text     string
+	attrs    map[string]string
+	children []*htmlNode
+	parent   *htmlNode
+}
+
+var (
+	htmlVoidTags    = []string{"area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "param", "source", "track", "wbr"}
+	htmlRawTextTags = []string{"script", "style", "textarea", "title", "xmp", "noscript", "template"}
+	// htmlClosesP lists the elements whose start tag ends an open paragraph.
+	htmlClosesP   = []string{"address", "article", "aside", "blockquote", "details", "div", "dl", "fieldset", "figure", "footer", "form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "main", "nav", "ol", "p", "pre", "section", "table", "ul"}
+	htmlBlockTags = []string{"address", "article", "aside", "blockquote", "body", "center", "dd", "details", "div", "dl", "dt", "fieldset", "figcaption", "figure", "footer", "form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "html", "li", "main", "nav", "ol", "p", "pre", "section", "summary", "table", "ul"}
+)
+
+// parseHTML builds a tree from an HTML document. It is forgiving the way browsers are: unclosed
+// paragraphs, list items, and table cells close when the next one starts, stray end tags are
+// ignored, and the content of script and style elements is kept as raw text.
+func parseHTML(src string) *htmlNode {
+	if !utf8.ValidString(src) {
+		// Pages that are not UTF-8 are nearly always Latin-1 or its Windows superset.
+		runes := make([]rune, len(src))
+		for i := 0; i < len(src); i++ {
+			runes[i] = rune(src[i])
+		}
+		src = string(runes)
+	}
+	root := &htmlNode{tag: "#document"}
+	stack := []*htmlNode{root}
+	top := func() *htmlNode { return stack[len(stack)-1] }
+	appendNode := func(n *htmlNode) {
+		n.parent = top()
+		n.parent.children = append(n.parent.children, n)
+	}
+	// closeTo pops the innermost open tag among tags, unless a tag of stopAt is open inside it.
+	closeTo := func(tags, stopAt []string) {
+		for i := len(stack) - 1; i > 0; i-- {
+			if slices.Contains(tags, stack[i].tag) {
+				stack = stack[:i]
+				return
+			}
+			if slices.Contains(stopAt, stack[i].tag) {
+				return
+			}
+		}
+	}
+
+	for i := 0; i < len(src); {
+		lt := strings.IndexByte(src[i:], '<')
+		if lt < 0 {
+			appendNode(&htmlNode{text: html.UnescapeString(src[i:])})
+			break
+		}
+		if lt > 0 {
+			appendNode(&htmlNode{text: html.UnescapeString(src[i : i+lt])})
+			i += lt
+		}
+		rest := src[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += 4 + end + 3
+			}
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			i += htmlTagEnd(rest)
+		case strings.HasPrefix(rest, "</"):
+			name := htmlTagName(rest[2:])
+			i += htmlTagEnd(rest)
+			if name != "" {
+				closeTo([]string{name}, nil)
+			}
+		case len(rest) > 1 && isASCIILetter(rest[1]):
+			name, attrs, selfClosing, n := parseHTMLStartTag(rest)
+			i += n
+			switch {
+			case name == "li":
+				closeTo([]string{"li"}, []string{"ul", "ol"})
+			case name == "dt" || name == "dd":
+				closeTo([]string{"dt", "dd"}, []string{"dl"})
+			case name == "tr":
+				closeTo([]string{"tr"}, []string{"table"})
+			case name == "td" || name == "th":
+				closeTo([]string{"td", "th"}, []string{"tr", "table"})
+			case name == "option":
+				closeTo([]string{"option"}, []string{"select"})
+			}
+			if slices.Contains(htmlClosesP, name) && top().tag == "p" {
+				stack = stack[:len(stack)-1]
+			}
+			node := &htmlNode{tag: name, attrs: attrs}
+			appendNode(node)
+			if slices.Contains(htmlRawTextTags, name) && !selfClosing {
+				end := indexFold(src[i:], "</"+name)
+				if end < 0 {
+					end = len(src) - i
+				}
+				raw := src[i : i+end]
+				if name == "title" || name == "textarea" {
+					raw = html.UnescapeString(raw)
+				}
+				node.children = []*htmlNode{{text: raw, parent: node}}
+				i += end
+				if i < len(src) {
+					i += htmlTagEnd(src[i:])
+				}
+			} else if !selfClosing && !slices.Contains(htmlVoidTags, name) {
+				stack = append(stack, node)
+			}
+		default:
+			appendNode(&htmlNode{text: "<"})
+			i++
+		}
+	}
+	return root
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func htmlTagName(s string) string {
+	end := 0
+	for end < len(s) && (isASCIILetter(s[end]) || s[end] >= '0' && s[end] <= '9' || s[end] == '-' || s[end] == ':') {
+		end++
+	}
+	return strings.ToLower(s[:end])
+}
+
+// htmlTagEnd returns the length of the tag at the start of s, through its closing >.
+func htmlTagEnd(s string) int {
+	if end := strings.IndexByte(s, '>'); end >= 0 {
+		return end + 1
+	}
+	return len(s)
+}
+
+func indexFold(s, substr string) int {
+	return strings.Index(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// parseHTMLStartTag parses the start tag at the start of s, returning its length.
+func parseHTMLStartTag(s string) (name string, attrs map[string]string, selfClosing bool, n int) {
+	name = htmlTagName(s[1:])
+	attrs = map[string]string{}
+	i := 1 + len(name)
+	for i < len(s) {
+		for i < len(s) && strings.ContainsRune(" \t\r\n\f", rune(s[i])) {
+			i++
+		}
+		if i == len(s) {
+			break
+		}
+		if s[i] == '>' {
+			return name, attrs, selfClosing, i + 1
+		}
+		if s[i] == '/' {
+			selfClosing = true
+			i++
+			continue
+		}
+		selfClosing = false
+		start := i
+		for i < len(s) && !strings.ContainsRune(" \t\r\n\f=/>", rune(s[i])) {
+			i++
+		}
+		if i == start {
+			i++
+			continue
+		}
+		key := strings.ToLower(s[start:i])
+		value := ""
+		if i < len(s) && s[i] == '=' {
+			i++
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				quote := s[i]
+				end := strings.IndexByte(s[i+1:], quote)
+				if end < 0 {
+					end = len(s) - i - 1
+				}
+				value = s[i+1 : i+1+end]
+				i = min(i+1+end+1, len(s))
+			} else {
+				start := i
+				for i < len(s) && !strings.ContainsRune(" \t\r\n\f>", rune(s[i])) {
+					i++
+				}
+				value = s[start:i]
+			}
+		}
+		if _, ok := attrs[key]; !ok {
+			attrs[key] = html.UnescapeString(value)
+		}
+	}
+	return name, attrs, selfClosing, len(s)
+}
+
+// find returns the elements below n, in document order, for which match is true.
+func (n *htmlNode) find(match func(*htmlNode) bool) []*htmlNode {
+	var found []*htmlNode
+	for _, c := range n.children {
+		if c.tag != "" && match(c) {
+			found = append(found, c)
+		}
+		found = append(found, c.find(match)...)
+	}
+	return found
+}
+
+func (n *htmlNode) first(tag string) *htmlNode {
+	if found := n.find(func(c *htmlNode) bool { return c.tag == tag }); len(found) > 0 {
+		return found[0]
+	}
+	return nil
+}
+
+// innerText returns the text below n with whitespace collapsed.
+func (n *htmlNode) innerText() string {
+	var b strings.Builder
+	var walk func(*htmlNode)
+	walk = func(n *htmlNode) {
+		if n.tag == "" {
+			b.WriteString(n.text)
+			b.WriteByte(' ')
+		}
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// linkDensity is the share of n's text that is link text.
+func (n *htmlNode) linkDensity() float64 {
+	total := len(n.innerText())
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	for _, a := range n.find(func(c *htmlNode) bool { return c.tag == "a" }) {
+		links += len(a.innerText())
+	}
+	return float64(links) / float64(total)
+}
+
+func (n *htmlNode) remove() {
+	if n.parent == nil {
+		return
+	}
+	n.parent.children = slices.DeleteFunc(n.parent.children, func(c *htmlNode) bool { return c == n })
+	n.parent = nil
+}
+
+// The class and id patterns Mozilla's Readability uses to tell page furniture from content.
+var (
+	htmlUnlikely = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|ad-break|agegate|pagination|pager|popup|yom-remote|cookie`)
+	htmlMaybe    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	htmlNegative = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+	htmlPositive = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+)
+
+// htmlNoiseTags never hold readable text.
+var htmlNoiseTags = []string{"head", "title", "script", "style", "noscript", "template", "svg", "canvas", "iframe", "object", "embed", "button", "select", "input", "textarea", "link", "meta"}
+
+// htmlPageFurniture are the elements dropped when extracting just the article.
+var htmlPageFurniture = []string{"nav", "header", "footer", "aside", "form", "dialog", "menu"}
+
+// stripHTML removes the elements that are not content: always the noise and hidden elements, and
+// in article mode the page furniture and elements whose class or id marks them as such.
+func stripHTML(n *htmlNode, article bool) {
+	for _, c := range slices.Clone(n.children) {
+		if c.tag == "" {
+			continue
+		}
+		classID := c.attrs["class"] + " " + c.attrs["id"]
+		_, hidden := c.attrs["hidden"]
+		hidden = hidden || c.attrs["aria-hidden"] == "true" || strings.Contains(strings.ReplaceAll(c.attrs["style"], " ", ""), "display:none")
+		unlikely := article && (slices.Contains(htmlPageFurniture, c.tag) ||
+			htmlUnlikely.MatchString(classID) && !htmlMaybe.MatchString(classID) && !slices.Contains([]string{"body", "article", "main", "a", "table", "tbody", "tr", "td"}, c.tag))
+		if slices.Contains(htmlNoiseTags, c.tag) || hidden || unlikely {
+			c.remove()
+			continue
+		}
+		stripHTML(c, article)
+	}
+}
+
+// articleContent picks the elements holding a page's main text, as Readability does: a single
+// <article> or <main> when the page marks one, otherwise the element whose paragraphs score
+// highest, together with sibling elements that score nearly as well.
+func articleContent(body *htmlNode) []*htmlNode {
+	marked := body.find(func(c *htmlNode) bool {
+		return c.tag == "article" || c.tag == "main" || c.attrs["role"] == "main"
+	})
+	if len(marked) > 0 {
+		best := marked[0]
+		for _, m := range marked[1:] {
+			if len(m.innerText()) > len(best.innerText()) {
+				best = m
+			}
+		}
+		if len(best.innerText()) >= 250 {
+			return []*htmlNode{best}
+		}
+	}
+
+	scores := map[*htmlNode]float64{}
+	initScore := func(n *htmlNode) {
+		if _, ok := scores[n]; ok {
+			return
+		}
+		score := 0.0
+		switch n.tag {
+		case "div":
+			score = 5
+		case "pre", "td", "blockquote":
+			score = 3
+		case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+			score = -3
+		case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+			score = -5
+		}
+		classID := n.attrs["class"] + " " + n.attrs["id"]
+		if htmlNegative.MatchString(classID) {
+			score -= 25
+		}
+		if htmlPositive.MatchString(classID) {
+			score += 25
+		}
+		scores[n] = score
+	}
+	paragraphs := body.find(func(c *htmlNode) bool {
+		return c.tag == "p" || c.tag == "pre" || c.tag == "td" ||
+			c.tag == "div" && !slices.ContainsFunc(c.children, func(g *htmlNode) bool { return slices.Contains(htmlBlockTags, g.tag) })
+	})
+	for _, p := range paragraphs {
+		text := p.innerText()
+		if len(text) < 25 {
+			continue
+		}
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text)/100), 3)
+		for level, ancestor := 0, p.parent; ancestor != nil && ancestor.tag != "#document" && level < 3; level, ancestor = level+1, ancestor.parent {
+			initScore(ancestor)
+			divider := []float64{1, 2, 6}[level]
+			scores[ancestor] += score / divider
+		}
+	}
+
+	var top *htmlNode
+	for n, score := range scores {
+		scores[n] = score * (1 - n.linkDensity())
+		if top == nil || scores[n] > scores[top] || scores[n] == scores[top] && len(n.innerText()) > len(top.innerText()) {
+			top = n
+		}
+	}
+	if top == nil || top.parent == nil {
+		return []*htmlNode{body}
+	}
+	threshold := math.Max(10, scores[top]*0.2)
+	var content []*htmlNode
+	for _, sibling := range top.parent.children {
+		if sibling == top {
+			content = append(content, sibling)
+			continue
+		}
+		if sibling.tag == "" {
+			continue
+		}
+		score, scored := scores[sibling]
+		text := sibling.innerText()
+		if scored && score >= threshold || sibling.tag == "p" && len(text) > 80 && sibling.linkDensity() < 0.25 {
+			content = append(content, sibling)
+		}
+	}
+	return content
+}
+
+// markdown renders HTML elements as Markdown.
+type markdown struct {
+	blocks []string
+	inline strings.Builder
+}
+
+func htmlToMarkdown(nodes []*htmlNode) string {
+	md := &markdown{}
+	for _, n := range nodes {
+		md.container(n)
+	}
+	md.flush()
+	return strings.Join(md.blocks, "\n\n")
+}
+
+// flush ends the paragraph being written.
+func (md *markdown) flush() {
+	text := md.inline.String()
+	md.inline.Reset()
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	if text := strings.Trim(strings.Join(lines, "\n"), "\n"); strings.TrimSpace(text) != "" {
+		md.blocks = append(md.blocks, text)
+	}
+}
+
+// container renders the children of n, whose inline content forms paragraphs between its blocks.
+func (md *markdown) container(n *htmlNode) {
+	for _, c := range n.children {
+		if c.tag != "" && slices.Contains(htmlBlockTags, c.tag) {
+			md.flush()
+			md.block(c)
+		} else {
+			md.inlineNode(c)
+		}
+	}
+}
+
+// sub renders the children of n as separate Markdown, for nesting inside lists and quotes.
+func sub(n *htmlNode) string {
+	return htmlToMarkdown([]*htmlNode{n})
+}
+
+func (md *markdown) block(n *htmlNode) {
+	switch n.tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(n.tag[1:])
+		if text := inlineMarkdown(n); text != "" {
+			md.blocks = append(md.blocks, strings.Repeat("#", level)+" "+strings.ReplaceAll(text, "\n", " "))
+		}
+	case "hr":
+		md.blocks = append(md.blocks, "---")
+	case "pre":
+		code := n.rawText()
+		lang := ""
+		for _, el := range append([]*htmlNode{n}, n.find(func(c *htmlNode) bool { return c.tag == "code" })...) {
+			for _, class := range strings.Fields(el.attrs["class"]) {
+				if l, ok := strings.CutPrefix(class, "language-"); ok && lang == "" {
+					lang = l
+				}
+			}
+		}
+		fence := "```"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		md.blocks = append(md.blocks, fence+lang+"\n"+strings.Trim(code, "\n")+"\n"+fence)
+	case "blockquote":
+		if text := sub(n); text != "" {
+			md.blocks = append(md.blocks, prefixLines(text, "> ", "> "))
+		}
+	case "ul", "ol":
+		var items []string
+		number := 1
+		if start, err := strconv.Atoi(n.attrs["start"]); err == nil {
+			number = start
+		}
+		for _, li := range n.children {
+			if li.tag != "li" {
+				continue
+			}
+			text := sub(li)
+			if text == "" {
+				continue
+			}
+			marker := "- "
+			if n.tag == "ol" {
+				marker = strconv.Itoa(number) + ". "
+				number++
+			}
+			items = append(items, prefixLines(text, marker, strings.Repeat(" ", len(marker))))
+		}
+		if len(items) > 0 {
+			md.blocks = append(md.blocks, strings.Join(items, "\n"))
+		}
+	case "table":
+		md.table(n)
+	case "dt":
+		if text := inlineMarkdown(n); text != "" {
+			md.blocks = append(md.blocks, "**"+text+"**")
+		}
+	case "dd":
+		if text := sub(n); text != "" {
+			md.blocks = append(md.blocks, prefixLines(text, ": ", "  "))
+		}
+	default:
+		md.container(n)
+		md.flush()
+	}
+}
+
+// table renders a table as a Markdown table, its first row as the header.
+func (md *markdown) table(n *htmlNode) {
+	var rows [][]string
+	for _, tr := range n.find(func(c *htmlNode) bool { return c.tag == "tr" }) {
+		var cells []string
+		for _, cell := range tr.children {
+			if cell.tag == "td" || cell.tag == "th" {
+				text := strings.ReplaceAll(inlineMarkdown(cell), "\n", " ")
+				cells = append(cells, strings.ReplaceAll(text, "|", `\|`))
+			}
+		}
+		if len(cells) > 0 {
+			rows = append(rows, cells)
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	var b strings.Builder
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", width) + "\n")
+		}
+	}
+	md.blocks = append(md.blocks, strings.TrimSuffix(b.String(), "\n"))
+}
+
+func prefixLines(text, first, rest string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		prefix := rest
+		if i == 0 {
+			prefix = first
+		}
+		if line == "" {
+			prefix = strings.TrimRight(prefix, " ")
+		}
+		lines[i] = prefix + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// inlineMarkdown renders the inline content of n on its own.
+func inlineMarkdown(n *htmlNode) string {
+	md := &markdown{}
+	for _, c := range n.children {
+		md.inlineNode(c)
+	}
+	md.flush()
+	return strings.Join(md.blocks, " ")
+}
+
+func (md *markdown) inlineNode(n *htmlNode) {
+	// Markdown markers must hug the text they mark, so whitespace at the element's edges goes
+	// outside them.
+	raw := n.rawText()
+	lead := raw[:len(raw)-len(strings.TrimLeft(raw, " \t\r\n"))]
+	trail := raw[len(strings.TrimRight(raw, " \t\r\n")):]
+	wrap := func(open, close string) {
+		if text := inlineMarkdown(n); text != "" {
+			md.inline.WriteString(lead + open + text + close + trail)
+		}
+	}
+	switch n.tag {
+	case "":
+		md.inline.WriteString(n.text)
+	case "br":
+		md.inline.WriteString("\n")
+	case "img":
+		alt := strings.Join(strings.Fields(n.attrs["alt"]), " ")
+		if src := n.attrs["src"]; alt != "" || src != "" && !strings.HasPrefix(src, "data:") {
+			if strings.HasPrefix(src, "data:") {
+				src = ""
+			}
+			md.inline.WriteString("![" + alt + "](" + src + ")")
+		}
+	case "a":
+		href := n.attrs["href"]
+		switch text := inlineMarkdown(n); {
+		case text == "":
+		case href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:"):
+			wrap("", "")
+		default:
+			wrap("[", "]("+href+")")
+		}
+	case "strong", "b":
+		wrap("**", "**")
+	case "em", "i":
+		wrap("*", "*")
+	case "del", "s", "strike":
+		wrap("~~", "~~")
+	case "code", "kbd", "samp", "tt":
+		if text := strings.Join(strings.Fields(n.rawText()), " "); text != "" {
+			fence := "`"
+			for strings.Contains(text, fence) {
+				fence += "`"
+			}
+			md.inline.WriteString(fence + text + fence)
+		}
+	default:
+		if slices.Contains(htmlBlockTags, n.tag) {
+			// A block inside inline content, such as a div in a link, still breaks the line.
+			md.inline.WriteString("\n")
+			for _, c := range n.children {
+				md.inlineNode(c)
+			}
+			md.inline.WriteString("\n")
+			return
+		}
+		for _, c := range n.children {
+			md.inlineNode(c)
+		}
+	}
+}
+
+// rawText returns the text below n as written, for preformatted content.
+func (n *htmlNode) rawText() string {
+	if n.tag == "" {
+		return n.text
+	}
+	if n.tag == "br" {
+		return "\n"
+	}
+	var b strings.Builder
+	for _, c := range n.children {
+		b.WriteString(c.rawText())
+	}
+	return b.String()
+}
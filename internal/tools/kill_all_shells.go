@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type killedShell struct {
+	ID      string `json:"id"`
+	Command string `json:"command"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+type killAllShellsResult struct {
+	Results []killedShell `json:"results"`
+	Count   int           `json:"count"`
+}
+
+func (s *State) executeKillAllShells(ctx context.Context, descriptionFilter string) (string, error) {
+	// Snapshot the running shells that match the filter. Completed shells are left in place so
+	// their output can still be retrieved, matching kill_shell's refusal to kill finished processes.
+	s.Mu.RLock()
+	var targets []*BackgroundShell
+	for _, shell := range s.BackgroundShells {
+		select {
+		case <-shell.Done:
+			continue
+		default:
+		}
+		if descriptionFilter != "" && !strings.Contains(shell.Description, descriptionFilter) {
+			continue
+		}
+		targets = append(targets, shell)
+	}
+	s.Mu.RUnlock()
+
+	if len(targets) == 0 {
+		return "No running background shells to kill.", nil
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return shellNumber(targets[i].ID) < shellNumber(targets[j].ID)
+	})
+
+	// Cancel waiting shells first, so killing their dependencies doesn't race them into being
+	// skipped instead.
+	cancelled := make(map[*BackgroundShell]bool)
+	s.Mu.Lock()
+	for _, shell := range targets {
+		cancelled[shell] = shell.abortWaiting()
+	}
+	s.Mu.Unlock()
+
+	results := make([]killedShell, 0, len(targets))
+	var killed []*BackgroundShell
+	for _, shell := range targets {
+		result := killedShell{ID: shell.ID, Command: shell.Command, Status: "killed"}
+		if cancelled[shell] {
+			result.Status = "cancelled"
+		} else if shell.Cmd.Process != nil {
+			if err := shell.Cmd.Process.Kill(); err != nil {
+				result.Status = "error"
+				result.Error = err.Error()
+				results = append(results, result)
+				continue
+			}
+		}
+		killed = append(killed, shell)
+		results = append(results, result)
+	}
+
+	// A single delay covers every kill, giving the OS time to tear the processes down before the
+	// records are dropped, for the same reasons as in executeKillShell.
+	if len(killed) > 0 {
+		time.Sleep(100 * time.Millisecond)
+		s.Mu.Lock()
+		for _, shell := range killed {
+			delete(s.BackgroundShells, shell.ID)
+		}
+		s.Mu.Unlock()
+		for _, shell := range killed {
+			s.removeSpool(shell)
+		}
+	}
+
+	jsonBytes, err := json.MarshalIndent(killAllShellsResult{Results: results, Count: len(results)}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format kill results: %s", err)
+	}
+	return string(jsonBytes), nil
+}
+
+var KillAllShellsTool = sdk.Tool{
+	Name:        "kill_all_shells",
+	Description: "- Kills every running background bash shell in one call\n- Optionally restricts the kill to shells whose description contains a given substring\n- Shells still waiting for their dependencies or a free slot are cancelled\n- Returns a per-shell list of results (killed, cancelled, or error)\n- Use this tool to clean up after a failed or abandoned workflow instead of calling kill_shell repeatedly",
+}
+
+type KillAllShellsInput struct {
+	Description string `json:"description,omitempty" jsonschema:"Only kill shells whose description contains this substring"`
+}
+type KillAllShellsOutput struct {
+	Result string `json:"result"`
+}
+
+func KillAllShells(ctx context.Context, req *sdk.CallToolRequest, args KillAllShellsInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeKillAllShells(ctx, args.Description)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &KillAllShellsOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
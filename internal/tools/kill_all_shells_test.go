@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKillAllShells_NoShells(t *testing.T) {
+	state := NewState()
+	result, err := state.executeKillAllShells(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, "No running background shells to kill.", result)
+}
+
+func TestKillAllShells_KillsRunning(t *testing.T) {
+	state := NewState()
+
+	_, err := state.executeBashCommand(context.Background(), "sleep 10", "Watcher one", 0, true, bashOptions{})
+	require.NoError(t, err)
+	_, err = state.executeBashCommand(context.Background(), "sleep 10", "Watcher two", 0, true, bashOptions{})
+	require.NoError(t, err)
+	_, err = state.executeBashCommand(context.Background(), "echo done", "Quick task", 0, true, bashOptions{})
+	require.NoError(t, err)
+
+	state.Mu.RLock()
+	quick := state.BackgroundShells["shell_3"]
+	state.Mu.RUnlock()
+	<-quick.Done
+
+	result, err := state.executeKillAllShells(context.Background(), "")
+	require.NoError(t, err)
+
+	var parsed killAllShellsResult
+	require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+	assert.Equal(t, 2, parsed.Count)
+	for _, r := range parsed.Results {
+		assert.Equal(t, "killed", r.Status)
+	}
+
+	// Completed shells are kept so their output remains readable.
+	state.Mu.RLock()
+	defer state.Mu.RUnlock()
+	assert.Len(t, state.BackgroundShells, 1)
+	_, exists := state.BackgroundShells["shell_3"]
+	assert.True(t, exists)
+}
+
+func TestKillAllShells_DescriptionFilter(t *testing.T) {
+	state := NewState()
+
+	_, err := state.executeBashCommand(context.Background(), "sleep 10", "Dev server", 0, true, bashOptions{})
+	require.NoError(t, err)
+	_, err = state.executeBashCommand(context.Background(), "sleep 10", "Test watcher", 0, true, bashOptions{})
+	require.NoError(t, err)
+
+	defer func() {
+		_, _ = state.executeKillAllShells(context.Background(), "")
+	}()
+
+	result, err := state.executeKillAllShells(context.Background(), "watcher")
+	require.NoError(t, err)
+
+	var parsed killAllShellsResult
+	require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+	require.Equal(t, 1, parsed.Count)
+	assert.Equal(t, "shell_2", parsed.Results[0].ID)
+
+	state.Mu.RLock()
+	_, exists := state.BackgroundShells["shell_1"]
+	state.Mu.RUnlock()
+	assert.True(t, exists)
+}
@@ -21,6 +21,19 @@ func (s *State) executeKillShell(ctx context.Context, shellID string) (string, e
 		return "", fmt.Errorf("Background shell with ID '%s' not found.", shellID)
 	}
 
+	// A shell still waiting for its dependencies or a free slot has no process to kill, so it is
+	// cancelled and its record dropped in one step.
+	s.Mu.Lock()
+	wasWaiting := shell.abortWaiting()
+	if wasWaiting {
+		delete(s.BackgroundShells, shellID)
+	}
+	s.Mu.Unlock()
+	if wasWaiting {
+		s.removeSpool(shell)
+		return fmt.Sprintf("Successfully cancelled waiting shell: %s (%s)", shellID, shell.Command), nil
+	}
+
 	// Non-blocking check using select prevents attempting to kill a process that has already
 	// completed. The background goroutine closes Done when cmd.Wait() returns, so we check this
 	// first to avoid errors from killing a process that no longer exists and to provide proper
@@ -45,6 +58,7 @@ func (s *State) executeKillShell(ctx context.Context, shellID string) (string, e
 		s.Mu.Lock()
 		delete(s.BackgroundShells, shellID)
 		s.Mu.Unlock()
+		s.removeSpool(shell)
 
 		return fmt.Sprintf("Successfully killed shell: %s (%s)", shellID, shell.Command), nil
 	}
@@ -52,7 +66,7 @@ func (s *State) executeKillShell(ctx context.Context, shellID string) (string, e
 
 var KillShellTool = sdk.Tool{
 	Name:        "kill_shell",
-	Description: "- Kills a running background bash shell by its ID\n- Takes a shell_id parameter identifying the shell to kill\n- Returns a success or failure status \n- Use this tool when you need to terminate a long-running shell",
+	Description: "- Kills a running background bash shell by its ID, or cancels one still waiting for its dependencies or a free slot (shells that depend on it are then skipped)\n- Takes a shell_id parameter identifying the shell to kill\n- Returns a success or failure status \n- Use this tool when you need to terminate a long-running shell",
 }
 
 type KillShellInput struct {
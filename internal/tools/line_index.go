@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+)
+
+const (
+	// lineIndexMinSize is the file size from which Read keeps a line index. Smaller files are
+	// cheap enough to scan from the start on every call.
+	lineIndexMinSize = 1024 * 1024
+
+	// maxLineIndexes bounds how many files have a cached line index at once.
+	maxLineIndexes = 32
+)
+
+// LineIndex records where each line of a file starts, so that a read of a line range can seek
+// straight to it. It is valid only while the file keeps the modification time and size it had when
+// the index was built.
+type LineIndex struct {
+	ModTime time.Time
+	Size    int64
+	// Starts holds the byte offset of each line. Lines are counted like strings.Split(content,
+	// "\n"), so a trailing newline begins a final empty line.
+	Starts []int64
+}
+
+// lineIndexFor returns the cached line index of the open file at resolved, building and caching it
+// when there is none or the file has changed since it was built.
+func (s *State) lineIndexFor(resolved string, file vfs.File, info os.FileInfo) (*LineIndex, error) {
+	s.Mu.RLock()
+	index, ok := s.LineIndexes[resolved]
+	s.Mu.RUnlock()
+	if ok && index.ModTime.Equal(info.ModTime()) && index.Size == info.Size() {
+		return index, nil
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	starts, err := buildLineStarts(file)
+	if err != nil {
+		return nil, err
+	}
+	index = &LineIndex{ModTime: info.ModTime(), Size: info.Size(), Starts: starts}
+
+	s.Mu.Lock()
+	if _, exists := s.LineIndexes[resolved]; !exists && len(s.LineIndexes) >= maxLineIndexes {
+		// Evict an arbitrary entry; indexes are cheap to rebuild compared to holding many of them.
+		for path := range s.LineIndexes {
+			delete(s.LineIndexes, path)
+			break
+		}
+	}
+	s.LineIndexes[resolved] = index
+	s.Mu.Unlock()
+	return index, nil
+}
+
+// dropLineIndex discards the cached line index of each path, for callers that have just changed
+// the file and don't want to rely on its modification time alone.
+func (s *State) dropLineIndex(paths ...string) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	for _, path := range paths {
+		delete(s.LineIndexes, path)
+	}
+}
+
+func buildLineStarts(r io.Reader) ([]int64, error) {
+	starts := []int64{0}
+	reader := bufio.NewReaderSize(r, 64*1024)
+	buf := make([]byte, 64*1024)
+	var pos int64
+	for {
+		n, err := reader.Read(buf)
+		chunk := buf[:n]
+		for i := bytes.IndexByte(chunk, '\n'); i >= 0; i = bytes.IndexByte(chunk, '\n') {
+			pos += int64(i) + 1
+			starts = append(starts, pos)
+			chunk = chunk[i+1:]
+		}
+		pos += int64(len(chunk))
+		if err == io.EOF {
+			return starts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readIndexedLines returns the lines of a large file from offset onwards, up to limit lines (all
+// remaining lines when limit is 0), seeking to the first of them via the file's line index. Like
+// readLines, it also returns the total line count.
+func (s *State) readIndexedLines(resolved string, file vfs.File, info os.FileInfo, offset, limit int) ([]string, int, error) {
+	index, err := s.lineIndexFor(resolved, file, info)
+	if err != nil {
+		return nil, 0, err
+	}
+	totalLines := len(index.Starts)
+	if offset > totalLines {
+		return nil, totalLines, nil
+	}
+	if _, err := file.Seek(index.Starts[offset-1], io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	lines, _, err := readLines(file, 1, limit)
+	return lines, totalLines, err
+}
+
+// lineBounds returns the byte range of content covering lines first through last (1-based,
+// inclusive), including the newline that ends the last of them. Unlike LineIndex, it does not
+// count the empty remainder after a trailing newline as a line. ok is false when content has fewer
+// than last lines.
+func lineBounds(content string, first, last int) (start, end int, ok bool) {
+	pos := 0
+	for line := 1; line < first; line++ {
+		i := strings.IndexByte(content[pos:], '\n')
+		if i < 0 {
+			return 0, 0, false
+		}
+		pos += i + 1
+	}
+	start = pos
+	for line := first; line <= last; line++ {
+		if pos >= len(content) {
+			return 0, 0, false
+		}
+		i := strings.IndexByte(content[pos:], '\n')
+		if i < 0 {
+			pos = len(content)
+		} else {
+			pos += i + 1
+		}
+	}
+	return start, pos, true
+}
+
+// countContentLines counts lines the way lineBounds does.
+func countContentLines(content string) int {
+	n := strings.Count(content, "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		n++
+	}
+	return n
+}
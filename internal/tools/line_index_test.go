@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildLineStarts(t *testing.T) {
+	starts, err := buildLineStarts(strings.NewReader("ab\nc\n\nd"))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 3, 5, 6}, starts)
+
+	// A trailing newline begins an empty final line, as with strings.Split
+	starts, err = buildLineStarts(strings.NewReader("a\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 2}, starts)
+}
+
+func TestLineBounds(t *testing.T) {
+	content := "one\ntwo\nthree"
+	tests := []struct {
+		first, last int
+		want        string
+		ok          bool
+	}{
+		{1, 1, "one\n", true},
+		{2, 3, "two\nthree", true},
+		{3, 3, "three", true},
+		{3, 4, "", false},
+		{4, 4, "", false},
+	}
+	for _, tt := range tests {
+		start, end, ok := lineBounds(content, tt.first, tt.last)
+		assert.Equal(t, tt.ok, ok, "%d-%d", tt.first, tt.last)
+		if ok {
+			assert.Equal(t, tt.want, content[start:end])
+		}
+	}
+
+	_, _, ok := lineBounds("one\n", 2, 2)
+	assert.False(t, ok)
+	assert.Equal(t, 1, countContentLines("one\n"))
+	assert.Equal(t, 2, countContentLines("one\ntwo"))
+	assert.Equal(t, 0, countContentLines(""))
+}
+
+func TestRead_LineIndex(t *testing.T) {
+	var b strings.Builder
+	for i := 1; b.Len() < lineIndexMinSize; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	path := filepath.Join(t.TempDir(), "large.txt")
+	require.NoError(t, os.WriteFile(path, []byte(b.String()), 0o644))
+	state := NewState()
+
+	result, err := state.executeRead(context.Background(), path, 90000, 2)
+	require.NoError(t, err)
+	assert.Contains(t, result, "90000→line 90000")
+	assert.Contains(t, result, "90001→line 90001")
+	assert.NotContains(t, result, "line 90002")
+
+	state.Mu.RLock()
+	index := state.LineIndexes[path]
+	state.Mu.RUnlock()
+	require.NotNil(t, index)
+	assert.Len(t, index.Starts, strings.Count(b.String(), "\n")+1)
+
+	t.Run("cached index is reused", func(t *testing.T) {
+		_, err := state.executeRead(context.Background(), path, 5, 1)
+		require.NoError(t, err)
+		state.Mu.RLock()
+		assert.Same(t, index, state.LineIndexes[path])
+		state.Mu.RUnlock()
+	})
+
+	t.Run("rebuilt after the file changes", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("new first\n"+b.String()), 0o644))
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(path, later, later))
+
+		result, err := state.executeRead(context.Background(), path, 90000, 1)
+		require.NoError(t, err)
+		assert.Contains(t, result, "90000→line 89999")
+	})
+
+	t.Run("offset past the end", func(t *testing.T) {
+		result, err := state.executeRead(context.Background(), path, 10000000, 1)
+		require.NoError(t, err)
+		assert.Contains(t, result, "shorter than the provided offset")
+	})
+}
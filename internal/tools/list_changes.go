@@ -0,0 +1,198 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type changeSummary struct {
+	Path      string   `json:"path"`
+	Operation string   `json:"operation"`
+	Directory bool     `json:"directory,omitempty"`
+	Tools     []string `json:"tools"`
+	MovedFrom string   `json:"moved_from,omitempty"`
+	FirstTime string   `json:"first_changed"`
+	LastTime  string   `json:"last_changed"`
+	Additions int      `json:"additions"`
+	Deletions int      `json:"deletions"`
+	Binary    bool     `json:"binary,omitempty"`
+	Diff      string   `json:"diff,omitempty"`
+	// Note explains a missing diff.
+	Note string `json:"note,omitempty"`
+}
+
+type listChangesResult struct {
+	Changes  []changeSummary `json:"changes"`
+	Created  int             `json:"created"`
+	Modified int             `json:"modified"`
+	Deleted  int             `json:"deleted"`
+}
+
+func (s *State) executeListChanges(ctx context.Context, session, path string, summaryOnly bool) (string, error) {
+	if path != "" {
+		resolved, err := s.resolvePath(path)
+		if err != nil {
+			return "", err
+		}
+		path = resolved
+	}
+
+	s.Mu.RLock()
+	var entries []FileChange
+	for _, c := range s.Changes[session] {
+		if path == "" || isWithin(s.FS, path, c.Path) {
+			entries = append(entries, *c)
+		}
+	}
+	s.Mu.RUnlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	result := listChangesResult{Changes: []changeSummary{}}
+	fsys := s.filesystem()
+	for _, c := range entries {
+		summary, changed := describeChange(fsys, c, summaryOnly)
+		if !changed {
+			continue
+		}
+		switch summary.Operation {
+		case "created":
+			result.Created++
+		case "modified":
+			result.Modified++
+		case "deleted":
+			result.Deleted++
+		}
+		result.Changes = append(result.Changes, summary)
+	}
+	if len(result.Changes) == 0 {
+		return "No files have been changed in this session.", nil
+	}
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format changes: %s", err)
+	}
+	output := string(jsonBytes)
+	if err := checkOutputSize(ctx, output, "list_changes"); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// describeChange compares a manifest entry with what is on fsys now. Paths that are back in their
+// original state, including files created and then deleted again, report no change.
+func describeChange(fsys vfs.FS, c FileChange, summaryOnly bool) (changeSummary, bool) {
+	summary := changeSummary{
+		Path:      c.Path,
+		Directory: c.Dir,
+		Tools:     c.Tools,
+		MovedFrom: c.MovedFrom,
+		FirstTime: c.First.Format(time.RFC3339),
+		LastTime:  c.Last.Format(time.RFC3339),
+	}
+	info, err := fsys.Lstat(c.Path)
+	exists := err == nil
+	switch {
+	case !c.Existed && !exists:
+		return summary, false
+	case c.Existed && !exists:
+		summary.Operation = "deleted"
+	case !c.Existed:
+		summary.Operation, summary.Directory = "created", info.IsDir()
+	case c.Dir || info.IsDir():
+		// A directory that still exists has not changed as an entry; its files are listed
+		// separately.
+		if c.Dir && info.IsDir() {
+			return summary, false
+		}
+		summary.Operation, summary.Note = "modified", "replaced by a different kind of file"
+		return summary, true
+	default:
+		summary.Operation = "modified"
+	}
+	if summary.Directory {
+		return summary, true
+	}
+
+	var before, after []byte
+	if exists {
+		if info.Size() > maxChangeSnapshotBytes {
+			summary.Note = "too large to diff"
+			return summary, true
+		}
+		if after, err = fsys.ReadFile(c.Path); err != nil {
+			summary.Note = "cannot be read: " + err.Error()
+			return summary, true
+		}
+	}
+	if c.Existed {
+		if c.Unknown {
+			summary.Note = "too large to diff"
+			return summary, true
+		}
+		before = c.Original
+	}
+	if summary.Operation == "modified" && bytes.Equal(before, after) {
+		return summary, false
+	}
+	if bytes.IndexByte(before, 0) >= 0 || bytes.IndexByte(after, 0) >= 0 {
+		summary.Binary = true
+		return summary, true
+	}
+
+	hunks := diffHunks(diffLines(splitDiffLines(before), splitDiffLines(after)), 3)
+	for _, h := range hunks {
+		for _, line := range h.Lines {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				summary.Additions++
+			case strings.HasPrefix(line, "-"):
+				summary.Deletions++
+			}
+		}
+	}
+	if !summaryOnly {
+		oldName, newName := c.Path, c.Path
+		if summary.Operation == "created" {
+			oldName = "/dev/null"
+		} else if summary.Operation == "deleted" {
+			newName = "/dev/null"
+		}
+		summary.Diff = renderUnified(oldName, newName, hunks)
+	}
+	return summary, true
+}
+
+var ListChangesTool = sdk.Tool{
+	Name:        "list_changes",
+	Description: "Lists every file this session created, modified, or deleted, with the net diff of each against its state before the session first changed it, for an accurate \"files changed\" summary.\n\nUsage:\n- Changes made through write, edit, write_many, render_template, format_file, config_edit, rename_symbol, delete_file, move_file, copy_file, create_directory, restore_backup, restore_snapshot, undo_edit, and archive extraction are tracked. Changes made by bash commands are not.\n- Files that end up back in their original state are left out, as are files created and then deleted again.\n- Each entry has the path, operation (created, modified, or deleted), the tools that changed it, moved_from for files put in place by move_file, line counts added and deleted, and a unified diff.\n- Set summary_only to true to leave out the diffs. Pass path (absolute) to only list changes to a file or beneath a directory.\n- Files over 1MB and binary files are listed without a diff.",
+}
+
+type ListChangesInput struct {
+	Path        string `json:"path,omitempty" jsonschema:"Only list changes to this absolute file path, or beneath this directory"`
+	SummaryOnly bool   `json:"summary_only,omitempty" jsonschema:"Leave out the diffs, listing only paths, operations, and line counts"`
+}
+type ListChangesOutput struct {
+	Result string `json:"result"`
+}
+
+func ListChanges(ctx context.Context, req *sdk.CallToolRequest, args ListChangesInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeListChanges(ctx, sessionID(req), args.Path, args.SummaryOnly)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &ListChangesOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListChanges(t *testing.T) {
+	state := NewState()
+	ctx := context.Background()
+	dir := t.TempDir()
+	list := func(path string, summaryOnly bool) listChangesResult {
+		t.Helper()
+		result, err := state.executeListChanges(ctx, defaultSessionID, path, summaryOnly)
+		require.NoError(t, err)
+		var decoded listChangesResult
+		require.NoError(t, json.Unmarshal([]byte(result), &decoded), result)
+		return decoded
+	}
+
+	result, err := state.executeListChanges(ctx, defaultSessionID, "", false)
+	require.NoError(t, err)
+	assert.Equal(t, "No files have been changed in this session.", result)
+
+	edited := filepath.Join(dir, "edited.txt")
+	require.NoError(t, os.WriteFile(edited, []byte("one\ntwo\n"), 0o644))
+	_, err = state.executeRead(ctx, edited, 0, 0)
+	require.NoError(t, err)
+	_, err = state.executeEdit(ctx, edited, editItem{OldString: "two", NewString: "2"}, false, false)
+	require.NoError(t, err)
+	_, err = state.executeEdit(ctx, edited, editItem{OldString: "one", NewString: "1"}, false, false)
+	require.NoError(t, err)
+
+	created := filepath.Join(dir, "sub", "created.txt")
+	_, err = callWrite(t, state, WriteInput{FilePath: created, Content: "new\n"})
+	require.NoError(t, err)
+
+	deleted := filepath.Join(dir, "deleted.txt")
+	require.NoError(t, os.WriteFile(deleted, []byte("bye\n"), 0o644))
+	_, err = state.executeDeleteFile(ctx, deleted, false, false)
+	require.NoError(t, err)
+
+	moved := filepath.Join(dir, "old-name.txt")
+	require.NoError(t, os.WriteFile(moved, []byte("moving\n"), 0o644))
+	_, err = state.executeMoveFile(ctx, moved, filepath.Join(dir, "new-name.txt"))
+	require.NoError(t, err)
+
+	// Changes that cancel out are left out.
+	temp := filepath.Join(dir, "temp.txt")
+	_, err = callWrite(t, state, WriteInput{FilePath: temp, Content: "scratch"})
+	require.NoError(t, err)
+	_, err = state.executeDeleteFile(ctx, temp, false, false)
+	require.NoError(t, err)
+	reverted := filepath.Join(dir, "reverted.txt")
+	require.NoError(t, os.WriteFile(reverted, []byte("same\n"), 0o644))
+	_, err = state.executeRead(ctx, reverted, 0, 0)
+	require.NoError(t, err)
+	_, err = state.executeEdit(ctx, reverted, editItem{OldString: "same", NewString: "different"}, false, false)
+	require.NoError(t, err)
+	_, err = state.executeEdit(ctx, reverted, editItem{OldString: "different", NewString: "same"}, false, false)
+	require.NoError(t, err)
+
+	changes := list("", false)
+	byPath := map[string]changeSummary{}
+	for _, c := range changes.Changes {
+		byPath[c.Path] = c
+	}
+	require.Len(t, byPath, 5, changes)
+	assert.Equal(t, 1, changes.Modified)
+	assert.Equal(t, 2, changes.Created)
+	assert.Equal(t, 2, changes.Deleted)
+
+	e := byPath[edited]
+	assert.Equal(t, "modified", e.Operation)
+	assert.Equal(t, []string{"edit"}, e.Tools)
+	assert.Equal(t, 2, e.Additions)
+	assert.Equal(t, 2, e.Deletions)
+	assert.Contains(t, e.Diff, "-one\n-two\n+1\n+2\n")
+
+	c := byPath[created]
+	assert.Equal(t, "created", c.Operation)
+	assert.Contains(t, c.Diff, "--- /dev/null\n")
+	assert.Equal(t, "deleted", byPath[deleted].Operation)
+	assert.Contains(t, byPath[deleted].Diff, "-bye\n")
+	assert.Equal(t, "deleted", byPath[moved].Operation)
+	assert.Equal(t, moved, byPath[filepath.Join(dir, "new-name.txt")].MovedFrom)
+
+	t.Run("summary only and path filter", func(t *testing.T) {
+		changes := list(filepath.Join(dir, "sub"), true)
+		require.Len(t, changes.Changes, 1)
+		assert.Equal(t, created, changes.Changes[0].Path)
+		assert.Empty(t, changes.Changes[0].Diff)
+		assert.Equal(t, 1, changes.Changes[0].Additions)
+	})
+
+	t.Run("sessions are separate", func(t *testing.T) {
+		result, err := state.executeListChanges(ctx, "other-session", "", false)
+		require.NoError(t, err)
+		assert.Contains(t, result, "No files have been changed")
+	})
+}
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type editSummary struct {
+	ID          int    `json:"id"`
+	Tool        string `json:"tool"`
+	Path        string `json:"path"`
+	Time        string `json:"time"`
+	Created     bool   `json:"created,omitempty"`
+	LinesBefore int    `json:"lines_before"`
+	LinesAfter  int    `json:"lines_after"`
+	BytesBefore int    `json:"bytes_before"`
+	BytesAfter  int    `json:"bytes_after"`
+}
+
+type listEditsResult struct {
+	Edits []editSummary `json:"edits"`
+	Count int           `json:"count"`
+}
+
+func (s *State) executeListEdits(ctx context.Context, session, path string) (string, error) {
+	if path != "" {
+		resolved, err := s.resolvePath(path)
+		if err != nil {
+			return "", err
+		}
+		path = resolved
+	}
+
+	s.Mu.RLock()
+	history := s.EditHistory[session]
+	result := listEditsResult{Edits: []editSummary{}}
+	// Newest first, matching the order in which edits would be undone.
+	for i := len(history) - 1; i >= 0; i-- {
+		r := history[i]
+		if path != "" && r.Path != path {
+			continue
+		}
+		result.Edits = append(result.Edits, editSummary{
+			ID:          r.ID,
+			Tool:        r.Tool,
+			Path:        r.Path,
+			Time:        r.Time.Format(time.RFC3339),
+			Created:     !r.Existed,
+			LinesBefore: countLines(r.OldContent),
+			LinesAfter:  countLines(r.NewContent),
+			BytesBefore: len(r.OldContent),
+			BytesAfter:  len(r.NewContent),
+		})
+	}
+	s.Mu.RUnlock()
+
+	if len(result.Edits) == 0 {
+		return "No edits recorded in this session.", nil
+	}
+	result.Count = len(result.Edits)
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format edit history: %s", err)
+	}
+	return string(jsonBytes), nil
+}
+
+func countLines(content []byte) int {
+	if len(content) == 0 {
+		return 0
+	}
+	n := bytes.Count(content, []byte("\n"))
+	if content[len(content)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+var ListEditsTool = sdk.Tool{
+	Name:        "list_edits",
+	Description: "Lists the file changes made by the write and edit tools in this session, newest first.\n\nUsage:\n- Each entry has an id to pass to undo_edit, the tool and path, when it happened, and the size before and after.\n- created is true when the write created a new file; undoing it deletes the file.\n- Pass path (absolute) to only list changes to one file.\n- Only recent changes are kept; the oldest are dropped once the history grows large.",
+}
+
+type ListEditsInput struct {
+	Path string `json:"path,omitempty" jsonschema:"Only list edits to this absolute file path"`
+}
+type ListEditsOutput struct {
+	Result string `json:"result"`
+}
+
+func ListEdits(ctx context.Context, req *sdk.CallToolRequest, args ListEditsInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeListEdits(ctx, sessionID(req), args.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &ListEditsOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListEdits(t *testing.T) {
+	state, path := setupFileForEdit(t, "one\ntwo\n")
+	_, err := callEdit(t, state, EditInput{FilePath: path, OldString: "two", NewString: "two\nthree"})
+	require.NoError(t, err)
+	created := filepath.Join(t.TempDir(), "new.txt")
+	_, err = callWrite(t, state, WriteInput{FilePath: created, Content: "fresh"})
+	require.NoError(t, err)
+
+	t.Run("newest first", func(t *testing.T) {
+		result, err := state.executeListEdits(context.Background(), defaultSessionID, "")
+		require.NoError(t, err)
+		var decoded listEditsResult
+		require.NoError(t, json.Unmarshal([]byte(result), &decoded))
+		require.Equal(t, 2, decoded.Count)
+		assert.Equal(t, created, decoded.Edits[0].Path)
+		assert.Equal(t, "write", decoded.Edits[0].Tool)
+		assert.True(t, decoded.Edits[0].Created)
+		assert.Equal(t, "edit", decoded.Edits[1].Tool)
+		assert.Equal(t, 2, decoded.Edits[1].LinesBefore)
+		assert.Equal(t, 3, decoded.Edits[1].LinesAfter)
+	})
+
+	t.Run("path filter", func(t *testing.T) {
+		result, err := state.executeListEdits(context.Background(), defaultSessionID, path)
+		require.NoError(t, err)
+		assert.Equal(t, 1, strings.Count(result, `"id"`))
+	})
+
+	t.Run("other session is empty", func(t *testing.T) {
+		result, err := state.executeListEdits(context.Background(), "other", "")
+		require.NoError(t, err)
+		assert.Contains(t, result, "No edits recorded")
+	})
+}
+
+func TestEditHistory_Bounded(t *testing.T) {
+	state := NewState()
+	for i := 0; i < maxEditHistory+5; i++ {
+		state.recordEdit(context.Background(), "write", "/tmp/x", true, []byte("a"), []byte("b"))
+	}
+	history := state.EditHistory[defaultSessionID]
+	assert.Len(t, history, maxEditHistory)
+	assert.Equal(t, 6, history[0].ID, "oldest records are evicted first")
+}
+
+func TestListEdits_MCPIntegration(t *testing.T) {
+	result, _, err := ListEdits(context.Background(), &sdk.CallToolRequest{}, ListEditsInput{Path: "relative"})
+	assert.Error(t, err)
+	assert.Nil(t, result)
+}
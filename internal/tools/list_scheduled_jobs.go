@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type scheduledJobInfo struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	Command     string `json:"command"`
+	Cron        string `json:"cron,omitempty"`
+	Status      string `json:"status"`
+	NextRun     string `json:"next_run,omitempty"`
+	Runs        int    `json:"runs"`
+	MaxRuns     int    `json:"max_runs,omitempty"`
+	Skipped     int    `json:"skipped,omitempty"`
+	LastShellID string `json:"last_shell_id,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+	seq         int    // Creation order for sorting (not exported)
+}
+
+type listScheduledJobsResult struct {
+	Jobs  []scheduledJobInfo `json:"jobs"`
+	Count int                `json:"count"`
+}
+
+func (s *State) executeListScheduledJobs(ctx context.Context) (string, error) {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+
+	if len(s.ScheduledJobs) == 0 {
+		return "No scheduled jobs.", nil
+	}
+
+	jobs := make([]scheduledJobInfo, 0, len(s.ScheduledJobs))
+	for _, job := range s.ScheduledJobs {
+		info := scheduledJobInfo{
+			ID:          job.ID,
+			Description: job.Description,
+			Command:     job.Command,
+			Cron:        job.Cron,
+			Status:      scheduledJobStatus(job),
+			Runs:        job.Runs,
+			MaxRuns:     job.MaxRuns,
+			Skipped:     job.Skipped,
+			LastShellID: job.LastShellID,
+			LastError:   job.LastError,
+		}
+		if !job.NextRun.IsZero() {
+			info.NextRun = job.NextRun.Format(time.RFC3339)
+		}
+		_, _ = fmt.Sscanf(job.ID, "job_%d", &info.seq)
+		jobs = append(jobs, info)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].seq < jobs[j].seq })
+
+	jsonBytes, err := json.MarshalIndent(listScheduledJobsResult{Jobs: jobs, Count: len(jobs)}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format job list: %s", err)
+	}
+	return string(jsonBytes), nil
+}
+
+var ListScheduledJobsTool = sdk.Tool{
+	Name:        "list_scheduled_jobs",
+	Description: "- Lists the jobs created with schedule_command\n- Shows each job's command, cron schedule, status (scheduled or finished), next run time, number of runs and skipped runs, and the shell ID and any start error of its latest run\n- Read a run's output with bash_output using last_shell_id",
+}
+
+type ListScheduledJobsInput struct{}
+type ListScheduledJobsOutput struct {
+	Result string `json:"result"`
+}
+
+func ListScheduledJobs(ctx context.Context, req *sdk.CallToolRequest, args ListScheduledJobsInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeListScheduledJobs(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &ListScheduledJobsOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
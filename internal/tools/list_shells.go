@@ -5,15 +5,30 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
+	"time"
 
 	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 type shellInfo struct {
-	ID          string `json:"id"`
-	Description string `json:"description"`
-	Status      string `json:"status"`
-	startTime   int64  // Unix timestamp for sorting (not exported)
+	ID          string   `json:"id"`
+	Description string   `json:"description"`
+	Label       string   `json:"label,omitempty"`
+	Status      string   `json:"status"`
+	Command     string   `json:"command"`
+	After       []string `json:"after,omitempty"`
+	SkipReason  string   `json:"skip_reason,omitempty"`
+	StartedAt   string   `json:"started_at,omitempty"`
+	RuntimeMs   int64    `json:"runtime_ms"`
+	ExitCode    *int     `json:"exit_code,omitempty"`
+	StdoutBytes int      `json:"stdout_bytes"`
+	StderrBytes int      `json:"stderr_bytes"`
+	StdoutFile  string   `json:"stdout_file,omitempty"`
+	StderrFile  string   `json:"stderr_file,omitempty"`
+	CPUTimeMs   int64    `json:"cpu_time_ms,omitempty"`
+	RSSBytes    int64    `json:"rss_bytes,omitempty"`
+	seq         int      // Creation order for sorting (not exported)
 }
 
 type listShellsResult struct {
@@ -21,7 +36,13 @@ type listShellsResult struct {
 	Count  int         `json:"count"`
 }
 
-func (s *State) executeListShells(ctx context.Context) (string, error) {
+func (s *State) executeListShells(ctx context.Context, statusFilter, substring string) (string, error) {
+	switch statusFilter {
+	case "", "waiting", "queued", "running", "completed", "failed", "skipped":
+	default:
+		return "", fmt.Errorf("Invalid status: %s. Must be one of: waiting, queued, running, completed, failed, skipped.", statusFilter)
+	}
+
 	s.Mu.RLock()
 	defer s.Mu.RUnlock()
 
@@ -32,35 +53,32 @@ func (s *State) executeListShells(ctx context.Context) (string, error) {
 	shells := make([]shellInfo, 0, len(s.BackgroundShells))
 
 	for _, shell := range s.BackgroundShells {
-		// Determine status without blocking
-		var status string
-		select {
-		case <-shell.Done:
-			if shell.ExitCode != 0 {
-				status = "failed"
-			} else {
-				status = "completed"
-			}
-		default:
-			status = "running"
-		}
+		status := shellStatus(shell)
 
-		info := shellInfo{
-			ID:          shell.ID,
-			Description: shell.Description,
-			Status:      status,
-			startTime:   shell.StartTime.Unix(),
+		if statusFilter != "" && status != statusFilter {
+			continue
+		}
+		if substring != "" && !strings.Contains(shell.Command, substring) && !strings.Contains(shell.Description, substring) && !strings.Contains(shell.Label, substring) {
+			continue
 		}
-		shells = append(shells, info)
+
+		shells = append(shells, describeShell(shell, status))
+	}
+
+	if len(shells) == 0 {
+		return "No background shells match the given filters.", nil
 	}
 
-	// Sort shells by status (running > failed > completed), then by creation time
+	// Sort shells by status (running > queued > waiting > failed > skipped > completed), then by creation time
 	sort.Slice(shells, func(i, j int) bool {
 		// Define status priority (lower number = higher priority)
 		statusPriority := map[string]int{
 			"running":   0,
-			"failed":    1,
-			"completed": 2,
+			"queued":    1,
+			"waiting":   2,
+			"failed":    3,
+			"skipped":   4,
+			"completed": 5,
 		}
 
 		priorityI := statusPriority[shells[i].Status]
@@ -72,7 +90,7 @@ func (s *State) executeListShells(ctx context.Context) (string, error) {
 		}
 
 		// Then sort by creation time (oldest first)
-		return shells[i].startTime < shells[j].startTime
+		return shells[i].seq < shells[j].seq
 	})
 
 	result := listShellsResult{
@@ -88,13 +106,62 @@ func (s *State) executeListShells(ctx context.Context) (string, error) {
 	return string(jsonBytes), nil
 }
 
+// describeShell builds the listing entry for a shell. Shells that have not started report no
+// start time, runtime, or usage. Runtime and resource usage are measured up to
+// now for running shells and up to exit for finished ones; CPU and RSS come from /proc while the
+// process runs and from its rusage once it has been reaped. Callers must hold s.Mu.
+func describeShell(shell *BackgroundShell, status string) shellInfo {
+	info := shellInfo{
+		ID:          shell.ID,
+		Description: shell.Description,
+		Label:       shell.Label,
+		Status:      status,
+		Command:     shell.Command,
+		After:       shell.After,
+		SkipReason:  shell.SkipReason,
+		StdoutBytes: shell.Stdout.Len(),
+		StderrBytes: shell.Stderr.Len(),
+		StdoutFile:  shell.StdoutFile,
+		StderrFile:  shell.StderrFile,
+		seq:         shellNumber(shell.ID),
+	}
+	switch status {
+	case "waiting", "queued", "skipped":
+		return info
+	}
+	info.StartedAt = shell.StartTime.Format(time.RFC3339)
+
+	if status == "running" {
+		info.RuntimeMs = time.Since(shell.StartTime).Milliseconds()
+		if shell.Cmd.Process != nil {
+			if cpu, rss, ok := liveProcessUsage(shell.Cmd.Process.Pid); ok {
+				info.CPUTimeMs = cpu.Milliseconds()
+				info.RSSBytes = rss
+			}
+		}
+		return info
+	}
+
+	exitCode := shell.ExitCode
+	info.ExitCode = &exitCode
+	if state := shell.Cmd.ProcessState; state != nil {
+		info.CPUTimeMs = (state.UserTime() + state.SystemTime()).Milliseconds()
+		info.RSSBytes = maxRSS(state)
+	}
+	if !shell.EndTime.IsZero() {
+		info.RuntimeMs = shell.EndTime.Sub(shell.StartTime).Milliseconds()
+	}
+	return info
+}
+
 var ListShellsTool = sdk.Tool{
 	Name:        "list_shells",
-	Description: "- Lists all background bash shells with their current status\n- Shows shell ID, description, and status (running/completed/failed)\n- Use this tool to see what background shells are active and check their status\n- Useful for tracking long-running operations before fetching their output with bash_output",
+	Description: "- Lists all background bash shells with their current status\n- Shows shell ID, description, command, label, status (waiting/queued/running/completed/failed/skipped), start time, runtime, exit code, output sizes, and CPU/memory usage\n- Shells started with `after` list the shells they depend on, and skipped shells say why they never ran\n- Optionally filter by status or by a substring of the command or description\n- Use this tool to see what background shells are active and check their status\n- Useful for tracking long-running operations before fetching their output with bash_output",
 }
 
 type ListShellsInput struct {
-	// No input parameters needed
+	Status    string `json:"status,omitempty" jsonschema:"Only list shells with this status: waiting, queued, running, completed, failed, or skipped"`
+	Substring string `json:"substring,omitempty" jsonschema:"Only list shells whose command, description, or label contains this substring"`
 }
 
 type ListShellsOutput struct {
@@ -103,7 +170,7 @@ type ListShellsOutput struct {
 
 func ListShells(ctx context.Context, req *sdk.CallToolRequest, args ListShellsInput) (*sdk.CallToolResult, any, error) {
 	server := GetState()
-	result, err := server.executeListShells(ctx)
+	result, err := server.executeListShells(ctx, args.Status, args.Substring)
 	if err != nil {
 		return nil, nil, err
 	}
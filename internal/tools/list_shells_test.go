@@ -12,7 +12,7 @@ import (
 
 func TestListShells_NoShells(t *testing.T) {
 	state := NewState()
-	result, err := state.executeListShells(context.Background())
+	result, err := state.executeListShells(context.Background(), "", "")
 	require.NoError(t, err)
 	assert.Equal(t, "No background shells are currently running.", result)
 }
@@ -21,13 +21,13 @@ func TestListShells_WithShells(t *testing.T) {
 	state := NewState()
 
 	// Start some background shells with sleep to ensure different timestamps
-	_, err := state.executeBashCommand(context.Background(), "sleep 10", "First task", 0, true)
+	_, err := state.executeBashCommand(context.Background(), "sleep 10", "First task", 0, true, bashOptions{})
 	require.NoError(t, err)
 
 	// Delay to ensure different Unix timestamps (second precision) for deterministic ordering
 	time.Sleep(1 * time.Second)
 
-	_, err = state.executeBashCommand(context.Background(), "sleep 10", "Second task", 0, true)
+	_, err = state.executeBashCommand(context.Background(), "sleep 10", "Second task", 0, true, bashOptions{})
 	require.NoError(t, err)
 
 	// Clean up background shells after test
@@ -42,7 +42,7 @@ func TestListShells_WithShells(t *testing.T) {
 	}()
 
 	// List shells
-	result, err := state.executeListShells(context.Background())
+	result, err := state.executeListShells(context.Background(), "", "")
 	require.NoError(t, err)
 
 	// Parse JSON result
@@ -69,7 +69,7 @@ func TestListShells_StatusTransitions(t *testing.T) {
 	state := NewState()
 
 	// Start a quick command that will complete
-	_, err := state.executeBashCommand(context.Background(), "echo test", "Quick task", 0, true)
+	_, err := state.executeBashCommand(context.Background(), "echo test", "Quick task", 0, true, bashOptions{})
 	require.NoError(t, err)
 
 	// Wait for completion
@@ -79,7 +79,7 @@ func TestListShells_StatusTransitions(t *testing.T) {
 	<-shell.Done
 
 	// List shells and verify status is "completed"
-	result, err := state.executeListShells(context.Background())
+	result, err := state.executeListShells(context.Background(), "", "")
 	require.NoError(t, err)
 
 	var parsed listShellsResult
@@ -94,7 +94,7 @@ func TestListShells_FailedCommand(t *testing.T) {
 	state := NewState()
 
 	// Start a command that will fail
-	_, err := state.executeBashCommand(context.Background(), "exit 1", "Failing task", 0, true)
+	_, err := state.executeBashCommand(context.Background(), "exit 1", "Failing task", 0, true, bashOptions{})
 	require.NoError(t, err)
 
 	// Wait for completion
@@ -104,7 +104,7 @@ func TestListShells_FailedCommand(t *testing.T) {
 	<-shell.Done
 
 	// List shells and verify status is "failed"
-	result, err := state.executeListShells(context.Background())
+	result, err := state.executeListShells(context.Background(), "", "")
 	require.NoError(t, err)
 
 	var parsed listShellsResult
@@ -119,7 +119,7 @@ func TestListShells_EmptyDescription(t *testing.T) {
 	state := NewState()
 
 	// Start a shell without description
-	_, err := state.executeBashCommand(context.Background(), "sleep 10", "", 0, true)
+	_, err := state.executeBashCommand(context.Background(), "sleep 10", "", 0, true, bashOptions{})
 	require.NoError(t, err)
 
 	// Clean up background shell after test
@@ -134,7 +134,7 @@ func TestListShells_EmptyDescription(t *testing.T) {
 	}()
 
 	// List shells
-	result, err := state.executeListShells(context.Background())
+	result, err := state.executeListShells(context.Background(), "", "")
 	require.NoError(t, err)
 
 	var parsed listShellsResult
@@ -145,3 +145,82 @@ func TestListShells_EmptyDescription(t *testing.T) {
 	assert.Equal(t, "", parsed.Shells[0].Description)
 	assert.Equal(t, "running", parsed.Shells[0].Status)
 }
+
+func TestListShells_Details(t *testing.T) {
+	state := NewState()
+
+	_, err := state.executeBashCommand(context.Background(), "echo hello && echo oops >&2 && exit 3", "Noisy task", 0, true, bashOptions{})
+	require.NoError(t, err)
+
+	state.Mu.RLock()
+	shell := state.BackgroundShells["shell_1"]
+	state.Mu.RUnlock()
+	<-shell.Done
+
+	result, err := state.executeListShells(context.Background(), "", "")
+	require.NoError(t, err)
+
+	var parsed listShellsResult
+	err = json.Unmarshal([]byte(result), &parsed)
+	require.NoError(t, err)
+
+	require.Len(t, parsed.Shells, 1)
+	info := parsed.Shells[0]
+	assert.Equal(t, "echo hello && echo oops >&2 && exit 3", info.Command)
+	assert.NotEmpty(t, info.StartedAt)
+	require.NotNil(t, info.ExitCode)
+	assert.Equal(t, 3, *info.ExitCode)
+	assert.Equal(t, len("hello\n"), info.StdoutBytes)
+	assert.Equal(t, len("oops\n"), info.StderrBytes)
+}
+
+func TestListShells_Filters(t *testing.T) {
+	state := NewState()
+
+	_, err := state.executeBashCommand(context.Background(), "sleep 10", "Watch assets", 0, true, bashOptions{})
+	require.NoError(t, err)
+	_, err = state.executeBashCommand(context.Background(), "echo built", "Build project", 0, true, bashOptions{})
+	require.NoError(t, err)
+
+	defer func() {
+		state.Mu.Lock()
+		for _, shell := range state.BackgroundShells {
+			if shell.Cmd != nil && shell.Cmd.Process != nil {
+				_ = shell.Cmd.Process.Kill()
+			}
+		}
+		state.Mu.Unlock()
+	}()
+
+	state.Mu.RLock()
+	shell := state.BackgroundShells["shell_2"]
+	state.Mu.RUnlock()
+	<-shell.Done
+
+	t.Run("by status", func(t *testing.T) {
+		result, err := state.executeListShells(context.Background(), "running", "")
+		require.NoError(t, err)
+		var parsed listShellsResult
+		require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+		require.Len(t, parsed.Shells, 1)
+		assert.Equal(t, "shell_1", parsed.Shells[0].ID)
+	})
+	t.Run("by substring", func(t *testing.T) {
+		result, err := state.executeListShells(context.Background(), "", "Build")
+		require.NoError(t, err)
+		var parsed listShellsResult
+		require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+		require.Len(t, parsed.Shells, 1)
+		assert.Equal(t, "shell_2", parsed.Shells[0].ID)
+	})
+	t.Run("no matches", func(t *testing.T) {
+		result, err := state.executeListShells(context.Background(), "failed", "")
+		require.NoError(t, err)
+		assert.Equal(t, "No background shells match the given filters.", result)
+	})
+	t.Run("invalid status", func(t *testing.T) {
+		_, err := state.executeListShells(context.Background(), "paused", "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid status")
+	})
+}
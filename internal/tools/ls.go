@@ -0,0 +1,198 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxLsDepth bounds recursive listings so a careless depth on a large tree cannot walk the whole
+// filesystem; deeper exploration should use the Glob tool.
+const maxLsDepth = 10
+
+type lsEntry struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Size        int64  `json:"size"`
+	ModTime     string `json:"mtime"`
+	Permissions string `json:"permissions"`
+	modTime     time.Time
+}
+
+type lsResult struct {
+	Path      string    `json:"path"`
+	Entries   []lsEntry `json:"entries"`
+	Count     int       `json:"count"`
+	Truncated bool      `json:"truncated,omitempty"`
+}
+
+func (s *State) executeLs(ctx context.Context, path string, showHidden bool, depth int, sortBy string, reverse bool) (string, error) {
+	resolved, err := s.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+
+	fsys := s.filesystem()
+	info, err := fsys.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("directory does not exist")
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("path is not a directory: %s", resolved)
+	}
+
+	if depth <= 0 {
+		depth = 1
+	}
+	if depth > maxLsDepth {
+		return "", fmt.Errorf("depth cannot exceed %d.", maxLsDepth)
+	}
+
+	var less func(a, b lsEntry) bool
+	switch sortBy {
+	case "", "name":
+		less = func(a, b lsEntry) bool { return a.Name < b.Name }
+	case "size":
+		less = func(a, b lsEntry) bool { return a.Size > b.Size }
+	case "mtime":
+		less = func(a, b lsEntry) bool { return a.modTime.After(b.modTime) }
+	default:
+		return "", fmt.Errorf("Invalid sort: %s. Must be one of: name, size, mtime.", sortBy)
+	}
+
+	s.Mu.RLock()
+	denied := s.DeniedPaths
+	s.Mu.RUnlock()
+	var entries []lsEntry
+	if err := listDir(ctx, fsys, denied, resolved, "", showHidden, depth, &entries); err != nil {
+		return "", err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if reverse {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+
+	result := lsResult{Path: resolved, Entries: entries}
+	if maxResults := limitsFromContext(ctx).maxResults; len(result.Entries) > maxResults {
+		result.Entries = result.Entries[:maxResults]
+		result.Truncated = true
+	}
+	if result.Entries == nil {
+		result.Entries = []lsEntry{}
+	}
+	result.Count = len(result.Entries)
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format directory listing: %s", err)
+	}
+	output := string(jsonBytes)
+	if err := checkOutputSize(ctx, output, "ls"); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// listDir appends the entries of dir on fsys to out, descending into subdirectories while depth allows.
+// Names are recorded relative to the listed root so recursive listings remain unambiguous.
+// Symlinked directories are reported but not followed, avoiding cycles. Entries matching the denied
+// patterns are left out.
+func listDir(ctx context.Context, fsys vfs.FS, denied []string, dir, prefix string, showHidden bool, depth int, out *[]lsEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	dirEntries, err := fsys.ReadDir(dir)
+	if err != nil {
+		if prefix != "" {
+			// Unreadable subdirectories are skipped rather than failing the whole listing.
+			return nil
+		}
+		return fmt.Errorf("Cannot read directory: %s", err)
+	}
+	for _, d := range dirEntries {
+		if !showHidden && strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		if _, isDenied := deniedBy(denied, filepath.Join(dir, d.Name())); isDenied {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			continue
+		}
+		name := d.Name()
+		if prefix != "" {
+			name = prefix + "/" + name
+		}
+		*out = append(*out, lsEntry{
+			Name:        name,
+			Type:        fileTypeName(info.Mode()),
+			Size:        info.Size(),
+			ModTime:     info.ModTime().Format(time.RFC3339),
+			Permissions: info.Mode().String(),
+			modTime:     info.ModTime(),
+		})
+		if d.IsDir() && depth > 1 {
+			if err := listDir(ctx, fsys, denied, filepath.Join(dir, d.Name()), name, showHidden, depth-1, out); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func fileTypeName(mode fs.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "dir"
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	case mode.IsRegular():
+		return "file"
+	default:
+		return "other"
+	}
+}
+
+var LsTool = sdk.Tool{
+	Name:        "ls",
+	Description: "- Lists the contents of a directory with name, type, size, modification time, and permissions for each entry\n- The path parameter must be an absolute path to a directory\n- Hidden entries (starting with '.') are omitted unless all is true\n- Use depth to list subdirectories recursively (default 1, max 10); nested names are relative to the listed directory\n- Sort by name (default), size (largest first), or mtime (newest first); set reverse to invert\n- Use this tool instead of running ls via Bash. To find files by name pattern across a tree, prefer the Glob tool",
+}
+
+type LsInput struct {
+	Path    string `json:"path" jsonschema:"The absolute path to the directory to list"`
+	All     bool   `json:"all,omitempty" jsonschema:"Include hidden entries whose names start with '.'"`
+	Depth   int    `json:"depth,omitempty" jsonschema:"How many directory levels to list. 1 (default) lists only immediate children"`
+	Sort    string `json:"sort,omitempty" jsonschema:"Sort order: 'name' (default), 'size' (largest first), or 'mtime' (newest first)"`
+	Reverse bool   `json:"reverse,omitempty" jsonschema:"Reverse the sort order"`
+}
+type LsOutput struct {
+	Result string `json:"result"`
+}
+
+func Ls(ctx context.Context, req *sdk.CallToolRequest, args LsInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeLs(ctx, args.Path, args.All, args.Depth, args.Sort, args.Reverse)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &LsOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,133 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupLsTestDir(t *testing.T) (state *State, dir string) {
+	t.Helper()
+	dir = t.TempDir()
+	files := map[string]string{
+		"small.txt":         "a",
+		"large.txt":         strings.Repeat("a", 8192),
+		".hidden":           "secret",
+		"subdir/nested.go":  "package sub",
+		"subdir/deep/x.txt": "x",
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0o755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0o644))
+	}
+	return NewState(), dir
+}
+
+func callLs(t *testing.T, state *State, input LsInput) lsResult {
+	t.Helper()
+	result, err := state.executeLs(context.Background(), input.Path, input.All, input.Depth, input.Sort, input.Reverse)
+	require.NoError(t, err)
+	var parsed lsResult
+	require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+	return parsed
+}
+
+func lsNames(result lsResult) []string {
+	names := make([]string, 0, len(result.Entries))
+	for _, e := range result.Entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestLs_BasicFunctionality(t *testing.T) {
+	state, dir := setupLsTestDir(t)
+	t.Run("lists immediate children", func(t *testing.T) {
+		result := callLs(t, state, LsInput{Path: dir})
+		assert.Equal(t, []string{"large.txt", "small.txt", "subdir"}, lsNames(result))
+		assert.Equal(t, 3, result.Count)
+	})
+	t.Run("reports metadata", func(t *testing.T) {
+		result := callLs(t, state, LsInput{Path: dir})
+		for _, e := range result.Entries {
+			switch e.Name {
+			case "large.txt":
+				assert.Equal(t, "file", e.Type)
+				assert.Equal(t, int64(8192), e.Size)
+				assert.True(t, strings.HasPrefix(e.Permissions, "-rw"), e.Permissions)
+				assert.NotEmpty(t, e.ModTime)
+			case "subdir":
+				assert.Equal(t, "dir", e.Type)
+			}
+		}
+	})
+	t.Run("includes hidden entries", func(t *testing.T) {
+		result := callLs(t, state, LsInput{Path: dir, All: true})
+		assert.Contains(t, lsNames(result), ".hidden")
+	})
+	t.Run("recursive depth", func(t *testing.T) {
+		result := callLs(t, state, LsInput{Path: dir, Depth: 2})
+		names := lsNames(result)
+		assert.Contains(t, names, "subdir/nested.go")
+		assert.Contains(t, names, "subdir/deep")
+		assert.NotContains(t, names, "subdir/deep/x.txt")
+	})
+}
+
+func TestLs_Sorting(t *testing.T) {
+	state, dir := setupLsTestDir(t)
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "large.txt"), old, old))
+	t.Run("by size", func(t *testing.T) {
+		result := callLs(t, state, LsInput{Path: dir, Sort: "size"})
+		assert.Equal(t, "large.txt", result.Entries[0].Name)
+	})
+	t.Run("by mtime", func(t *testing.T) {
+		result := callLs(t, state, LsInput{Path: dir, Sort: "mtime"})
+		assert.Equal(t, "large.txt", result.Entries[len(result.Entries)-1].Name)
+	})
+	t.Run("reverse", func(t *testing.T) {
+		result := callLs(t, state, LsInput{Path: dir, Reverse: true})
+		assert.Equal(t, []string{"subdir", "small.txt", "large.txt"}, lsNames(result))
+	})
+}
+
+func TestLs_Errors(t *testing.T) {
+	state, dir := setupLsTestDir(t)
+	t.Run("relative path", func(t *testing.T) {
+		_, err := state.executeLs(context.Background(), "relative/dir", false, 0, "", false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be absolute")
+	})
+	t.Run("missing directory", func(t *testing.T) {
+		_, err := state.executeLs(context.Background(), filepath.Join(dir, "missing"), false, 0, "", false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not exist")
+	})
+	t.Run("file instead of directory", func(t *testing.T) {
+		_, err := state.executeLs(context.Background(), filepath.Join(dir, "small.txt"), false, 0, "", false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not a directory")
+	})
+	t.Run("invalid sort", func(t *testing.T) {
+		_, err := state.executeLs(context.Background(), dir, false, 0, "color", false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid sort")
+	})
+}
+
+func TestLs_MCPIntegration(t *testing.T) {
+	_, dir := setupLsTestDir(t)
+	result, _, err := Ls(context.Background(), &sdk.CallToolRequest{}, LsInput{Path: dir})
+	require.NoError(t, err)
+	assert.NotNil(t, result)
+}
@@ -0,0 +1,778 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	// lspRequestTimeout bounds each request to a language server. It is generous because the first
+	// requests after a server starts wait for it to load the project.
+	lspRequestTimeout = 60 * time.Second
+
+	// lspIdleTimeout is how long a language server may go unused before it is shut down to free
+	// the memory it holds.
+	lspIdleTimeout = 10 * time.Minute
+
+	// maxLanguageServers bounds how many language servers may run at once.
+	maxLanguageServers = 8
+
+	// lspStderrTail is how much of a language server's most recent stderr output is kept, to explain
+	// failures.
+	lspStderrTail = 4096
+)
+
+// LanguageServerConfig is a language server command and the file extensions it serves. A file's
+// project root, where its server is started, is its nearest ancestor directory containing one of
+// RootMarkers.
+type LanguageServerConfig struct {
+	Extensions  []string
+	Command     []string
+	RootMarkers []string
+}
+
+var defaultLanguageServers = []LanguageServerConfig{
+	{Extensions: []string{".go"}, Command: []string{"gopls"}, RootMarkers: []string{"go.work", "go.mod"}},
+	{Extensions: []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs", ".mts", ".cts"}, Command: []string{"typescript-language-server", "--stdio"}, RootMarkers: []string{"tsconfig.json", "jsconfig.json", "package.json"}},
+	{Extensions: []string{".py", ".pyi"}, Command: []string{"pyright-langserver", "--stdio"}, RootMarkers: []string{"pyproject.toml", "pyrightconfig.json", "setup.py", "setup.cfg", "requirements.txt"}},
+	{Extensions: []string{".rs"}, Command: []string{"rust-analyzer"}, RootMarkers: []string{"Cargo.toml"}},
+	{Extensions: []string{".c", ".h", ".cc", ".cpp", ".cxx", ".hpp", ".hh"}, Command: []string{"clangd"}, RootMarkers: []string{"compile_commands.json", "compile_flags.txt", ".clangd"}},
+}
+
+// lspLanguageIDs maps file extensions to LSP language identifiers where they differ from the
+// extension itself.
+var lspLanguageIDs = map[string]string{
+	".py": "python", ".pyi": "python", ".ts": "typescript", ".mts": "typescript", ".cts": "typescript",
+	".tsx": "typescriptreact", ".js": "javascript", ".mjs": "javascript", ".cjs": "javascript",
+	".jsx": "javascriptreact", ".rs": "rust", ".h": "c", ".cc": "cpp", ".cxx": "cpp", ".hpp": "cpp",
+	".hh": "cpp",
+}
+
+// SetLanguageServers configures language servers from specs of the form "ext[,ext...]=command
+// [args...]" (e.g. ".py=pylsp"). They take precedence over the built-in servers for their
+// extensions and find project roots by the same markers.
+func (s *State) SetLanguageServers(specs []string) error {
+	var configs []LanguageServerConfig
+	for _, spec := range specs {
+		extensions, command, ok := parseExtensionCommand(spec)
+		if !ok {
+			return fmt.Errorf("Invalid language server: %s. Must be ext[,ext...]=command [args...], e.g. .py=pylsp", spec)
+		}
+		config := LanguageServerConfig{Extensions: extensions, Command: command}
+		for _, ext := range extensions {
+			for _, builtin := range defaultLanguageServers {
+				if slices.Contains(builtin.Extensions, ext) {
+					config.RootMarkers = append(config.RootMarkers, builtin.RootMarkers...)
+				}
+			}
+		}
+		configs = append(configs, config)
+	}
+	s.Mu.Lock()
+	s.LanguageServers = append(configs, defaultLanguageServers...)
+	s.Mu.Unlock()
+	return nil
+}
+
+// lspClient is a running language server, started for one project root and shared by every call
+// on files beneath it.
+type lspClient struct {
+	name   string
+	root   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *tailWriter
+	exited chan struct{}
+	// idle shuts the server down once it has gone unused for lspIdleTimeout. It is guarded by
+	// State.lspMu.
+	idle *time.Timer
+
+	// encoding is the position encoding agreed with the server, "utf-8" or "utf-16". LSP columns
+	// count code units of this encoding.
+	encoding string
+
+	// writeMu keeps concurrent messages from interleaving on stdin.
+	writeMu sync.Mutex
+
+	// mu guards nextID and pending, the response channels of requests awaiting an answer.
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan lspMessage
+
+	// docMu guards docs, the files opened on the server with the version last sent. It is separate
+	// from mu so that sending a document never holds up delivery of responses.
+	docMu sync.Mutex
+	docs  map[string]lspDocument
+}
+
+type lspDocument struct {
+	version int
+	modTime time.Time
+	size    int64
+}
+
+type lspMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *lspError       `json:"error,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspRawLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextDocumentPosition struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lspPosition `json:"position"`
+}
+
+// tailWriter keeps the last lspStderrTail bytes written to it.
+type tailWriter struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > lspStderrTail {
+		w.buf = w.buf[len(w.buf)-lspStderrTail:]
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.TrimSpace(string(w.buf))
+}
+
+// languageServerFor returns the language server for the file at path, starting it if needed.
+func (s *State) languageServerFor(ctx context.Context, path string) (*lspClient, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	s.Mu.RLock()
+	configs := s.LanguageServers
+	s.Mu.RUnlock()
+	var config *LanguageServerConfig
+	for i := range configs {
+		if slices.Contains(configs[i].Extensions, ext) {
+			config = &configs[i]
+			break
+		}
+	}
+	if config == nil {
+		return nil, fmt.Errorf("No language server is configured for %s files. Start the server with --language-server %s=<command> to add one.", ext, ext)
+	}
+	root := projectRoot(filepath.Dir(path), config.RootMarkers)
+	key := strings.Join(config.Command, " ") + "\x00" + root
+
+	// lspMu is held while a server starts so that concurrent calls share one server per root.
+	// Servers that exited, whether idle or crashed, are forgotten here and restarted on demand.
+	s.lspMu.Lock()
+	defer s.lspMu.Unlock()
+	for k, client := range s.lspClients {
+		select {
+		case <-client.exited:
+			client.idle.Stop()
+			delete(s.lspClients, k)
+		default:
+		}
+	}
+	if client, ok := s.lspClients[key]; ok {
+		client.idle.Reset(lspIdleTimeout)
+		return client, nil
+	}
+	if len(s.lspClients) >= maxLanguageServers {
+		return nil, fmt.Errorf("Too many language servers running (limit %d). They stop after %s unused.", maxLanguageServers, lspIdleTimeout)
+	}
+	client, err := startLanguageServer(ctx, config.Command, root)
+	if err != nil {
+		return nil, err
+	}
+	client.idle = time.AfterFunc(lspIdleTimeout, client.shutdown)
+	s.lspClients[key] = client
+	return client, nil
+}
+
+// ShutdownLanguageServers stops every running language server.
+func (s *State) ShutdownLanguageServers() {
+	s.lspMu.Lock()
+	clients := make([]*lspClient, 0, len(s.lspClients))
+	for key, client := range s.lspClients {
+		client.idle.Stop()
+		clients = append(clients, client)
+		delete(s.lspClients, key)
+	}
+	s.lspMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.shutdown()
+		}()
+	}
+	wg.Wait()
+}
+
+// projectRoot returns the nearest ancestor of dir containing one of markers, else the nearest
+// containing .git, else dir itself.
+func projectRoot(dir string, markers []string) string {
+	for _, candidates := range [][]string{markers, {".git"}} {
+		for d := dir; ; d = filepath.Dir(d) {
+			for _, marker := range candidates {
+				if _, err := os.Stat(filepath.Join(d, marker)); err == nil {
+					return d
+				}
+			}
+			if filepath.Dir(d) == d {
+				break
+			}
+		}
+	}
+	return dir
+}
+
+// startLanguageServer launches command in root and completes the LSP initialize handshake.
+func startLanguageServer(ctx context.Context, command []string, root string) (*lspClient, error) {
+	name := filepath.Base(command[0])
+	if _, err := exec.LookPath(command[0]); err != nil {
+		return nil, fmt.Errorf("Language server %s is not installed or not in PATH. Install it, or configure another with --language-server.", command[0])
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = root
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to start %s: %s", name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to start %s: %s", name, err)
+	}
+	stderr := &tailWriter{}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Failed to start %s: %s", name, err)
+	}
+
+	client := &lspClient{
+		name:    name,
+		root:    root,
+		cmd:     cmd,
+		stdin:   stdin,
+		stderr:  stderr,
+		exited:  make(chan struct{}),
+		nextID:  1,
+		pending: make(map[int]chan lspMessage),
+		docs:    make(map[string]lspDocument),
+	}
+	go client.readLoop(stdout)
+
+	params := map[string]any{
+		"processId": os.Getpid(),
+		"rootUri":   pathToURI(root),
+		"rootPath":  root,
+		"workspaceFolders": []map[string]string{
+			{"uri": pathToURI(root), "name": filepath.Base(root)},
+		},
+		"clientInfo": map[string]string{"name": "claude-tools-mcp"},
+		"capabilities": map[string]any{
+			"general": map[string]any{"positionEncodings": []string{"utf-8", "utf-16"}},
+			"textDocument": map[string]any{
+				"synchronization": map[string]any{"dynamicRegistration": false},
+				"definition":      map[string]any{"linkSupport": true},
+				"references":      map[string]any{},
+				"hover":           map[string]any{"contentFormat": []string{"markdown", "plaintext"}},
+				"rename":          map[string]any{"prepareSupport": false},
+			},
+			"workspace": map[string]any{
+				"workspaceEdit":    map[string]any{"documentChanges": true},
+				"workspaceFolders": true,
+				"configuration":    true,
+			},
+		},
+	}
+	var result struct {
+		Capabilities struct {
+			PositionEncoding string `json:"positionEncoding"`
+		} `json:"capabilities"`
+	}
+	if err := client.call(ctx, "initialize", params, &result); err != nil {
+		client.kill()
+		return nil, err
+	}
+	client.encoding = "utf-16"
+	if result.Capabilities.PositionEncoding == "utf-8" {
+		client.encoding = "utf-8"
+	}
+	if err := client.notify("initialized", map[string]any{}); err != nil {
+		client.kill()
+		return nil, err
+	}
+	return client, nil
+}
+
+// readLoop dispatches the server's messages until it exits: responses go to the waiting request,
+// requests from the server are answered, and notifications such as diagnostics are ignored.
+func (c *lspClient) readLoop(stdout io.Reader) {
+	reader := bufio.NewReader(stdout)
+	for {
+		msg, err := readLSPMessage(reader)
+		if err != nil {
+			break
+		}
+		switch {
+		case msg.Method != "" && len(msg.ID) > 0:
+			go c.answerServerRequest(msg)
+		case msg.Method != "":
+		case len(msg.ID) > 0:
+			id, err := strconv.Atoi(string(msg.ID))
+			if err != nil {
+				continue
+			}
+			c.mu.Lock()
+			ch, ok := c.pending[id]
+			delete(c.pending, id)
+			c.mu.Unlock()
+			if ok {
+				ch <- msg
+			}
+		}
+	}
+	_ = c.cmd.Wait()
+	close(c.exited)
+}
+
+// readLSPMessage reads one message framed by a Content-Length header.
+func readLSPMessage(reader *bufio.Reader) (lspMessage, error) {
+	header, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if err != nil {
+		return lspMessage{}, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return lspMessage{}, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return lspMessage{}, err
+	}
+	var msg lspMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return lspMessage{}, err
+	}
+	return msg, nil
+}
+
+// answerServerRequest gives minimal answers to the requests servers send their clients. Servers
+// ask for settings and to register capabilities; both are answered with defaults. Edits the
+// server wants applied on its own initiative are declined.
+func (c *lspClient) answerServerRequest(msg lspMessage) {
+	reply := lspMessage{JSONRPC: "2.0", ID: msg.ID}
+	switch msg.Method {
+	case "workspace/configuration":
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		_ = json.Unmarshal(msg.Params, &params)
+		reply.Result, _ = json.Marshal(make([]any, len(params.Items)))
+	case "workspace/applyEdit":
+		reply.Result = json.RawMessage(`{"applied":false}`)
+	case "client/registerCapability", "client/unregisterCapability", "window/workDoneProgress/create", "window/showMessageRequest":
+		reply.Result = json.RawMessage("null")
+	case "workspace/workspaceFolders":
+		reply.Result, _ = json.Marshal([]map[string]string{{"uri": pathToURI(c.root), "name": filepath.Base(c.root)}})
+	default:
+		reply.Error = &lspError{Code: -32601, Message: "method not supported: " + msg.Method}
+	}
+	_ = c.write(reply)
+}
+
+func (c *lspClient) write(msg any) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.stdin, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		return fmt.Errorf("Cannot write to language server %s: %s", c.name, err)
+	}
+	return nil
+}
+
+func (c *lspClient) notify(method string, params any) error {
+	return c.write(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// call sends a request and decodes its result into result, which may be nil.
+func (c *lspClient) call(ctx context.Context, method string, params, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, lspRequestTimeout)
+	defer cancel()
+	c.mu.Lock()
+	id := c.nextID
+	c.nextID++
+	ch := make(chan lspMessage, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params}); err != nil {
+		return err
+	}
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return fmt.Errorf("%s %s failed: %s", c.name, method, resp.Error.Message)
+		}
+		if result != nil && len(resp.Result) > 0 {
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				return fmt.Errorf("%s returned an invalid %s response: %s", c.name, method, err)
+			}
+		}
+		return nil
+	case <-c.exited:
+		message := fmt.Sprintf("Language server %s exited", c.name)
+		if tail := c.stderr.String(); tail != "" {
+			message += ":\n" + tail
+		}
+		return errors.New(message)
+	case <-ctx.Done():
+		_ = c.notify("$/cancelRequest", map[string]int{"id": id})
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("Language server %s did not answer %s within %s. It may still be loading the project; try again shortly.", c.name, method, lspRequestTimeout)
+		}
+		return ctx.Err()
+	}
+}
+
+// syncDocument opens the file at path on the server, or sends its new content if it changed since
+// it was last sent, so that requests see the file as it is on disk.
+func (c *lspClient) syncDocument(path string, content []byte, info os.FileInfo) error {
+	c.docMu.Lock()
+	defer c.docMu.Unlock()
+	doc, open := c.docs[path]
+	if open && doc.modTime.Equal(info.ModTime()) && doc.size == info.Size() {
+		return nil
+	}
+	uri := pathToURI(path)
+	var err error
+	if !open {
+		ext := strings.ToLower(filepath.Ext(path))
+		languageID, ok := lspLanguageIDs[ext]
+		if !ok {
+			languageID = strings.TrimPrefix(ext, ".")
+		}
+		doc.version = 1
+		err = c.notify("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "languageId": languageID, "version": doc.version, "text": string(content)},
+		})
+	} else {
+		doc.version++
+		err = c.notify("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": uri, "version": doc.version},
+			"contentChanges": []map[string]string{{"text": string(content)}},
+		})
+	}
+	if err != nil {
+		return err
+	}
+	doc.modTime, doc.size = info.ModTime(), info.Size()
+	c.docs[path] = doc
+	return nil
+}
+
+// shutdown asks the server to exit, killing it if it does not within a few seconds.
+func (c *lspClient) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.call(ctx, "shutdown", nil, nil); err == nil {
+		_ = c.notify("exit", nil)
+	}
+	_ = c.stdin.Close()
+	select {
+	case <-c.exited:
+	case <-time.After(5 * time.Second):
+		c.kill()
+	}
+}
+
+func (c *lspClient) kill() {
+	_ = c.stdin.Close()
+	if c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+	<-c.exited
+}
+
+// lspTarget prepares a position request: it checks the file, starts or reuses its language
+// server, syncs the file to it, and locates symbol on line (1-based), or column (1-based, in
+// characters) when symbol is empty.
+func (s *State) lspTarget(ctx context.Context, filePath string, line int, symbol string, column int) (*lspClient, lspTextDocumentPosition, error) {
+	var target lspTextDocumentPosition
+	resolved, err := s.resolvePath(filePath)
+	if err != nil {
+		return nil, target, err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return nil, target, err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return nil, target, fmt.Errorf("File does not exist.")
+	}
+	if info.IsDir() {
+		return nil, target, fmt.Errorf("path is a directory, not a file: %s", resolved)
+	}
+	if err := s.checkFileSize(ctx, info.Size(), "lsp"); err != nil {
+		return nil, target, err
+	}
+	content, err := os.ReadFile(resolved)
+	if err != nil {
+		return nil, target, fmt.Errorf("Cannot read file: %s", err)
+	}
+
+	client, err := s.languageServerFor(ctx, resolved)
+	if err != nil {
+		return nil, target, err
+	}
+	position, err := lspPositionFor(string(content), line, symbol, column, client.encoding)
+	if err != nil {
+		return nil, target, err
+	}
+	if err := client.syncDocument(resolved, content, info); err != nil {
+		return nil, target, err
+	}
+	target.TextDocument.URI = pathToURI(resolved)
+	target.Position = position
+	return client, target, nil
+}
+
+// lspPositionFor converts a 1-based line and either a symbol on that line or a 1-based character
+// column into an LSP position in the given encoding.
+func lspPositionFor(content string, line int, symbol string, column int, encoding string) (lspPosition, error) {
+	lines := strings.Split(content, "\n")
+	if line < 1 || line > len(lines) {
+		return lspPosition{}, fmt.Errorf("line %d is outside the file, which has %d lines", line, len(lines))
+	}
+	text := strings.TrimSuffix(lines[line-1], "\r")
+	var offset int
+	switch {
+	case symbol != "":
+		offset = symbolIndex(text, symbol)
+		if offset < 0 {
+			return lspPosition{}, fmt.Errorf("symbol %q not found on line %d: %s", symbol, line, strings.TrimSpace(text))
+		}
+	case column > 0:
+		offset = -1
+		for i := range text {
+			if column--; column == 0 {
+				offset = i
+				break
+			}
+		}
+		if offset < 0 {
+			return lspPosition{}, fmt.Errorf("column is beyond the end of line %d, which has %d characters", line, utf8.RuneCountInString(text))
+		}
+	default:
+		return lspPosition{}, fmt.Errorf("Provide symbol, the name to look up as it appears on the line, or column.")
+	}
+	return lspPosition{Line: line - 1, Character: encodedLength(text[:offset], encoding)}, nil
+}
+
+// symbolIndex returns the byte offset of the first whole-word occurrence of symbol in text, or of
+// its first occurrence if none stands alone, or -1.
+func symbolIndex(text, symbol string) int {
+	isWord := func(r rune) bool { return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+	for from := 0; from <= len(text); {
+		i := strings.Index(text[from:], symbol)
+		if i < 0 {
+			break
+		}
+		start, end := from+i, from+i+len(symbol)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if (start == 0 || !isWord(before)) && (end == len(text) || !isWord(after)) {
+			return start
+		}
+		from = start + 1
+	}
+	return strings.Index(text, symbol)
+}
+
+// encodedLength returns the length of s in code units of the position encoding.
+func encodedLength(s, encoding string) int {
+	if encoding == "utf-8" {
+		return len(s)
+	}
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// byteOffsetInLine converts an LSP character offset within text into a byte offset, clamped to
+// the end of the line.
+func byteOffsetInLine(text string, character int, encoding string) int {
+	if encoding == "utf-8" {
+		return min(character, len(text))
+	}
+	units := 0
+	for i, r := range text {
+		if units >= character {
+			return i
+		}
+		units += utf16.RuneLen(r)
+	}
+	return len(text)
+}
+
+// lspLocation is a source range reported to the client, with 1-based lines and character
+// columns, and the text of its first line.
+type lspLocation struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"end_line"`
+	EndColumn int    `json:"end_column"`
+	Text      string `json:"text,omitempty"`
+}
+
+// lspFileLines caches the lines of files named in a response, to convert their positions.
+type lspFileLines map[string][]string
+
+func (c lspFileLines) line(path string, line int) (string, bool) {
+	lines, ok := c[path]
+	if !ok {
+		content, err := os.ReadFile(path)
+		if err == nil {
+			lines = strings.Split(string(content), "\n")
+		}
+		c[path] = lines
+	}
+	if line < 0 || line >= len(lines) {
+		return "", false
+	}
+	return strings.TrimSuffix(lines[line], "\r"), true
+}
+
+// location converts an LSP location into one for the client.
+func (c lspFileLines) location(uri string, r lspRange, encoding string) (lspLocation, error) {
+	path, err := uriToPath(uri)
+	if err != nil {
+		return lspLocation{}, err
+	}
+	column := func(line, character int) int {
+		text, _ := c.line(path, line)
+		return utf8.RuneCountInString(text[:byteOffsetInLine(text, character, encoding)]) + 1
+	}
+	text, _ := c.line(path, r.Start.Line)
+	return lspLocation{
+		File:      path,
+		Line:      r.Start.Line + 1,
+		Column:    column(r.Start.Line, r.Start.Character),
+		EndLine:   r.End.Line + 1,
+		EndColumn: column(r.End.Line, r.End.Character),
+		Text:      strings.TrimSpace(text),
+	}, nil
+}
+
+// locations converts a list of LSP locations into sorted client locations, dropping any under
+// denied paths.
+func (s *State) lspLocations(raw []lspRawLocation, encoding string) ([]lspLocation, error) {
+	files := lspFileLines{}
+	locations := []lspLocation{}
+	for _, l := range raw {
+		location, err := files.location(l.URI, l.Range, encoding)
+		if err != nil {
+			return nil, err
+		}
+		if s.checkPathAllowed(location.File) != nil {
+			continue
+		}
+		locations = append(locations, location)
+	}
+	sort.SliceStable(locations, func(i, j int) bool {
+		if locations[i].File != locations[j].File {
+			return locations[i].File < locations[j].File
+		}
+		if locations[i].Line != locations[j].Line {
+			return locations[i].Line < locations[j].Line
+		}
+		return locations[i].Column < locations[j].Column
+	})
+	return locations, nil
+}
+
+func pathToURI(path string) string {
+	slashed := filepath.ToSlash(path)
+	if !strings.HasPrefix(slashed, "/") {
+		// Windows paths such as C:/src gain the leading slash file URIs require.
+		slashed = "/" + slashed
+	}
+	return (&url.URL{Scheme: "file", Path: slashed}).String()
+}
+
+func uriToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", fmt.Errorf("language server returned an unsupported location: %s", uri)
+	}
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path), nil
+}
@@ -0,0 +1,362 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLSPHelperProcess is not a real test: run with CLAUDE_TOOLS_FAKE_LSP=1, the test binary acts
+// as the fake language server the LSP tool tests talk to.
+func TestLSPHelperProcess(t *testing.T) {
+	if os.Getenv("CLAUDE_TOOLS_FAKE_LSP") != "1" {
+		return
+	}
+	runFakeLanguageServer(os.Stdin, os.Stdout)
+	os.Exit(0)
+}
+
+// runFakeLanguageServer serves a toy language in .fake files, in which "func name" declares name
+// and every other whole-word occurrence of name refers to it. Positions are in UTF-16.
+func runFakeLanguageServer(in io.Reader, out io.Writer) {
+	reader := bufio.NewReader(in)
+	send := func(msg any) {
+		body, _ := json.Marshal(msg)
+		fmt.Fprintf(out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	var root string
+	docs := map[string]string{}
+	for {
+		msg, err := readLSPMessage(reader)
+		if err != nil {
+			return
+		}
+		if msg.Method == "" {
+			continue
+		}
+		var params struct {
+			RootURI      string `json:"rootUri"`
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+			Position lspPosition `json:"position"`
+			NewName  string      `json:"newName"`
+			Context  struct {
+				IncludeDeclaration bool `json:"includeDeclaration"`
+			} `json:"context"`
+		}
+		_ = json.Unmarshal(msg.Params, &params)
+		uri := params.TextDocument.URI
+
+		var result any
+		switch msg.Method {
+		case "initialize":
+			root, _ = uriToPath(params.RootURI)
+			result = map[string]any{"capabilities": map[string]any{"positionEncoding": "utf-16"}}
+		case "initialized":
+			// Real servers ask for their settings once initialized.
+			send(map[string]any{"jsonrpc": "2.0", "id": "config", "method": "workspace/configuration", "params": map[string]any{"items": []any{map[string]any{}}}})
+			continue
+		case "textDocument/didOpen":
+			docs[uri] = params.TextDocument.Text
+			continue
+		case "textDocument/didChange":
+			docs[uri] = params.ContentChanges[0].Text
+			continue
+		case "exit":
+			return
+		case "textDocument/definition":
+			word := fakeWordAt(docs[uri], params.Position)
+			var links []map[string]any
+			for _, o := range fakeOccurrences(root, docs, word) {
+				if o.declaration {
+					links = append(links, map[string]any{"targetUri": o.URI, "targetRange": o.lineRange, "targetSelectionRange": o.Range})
+				}
+			}
+			result = links
+		case "textDocument/references":
+			word := fakeWordAt(docs[uri], params.Position)
+			locations := []lspRawLocation{}
+			for _, o := range fakeOccurrences(root, docs, word) {
+				if !o.declaration || params.Context.IncludeDeclaration {
+					locations = append(locations, o.lspRawLocation)
+				}
+			}
+			result = locations
+		case "textDocument/hover":
+			if word := fakeWordAt(docs[uri], params.Position); word != "" {
+				result = map[string]any{"contents": map[string]string{"kind": "markdown", "value": "```fake\nfunc " + word + "\n```"}}
+			}
+		case "textDocument/rename":
+			if params.NewName == "create" {
+				result = map[string]any{"documentChanges": []any{map[string]string{"kind": "create", "uri": uri}}}
+				break
+			}
+			edits := map[string][]lspTextEdit{}
+			for _, o := range fakeOccurrences(root, docs, fakeWordAt(docs[uri], params.Position)) {
+				edits[o.URI] = append(edits[o.URI], lspTextEdit{Range: o.Range, NewText: params.NewName})
+			}
+			var changes []any
+			for uri, e := range edits {
+				changes = append(changes, map[string]any{"textDocument": map[string]any{"uri": uri, "version": nil}, "edits": e})
+			}
+			result = map[string]any{"documentChanges": changes}
+		}
+		send(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+	}
+}
+
+type fakeOccurrence struct {
+	lspRawLocation
+	declaration bool
+	lineRange   lspRange
+}
+
+func fakeWordAt(text string, pos lspPosition) string {
+	lines := strings.Split(text, "\n")
+	if pos.Line >= len(lines) {
+		return ""
+	}
+	line := lines[pos.Line]
+	at := byteOffsetInLine(line, pos.Character, "utf-16")
+	for _, loc := range regexp.MustCompile(`\w+`).FindAllStringIndex(line, -1) {
+		if loc[0] <= at && at < loc[1] {
+			return line[loc[0]:loc[1]]
+		}
+	}
+	return ""
+}
+
+func fakeOccurrences(root string, docs map[string]string, word string) []fakeOccurrence {
+	if word == "" {
+		return nil
+	}
+	pattern := regexp.MustCompile(`\b` + regexp.QuoteMeta(word) + `\b`)
+	var occurrences []fakeOccurrence
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".fake" {
+			return nil
+		}
+		uri := pathToURI(path)
+		text, open := docs[uri]
+		if !open {
+			content, _ := os.ReadFile(path)
+			text = string(content)
+		}
+		for i, line := range strings.Split(text, "\n") {
+			for _, loc := range pattern.FindAllStringIndex(line, -1) {
+				occurrences = append(occurrences, fakeOccurrence{
+					lspRawLocation: lspRawLocation{URI: uri, Range: lspRange{
+						Start: lspPosition{Line: i, Character: encodedLength(line[:loc[0]], "utf-16")},
+						End:   lspPosition{Line: i, Character: encodedLength(line[:loc[1]], "utf-16")},
+					}},
+					declaration: strings.HasPrefix(line[:loc[0]], "func "),
+					lineRange:   lspRange{Start: lspPosition{Line: i}, End: lspPosition{Line: i, Character: encodedLength(line, "utf-16")}},
+				})
+			}
+		}
+		return nil
+	})
+	return occurrences
+}
+
+// newFakeLSPState returns a State serving .fake files with the fake language server, and a
+// project containing a.fake, which declares greet, and b.fake, which calls it.
+func newFakeLSPState(t *testing.T) (*State, string) {
+	t.Helper()
+	t.Setenv("CLAUDE_TOOLS_FAKE_LSP", "1")
+	state := NewState()
+	state.LanguageServers = []LanguageServerConfig{{
+		Extensions:  []string{".fake"},
+		Command:     []string{os.Args[0], "-test.run=^TestLSPHelperProcess$"},
+		RootMarkers: []string{"fake.mod"},
+	}}
+	t.Cleanup(state.ShutdownLanguageServers)
+
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "fake.mod"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.fake"), []byte("func greet\ncall greet\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "sub", "b.fake"), []byte("say \"héllo\"; call greet\n"), 0o644))
+	return state, root
+}
+
+func TestLSPPositionFor(t *testing.T) {
+	content := "x := \"héllo\" + 𝔊reet(greeter, greet)\n"
+
+	pos, err := lspPositionFor(content, 1, "greet", 0, "utf-16")
+	require.NoError(t, err)
+	// The whole word is preferred over the earlier "greet" inside "greeter"; é is one UTF-16 unit
+	// and 𝔊 two.
+	assert.Equal(t, lspPosition{Line: 0, Character: 31}, pos)
+
+	pos, err = lspPositionFor(content, 1, "greet", 0, "utf-8")
+	require.NoError(t, err)
+	assert.Equal(t, lspPosition{Line: 0, Character: 34}, pos)
+
+	pos, err = lspPositionFor(content, 1, "", 7, "utf-16")
+	require.NoError(t, err)
+	assert.Equal(t, lspPosition{Line: 0, Character: 6}, pos)
+
+	_, err = lspPositionFor(content, 3, "greet", 0, "utf-16")
+	assert.ErrorContains(t, err, "outside the file")
+	_, err = lspPositionFor(content, 1, "missing", 0, "utf-16")
+	assert.ErrorContains(t, err, "not found on line 1")
+	_, err = lspPositionFor(content, 1, "", 0, "utf-16")
+	assert.ErrorContains(t, err, "Provide symbol")
+}
+
+func TestApplyTextEdits(t *testing.T) {
+	content := []byte("a 𝔊 b\r\nb\n")
+	edits := []lspTextEdit{
+		{Range: lspRange{Start: lspPosition{Line: 1, Character: 0}, End: lspPosition{Line: 1, Character: 1}}, NewText: "c"},
+		{Range: lspRange{Start: lspPosition{Line: 0, Character: 5}, End: lspPosition{Line: 0, Character: 6}}, NewText: "c"},
+	}
+	updated, err := applyTextEdits(content, edits, "utf-16")
+	require.NoError(t, err)
+	assert.Equal(t, "a 𝔊 c\r\nc\n", string(updated))
+
+	edits = append(edits, lspTextEdit{Range: lspRange{Start: lspPosition{Line: 0, Character: 0}, End: lspPosition{Line: 0, Character: 6}}})
+	_, err = applyTextEdits(content, edits, "utf-16")
+	assert.ErrorContains(t, err, "overlap")
+}
+
+func TestHoverText(t *testing.T) {
+	assert.Equal(t, "plain", hoverText(json.RawMessage(`"plain"`)))
+	assert.Equal(t, "**doc**", hoverText(json.RawMessage(`{"kind":"markdown","value":"**doc**"}`)))
+	assert.Equal(t, "```go\nfunc f()\n```\n\ndoc", hoverText(json.RawMessage(`[{"language":"go","value":"func f()"},"doc",""]`)))
+	assert.Empty(t, hoverText(nil))
+}
+
+func TestSetLanguageServers(t *testing.T) {
+	state := NewState()
+	require.NoError(t, state.SetLanguageServers([]string{"go,.tmpl=my-gopls serve -rpc.trace"}))
+	require.Greater(t, len(state.LanguageServers), len(defaultLanguageServers))
+	custom := state.LanguageServers[0]
+	assert.Equal(t, []string{".go", ".tmpl"}, custom.Extensions)
+	assert.Equal(t, []string{"my-gopls", "serve", "-rpc.trace"}, custom.Command)
+	assert.Contains(t, custom.RootMarkers, "go.mod")
+
+	assert.Error(t, state.SetLanguageServers([]string{"pylsp"}))
+	assert.Error(t, state.SetLanguageServers([]string{".py="}))
+}
+
+func TestLSPTools_Errors(t *testing.T) {
+	state, root := newFakeLSPState(t)
+	other := filepath.Join(root, "notes.txt")
+	require.NoError(t, os.WriteFile(other, []byte("greet\n"), 0o644))
+
+	_, err := state.executeDefinition(context.Background(), other, 1, "greet", 0)
+	assert.ErrorContains(t, err, "--language-server .txt=<command>")
+	_, err = state.executeDefinition(context.Background(), "a.fake", 1, "greet", 0)
+	assert.Error(t, err)
+
+	state.LanguageServers[0].Command = []string{"no-such-language-server"}
+	_, err = state.executeHover(context.Background(), filepath.Join(root, "a.fake"), 1, "greet", 0)
+	assert.ErrorContains(t, err, "not installed")
+}
+
+func TestLSPTools(t *testing.T) {
+	state, root := newFakeLSPState(t)
+	a := filepath.Join(root, "a.fake")
+	b := filepath.Join(root, "sub", "b.fake")
+	ctx := context.Background()
+
+	t.Run("definition", func(t *testing.T) {
+		result, err := state.executeDefinition(ctx, b, 1, "greet", 0)
+		require.NoError(t, err)
+		var parsed struct {
+			Definitions []lspLocation `json:"definitions"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+		assert.Equal(t, []lspLocation{{File: a, Line: 1, Column: 6, EndLine: 1, EndColumn: 11, Text: "func greet"}}, parsed.Definitions)
+
+		result, err = state.executeDefinition(ctx, b, 1, "call", 0)
+		require.NoError(t, err)
+		assert.Equal(t, "No definition found.", result)
+	})
+
+	t.Run("references", func(t *testing.T) {
+		result, err := state.executeReferences(ctx, a, 1, "greet", 0, false)
+		require.NoError(t, err)
+		var parsed referencesResult
+		require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+		require.Equal(t, 2, parsed.Total)
+		assert.Equal(t, lspLocation{File: a, Line: 2, Column: 6, EndLine: 2, EndColumn: 11, Text: "call greet"}, parsed.References[0])
+		// Columns count characters, so é counts once although it is two bytes.
+		assert.Equal(t, lspLocation{File: b, Line: 1, Column: 19, EndLine: 1, EndColumn: 24, Text: "say \"héllo\"; call greet"}, parsed.References[1])
+
+		result, err = state.executeReferences(ctx, a, 1, "greet", 0, true)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+		assert.Equal(t, 3, parsed.Total)
+	})
+
+	t.Run("denied paths are hidden", func(t *testing.T) {
+		require.NoError(t, state.SetDeniedPaths([]string{filepath.Join(root, "sub")}))
+		defer func() { require.NoError(t, state.SetDeniedPaths(nil)) }()
+		result, err := state.executeReferences(ctx, a, 1, "greet", 0, false)
+		require.NoError(t, err)
+		assert.NotContains(t, result, "b.fake")
+	})
+
+	t.Run("hover", func(t *testing.T) {
+		result, err := state.executeHover(ctx, b, 1, "", 20)
+		require.NoError(t, err)
+		assert.Equal(t, "```fake\nfunc greet\n```", result)
+	})
+
+	t.Run("unsaved changes on disk are synced", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(a, []byte("func greet\ncall greet\ncall greet\n"), 0o644))
+		result, err := state.executeReferences(ctx, a, 1, "greet", 0, false)
+		require.NoError(t, err)
+		assert.Contains(t, result, `"total": 3`)
+	})
+
+	t.Run("rename dry run", func(t *testing.T) {
+		result, err := state.executeRenameSymbol(ctx, a, 1, "greet", 0, "welcome", true, false)
+		require.NoError(t, err)
+		assert.Contains(t, result, "-func greet\n-call greet\n-call greet\n+func welcome\n")
+		assert.Contains(t, result, "+say \"héllo\"; call welcome\n")
+		content, err := os.ReadFile(b)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "call greet")
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		result, err := state.executeRenameSymbol(ctx, a, 1, "greet", 0, "welcome", false, false)
+		require.NoError(t, err)
+		assert.Contains(t, result, "Renamed greet to welcome: 4 occurrences in 2 files")
+		content, err := os.ReadFile(a)
+		require.NoError(t, err)
+		assert.Equal(t, "func welcome\ncall welcome\ncall welcome\n", string(content))
+		content, err = os.ReadFile(b)
+		require.NoError(t, err)
+		assert.Equal(t, "say \"héllo\"; call welcome\n", string(content))
+
+		// The server sees the renamed files.
+		result, err = state.executeReferences(ctx, b, 1, "welcome", 0, true)
+		require.NoError(t, err)
+		assert.Contains(t, result, `"total": 4`)
+	})
+
+	t.Run("rename refuses file operations", func(t *testing.T) {
+		_, err := state.executeRenameSymbol(ctx, a, 1, "welcome", 0, "create", false, false)
+		assert.ErrorContains(t, err, "would create files")
+	})
+}
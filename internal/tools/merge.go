@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxMergeBaseBytes bounds the files whose read content is kept as a merge base.
+const maxMergeBaseBytes = 1024 * 1024
+
+// readContent is a file's content as last read or written by a tool, at modification time modTime.
+type readContent struct {
+	modTime time.Time
+	data    []byte
+}
+
+// rememberContent records content as what the client last saw of path, at modTime. Files over
+// maxMergeBaseBytes are not kept. The caller must hold s.Mu.
+func (s *State) rememberContent(path string, modTime time.Time, content []byte) {
+	if len(content) > maxMergeBaseBytes {
+		delete(s.ReadContents, path)
+		return
+	}
+	s.ReadContents[path] = readContent{modTime: modTime, data: content}
+}
+
+// mergeBase returns the content the client last saw of path, if it is known. Content recorded
+// before the file was last tracked at another modification time is stale and not returned.
+func (s *State) mergeBase(path string) ([]byte, bool) {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	content, ok := s.ReadContents[path]
+	readTime, tracked := s.ReadFiles[path]
+	if !ok || !tracked || !content.modTime.Equal(readTime) {
+		return nil, false
+	}
+	return content.data, true
+}
+
+// mergeLines three-way merges ours and theirs, both derived from base, line by line. Regions that
+// only one side changed take that side's lines. Regions changed by both, which includes changes to
+// adjacent lines, are conflicts unless both made the same change: conflicts holds each one rendered
+// with conflict markers, and merged has them marked the same way.
+func mergeLines(base, ours, theirs string) (merged string, conflicts []string) {
+	baseLines, ourLines, theirLines := splitLinesKeepEnds(base), splitLinesKeepEnds(ours), splitLinesKeepEnds(theirs)
+	ourMatch := matchBaseLines(diffLines(baseLines, ourLines), len(baseLines))
+	theirMatch := matchBaseLines(diffLines(baseLines, theirLines), len(baseLines))
+
+	var b strings.Builder
+	i, o, t := 0, 0, 0
+	for {
+		// Copy the lines both sides kept in place.
+		for i < len(baseLines) && ourMatch[i] == o && theirMatch[i] == t {
+			b.WriteString(baseLines[i])
+			i, o, t = i+1, o+1, t+1
+		}
+		if i == len(baseLines) && o == len(ourLines) && t == len(theirLines) {
+			break
+		}
+
+		// The changed region runs up to the next base line both sides kept.
+		next := i
+		for next < len(baseLines) && (ourMatch[next] < 0 || theirMatch[next] < 0) {
+			next++
+		}
+		ourEnd, theirEnd := len(ourLines), len(theirLines)
+		if next < len(baseLines) {
+			ourEnd, theirEnd = ourMatch[next], theirMatch[next]
+		}
+		baseRegion, ourRegion, theirRegion := baseLines[i:next], ourLines[o:ourEnd], theirLines[t:theirEnd]
+		switch {
+		case slices.Equal(ourRegion, baseRegion):
+			b.WriteString(strings.Join(theirRegion, ""))
+		case slices.Equal(theirRegion, baseRegion), slices.Equal(ourRegion, theirRegion):
+			b.WriteString(strings.Join(ourRegion, ""))
+		default:
+			conflict := renderConflict(ourRegion, baseRegion, theirRegion)
+			conflicts = append(conflicts, conflict)
+			b.WriteString(conflict)
+		}
+		i, o, t = next, ourEnd, theirEnd
+	}
+	return b.String(), conflicts
+}
+
+// renderConflict formats a conflicting region diff3-style: the edit's lines, the lines as last
+// read, and the lines now on disk.
+func renderConflict(ours, base, theirs []string) string {
+	var b strings.Builder
+	section := func(marker string, lines []string) {
+		b.WriteString(marker + "\n")
+		for _, line := range lines {
+			b.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				b.WriteString("\n")
+			}
+		}
+	}
+	section("<<<<<<< edit", ours)
+	section("||||||| last read", base)
+	section("=======", theirs)
+	b.WriteString(">>>>>>> on disk\n")
+	return b.String()
+}
+
+// matchBaseLines maps each line of the n base lines to its index in the other side of ops, or -1
+// where ops deletes it.
+func matchBaseLines(ops []diffOp, n int) []int {
+	match := make([]int, n)
+	i, j := 0, 0
+	for _, op := range ops {
+		switch op.kind {
+		case ' ':
+			match[i] = j
+			i, j = i+1, j+1
+		case '-':
+			match[i] = -1
+			i++
+		case '+':
+			j++
+		}
+	}
+	return match
+}
+
+// splitLinesKeepEnds splits text into lines that keep their terminators, so that joining them
+// reproduces text exactly.
+func splitLinesKeepEnds(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeLines(t *testing.T) {
+	base := "a\nb\nc\nd\ne\n"
+	tests := []struct {
+		name, ours, theirs, want string
+		conflicts                int
+	}{
+		{"separate changes", "A\nb\nc\nd\ne\n", "a\nb\nc\nd\nE\n", "A\nb\nc\nd\nE\n", 0},
+		{"insertions on both sides", "a\nb\nx\nc\nd\ne\n", "a\nb\nc\nd\ne\ny\n", "a\nb\nx\nc\nd\ne\ny\n", 0},
+		{"same change", "a\nB\nc\nd\ne\n", "a\nB\nc\nd\ne\n", "a\nB\nc\nd\ne\n", 0},
+		{"deletion and change elsewhere", "a\nc\nd\ne\n", "a\nb\nc\nD\ne\n", "a\nc\nD\ne\n", 0},
+		{"colliding changes", "a\nB1\nc\nd\ne\n", "a\nB2\nc\nd\ne\n", "a\n<<<<<<< edit\nB1\n||||||| last read\nb\n=======\nB2\n>>>>>>> on disk\nc\nd\ne\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, conflicts := mergeLines(base, tt.ours, tt.theirs)
+			assert.Equal(t, tt.want, merged)
+			assert.Len(t, conflicts, tt.conflicts)
+		})
+	}
+}
+
+func TestEditMerge(t *testing.T) {
+	state := NewState()
+	path := filepath.Join(t.TempDir(), "file.go")
+	require.NoError(t, os.WriteFile(path, []byte("package main\n\nfunc a() {}\n\nfunc b() {}\n"), 0o644))
+	_, err := state.executeRead(context.Background(), path, 0, 0)
+	require.NoError(t, err)
+
+	// Something else changes another part of the file after it was read.
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, os.WriteFile(path, []byte("package main\n\nfunc a() {}\n\nfunc b() { return }\n"), 0o644))
+
+	_, err = callEdit(t, state, EditInput{FilePath: path, OldString: "func a() {}", NewString: "func a() { println() }"})
+	assert.ErrorContains(t, err, "modified since it was last read")
+
+	result, err := callEdit(t, state, EditInput{FilePath: path, OldString: "func a() {}", NewString: "func a() { println() }", Merge: true})
+	require.NoError(t, err)
+	assert.Contains(t, result, "Merged with the changes")
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, "package main\n\nfunc a() { println() }\n\nfunc b() { return }\n", string(content))
+
+	// The merged content counts as read, so another concurrent change to the same lines conflicts.
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, os.WriteFile(path, []byte("package main\n\nfunc a() { panic() }\n\nfunc b() { return }\n"), 0o644))
+	_, err = callEdit(t, state, EditInput{FilePath: path, OldString: "println()", NewString: "print()", Merge: true})
+	assert.ErrorContains(t, err, "1 conflicting hunks")
+	assert.ErrorContains(t, err, "<<<<<<< edit\nfunc a() { print() }\n||||||| last read\nfunc a() { println() }\n=======\nfunc a() { panic() }\n>>>>>>> on disk")
+	content, _ = os.ReadFile(path)
+	assert.Equal(t, "package main\n\nfunc a() { panic() }\n\nfunc b() { return }\n", string(content))
+}
@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func (s *State) executeMoveFile(ctx context.Context, source, destination string) (string, error) {
+	if err := s.checkOverlay("move_file"); err != nil {
+		return "", err
+	}
+	src, dst, err := s.resolveSourceAndDestination(source, destination)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(src, dst); err != nil {
+		return "", err
+	}
+	defer s.lockPaths(ctx, src, dst)()
+	if err := s.confirmWrite(ctx, "move_file", src, dst); err != nil {
+		return "", err
+	}
+
+	fsys := s.filesystem()
+	srcInfo, err := fsys.Lstat(src)
+	if err != nil {
+		return "", fmt.Errorf("source does not exist")
+	}
+	if srcInfo.IsDir() {
+		if _, err := fsys.Lstat(dst); err == nil {
+			return "", fmt.Errorf("destination already exists: %s", dst)
+		}
+		if strings.HasPrefix(dst, src+string(filepath.Separator)) {
+			return "", fmt.Errorf("cannot move a directory into itself")
+		}
+	} else if err := s.validateDestination(dst); err != nil {
+		return "", err
+	}
+
+	if err := fsys.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return "", fmt.Errorf("Cannot create parent directory: %s", err)
+	}
+
+	s.noteChange(ctx, "move_file", src)
+	s.noteChange(ctx, "move_file", dst)
+	if err := fsys.Rename(src, dst); err != nil {
+		// Renames can't cross filesystems; fall back to copying and then removing the source.
+		if !errors.Is(err, syscall.EXDEV) {
+			return "", fmt.Errorf("Cannot move file: %s", err)
+		}
+		if srcInfo.IsDir() {
+			err = copyTree(ctx, src, dst)
+		} else {
+			err = copyEntry(src, dst, srcInfo)
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := os.RemoveAll(src); err != nil {
+			return "", fmt.Errorf("Copied to %s but cannot remove source: %s", dst, err)
+		}
+	}
+
+	// Reads of the source don't carry over: the moved content must be re-read at its new
+	// location before it can be edited, just like any other file.
+	s.forgetPath(src)
+	s.forgetPath(dst)
+	s.noteMove(ctx, src, dst)
+
+	return fmt.Sprintf("Moved successfully from %s to %s", src, dst), nil
+}
+
+var MoveFileTool = sdk.Tool{
+	Name:        "move_file",
+	Description: "Moves or renames a file or directory.\n\nUsage:\n- Both source and destination must be absolute paths.\n- Parent directories of the destination are created as needed.\n- If the destination file already exists, you MUST use the Read tool on it first. This tool will fail if you did not read the existing destination.\n- Directories are never moved onto an existing destination.\n- After moving, read the file at its new path before editing it.",
+}
+
+type MoveFileInput struct {
+	Source      string `json:"source" jsonschema:"The absolute path of the file or directory to move"`
+	Destination string `json:"destination" jsonschema:"The absolute path to move to"`
+}
+type MoveFileOutput struct {
+	Message string `json:"message"`
+}
+
+func MoveFile(ctx context.Context, req *sdk.CallToolRequest, args MoveFileInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeMoveFile(withSession(ctx, req), args.Source, args.Destination)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &MoveFileOutput{Message: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveFile_BasicFunctionality(t *testing.T) {
+	t.Run("renames file", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "a.txt")
+		require.NoError(t, os.WriteFile(src, []byte("content"), 0o644))
+		dst := filepath.Join(dir, "sub", "b.txt")
+		state := NewState()
+		result, err := state.executeMoveFile(context.Background(), src, dst)
+		require.NoError(t, err)
+		assert.Contains(t, result, "Moved successfully")
+		_, err = os.Stat(src)
+		assert.True(t, os.IsNotExist(err))
+		content, err := os.ReadFile(dst)
+		require.NoError(t, err)
+		assert.Equal(t, "content", string(content))
+	})
+	t.Run("clears read tracking for source", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "a.txt")
+		require.NoError(t, os.WriteFile(src, []byte("content"), 0o644))
+		state := NewState()
+		_, err := state.executeRead(context.Background(), src, 0, 0)
+		require.NoError(t, err)
+		_, err = state.executeMoveFile(context.Background(), src, filepath.Join(dir, "b.txt"))
+		require.NoError(t, err)
+		state.Mu.RLock()
+		_, tracked := state.ReadFiles[src]
+		state.Mu.RUnlock()
+		assert.False(t, tracked)
+	})
+}
+
+func TestMoveFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	require.NoError(t, os.WriteFile(src, []byte("new"), 0o644))
+	existing := filepath.Join(dir, "existing.txt")
+	require.NoError(t, os.WriteFile(existing, []byte("old"), 0o644))
+	state := NewState()
+
+	t.Run("unread destination", func(t *testing.T) {
+		_, err := state.executeMoveFile(context.Background(), src, existing)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "read it first")
+	})
+	t.Run("directory onto existing path", func(t *testing.T) {
+		sub := filepath.Join(dir, "subdir")
+		require.NoError(t, os.Mkdir(sub, 0o755))
+		_, err := state.executeMoveFile(context.Background(), sub, existing)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists")
+	})
+	t.Run("same path", func(t *testing.T) {
+		_, err := state.executeMoveFile(context.Background(), src, src)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "same path")
+	})
+}
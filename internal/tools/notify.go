@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// shellDoneNotification is the payload of the log notification sent when a background shell exits.
+type shellDoneNotification struct {
+	Event      string `json:"event"`
+	ShellID    string `json:"shell_id"`
+	Status     string `json:"status"`
+	ExitCode   int    `json:"exit_code"`
+	DurationMs int64  `json:"duration_ms"`
+	Command    string `json:"command"`
+}
+
+// notifyShellDone pushes a notifications/message to the session that started the shell. Clients
+// receive it only if they enabled logging via logging/setLevel and the session is still connected;
+// in stateless mode the session closes with the originating request, so the notification is
+// dropped and clients must fall back to polling bash_output or list_shells.
+func notifyShellDone(session *sdk.ServerSession, shell *BackgroundShell, status string, duration time.Duration) {
+	if session == nil {
+		return
+	}
+	level := sdk.LoggingLevel("info")
+	if status != "completed" {
+		level = "warning"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Delivery is best-effort: the client may have disconnected since starting the shell.
+	_ = session.Log(ctx, &sdk.LoggingMessageParams{
+		Level:  level,
+		Logger: "bash",
+		Data: shellDoneNotification{
+			Event:      "shell_done",
+			ShellID:    shell.ID,
+			Status:     status,
+			ExitCode:   shell.ExitCode,
+			DurationMs: duration.Milliseconds(),
+			Command:    shell.Command,
+		},
+	})
+}
+
+// progressReporter sends notifications/progress for a request whose client supplied a progress
+// token. A nil reporter discards every report, so callers need not check whether progress was
+// requested.
+type progressReporter struct {
+	session *sdk.ServerSession
+	token   any
+}
+
+func newProgressReporter(req *sdk.CallToolRequest) *progressReporter {
+	if req == nil || req.Session == nil || req.Params == nil {
+		return nil
+	}
+	token := req.Params.GetProgressToken()
+	if token == nil {
+		return nil
+	}
+	return &progressReporter{session: req.Session, token: token}
+}
+
+func (p *progressReporter) report(ctx context.Context, progress float64, message string) {
+	if p == nil {
+		return
+	}
+	// Like log notifications, progress is best-effort and never fails the request.
+	_ = p.session.NotifyProgress(ctx, &sdk.ProgressNotificationParams{
+		ProgressToken: p.token,
+		Progress:      progress,
+		Message:       message,
+	})
+}
@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connectLoggingSession connects an in-memory client that enabled logging and returns the server
+// side of the session along with the log messages the client receives.
+func connectLoggingSession(t *testing.T) (*sdk.ServerSession, <-chan *sdk.LoggingMessageParams) {
+	t.Helper()
+	ctx := context.Background()
+	messages := make(chan *sdk.LoggingMessageParams, 10)
+	serverTransport, clientTransport := sdk.NewInMemoryTransports()
+	server := sdk.NewServer(&sdk.Implementation{Name: "test"}, nil)
+	session, err := server.Connect(ctx, serverTransport, nil)
+	require.NoError(t, err)
+	client, err := sdk.NewClient(&sdk.Implementation{Name: "test"}, &sdk.ClientOptions{
+		LoggingMessageHandler: func(_ context.Context, req *sdk.LoggingMessageRequest) {
+			messages <- req.Params
+		},
+	}).Connect(ctx, clientTransport, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	require.NoError(t, client.SetLoggingLevel(ctx, &sdk.SetLoggingLevelParams{Level: "info"}))
+	return session, messages
+}
+
+func TestMonitorShell_Notification(t *testing.T) {
+	session, messages := connectLoggingSession(t)
+	ctx := context.WithValue(context.Background(), sessionKey{}, session)
+
+	tests := []struct {
+		command  string
+		status   string
+		level    sdk.LoggingLevel
+		exitCode float64
+	}{
+		{"true", "completed", "info", 0},
+		{"exit 3", "failed", "warning", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			state := NewState()
+			result, err := state.executeBashCommand(ctx, tt.command, "", 0, true, bashOptions{})
+			require.NoError(t, err)
+			shellID := extractShellID(result.Result)
+
+			select {
+			case msg := <-messages:
+				assert.Equal(t, tt.level, msg.Level)
+				assert.Equal(t, "bash", msg.Logger)
+				data, ok := msg.Data.(map[string]any)
+				require.True(t, ok, "payload is a JSON object: %#v", msg.Data)
+				assert.Equal(t, "shell_done", data["event"])
+				assert.Equal(t, shellID, data["shell_id"])
+				assert.Equal(t, tt.status, data["status"])
+				assert.Equal(t, tt.exitCode, data["exit_code"])
+				assert.Equal(t, tt.command, data["command"])
+				assert.Contains(t, data, "duration_ms")
+			case <-time.After(5 * time.Second):
+				t.Fatal("no shell_done notification")
+			}
+		})
+	}
+}
+
+func TestMonitorShell_NoSession(t *testing.T) {
+	_, messages := connectLoggingSession(t)
+	state := NewState()
+
+	// The shell is started outside any session, so its completion has nowhere to go.
+	result, err := state.executeBashCommand(context.Background(), "true", "", 0, true, bashOptions{})
+	require.NoError(t, err)
+	waitShell(t, state, extractShellID(result.Result))
+
+	select {
+	case msg := <-messages:
+		t.Fatalf("unexpected notification: %#v", msg)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
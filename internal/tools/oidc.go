@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/brwse/claude-tools-mcp/internal/oidc"
+	"github.com/modelcontextprotocol/go-sdk/auth"
+)
+
+// oidcDiscoveryTimeout bounds fetching the issuer's discovery document and keys at startup.
+const oidcDiscoveryTimeout = 30 * time.Second
+
+// ConfigureOIDC accepts JWTs from an OpenID Connect issuer, for teams that sign in through SSO.
+// Tokens must name audience in aud and carry every name=value claim in claims. Their read, write,
+// and exec scopes come from the scope or scp claim, each prefixed with scopePrefix, or are
+// defaultScopes when the token names none. An empty issuer disables OIDC.
+func (s *State) ConfigureOIDC(issuer, audience string, claims []string, scopePrefix string, defaultScopes []string) error {
+	if issuer == "" {
+		s.Mu.Lock()
+		s.OIDC = nil
+		s.Mu.Unlock()
+		return nil
+	}
+	if audience == "" {
+		return fmt.Errorf("An OIDC audience is required, so that tokens issued for other services are refused.")
+	}
+	required := make(map[string]string, len(claims))
+	for _, spec := range claims {
+		name, value, ok := strings.Cut(spec, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("Invalid OIDC claim %q. Must be name=value.", spec)
+		}
+		required[name] = value
+	}
+	for _, scope := range defaultScopes {
+		if scope != ScopeRead && scope != ScopeWrite && scope != ScopeExec {
+			return fmt.Errorf("Invalid default OIDC scope %q. Must be read, write, or exec.", scope)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), oidcDiscoveryTimeout)
+	defer cancel()
+	verifier, err := oidc.NewVerifier(ctx, oidc.Config{Issuer: issuer, Audience: audience, Claims: required})
+	if err != nil {
+		return fmt.Errorf("Cannot use OIDC issuer %s: %s", issuer, err)
+	}
+	s.Mu.Lock()
+	s.OIDC = verifier
+	s.OIDCScopePrefix = scopePrefix
+	s.OIDCDefaultScopes = defaultScopes
+	s.Mu.Unlock()
+	return nil
+}
+
+// verifyOIDCToken returns the token info of a JWT verified by verifier. The client is named by the
+// token's email claim, or its subject.
+func (s *State) verifyOIDCToken(ctx context.Context, verifier *oidc.Verifier, token string) (*auth.TokenInfo, error) {
+	claims, err := verifier.Verify(ctx, token)
+	if errors.Is(err, oidc.ErrInvalidToken) {
+		return nil, fmt.Errorf("%w: %s", auth.ErrInvalidToken, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.Mu.RLock()
+	prefix, defaults := s.OIDCScopePrefix, s.OIDCDefaultScopes
+	s.Mu.RUnlock()
+	scopes := tokenScopes(claims, prefix)
+	if len(scopes) == 0 {
+		scopes = defaults
+	}
+	client := claims.String("email")
+	if client == "" {
+		client = claims.String("sub")
+	}
+	return &auth.TokenInfo{
+		Scopes:     scopes,
+		Expiration: claims.Expiration(),
+		Extra:      map[string]any{"client": client},
+	}, nil
+}
+
+// tokenScopes returns the server's scopes among a token's scope claim (space-separated, per RFC
+// 8693) and scp claim (a list or string, as some providers issue), after removing prefix.
+func tokenScopes(claims oidc.Claims, prefix string) []string {
+	granted := strings.Fields(claims.String("scope"))
+	switch scp := claims["scp"].(type) {
+	case string:
+		granted = append(granted, strings.Fields(scp)...)
+	case []any:
+		for _, scope := range scp {
+			if scope, ok := scope.(string); ok {
+				granted = append(granted, scope)
+			}
+		}
+	}
+	var scopes []string
+	for _, scope := range granted {
+		scope, ok := strings.CutPrefix(scope, prefix)
+		if ok && (scope == ScopeRead || scope == ScopeWrite || scope == ScopeExec) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
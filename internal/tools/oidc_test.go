@@ -0,0 +1,26 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/brwse/claude-tools-mcp/internal/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureOIDC(t *testing.T) {
+	state := NewState()
+	require.NoError(t, state.ConfigureOIDC("", "", nil, "", nil))
+	assert.False(t, state.AuthRequired())
+
+	assert.ErrorContains(t, state.ConfigureOIDC("https://sso.example.com", "", nil, "", nil), "audience is required")
+	assert.ErrorContains(t, state.ConfigureOIDC("https://sso.example.com", "claude-tools", []string{"groups"}, "", nil), "Must be name=value")
+	assert.ErrorContains(t, state.ConfigureOIDC("https://sso.example.com", "claude-tools", nil, "", []string{"admin"}), "Invalid default OIDC scope")
+}
+
+func TestTokenScopes(t *testing.T) {
+	assert.Equal(t, []string{"read", "exec"}, tokenScopes(oidc.Claims{"scope": "openid read exec email"}, ""))
+	assert.Equal(t, []string{"read", "write"}, tokenScopes(oidc.Claims{"scp": []any{"tools:read", "tools:write", "read"}}, "tools:"))
+	assert.Equal(t, []string{"write"}, tokenScopes(oidc.Claims{"scope": "tools:write", "scp": "tools:write"}, "tools:"))
+	assert.Empty(t, tokenScopes(oidc.Claims{"sub": "user"}, ""))
+}
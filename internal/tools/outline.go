@@ -0,0 +1,487 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxSignatureLength bounds the declaration line reported for each symbol.
+const maxSignatureLength = 200
+
+type outlineSymbol struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Parent    string `json:"parent,omitempty"`
+	Signature string `json:"signature"`
+}
+
+type outlineResult struct {
+	Path      string          `json:"path"`
+	Language  string          `json:"language"`
+	Symbols   []outlineSymbol `json:"symbols"`
+	Count     int             `json:"count"`
+	Truncated bool            `json:"truncated,omitempty"`
+}
+
+// outlineRule recognizes one kind of declaration by the line it starts on. The name is the
+// pattern's "name" group.
+type outlineRule struct {
+	kind    string
+	pattern *regexp.Regexp
+}
+
+// outlineLanguage describes how to outline files of one language: the declarations it has, and
+// whether a declaration's body is delimited by braces or by indentation.
+type outlineLanguage struct {
+	name     string
+	rules    []outlineRule
+	indented bool
+}
+
+func newOutlineRule(kind, pattern string) outlineRule {
+	return outlineRule{kind: kind, pattern: regexp.MustCompile(pattern)}
+}
+
+// Declarations inside function bodies are not symbols worth navigating to, and keywords that look
+// like calls (if, for, while...) must never be taken for method names.
+var (
+	pythonOutline = &outlineLanguage{name: "python", indented: true, rules: []outlineRule{
+		newOutlineRule("class", `^\s*class\s+(?P<name>\w+)`),
+		newOutlineRule("function", `^\s*(?:async\s+)?def\s+(?P<name>\w+)`),
+	}}
+	javascriptOutline = &outlineLanguage{name: "javascript", rules: []outlineRule{
+		newOutlineRule("class", `^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(?P<name>[\w$]+)`),
+		newOutlineRule("interface", `^\s*(?:export\s+)?interface\s+(?P<name>[\w$]+)`),
+		newOutlineRule("type", `^\s*(?:export\s+)?type\s+(?P<name>[\w$]+)\s*(?:<[^=]*>)?\s*=`),
+		newOutlineRule("enum", `^\s*(?:export\s+)?(?:const\s+)?enum\s+(?P<name>[\w$]+)`),
+		newOutlineRule("function", `^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(?P<name>[\w$]+)`),
+		newOutlineRule("function", `^\s*(?:export\s+)?(?:const|let|var)\s+(?P<name>[\w$]+)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|[\w$]+\s*=>)`),
+		newOutlineRule("method", `^\s+(?:(?:public|private|protected|static|readonly|override|abstract|async|get|set)\s+)*\*?(?P<name>[\w$]+)\s*(?:<[^>]*>)?\([^;]*$`),
+	}}
+	rustOutline = &outlineLanguage{name: "rust", rules: []outlineRule{
+		newOutlineRule("function", `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?(?:extern\s+"[^"]*"\s+)?fn\s+(?P<name>\w+)`),
+		newOutlineRule("struct", `^\s*(?:pub(?:\([^)]*\))?\s+)?struct\s+(?P<name>\w+)`),
+		newOutlineRule("enum", `^\s*(?:pub(?:\([^)]*\))?\s+)?enum\s+(?P<name>\w+)`),
+		newOutlineRule("trait", `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:unsafe\s+)?trait\s+(?P<name>\w+)`),
+		newOutlineRule("impl", `^\s*(?:unsafe\s+)?impl\b(?:\s*<[^>]*>)?\s+(?P<name>[\w:]+(?:<[^>]*>)?(?:\s+for\s+[\w:]+(?:<[^>]*>)?)?)`),
+		newOutlineRule("module", `^\s*(?:pub(?:\([^)]*\))?\s+)?mod\s+(?P<name>\w+)\s*\{`),
+	}}
+	javaOutline = &outlineLanguage{name: "java", rules: []outlineRule{
+		newOutlineRule("class", `^\s*(?:(?:public|private|protected|static|final|abstract|sealed|partial|internal|data|open)\s+)*class\s+(?P<name>\w+)`),
+		newOutlineRule("interface", `^\s*(?:(?:public|private|protected|static|sealed|internal)\s+)*(?:@)?interface\s+(?P<name>\w+)`),
+		newOutlineRule("enum", `^\s*(?:(?:public|private|protected|static|internal)\s+)*enum\s+(?:class\s+)?(?P<name>\w+)`),
+		newOutlineRule("record", `^\s*(?:(?:public|private|protected|static|final)\s+)*record\s+(?P<name>\w+)`),
+		newOutlineRule("method", `^\s*(?:(?:public|private|protected|static|final|abstract|synchronized|native|override|virtual|async|internal)\s+)+[\w<>\[\],.? ]+\s+(?P<name>\w+)\s*\([^;]*$`),
+	}}
+	cOutline = &outlineLanguage{name: "c", rules: []outlineRule{
+		newOutlineRule("struct", `^\s*(?:typedef\s+)?struct\s+(?P<name>\w+)\s*\{?\s*$`),
+		newOutlineRule("class", `^\s*(?:template\s*<[^>]*>\s*)?class\s+(?P<name>\w+)[^;]*$`),
+		newOutlineRule("enum", `^\s*(?:typedef\s+)?enum\s+(?:class\s+)?(?P<name>\w+)[^;]*$`),
+		newOutlineRule("namespace", `^\s*namespace\s+(?P<name>[\w:]+)\s*\{?\s*$`),
+		newOutlineRule("function", `^[A-Za-z_][\w\s\*&:<>,]*?[\s\*&](?P<name>[A-Za-z_][\w:~]*)\s*\([^;]*$`),
+	}}
+)
+
+// outlineKeywords are words that method and function patterns could mistake for names.
+var outlineKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true,
+	"function": true, "else": true, "do": true, "try": true, "sizeof": true,
+	"elif": true, "with": true, "await": true, "typeof": true, "delete": true, "throw": true,
+}
+
+var outlineLanguages = map[string]*outlineLanguage{
+	".py": pythonOutline, ".pyi": pythonOutline,
+	".js": javascriptOutline, ".jsx": javascriptOutline, ".mjs": javascriptOutline, ".cjs": javascriptOutline,
+	".ts": javascriptOutline, ".tsx": javascriptOutline, ".mts": javascriptOutline, ".cts": javascriptOutline,
+	".rs":   rustOutline,
+	".java": javaOutline, ".kt": javaOutline, ".cs": javaOutline, ".scala": javaOutline,
+	".c": cOutline, ".h": cOutline, ".cc": cOutline, ".cpp": cOutline, ".cxx": cOutline, ".hpp": cOutline, ".hh": cOutline,
+}
+
+func (s *State) executeOutline(ctx context.Context, filePath string) (string, error) {
+	resolved, err := s.resolvePath(filePath)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("File does not exist.")
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("path is a directory, not a file: %s", resolved)
+	}
+	if err := s.checkFileSize(ctx, info.Size(), "outline"); err != nil {
+		return "", err
+	}
+	src, err := os.ReadFile(resolved)
+	if err != nil {
+		return "", fmt.Errorf("Cannot read file: %s", err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(resolved))
+	result := outlineResult{Path: resolved}
+	if ext == ".go" {
+		result.Language = "go"
+		if result.Symbols, err = outlineGo(resolved, src); err != nil {
+			return "", err
+		}
+	} else if lang, ok := outlineLanguages[ext]; ok {
+		result.Language = lang.name
+		result.Symbols = outlineHeuristic(lang, strings.Split(string(src), "\n"))
+	} else {
+		return "", fmt.Errorf("Unsupported file type: %s. Supported languages are Go, Python, JavaScript/TypeScript, Rust, Java/Kotlin/C#, and C/C++.", ext)
+	}
+
+	if result.Symbols == nil {
+		result.Symbols = []outlineSymbol{}
+	}
+	if maxResults := limitsFromContext(ctx).maxResults; len(result.Symbols) > maxResults {
+		result.Symbols = result.Symbols[:maxResults]
+		result.Truncated = true
+	}
+	result.Count = len(result.Symbols)
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format outline: %s", err)
+	}
+	output := string(jsonBytes)
+	if err := checkOutputSize(ctx, output, "outline"); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// outlineGo lists the top-level declarations of a Go file using the Go parser. Doc comments are
+// included in each symbol's range so a Read of it shows the documentation too.
+func outlineGo(path string, src []byte) ([]outlineSymbol, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments|parser.SkipObjectResolution)
+	if file == nil {
+		return nil, fmt.Errorf("Cannot parse Go file: %s", err)
+	}
+	lines := strings.Split(string(src), "\n")
+	symbol := func(name, kind, parent string, doc *ast.CommentGroup, start, end token.Pos) outlineSymbol {
+		if doc != nil {
+			start = doc.Pos()
+		}
+		startLine := fset.Position(start).Line
+		declLine := startLine
+		if doc != nil {
+			declLine = fset.Position(doc.End()).Line + 1
+		}
+		return outlineSymbol{
+			Name:      name,
+			Kind:      kind,
+			StartLine: startLine,
+			EndLine:   fset.Position(end).Line,
+			Parent:    parent,
+			Signature: outlineSignature(lines, declLine),
+		}
+	}
+
+	var symbols []outlineSymbol
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) == 0 {
+				symbols = append(symbols, symbol(d.Name.Name, "function", "", d.Doc, d.Pos(), d.End()))
+				continue
+			}
+			symbols = append(symbols, symbol(d.Name.Name, "method", goReceiverType(d.Recv.List[0].Type), d.Doc, d.Pos(), d.End()))
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					kind := "type"
+					switch sp.Type.(type) {
+					case *ast.StructType:
+						kind = "struct"
+					case *ast.InterfaceType:
+						kind = "interface"
+					}
+					doc := sp.Doc
+					start, end := sp.Pos(), sp.End()
+					// An ungrouped declaration carries its doc comment and "type" keyword on the
+					// GenDecl rather than the spec.
+					if !d.Lparen.IsValid() {
+						doc, start, end = d.Doc, d.Pos(), d.End()
+					}
+					symbols = append(symbols, symbol(sp.Name.Name, kind, "", doc, start, end))
+				case *ast.ValueSpec:
+					kind := "var"
+					if d.Tok == token.CONST {
+						kind = "const"
+					}
+					doc := sp.Doc
+					start, end := sp.Pos(), sp.End()
+					if !d.Lparen.IsValid() {
+						doc, start, end = d.Doc, d.Pos(), d.End()
+					}
+					for _, name := range sp.Names {
+						if name.Name != "_" {
+							symbols = append(symbols, symbol(name.Name, kind, "", doc, start, end))
+						}
+					}
+				}
+			}
+		}
+	}
+	return symbols, nil
+}
+
+// goReceiverType returns the type name of a method receiver, without pointer or type parameters.
+func goReceiverType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return goReceiverType(t.X)
+	case *ast.IndexExpr:
+		return goReceiverType(t.X)
+	case *ast.IndexListExpr:
+		return goReceiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	default:
+		return ""
+	}
+}
+
+// outlineHeuristic finds declarations line by line with the language's patterns, then measures
+// each one's extent by its braces or indentation. It is approximate by nature, but symbols are
+// only navigation aids: a slightly wrong end line still points a Read at the right code.
+func outlineHeuristic(lang *outlineLanguage, lines []string) []outlineSymbol {
+	var symbols []outlineSymbol
+	// skipUntil hides declarations inside function bodies, which are local helpers rather than
+	// part of the file's outline.
+	skipUntil := 0
+	for i, line := range lines {
+		if i < skipUntil {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "*") || strings.HasPrefix(trimmed, "/*") {
+			continue
+		}
+		for _, r := range lang.rules {
+			m := r.pattern.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			name := m[r.pattern.SubexpIndex("name")]
+			if outlineKeywords[name] {
+				continue
+			}
+			end := i
+			if lang.indented {
+				end = indentedBlockEnd(lines, i)
+			} else if braceEnd, ok := braceBlockEnd(lines, i); ok {
+				end = braceEnd
+			} else if r.kind == "method" || r.kind == "function" {
+				// A prototype or a call, not a definition.
+				continue
+			}
+			start := i
+			for start > 0 && isOutlinePreamble(lang, lines[start-1]) {
+				start--
+			}
+			symbols = append(symbols, outlineSymbol{
+				Name:      name,
+				Kind:      r.kind,
+				StartLine: start + 1,
+				EndLine:   end + 1,
+				Signature: outlineSignature(lines, i+1),
+			})
+			if r.kind == "function" || r.kind == "method" {
+				skipUntil = end + 1
+			}
+			break
+		}
+	}
+
+	// Each symbol's parent is the innermost container whose range encloses it.
+	for i := range symbols {
+		for j := i - 1; j >= 0; j-- {
+			if symbols[j].EndLine >= symbols[i].EndLine && symbols[j].Kind != "function" && symbols[j].Kind != "method" {
+				symbols[i].Parent = symbols[j].Name
+				if symbols[i].Kind == "function" {
+					symbols[i].Kind = "method"
+				}
+				break
+			}
+		}
+		if symbols[i].Parent == "" && symbols[i].Kind == "method" {
+			// Method patterns match indented functions; without a container they are functions.
+			symbols[i].Kind = "function"
+		}
+	}
+	sort.SliceStable(symbols, func(i, j int) bool { return symbols[i].StartLine < symbols[j].StartLine })
+	return symbols
+}
+
+// isOutlinePreamble reports whether line is a decorator or annotation that belongs to the
+// declaration below it.
+func isOutlinePreamble(lang *outlineLanguage, line string) bool {
+	trimmed := strings.TrimSpace(line)
+	switch lang {
+	case pythonOutline, javascriptOutline, javaOutline:
+		return strings.HasPrefix(trimmed, "@")
+	case rustOutline:
+		return strings.HasPrefix(trimmed, "#[")
+	default:
+		return false
+	}
+}
+
+// indentedBlockEnd returns the last line of the block introduced at line start: the last
+// non-blank line before the next line indented no deeper than start.
+func indentedBlockEnd(lines []string, start int) int {
+	indent := indentWidth(lines[start])
+	// A signature may continue over several lines before the colon that opens the body.
+	i := start
+	for i < len(lines) && !strings.HasSuffix(strings.TrimSpace(stripLineComment(lines[i], "#")), ":") {
+		i++
+	}
+	end := min(i, len(lines)-1)
+	for i++; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		if indentWidth(lines[i]) <= indent {
+			break
+		}
+		end = i
+	}
+	return end
+}
+
+func indentWidth(line string) int {
+	width := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width += 8 - width%8
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+func stripLineComment(line, marker string) string {
+	if i := strings.Index(line, marker); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// braceBlockEnd returns the line holding the brace that closes the first block opened at or after
+// line start. Strings, character literals, and comments are skipped so braces inside them are not
+// counted. ok is false if a semicolon ends the statement before any block opens.
+func braceBlockEnd(lines []string, start int) (end int, ok bool) {
+	depth := 0
+	opened := false
+	inBlockComment := false
+	for i := start; i < len(lines); i++ {
+		line := lines[i]
+		var quote byte
+		for j := 0; j < len(line); j++ {
+			c := line[j]
+			switch {
+			case inBlockComment:
+				if c == '*' && j+1 < len(line) && line[j+1] == '/' {
+					inBlockComment = false
+					j++
+				}
+			case quote != 0:
+				if c == '\\' {
+					j++
+				} else if c == quote {
+					quote = 0
+				}
+			case c == '/' && j+1 < len(line) && line[j+1] == '/':
+				j = len(line)
+			case c == '/' && j+1 < len(line) && line[j+1] == '*':
+				inBlockComment = true
+				j++
+			case c == '"' || c == '`':
+				quote = c
+			case c == '\'':
+				// Rust lifetimes ('a) look like unterminated character literals.
+				if j+2 < len(line) && (line[j+2] == '\'' || (line[j+1] == '\\' && j+3 < len(line))) {
+					quote = c
+				}
+			case c == '{':
+				depth++
+				opened = true
+			case c == '}':
+				depth--
+				if opened && depth == 0 {
+					return i, true
+				}
+			case c == ';' && !opened && depth == 0:
+				return i, false
+			}
+		}
+		// Template literals may span lines; other quotes end with their line.
+		if quote != '`' {
+			quote = 0
+		}
+	}
+	return len(lines) - 1, opened
+}
+
+// outlineSignature returns the declaration line, trimmed and bounded in length.
+func outlineSignature(lines []string, line int) string {
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	signature := strings.TrimSpace(lines[line-1])
+	signature = strings.TrimSpace(strings.TrimSuffix(signature, "{"))
+	if len(signature) > maxSignatureLength {
+		signature = signature[:maxSignatureLength] + "..."
+	}
+	return signature
+}
+
+var OutlineTool = sdk.Tool{
+	Name:        "outline",
+	Description: "Lists the symbols declared in a source file, such as functions, methods, classes, and types, with their line ranges.\n\nUsage:\n- The file_path parameter must be an absolute path\n- Each symbol has its name, kind, start_line and end_line (including doc comments or decorators), its enclosing class or type (parent) where it has one, and its declaration line (signature)\n- Go files are parsed exactly; Python, JavaScript/TypeScript, Rust, Java/Kotlin/C#, and C/C++ files are outlined heuristically\n- Use the line ranges with the Read tool's offset and limit to read just the symbol you need instead of the whole file",
+}
+
+type OutlineInput struct {
+	FilePath string `json:"file_path" jsonschema:"The absolute path to the source file to outline"`
+}
+type OutlineOutput struct {
+	Result string `json:"result"`
+}
+
+func Outline(ctx context.Context, req *sdk.CallToolRequest, args OutlineInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeOutline(ctx, args.FilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &OutlineOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,195 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func callOutline(t *testing.T, name, content string) outlineResult {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	result, err := NewState().executeOutline(context.Background(), path)
+	require.NoError(t, err)
+	var parsed outlineResult
+	require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+	return parsed
+}
+
+// symbolSummary drops signatures so expectations stay readable.
+func symbolSummary(symbols []outlineSymbol) []outlineSymbol {
+	summary := make([]outlineSymbol, len(symbols))
+	for i, s := range symbols {
+		s.Signature = ""
+		summary[i] = s
+	}
+	return summary
+}
+
+func TestOutline_Go(t *testing.T) {
+	src := `package demo
+
+import "fmt"
+
+// Greeter says hello.
+type Greeter struct {
+	Name string
+}
+
+type Shape interface {
+	Area() float64
+}
+
+const (
+	// Pi is close enough.
+	Pi = 3.14
+	E  = 2.71
+)
+
+// Greet returns a greeting.
+func (g *Greeter) Greet() string {
+	return fmt.Sprintf("hello %s", g.Name)
+}
+
+func main() {
+	fmt.Println(Pi)
+}
+`
+	result := callOutline(t, "demo.go", src)
+	assert.Equal(t, "go", result.Language)
+	assert.Equal(t, 6, result.Count)
+	assert.Equal(t, []outlineSymbol{
+		{Name: "Greeter", Kind: "struct", StartLine: 5, EndLine: 8},
+		{Name: "Shape", Kind: "interface", StartLine: 10, EndLine: 12},
+		{Name: "Pi", Kind: "const", StartLine: 15, EndLine: 16},
+		{Name: "E", Kind: "const", StartLine: 17, EndLine: 17},
+		{Name: "Greet", Kind: "method", StartLine: 20, EndLine: 23, Parent: "Greeter"},
+		{Name: "main", Kind: "function", StartLine: 25, EndLine: 27},
+	}, symbolSummary(result.Symbols))
+	assert.Equal(t, "func (g *Greeter) Greet() string", result.Symbols[4].Signature)
+}
+
+func TestOutline_Python(t *testing.T) {
+	src := `import os
+
+
+class Store:
+    """A store."""
+
+    def __init__(self, path):
+        self.path = path
+
+    @property
+    def size(self):
+        def helper():
+            return 1
+        return helper()
+
+
+async def fetch(url,
+                timeout=5):
+    return url
+`
+	result := callOutline(t, "store.py", src)
+	assert.Equal(t, "python", result.Language)
+	assert.Equal(t, []outlineSymbol{
+		{Name: "Store", Kind: "class", StartLine: 4, EndLine: 14},
+		{Name: "__init__", Kind: "method", StartLine: 7, EndLine: 8, Parent: "Store"},
+		{Name: "size", Kind: "method", StartLine: 10, EndLine: 14, Parent: "Store"},
+		{Name: "fetch", Kind: "function", StartLine: 17, EndLine: 19},
+	}, symbolSummary(result.Symbols))
+}
+
+func TestOutline_TypeScript(t *testing.T) {
+	src := `export interface Options {
+  verbose: boolean;
+}
+
+export class Client {
+  private url: string;
+
+  constructor(url: string) {
+    this.url = url;
+  }
+
+  async get(path: string): Promise<string> {
+    if (path === "}") {
+      return "{";
+    }
+    return fetch(this.url + path);
+  }
+}
+
+export const handler = async (req: Request) => {
+  return new Response("ok");
+};
+
+function helper() {}
+`
+	result := callOutline(t, "client.ts", src)
+	assert.Equal(t, "javascript", result.Language)
+	assert.Equal(t, []outlineSymbol{
+		{Name: "Options", Kind: "interface", StartLine: 1, EndLine: 3},
+		{Name: "Client", Kind: "class", StartLine: 5, EndLine: 18},
+		{Name: "constructor", Kind: "method", StartLine: 8, EndLine: 10, Parent: "Client"},
+		{Name: "get", Kind: "method", StartLine: 12, EndLine: 17, Parent: "Client"},
+		{Name: "handler", Kind: "function", StartLine: 20, EndLine: 22},
+		{Name: "helper", Kind: "function", StartLine: 24, EndLine: 24},
+	}, symbolSummary(result.Symbols))
+}
+
+func TestOutline_Rust(t *testing.T) {
+	src := `#[derive(Debug)]
+pub struct Point {
+    x: i32,
+}
+
+impl Point {
+    pub fn new(x: i32) -> Self {
+        Point { x }
+    }
+
+    fn get<'a>(&'a self) -> &'a i32 {
+        &self.x
+    }
+}
+`
+	result := callOutline(t, "point.rs", src)
+	assert.Equal(t, []outlineSymbol{
+		{Name: "Point", Kind: "struct", StartLine: 1, EndLine: 4},
+		{Name: "Point", Kind: "impl", StartLine: 6, EndLine: 14},
+		{Name: "new", Kind: "method", StartLine: 7, EndLine: 9, Parent: "Point"},
+		{Name: "get", Kind: "method", StartLine: 11, EndLine: 13, Parent: "Point"},
+	}, symbolSummary(result.Symbols))
+}
+
+func TestOutline_Errors(t *testing.T) {
+	state := NewState()
+	dir := t.TempDir()
+
+	_, err := state.executeOutline(context.Background(), "relative.go")
+	assert.Error(t, err)
+
+	_, err = state.executeOutline(context.Background(), filepath.Join(dir, "missing.go"))
+	assert.ErrorContains(t, err, "does not exist")
+
+	_, err = state.executeOutline(context.Background(), dir)
+	assert.ErrorContains(t, err, "directory")
+
+	path := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0o644))
+	_, err = state.executeOutline(context.Background(), path)
+	assert.ErrorContains(t, err, "Unsupported file type")
+}
+
+func TestOutline_GoSyntaxErrorStillOutlines(t *testing.T) {
+	result := callOutline(t, "broken.go", "package demo\n\nfunc ok() {}\n\nfunc broken( {\n")
+	require.NotEmpty(t, result.Symbols)
+	assert.Equal(t, "ok", result.Symbols[0].Name)
+}
@@ -0,0 +1,450 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// stagedFile is a change held in a session's overlay instead of on disk: new content for the file,
+// or its deletion.
+type stagedFile struct {
+	data    []byte
+	perm    os.FileMode
+	deleted bool
+	staged  time.Time
+
+	// baseExisted and baseContent are the file on disk when it was first staged, so commit can
+	// tell whether something else has changed it since.
+	baseExisted bool
+	baseContent []byte
+}
+
+// SetOverlayMode makes write, edit, and delete_file stage their changes in a per-session overlay
+// that only commit_changes writes to disk.
+func (s *State) SetOverlayMode(enabled bool) {
+	s.Mu.Lock()
+	s.OverlayMode = enabled
+	s.Mu.Unlock()
+}
+
+func (s *State) overlayEnabled() bool {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.OverlayMode
+}
+
+// checkOverlay refuses tools that change files directly while overlay mode is on, since their
+// changes would bypass the overlay.
+func (s *State) checkOverlay(tool string) error {
+	if !s.overlayEnabled() {
+		return nil
+	}
+	return fmt.Errorf("%s changes files directly, which overlay mode does not allow. Use write, edit, or delete_file, whose changes are staged until commit_changes", tool)
+}
+
+// staged returns the calling session's staged change to path, if any.
+func (s *State) staged(ctx context.Context, path string) (*stagedFile, bool) {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	f, ok := s.Overlays[sessionIDFromContext(ctx)][path]
+	return f, ok
+}
+
+// readCurrent returns path's content as the calling session sees it: staged content in overlay
+// mode, the file on disk otherwise. exists is false for missing and staged-deleted files.
+func (s *State) readCurrent(ctx context.Context, path string) (content []byte, exists bool, err error) {
+	if f, ok := s.staged(ctx, path); ok {
+		return f.data, !f.deleted, nil
+	}
+	content, err = s.filesystem().ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	return content, err == nil, err
+}
+
+// stageFile records new content for path, or its deletion, in the calling session's overlay. The
+// file counts as read at its staged content, so it can be changed again without a fresh read.
+func (s *State) stageFile(ctx context.Context, path string, data []byte, perm os.FileMode, deleted bool) {
+	session := sessionIDFromContext(ctx)
+	var baseContent []byte
+	baseErr := error(nil)
+	s.Mu.RLock()
+	_, known := s.Overlays[session][path]
+	s.Mu.RUnlock()
+	if !known {
+		baseContent, baseErr = os.ReadFile(path)
+	}
+
+	now := time.Now()
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	overlay := s.Overlays[session]
+	if overlay == nil {
+		overlay = make(map[string]*stagedFile)
+		s.Overlays[session] = overlay
+	}
+	s.useSession(session)
+	f := overlay[path]
+	if f == nil {
+		f = &stagedFile{baseExisted: baseErr == nil, baseContent: baseContent}
+		overlay[path] = f
+	}
+	// A file created in the overlay and deleted again leaves nothing to commit.
+	if deleted && !f.baseExisted {
+		delete(overlay, path)
+		delete(s.ReadFiles, path)
+		return
+	}
+	f.data, f.deleted, f.staged = bytes.Clone(data), deleted, now
+	if perm != 0 {
+		f.perm = perm
+	}
+	if deleted {
+		delete(s.ReadFiles, path)
+	} else {
+		s.ReadFiles[path] = now
+	}
+}
+
+// stageWrite is write in overlay mode: the content is staged rather than written, under the same
+// read-first rule as a write to disk. Confirmation and backups happen when it is committed.
+func (s *State) stageWrite(ctx context.Context, resolved, content string, opts writeOptions) (string, error) {
+	existing, exists, err := s.readCurrent(ctx, resolved)
+	if err != nil {
+		return "", fmt.Errorf("Cannot read file: %s", err)
+	}
+	if exists && opts.IfNotExists {
+		return "", fileExistsError(resolved)
+	}
+	if _, staged := s.staged(ctx, resolved); !staged {
+		if err := s.validateFileForWrite(resolved); err != nil {
+			return "", err
+		}
+	}
+	var perm os.FileMode
+	if opts.Mode != "" {
+		if perm, err = parseFileMode(opts.Mode); err != nil {
+			return "", err
+		}
+	}
+	data, formatNote, err := s.prepareWriteData(ctx, resolved, content, opts, existing, exists)
+	if err != nil {
+		return "", err
+	}
+	s.stageFile(ctx, resolved, data, perm, false)
+
+	message := "File creation staged at: " + resolved
+	if exists {
+		message = "File update staged at: " + resolved
+	}
+	message += " (not on disk until commit_changes)"
+	if formatNote != "" {
+		message += "\n" + formatNote
+	}
+	return message, nil
+}
+
+// stageDelete is delete_file in overlay mode. Only files can be staged for deletion.
+func (s *State) stageDelete(ctx context.Context, resolved string) (string, error) {
+	if info, err := os.Lstat(resolved); err == nil && info.IsDir() {
+		return "", fmt.Errorf("overlay mode cannot stage the deletion of a directory; only files can be deleted")
+	}
+	_, exists, err := s.readCurrent(ctx, resolved)
+	if err != nil {
+		return "", fmt.Errorf("Cannot read file: %s", err)
+	}
+	if !exists {
+		return "", fmt.Errorf("file does not exist")
+	}
+	s.stageFile(ctx, resolved, nil, 0, true)
+	return "File deletion staged: " + resolved + " (not on disk until commit_changes)", nil
+}
+
+// readStaged is read for a file with a staged change, returning its staged content as numbered
+// lines.
+func (s *State) readStaged(ctx context.Context, resolved string, f *stagedFile, offset, limit int64, annotate string) (string, error) {
+	if f.deleted {
+		return "", fmt.Errorf("file does not exist (its deletion is staged)")
+	}
+	if annotate != "" {
+		return "", fmt.Errorf("annotate is not supported for files with staged changes")
+	}
+	if limit == 0 {
+		if err := s.checkFileSize(ctx, int64(len(f.data)), "read"); err != nil {
+			return "", err
+		}
+	}
+	if len(f.data) == 0 {
+		return "<system-reminder>Warning: the file exists but the contents are empty.</system-reminder>", nil
+	}
+	mtype := mimetype.Detect(f.data)
+	if !mtype.Is("text/plain") && (mtype.Parent() == nil || !mtype.Parent().Is("text/plain")) {
+		return fmt.Sprintf("[Binary file: %s (%s), %d bytes. Use format \"hex\" or \"base64\" to inspect its bytes]", resolved, mtype.String(), len(f.data)), nil
+	}
+
+	lines, totalLines, err := readLines(bytes.NewReader(f.data), int(offset), int(limit))
+	if err != nil {
+		return "", fmt.Errorf("Cannot read file: %s", err)
+	}
+	startLine, endLine := calculateLineRange(totalLines, int(offset), int(limit))
+	if offset > 0 && (startLine < 1 || startLine > totalLines) {
+		return fmt.Sprintf(
+			"<system-reminder>Warning: the file exists but is shorter than the provided offset (%d). The file has %d lines.</system-reminder>",
+			startLine,
+			totalLines,
+		), nil
+	}
+	result := catN(lines[:endLine-startLine+1], startLine)
+	if err := checkOutputSize(ctx, result, "read"); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// readStagedBytes is read's base64 and hex formats for a file with a staged change.
+func (s *State) readStagedBytes(ctx context.Context, resolved string, f *stagedFile, format string, offset, limit int64) (string, error) {
+	if f.deleted {
+		return "", fmt.Errorf("file does not exist (its deletion is staged)")
+	}
+	size := int64(len(f.data))
+	if limit == 0 {
+		if err := s.checkFileSize(ctx, size, "read"); err != nil {
+			return "", err
+		}
+	}
+	if offset > size {
+		return "", fmt.Errorf("offset %d is beyond the end of the file (%d bytes)", offset, size)
+	}
+	end := size
+	if limit > 0 && offset+limit < size {
+		end = offset + limit
+	}
+	return encodeBytes(ctx, f.data[offset:end], format, offset)
+}
+
+// stagedPaths returns the paths of the calling session's staged changes, sorted, restricted to
+// paths when any are given.
+func (s *State) stagedPaths(ctx context.Context, paths []string) ([]string, error) {
+	resolvedPaths := make([]string, 0, len(paths))
+	for _, p := range paths {
+		resolved, err := s.resolvePath(p)
+		if err != nil {
+			return nil, err
+		}
+		resolvedPaths = append(resolvedPaths, resolved)
+	}
+	s.Mu.RLock()
+	overlay := s.Overlays[sessionIDFromContext(ctx)]
+	var selected []string
+	if len(paths) == 0 {
+		for p := range overlay {
+			selected = append(selected, p)
+		}
+	} else {
+		for _, resolved := range resolvedPaths {
+			if _, ok := overlay[resolved]; !ok {
+				s.Mu.RUnlock()
+				return nil, fmt.Errorf("no staged change to %s", resolved)
+			}
+			selected = append(selected, resolved)
+		}
+	}
+	s.Mu.RUnlock()
+	sort.Strings(selected)
+	return selected, nil
+}
+
+func (s *State) executeCommitChanges(ctx context.Context, paths []string, dryRun, force, backup bool) (string, error) {
+	selected, err := s.stagedPaths(ctx, paths)
+	if err != nil {
+		return "", err
+	}
+	if len(selected) == 0 {
+		return "No staged changes.", nil
+	}
+	defer s.lockPaths(ctx, selected...)()
+
+	// Refuse to overwrite changes made on disk since the files were staged, which committing would
+	// silently discard.
+	var conflicts []string
+	for _, p := range selected {
+		f, _ := s.staged(ctx, p)
+		current, err := os.ReadFile(p)
+		if (err == nil) != f.baseExisted || !bytes.Equal(current, f.baseContent) {
+			conflicts = append(conflicts, p)
+		}
+	}
+	if len(conflicts) > 0 && !force && !dryRun {
+		return "", fmt.Errorf("%s changed on disk after being staged; discard the staged changes, or set force to true to overwrite them", strings.Join(conflicts, ", "))
+	}
+
+	if dryRun {
+		var b strings.Builder
+		for _, p := range selected {
+			f, _ := s.staged(ctx, p)
+			current, _ := os.ReadFile(p)
+			oldName, newName, after := p, p, f.data
+			if f.deleted {
+				newName, after = "/dev/null", nil
+			}
+			if _, err := os.Lstat(p); err != nil {
+				oldName = "/dev/null"
+			}
+			b.WriteString(renderUnified(oldName, newName, diffHunks(diffLines(splitDiffLines(current), splitDiffLines(after)), 3)))
+		}
+		output := b.String()
+		if len(conflicts) > 0 {
+			output += fmt.Sprintf("\nChanged on disk since staged, so committing requires force: %s", strings.Join(conflicts, ", "))
+		}
+		if err := checkOutputSize(ctx, output, "commit_changes"); err != nil {
+			return "", err
+		}
+		return output, nil
+	}
+
+	if err := s.confirmWrite(ctx, "commit_changes", selected...); err != nil {
+		return "", err
+	}
+	for _, p := range selected {
+		if f, _ := s.staged(ctx, p); f.deleted {
+			if err := s.confirmDelete(ctx, p, false); err != nil {
+				return "", err
+			}
+		}
+	}
+	var summary strings.Builder
+	committed := 0
+	for _, p := range selected {
+		f, _ := s.staged(ctx, p)
+		status, err := s.commitStaged(ctx, p, f, backup)
+		if err != nil {
+			return "", fmt.Errorf("Committed %d of %d changes%s\nCannot commit %s: %s; it and the remaining changes are still staged", committed, len(selected), summary.String(), p, err)
+		}
+		committed++
+		fmt.Fprintf(&summary, "\n- %s (%s)", p, status)
+	}
+	return fmt.Sprintf("Committed %d changes:%s", committed, summary.String()), nil
+}
+
+// commitStaged writes one staged change to disk and removes it from the overlay.
+func (s *State) commitStaged(ctx context.Context, path string, f *stagedFile, backup bool) (string, error) {
+	existing, readErr := os.ReadFile(path)
+	existed := readErr == nil
+	if backup && existed {
+		if _, err := s.backupFile(ctx, path, !f.deleted); err != nil {
+			return "", err
+		}
+	}
+
+	status := "updated"
+	if f.deleted {
+		status = "deleted"
+		s.noteChange(ctx, "commit_changes", path)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("Cannot delete: %s", err)
+		}
+		s.forgetPath(path)
+	} else {
+		if !existed {
+			status = "created"
+		}
+		_ = os.MkdirAll(filepath.Dir(path), 0o750)
+		if err := s.writeFile(path, f.data, f.perm); err != nil {
+			return "", err
+		}
+		s.recordEdit(ctx, "commit_changes", path, existed, existing, f.data)
+	}
+
+	s.Mu.Lock()
+	delete(s.Overlays[sessionIDFromContext(ctx)], path)
+	if info, err := os.Stat(path); err == nil && !f.deleted {
+		if _, tracked := s.ReadFiles[path]; tracked {
+			s.ReadFiles[path] = info.ModTime()
+		}
+	}
+	s.Mu.Unlock()
+	return status, nil
+}
+
+func (s *State) executeDiscardChanges(ctx context.Context, paths []string) (string, error) {
+	selected, err := s.stagedPaths(ctx, paths)
+	if err != nil {
+		return "", err
+	}
+	if len(selected) == 0 {
+		return "No staged changes.", nil
+	}
+	s.Mu.Lock()
+	for _, p := range selected {
+		delete(s.Overlays[sessionIDFromContext(ctx)], p)
+	}
+	s.Mu.Unlock()
+	// What was read was the staged content, so the files on disk must be read afresh.
+	for _, p := range selected {
+		s.forgetPath(p)
+	}
+	return fmt.Sprintf("Discarded %d staged changes:\n- %s", len(selected), strings.Join(selected, "\n- ")), nil
+}
+
+var CommitChangesTool = sdk.Tool{
+	Name:        "commit_changes",
+	Description: "Writes the changes staged in overlay mode to disk.\n\nUsage:\n- With the server's --overlay mode, write, edit, and delete_file stage their changes in this session's overlay instead of changing files; read shows the staged content, while bash, grep, and glob still see the files on disk.\n- Set dry_run to true to review the staged changes as a diff against the files on disk without committing.\n- Pass paths (absolute) to commit only some of the staged files; by default all are committed.\n- Files changed on disk since they were staged are not overwritten unless force is true.\n- Set backup to true to save the previous content of each file, restorable with restore_backup.\n- Use discard_changes to drop staged changes instead.",
+}
+
+type CommitChangesInput struct {
+	Paths  []string `json:"paths,omitempty" jsonschema:"Absolute paths of the staged files to commit. Defaults to all of them"`
+	DryRun bool     `json:"dry_run,omitempty" jsonschema:"Show the staged changes as a diff without writing them"`
+	Force  bool     `json:"force,omitempty" jsonschema:"Commit even files that changed on disk since they were staged, overwriting those changes"`
+	Backup *bool    `json:"backup,omitempty" jsonschema:"Save each file's content before overwriting it, restorable with restore_backup. Defaults to the server setting"`
+}
+type CommitChangesOutput struct {
+	Result string `json:"result"`
+}
+
+func CommitChanges(ctx context.Context, req *sdk.CallToolRequest, args CommitChangesInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeCommitChanges(withSession(ctx, req), args.Paths, args.DryRun, args.Force, server.useBackup(args.Backup))
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &CommitChangesOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
+
+var DiscardChangesTool = sdk.Tool{
+	Name:        "discard_changes",
+	Description: "Drops changes staged in overlay mode, leaving the files on disk as they are.\n\nUsage:\n- Pass paths (absolute) to discard only some of the staged files; by default all are discarded.\n- Read discarded files again before editing them.",
+}
+
+type DiscardChangesInput struct {
+	Paths []string `json:"paths,omitempty" jsonschema:"Absolute paths of the staged files to discard. Defaults to all of them"`
+}
+type DiscardChangesOutput struct {
+	Result string `json:"result"`
+}
+
+func DiscardChanges(ctx context.Context, req *sdk.CallToolRequest, args DiscardChangesInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeDiscardChanges(withSession(ctx, req), args.Paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &DiscardChangesOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayMode(t *testing.T) {
+	state := NewState()
+	state.SetOverlayMode(true)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	edited := filepath.Join(dir, "edited.txt")
+	require.NoError(t, os.WriteFile(edited, []byte("one\ntwo\n"), 0o644))
+	_, err := state.executeRead(ctx, edited, 0, 0)
+	require.NoError(t, err)
+	_, err = state.executeEdit(ctx, edited, editItem{OldString: "two", NewString: "2"}, false, false)
+	require.NoError(t, err)
+	// A second edit applies on top of the staged content.
+	_, err = state.executeEdit(ctx, edited, editItem{OldString: "one", NewString: "1"}, false, false)
+	require.NoError(t, err)
+
+	created := filepath.Join(dir, "sub", "created.txt")
+	result, err := callWrite(t, state, WriteInput{FilePath: created, Content: "new\n"})
+	require.NoError(t, err)
+	assert.Contains(t, result, "staged")
+
+	deleted := filepath.Join(dir, "deleted.txt")
+	require.NoError(t, os.WriteFile(deleted, []byte("bye\n"), 0o644))
+	_, err = state.executeDeleteFile(ctx, deleted, false, false)
+	require.NoError(t, err)
+
+	// Nothing has reached the disk, but read sees the staged content.
+	content, _ := os.ReadFile(edited)
+	assert.Equal(t, "one\ntwo\n", string(content))
+	assert.NoFileExists(t, created)
+	assert.FileExists(t, deleted)
+	result, err = state.executeRead(ctx, edited, 0, 0)
+	require.NoError(t, err)
+	assert.Contains(t, result, "1\n")
+	assert.Contains(t, result, "2")
+	_, err = state.executeRead(ctx, deleted, 0, 0)
+	assert.ErrorContains(t, err, "does not exist")
+
+	_, err = state.executeMoveFile(ctx, edited, filepath.Join(dir, "moved.txt"))
+	assert.ErrorContains(t, err, "overlay mode")
+
+	result, err = state.executeCommitChanges(ctx, nil, true, false, false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "+1")
+	assert.Contains(t, result, "+++ /dev/null")
+	assert.NoFileExists(t, created)
+
+	result, err = state.executeCommitChanges(ctx, nil, false, false, false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "Committed 3 changes")
+	content, _ = os.ReadFile(edited)
+	assert.Equal(t, "1\n2\n", string(content))
+	content, _ = os.ReadFile(created)
+	assert.Equal(t, "new\n", string(content))
+	assert.NoFileExists(t, deleted)
+
+	result, err = state.executeCommitChanges(ctx, nil, false, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, "No staged changes.", result)
+}
+
+func TestOverlayModeDiscard(t *testing.T) {
+	state := NewState()
+	state.SetOverlayMode(true)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(path, []byte("original\n"), 0o644))
+	_, err := state.executeRead(ctx, path, 0, 0)
+	require.NoError(t, err)
+	_, err = callWrite(t, state, WriteInput{FilePath: path, Content: "speculative\n"})
+	require.NoError(t, err)
+
+	result, err := state.executeDiscardChanges(ctx, []string{path})
+	require.NoError(t, err)
+	assert.Contains(t, result, "Discarded 1 staged changes")
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, "original\n", string(content))
+
+	// The staged content was what had been read, so the file must be read again.
+	_, err = callWrite(t, state, WriteInput{FilePath: path, Content: "again\n"})
+	assert.ErrorContains(t, err, "read it first")
+
+	_, err = state.executeDiscardChanges(ctx, []string{path})
+	assert.ErrorContains(t, err, "no staged change")
+}
+
+func TestOverlayModeCommitConflict(t *testing.T) {
+	state := NewState()
+	state.SetOverlayMode(true)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(path, []byte("original\n"), 0o644))
+	_, err := state.executeRead(ctx, path, 0, 0)
+	require.NoError(t, err)
+	_, err = callWrite(t, state, WriteInput{FilePath: path, Content: "staged\n"})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte("changed elsewhere\n"), 0o644))
+
+	_, err = state.executeCommitChanges(ctx, nil, false, false, false)
+	assert.ErrorContains(t, err, "changed on disk after being staged")
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, "changed elsewhere\n", string(content))
+
+	_, err = state.executeCommitChanges(ctx, nil, false, true, false)
+	require.NoError(t, err)
+	content, _ = os.ReadFile(path)
+	assert.Equal(t, "staged\n", string(content))
+}
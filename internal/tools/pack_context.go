@@ -0,0 +1,392 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+	"github.com/gabriel-vasile/mimetype"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// packFile is a file considered for a pack, with its path relative to the pack's root.
+type packFile struct {
+	path    string
+	rel     string
+	content string
+}
+
+func (s *State) executePackContext(ctx context.Context, root string, include, exclude []string, maxFileLines, tokenBudget int, order string) (string, error) {
+	if root == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	resolved, err := s.resolvePath(root)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("path is not a directory: %s", resolved)
+	}
+	if len(include) == 0 {
+		include = []string{"**/*"}
+	}
+	for _, pattern := range slices.Concat(include, exclude) {
+		if strings.Contains(pattern, "\x00") || !doublestar.ValidatePattern(pattern) {
+			return "", fmt.Errorf("Invalid glob pattern: %s", pattern)
+		}
+	}
+	switch order {
+	case "":
+		order = "dependency"
+	case "dependency", "path":
+	default:
+		return "", fmt.Errorf("Invalid order: %s. Must be one of: dependency, path.", order)
+	}
+	if maxFileLines < 0 || tokenBudget < 0 {
+		return "", fmt.Errorf("max_file_lines and token_budget cannot be negative")
+	}
+	tokenizer, count, err := s.tokenCounter("")
+	if err != nil {
+		return "", err
+	}
+	limits := limitsFromContext(ctx)
+	if tokenBudget == 0 {
+		tokenBudget = limits.maxOutputSize / 4
+	}
+
+	// Collect the files matching any include pattern and no exclude pattern.
+	s.Mu.RLock()
+	denied := s.DeniedPaths
+	maxFileSize := s.MaxFileSize
+	s.Mu.RUnlock()
+	matched := map[string]fileInfo{}
+	for _, pattern := range include {
+		walker := newGlobWalker(vfs.OS{}, resolved, pattern, maxGlobMatches, false)
+		walker.denied = denied
+		matches, _ := walker.run(ctx)
+		for _, match := range matches {
+			matched[match.path] = match
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	var files []packFile
+	var skipped []string
+	overLimit := 0
+	rels := make([]string, 0, len(matched))
+	for rel := range matched {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	for _, rel := range rels {
+		if slices.ContainsFunc(exclude, func(pattern string) bool {
+			ok, _ := doublestar.Match(pattern, rel)
+			return ok
+		}) {
+			continue
+		}
+		if len(files) >= limits.maxResults {
+			overLimit++
+			continue
+		}
+		if matched[rel].size > int64(maxFileSize) {
+			skipped = append(skipped, rel+" (too large)")
+			continue
+		}
+		full := filepath.Join(resolved, filepath.FromSlash(rel))
+		content, err := os.ReadFile(full)
+		if err != nil {
+			skipped = append(skipped, rel+" (unreadable)")
+			continue
+		}
+		if len(content) > 0 && !isTextMIME(mimetype.Detect(content)) {
+			skipped = append(skipped, rel+" (binary)")
+			continue
+		}
+		files = append(files, packFile{path: full, rel: rel, content: string(content)})
+	}
+	if len(files) == 0 {
+		return "No files found", nil
+	}
+	if order == "dependency" {
+		files = dependencyOrder(resolved, files)
+	}
+
+	// Add files in order while they fit both the token budget and the output size limit. A file
+	// that does not fit is left out, but smaller files after it may still be packed.
+	var body strings.Builder
+	var omitted, truncated []string
+	tokens, packed := 0, 0
+	headerRoom := 2048 + 64*len(files)
+	for _, f := range files {
+		section, wasTruncated := packSection(f, maxFileLines)
+		sectionTokens, err := count(ctx, section)
+		if err != nil {
+			return "", err
+		}
+		if tokens+sectionTokens > tokenBudget || body.Len()+len(section)+headerRoom > limits.maxOutputSize {
+			omitted = append(omitted, f.rel)
+			continue
+		}
+		body.WriteString(section)
+		tokens += sectionTokens
+		packed++
+		if wasTruncated {
+			truncated = append(truncated, f.rel)
+		}
+		s.Mu.Lock()
+		if info, err := os.Stat(f.path); err == nil {
+			s.ReadFiles[f.path] = info.ModTime()
+		}
+		s.Mu.Unlock()
+	}
+
+	var header strings.Builder
+	fmt.Fprintf(&header, "Packed %d of %d files from %s in %s order (~%d of %d tokens, counted with the %s tokenizer).\n", packed, len(files), resolved, order, tokens, tokenBudget, tokenizer)
+	if len(truncated) > 0 {
+		fmt.Fprintf(&header, "Truncated to %d lines: %s\n", maxFileLines, strings.Join(truncated, ", "))
+	}
+	if len(omitted) > 0 {
+		fmt.Fprintf(&header, "Omitted to stay within the budget: %s\n", strings.Join(omitted, ", "))
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(&header, "Skipped: %s\n", strings.Join(skipped, ", "))
+	}
+	if overLimit > 0 {
+		fmt.Fprintf(&header, "%d more files matched beyond the %d-file limit; narrow include to pack them.\n", overLimit, limits.maxResults)
+	}
+	output := header.String() + body.String()
+	if err := checkOutputSize(ctx, output, "pack_context"); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// packSection renders a file for the pack under a header naming it, with line numbers as the Read
+// tool shows them, cut to maxLines when that is set.
+func packSection(f packFile, maxLines int) (string, bool) {
+	lines := strings.Split(f.content, "\n")
+	total := len(lines)
+	if total > 0 && lines[total-1] == "" {
+		lines, total = lines[:total-1], total-1
+	}
+	truncated := maxLines > 0 && total > maxLines
+	if truncated {
+		lines = lines[:maxLines]
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n==> %s <==\n", f.path)
+	if total == 0 {
+		b.WriteString("(empty file)\n")
+		return b.String(), false
+	}
+	b.WriteString(catN(lines, 1))
+	b.WriteString("\n")
+	if truncated {
+		fmt.Fprintf(&b, "... %d more lines; read them with offset %d\n", total-maxLines, maxLines+1)
+	}
+	return b.String(), truncated
+}
+
+// dependencyOrder sorts files so that each comes after the files it imports, falling back to path
+// order between unrelated files and within import cycles.
+func dependencyOrder(root string, files []packFile) []packFile {
+	deps := packDependencies(root, files)
+	byRel := make(map[string]packFile, len(files))
+	for _, f := range files {
+		byRel[f.rel] = f
+	}
+	ordered := make([]packFile, 0, len(files))
+	visited := map[string]bool{}
+	var visit func(rel string)
+	visit = func(rel string) {
+		if visited[rel] {
+			return
+		}
+		visited[rel] = true
+		for _, dep := range deps[rel] {
+			visit(dep)
+		}
+		ordered = append(ordered, byRel[rel])
+	}
+	for _, f := range files {
+		visit(f.rel)
+	}
+	return ordered
+}
+
+var (
+	jsImportPattern     = regexp.MustCompile(`(?:\bfrom\s*|\bimport\s*\(?\s*|\brequire\(\s*)['"](\.{1,2}/[^'"]+)['"]`)
+	pythonImportPattern = regexp.MustCompile(`(?m)^\s*(?:from\s+(\.*)([\w.]*)\s+import\s+([\w, ]+)|import\s+([\w.]+))`)
+	cIncludePattern     = regexp.MustCompile(`(?m)^\s*#\s*include\s*"([^"]+)"`)
+	rustModPattern      = regexp.MustCompile(`(?m)^\s*(?:pub(?:\([^)]*\))?\s+)?mod\s+(\w+)\s*;`)
+)
+
+// packDependencies finds, for each file, the other packed files it imports: Go packages within
+// the module, relative JavaScript and TypeScript imports, Python imports of modules under root,
+// quoted C and C++ includes, and Rust mod declarations.
+func packDependencies(root string, files []packFile) map[string][]string {
+	present := make(map[string]bool, len(files))
+	byDir := map[string][]string{}
+	for _, f := range files {
+		present[f.rel] = true
+		if strings.HasSuffix(f.rel, ".go") && !strings.HasSuffix(f.rel, "_test.go") {
+			byDir[path.Dir(f.rel)] = append(byDir[path.Dir(f.rel)], f.rel)
+		}
+	}
+	// first returns the first of several possible paths that is in the pack.
+	first := func(candidates ...string) []string {
+		for _, c := range candidates {
+			if c = path.Clean(c); present[c] {
+				return []string{c}
+			}
+		}
+		return nil
+	}
+	modulePath, moduleDir := goModule(root)
+
+	deps := map[string][]string{}
+	for _, f := range files {
+		dir := path.Dir(f.rel)
+		var found []string
+		switch strings.ToLower(path.Ext(f.rel)) {
+		case ".go":
+			parsed, err := parser.ParseFile(token.NewFileSet(), f.path, f.content, parser.ImportsOnly)
+			if err != nil || modulePath == "" {
+				break
+			}
+			for _, spec := range parsed.Imports {
+				importPath, _ := strconv.Unquote(spec.Path.Value)
+				rest, ok := strings.CutPrefix(importPath, modulePath)
+				if !ok || (rest != "" && rest[0] != '/') {
+					continue
+				}
+				rel, err := filepath.Rel(root, filepath.Join(moduleDir, filepath.FromSlash(rest)))
+				if err == nil {
+					found = append(found, byDir[filepath.ToSlash(rel)]...)
+				}
+			}
+		case ".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx", ".vue", ".svelte":
+			for _, m := range jsImportPattern.FindAllStringSubmatch(f.content, -1) {
+				target := path.Join(dir, m[1])
+				stem := strings.TrimSuffix(target, path.Ext(target))
+				var candidates []string
+				candidates = append(candidates, target)
+				for _, ext := range []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs"} {
+					candidates = append(candidates, target+ext, stem+ext, target+"/index"+ext)
+				}
+				found = append(found, first(candidates...)...)
+			}
+		case ".py", ".pyi":
+			for _, m := range pythonImportPattern.FindAllStringSubmatch(f.content, -1) {
+				var bases []string
+				switch {
+				case m[4] != "":
+					bases = []string{strings.ReplaceAll(m[4], ".", "/")}
+				case m[1] != "":
+					// Relative: one dot is this package, each further dot a parent.
+					base := dir
+					for range len(m[1]) - 1 {
+						base = path.Dir(base)
+					}
+					bases = []string{path.Join(base, strings.ReplaceAll(m[2], ".", "/"))}
+				default:
+					bases = []string{strings.ReplaceAll(m[2], ".", "/")}
+				}
+				// from pkg import mod names a module as often as an attribute.
+				if m[3] != "" {
+					for _, name := range strings.Split(m[3], ",") {
+						// "name as alias" imports name.
+						if fields := strings.Fields(name); len(fields) > 0 {
+							bases = append(bases, path.Join(bases[0], fields[0]))
+						}
+					}
+				}
+				for _, base := range bases {
+					found = append(found, first(base+".py", base+"/__init__.py", base+".pyi")...)
+				}
+			}
+		case ".c", ".cc", ".cpp", ".cxx", ".h", ".hh", ".hpp", ".hxx", ".m", ".mm":
+			for _, m := range cIncludePattern.FindAllStringSubmatch(f.content, -1) {
+				found = append(found, first(path.Join(dir, m[1]), m[1])...)
+			}
+		case ".rs":
+			// Modules declared in lib.rs, main.rs, and mod.rs live beside them; those declared in
+			// other files live in a directory named after the file.
+			modDir := dir
+			if base := path.Base(f.rel); base != "lib.rs" && base != "main.rs" && base != "mod.rs" {
+				modDir = strings.TrimSuffix(f.rel, ".rs")
+			}
+			for _, m := range rustModPattern.FindAllStringSubmatch(f.content, -1) {
+				found = append(found, first(path.Join(modDir, m[1]+".rs"), path.Join(modDir, m[1], "mod.rs"))...)
+			}
+		}
+		found = slices.DeleteFunc(found, func(dep string) bool { return dep == f.rel })
+		sort.Strings(found)
+		deps[f.rel] = slices.Compact(found)
+	}
+	return deps
+}
+
+// goModule returns the module path and directory of the go.mod governing root, or empty strings
+// outside a Go module.
+func goModule(root string) (modulePath, moduleDir string) {
+	for dir := root; ; dir = filepath.Dir(dir) {
+		if content, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+			for _, line := range strings.Split(string(content), "\n") {
+				if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+					return strings.Trim(strings.TrimSpace(rest), `"`), dir
+				}
+			}
+			return "", ""
+		}
+		if filepath.Dir(dir) == dir {
+			return "", ""
+		}
+	}
+}
+
+var PackContextTool = sdk.Tool{
+	Name:        "pack_context",
+	Description: "Reads a bundle of files under a directory in one call, instead of one Read per file: a small module, a feature's files, or everything matching a few globs.\n\nUsage:\n- path is the directory to pack; include and exclude are glob patterns relative to it (include defaults to everything). Dependency, build, and VCS directories, binary files, and files over the size limit are skipped.\n- Files are shown with line numbers as the Read tool shows them, each under a ==> path <== header, and count as read for later edits.\n- order \"dependency\" (the default) puts each file after the files it imports, for Go, JavaScript/TypeScript, Python, C/C++, and Rust, so definitions come before their uses; \"path\" sorts by path.\n- Files are added until token_budget (default: the output limit) is reached; files that do not fit are listed as omitted, to be read separately. Set max_file_lines to include only the start of long files.",
+}
+
+type PackContextInput struct {
+	Path         string   `json:"path" jsonschema:"The absolute path of the directory to pack"`
+	Include      []string `json:"include,omitempty" jsonschema:"Glob patterns, relative to path, of the files to pack (default all files)"`
+	Exclude      []string `json:"exclude,omitempty" jsonschema:"Glob patterns, relative to path, of files to leave out, e.g. **/*_test.go"`
+	MaxFileLines int      `json:"max_file_lines,omitempty" jsonschema:"Include only the first this many lines of each file"`
+	TokenBudget  int      `json:"token_budget,omitempty" jsonschema:"Stop adding files at about this many tokens. Defaults to the output limit"`
+	Order        string   `json:"order,omitempty" jsonschema:"File order: 'dependency' (default), imported files first, or 'path'"`
+}
+type PackContextOutput struct {
+	Result string `json:"result"`
+}
+
+func PackContext(ctx context.Context, req *sdk.CallToolRequest, args PackContextInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executePackContext(ctx, args.Path, args.Include, args.Exclude, args.MaxFileLines, args.TokenBudget, args.Order)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &PackContextOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePackFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+// packedFiles lists the files in a pack in the order they appear, relative to root.
+func packedFiles(t *testing.T, root, pack string) []string {
+	t.Helper()
+	var files []string
+	for _, m := range regexp.MustCompile(`(?m)^==> (.+) <==$`).FindAllStringSubmatch(pack, -1) {
+		rel, err := filepath.Rel(root, m[1])
+		require.NoError(t, err)
+		files = append(files, filepath.ToSlash(rel))
+	}
+	return files
+}
+
+func TestPackDependencies(t *testing.T) {
+	root := t.TempDir()
+	writePackFiles(t, root, map[string]string{
+		"go.mod":                    "module example.com/app\n\ngo 1.22\n",
+		"main.go":                   "package main\n\nimport (\n\t\"fmt\"\n\t\"example.com/app/store\"\n)\n",
+		"store/store.go":            "package store\n\nimport \"example.com/app/store/codec\"\n",
+		"store/codec/codec.go":      "package codec\n",
+		"store/store_test.go":       "package store\n",
+		"web/app.ts":                "import { api } from './api';\nconst util = require(\"../lib/util.js\");\n",
+		"web/api/index.ts":          "export const api = 1;\n",
+		"lib/util.js":               "module.exports = {};\n",
+		"py/pkg/__init__.py":        "",
+		"py/pkg/main.py":            "from . import models\nfrom .db import connect as c\nimport os\n",
+		"py/pkg/models.py":          "",
+		"py/pkg/db.py":              "",
+		"native/main.c":             "#include <stdio.h>\n#include \"parse.h\"\n",
+		"native/parse.h":            "",
+		"crate/src/lib.rs":          "pub mod net;\nmod util;\n",
+		"crate/src/net.rs":          "mod tcp;\n",
+		"crate/src/net/tcp.rs":      "",
+		"crate/src/util/mod.rs":     "",
+		"crate/src/unrelated/x.rs":  "",
+		"store/codec/codec_test.go": "package codec\n",
+	})
+	var files []packFile
+	require.NoError(t, filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		content, _ := os.ReadFile(path)
+		files = append(files, packFile{path: path, rel: filepath.ToSlash(rel), content: string(content)})
+		return nil
+	}))
+
+	deps := packDependencies(root, files)
+	assert.Equal(t, []string{"store/store.go"}, deps["main.go"])
+	assert.Equal(t, []string{"store/codec/codec.go"}, deps["store/store.go"])
+	assert.Equal(t, []string{"lib/util.js", "web/api/index.ts"}, deps["web/app.ts"])
+	assert.Equal(t, []string{"py/pkg/__init__.py", "py/pkg/db.py", "py/pkg/models.py"}, deps["py/pkg/main.py"])
+	assert.Equal(t, []string{"native/parse.h"}, deps["native/main.c"])
+	assert.Equal(t, []string{"crate/src/net.rs", "crate/src/util/mod.rs"}, deps["crate/src/lib.rs"])
+	assert.Equal(t, []string{"crate/src/net/tcp.rs"}, deps["crate/src/net.rs"])
+
+	t.Run("go imports resolve from a root below the module", func(t *testing.T) {
+		sub := filepath.Join(root, "store")
+		var subFiles []packFile
+		for _, f := range files {
+			if rel, ok := strings.CutPrefix(f.rel, "store/"); ok {
+				subFiles = append(subFiles, packFile{path: f.path, rel: rel, content: f.content})
+			}
+		}
+		assert.Equal(t, []string{"codec/codec.go"}, packDependencies(sub, subFiles)["store.go"])
+	})
+}
+
+func TestPackContext(t *testing.T) {
+	state := NewState()
+	ctx := context.Background()
+	root := t.TempDir()
+	writePackFiles(t, root, map[string]string{
+		"go.mod":            "module example.com/app\n",
+		"a_main.go":         "package main\n\nimport \"example.com/app/z\"\n\nfunc main() { z.Run() }\n",
+		"z/run.go":          "package z\n\nfunc Run() {}\n",
+		"z/run_test.go":     "package z\n",
+		"docs/long.md":      strings.Repeat("line\n", 50),
+		"node_modules/x.js": "ignored",
+		"logo.png":          "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+	})
+
+	t.Run("dependency order", func(t *testing.T) {
+		pack, err := state.executePackContext(ctx, root, []string{"**/*.go"}, nil, 0, 0, "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"z/run.go", "a_main.go", "z/run_test.go"}, packedFiles(t, root, pack))
+		assert.Contains(t, pack, "Packed 3 of 3 files")
+		assert.Contains(t, pack, "     3→func Run() {}\n")
+
+		// Packed files count as read, so they can be edited straight away.
+		assert.Contains(t, state.ReadFiles, filepath.Join(root, "z", "run.go"))
+	})
+
+	t.Run("path order and exclude", func(t *testing.T) {
+		pack, err := state.executePackContext(ctx, root, nil, []string{"**/*_test.go", "go.mod"}, 0, 0, "path")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a_main.go", "docs/long.md", "z/run.go"}, packedFiles(t, root, pack))
+		assert.Contains(t, pack, "Skipped: logo.png (binary)")
+		assert.NotContains(t, pack, "node_modules")
+	})
+
+	t.Run("per-file truncation", func(t *testing.T) {
+		pack, err := state.executePackContext(ctx, root, []string{"docs/*"}, nil, 10, 0, "")
+		require.NoError(t, err)
+		assert.Contains(t, pack, "Truncated to 10 lines: docs/long.md")
+		assert.Contains(t, pack, "    10→line\n... 40 more lines; read them with offset 11\n")
+		assert.NotContains(t, pack, "    11→")
+	})
+
+	t.Run("token budget", func(t *testing.T) {
+		pack, err := state.executePackContext(ctx, root, []string{"**/*.go", "docs/*"}, nil, 0, 200, "path")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a_main.go", "z/run.go", "z/run_test.go"}, packedFiles(t, root, pack))
+		assert.Contains(t, pack, "Omitted to stay within the budget: docs/long.md")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := state.executePackContext(ctx, "", nil, nil, 0, 0, "")
+		assert.ErrorContains(t, err, "path is required")
+		_, err = state.executePackContext(ctx, filepath.Join(root, "go.mod"), nil, nil, 0, 0, "")
+		assert.ErrorContains(t, err, "not a directory")
+		_, err = state.executePackContext(ctx, root, []string{"[z"}, nil, 0, 0, "")
+		assert.ErrorContains(t, err, "Invalid glob pattern")
+		_, err = state.executePackContext(ctx, root, nil, nil, 0, 0, "size")
+		assert.ErrorContains(t, err, "Invalid order")
+
+		result, err := state.executePackContext(ctx, root, []string{"*.rs"}, nil, 0, 0, "")
+		require.NoError(t, err)
+		assert.Equal(t, "No files found", result)
+	})
+}
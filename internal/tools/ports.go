@@ -0,0 +1,231 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultPortCheckTimeout = 2 * time.Second
+	maxPortCheckTimeout     = 30 * time.Second
+)
+
+type listeningSocket struct {
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	Port     int    `json:"port"`
+	PID      int    `json:"pid,omitempty"`
+	Process  string `json:"process,omitempty"`
+	Command  string `json:"command,omitempty"`
+}
+
+type portCheck struct {
+	Host      string            `json:"host"`
+	Port      int               `json:"port"`
+	Open      bool              `json:"open"`
+	Error     string            `json:"error,omitempty"`
+	LatencyMs int64             `json:"latency_ms,omitempty"`
+	Listeners []listeningSocket `json:"listeners,omitempty"`
+}
+
+type listeningPorts struct {
+	Sockets   []listeningSocket `json:"sockets"`
+	Count     int               `json:"count"`
+	Truncated bool              `json:"truncated,omitempty"`
+}
+
+func (s *State) executeCheckPort(ctx context.Context, host string, port int, timeoutMs int) (string, error) {
+	if port < 1 || port > 65535 {
+		return "", fmt.Errorf("port must be between 1 and 65535, got %d", port)
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	timeout := defaultPortCheckTimeout
+	if timeoutMs > 0 {
+		timeout = time.Duration(timeoutMs) * time.Millisecond
+	}
+	if timeout > maxPortCheckTimeout {
+		timeout = maxPortCheckTimeout
+	}
+
+	result := portCheck{Host: host, Port: port}
+	dialer := net.Dialer{Timeout: timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		result.Error = describeDialError(err)
+	} else {
+		result.Open = true
+		result.LatencyMs = time.Since(start).Milliseconds()
+		conn.Close()
+	}
+
+	// Only local ports can be traced to a process. A closed port can still have a listener bound
+	// to another address, which is itself the answer to "why can't I connect".
+	if isLocalHost(host) {
+		if sockets, err := listeningSockets(); err == nil {
+			for _, socket := range sockets {
+				if socket.Port == port {
+					result.Listeners = append(result.Listeners, socket)
+				}
+			}
+		}
+	}
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format port check: %s", err)
+	}
+	return string(jsonBytes), nil
+}
+
+// describeDialError reduces a dial failure to the reason that matters when debugging a port.
+func describeDialError(err error) string {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.As(err, &dnsErr):
+		return "cannot resolve host: " + dnsErr.Err
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timed out"
+	default:
+		return err.Error()
+	}
+}
+
+// isLocalHost reports whether host names this machine.
+func isLocalHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	if hostname, err := os.Hostname(); err == nil && host == hostname {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() || ip.IsUnspecified() {
+		return true
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *State) executeListeningPorts(ctx context.Context, port int) (string, error) {
+	if port < 0 || port > 65535 {
+		return "", fmt.Errorf("port must be between 1 and 65535, got %d", port)
+	}
+	sockets, err := listeningSockets()
+	if err != nil {
+		return "", err
+	}
+
+	result := listeningPorts{Sockets: []listeningSocket{}}
+	for _, socket := range sockets {
+		if port == 0 || socket.Port == port {
+			result.Sockets = append(result.Sockets, socket)
+		}
+	}
+	sort.Slice(result.Sockets, func(i, j int) bool {
+		a, b := result.Sockets[i], result.Sockets[j]
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Address < b.Address
+	})
+	if maxResults := limitsFromContext(ctx).maxResults; len(result.Sockets) > maxResults {
+		result.Sockets = result.Sockets[:maxResults]
+		result.Truncated = true
+	}
+	result.Count = len(result.Sockets)
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format listening ports: %s", err)
+	}
+	output := string(jsonBytes)
+	if err := checkOutputSize(ctx, output, "listening_ports"); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+var CheckPortTool = sdk.Tool{
+	Name:        "check_port",
+	Description: "Checks whether a TCP port accepts connections, and which process is listening on it.\n\nUsage:\n- host defaults to localhost; port is required\n- Reports open, or the reason the connection failed (connection refused, timed out, cannot resolve host)\n- For local hosts, lists the sockets listening on the port with the PID, name, and command line of the process holding each (Linux only; processes of other users may be unidentifiable)\n- Use this to debug \"address already in use\" errors or to wait for a server to come up, instead of parsing lsof or ss output via Bash",
+}
+
+type CheckPortInput struct {
+	Host    string `json:"host,omitempty" jsonschema:"The host to connect to. Defaults to localhost"`
+	Port    int    `json:"port" jsonschema:"The TCP port to check"`
+	Timeout int    `json:"timeout,omitempty" jsonschema:"Connection timeout in milliseconds (default 2000, max 30000)"`
+}
+type CheckPortOutput struct {
+	Result string `json:"result"`
+}
+
+func CheckPort(ctx context.Context, req *sdk.CallToolRequest, args CheckPortInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeCheckPort(ctx, args.Host, args.Port, args.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &CheckPortOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
+
+var ListeningPortsTool = sdk.Tool{
+	Name:        "listening_ports",
+	Description: "Lists the TCP sockets listening on this machine, sorted by port.\n\nUsage:\n- Each socket has its protocol (tcp or tcp6), bound address, port, and the PID, name, and command line of the process holding it where it can be identified\n- Set port to show only the listeners on that port\n- Linux only. Use this instead of parsing lsof, ss, or netstat output via Bash",
+}
+
+type ListeningPortsInput struct {
+	Port int `json:"port,omitempty" jsonschema:"Only list sockets listening on this port"`
+}
+type ListeningPortsOutput struct {
+	Result string `json:"result"`
+}
+
+func ListeningPorts(ctx context.Context, req *sdk.CallToolRequest, args ListeningPortsInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeListeningPorts(ctx, args.Port)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &ListeningPortsOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
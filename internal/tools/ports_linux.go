@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// tcpListenState is the st value /proc/net/tcp uses for sockets in the LISTEN state.
+const tcpListenState = "0A"
+
+// listeningSockets reports every listening TCP socket from /proc/net/tcp and /proc/net/tcp6, with
+// the process holding each one where its /proc/<pid>/fd entries are readable.
+func listeningSockets() ([]listeningSocket, error) {
+	var sockets []listeningSocket
+	byInode := make(map[string][]int)
+	for _, table := range []struct{ path, protocol string }{{"/proc/net/tcp", "tcp"}, {"/proc/net/tcp6", "tcp6"}} {
+		file, err := os.Open(table.path)
+		if err != nil {
+			if table.protocol == "tcp6" && os.IsNotExist(err) {
+				// Kernels built without IPv6 have no tcp6 table.
+				continue
+			}
+			return nil, fmt.Errorf("Cannot read socket table: %s", err)
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Scan() // header
+		for scanner.Scan() {
+			// Fields: sl local_address rem_address st tx:rx tr:when retrnsmt uid timeout inode ...
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 10 || fields[3] != tcpListenState {
+				continue
+			}
+			ip, port, ok := parseProcNetAddress(fields[1])
+			if !ok {
+				continue
+			}
+			byInode[fields[9]] = append(byInode[fields[9]], len(sockets))
+			sockets = append(sockets, listeningSocket{Protocol: table.protocol, Address: ip.String(), Port: port})
+		}
+		file.Close()
+	}
+
+	// Map socket inodes to the processes holding them by scanning each process's open descriptors,
+	// which the fd symlinks name as "socket:[inode]".
+	procs, _ := filepath.Glob("/proc/[0-9]*")
+	for _, proc := range procs {
+		pid, err := strconv.Atoi(filepath.Base(proc))
+		if err != nil {
+			continue
+		}
+		fds, err := os.ReadDir(filepath.Join(proc, "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(proc, "fd", fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			for _, i := range byInode[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] {
+				if sockets[i].PID == 0 {
+					sockets[i].PID = pid
+					sockets[i].Process, sockets[i].Command = processName(pid)
+				}
+			}
+		}
+	}
+	return sockets, nil
+}
+
+// parseProcNetAddress decodes an "ADDR:PORT" field of /proc/net/tcp{,6}, where the address is
+// hex in host byte order, one 32-bit word at a time.
+func parseProcNetAddress(field string) (net.IP, int, bool) {
+	addrHex, portHex, ok := strings.Cut(field, ":")
+	if !ok {
+		return nil, 0, false
+	}
+	raw, err := hex.DecodeString(addrHex)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, false
+	}
+	for word := 0; word < len(raw); word += 4 {
+		raw[word], raw[word+1], raw[word+2], raw[word+3] = raw[word+3], raw[word+2], raw[word+1], raw[word]
+	}
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return nil, 0, false
+	}
+	return net.IP(raw), int(port), true
+}
+
+// processName returns the short name and full command line of a process.
+func processName(pid int) (name, command string) {
+	if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+		name = strings.TrimSpace(string(data))
+	}
+	if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil {
+		command = strings.TrimSpace(strings.ReplaceAll(string(data), "\x00", " "))
+	}
+	return name, command
+}
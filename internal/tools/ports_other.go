@@ -0,0 +1,10 @@
+//go:build !linux
+
+package tools
+
+import "fmt"
+
+// listeningSockets is only implemented on Linux, where /proc exposes the socket tables.
+func listeningSockets() ([]listeningSocket, error) {
+	return nil, fmt.Errorf("listing listening ports is only supported on Linux. Use lsof or netstat via Bash instead.")
+}
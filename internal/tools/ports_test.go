@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPort(t *testing.T) {
+	state := NewState()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	t.Run("open port names its process", func(t *testing.T) {
+		result, err := state.executeCheckPort(context.Background(), "127.0.0.1", port, 0)
+		require.NoError(t, err)
+		var check portCheck
+		require.NoError(t, json.Unmarshal([]byte(result), &check))
+		assert.True(t, check.Open)
+		assert.Empty(t, check.Error)
+		if runtime.GOOS == "linux" {
+			require.Len(t, check.Listeners, 1)
+			assert.Equal(t, os.Getpid(), check.Listeners[0].PID)
+			assert.Equal(t, "127.0.0.1", check.Listeners[0].Address)
+		}
+	})
+
+	t.Run("closed port is refused", func(t *testing.T) {
+		require.NoError(t, listener.Close())
+		result, err := state.executeCheckPort(context.Background(), "127.0.0.1", port, 0)
+		require.NoError(t, err)
+		var check portCheck
+		require.NoError(t, json.Unmarshal([]byte(result), &check))
+		assert.False(t, check.Open)
+		assert.Equal(t, "connection refused", check.Error)
+		assert.Empty(t, check.Listeners)
+	})
+
+	t.Run("validates port", func(t *testing.T) {
+		_, err := state.executeCheckPort(context.Background(), "", 0, 0)
+		assert.ErrorContains(t, err, "port must be between")
+		_, err = state.executeCheckPort(context.Background(), "", 70000, 0)
+		assert.ErrorContains(t, err, "port must be between")
+	})
+}
+
+func TestListeningPorts(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("listening_ports reads /proc")
+	}
+	state := NewState()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	result, err := state.executeListeningPorts(context.Background(), port)
+	require.NoError(t, err)
+	var ports listeningPorts
+	require.NoError(t, json.Unmarshal([]byte(result), &ports))
+	require.Equal(t, 1, ports.Count)
+	assert.Equal(t, listeningSocket{
+		Protocol: "tcp",
+		Address:  "127.0.0.1",
+		Port:     port,
+		PID:      os.Getpid(),
+		Process:  ports.Sockets[0].Process,
+		Command:  ports.Sockets[0].Command,
+	}, ports.Sockets[0])
+	assert.NotEmpty(t, ports.Sockets[0].Process)
+}
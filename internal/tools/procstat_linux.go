@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicksPerSecond is USER_HZ, the unit of the CPU time fields in /proc/<pid>/stat. It is 100
+// on every mainstream Linux architecture and is not exposed without cgo, so it is hard-coded.
+const clockTicksPerSecond = 100
+
+// liveProcessUsage reports the CPU time (including reaped children) and resident set size of a
+// running process by parsing /proc/<pid>/stat. ok is false if the process has exited or /proc is
+// unavailable.
+func liveProcessUsage(pid int) (cpu time.Duration, rss int64, ok bool) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, 0, false
+	}
+	// The command name (field 2) is parenthesized and may itself contain spaces or parentheses,
+	// so split only what follows the last closing parenthesis. fields[0] is then field 3 (state).
+	stat := string(data)
+	idx := strings.LastIndexByte(stat, ')')
+	if idx < 0 {
+		return 0, 0, false
+	}
+	fields := strings.Fields(stat[idx+1:])
+	if len(fields) < 22 {
+		return 0, 0, false
+	}
+	var ticks int64
+	// utime, stime, cutime, cstime are fields 14-17.
+	for _, field := range fields[11:15] {
+		n, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		ticks += n
+	}
+	// rss (field 24) is measured in pages.
+	pages, err := strconv.ParseInt(fields[21], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	cpu = time.Duration(ticks) * time.Second / clockTicksPerSecond
+	return cpu, pages * int64(os.Getpagesize()), true
+}
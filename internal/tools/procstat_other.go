@@ -0,0 +1,10 @@
+//go:build !linux
+
+package tools
+
+import "time"
+
+// liveProcessUsage is only implemented on Linux, where /proc exposes per-process counters.
+func liveProcessUsage(pid int) (cpu time.Duration, rss int64, ok bool) {
+	return 0, 0, false
+}
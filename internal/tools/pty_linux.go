@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openPTY allocates a pseudo-terminal through /dev/ptmx and returns its controller and terminal
+// ends. The terminal is sized 80x24 because some programs misbehave on a zero-sized window.
+func openPTY() (controller, terminal *os.File, err error) {
+	controller, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	fd := controller.Fd()
+	var number uint32
+	if err := ioctl(fd, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&number))); err != nil {
+		controller.Close()
+		return nil, nil, err
+	}
+	var unlock int32
+	if err := ioctl(fd, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		controller.Close()
+		return nil, nil, err
+	}
+	terminal, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", number), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		controller.Close()
+		return nil, nil, err
+	}
+	size := struct{ rows, cols, x, y uint16 }{rows: 24, cols: 80}
+	_ = ioctl(terminal.Fd(), syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&size)))
+	return controller, terminal, nil
+}
+
+func ioctl(fd, request, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// controllingTerminal makes the child the leader of a new session whose controlling terminal is
+// its stdin, as programs that prompt for passwords open /dev/tty rather than reading stdin.
+func controllingTerminal() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+}
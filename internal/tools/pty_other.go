@@ -0,0 +1,18 @@
+//go:build !linux
+
+package tools
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// openPTY is only implemented on Linux, where pseudo-terminals can be allocated without cgo.
+func openPTY() (controller, terminal *os.File, err error) {
+	return nil, nil, errors.New("pseudo-terminals are only supported on Linux")
+}
+
+func controllingTerminal() *syscall.SysProcAttr {
+	return nil
+}
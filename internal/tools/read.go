@@ -1,58 +1,111 @@
 package tools
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/gabriel-vasile/mimetype"
 	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 func (s *State) executeRead(ctx context.Context, filePath string, offset, limit int64) (string, error) {
-	resolved, err := resolvePath(filePath)
+	return s.readText(ctx, filePath, offset, limit, "")
+}
+
+// readText reads a file as numbered lines, annotating each with its last change when annotate is
+// "blame".
+func (s *State) readText(ctx context.Context, filePath string, offset, limit int64, annotate string) (string, error) {
+	if annotate != "" && annotate != "blame" {
+		return "", fmt.Errorf("Invalid annotate: %s. Must be: blame.", annotate)
+	}
+	if annotate != "" && !s.onDisk() {
+		return "", errNeedsDisk("Annotating with blame")
+	}
+	resolved, err := s.resolvePath(filePath)
 	if err != nil {
 		return "", err
 	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+	if f, ok := s.staged(ctx, resolved); ok {
+		return s.readStaged(ctx, resolved, f, offset, limit, annotate)
+	}
 
-	fileInfo, err := validateFileForRead(ctx, resolved)
+	fileInfo, err := s.validateFileForRead(ctx, resolved, limit > 0)
 	if err != nil {
 		return "", err
 	}
 
-	content, err := os.ReadFile(resolved)
+	fsys := s.filesystem()
+	file, err := fsys.Open(resolved)
 	if err != nil {
 		return "", fmt.Errorf("Cannot read file: %s", err)
 	}
+	defer file.Close()
 
 	// Track modification time for files that have been read, enabling change detection
 	// for features that may depend on knowing when a file was last accessed
 	s.Mu.Lock()
 	s.ReadFiles[resolved] = fileInfo.ModTime()
 	s.Mu.Unlock()
+	if fileInfo.Size() <= maxMergeBaseBytes {
+		if content, err := fsys.ReadFile(resolved); err == nil {
+			s.Mu.Lock()
+			s.rememberContent(resolved, fileInfo.ModTime(), content)
+			s.Mu.Unlock()
+		}
+	}
 
-	if len(content) == 0 {
+	// Detect the type from the same prefix mimetype.DetectFile would read, and reuse that prefix
+	// for the line scan below so the file is read only once
+	header := make([]byte, mimeHeaderBytes)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("Cannot read file: %s", err)
+	}
+	header = header[:n]
+	if n == 0 {
 		return "<system-reminder>Warning: the file exists but the contents are empty.</system-reminder>", nil
 	}
 
-	mtype, err := mimetype.DetectFile(resolved)
-	if err != nil {
-		return "", fmt.Errorf("Cannot detect file type: %s", err)
+	mtype := mimetype.Detect(header)
+	if format, ok := compressionFormats[mtype.String()]; ok {
+		if annotate != "" {
+			return "", fmt.Errorf("annotate is not supported for compressed files")
+		}
+		return s.readCompressed(ctx, resolved, format, file, header, fileInfo, offset, limit)
 	}
 
 	// Reject binary files like images and audio; only display text-like content
 	switch strings.Split(mtype.String(), "/")[0] {
 	case "image", "audio":
-		return fmt.Sprintf("[Binary file: %s (%s), %d bytes]", resolved, mtype.String(), len(content)), nil
+		return fmt.Sprintf("[Binary file: %s (%s), %d bytes. Use format \"hex\" or \"base64\" to inspect its bytes]", resolved, mtype.String(), fileInfo.Size()), nil
 	default:
 		if !mtype.Is("text/plain") && !mtype.Parent().Is("text/plain") {
-			return fmt.Sprintf("[Binary file: %s (%s), %d bytes]", resolved, mtype.String(), len(content)), nil
+			return fmt.Sprintf("[Binary file: %s (%s), %d bytes. Use format \"hex\" or \"base64\" to inspect its bytes]", resolved, mtype.String(), fileInfo.Size()), nil
 		}
 	}
 
-	lines := strings.Split(string(content), "\n")
-	totalLines := len(lines)
+	var lines []string
+	var totalLines int
+	if offset > 1 && fileInfo.Size() >= lineIndexMinSize {
+		// Reads deep into a large file seek straight to the requested line via a cached index
+		lines, totalLines, err = s.readIndexedLines(resolved, file, fileInfo, int(offset), int(limit))
+	} else {
+		lines, totalLines, err = readLines(io.MultiReader(bytes.NewReader(header), file), int(offset), int(limit))
+	}
+	if err != nil {
+		return "", fmt.Errorf("Cannot read file: %s", err)
+	}
 	startLine, endLine := calculateLineRange(totalLines, int(offset), int(limit))
 
 	// When user provides an offset, validate it points to a valid line in the file
@@ -64,8 +117,13 @@ func (s *State) executeRead(ctx context.Context, filePath string, offset, limit
 		), nil
 	}
 
-	selectedLines := lines[startLine-1 : endLine]
-	result := catN(selectedLines, startLine)
+	lines = lines[:endLine-startLine+1]
+	if annotate == "blame" {
+		if lines, err = blameAnnotate(ctx, resolved, lines, startLine); err != nil {
+			return "", err
+		}
+	}
+	result := catN(lines, startLine)
 
 	if err := checkOutputSize(ctx, result, "read"); err != nil {
 		return "", err
@@ -74,13 +132,198 @@ func (s *State) executeRead(ctx context.Context, filePath string, offset, limit
 	return result, nil
 }
 
-func validateFileForRead(ctx context.Context, resolved string) (os.FileInfo, error) {
-	fileInfo, err := os.Stat(resolved)
+// blameAnnotate prefixes lines, which start at startLine of path, with the short hash, date, and
+// author of the commit that last changed each one, taken from git blame of the working tree file so
+// that uncommitted changes line up too.
+func blameAnnotate(ctx context.Context, path string, lines []string, startLine int) ([]string, error) {
+	if len(lines) == 0 {
+		return lines, nil
+	}
+	endLine := startLine + len(lines) - 1
+	output, err := runGit(ctx, filepath.Dir(path), "blame", "--line-porcelain", fmt.Sprintf("-L%d,%d", startLine, endLine), "--", filepath.Base(path))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot annotate with blame: %s", err)
+	}
+	blames := make(map[int]gitBlameLine, len(lines))
+	authorWidth := 0
+	for _, b := range parseBlamePorcelain(output) {
+		if strings.Trim(b.Commit, "0") == "" {
+			b.Author = "(uncommitted)"
+		}
+		blames[b.Line] = b
+		authorWidth = max(authorWidth, utf8.RuneCountInString(b.Author))
+	}
+
+	annotated := make([]string, len(lines))
+	for i, line := range lines {
+		b, ok := blames[startLine+i]
+		if !ok {
+			annotated[i] = fmt.Sprintf("%-*s │ %s", 7+1+10+1+authorWidth, "", line)
+			continue
+		}
+		hash, date := b.Commit, b.Date
+		if len(hash) > 7 {
+			hash = hash[:7]
+		}
+		if len(date) > 10 {
+			date = date[:10]
+		}
+		padding := strings.Repeat(" ", authorWidth-utf8.RuneCountInString(b.Author))
+		annotated[i] = fmt.Sprintf("%s %s %s%s │ %s", hash, date, b.Author, padding, line)
+	}
+	return annotated, nil
+}
+
+// mimeHeaderBytes is how much of a file is inspected to detect its type, matching the read limit
+// mimetype uses by default.
+const mimeHeaderBytes = 3072
+
+// readLines scans r line by line, splitting exactly like strings.Split(content, "\n"), and returns
+// the lines from the one calculateLineRange would start at onwards. Scanning stops once the last line
+// the range can include has been read, so totalLines is only the full count when the range runs
+// past the end of the file, which is all calculateLineRange needs to clamp it.
+func readLines(r io.Reader, offset, limit int) (lines []string, totalLines int, err error) {
+	start, stop := max(offset, 1), -1
+	if limit > 0 {
+		stop = start + limit - 1
+	} else if offset == 0 {
+		stop = 2000
+	}
+
+	reader := bufio.NewReader(r)
+	for stop < 0 || totalLines < stop {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, 0, readErr
+		}
+		totalLines++
+		if totalLines >= start {
+			lines = append(lines, strings.TrimSuffix(line, "\n"))
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+	return lines, totalLines, nil
+}
+
+// defaultHexDumpBytes is how much of a file a hex dump covers when no limit is given; a dump is
+// roughly four times the size of its input, so whole large files would blow the output limit.
+const defaultHexDumpBytes = 4096
+
+// executeReadBytes returns raw file bytes in an encoding that survives JSON transport, for binary
+// files that the line-oriented text mode cannot represent. offset and limit count bytes here.
+func (s *State) executeReadBytes(ctx context.Context, filePath, format string, offset, limit int64) (string, error) {
+	resolved, err := s.resolvePath(filePath)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+	if offset < 0 || limit < 0 {
+		return "", fmt.Errorf("offset and limit cannot be negative")
+	}
+
+	if limit == 0 && format == "hex" {
+		limit = defaultHexDumpBytes
+	}
+	if f, ok := s.staged(ctx, resolved); ok {
+		return s.readStagedBytes(ctx, resolved, f, format, offset, limit)
+	}
+
+	fileInfo, err := s.validateFileForRead(ctx, resolved, limit > 0)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := s.filesystem().Open(resolved)
+	if err != nil {
+		return "", fmt.Errorf("Cannot read file: %s", err)
+	}
+	defer file.Close()
+
+	s.Mu.Lock()
+	s.ReadFiles[resolved] = fileInfo.ModTime()
+	s.Mu.Unlock()
+
+	if offset > fileInfo.Size() {
+		return "", fmt.Errorf("offset %d is beyond the end of the file (%d bytes)", offset, fileInfo.Size())
+	}
+	// Read only the requested range, so byte ranges of files above the size limit stay cheap
+	var section io.Reader = io.NewSectionReader(file, offset, fileInfo.Size()-offset)
+	if limit > 0 {
+		section = io.LimitReader(section, limit)
+	}
+	content, err := io.ReadAll(section)
+	if err != nil {
+		return "", fmt.Errorf("Cannot read file: %s", err)
+	}
+	return encodeBytes(ctx, content, format, offset)
+}
+
+// encodeBytes renders content, which starts at offset in its file, in format.
+func encodeBytes(ctx context.Context, content []byte, format string, offset int64) (string, error) {
+	var result string
+	switch format {
+	case "base64":
+		result = base64.StdEncoding.EncodeToString(content)
+	case "hex":
+		result = hexDump(content, offset)
+	default:
+		return "", fmt.Errorf("Invalid format: %s. Must be one of: text, base64, hex.", format)
+	}
+
+	if err := checkOutputSize(ctx, result, "read"); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// hexDump formats data like `xxd`: 16 bytes per line as an offset, hex in two-byte groups, and
+// printable ASCII. Offsets start at base so a dump of a byte range shows file positions.
+func hexDump(data []byte, base int64) string {
+	var b strings.Builder
+	for i := 0; i < len(data); i += 16 {
+		row := data[i:min(i+16, len(data))]
+		fmt.Fprintf(&b, "%08x: ", base+int64(i))
+		for j := 0; j < 16; j++ {
+			if j < len(row) {
+				fmt.Fprintf(&b, "%02x", row[j])
+			} else {
+				b.WriteString("  ")
+			}
+			if j%2 == 1 {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteByte(' ')
+		for _, c := range row {
+			if c >= 0x20 && c < 0x7f {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// validateFileForRead checks that resolved is a readable file. The size limit applies only to
+// unbounded reads: a read with a limit returns a bounded slice of the file however large it is.
+func (s *State) validateFileForRead(ctx context.Context, resolved string, bounded bool) (os.FileInfo, error) {
+	fileInfo, err := s.filesystem().Stat(resolved)
 	if os.IsNotExist(err) || (err == nil && fileInfo.IsDir()) {
 		return nil, fmt.Errorf("file does not exist")
 	}
-	if err := checkFileSize(ctx, fileInfo.Size(), "read"); err != nil {
-		return nil, err
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read file: %s", err)
+	}
+	if !bounded {
+		if err := s.checkFileSize(ctx, fileInfo.Size(), "read"); err != nil {
+			return nil, err
+		}
 	}
 	return fileInfo, nil
 }
@@ -108,13 +351,15 @@ func calculateLineRange(totalLines, offset, limit int) (start, end int) {
 
 var ReadTool = sdk.Tool{
 	Name:        "read",
-	Description: "Reads a file from the local filesystem. You can access any file directly by using this tool.\nAssume this tool is able to read all files on the machine. If the User provides a path to a file assume that path is valid. It is okay to read a file that does not exist; an error will be returned.\n\nUsage:\n- The file_path parameter must be an absolute path, not a relative path\n- By default, it reads up to 2000 lines starting from the beginning of the file\n- You can optionally specify a line offset and limit (especially handy for large files), but it's recommended to read the whole file by not providing these parameters\n- Any lines longer than 2000 characters will be truncated\n- Results are returned using cat -n format, with line numbers starting at 1\n- This tool can only read files, not directories. To read a directory, use an ls command via the Bash tool.\n- You can call multiple tools in a single response. It is always better to speculatively read multiple potentially useful files in parallel.\n- If you read a file that exists but has empty contents you will receive a system reminder warning in place of file contents.",
+	Description: "Reads a file from the local filesystem. You can access any file directly by using this tool.\nAssume this tool is able to read all files on the machine. If the User provides a path to a file assume that path is valid. It is okay to read a file that does not exist; an error will be returned.\n\nUsage:\n- The file_path parameter must be an absolute path, not a relative path\n- By default, it reads up to 2000 lines starting from the beginning of the file\n- You can optionally specify a line offset and limit (especially handy for large files), but it's recommended to read the whole file by not providing these parameters\n- Files above the server's size limit (10MB by default) can only be read in portions: pass a limit, with an offset to choose where to start\n- Any lines longer than 2000 characters will be truncated\n- Results are returned using cat -n format, with line numbers starting at 1\n- Gzip (.gz) and zstd (.zst) files holding text are decompressed and read like plain text files, with a note of their compressed size\n- This tool can only read files, not directories. To read a directory, use the ls tool.\n- You can call multiple tools in a single response. It is always better to speculatively read multiple potentially useful files in parallel.\n- If you read a file that exists but has empty contents you will receive a system reminder warning in place of file contents.\n- Set format to \"base64\" to get the raw bytes of a binary file base64-encoded, or \"hex\" for an xxd-style hex and ASCII dump (first 4096 bytes by default) to inspect headers and magic numbers. In these formats offset and limit count bytes instead of lines.\n- Set annotate to \"blame\" to prefix each line of a file in a git repository with the short hash, date, and author of the commit that last changed it, to answer who changed a line and when.",
 }
 
 type ReadInput struct {
 	FilePath string `json:"file_path" jsonschema:"The absolute path to the file to read"`
 	Offset   int64  `json:"offset,omitempty" jsonschema:"The line number to start reading from. Only provide if the file is too large to read at once"`
 	Limit    int64  `json:"limit,omitempty" jsonschema:"The number of lines to read. Only provide if the file is too large to read at once"`
+	Format   string `json:"format,omitempty" jsonschema:"Output format: 'text' (default) for numbered lines, 'base64' for raw bytes, or 'hex' for a hex dump. In base64 and hex formats offset and limit are byte counts"`
+	Annotate string `json:"annotate,omitempty" jsonschema:"Set to 'blame' to prefix each line with the commit, date, and author that last changed it. Text format only"`
 }
 type ReadOutput struct {
 	Content string `json:"content"`
@@ -122,7 +367,15 @@ type ReadOutput struct {
 
 func Read(ctx context.Context, req *sdk.CallToolRequest, args ReadInput) (*sdk.CallToolResult, any, error) {
 	server := GetState()
-	result, err := server.executeRead(ctx, args.FilePath, args.Offset, args.Limit)
+	var result string
+	var err error
+	if args.Format == "" || args.Format == "text" {
+		result, err = server.readText(withSession(ctx, req), args.FilePath, args.Offset, args.Limit, args.Annotate)
+	} else if args.Annotate != "" {
+		err = fmt.Errorf("annotate can only be used with the text format")
+	} else {
+		result, err = server.executeReadBytes(withSession(ctx, req), args.FilePath, args.Format, args.Offset, args.Limit)
+	}
 	if err != nil {
 		return nil, nil, err
 	}
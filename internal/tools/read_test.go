@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"encoding/base64"
 	"os"
 	"path/filepath"
 	"strings"
@@ -210,6 +211,67 @@ func TestRead_BinaryFiles(t *testing.T) {
 	})
 }
 
+func TestRead_Base64Format(t *testing.T) {
+	state := NewState()
+	path := filepath.Join(t.TempDir(), "image.png")
+	data := []byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A, 0x00, 0xFF}
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	t.Run("whole file", func(t *testing.T) {
+		result, err := state.executeReadBytes(context.Background(), path, "base64", 0, 0)
+		require.NoError(t, err)
+		decoded, err := base64.StdEncoding.DecodeString(result)
+		require.NoError(t, err)
+		assert.Equal(t, data, decoded)
+	})
+
+	t.Run("byte range", func(t *testing.T) {
+		result, err := state.executeReadBytes(context.Background(), path, "base64", 1, 3)
+		require.NoError(t, err)
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("PNG")), result)
+	})
+
+	t.Run("offset past end", func(t *testing.T) {
+		_, err := state.executeReadBytes(context.Background(), path, "base64", 100, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		_, _, err := Read(context.Background(), &sdk.CallToolRequest{}, ReadInput{FilePath: path, Format: "rot13"})
+		assert.Error(t, err)
+	})
+}
+
+func TestRead_HexFormat(t *testing.T) {
+	state := NewState()
+	path := filepath.Join(t.TempDir(), "data.bin")
+	data := append([]byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A}, []byte("0123456789abcdefXYZ")...)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	t.Run("xxd layout", func(t *testing.T) {
+		result, err := state.executeReadBytes(context.Background(), path, "hex", 0, 0)
+		require.NoError(t, err)
+		lines := strings.Split(result, "\n")
+		require.Len(t, lines, 2)
+		assert.Equal(t, "00000000: 8950 4e47 0d0a 1a0a 3031 3233 3435 3637  .PNG....01234567", lines[0])
+		assert.Equal(t, "00000010: 3839 6162 6364 6566 5859 5a              89abcdefXYZ", lines[1])
+	})
+
+	t.Run("byte range keeps file offsets", func(t *testing.T) {
+		result, err := state.executeReadBytes(context.Background(), path, "hex", 16, 4)
+		require.NoError(t, err)
+		assert.Equal(t, "00000010: 3839 6162                                89ab", result)
+	})
+
+	t.Run("default limit", func(t *testing.T) {
+		big := filepath.Join(t.TempDir(), "big.bin")
+		require.NoError(t, os.WriteFile(big, make([]byte, defaultHexDumpBytes*2), 0o644))
+		result, err := state.executeReadBytes(context.Background(), big, "hex", 0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, defaultHexDumpBytes/16, strings.Count(result, "\n")+1)
+	})
+}
+
 func TestRead_EdgeCases(t *testing.T) {
 	t.Run("path with spaces", func(t *testing.T) {
 		state := NewState()
@@ -288,6 +350,73 @@ func TestCalculateLineRange(t *testing.T) {
 	}
 }
 
+func TestRead_SizeLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.log")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("0123456789\n", 100)), 0o644))
+	state := NewState()
+	require.NoError(t, state.SetMaxFileSize(500))
+
+	t.Run("full read rejected", func(t *testing.T) {
+		_, err := state.executeRead(context.Background(), path, 0, 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exceeds maximum allowed size (500 bytes)")
+
+		_, err = state.executeRead(context.Background(), path, 50, 0)
+		require.Error(t, err)
+	})
+
+	t.Run("line range allowed", func(t *testing.T) {
+		result, err := state.executeRead(context.Background(), path, 90, 2)
+		require.NoError(t, err)
+		assert.Contains(t, result, "90→0123456789")
+		assert.Contains(t, result, "91→0123456789")
+	})
+
+	t.Run("byte range allowed", func(t *testing.T) {
+		result, err := state.executeReadBytes(context.Background(), path, "base64", 990, 5)
+		require.NoError(t, err)
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("01234")), result)
+
+		_, err = state.executeReadBytes(context.Background(), path, "base64", 0, 0)
+		require.Error(t, err)
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		require.Error(t, state.SetMaxFileSize(0))
+	})
+}
+
+func TestReadLines(t *testing.T) {
+	// readLines must number lines exactly as splitting the whole content on "\n" would, including
+	// the empty line after a trailing newline
+	for _, content := range []string{"a", "a\n", "a\nb", "a\nb\n", "\n\n", "a\r\nb"} {
+		lines, total, err := readLines(strings.NewReader(content), 1, 0)
+		require.NoError(t, err)
+		want := strings.Split(content, "\n")
+		assert.Equal(t, want, lines, "%q", content)
+		assert.Equal(t, len(want), total, "%q", content)
+	}
+
+	t.Run("stops after the requested range", func(t *testing.T) {
+		content := strings.Repeat("line\n", 5000)
+		lines, total, err := readLines(strings.NewReader(content), 10, 5)
+		require.NoError(t, err)
+		assert.Len(t, lines, 5)
+		assert.Equal(t, 14, total)
+
+		_, total, err = readLines(strings.NewReader(content), 0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2000, total)
+	})
+
+	t.Run("range past the end", func(t *testing.T) {
+		lines, total, err := readLines(strings.NewReader("a\nb"), 5, 10)
+		require.NoError(t, err)
+		assert.Empty(t, lines)
+		assert.Equal(t, 2, total)
+	})
+}
+
 func TestRead_MCPIntegration(t *testing.T) {
 	// Verify the public Read function (called by the MCP server) properly
 	// registers files in the global state for edit validation.
@@ -303,3 +432,24 @@ func TestRead_MCPIntegration(t *testing.T) {
 	state.Mu.Unlock()
 	assert.True(t, exists)
 }
+
+func TestRead_BlameAnnotation(t *testing.T) {
+	state, dir := setupGitRepo(t)
+	path := filepath.Join(dir, "hello.txt")
+	require.NoError(t, os.WriteFile(path, []byte("line 1\nline two\nline 3\n"), 0o644))
+
+	result, err := state.readText(context.Background(), path, 2, 2, "blame")
+	require.NoError(t, err)
+	lines := strings.Split(result, "\n")
+	require.Len(t, lines, 2)
+	assert.Regexp(t, `^     2→0000000 \d{4}-\d\d-\d\d \(uncommitted\) │ line two$`, lines[0])
+	assert.Regexp(t, `^     3→[0-9a-f]{7} \d{4}-\d\d-\d\d Test User     │ line 3$`, lines[1])
+
+	_, err = state.readText(context.Background(), path, 0, 0, "authors")
+	assert.ErrorContains(t, err, "Invalid annotate")
+
+	outside := filepath.Join(t.TempDir(), "plain.txt")
+	require.NoError(t, os.WriteFile(outside, []byte("x\n"), 0o644))
+	_, err = state.readText(context.Background(), outside, 0, 0, "blame")
+	assert.ErrorContains(t, err, "Cannot annotate with blame")
+}
@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type referencesResult struct {
+	References []lspLocation `json:"references"`
+	Total      int           `json:"total"`
+	Truncated  bool          `json:"truncated,omitempty"`
+}
+
+func (s *State) executeReferences(ctx context.Context, filePath string, line int, symbol string, column int, includeDeclaration bool) (string, error) {
+	client, target, err := s.lspTarget(ctx, filePath, line, symbol, column)
+	if err != nil {
+		return "", err
+	}
+	params := struct {
+		lspTextDocumentPosition
+		Context struct {
+			IncludeDeclaration bool `json:"includeDeclaration"`
+		} `json:"context"`
+	}{lspTextDocumentPosition: target}
+	params.Context.IncludeDeclaration = includeDeclaration
+	var raw []lspRawLocation
+	if err := client.call(ctx, "textDocument/references", params, &raw); err != nil {
+		return "", err
+	}
+	locations, err := s.lspLocations(raw, client.encoding)
+	if err != nil {
+		return "", err
+	}
+	if len(locations) == 0 {
+		return "No references found.", nil
+	}
+
+	result := referencesResult{References: locations, Total: len(locations)}
+	if maxResults := limitsFromContext(ctx).maxResults; len(locations) > maxResults {
+		result.References = locations[:maxResults]
+		result.Truncated = true
+	}
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format references: %s", err)
+	}
+	output := string(jsonBytes)
+	if err := checkOutputSize(ctx, output, "references"); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+var ReferencesTool = sdk.Tool{
+	Name:        "references",
+	Description: "Finds every reference to a symbol across the project, using the project's language server. Unlike Grep, it only matches the symbol itself, not other identifiers or comments with the same name.\n\nUsage:\n- Give the file, the 1-based line where the symbol appears, and the symbol's name as it appears on that line (or a 1-based column instead).\n- Set include_declaration to also list the declaration itself.\n- Returns each reference's file, line, column, and line text, sorted by file and position.\n- Language servers are started on demand for the file's project; the first call may take a while as the server loads it.",
+}
+
+type ReferencesInput struct {
+	LSPPositionInput
+	IncludeDeclaration bool `json:"include_declaration,omitempty" jsonschema:"Also list the symbol's declaration"`
+}
+type ReferencesOutput struct {
+	Result string `json:"result"`
+}
+
+func References(ctx context.Context, req *sdk.CallToolRequest, args ReferencesInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeReferences(ctx, args.FilePath, args.Line, args.Symbol, args.Column, args.IncludeDeclaration)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &ReferencesOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
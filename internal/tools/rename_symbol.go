@@ -0,0 +1,240 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// renameFile is the new content a rename produces for one file.
+type renameFile struct {
+	path       string
+	oldContent []byte
+	newContent []byte
+	edits      int
+}
+
+func (s *State) executeRenameSymbol(ctx context.Context, filePath string, line int, symbol string, column int, newName string, dryRun, backup bool) (string, error) {
+	if !dryRun {
+		if err := s.checkOverlay("rename_symbol"); err != nil {
+			return "", err
+		}
+	}
+	if strings.TrimSpace(newName) == "" {
+		return "", fmt.Errorf("new_name is required")
+	}
+	client, target, err := s.lspTarget(ctx, filePath, line, symbol, column)
+	if err != nil {
+		return "", err
+	}
+	params := struct {
+		lspTextDocumentPosition
+		NewName string `json:"newName"`
+	}{target, newName}
+	var workspaceEdit struct {
+		Changes         map[string][]lspTextEdit `json:"changes"`
+		DocumentChanges []json.RawMessage        `json:"documentChanges"`
+	}
+	if err := client.call(ctx, "textDocument/rename", params, &workspaceEdit); err != nil {
+		return "", err
+	}
+	changes, err := workspaceEditChanges(workspaceEdit.Changes, workspaceEdit.DocumentChanges)
+	if err != nil {
+		return "", err
+	}
+	if len(changes) == 0 {
+		return "", fmt.Errorf("%s found nothing to rename at that position", client.name)
+	}
+	if !dryRun {
+		var targets []string
+		for uri := range changes {
+			if path, err := uriToPath(uri); err == nil {
+				targets = append(targets, path)
+			}
+		}
+		defer s.lockPaths(ctx, targets...)()
+	}
+
+	var files []renameFile
+	for uri, edits := range changes {
+		path, err := uriToPath(uri)
+		if err != nil {
+			return "", err
+		}
+		if err := s.checkPathAllowed(path); err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("Cannot read file: %s", err)
+		}
+		updated, err := applyTextEdits(content, edits, client.encoding)
+		if err != nil {
+			return "", fmt.Errorf("Cannot apply the rename to %s: %s", path, err)
+		}
+		files = append(files, renameFile{path: path, oldContent: content, newContent: updated, edits: len(edits)})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+
+	if dryRun {
+		var b strings.Builder
+		for _, f := range files {
+			hunks := diffHunks(diffLines(splitDiffLines(f.oldContent), splitDiffLines(f.newContent)), 1)
+			b.WriteString(renderUnified(f.path, f.path, hunks))
+		}
+		output := b.String()
+		if err := checkOutputSize(ctx, output, "rename_symbol"); err != nil {
+			return "", err
+		}
+		return output, nil
+	}
+
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	if err := s.confirmWrite(ctx, "rename_symbol", paths...); err != nil {
+		return "", err
+	}
+	var summary strings.Builder
+	total := 0
+	for _, f := range files {
+		if backup {
+			if _, err := s.backupFile(ctx, f.path, true); err != nil {
+				return "", err
+			}
+		}
+		if err := s.writeFile(f.path, f.newContent, 0); err != nil {
+			return "", err
+		}
+		s.recordEdit(ctx, "rename_symbol", f.path, true, f.oldContent, f.newContent)
+
+		// Files already read stay editable without being read again, as after an edit.
+		info, err := os.Stat(f.path)
+		if err == nil {
+			s.Mu.Lock()
+			if _, tracked := s.ReadFiles[f.path]; tracked {
+				s.ReadFiles[f.path] = info.ModTime()
+			}
+			s.Mu.Unlock()
+			_ = client.syncDocument(f.path, f.newContent, info)
+		}
+		total += f.edits
+		fmt.Fprintf(&summary, "\n- %s (%d)", f.path, f.edits)
+	}
+	return fmt.Sprintf("Renamed %s to %s: %d occurrences in %d files:%s", symbolOrPosition(symbol, line, column), newName, total, len(files), summary.String()), nil
+}
+
+func symbolOrPosition(symbol string, line, column int) string {
+	if symbol != "" {
+		return symbol
+	}
+	return fmt.Sprintf("the symbol at line %d, column %d", line, column)
+}
+
+// workspaceEditChanges collects the text edits of a WorkspaceEdit by document URI, from either its
+// changes map or its documentChanges list. Renames that create, move, or delete files are refused.
+func workspaceEditChanges(changes map[string][]lspTextEdit, documentChanges []json.RawMessage) (map[string][]lspTextEdit, error) {
+	if len(documentChanges) == 0 {
+		return changes, nil
+	}
+	result := map[string][]lspTextEdit{}
+	for _, raw := range documentChanges {
+		var change struct {
+			Kind         string `json:"kind"`
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			Edits []lspTextEdit `json:"edits"`
+		}
+		if err := json.Unmarshal(raw, &change); err != nil {
+			return nil, fmt.Errorf("language server returned an invalid rename: %s", err)
+		}
+		if change.Kind != "" {
+			return nil, fmt.Errorf("the rename would %s files, which rename_symbol does not support", change.Kind)
+		}
+		result[change.TextDocument.URI] = append(result[change.TextDocument.URI], change.Edits...)
+	}
+	return result, nil
+}
+
+// applyTextEdits applies LSP text edits, whose positions all refer to the original content, to
+// content. Edits may not overlap.
+func applyTextEdits(content []byte, edits []lspTextEdit, encoding string) ([]byte, error) {
+	text := string(content)
+	lineStarts := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	offset := func(p lspPosition) int {
+		if p.Line >= len(lineStarts) {
+			return len(text)
+		}
+		start := lineStarts[p.Line]
+		end := len(text)
+		if p.Line+1 < len(lineStarts) {
+			end = lineStarts[p.Line+1] - 1
+		}
+		return start + byteOffsetInLine(strings.TrimSuffix(text[start:end], "\r"), p.Character, encoding)
+	}
+
+	type span struct {
+		start, end int
+		newText    string
+	}
+	spans := make([]span, len(edits))
+	for i, edit := range edits {
+		spans[i] = span{offset(edit.Range.Start), offset(edit.Range.End), edit.NewText}
+		if spans[i].end < spans[i].start {
+			return nil, fmt.Errorf("edit range ends before it starts")
+		}
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var b strings.Builder
+	last := 0
+	for _, sp := range spans {
+		if sp.start < last {
+			return nil, fmt.Errorf("edits overlap")
+		}
+		b.WriteString(text[last:sp.start])
+		b.WriteString(sp.newText)
+		last = sp.end
+	}
+	b.WriteString(text[last:])
+	return []byte(b.String()), nil
+}
+
+var RenameSymbolTool = sdk.Tool{
+	Name:        "rename_symbol",
+	Description: "Renames a symbol everywhere it is used, using the project's language server, so that every reference across the project changes together and nothing else does.\n\nUsage:\n- Give the file, the 1-based line where the symbol appears, the symbol's name as it appears on that line (or a 1-based column instead), and new_name.\n- Set dry_run to preview the change as a unified diff without writing anything.\n- Files are rewritten in place; files you have already read stay editable without reading them again.\n- Renames that would create, move, or delete files are refused.\n- Set backup to true to save each file's previous content so it can be reverted with restore_backup.",
+}
+
+type RenameSymbolInput struct {
+	LSPPositionInput
+	NewName string `json:"new_name" jsonschema:"The symbol's new name"`
+	DryRun  bool   `json:"dry_run,omitempty" jsonschema:"Show the change as a unified diff without writing it"`
+	Backup  *bool  `json:"backup,omitempty" jsonschema:"Save each file's content before renaming, restorable with restore_backup. Defaults to the server setting"`
+}
+type RenameSymbolOutput struct {
+	Result string `json:"result"`
+}
+
+func RenameSymbol(ctx context.Context, req *sdk.CallToolRequest, args RenameSymbolInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeRenameSymbol(withSession(ctx, req), args.FilePath, args.Line, args.Symbol, args.Column, args.NewName, args.DryRun, server.useBackup(args.Backup))
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &RenameSymbolOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
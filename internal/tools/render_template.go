@@ -0,0 +1,226 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"unicode"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// templateFuncs are the functions available to render_template templates, beyond text/template's
+// builtins: string helpers and the identifier case conversions boilerplate usually needs.
+var templateFuncs = template.FuncMap{
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"title":     capitalize,
+	"trim":      strings.TrimSpace,
+	"replace":   func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"hasPrefix": strings.HasPrefix,
+	"hasSuffix": strings.HasSuffix,
+	"contains":  strings.Contains,
+	"split":     strings.Split,
+	"join":      templateJoin,
+	"repeat":    func(n int, s string) string { return strings.Repeat(s, max(n, 0)) },
+	"indent":    templateIndent,
+	"quote":     func(s string) string { return fmt.Sprintf("%q", s) },
+	"default":   templateDefault,
+	"camel":     func(s string) string { return joinWords(identifierWords(s), false) },
+	"pascal":    func(s string) string { return joinWords(identifierWords(s), true) },
+	"snake":     func(s string) string { return strings.ToLower(strings.Join(identifierWords(s), "_")) },
+	"kebab":     func(s string) string { return strings.ToLower(strings.Join(identifierWords(s), "-")) },
+}
+
+func (s *State) executeRenderTemplate(ctx context.Context, templateText, templateFile string, variables map[string]any, filePath, mode string, ifNotExists, dryRun, backup bool) (string, error) {
+	if (templateText == "") == (templateFile == "") {
+		return "", fmt.Errorf("Provide exactly one of template or template_file.")
+	}
+	if filePath == "" && !dryRun {
+		return "", fmt.Errorf("file_path is required unless dry_run is set")
+	}
+	name := "template"
+	if templateFile != "" {
+		resolved, err := s.resolvePath(templateFile)
+		if err != nil {
+			return "", err
+		}
+		if err := s.checkPathAllowed(resolved); err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(resolved)
+		if err != nil {
+			return "", fmt.Errorf("Cannot read template file: %s", err)
+		}
+		templateText, name = string(content), resolved
+	}
+
+	// Referring to a variable that was not provided is an error, so a misspelled name cannot
+	// silently render as "<no value>".
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(templateFuncs).Parse(templateText)
+	if err != nil {
+		return "", fmt.Errorf("Cannot parse template: %s", err)
+	}
+	if variables == nil {
+		variables = map[string]any{}
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, variables); err != nil {
+		return "", fmt.Errorf("Cannot render template: %s", err)
+	}
+
+	if dryRun {
+		if err := checkOutputSize(ctx, out.String(), "render_template"); err != nil {
+			return "", err
+		}
+		return out.String(), nil
+	}
+	return s.writeAs(ctx, "render_template", filePath, out.String(), writeOptions{Mode: mode, IfNotExists: ifNotExists, Backup: backup})
+}
+
+// identifierWords splits s into words at spaces, punctuation, and camelCase boundaries, keeping
+// acronyms together: "HTTPServer_config" gives HTTP, Server, config.
+func identifierWords(s string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				words, word = append(words, string(word)), nil
+			}
+			continue
+		}
+		if len(word) > 0 && unicode.IsUpper(r) {
+			prev := word[len(word)-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				words, word = append(words, string(word)), nil
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// joinWords joins words in camelCase, or PascalCase when upperFirst is set.
+func joinWords(words []string, upperFirst bool) string {
+	var b strings.Builder
+	for i, w := range words {
+		w = strings.ToLower(w)
+		if i > 0 || upperFirst {
+			w = capitalize(w)
+		}
+		b.WriteString(w)
+	}
+	return b.String()
+}
+
+// capitalize upper-cases the first letter of s.
+func capitalize(s string) string {
+	for i, r := range s {
+		return s[:i] + string(unicode.ToUpper(r)) + s[i+len(string(r)):]
+	}
+	return s
+}
+
+// templateJoin joins a list of any values, as variables decoded from JSON arrive as []any.
+func templateJoin(sep string, list any) (string, error) {
+	switch l := list.(type) {
+	case []string:
+		return strings.Join(l, sep), nil
+	case []any:
+		parts := make([]string, len(l))
+		for i, v := range l {
+			parts[i] = fmt.Sprint(v)
+		}
+		return strings.Join(parts, sep), nil
+	default:
+		return "", fmt.Errorf("join expects a list, got %T", list)
+	}
+}
+
+// templateIndent prefixes every non-empty line of s with n spaces.
+func templateIndent(n int, s string) string {
+	pad := strings.Repeat(" ", max(n, 0))
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// templateDefault returns value, or fallback when value is empty: nil, false, zero, or an empty
+// string, list, or map.
+func templateDefault(fallback, value any) any {
+	switch v := value.(type) {
+	case nil:
+		return fallback
+	case string:
+		if v == "" {
+			return fallback
+		}
+	case bool:
+		if !v {
+			return fallback
+		}
+	case float64:
+		if v == 0 {
+			return fallback
+		}
+	case int:
+		if v == 0 {
+			return fallback
+		}
+	case []any:
+		if len(v) == 0 {
+			return fallback
+		}
+	case map[string]any:
+		if len(v) == 0 {
+			return fallback
+		}
+	}
+	return value
+}
+
+var RenderTemplateTool = sdk.Tool{
+	Name:        "render_template",
+	Description: "Expands a Go text/template with the given variables and writes the result to a file, so boilerplate that differs only in names and a few values can be generated without sending each copy in full.\n\nUsage:\n- Give the template inline with template, or as a file with template_file, and the values it uses as variables, e.g. {\"name\": \"user store\", \"fields\": [\"id\", \"email\"]} for {{.name}} and {{range .fields}}.\n- Using a variable that was not provided is an error rather than an empty string.\n- Besides the text/template builtins (if, range, with, printf, len, index, eq, ...), templates can use: lower, upper, title, trim, replace OLD NEW S, hasPrefix, hasSuffix, contains, split S SEP, join SEP LIST, repeat N S, indent N S, quote, default FALLBACK VALUE, and the case conversions camel, pascal, snake, and kebab (e.g. {{pascal .name}} gives UserStore).\n- The file is written as write writes it: an existing file MUST have been read first, and mode, if_not_exists, and backup work the same way.\n- Set dry_run to true to return the rendered text without writing it; file_path is then optional.",
+}
+
+type RenderTemplateInput struct {
+	Template     string         `json:"template,omitempty" jsonschema:"The Go text/template to expand, instead of a template file"`
+	TemplateFile string         `json:"template_file,omitempty" jsonschema:"The absolute path to a file holding the template, instead of template"`
+	Variables    map[string]any `json:"variables,omitempty" jsonschema:"The values the template refers to, e.g. {\"name\": \"user\"} for {{.name}}"`
+	FilePath     string         `json:"file_path,omitempty" jsonschema:"The absolute path to write the rendered text to"`
+	Mode         string         `json:"mode,omitempty" jsonschema:"Octal permissions to set on the file (e.g. 755). Defaults to the existing file's mode, or the server default for new files"`
+	IfNotExists  bool           `json:"if_not_exists,omitempty" jsonschema:"Only create the file: fail without changing anything if the path already exists"`
+	DryRun       bool           `json:"dry_run,omitempty" jsonschema:"Return the rendered text without writing it"`
+	Backup       *bool          `json:"backup,omitempty" jsonschema:"Save the existing file's content before overwriting it, restorable with restore_backup. Defaults to the server setting"`
+}
+type RenderTemplateOutput struct {
+	Result string `json:"result"`
+}
+
+func RenderTemplate(ctx context.Context, req *sdk.CallToolRequest, args RenderTemplateInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeRenderTemplate(withSession(ctx, req), args.Template, args.TemplateFile, args.Variables, args.FilePath, args.Mode,
+		args.IfNotExists, args.DryRun, server.useBackup(args.Backup))
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &RenderTemplateOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
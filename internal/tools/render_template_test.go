@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	ctx := context.Background()
+	const tmpl = `package {{snake .name}}
+
+// {{pascal .name}} holds {{join ", " .fields}}.
+type {{pascal .name}} struct {
+{{- range .fields}}
+	{{pascal .}} string
+{{- end}}
+}
+`
+	vars := map[string]any{"name": "user store", "fields": []any{"id", "email_address"}}
+	const want = `package user_store
+
+// UserStore holds id, email_address.
+type UserStore struct {
+	Id string
+	EmailAddress string
+}
+`
+
+	t.Run("writes the rendered file", func(t *testing.T) {
+		state := NewState()
+		path := filepath.Join(t.TempDir(), "store", "store.go")
+		result, err := state.executeRenderTemplate(ctx, tmpl, "", vars, path, "", false, false, false)
+		require.NoError(t, err)
+		assert.Contains(t, result, "created successfully")
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(content))
+		assert.Equal(t, "render_template", state.EditHistory[defaultSessionID][0].Tool)
+
+		// Existing files follow write's rules.
+		_, err = state.executeRenderTemplate(ctx, tmpl, "", vars, path, "", true, false, false)
+		assert.ErrorContains(t, err, "already exists")
+	})
+
+	t.Run("template file and dry run", func(t *testing.T) {
+		state := NewState()
+		file := filepath.Join(t.TempDir(), "struct.tmpl")
+		require.NoError(t, os.WriteFile(file, []byte(tmpl), 0o644))
+		result, err := state.executeRenderTemplate(ctx, "", file, vars, "", "", false, true, false)
+		require.NoError(t, err)
+		assert.Equal(t, want, result)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		state := NewState()
+		path := filepath.Join(t.TempDir(), "out.txt")
+		_, err := state.executeRenderTemplate(ctx, "", "", nil, path, "", false, false, false)
+		assert.ErrorContains(t, err, "exactly one of")
+		_, err = state.executeRenderTemplate(ctx, "x", "", nil, "", "", false, false, false)
+		assert.ErrorContains(t, err, "file_path is required")
+		_, err = state.executeRenderTemplate(ctx, "{{.name", "", nil, path, "", false, false, false)
+		assert.ErrorContains(t, err, "Cannot parse template")
+		_, err = state.executeRenderTemplate(ctx, "{{.nmae}}", "", map[string]any{"name": "x"}, path, "", false, false, false)
+		assert.ErrorContains(t, err, "Cannot render template")
+		assert.NoFileExists(t, path)
+	})
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	for _, tc := range []struct{ in, camel, pascal, snake, kebab string }{
+		{"user store", "userStore", "UserStore", "user_store", "user-store"},
+		{"HTTPServer_config", "httpServerConfig", "HttpServerConfig", "http_server_config", "http-server-config"},
+		{"getV2Items", "getV2Items", "GetV2Items", "get_v2_items", "get-v2-items"},
+	} {
+		assert.Equal(t, tc.camel, templateFuncs["camel"].(func(string) string)(tc.in))
+		assert.Equal(t, tc.pascal, templateFuncs["pascal"].(func(string) string)(tc.in))
+		assert.Equal(t, tc.snake, templateFuncs["snake"].(func(string) string)(tc.in))
+		assert.Equal(t, tc.kebab, templateFuncs["kebab"].(func(string) string)(tc.in))
+	}
+	assert.Equal(t, "fallback", templateDefault("fallback", ""))
+	assert.Equal(t, 3.0, templateDefault("fallback", 3.0))
+	assert.Equal(t, "  a\n\n  b", templateIndent(2, "a\n\nb"))
+}
@@ -0,0 +1,269 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// replMarker is written by the interpreter drivers after each snippet finishes. It starts with an
+// ASCII record separator, which ordinary program output practically never contains.
+var replMarker = []byte("\x1eREPL_DONE\n")
+
+// replDrivers run a read-eval-print loop over a simple framing on stdin: a line holding the
+// snippet's length in bytes, then the snippet. After each snippet they print the value of a final
+// expression, if any, and then replMarker. Using a driver rather than the interpreter's own
+// interactive mode keeps prompts out of the output and tells us exactly when a snippet is done.
+var replDrivers = map[string][]string{
+	"python": {"python3", "-u", "-c", `import ast, sys, traceback
+_ns = {"__name__": "__main__", "__builtins__": __builtins__}
+def _run(src):
+    tree = ast.parse(src, "<repl>", "exec")
+    last = None
+    if tree.body and isinstance(tree.body[-1], ast.Expr):
+        last = ast.Expression(tree.body.pop().value)
+    exec(compile(tree, "<repl>", "exec"), _ns)
+    if last is not None:
+        value = eval(compile(last, "<repl>", "eval"), _ns)
+        if value is not None:
+            print(repr(value))
+_stdin = sys.stdin.buffer
+while True:
+    header = _stdin.readline()
+    if not header:
+        break
+    src = _stdin.read(int(header)).decode("utf-8")
+    try:
+        _run(src)
+    except SystemExit:
+        raise
+    except BaseException:
+        traceback.print_exc()
+    sys.stdout.flush()
+    sys.stderr.flush()
+    sys.stdout.write("\x1eREPL_DONE\n")
+    sys.stdout.flush()
+`},
+	"node": {"node", "-e", `const vm = require("vm");
+const util = require("util");
+globalThis.require = require;
+let buffered = Buffer.alloc(0);
+let queue = Promise.resolve();
+async function run(src) {
+  try {
+    let value = vm.runInThisContext(src, { filename: "<repl>" });
+    if (value && typeof value.then === "function") value = await value;
+    if (value !== undefined) console.log(util.inspect(value));
+  } catch (err) {
+    console.error(err && err.stack ? err.stack : String(err));
+  }
+  process.stdout.write("\x1eREPL_DONE\n");
+}
+process.stdin.on("data", (chunk) => {
+  buffered = Buffer.concat([buffered, chunk]);
+  for (;;) {
+    const newline = buffered.indexOf(10);
+    if (newline < 0) return;
+    const length = parseInt(buffered.subarray(0, newline).toString(), 10);
+    if (buffered.length < newline + 1 + length) return;
+    const src = buffered.subarray(newline + 1, newline + 1 + length).toString("utf8");
+    buffered = buffered.subarray(newline + 1 + length);
+    queue = queue.then(() => run(src));
+  }
+});
+`},
+}
+
+// Repl is a long-lived interpreter process that runs code snippets sent by repl_send, keeping its
+// variables and imports between them.
+type Repl struct {
+	ID        string
+	Language  string
+	Cmd       *exec.Cmd
+	Stdin     io.WriteCloser
+	Output    *replOutput
+	StartTime time.Time
+	Done      chan struct{}
+	ExitCode  int
+
+	// Sent counts the snippets written to the interpreter, and ReadAt is how much of Output has
+	// been returned to the client. Both are guarded by State.Mu.
+	Sent   int
+	ReadAt int
+
+	// sendMu keeps concurrent sends from interleaving their frames on Stdin.
+	sendMu sync.Mutex
+}
+
+// replOutput collects an interpreter's combined output, counting completion markers instead of
+// storing them.
+type replOutput struct {
+	*SyncBuffer
+
+	mu        sync.Mutex
+	pending   []byte
+	completed int
+	changed   chan struct{}
+}
+
+func newReplOutput() *replOutput {
+	return &replOutput{SyncBuffer: &SyncBuffer{}}
+}
+
+func (o *replOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	data := append(o.pending, p...)
+	o.pending = nil
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, replMarker[0])
+		if i < 0 {
+			_, _ = o.SyncBuffer.Write(data)
+			break
+		}
+		if i > 0 {
+			_, _ = o.SyncBuffer.Write(data[:i])
+		}
+		rest := data[i:]
+		switch {
+		case bytes.HasPrefix(rest, replMarker):
+			o.completed++
+			if o.changed != nil {
+				close(o.changed)
+				o.changed = nil
+			}
+			data = rest[len(replMarker):]
+		case len(rest) < len(replMarker) && bytes.HasPrefix(replMarker, rest):
+			// Possibly a marker split across writes; hold it until the rest arrives.
+			o.pending = bytes.Clone(rest)
+			data = nil
+		default:
+			_, _ = o.SyncBuffer.Write(rest[:1])
+			data = rest[1:]
+		}
+	}
+	return len(p), nil
+}
+
+// Completed returns how many snippets have finished, and a channel closed when the next one does.
+func (o *replOutput) Completed() (int, <-chan struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.changed == nil {
+		o.changed = make(chan struct{})
+	}
+	return o.completed, o.changed
+}
+
+func replLanguages() []string {
+	names := make([]string, 0, len(replDrivers))
+	for name := range replDrivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *State) executeReplStart(ctx context.Context, language string) (string, error) {
+	driver, ok := replDrivers[language]
+	if !ok {
+		return "", fmt.Errorf("Unsupported language: %s. Must be one of: %s", language, strings.Join(replLanguages(), ", "))
+	}
+	if _, err := exec.LookPath(driver[0]); err != nil {
+		return "", fmt.Errorf("Cannot start %s REPL: %s not found in PATH", language, driver[0])
+	}
+
+	cmd := exec.Command(driver[0], driver[1:]...)
+	cmd.Dir = s.workDir(ctx)
+	output := newReplOutput()
+	// A single writer for both streams makes exec share one pipe, keeping stdout and stderr in order.
+	cmd.Stdout = output
+	cmd.Stderr = output
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", fmt.Errorf("Failed to start %s REPL: %s", language, err)
+	}
+
+	if err := s.acquireShellSlot(); err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		s.releaseShellSlot()
+		return "", fmt.Errorf("Failed to start %s REPL: %s", language, err)
+	}
+
+	s.Mu.Lock()
+	replID := fmt.Sprintf("repl_%d", s.NextReplID)
+	s.NextReplID++
+	repl := &Repl{
+		ID:        replID,
+		Language:  language,
+		Cmd:       cmd,
+		Stdin:     stdin,
+		Output:    output,
+		StartTime: time.Now(),
+		Done:      make(chan struct{}),
+	}
+	s.Repls[replID] = repl
+	s.Mu.Unlock()
+
+	go func() {
+		_ = cmd.Wait()
+		s.releaseShellSlot()
+		s.Mu.Lock()
+		if cmd.ProcessState != nil {
+			repl.ExitCode = cmd.ProcessState.ExitCode()
+		}
+		close(repl.Done)
+		s.Mu.Unlock()
+	}()
+
+	return fmt.Sprintf("Started %s REPL with ID: %s", language, replID), nil
+}
+
+// lookupRepl returns the REPL with the given ID.
+func (s *State) lookupRepl(replID string) (*Repl, error) {
+	if replID == "" {
+		return nil, fmt.Errorf("repl_id is required.")
+	}
+	s.Mu.RLock()
+	repl, ok := s.Repls[replID]
+	s.Mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("REPL with ID '%s' not found.", replID)
+	}
+	return repl, nil
+}
+
+var ReplStartTool = sdk.Tool{
+	Name:        "repl_start",
+	Description: "Starts a long-lived Python or Node.js interpreter for iterative work such as data exploration, so imports and variables persist between snippets instead of being reloaded by every bash call.\n\nUsage:\n- Returns a REPL ID to pass to repl_send and repl_close.\n- The interpreter runs in the server's working directory.\n- REPLs count toward the server's limit on background processes; close them with repl_close when done.",
+}
+
+type ReplStartInput struct {
+	Language string `json:"language" jsonschema:"The interpreter to start: python or node"`
+}
+type ReplStartOutput struct {
+	Message string `json:"message"`
+}
+
+func ReplStart(ctx context.Context, req *sdk.CallToolRequest, args ReplStartInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeReplStart(withSession(ctx, req), args.Language)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &ReplStartOutput{Message: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
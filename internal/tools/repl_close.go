@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// replCloseGrace is how long a REPL may take to exit after its input is closed before it is killed.
+const replCloseGrace = 2 * time.Second
+
+func (s *State) executeReplClose(ctx context.Context, replID string) (string, error) {
+	repl, err := s.lookupRepl(replID)
+	if err != nil {
+		return "", err
+	}
+
+	// Closing stdin ends the driver loop, letting the interpreter run its exit handlers.
+	_ = repl.Stdin.Close()
+	select {
+	case <-repl.Done:
+	case <-time.After(replCloseGrace):
+		if repl.Cmd.Process != nil {
+			_ = repl.Cmd.Process.Kill()
+		}
+		<-repl.Done
+	}
+
+	s.Mu.Lock()
+	delete(s.Repls, replID)
+	s.Mu.Unlock()
+
+	return fmt.Sprintf("Closed %s REPL: %s", repl.Language, replID), nil
+}
+
+var ReplCloseTool = sdk.Tool{
+	Name:        "repl_close",
+	Description: "Stops a REPL started with repl_start, discarding its state.\n\nUsage:\n- Code still running is interrupted if the interpreter does not exit within 2 seconds.\n- Any output not yet collected with repl_send is discarded.",
+}
+
+type ReplCloseInput struct {
+	ReplID string `json:"repl_id" jsonschema:"The ID of the REPL to close"`
+}
+type ReplCloseOutput struct {
+	Message string `json:"message"`
+}
+
+func ReplClose(ctx context.Context, req *sdk.CallToolRequest, args ReplCloseInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeReplClose(ctx, args.ReplID)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &ReplCloseOutput{Message: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// defaultReplWait is how long repl_send waits for a snippet before returning it as still busy.
+const defaultReplWait = 30000
+
+type replSendResult struct {
+	ReplID   string `json:"repl_id"`
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code,omitempty"`
+	Output   string `json:"output"`
+}
+
+func (s *State) executeReplSend(ctx context.Context, replID, code string, timeoutMs int64) (string, error) {
+	repl, err := s.lookupRepl(replID)
+	if err != nil {
+		return "", err
+	}
+	if timeoutMs <= 0 {
+		timeoutMs = defaultReplWait
+	}
+	if timeoutMs > maxTimeout {
+		return "", fmt.Errorf("Timeout cannot exceed %d milliseconds (10 minutes).", maxTimeout)
+	}
+
+	if code != "" {
+		select {
+		case <-repl.Done:
+			return "", fmt.Errorf("REPL %s has exited with code %d. Start a new one with repl_start.", replID, repl.ExitCode)
+		default:
+		}
+		if err := s.confirmCommand(ctx, code); err != nil {
+			return "", err
+		}
+		repl.sendMu.Lock()
+		_, err := fmt.Fprintf(repl.Stdin, "%d\n%s", len(code), code)
+		repl.sendMu.Unlock()
+		if err != nil {
+			return "", fmt.Errorf("Failed to send code to REPL %s: %s", replID, err)
+		}
+		s.Mu.Lock()
+		repl.Sent++
+		s.Mu.Unlock()
+	}
+
+	timer := time.NewTimer(time.Duration(timeoutMs) * time.Millisecond)
+	defer timer.Stop()
+	status := "busy"
+wait:
+	for {
+		completed, changed := repl.Output.Completed()
+		s.Mu.RLock()
+		sent := repl.Sent
+		s.Mu.RUnlock()
+		if completed >= sent {
+			status = "idle"
+			break
+		}
+		select {
+		case <-changed:
+		case <-repl.Done:
+			break wait
+		case <-timer.C:
+			break wait
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	result := replSendResult{ReplID: replID, Status: status}
+	select {
+	case <-repl.Done:
+		result.Status = "exited"
+		result.ExitCode = repl.ExitCode
+	default:
+	}
+
+	s.Mu.Lock()
+	all := repl.Output.String()
+	output := all[repl.ReadAt:]
+	repl.ReadAt = len(all)
+	s.Mu.Unlock()
+
+	// Keep the most recent output, which holds the result and any traceback, when there is too much.
+	if limit := limitsFromContext(ctx).maxOutputSize; len(output) > limit {
+		omitted := len(output) - limit
+		output = fmt.Sprintf("[... %d earlier characters omitted ...]\n", omitted) + output[omitted:]
+	}
+	result.Output = output
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format output: %s", err)
+	}
+	return string(jsonBytes), nil
+}
+
+var ReplSendTool = sdk.Tool{
+	Name:        "repl_send",
+	Description: "Runs code in a REPL started with repl_start and returns the output it produced.\n\nUsage:\n- Variables, functions, and imports persist between calls.\n- The value of a final expression is printed, as in an interactive session. Exceptions are reported as tracebacks in the output; the REPL keeps running.\n- Waits up to timeout milliseconds (default 30000) for the code to finish. If it is still running, the status is \"busy\"; call repl_send again with empty code to collect more output.\n- Status is \"idle\" when all sent code has finished, and \"exited\" if the interpreter stopped.\n- Code must not read from standard input.",
+}
+
+type ReplSendInput struct {
+	ReplID  string `json:"repl_id" jsonschema:"The ID of the REPL returned by repl_start"`
+	Code    string `json:"code,omitempty" jsonschema:"The code to run. Leave empty to only collect output of code that is still running"`
+	Timeout int64  `json:"timeout,omitempty" jsonschema:"How long to wait for the code to finish, in milliseconds (default 30000, max 600000)"`
+}
+type ReplSendOutput struct {
+	Result string `json:"result"`
+}
+
+func ReplSend(ctx context.Context, req *sdk.CallToolRequest, args ReplSendInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeReplSend(ctx, args.ReplID, args.Code, args.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &ReplSendOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
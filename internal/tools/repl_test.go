@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startRepl(t *testing.T, state *State, language string) string {
+	t.Helper()
+	if _, err := exec.LookPath(replDrivers[language][0]); err != nil {
+		t.Skipf("%s not installed", replDrivers[language][0])
+	}
+	result, err := state.executeReplStart(context.Background(), language)
+	require.NoError(t, err)
+	replID := strings.TrimSpace(result[strings.LastIndex(result, " "):])
+	t.Cleanup(func() { _, _ = state.executeReplClose(context.Background(), replID) })
+	return replID
+}
+
+func sendRepl(t *testing.T, state *State, replID, code string, timeoutMs int64) replSendResult {
+	t.Helper()
+	text, err := state.executeReplSend(context.Background(), replID, code, timeoutMs)
+	require.NoError(t, err)
+	var result replSendResult
+	require.NoError(t, json.Unmarshal([]byte(text), &result))
+	return result
+}
+
+func TestReplOutput(t *testing.T) {
+	output := newReplOutput()
+	_, _ = output.Write([]byte("first\n\x1eREPL_"))
+	completed, _ := output.Completed()
+	assert.Equal(t, 0, completed)
+
+	// The marker completes across writes and is not recorded as output
+	_, _ = output.Write([]byte("DONE\nsecond\x1e not a marker\n"))
+	completed, _ = output.Completed()
+	assert.Equal(t, 1, completed)
+	assert.Equal(t, "first\nsecond\x1e not a marker\n", output.String())
+}
+
+func TestRepl_Python(t *testing.T) {
+	state := NewState()
+	replID := startRepl(t, state, "python")
+
+	t.Run("state persists between snippets", func(t *testing.T) {
+		result := sendRepl(t, state, replID, "import math\nx = 21", 0)
+		assert.Equal(t, "idle", result.Status)
+		assert.Empty(t, result.Output)
+
+		result = sendRepl(t, state, replID, "print('doubling')\nmath.floor(x * 2.5)", 0)
+		assert.Equal(t, "doubling\n52\n", result.Output)
+	})
+
+	t.Run("compound statements", func(t *testing.T) {
+		result := sendRepl(t, state, replID, "for i in range(3):\n    print(i)\n", 0)
+		assert.Equal(t, "0\n1\n2\n", result.Output)
+	})
+
+	t.Run("exceptions keep the REPL alive", func(t *testing.T) {
+		result := sendRepl(t, state, replID, "1 / 0", 0)
+		assert.Equal(t, "idle", result.Status)
+		assert.Contains(t, result.Output, "ZeroDivisionError")
+
+		result = sendRepl(t, state, replID, "x", 0)
+		assert.Equal(t, "21\n", result.Output)
+	})
+
+	t.Run("slow code is collected later", func(t *testing.T) {
+		result := sendRepl(t, state, replID, "import time\nprint('start', flush=True)\ntime.sleep(0.5)\nprint('end')", 100)
+		assert.Equal(t, "busy", result.Status)
+
+		result = sendRepl(t, state, replID, "", 5000)
+		assert.Equal(t, "idle", result.Status)
+		assert.Contains(t, result.Output, "end")
+	})
+
+	t.Run("exit", func(t *testing.T) {
+		result := sendRepl(t, state, replID, "import sys\nsys.exit(3)", 0)
+		assert.Equal(t, "exited", result.Status)
+		assert.Equal(t, 3, result.ExitCode)
+
+		_, err := state.executeReplSend(context.Background(), replID, "1", 0)
+		require.Error(t, err)
+	})
+}
+
+func TestRepl_Node(t *testing.T) {
+	state := NewState()
+	replID := startRepl(t, state, "node")
+
+	result := sendRepl(t, state, replID, "const path = require('path'); var n = 20", 0)
+	assert.Equal(t, "idle", result.Status)
+
+	result = sendRepl(t, state, replID, "console.log(path.basename('/a/b.txt')); n + 22", 0)
+	assert.Equal(t, "b.txt\n42\n", result.Output)
+
+	result = sendRepl(t, state, replID, "Promise.resolve(n * 2)", 0)
+	assert.Equal(t, "40\n", result.Output)
+
+	result = sendRepl(t, state, replID, "undefinedFunction()", 0)
+	assert.Contains(t, result.Output, "ReferenceError")
+}
+
+func TestRepl_Lifecycle(t *testing.T) {
+	state := NewState()
+
+	_, err := state.executeReplStart(context.Background(), "ruby")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Unsupported language")
+
+	_, err = state.executeReplSend(context.Background(), "repl_99", "1", 0)
+	require.Error(t, err)
+
+	replID := startRepl(t, state, "python")
+	require.NoError(t, state.SetConcurrencyLimits(0, 1))
+	_, err = state.executeReplStart(context.Background(), "python")
+	require.Error(t, err, "REPLs count toward the background process limit")
+
+	_, err = state.executeReplClose(context.Background(), replID)
+	require.NoError(t, err)
+	assert.Empty(t, state.Repls)
+	_, err = state.executeReplClose(context.Background(), replID)
+	require.Error(t, err)
+}
+
+func TestRepl_ConfirmCommand(t *testing.T) {
+	state := NewState()
+	replID := startRepl(t, state, "python")
+	require.NoError(t, state.SetConfirmPolicy(false, []string{`shutil\.rmtree`}, ""))
+
+	_, err := state.executeReplSend(context.Background(), replID, "import shutil; shutil.rmtree('/tmp/build')", 0)
+	assert.ErrorContains(t, err, "requires user confirmation")
+	assert.Zero(t, state.Repls[replID].Sent, "refused code is never sent")
+
+	result := sendRepl(t, state, replID, "print(1 + 1)", 0)
+	assert.Equal(t, "idle", result.Status)
+	assert.Contains(t, result.Output, "2")
+}
@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxRetries bounds how many times a failing command is rerun, and maxRetryBackoff the
+	// initial wait between attempts, so a retried call still finishes in reasonable time.
+	maxRetries      = 10
+	maxRetryBackoff = 60000
+	// defaultRetryBackoff is the wait before the first retry when none is given.
+	defaultRetryBackoff = 1000
+	// attemptOutputTail is how much of a failed attempt's output is kept. The end of the output
+	// usually holds the error that explains the failure.
+	attemptOutputTail = 2000
+)
+
+// BashAttempt records one failed attempt of a command run with retries.
+type BashAttempt struct {
+	ExitCode   int    `json:"exit_code,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+	Output     string `json:"output,omitempty"`
+}
+
+// executeWithRetries runs a foreground command, running it again while it exits non-zero or times
+// out, up to retries more times. The result is that of the last attempt, with the failed attempts
+// before it listed in Attempts. Errors other than timeouts, such as failing to start the shell,
+// are returned at once since another attempt would fail the same way.
+func (s *State) executeWithRetries(ctx context.Context, run func() (*BashResult, error), retries int, backoffMs int64) (*BashResult, error) {
+	if backoffMs == 0 {
+		backoffMs = defaultRetryBackoff
+	}
+	backoff := time.Duration(backoffMs) * time.Millisecond
+
+	var attempts []BashAttempt
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		result, err := run()
+		if err != nil && !errors.Is(err, errCommandTimeout) {
+			return nil, err
+		}
+		if err == nil && result.ExitCode == 0 {
+			result.Attempts = attempts
+			if len(attempts) > 0 {
+				result.Result = fmt.Sprintf("%s\n(Succeeded on attempt %d of %d.)", result.Result, attempt, retries+1)
+			}
+			return result, nil
+		}
+		if attempt > retries {
+			if err != nil {
+				return nil, fmt.Errorf("All %d attempts failed. Last attempt: %s", attempt, err)
+			}
+			result.Attempts = attempts
+			result.Result = fmt.Sprintf("%s\n(Failed on all %d attempts.)", result.Result, attempt)
+			return result, nil
+		}
+
+		failed := BashAttempt{DurationMs: time.Since(start).Milliseconds()}
+		if err != nil {
+			failed.Error = err.Error()
+		} else {
+			failed.ExitCode = result.ExitCode
+			failed.Output = outputTail(result.Result, attemptOutputTail)
+		}
+		attempts = append(attempts, failed)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// outputTail returns the last limit bytes of output, not splitting a UTF-8 character.
+func outputTail(output string, limit int) string {
+	if len(output) <= limit {
+		return output
+	}
+	start := len(output) - limit
+	for start < len(output) && !utf8.RuneStart(output[start]) {
+		start++
+	}
+	return output[start:]
+}
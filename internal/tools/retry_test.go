@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBashRetries(t *testing.T) {
+	state := NewState()
+
+	t.Run("succeeds on a later attempt", func(t *testing.T) {
+		counter := filepath.Join(t.TempDir(), "count")
+		// Fails until the third run
+		command := fmt.Sprintf("n=$(cat %[1]s 2>/dev/null || echo 0); n=$((n+1)); echo $n > %[1]s; echo attempt $n; [ $n -ge 3 ]", counter)
+		result, err := state.executeBashCommand(context.Background(), command, "", 0, false, bashOptions{Retries: 4, RetryBackoffMs: 10})
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.ExitCode)
+		assert.Contains(t, result.Result, "attempt 3")
+		assert.Contains(t, result.Result, "Succeeded on attempt 3 of 5.")
+		require.Len(t, result.Attempts, 2)
+		assert.Equal(t, 1, result.Attempts[0].ExitCode)
+		assert.Contains(t, result.Attempts[0].Output, "attempt 1")
+		assert.Contains(t, result.Attempts[1].Output, "attempt 2")
+	})
+
+	t.Run("reports every failed attempt", func(t *testing.T) {
+		result, err := state.executeBashCommand(context.Background(), "echo nope; exit 2", "", 0, false, bashOptions{Retries: 2, RetryBackoffMs: 1})
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.ExitCode)
+		assert.Contains(t, result.Result, "Failed on all 3 attempts.")
+		assert.Len(t, result.Attempts, 2)
+	})
+
+	t.Run("retries timeouts", func(t *testing.T) {
+		_, err := state.executeBashCommand(context.Background(), "sleep 5", "", 50, false, bashOptions{Retries: 1, RetryBackoffMs: 1})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "All 2 attempts failed. Last attempt: Command timed out.")
+	})
+
+	t.Run("no retries after success", func(t *testing.T) {
+		result, err := state.executeBashCommand(context.Background(), "echo ok", "", 0, false, bashOptions{Retries: 3})
+		require.NoError(t, err)
+		assert.Equal(t, "ok\n", result.Result)
+		assert.Empty(t, result.Attempts)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		_, err := state.executeBashCommand(context.Background(), "true", "", 0, false, bashOptions{Retries: maxRetries + 1})
+		require.Error(t, err)
+		_, err = state.executeBashCommand(context.Background(), "true", "", 0, false, bashOptions{Retries: 1, RetryBackoffMs: -1})
+		require.Error(t, err)
+		_, err = state.executeBashCommand(context.Background(), "true", "", 0, true, bashOptions{Retries: 1})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "retries cannot be combined with run_in_background.")
+	})
+}
+
+func TestOutputTail(t *testing.T) {
+	assert.Equal(t, "abc", outputTail("abc", 5))
+	assert.Equal(t, "ef", outputTail("abcdef", 2))
+	assert.Equal(t, "é", outputTail("éé", 3), "a cut never splits a character")
+}
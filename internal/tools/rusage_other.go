@@ -0,0 +1,10 @@
+//go:build !unix
+
+package tools
+
+import "os"
+
+// maxRSS is unavailable on platforms without wait4 rusage reporting.
+func maxRSS(state *os.ProcessState) int64 {
+	return 0
+}
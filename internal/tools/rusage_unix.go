@@ -0,0 +1,25 @@
+//go:build unix
+
+package tools
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// maxRSS returns the peak resident set size in bytes of an exited process, as reported by
+// wait4's rusage. Linux reports ru_maxrss in kilobytes while Darwin reports bytes.
+func maxRSS(state *os.ProcessState) int64 {
+	if state == nil {
+		return 0
+	}
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok || usage == nil {
+		return 0
+	}
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return int64(usage.Maxrss)
+	}
+	return int64(usage.Maxrss) * 1024
+}
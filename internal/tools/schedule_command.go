@@ -0,0 +1,199 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxScheduleDelay bounds how far ahead a one-off command may be scheduled.
+const maxScheduleDelay = 7 * 24 * time.Hour
+
+// ScheduledJob is a command that schedule_command runs later, once after a delay or repeatedly on
+// a cron schedule. Each run starts a background shell, whose output is read with bash_output.
+type ScheduledJob struct {
+	ID          string
+	Command     string
+	Description string
+	Cron        string
+	MaxRuns     int
+	CreatedAt   time.Time
+
+	// NextRun is when the job fires next, zero once it has finished. Runs counts the shells
+	// started, Skipped the runs passed over because the previous one was still running, and
+	// LastShellID and LastError describe the most recent run. All are guarded by State.Mu.
+	NextRun     time.Time
+	Runs        int
+	Skipped     int
+	LastShellID string
+	LastError   string
+
+	args     []string
+	schedule *cronSchedule
+	timer    *time.Timer
+	// ctx carries the scheduling session, so runs notify it when they finish.
+	ctx context.Context
+}
+
+func (s *State) executeScheduleCommand(ctx context.Context, command, description string, delayMs int64, cronExpr string, maxRuns int, opts bashOptions) (string, error) {
+	if command == "" {
+		return "", fmt.Errorf("Command cannot be empty.")
+	}
+	if (delayMs > 0) == (cronExpr != "") {
+		return "", fmt.Errorf("Specify exactly one of delay_ms or cron.")
+	}
+	if maxRuns < 0 {
+		return "", fmt.Errorf("max_runs cannot be negative.")
+	}
+	delay := time.Duration(delayMs) * time.Millisecond
+	if delay > maxScheduleDelay {
+		return "", fmt.Errorf("delay_ms cannot exceed %d milliseconds (7 days).", maxScheduleDelay.Milliseconds())
+	}
+	var schedule *cronSchedule
+	if cronExpr != "" {
+		var err error
+		if schedule, err = parseCron(cronExpr); err != nil {
+			return "", err
+		}
+	}
+	args, err := s.shellArgs(opts, command)
+	if err != nil {
+		return "", err
+	}
+	// Runs happen with nobody to ask, so any confirmation is requested once, now.
+	if err := s.confirmCommand(ctx, command); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	job := &ScheduledJob{
+		Command:     command,
+		Description: description,
+		Cron:        cronExpr,
+		MaxRuns:     maxRuns,
+		CreatedAt:   now,
+		args:        args,
+		schedule:    schedule,
+		ctx:         context.WithoutCancel(ctx),
+	}
+	if schedule != nil {
+		job.NextRun = schedule.Next(now)
+		if job.NextRun.IsZero() {
+			return "", fmt.Errorf("Cron expression %q never matches.", cronExpr)
+		}
+	} else {
+		job.NextRun = now.Add(delay)
+		job.MaxRuns = 1
+	}
+
+	s.Mu.Lock()
+	job.ID = fmt.Sprintf("job_%d", s.NextJobID)
+	s.NextJobID++
+	s.ScheduledJobs[job.ID] = job
+	job.timer = time.AfterFunc(time.Until(job.NextRun), func() { s.runScheduledJob(job) })
+	s.Mu.Unlock()
+
+	return fmt.Sprintf("Scheduled job %s, next run at %s", job.ID, job.NextRun.Format(time.RFC3339)), nil
+}
+
+// runScheduledJob starts one run of job as a background shell and arms the timer for the next.
+func (s *State) runScheduledJob(job *ScheduledJob) {
+	s.Mu.Lock()
+	if s.ScheduledJobs[job.ID] != job {
+		// Cancelled after the timer fired.
+		s.Mu.Unlock()
+		return
+	}
+	previous := s.BackgroundShells[job.LastShellID]
+	overlapping := previous != nil && shellStatus(previous) == "running"
+	s.Mu.Unlock()
+
+	shellID := ""
+	var runErr error
+	if !overlapping {
+		cmd := exec.Command(job.args[0], job.args[1:]...)
+		if wd, err := os.Getwd(); err == nil {
+			cmd.Dir = wd
+		}
+		description := job.Description
+		if description == "" {
+			description = "Scheduled " + job.ID
+		}
+		shell := newBackgroundShell(cmd, job.Command, description, job.ID)
+		if runErr = s.launchShell(shell); runErr == nil {
+			s.Mu.Lock()
+			s.addShell(shell)
+			s.Mu.Unlock()
+			s.monitorShell(shell, sessionFromContext(job.ctx))
+			shellID = shell.ID
+			if s.useSpool(nil) {
+				// A failure leaves the output in memory, where it is still readable.
+				_ = s.spoolShell(shell)
+			}
+		}
+	}
+
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	switch {
+	case overlapping:
+		job.Skipped++
+	case runErr != nil:
+		job.Runs++
+		job.LastError = runErr.Error()
+	default:
+		job.Runs++
+		job.LastShellID = shellID
+		job.LastError = ""
+	}
+
+	job.NextRun = time.Time{}
+	if job.schedule != nil && (job.MaxRuns == 0 || job.Runs < job.MaxRuns) && s.ScheduledJobs[job.ID] == job {
+		job.NextRun = job.schedule.Next(time.Now())
+	}
+	if !job.NextRun.IsZero() {
+		job.timer = time.AfterFunc(time.Until(job.NextRun), func() { s.runScheduledJob(job) })
+	}
+}
+
+// scheduledJobStatus reports whether a job will run again. Callers must hold s.Mu.
+func scheduledJobStatus(job *ScheduledJob) string {
+	if job.NextRun.IsZero() {
+		return "finished"
+	}
+	return "scheduled"
+}
+
+var ScheduleCommandTool = sdk.Tool{
+	Name:        "schedule_command",
+	Description: "Schedules a shell command to run later, once after a delay or repeatedly on a cron schedule, for periodic polling such as checking CI status or scraping logs.\n\nUsage:\n- Set exactly one of delay_ms (run once) or cron (a five-field expression in server local time, e.g. \"*/5 * * * *\" for every 5 minutes, or @hourly/@daily).\n- Each run starts a background shell; read its output with bash_output using the shell ID shown by list_scheduled_jobs, and find past runs with list_shells (their label is the job ID).\n- A run is skipped if the job's previous run is still going.\n- max_runs stops a recurring job after that many runs.\n- Stop a job with cancel_scheduled_job. Jobs do not survive a server restart.",
+}
+
+type ScheduleCommandInput struct {
+	Command     string `json:"command" jsonschema:"The command to run"`
+	Description string `json:"description,omitempty" jsonschema:"Clear, concise description of what this job does in 5-10 words"`
+	DelayMs     int64  `json:"delay_ms,omitempty" jsonschema:"Run the command once after this many milliseconds (max 7 days)"`
+	Cron        string `json:"cron,omitempty" jsonschema:"Run the command on this cron schedule: minute hour day-of-month month day-of-week"`
+	MaxRuns     int    `json:"max_runs,omitempty" jsonschema:"Stop a cron job after this many runs. Defaults to no limit"`
+	Shell       string `json:"shell,omitempty" jsonschema:"The shell that runs the command, as for bash. Defaults to bash"`
+}
+type ScheduleCommandOutput struct {
+	Message string `json:"message"`
+}
+
+func ScheduleCommand(ctx context.Context, req *sdk.CallToolRequest, args ScheduleCommandInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeScheduleCommand(withSession(ctx, req), args.Command, args.Description, args.DelayMs, args.Cron, args.MaxRuns, bashOptions{Shell: args.Shell})
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &ScheduleCommandOutput{Message: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleCommand(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("delayed run", func(t *testing.T) {
+		state := NewState()
+		result, err := state.executeScheduleCommand(ctx, "echo scheduled", "", 50, "", 0, bashOptions{})
+		require.NoError(t, err)
+		assert.Contains(t, result, "Scheduled job job_1")
+
+		require.Eventually(t, func() bool {
+			state.Mu.RLock()
+			defer state.Mu.RUnlock()
+			return state.ScheduledJobs["job_1"].LastShellID != ""
+		}, 5*time.Second, 10*time.Millisecond)
+
+		state.Mu.RLock()
+		job := state.ScheduledJobs["job_1"]
+		shell := state.BackgroundShells[job.LastShellID]
+		assert.Equal(t, "finished", scheduledJobStatus(job))
+		state.Mu.RUnlock()
+		<-shell.Done
+		assert.Equal(t, "scheduled\n", shell.Stdout.String())
+		assert.Equal(t, "job_1", shell.Label)
+
+		list, err := state.executeListScheduledJobs(ctx)
+		require.NoError(t, err)
+		var parsed listScheduledJobsResult
+		require.NoError(t, json.Unmarshal([]byte(list), &parsed))
+		require.Len(t, parsed.Jobs, 1)
+		assert.Equal(t, 1, parsed.Jobs[0].Runs)
+		assert.Empty(t, parsed.Jobs[0].NextRun)
+	})
+
+	t.Run("cron runs until max_runs", func(t *testing.T) {
+		state := NewState()
+		_, err := state.executeScheduleCommand(ctx, "true", "Poll", 0, "* * * * *", 2, bashOptions{})
+		require.NoError(t, err)
+		state.Mu.RLock()
+		job := state.ScheduledJobs["job_1"]
+		next := job.NextRun
+		state.Mu.RUnlock()
+		assert.True(t, next.After(time.Now()))
+		defer func() { _, _ = state.executeCancelScheduledJob(ctx, "job_1") }()
+
+		// Fire the job directly rather than waiting for the minute to turn.
+		state.runScheduledJob(job)
+		state.Mu.RLock()
+		first := state.BackgroundShells[job.LastShellID]
+		assert.Equal(t, "scheduled", scheduledJobStatus(job))
+		state.Mu.RUnlock()
+		<-first.Done
+
+		state.runScheduledJob(job)
+		state.Mu.RLock()
+		assert.Equal(t, 2, job.Runs)
+		assert.Equal(t, "finished", scheduledJobStatus(job))
+		state.Mu.RUnlock()
+	})
+
+	t.Run("skips a run while the previous one is running", func(t *testing.T) {
+		state := NewState()
+		_, err := state.executeScheduleCommand(ctx, "sleep 10", "", 0, "@daily", 0, bashOptions{})
+		require.NoError(t, err)
+		defer func() { _, _ = state.executeKillAllShells(ctx, "") }()
+		defer func() { _, _ = state.executeCancelScheduledJob(ctx, "job_1") }()
+
+		state.Mu.RLock()
+		job := state.ScheduledJobs["job_1"]
+		state.Mu.RUnlock()
+		state.runScheduledJob(job)
+		state.runScheduledJob(job)
+
+		state.Mu.RLock()
+		defer state.Mu.RUnlock()
+		assert.Equal(t, 1, job.Runs)
+		assert.Equal(t, 1, job.Skipped)
+		assert.Len(t, state.BackgroundShells, 1)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		state := NewState()
+		_, err := state.executeScheduleCommand(ctx, "echo never", "", 200, "", 0, bashOptions{})
+		require.NoError(t, err)
+		result, err := state.executeCancelScheduledJob(ctx, "job_1")
+		require.NoError(t, err)
+		assert.Contains(t, result, "Cancelled scheduled job: job_1")
+
+		time.Sleep(300 * time.Millisecond)
+		assert.Empty(t, state.BackgroundShells)
+		_, err = state.executeCancelScheduledJob(ctx, "job_1")
+		require.Error(t, err)
+
+		list, err := state.executeListScheduledJobs(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "No scheduled jobs.", list)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		state := NewState()
+		for _, tc := range []struct {
+			delay   int64
+			cron    string
+			message string
+		}{
+			{0, "", "exactly one of delay_ms or cron"},
+			{100, "* * * * *", "exactly one of delay_ms or cron"},
+			{0, "* * *", "expected 5 fields"},
+			{0, "0 0 30 2 *", "never matches"},
+			{8 * 24 * 3600 * 1000, "", "cannot exceed"},
+		} {
+			_, err := state.executeScheduleCommand(ctx, "true", "", tc.delay, tc.cron, 0, bashOptions{})
+			require.Error(t, err)
+			assert.True(t, strings.Contains(err.Error(), tc.message), err.Error())
+		}
+		_, err := state.executeScheduleCommand(ctx, "true", "", 100, "", 0, bashOptions{Shell: "csh"})
+		require.Error(t, err)
+		assert.Empty(t, state.ScheduledJobs)
+	})
+}
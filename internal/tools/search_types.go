@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// searchTypeName matches the type names ripgrep accepts in --type-add definitions.
+var searchTypeName = regexp.MustCompile(`^[A-Za-z0-9_+-]+$`)
+
+// AddSearchTypes registers custom ripgrep file types for the Grep type filter. Each definition uses
+// ripgrep's --type-add syntax: "name:glob" adds a glob to a type, and "name:include:a,b" makes a
+// type the union of existing ones.
+func (s *State) AddSearchTypes(defs []string) error {
+	for _, def := range defs {
+		name, globs, ok := strings.Cut(def, ":")
+		if !ok || !searchTypeName.MatchString(name) || strings.TrimSpace(globs) == "" {
+			return fmt.Errorf("Invalid type definition: %s. Must be of the form name:glob (e.g. proto:*.proto3)", def)
+		}
+	}
+	s.Mu.Lock()
+	s.SearchTypes = append(s.SearchTypes, defs...)
+	s.Mu.Unlock()
+	return nil
+}
+
+func (s *State) searchTypes() []string {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return append([]string(nil), s.SearchTypes...)
+}
+
+type searchType struct {
+	Name  string   `json:"name"`
+	Globs []string `json:"globs"`
+}
+
+type listSearchTypesResult struct {
+	Types []searchType `json:"types"`
+	Count int          `json:"count"`
+}
+
+func (s *State) executeListSearchTypes(ctx context.Context, filter string) (string, error) {
+	var rgArgs []string
+	for _, def := range s.searchTypes() {
+		rgArgs = append(rgArgs, "--type-add", def)
+	}
+	rgArgs = append(rgArgs, "--type-list")
+	output, err := execRipgrep(ctx, rgArgs...)
+	if err != nil {
+		return "", err
+	}
+
+	result := listSearchTypesResult{Types: []searchType{}}
+	for _, t := range parseTypeList(output) {
+		if filter != "" && !strings.Contains(t.Name, filter) && !containsSubstring(t.Globs, filter) {
+			continue
+		}
+		result.Types = append(result.Types, t)
+	}
+	if len(result.Types) == 0 {
+		return fmt.Sprintf("No search types match %q", filter), nil
+	}
+	result.Count = len(result.Types)
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format search types: %s", err)
+	}
+	return string(jsonBytes), nil
+}
+
+// parseTypeList parses the output of rg --type-list, which has one "name: glob, glob" line per type.
+func parseTypeList(output string) []searchType {
+	var types []searchType
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		name, globs, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		types = append(types, searchType{Name: name, Globs: strings.Split(globs, ", ")})
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types
+}
+
+func containsSubstring(values []string, substr string) bool {
+	for _, v := range values {
+		if strings.Contains(v, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+var ListSearchTypesTool = sdk.Tool{
+	Name:        "list_search_types",
+	Description: "Lists the file types that the Grep tool's type parameter accepts, with the globs each one matches.\n\nUsage:\n- Includes ripgrep's built-in types and any custom types configured on the server.\n- Use filter to narrow the list by type name or glob, e.g. \"proto\" or \".tf\".\n- Prefer a type filter over a glob when a suitable type exists.",
+}
+
+type ListSearchTypesInput struct {
+	Filter string `json:"filter,omitempty" jsonschema:"Only list types whose name or globs contain this text"`
+}
+type ListSearchTypesOutput struct {
+	Types string `json:"types"`
+}
+
+func ListSearchTypes(ctx context.Context, req *sdk.CallToolRequest, args ListSearchTypesInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeListSearchTypes(ctx, args.Filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &ListSearchTypesOutput{Types: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddSearchTypes(t *testing.T) {
+	t.Run("valid definitions", func(t *testing.T) {
+		state := NewState()
+		require.NoError(t, state.AddSearchTypes([]string{"proto:*.proto3", "infra:include:tf,yaml"}))
+		assert.Equal(t, []string{"proto:*.proto3", "infra:include:tf,yaml"}, state.searchTypes())
+	})
+
+	t.Run("invalid definitions", func(t *testing.T) {
+		for _, def := range []string{"proto", ":*.proto", "proto:", "bad name:*.x"} {
+			state := NewState()
+			require.Error(t, state.AddSearchTypes([]string{def}), def)
+			assert.Empty(t, state.searchTypes())
+		}
+	})
+}
+
+func TestParseTypeList(t *testing.T) {
+	types := parseTypeList("rust: *.rs\ngo: *.go\nc: *.[chH], *.[chH].in, *.cats\n")
+	require.Len(t, types, 3)
+	assert.Equal(t, searchType{Name: "c", Globs: []string{"*.[chH]", "*.[chH].in", "*.cats"}}, types[0])
+	assert.Equal(t, "go", types[1].Name)
+	assert.Equal(t, "rust", types[2].Name)
+}
+
+func TestListSearchTypes(t *testing.T) {
+	state := GetState()
+	state.Mu.Lock()
+	saved := state.SearchTypes
+	state.SearchTypes = []string{"gotmpl:*.gotmpl"}
+	state.Mu.Unlock()
+	t.Cleanup(func() {
+		state.Mu.Lock()
+		state.SearchTypes = saved
+		state.Mu.Unlock()
+	})
+
+	result, _, err := ListSearchTypes(context.Background(), &sdk.CallToolRequest{}, ListSearchTypesInput{Filter: "go"})
+	require.NoError(t, err)
+	text := result.Content[0].(*sdk.TextContent).Text
+	assert.Contains(t, text, `"*.go"`)
+	assert.Contains(t, text, `"*.gotmpl"`)
+}
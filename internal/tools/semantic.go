@@ -0,0 +1,318 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+)
+
+const (
+	// Files are split into chunks of semanticChunkLines lines, each overlapping the previous one by
+	// semanticChunkOverlap lines so that code straddling a boundary is whole in some chunk.
+	semanticChunkLines   = 40
+	semanticChunkOverlap = 10
+
+	// maxSemanticChunkChars bounds the text of a chunk sent to the embedder, since minified or
+	// generated files can have very long lines.
+	maxSemanticChunkChars = 4000
+
+	// Files larger than maxSemanticFileSize are skipped, as they are rarely hand-written source. An
+	// index may cover at most maxSemanticFiles files.
+	maxSemanticFileSize = 1 << 20
+	maxSemanticFiles    = 20_000
+
+	// semanticEmbedBatch is how many chunks are sent to the embedder per request, and
+	// semanticEmbedTimeout bounds each request.
+	semanticEmbedBatch   = 64
+	semanticEmbedTimeout = 60 * time.Second
+
+	defaultEmbeddingModel = "text-embedding-3-small"
+)
+
+// EmbeddingIndex holds the embedded chunks of the files under Root, as built by semantic_index with
+// one embedding model. Indexes are saved under the semantic index directory and survive restarts.
+type EmbeddingIndex struct {
+	Root      string
+	Model     string
+	Files     map[string]*semanticFile
+	UpdatedAt time.Time
+
+	// mu serializes updates and guards Files, so a search never sees a half-updated file.
+	mu sync.Mutex
+}
+
+// semanticFile is one indexed file. ModTime and Size identify the version that was embedded, so
+// unchanged files are skipped when the index is updated and changed ones are reported as stale.
+type semanticFile struct {
+	ModTime time.Time
+	Size    int64
+	Chunks  []semanticChunk
+}
+
+type semanticChunk struct {
+	StartLine int
+	EndLine   int
+	Text      string
+	Vector    []float32
+}
+
+// embedder calls an OpenAI-compatible embeddings endpoint, such as OpenAI's own, Ollama's, or a
+// local inference server's /v1/embeddings.
+type embedder struct {
+	url    string
+	model  string
+	apiKey string
+}
+
+// SetEmbedder enables semantic_index and semantic_search, embedding text with model at endpoint.
+// An empty endpoint disables them. Indexes are stored in indexDir, or under the user's cache
+// directory when it is empty.
+func (s *State) SetEmbedder(endpoint, model, apiKey, indexDir string) error {
+	if endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Invalid embedding URL: %s. Must be an http or https URL", endpoint)
+		}
+	}
+	if model == "" {
+		model = defaultEmbeddingModel
+	}
+	if indexDir != "" {
+		resolved, err := resolvePath(indexDir)
+		if err != nil {
+			return fmt.Errorf("semantic index directory must be absolute, not relative")
+		}
+		indexDir = resolved
+	}
+	s.Mu.Lock()
+	s.EmbeddingURL = endpoint
+	s.EmbeddingModel = model
+	s.EmbeddingAPIKey = apiKey
+	s.SemanticIndexDir = indexDir
+	s.Mu.Unlock()
+	return nil
+}
+
+// embedder returns the configured embedder, or an error explaining how to enable semantic search.
+func (s *State) embedder() (*embedder, error) {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	if s.EmbeddingURL == "" {
+		return nil, fmt.Errorf("Semantic search is not enabled. Start the server with --embedding-url pointing at an OpenAI-compatible embeddings endpoint.")
+	}
+	return &embedder{url: s.EmbeddingURL, model: s.EmbeddingModel, apiKey: s.EmbeddingAPIKey}, nil
+}
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// embed returns one vector per text, in order.
+func (e *embedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	payload, err := json.Marshal(embeddingRequest{Model: e.model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("Cannot encode embedding request: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, semanticEmbedTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot create embedding request: %s", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("Embedding endpoint unavailable: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("Embedding endpoint returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var decoded embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("Embedding endpoint returned an invalid response: %s", err)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, item := range decoded.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("Embedding endpoint returned an embedding for unknown input %d.", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("Embedding endpoint returned no embedding for input %d.", i)
+		}
+	}
+	return vectors, nil
+}
+
+// chunkFile splits a file's content into overlapping chunks of lines, dropping blank ones.
+func chunkFile(content string) []semanticChunk {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	var chunks []semanticChunk
+	step := semanticChunkLines - semanticChunkOverlap
+	for start := 0; start < len(lines); start += step {
+		end := min(start+semanticChunkLines, len(lines))
+		text := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(text) != "" {
+			if len(text) > maxSemanticChunkChars {
+				text = text[:maxSemanticChunkChars]
+			}
+			chunks = append(chunks, semanticChunk{StartLine: start + 1, EndLine: end, Text: text})
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0 when they differ in
+// length, as vectors from different models do.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// semanticIndexDir returns the directory holding saved indexes.
+func (s *State) semanticIndexDir() string {
+	s.Mu.RLock()
+	dir := s.SemanticIndexDir
+	s.Mu.RUnlock()
+	if dir != "" {
+		return dir
+	}
+	if cache, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(cache, "claude-tools-semantic")
+	}
+	return filepath.Join(os.TempDir(), "claude-tools-semantic")
+}
+
+// semanticIndexKey names an index by its root and model, since vectors from different models
+// cannot be compared.
+func semanticIndexKey(root, model string) string {
+	sum := sha256.Sum256([]byte(root + "\x00" + model))
+	return hex.EncodeToString(sum[:12])
+}
+
+// semanticIndex returns the index of root for model, loading it from disk if this server has not
+// used it yet. When there is none, a new empty index is returned if create is set, and nil
+// otherwise.
+func (s *State) semanticIndex(root, model string, create bool) (*EmbeddingIndex, error) {
+	key := semanticIndexKey(root, model)
+	s.Mu.RLock()
+	index, ok := s.SemanticIndexes[key]
+	s.Mu.RUnlock()
+	if ok {
+		return index, nil
+	}
+	// Saved indexes can be large, so they are read without holding the state lock.
+	index, err := loadSemanticIndex(filepath.Join(s.semanticIndexDir(), key+".gob"))
+	if err != nil {
+		return nil, err
+	}
+	if index == nil {
+		if !create {
+			return nil, nil
+		}
+		index = &EmbeddingIndex{Root: root, Model: model, Files: make(map[string]*semanticFile)}
+	}
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	// Another call may have loaded or created the index meanwhile; every caller must share one.
+	if existing, ok := s.SemanticIndexes[key]; ok {
+		return existing, nil
+	}
+	s.SemanticIndexes[key] = index
+	return index, nil
+}
+
+// findSemanticIndex returns the index for model whose root is path or its nearest indexed
+// ancestor, or nil when no index covers path.
+func (s *State) findSemanticIndex(path, model string) (*EmbeddingIndex, error) {
+	for dir := path; ; dir = filepath.Dir(dir) {
+		index, err := s.semanticIndex(dir, model, false)
+		if err != nil || index != nil {
+			return index, err
+		}
+		if filepath.Dir(dir) == dir {
+			return nil, nil
+		}
+	}
+}
+
+func loadSemanticIndex(path string) (*EmbeddingIndex, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open semantic index: %s", err)
+	}
+	defer f.Close()
+	var index EmbeddingIndex
+	if err := gob.NewDecoder(f).Decode(&index); err != nil {
+		return nil, fmt.Errorf("Cannot read semantic index %s: %s. Delete it and rebuild with semantic_index.", path, err)
+	}
+	if index.Files == nil {
+		index.Files = make(map[string]*semanticFile)
+	}
+	return &index, nil
+}
+
+// save writes the index to dir. The caller must hold index.mu.
+func (index *EmbeddingIndex) save(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("Cannot create semantic index directory: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(index); err != nil {
+		return fmt.Errorf("Cannot encode semantic index: %s", err)
+	}
+	path := filepath.Join(dir, semanticIndexKey(index.Root, index.Model)+".gob")
+	if err := writeFileAtomic(vfs.OS{}, path, buf.Bytes(), 0o600, true); err != nil {
+		return fmt.Errorf("Cannot save semantic index: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type semanticIndexStats struct {
+	Indexed   int
+	Unchanged int
+	Removed   int
+	Skipped   int
+	Chunks    int
+}
+
+// semanticSource is a file under an index's root that may need embedding.
+type semanticSource struct {
+	path string
+	info fs.FileInfo
+}
+
+func (s *State) executeSemanticIndex(ctx context.Context, path string, progress *progressReporter) (string, error) {
+	emb, err := s.embedder()
+	if err != nil {
+		return "", err
+	}
+	root, err := s.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(root); err != nil {
+		return "", err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", fmt.Errorf("path does not exist: %s", root)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("path is not a directory: %s", root)
+	}
+
+	sources, err := s.semanticSources(root)
+	if err != nil {
+		return "", err
+	}
+	index, err := s.semanticIndex(root, emb.model, true)
+	if err != nil {
+		return "", err
+	}
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	var stats semanticIndexStats
+	present := make(map[string]bool, len(sources))
+	var changed []semanticSource
+	for _, source := range sources {
+		present[source.path] = true
+		if file, ok := index.Files[source.path]; ok && file.ModTime.Equal(source.info.ModTime()) && file.Size == source.info.Size() {
+			stats.Unchanged++
+			continue
+		}
+		changed = append(changed, source)
+	}
+	for path := range index.Files {
+		if !present[path] {
+			delete(index.Files, path)
+			stats.Removed++
+		}
+	}
+
+	// Chunks are embedded in batches that may span files. A file joins the index once all of its
+	// chunks are embedded, and the index is saved after every batch, so an interrupted run keeps
+	// the files it finished and the next run picks up where it stopped.
+	dir := s.semanticIndexDir()
+	type pendingFile struct {
+		path      string
+		file      *semanticFile
+		remaining int
+	}
+	type pendingChunk struct {
+		owner *pendingFile
+		chunk *semanticChunk
+		input string
+	}
+	var batch []pendingChunk
+	done := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		inputs := make([]string, len(batch))
+		for i, p := range batch {
+			inputs[i] = p.input
+		}
+		vectors, err := emb.embed(ctx, inputs)
+		if err != nil {
+			return err
+		}
+		for i, p := range batch {
+			p.chunk.Vector = vectors[i]
+			p.owner.remaining--
+			if p.owner.remaining == 0 {
+				index.Files[p.owner.path] = p.owner.file
+				stats.Indexed++
+			}
+		}
+		batch = batch[:0]
+		index.UpdatedAt = time.Now()
+		progress.report(ctx, float64(done), fmt.Sprintf("Indexed %d of %d changed files", stats.Indexed, len(changed)))
+		return index.save(dir)
+	}
+	for _, source := range changed {
+		done++
+		content, err := os.ReadFile(source.path)
+		if err != nil || bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0 {
+			// Unreadable and binary files are left out, and any earlier version is dropped.
+			delete(index.Files, source.path)
+			stats.Skipped++
+			continue
+		}
+		file := &semanticFile{ModTime: source.info.ModTime(), Size: source.info.Size(), Chunks: chunkFile(string(content))}
+		if len(file.Chunks) == 0 {
+			index.Files[source.path] = file
+			stats.Indexed++
+			continue
+		}
+		owner := &pendingFile{path: source.path, file: file, remaining: len(file.Chunks)}
+		rel, _ := filepath.Rel(root, source.path)
+		for i := range file.Chunks {
+			// The path tells the embedder what the code is part of, which the code alone often
+			// does not.
+			input := filepath.ToSlash(rel) + "\n" + file.Chunks[i].Text
+			batch = append(batch, pendingChunk{owner: owner, chunk: &file.Chunks[i], input: input})
+			if len(batch) == semanticEmbedBatch {
+				if err := flush(); err != nil {
+					return "", fmt.Errorf("%s Indexed %d of %d changed files before stopping; run semantic_index again to continue.", err, stats.Indexed, len(changed))
+				}
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return "", fmt.Errorf("%s Indexed %d of %d changed files before stopping; run semantic_index again to continue.", err, stats.Indexed, len(changed))
+	}
+	if stats.Removed > 0 || stats.Skipped > 0 || index.UpdatedAt.IsZero() {
+		index.UpdatedAt = time.Now()
+		if err := index.save(dir); err != nil {
+			return "", err
+		}
+	}
+
+	for _, file := range index.Files {
+		stats.Chunks += len(file.Chunks)
+	}
+	return fmt.Sprintf("Semantic index of %s updated: %d files indexed, %d unchanged, %d removed, %d skipped. The index holds %d chunks from %d files.",
+		root, stats.Indexed, stats.Unchanged, stats.Removed, stats.Skipped, stats.Chunks, len(index.Files)), nil
+}
+
+// semanticSources lists the regular files under root worth indexing, in path order. Like Glob, it
+// skips dependency, build, and VCS directories, and it also skips hidden directories, denied paths,
+// and files too large to be hand-written source.
+func (s *State) semanticSources(root string) ([]semanticSource, error) {
+	var sources []semanticSource
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if s.checkPathAllowed(path) != nil {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(d.Name(), ".") || slices.Contains(globSkipDirs, d.Name())) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() == 0 || info.Size() > maxSemanticFileSize {
+			return nil
+		}
+		if len(sources) == maxSemanticFiles {
+			return fmt.Errorf("more than %d files to index. Index a narrower directory.", maxSemanticFiles)
+		}
+		sources = append(sources, semanticSource{path: path, info: info})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Cannot index %s: %s", root, err)
+	}
+	return sources, nil
+}
+
+var SemanticIndexTool = sdk.Tool{
+	Name:        "semantic_index",
+	Description: "Builds or updates the semantic search index of a directory, for use by semantic_search.\n\nUsage:\n- path must be an absolute directory, typically the project root. Searches anywhere beneath it use this index.\n- Files are split into overlapping chunks of about 40 lines, each embedded by the server's embedding endpoint and stored locally.\n- Updates are incremental: only new or changed files are embedded again, and deleted files are dropped. Run it again after significant edits.\n- Hidden, dependency, build, and VCS directories, binary files, and files over 1MB are skipped.\n- Only available when the server was started with --embedding-url.",
+}
+
+type SemanticIndexInput struct {
+	Path string `json:"path" jsonschema:"The absolute path of the directory to index"`
+}
+type SemanticIndexOutput struct {
+	Message string `json:"message"`
+}
+
+func SemanticIndex(ctx context.Context, req *sdk.CallToolRequest, args SemanticIndexInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeSemanticIndex(ctx, args.Path, newProgressReporter(req))
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &SemanticIndexOutput{Message: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultSemanticResults = 10
+	maxSemanticResults     = 50
+)
+
+type semanticMatch struct {
+	File      string  `json:"file"`
+	StartLine int     `json:"start_line"`
+	EndLine   int     `json:"end_line"`
+	Score     float64 `json:"score"`
+	Stale     bool    `json:"stale,omitempty"`
+	Text      string  `json:"text"`
+}
+
+type semanticSearchResult struct {
+	Root      string          `json:"root"`
+	IndexedAt string          `json:"indexed_at"`
+	Matches   []semanticMatch `json:"matches"`
+}
+
+func (s *State) executeSemanticSearch(ctx context.Context, query, path string, topK int) (string, error) {
+	emb, err := s.embedder()
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("query is required.")
+	}
+	if topK < 0 || topK > maxSemanticResults {
+		return "", fmt.Errorf("top_k must be between 1 and %d.", maxSemanticResults)
+	}
+	if topK == 0 {
+		topK = defaultSemanticResults
+	}
+	scope := s.workDir(ctx)
+	if scope == "" {
+		return "", fmt.Errorf("Cannot determine working directory")
+	}
+	if path != "" {
+		if scope, err = s.resolvePath(path); err != nil {
+			return "", err
+		}
+	}
+	if err := s.checkPathAllowed(scope); err != nil {
+		return "", err
+	}
+
+	index, err := s.findSemanticIndex(scope, emb.model)
+	if err != nil {
+		return "", err
+	}
+	if index == nil {
+		return "", fmt.Errorf("No semantic index covers %s. Build one with semantic_index, typically for the project root.", scope)
+	}
+	vectors, err := emb.embed(ctx, []string{query})
+	if err != nil {
+		return "", err
+	}
+	queryVector := vectors[0]
+
+	index.mu.Lock()
+	var matches []semanticMatch
+	stat := make(map[string]*semanticFile)
+	for file, indexed := range index.Files {
+		if file != scope && !strings.HasPrefix(file, scope+string(filepath.Separator)) {
+			continue
+		}
+		for _, chunk := range indexed.Chunks {
+			matches = append(matches, semanticMatch{
+				File:      file,
+				StartLine: chunk.StartLine,
+				EndLine:   chunk.EndLine,
+				Score:     cosineSimilarity(queryVector, chunk.Vector),
+				Text:      chunk.Text,
+			})
+		}
+		stat[file] = indexed
+	}
+	root, indexedAt := index.Root, index.UpdatedAt
+	index.mu.Unlock()
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if matches[i].File != matches[j].File {
+			return matches[i].File < matches[j].File
+		}
+		return matches[i].StartLine < matches[j].StartLine
+	})
+	// Denied paths are checked here too, since the denylist may have grown since indexing.
+	result := semanticSearchResult{Root: root, IndexedAt: indexedAt.Format(time.RFC3339), Matches: []semanticMatch{}}
+	for _, match := range matches {
+		if len(result.Matches) == topK {
+			break
+		}
+		if s.checkPathAllowed(match.File) != nil {
+			continue
+		}
+		info, err := os.Stat(match.File)
+		indexed := stat[match.File]
+		match.Stale = err != nil || !info.ModTime().Equal(indexed.ModTime) || info.Size() != indexed.Size
+		match.Score = math.Round(match.Score*10000) / 10000
+		result.Matches = append(result.Matches, match)
+	}
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format matches: %s", err)
+	}
+	output := string(jsonBytes)
+	if err := checkOutputSize(ctx, output, "semantic_search"); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+var SemanticSearchTool = sdk.Tool{
+	Name:        "semantic_search",
+	Description: "Finds the code most related to a natural-language query, such as \"where is retry logic handled\", using the embeddings built by semantic_index. Complements Grep, which finds exact text.\n\nUsage:\n- path narrows the search to a file or directory and defaults to the working directory. It must lie within a directory indexed by semantic_index.\n- Returns up to top_k chunks (default 10, max 50), most relevant first, each with its file, line range, similarity score, and text.\n- stale is set on chunks whose file changed since it was indexed; their line numbers may be off. Run semantic_index again to refresh.\n- Use the line ranges with the Read tool to see the surrounding code.\n- Only available when the server was started with --embedding-url.",
+}
+
+type SemanticSearchInput struct {
+	Query string `json:"query" jsonschema:"A natural-language description of the code to find"`
+	Path  string `json:"path,omitempty" jsonschema:"File or directory to search within. Defaults to working directory"`
+	TopK  int    `json:"top_k,omitempty" jsonschema:"How many chunks to return (default 10, max 50)"`
+}
+type SemanticSearchOutput struct {
+	Result string `json:"result"`
+}
+
+func SemanticSearch(ctx context.Context, req *sdk.CallToolRequest, args SemanticSearchInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeSemanticSearch(ctx, args.Query, args.Path, args.TopK)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &SemanticSearchOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEmbedder serves bag-of-words embeddings, so texts sharing words score as similar. It counts
+// the inputs it embeds.
+func fakeEmbedder(t *testing.T, embedded *atomic.Int64) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+		var req embeddingRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		var resp embeddingResponse
+		for i, input := range req.Input {
+			vector := make([]float32, 64)
+			for _, word := range strings.FieldsFunc(strings.ToLower(input), func(r rune) bool { return !unicode.IsLetter(r) }) {
+				h := fnv.New32a()
+				h.Write([]byte(word))
+				vector[h.Sum32()%64]++
+			}
+			resp.Data = append(resp.Data, struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			}{Index: i, Embedding: vector})
+		}
+		embedded.Add(int64(len(req.Input)))
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newSemanticState(t *testing.T, embedderURL, indexDir string) *State {
+	t.Helper()
+	state := NewState()
+	require.NoError(t, state.SetEmbedder(embedderURL, "fake", "test-key", indexDir))
+	return state
+}
+
+func TestChunkFile(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 75; i++ {
+		lines = append(lines, "line")
+	}
+	chunks := chunkFile(strings.Join(lines, "\n") + "\n")
+	require.Len(t, chunks, 3)
+	assert.Equal(t, [2]int{1, 40}, [2]int{chunks[0].StartLine, chunks[0].EndLine})
+	assert.Equal(t, [2]int{31, 70}, [2]int{chunks[1].StartLine, chunks[1].EndLine})
+	assert.Equal(t, [2]int{61, 75}, [2]int{chunks[2].StartLine, chunks[2].EndLine})
+
+	assert.Empty(t, chunkFile("\n\n  \n"))
+}
+
+func TestSemanticSearch_Disabled(t *testing.T) {
+	state := NewState()
+	_, err := state.executeSemanticIndex(context.Background(), t.TempDir(), nil)
+	assert.ErrorContains(t, err, "--embedding-url")
+	_, err = state.executeSemanticSearch(context.Background(), "retry", t.TempDir(), 0)
+	assert.ErrorContains(t, err, "--embedding-url")
+
+	assert.Error(t, state.SetEmbedder("ftp://example.com", "", "", ""))
+	assert.Error(t, state.SetEmbedder("http://localhost:1", "", "", "relative/dir"))
+}
+
+func TestSemanticSearch_IndexAndSearch(t *testing.T) {
+	var embedded atomic.Int64
+	server := fakeEmbedder(t, &embedded)
+	indexDir := t.TempDir()
+	state := newSemanticState(t, server.URL, indexDir)
+
+	root := t.TempDir()
+	retry := filepath.Join(root, "net", "retry.go")
+	require.NoError(t, os.MkdirAll(filepath.Dir(retry), 0o755))
+	require.NoError(t, os.WriteFile(retry, []byte("package net\n\n// retry the request with backoff until the retry budget is spent\nfunc retry() {}\n"), 0o644))
+	parse := filepath.Join(root, "parse.go")
+	require.NoError(t, os.WriteFile(parse, []byte("package main\n\n// parse the configuration file\nfunc parse() {}\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "node_modules"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "node_modules", "dep.js"), []byte("retry retry retry"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "blob.bin"), []byte("retry\x00\x01"), 0o644))
+
+	_, err := state.executeSemanticSearch(context.Background(), "retry", root, 0)
+	assert.ErrorContains(t, err, "No semantic index covers")
+
+	message, err := state.executeSemanticIndex(context.Background(), root, nil)
+	require.NoError(t, err)
+	assert.Contains(t, message, "2 files indexed, 0 unchanged, 0 removed, 1 skipped")
+	assert.Equal(t, int64(2), embedded.Load())
+
+	search := func(state *State, query, path string) semanticSearchResult {
+		t.Helper()
+		result, err := state.executeSemanticSearch(context.Background(), query, path, 0)
+		require.NoError(t, err)
+		var parsed semanticSearchResult
+		require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+		return parsed
+	}
+	result := search(state, "where is the retry budget handled", root)
+	assert.Equal(t, root, result.Root)
+	require.Len(t, result.Matches, 2)
+	assert.Equal(t, retry, result.Matches[0].File)
+	assert.Equal(t, 1, result.Matches[0].StartLine)
+	assert.Equal(t, 4, result.Matches[0].EndLine)
+	assert.Greater(t, result.Matches[0].Score, result.Matches[1].Score)
+	assert.False(t, result.Matches[0].Stale)
+
+	t.Run("path narrows the search", func(t *testing.T) {
+		result := search(state, "parse", filepath.Join(root, "net"))
+		require.Len(t, result.Matches, 1)
+		assert.Equal(t, retry, result.Matches[0].File)
+	})
+
+	t.Run("changed files are stale until reindexed", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(parse, []byte("package main\n\n// parse flags\nfunc parse() {}\n\nfunc extra() {}\n"), 0o644))
+		result := search(state, "parse", root)
+		require.Len(t, result.Matches, 2)
+		assert.Equal(t, parse, result.Matches[0].File)
+		assert.True(t, result.Matches[0].Stale)
+
+		require.NoError(t, os.Remove(retry))
+		embedded.Store(0)
+		message, err := state.executeSemanticIndex(context.Background(), root, nil)
+		require.NoError(t, err)
+		assert.Contains(t, message, "1 files indexed, 0 unchanged, 1 removed")
+		assert.Equal(t, int64(1), embedded.Load())
+
+		result = search(state, "parse", root)
+		require.Len(t, result.Matches, 1)
+		assert.False(t, result.Matches[0].Stale)
+	})
+
+	t.Run("indexes persist across restarts", func(t *testing.T) {
+		restarted := newSemanticState(t, server.URL, indexDir)
+		result := search(restarted, "parse", root)
+		require.Len(t, result.Matches, 1)
+		assert.Equal(t, parse, result.Matches[0].File)
+
+		embedded.Store(0)
+		message, err := restarted.executeSemanticIndex(context.Background(), root, nil)
+		require.NoError(t, err)
+		assert.Contains(t, message, "0 files indexed, 1 unchanged")
+		assert.Equal(t, int64(0), embedded.Load())
+	})
+}
+
+func TestSemanticIndex_EmbedderFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+	state := newSemanticState(t, server.URL, t.TempDir())
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("hello"), 0o644))
+
+	_, err := state.executeSemanticIndex(context.Background(), root, nil)
+	assert.ErrorContains(t, err, "HTTP 404: model not found")
+	assert.ErrorContains(t, err, "Indexed 0 of 1 changed files")
+}
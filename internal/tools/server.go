@@ -1,8 +1,17 @@
 package tools
 
 import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/brwse/claude-tools-mcp/internal/oidc"
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 // State manages global application state for the tools package, including
@@ -16,6 +25,10 @@ type State struct {
 	// used to detect when file content may have changed between operations.
 	ReadFiles map[string]time.Time
 
+	// ReadContents holds the content of files up to maxMergeBaseBytes as of their ReadFiles time:
+	// the base that edit's merge option reconciles later changes on disk against.
+	ReadContents map[string]readContent
+
 	// BackgroundShells maps shell IDs to their corresponding BackgroundShell
 	// structs, allowing callers to monitor running processes and retrieve output.
 	BackgroundShells map[string]*BackgroundShell
@@ -24,6 +37,213 @@ type State struct {
 	// shell IDs (e.g., "shell_1", "shell_2"). Must be incremented atomically
 	// when protected by Mu.Lock() to ensure IDs remain globally unique.
 	NextShellID int
+
+	// Todos holds each session's task list as last written by the todo_write tool, keyed by
+	// session ID. Each write replaces the session's list wholesale.
+	Todos map[string][]TodoItem
+
+	// sessionUse records when each session last added per-session state, as a tick of sessionClock,
+	// so that the least recently active session can be dropped once more than maxSessions are kept.
+	// See useSession.
+	sessionUse   map[string]uint64
+	sessionClock uint64
+
+	// EditHistory holds each session's recent Write and Edit mutations, oldest first, keyed by
+	// session ID, so that list_edits and undo_edit can revert them.
+	EditHistory map[string][]EditRecord
+
+	// NextEditID is the ID given to the next recorded edit. IDs are unique across sessions.
+	NextEditID int
+
+	// WorkDirs holds the working directory each session chose with the worktree tool, keyed by
+	// session ID. Commands run by bash, git, and repl_start start there; sessions without one use
+	// the server's working directory.
+	WorkDirs map[string]string
+
+	// Changes holds each session's change manifest, keyed by session ID and then path: every file
+	// and directory the session's tools created, modified, or deleted, as it was before the first
+	// change, for list_changes.
+	Changes map[string]map[string]*FileChange
+
+	// Snapshots maps snapshot IDs to the files saved by the snapshot tool under SnapshotDir, or a
+	// directory under the system temp directory when it is empty. NextSnapshotID numbers them.
+	Snapshots      map[string]*WorkspaceSnapshot
+	NextSnapshotID int
+	SnapshotDir    string
+
+	// pathLocks holds the locks of the paths tools are currently changing, keyed by path, and
+	// pathLocksMu guards it. See lockPaths.
+	pathLocksMu sync.Mutex
+	pathLocks   map[string]*pathLock
+
+	// OverlayMode makes write, edit, and delete_file stage their changes in Overlays, keyed by
+	// session ID and then path, until commit_changes writes them to disk. See SetOverlayMode.
+	OverlayMode bool
+	Overlays    map[string]map[string]*stagedFile
+
+	// Workspaces maps workspace names to their roots, with symlinks resolved, for
+	// workspace://name/relative/path URIs. See SetWorkspaces.
+	Workspaces map[string]string
+
+	// FS is the filesystem the file tools work on: the real disk unless SetFilesystem chose
+	// another.
+	FS vfs.FS
+
+	// DefaultFileMode is the permission set given to files created by Write when no explicit mode
+	// is requested. Like open(2), the process umask is applied on top of it.
+	DefaultFileMode os.FileMode
+
+	// BackupByDefault makes Write, Edit, and delete_file save the previous content before changing
+	// a file, unless a call explicitly opts out.
+	BackupByDefault bool
+
+	// BackupDir is where backups are stored. When empty, each backup is kept next to its file with
+	// a .bak suffix.
+	BackupDir string
+
+	// Backups maps each path backed up since the server started to where its backup was saved.
+	// restore_backup only restores from these, never from a .bak file it did not write.
+	Backups map[string]string
+
+	// SearchTypes holds extra ripgrep file type definitions in --type-add form (e.g.
+	// "proto:*.proto3"), applied to every Grep and list_search_types call.
+	SearchTypes []string
+
+	// MaxFileSize is the largest file, in bytes, that Read returns without an explicit limit.
+	MaxFileSize int64
+
+	// MaxOutputSize bounds the characters a tool call returns, and MaxResults the entries returned by
+	// listing and search tools, unless a call overrides them (see OutputLimitsMiddleware).
+	MaxOutputSize int
+	MaxResults    int
+
+	// MaxConcurrentCommands and MaxBackgroundShells cap how many foreground bash commands and
+	// background shells may run at once; zero means unlimited. RunningCommands and RunningShells
+	// count those currently running.
+	MaxConcurrentCommands int
+	MaxBackgroundShells   int
+	RunningCommands       int
+	RunningShells         int
+	// shellQueue holds the shells waiting for a background shell slot, first in first out. Each
+	// channel is closed when its shell is handed a slot.
+	shellQueue []chan struct{}
+
+	// DeniedPaths lists path patterns that file tools refuse to read, search, or modify, such as
+	// credential directories. See SetDeniedPaths for the pattern syntax.
+	DeniedPaths []string
+
+	// SpoolByDefault makes background shells keep their output in files under SpoolDir rather
+	// than in memory, unless a call opts out. An empty SpoolDir uses a directory under the system
+	// temp directory.
+	SpoolByDefault bool
+	SpoolDir       string
+
+	// ArtifactsDir is where bash calls with save_output write their output. Empty uses a directory
+	// under the system temp directory.
+	ArtifactsDir string
+
+	// ScheduledJobs maps job IDs to the commands scheduled by schedule_command, and NextJobID
+	// numbers them.
+	ScheduledJobs map[string]*ScheduledJob
+	NextJobID     int
+
+	// Repls maps REPL IDs to the interpreter processes started by repl_start, and NextReplID
+	// numbers them like NextShellID does shells.
+	Repls      map[string]*Repl
+	NextReplID int
+
+	// Watches maps watch IDs to the file watches started by watch_path, and NextWatchID numbers
+	// them.
+	Watches     map[string]*Watch
+	NextWatchID int
+
+	// AllowedShells lists the shells bash calls may select; empty allows every supported shell.
+	AllowedShells []string
+
+	// LoginShell runs commands in login shells unless a call opts out, and InitScript, when set, is
+	// sourced before each command. See SetShellEnvironment.
+	LoginShell bool
+	InitScript string
+
+	// ConfirmDeletes, ConfirmCommands, and ConfirmOutside make delete_file calls, bash commands
+	// matching one of the patterns, and file changes outside the given directory wait for the user's
+	// approval via elicitation. See SetConfirmPolicy.
+	ConfirmDeletes  bool
+	ConfirmCommands []*regexp.Regexp
+	ConfirmOutside  string
+
+	// ApprovalURL is the webhook that must allow tool calls matching ApprovalRules before they run,
+	// waiting at most ApprovalTimeout. Requests are signed with ApprovalSecret when it is set. See
+	// SetApprovalWebhook.
+	ApprovalURL     string
+	ApprovalRules   []ApprovalRule
+	ApprovalTimeout time.Duration
+	ApprovalSecret  string
+
+	// APIKeys are static credentials clients may present, each granting scopes and optionally
+	// confined to a workspace. See LoadAPIKeys and AuthMiddleware.
+	APIKeys []APIKey
+
+	// OIDC, if set, verifies JWTs from an OpenID Connect issuer as an alternative to API keys.
+	// Their scopes are read from the scope and scp claims, less OIDCScopePrefix, falling back to
+	// OIDCDefaultScopes when a token names none. See ConfigureOIDC.
+	OIDC              *oidc.Verifier
+	OIDCScopePrefix   string
+	OIDCDefaultScopes []string
+
+	// RESTSession is the in-process session the REST API calls tools through. Only its calls may
+	// carry their token info in _meta; see SetRESTSession.
+	RESTSession *sdk.ServerSession
+
+	// EmbeddingURL is the OpenAI-compatible embeddings endpoint used by semantic_index and
+	// semantic_search, which are disabled while it is empty. Requests name EmbeddingModel and carry
+	// EmbeddingAPIKey as a bearer token when it is set. See SetEmbedder.
+	EmbeddingURL    string
+	EmbeddingModel  string
+	EmbeddingAPIKey string
+
+	// SemanticIndexDir is where semantic indexes are saved. Empty uses a directory under the user's
+	// cache directory. SemanticIndexes holds the indexes loaded or built by this server, keyed by
+	// root and model.
+	SemanticIndexDir string
+	SemanticIndexes  map[string]*EmbeddingIndex
+
+	// LanguageServers lists the language servers used by the definition, references, hover, and
+	// rename_symbol tools, first match wins. lspClients holds the running ones, keyed by command and
+	// project root, and lspMu guards it.
+	LanguageServers []LanguageServerConfig
+	lspMu           sync.Mutex
+	lspClients      map[string]*lspClient
+
+	// Formatters lists the formatters used by format_file, first match wins. FormatOnWrite makes
+	// write and edit format the files they change.
+	Formatters    []FormatterConfig
+	FormatOnWrite bool
+
+	// Tokenizer is count_tokens' default tokenizer. TokenizerCommand, if set, is the external
+	// command the "command" tokenizer runs.
+	Tokenizer        string
+	TokenizerCommand []string
+
+	// LineIndexes caches the line offsets of recently read large files, keyed by path, so that
+	// repeated offset/limit reads need not rescan them.
+	LineIndexes map[string]*LineIndex
+
+	// RecentCalls holds the last maxRecentCalls tool calls, oldest first, for the dashboard. See
+	// CallLogMiddleware.
+	RecentCalls []CallRecord
+
+	// SlowCallThreshold, when nonzero, makes CallLogMiddleware write calls taking at least that long
+	// to SlowCallLog, and slowCallMu keeps their lines whole. See SetSlowCallLog.
+	SlowCallThreshold time.Duration
+	SlowCallLog       io.Writer
+	slowCallMu        sync.Mutex
+
+	// recorder, when set, records every tool call to a cassette file, and player, when set, answers
+	// tool calls from one instead of running them. See RecordCalls, ReplayCalls, and
+	// CassetteMiddleware.
+	recorder *cassetteRecorder
+	player   *cassettePlayer
 }
 
 // globalState is the singleton instance of State for the entire tools package.
@@ -36,10 +256,42 @@ func init() {
 }
 
 func NewState() *State {
+	deniedPaths, _ := expandDeniedPaths(defaultDeniedPaths)
 	return &State{
 		ReadFiles:        make(map[string]time.Time),
+		ReadContents:     make(map[string]readContent),
 		BackgroundShells: make(map[string]*BackgroundShell),
 		NextShellID:      1,
+		ScheduledJobs:    make(map[string]*ScheduledJob),
+		NextJobID:        1,
+		Repls:            make(map[string]*Repl),
+		NextReplID:       1,
+		Watches:          make(map[string]*Watch),
+		NextWatchID:      1,
+		Todos:            make(map[string][]TodoItem),
+		sessionUse:       make(map[string]uint64),
+		EditHistory:      make(map[string][]EditRecord),
+		Backups:          make(map[string]string),
+		NextEditID:       1,
+		WorkDirs:         make(map[string]string),
+		Changes:          make(map[string]map[string]*FileChange),
+		Overlays:         make(map[string]map[string]*stagedFile),
+		Snapshots:        make(map[string]*WorkspaceSnapshot),
+		Workspaces:       make(map[string]string),
+		pathLocks:        make(map[string]*pathLock),
+		NextSnapshotID:   1,
+		FS:               vfs.OS{},
+		DefaultFileMode:  defaultFileMode,
+		MaxFileSize:      absoluteMaxFileSize,
+		MaxOutputSize:    absoluteMaxOutputSize,
+		MaxResults:       absoluteMaxResults,
+		DeniedPaths:      deniedPaths,
+		LineIndexes:      make(map[string]*LineIndex),
+		SemanticIndexes:  make(map[string]*EmbeddingIndex),
+		LanguageServers:  defaultLanguageServers,
+		lspClients:       make(map[string]*lspClient),
+		Formatters:       defaultFormatters,
+		Tokenizer:        "heuristic",
 	}
 }
 
@@ -47,3 +299,37 @@ func NewState() *State {
 func GetState() *State {
 	return globalState
 }
+
+// SetDefaultFileMode configures the permissions for newly created files from an octal string
+// such as "644".
+func (s *State) SetDefaultFileMode(mode string) error {
+	perm, err := parseFileMode(mode)
+	if err != nil {
+		return err
+	}
+	s.Mu.Lock()
+	s.DefaultFileMode = perm
+	s.Mu.Unlock()
+	return nil
+}
+
+// forgetPath drops read tracking and cached line indexes for path and, when path is a directory, for
+// everything beneath it.
+// Called after a path is deleted or moved away so stale entries can't vouch for a new file later
+// created at the same location.
+func (s *State) forgetPath(path string) {
+	prefix := path + string(filepath.Separator)
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	for tracked := range s.ReadFiles {
+		if tracked == path || strings.HasPrefix(tracked, prefix) {
+			delete(s.ReadFiles, tracked)
+			delete(s.ReadContents, tracked)
+		}
+	}
+	for indexed := range s.LineIndexes {
+		if indexed == path || strings.HasPrefix(indexed, prefix) {
+			delete(s.LineIndexes, indexed)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"os"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// defaultSessionID keys per-session state for requests that arrive without a session ID, such as
+// direct calls in tests or clients that never negotiated one.
+const defaultSessionID = "default"
+
+// maxSessions bounds how many sessions keep per-session state. Session IDs come from clients, so
+// rather than let the maps grow with every new ID, the least recently active session's state is
+// dropped to make room.
+const maxSessions = 1000
+
+// useSession marks session as the most recently active one and, when that makes more than
+// maxSessions sessions with state, drops the state of the least recently active. Call it whenever
+// per-session state is added. The caller must hold s.Mu.
+func (s *State) useSession(session string) {
+	s.sessionClock++
+	s.sessionUse[session] = s.sessionClock
+	if len(s.sessionUse) <= maxSessions {
+		return
+	}
+	oldest := session
+	for id, used := range s.sessionUse {
+		if used < s.sessionUse[oldest] {
+			oldest = id
+		}
+	}
+	s.dropSession(oldest)
+}
+
+// dropSession forgets all per-session state of session, including changes staged in its overlay
+// and not yet committed. The caller must hold s.Mu.
+func (s *State) dropSession(session string) {
+	delete(s.sessionUse, session)
+	delete(s.Todos, session)
+	delete(s.EditHistory, session)
+	delete(s.WorkDirs, session)
+	delete(s.Changes, session)
+	delete(s.Overlays, session)
+}
+
+// sessionKey is the context key under which tool handlers stash the calling client session, so
+// that work outliving the request (such as background shells) can notify the client later.
+type sessionKey struct{}
+
+func withSession(ctx context.Context, req *sdk.CallToolRequest) context.Context {
+	if req == nil || req.Session == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, sessionKey{}, req.Session)
+}
+
+func sessionFromContext(ctx context.Context) *sdk.ServerSession {
+	session, _ := ctx.Value(sessionKey{}).(*sdk.ServerSession)
+	return session
+}
+
+// sessionIDFromContext returns the ID of the session stored by withSession, for per-session state
+// touched by tools that receive only a context.
+func sessionIDFromContext(ctx context.Context) string {
+	session := sessionFromContext(ctx)
+	if session == nil || session.ID() == "" {
+		return defaultSessionID
+	}
+	return session.ID()
+}
+
+// workDir returns the calling session's working directory, falling back to the server's own. It
+// returns "" if neither can be determined, which leaves commands in the inherited directory.
+func (s *State) workDir(ctx context.Context) string {
+	s.Mu.RLock()
+	dir, ok := s.WorkDirs[sessionIDFromContext(ctx)]
+	s.Mu.RUnlock()
+	if ok {
+		return dir
+	}
+	wd, _ := os.Getwd()
+	return wd
+}
+
+// sessionID returns the MCP session ID of the request, used to key per-session state such as the
+// todo list. In stateless mode the ID comes from the client's Mcp-Session-Id header when present.
+func sessionID(req *sdk.CallToolRequest) string {
+	if req == nil || req.Session == nil || req.Session.ID() == "" {
+		return defaultSessionID
+	}
+	return req.Session.ID()
+}
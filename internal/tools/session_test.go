@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionEviction(t *testing.T) {
+	state := NewState()
+	ctx := context.Background()
+	todos := []TodoItem{{Content: "task", Status: "pending", ActiveForm: "Doing task"}}
+	session := func(i int) string { return fmt.Sprintf("session-%d", i) }
+
+	for i := range maxSessions {
+		_, err := state.executeTodoWrite(ctx, session(i), todos)
+		require.NoError(t, err)
+	}
+	// Touching the oldest session makes session-1 the least recently active instead.
+	_, err := state.executeTodoWrite(ctx, session(0), todos)
+	require.NoError(t, err)
+	_, err = state.executeTodoWrite(ctx, session(maxSessions), todos)
+	require.NoError(t, err)
+
+	assert.Len(t, state.Todos, maxSessions)
+	assert.Len(t, state.sessionUse, maxSessions)
+	assert.Contains(t, state.Todos, session(0))
+	assert.NotContains(t, state.Todos, session(1))
+	assert.Contains(t, state.Todos, session(maxSessions))
+}
+
+func TestSessionEviction_EditHistory(t *testing.T) {
+	state := NewState()
+	ctx := context.Background()
+	state.recordEdit(ctx, "write", "/tmp/x", true, []byte("a"), []byte("b"))
+	require.Contains(t, state.EditHistory, defaultSessionID)
+
+	todos := []TodoItem{{Content: "task", Status: "pending", ActiveForm: "Doing task"}}
+	for i := range maxSessions {
+		_, err := state.executeTodoWrite(ctx, fmt.Sprintf("session-%d", i), todos)
+		require.NoError(t, err)
+	}
+	assert.NotContains(t, state.EditHistory, defaultSessionID, "the least recently active session's history is dropped")
+}
+
+func TestSessionEviction_WorkDirChangesAndOverlay(t *testing.T) {
+	state := NewState()
+	ctx := context.Background()
+	dir := t.TempDir()
+	state.setWorkDir(ctx, dir)
+	state.noteChange(ctx, "write", filepath.Join(dir, "a.txt"))
+	state.stageFile(ctx, filepath.Join(dir, "b.txt"), []byte("b"), 0o644, false)
+	require.Contains(t, state.WorkDirs, defaultSessionID)
+	require.Contains(t, state.Changes, defaultSessionID)
+	require.Contains(t, state.Overlays, defaultSessionID)
+
+	todos := []TodoItem{{Content: "task", Status: "pending", ActiveForm: "Doing task"}}
+	for i := range maxSessions {
+		_, err := state.executeTodoWrite(ctx, fmt.Sprintf("session-%d", i), todos)
+		require.NoError(t, err)
+	}
+	assert.NotContains(t, state.WorkDirs, defaultSessionID)
+	assert.NotContains(t, state.Changes, defaultSessionID)
+	assert.NotContains(t, state.Overlays, defaultSessionID)
+}
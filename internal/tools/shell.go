@@ -0,0 +1,162 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// defaultShell runs commands that don't request a shell.
+const defaultShell = "bash"
+
+// shellSpec describes how to run a command string with a shell.
+type shellSpec struct {
+	// args precede the command string, and loginArgs replace them for a login shell; nil when the
+	// shell has no login mode.
+	args      []string
+	loginArgs []string
+	// source is the command that runs an init script in the current shell, or empty when init
+	// scripts written for POSIX shells don't apply.
+	source string
+}
+
+// shellCommands maps each supported shell name to how it runs a command string.
+var shellCommands = map[string]shellSpec{
+	"bash":   {args: []string{"bash", "-c"}, loginArgs: []string{"bash", "-l", "-c"}, source: "."},
+	"sh":     {args: []string{"sh", "-c"}, loginArgs: []string{"sh", "-l", "-c"}, source: "."},
+	"zsh":    {args: []string{"zsh", "-c"}, loginArgs: []string{"zsh", "-l", "-c"}, source: "."},
+	"fish":   {args: []string{"fish", "-c"}, loginArgs: []string{"fish", "-l", "-c"}, source: "source"},
+	"pwsh":   {args: []string{"pwsh", "-NoProfile", "-NonInteractive", "-Command"}, loginArgs: []string{"pwsh", "-Login", "-NonInteractive", "-Command"}},
+	"python": {args: []string{"python3", "-c"}},
+}
+
+// bashOptions holds the optional settings of a bash call.
+type bashOptions struct {
+	// Shell names the entry of shellCommands that runs the command; empty means defaultShell.
+	Shell string
+
+	// Login runs the shell as a login shell, so it reads the user's profile; nil uses the
+	// server's default.
+	Login *bool
+
+	// Expect lists prompts to answer, which runs the command on a pseudo-terminal.
+	Expect []ExpectStep
+
+	// Label names a background shell, and After lists shell IDs or labels that must complete
+	// successfully before it starts.
+	Label string
+	After []string
+
+	// QueueIfBusy queues a background shell for a slot when the concurrency cap is reached,
+	// rather than failing.
+	QueueIfBusy bool
+
+	// Spool keeps a background shell's output in files rather than memory; nil uses the server's
+	// default.
+	Spool *bool
+
+	// SaveOutput writes a foreground command's full output to a file in the artifacts directory
+	// and returns only a preview of it.
+	SaveOutput bool
+
+	// Retries reruns a failed foreground command up to this many times, waiting RetryBackoffMs
+	// before the first retry and twice as long before each one after it.
+	Retries        int
+	RetryBackoffMs int64
+}
+
+// supportedShells returns the names of all shells the server knows how to run, sorted.
+func supportedShells() []string {
+	names := make([]string, 0, len(shellCommands))
+	for name := range shellCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetAllowedShells restricts which shells bash calls may select. An empty list allows every
+// supported shell.
+func (s *State) SetAllowedShells(shells []string) error {
+	for _, shell := range shells {
+		if _, ok := shellCommands[shell]; !ok {
+			return fmt.Errorf("Unknown shell: %s. Must be one of: %s", shell, strings.Join(supportedShells(), ", "))
+		}
+	}
+	s.Mu.Lock()
+	s.AllowedShells = shells
+	s.Mu.Unlock()
+	return nil
+}
+
+// SetShellEnvironment configures how commands pick up the user's environment: login makes shells
+// login shells by default, and initScript, when set, is sourced before every command run by a
+// shell that can source it (for example to activate nvm, pyenv, or direnv).
+func (s *State) SetShellEnvironment(login bool, initScript string) error {
+	if initScript != "" {
+		resolved, err := resolvePath(initScript)
+		if err != nil {
+			return fmt.Errorf("init script path must be absolute, not relative")
+		}
+		info, err := os.Stat(resolved)
+		if err != nil || info.IsDir() {
+			return fmt.Errorf("init script does not exist: %s", resolved)
+		}
+		initScript = resolved
+	}
+	s.Mu.Lock()
+	s.LoginShell = login
+	s.InitScript = initScript
+	s.Mu.Unlock()
+	return nil
+}
+
+// shellArgs returns the argv that runs command under the shell opts selects, after checking that
+// the shell is allowed and installed.
+func (s *State) shellArgs(opts bashOptions, command string) ([]string, error) {
+	shell := opts.Shell
+	if shell == "" {
+		shell = defaultShell
+	}
+	spec, ok := shellCommands[shell]
+	if !ok {
+		return nil, fmt.Errorf("Unknown shell: %s. Must be one of: %s", shell, strings.Join(supportedShells(), ", "))
+	}
+	s.Mu.RLock()
+	allowed, login, initScript := s.AllowedShells, s.LoginShell, s.InitScript
+	s.Mu.RUnlock()
+	if len(allowed) > 0 && !slices.Contains(allowed, shell) {
+		return nil, fmt.Errorf("Shell %s is not allowed on this server. Allowed shells: %s", shell, strings.Join(allowed, ", "))
+	}
+	if opts.Login != nil {
+		login = *opts.Login
+	}
+
+	args := spec.args
+	if login {
+		if spec.loginArgs == nil {
+			if opts.Login != nil {
+				return nil, fmt.Errorf("Shell %s has no login mode", shell)
+			}
+		} else {
+			args = spec.loginArgs
+		}
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return nil, fmt.Errorf("Shell %s is not installed: %s not found in PATH", shell, args[0])
+	}
+	if initScript != "" && spec.source != "" {
+		// A newline rather than && keeps the command running when the script's last statement
+		// fails, as it would in an interactive terminal.
+		command = spec.source + " " + singleQuote(initScript) + "\n" + command
+	}
+	return append(slices.Clone(args), command), nil
+}
+
+// singleQuote quotes s as a single word for POSIX shells and fish.
+func singleQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// shellStatus reports where a background shell is in its lifecycle: waiting for dependencies,
+// queued for a slot, running, completed, failed, or skipped because it never ran. Callers must
+// hold s.Mu.
+func shellStatus(shell *BackgroundShell) string {
+	select {
+	case <-shell.Done:
+		switch {
+		case shell.SkipReason != "":
+			return "skipped"
+		case shell.ExitCode != 0:
+			return "failed"
+		}
+		return "completed"
+	default:
+	}
+	if shell.queued {
+		return "queued"
+	}
+	if shell.abort != nil {
+		return "waiting"
+	}
+	return "running"
+}
+
+// resolveShellRef finds the shell a dependency refers to, by ID or else by label. A label names
+// the most recent shell created with it, so a pipeline can be started again under the same
+// labels. Callers must hold s.Mu.
+func (s *State) resolveShellRef(ref string) (*BackgroundShell, bool) {
+	if shell, ok := s.BackgroundShells[ref]; ok {
+		return shell, true
+	}
+	var found *BackgroundShell
+	for _, shell := range s.BackgroundShells {
+		if shell.Label == ref && (found == nil || shellNumber(shell.ID) > shellNumber(found.ID)) {
+			found = shell
+		}
+	}
+	return found, found != nil
+}
+
+func shellNumber(id string) int {
+	var n int
+	_, _ = fmt.Sscanf(id, "shell_%d", &n)
+	return n
+}
+
+// queueShell records a shell that starts once every shell in after has completed successfully and
+// a background shell slot is free. If any of them fails or is skipped, the shell is skipped too,
+// so a failure stops the rest of a pipeline.
+func (s *State) queueShell(shell *BackgroundShell, after []string, session *sdk.ServerSession) (string, error) {
+	s.Mu.Lock()
+	deps := make([]*BackgroundShell, 0, len(after))
+	for _, ref := range after {
+		dep, ok := s.resolveShellRef(ref)
+		if !ok {
+			s.Mu.Unlock()
+			return "", fmt.Errorf("Background shell with ID or label '%s' not found.", ref)
+		}
+		deps = append(deps, dep)
+		shell.After = append(shell.After, dep.ID)
+	}
+	abort := make(chan struct{})
+	shell.abort = abort
+	s.addShell(shell)
+	// A shell with nothing to wait for takes its place in the slot queue now, so queued commands
+	// start in the order they were made.
+	var slot <-chan struct{}
+	if len(deps) == 0 {
+		slot = s.requestShellSlot(shell)
+	}
+	s.Mu.Unlock()
+
+	go s.runAfterDependencies(shell, deps, slot, abort, session)
+
+	if len(deps) == 0 {
+		return fmt.Sprintf("Command queued to run in background with ID: %s when a background shell slot frees up", shell.ID), nil
+	}
+	return fmt.Sprintf("Command will run in background with ID: %s after %s complete successfully", shell.ID, strings.Join(shell.After, ", ")), nil
+}
+
+// runAfterDependencies starts a queued shell once its dependencies have succeeded and it holds a
+// slot. slot is the slot already requested for it, if any.
+func (s *State) runAfterDependencies(shell *BackgroundShell, deps []*BackgroundShell, slot <-chan struct{}, abort <-chan struct{}, session *sdk.ServerSession) {
+	for _, dep := range deps {
+		select {
+		case <-dep.Done:
+		case <-abort:
+		}
+	}
+
+	const killedQueued = "killed while queued for a background shell slot"
+	s.Mu.Lock()
+	reason := ""
+	if shell.abort == nil {
+		reason = "killed while waiting for its dependencies"
+	}
+	for _, dep := range deps {
+		if reason != "" {
+			break
+		}
+		switch shellStatus(dep) {
+		case "failed":
+			reason = fmt.Sprintf("dependency %s failed with exit code %d", dep.ID, dep.ExitCode)
+		case "skipped":
+			reason = fmt.Sprintf("dependency %s was skipped", dep.ID)
+		}
+	}
+	if reason == "" && slot == nil {
+		slot = s.requestShellSlot(shell)
+	}
+	s.Mu.Unlock()
+
+	switch {
+	case reason != "":
+		if slot != nil {
+			// Killed before the wait for the slot requested up front began.
+			reason = killedQueued
+			s.cancelShellSlot(shell, slot)
+		}
+	case !s.awaitShellSlot(shell, slot, abort):
+		reason = killedQueued
+	}
+	s.Mu.Lock()
+	if reason == "" && shell.abort == nil {
+		// Killed after the slot was granted but before the shell started.
+		reason = killedQueued
+		s.Mu.Unlock()
+		s.releaseShellSlot()
+		s.Mu.Lock()
+	}
+	shell.abort = nil
+	s.Mu.Unlock()
+
+	if reason == "" {
+		if err := s.startShell(shell); err != nil {
+			reason = err.Error()
+		}
+	}
+	if reason != "" {
+		s.Mu.Lock()
+		shell.SkipReason = reason
+		shell.EndTime = time.Now()
+		close(shell.Done)
+		s.Mu.Unlock()
+		_ = shell.Stdout.Close()
+		_ = shell.Stderr.Close()
+		notifyShellDone(session, shell, "skipped", 0)
+		return
+	}
+	s.monitorShell(shell, session)
+}
+
+// abortWaiting cancels a shell that is still waiting for its dependencies, reporting whether it
+// was waiting. Callers must hold s.Mu.
+func (shell *BackgroundShell) abortWaiting() bool {
+	if shell.abort == nil {
+		return false
+	}
+	close(shell.abort)
+	shell.abort = nil
+	return true
+}
@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startBackground(t *testing.T, state *State, command string, opts bashOptions) string {
+	t.Helper()
+	result, err := state.executeBashCommand(context.Background(), command, "", 0, true, opts)
+	require.NoError(t, err)
+	shellID := extractShellID(result.Result)
+	require.NotEmpty(t, shellID)
+	return shellID
+}
+
+func waitShell(t *testing.T, state *State, shellID string) *BackgroundShell {
+	t.Helper()
+	state.Mu.RLock()
+	shell := state.BackgroundShells[shellID]
+	state.Mu.RUnlock()
+	require.NotNil(t, shell)
+	select {
+	case <-shell.Done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("shell %s did not finish", shellID)
+	}
+	return shell
+}
+
+func statusOf(state *State, shell *BackgroundShell) string {
+	state.Mu.RLock()
+	defer state.Mu.RUnlock()
+	return shellStatus(shell)
+}
+
+func TestShellDependencies(t *testing.T) {
+	t.Run("runs after dependencies succeed", func(t *testing.T) {
+		state := NewState()
+		dir := t.TempDir()
+		build := startBackground(t, state, "sleep 0.2; echo built > "+dir+"/out", bashOptions{Label: "build"})
+		test := startBackground(t, state, "cat "+dir+"/out", bashOptions{Label: "test", After: []string{"build"}})
+
+		state.Mu.RLock()
+		assert.Equal(t, "waiting", shellStatus(state.BackgroundShells[test]))
+		assert.Equal(t, []string{build}, state.BackgroundShells[test].After)
+		state.Mu.RUnlock()
+
+		shell := waitShell(t, state, test)
+		assert.Equal(t, "completed", statusOf(state, shell))
+		assert.Equal(t, "built\n", shell.Stdout.String())
+	})
+
+	t.Run("skips when a dependency fails", func(t *testing.T) {
+		state := NewState()
+		build := startBackground(t, state, "exit 2", bashOptions{})
+		test := startBackground(t, state, "echo should not run", bashOptions{After: []string{build}})
+		deploy := startBackground(t, state, "echo should not run", bashOptions{After: []string{test}})
+
+		shell := waitShell(t, state, deploy)
+		assert.Equal(t, "skipped", statusOf(state, shell))
+		assert.Contains(t, shell.SkipReason, test+" was skipped")
+		assert.Contains(t, waitShell(t, state, test).SkipReason, build+" failed with exit code 2")
+		assert.Empty(t, shell.Stdout.String())
+
+		output, err := state.executeBashOutput(context.Background(), test, "", 0, "", bashOutputOptions{})
+		require.NoError(t, err)
+		var parsed bashOutputResult
+		require.NoError(t, json.Unmarshal([]byte(output), &parsed))
+		assert.Equal(t, "skipped", parsed.Status)
+		assert.Contains(t, parsed.Reason, "failed with exit code 2")
+	})
+
+	t.Run("waits for every dependency", func(t *testing.T) {
+		state := NewState()
+		fast := startBackground(t, state, "true", bashOptions{})
+		slow := startBackground(t, state, "sleep 0.3", bashOptions{})
+		last := startBackground(t, state, "echo done", bashOptions{After: []string{fast, slow}})
+
+		shell := waitShell(t, state, last)
+		slowShell := waitShell(t, state, slow)
+		assert.False(t, shell.StartTime.Before(slowShell.EndTime))
+	})
+
+	t.Run("labels resolve to the newest shell", func(t *testing.T) {
+		state := NewState()
+		first := startBackground(t, state, "exit 1", bashOptions{Label: "build"})
+		waitShell(t, state, first)
+		startBackground(t, state, "true", bashOptions{Label: "build"})
+		test := startBackground(t, state, "echo ok", bashOptions{After: []string{"build"}})
+		assert.Equal(t, "completed", statusOf(state, waitShell(t, state, test)))
+	})
+
+	t.Run("unknown dependency", func(t *testing.T) {
+		state := NewState()
+		_, err := state.executeBashCommand(context.Background(), "true", "", 0, true, bashOptions{After: []string{"nope"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "'nope' not found")
+		assert.Empty(t, state.BackgroundShells)
+	})
+
+	t.Run("requires run_in_background", func(t *testing.T) {
+		state := NewState()
+		_, err := state.executeBashCommand(context.Background(), "true", "", 0, false, bashOptions{Label: "x"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "run_in_background")
+	})
+
+	t.Run("killing a waiting shell skips its dependents", func(t *testing.T) {
+		state := NewState()
+		blocker := startBackground(t, state, "sleep 10", bashOptions{})
+		waiting := startBackground(t, state, "echo never", bashOptions{After: []string{blocker}})
+		dependent := startBackground(t, state, "echo never", bashOptions{After: []string{waiting}})
+
+		state.Mu.RLock()
+		waitingShell := state.BackgroundShells[waiting]
+		state.Mu.RUnlock()
+		message, err := state.executeKillShell(context.Background(), waiting)
+		require.NoError(t, err)
+		assert.Contains(t, message, "cancelled waiting shell")
+
+		<-waitingShell.Done
+		assert.Contains(t, waitingShell.SkipReason, "killed while waiting")
+		assert.Equal(t, "skipped", statusOf(state, waitShell(t, state, dependent)))
+		_, _ = state.executeKillShell(context.Background(), blocker)
+	})
+
+	t.Run("list_shells shows the graph", func(t *testing.T) {
+		state := NewState()
+		blocker := startBackground(t, state, "sleep 10", bashOptions{Label: "build"})
+		defer func() { _, _ = state.executeKillAllShells(context.Background(), "") }()
+		startBackground(t, state, "true", bashOptions{Label: "test", After: []string{"build"}})
+
+		result, err := state.executeListShells(context.Background(), "waiting", "")
+		require.NoError(t, err)
+		var parsed listShellsResult
+		require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+		require.Len(t, parsed.Shells, 1)
+		assert.Equal(t, "test", parsed.Shells[0].Label)
+		assert.Equal(t, []string{blocker}, parsed.Shells[0].After)
+		assert.Empty(t, parsed.Shells[0].StartedAt)
+
+		result, err = state.executeKillAllShells(context.Background(), "")
+		require.NoError(t, err)
+		assert.True(t, strings.Contains(result, `"cancelled"`))
+	})
+}
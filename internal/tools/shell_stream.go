@@ -0,0 +1,182 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// ShellStreamPath is the pattern ShellStreamHandler serves.
+const ShellStreamPath = "GET /shells/{id}/stream"
+
+const (
+	// maxStreamChunk bounds the output sent in one event, so that a burst of output is split
+	// across events rather than buffered into one.
+	maxStreamChunk = 64 * 1024
+	// streamKeepAlive is how often an idle stream sends a comment, so that proxies do not close it.
+	streamKeepAlive = 15 * time.Second
+)
+
+// streamChunk is the data of a stdout or stderr event: output and the byte offset it starts at.
+type streamChunk struct {
+	Data   string `json:"data"`
+	Offset int    `json:"offset"`
+}
+
+// streamExit is the data of the final exit event.
+type streamExit struct {
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ShellStreamHandler serves GET /shells/{id}/stream, which tails a background shell's output as
+// server-sent events. Each chunk of new output is a "stdout" or "stderr" event whose data is a JSON
+// object of the output and its byte offset in the stream, and an "exit" event with the shell's
+// status and exit code ends the stream. The stdout_from and stderr_from query parameters start the
+// stream at byte offsets instead of the beginning. Streaming leaves the read positions bash_output
+// uses untouched.
+func (s *State) ShellStreamHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		s.Mu.RLock()
+		shell, exists := s.BackgroundShells[id]
+		s.Mu.RUnlock()
+		if !exists {
+			http.Error(w, fmt.Sprintf("Background shell with ID '%s' not found.", id), http.StatusNotFound)
+			return
+		}
+		stdoutFrom, err := streamOffset(r, "stdout_from")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		stderrFrom, err := streamOffset(r, "stderr_from")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming is not supported by this connection.", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			// Subscribe before reading so that output written in between still wakes the loop.
+			stdoutChanged := shell.Stdout.Changed()
+			stderrChanged := shell.Stderr.Changed()
+			var done bool
+			select {
+			case <-shell.Done:
+				done = true
+			default:
+			}
+
+			// Once the shell is done its output is complete, so a trailing partial rune is sent as is.
+			if stdoutFrom, err = sendStreamOutput(w, "stdout", shell.Stdout, stdoutFrom, done); err != nil {
+				return
+			}
+			if stderrFrom, err = sendStreamOutput(w, "stderr", shell.Stderr, stderrFrom, done); err != nil {
+				return
+			}
+			if done {
+				s.Mu.RLock()
+				exit := streamExit{Status: shellStatus(shell), ExitCode: shell.ExitCode, Reason: shell.SkipReason}
+				s.Mu.RUnlock()
+				_ = writeEvent(w, "exit", exit)
+				flusher.Flush()
+				return
+			}
+			flusher.Flush()
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-shell.Done:
+			case <-stdoutChanged:
+			case <-stderrChanged:
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+// streamOffset parses the byte offset query parameter name, which defaults to 0.
+func streamOffset(r *http.Request, name string) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return offset, nil
+}
+
+// sendStreamOutput writes buf's output after offset as events named stream, and returns the offset
+// the next call should start at. Unless final is set, a partial UTF-8 sequence at the end is held
+// back until the rest of it is written.
+func sendStreamOutput(w http.ResponseWriter, stream string, buf *SyncBuffer, offset int, final bool) (int, error) {
+	output := buf.From(min(offset, buf.Len()))
+	if !final {
+		output = output[:len(output)-partialRuneLen(output)]
+	}
+	for output != "" {
+		chunk := output
+		if len(chunk) > maxStreamChunk {
+			chunk = chunk[:maxStreamChunk]
+			// Split on a rune boundary.
+			for end := maxStreamChunk; end > maxStreamChunk-utf8.UTFMax; end-- {
+				if utf8.RuneStart(output[end]) {
+					chunk = output[:end]
+					break
+				}
+			}
+		}
+		if err := writeEvent(w, stream, streamChunk{Data: chunk, Offset: offset}); err != nil {
+			return offset, err
+		}
+		offset += len(chunk)
+		output = output[len(chunk):]
+	}
+	return offset, nil
+}
+
+// partialRuneLen returns the length of the incomplete UTF-8 sequence that text ends with, if any.
+func partialRuneLen(text string) int {
+	for n := 1; n < utf8.UTFMax && n <= len(text); n++ {
+		if utf8.RuneStart(text[len(text)-n]) {
+			if !utf8.FullRuneInString(text[len(text)-n:]) {
+				return n
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// writeEvent writes a server-sent event named event with data encoded as JSON.
+func writeEvent(w http.ResponseWriter, event string, data any) error {
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, jsonBytes)
+	return err
+}
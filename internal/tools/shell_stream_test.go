@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readEvents reads server-sent events from body until it ends, returning each event's name and data.
+func readEvents(t *testing.T, body *bufio.Scanner) [][2]string {
+	var events [][2]string
+	var event string
+	for body.Scan() {
+		line := body.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			events = append(events, [2]string{event, strings.TrimPrefix(line, "data: ")})
+		}
+	}
+	require.NoError(t, body.Err())
+	return events
+}
+
+func TestShellStream(t *testing.T) {
+	state := NewState()
+	mux := http.NewServeMux()
+	mux.Handle(ShellStreamPath, state.ShellStreamHandler())
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	_, err := state.executeBashCommand(context.Background(), "echo one; sleep 0.2; echo two; echo oops >&2; exit 3", "", 0, true, bashOptions{})
+	require.NoError(t, err)
+
+	resp, err := http.Get(server.URL + "/shells/shell_1/stream")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	var stdout, stderr strings.Builder
+	events := readEvents(t, bufio.NewScanner(resp.Body))
+	require.NotEmpty(t, events)
+	for _, event := range events[:len(events)-1] {
+		var chunk streamChunk
+		require.NoError(t, json.Unmarshal([]byte(event[1]), &chunk))
+		switch event[0] {
+		case "stdout":
+			assert.Equal(t, stdout.Len(), chunk.Offset)
+			stdout.WriteString(chunk.Data)
+		case "stderr":
+			assert.Equal(t, stderr.Len(), chunk.Offset)
+			stderr.WriteString(chunk.Data)
+		default:
+			t.Fatalf("unexpected event %q", event[0])
+		}
+	}
+	assert.Equal(t, "one\ntwo\n", stdout.String())
+	assert.Equal(t, "oops\n", stderr.String())
+
+	last := events[len(events)-1]
+	assert.Equal(t, "exit", last[0])
+	var exit streamExit
+	require.NoError(t, json.Unmarshal([]byte(last[1]), &exit))
+	assert.Equal(t, "failed", exit.Status)
+	assert.Equal(t, 3, exit.ExitCode)
+
+	// Resuming from an offset skips the output already seen.
+	resp, err = http.Get(server.URL + "/shells/shell_1/stream?stdout_from=4&stderr_from=5")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	events = readEvents(t, bufio.NewScanner(resp.Body))
+	require.Len(t, events, 2)
+	assert.Equal(t, "stdout", events[0][0])
+	assert.JSONEq(t, `{"data": "two\n", "offset": 4}`, events[0][1])
+
+	resp, err = http.Get(server.URL + "/shells/shell_9/stream")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestPartialRuneLen(t *testing.T) {
+	assert.Equal(t, 0, partialRuneLen(""))
+	assert.Equal(t, 0, partialRuneLen("abc"))
+	assert.Equal(t, 0, partialRuneLen("a€"))
+	assert.Equal(t, 2, partialRuneLen("a€"[:3]))
+	assert.Equal(t, 1, partialRuneLen("a€"[:2]))
+}
@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetAllowedShells(t *testing.T) {
+	state := NewState()
+	require.NoError(t, state.SetAllowedShells([]string{"bash", "sh"}))
+	assert.Equal(t, []string{"bash", "sh"}, state.AllowedShells)
+
+	err := state.SetAllowedShells([]string{"bash", "csh"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Unknown shell: csh")
+}
+
+func TestShellArgs(t *testing.T) {
+	state := NewState()
+
+	args, err := state.shellArgs(bashOptions{}, "echo hi")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bash", "-c", "echo hi"}, args)
+
+	_, err = state.shellArgs(bashOptions{Shell: "tcsh"}, "echo hi")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Unknown shell")
+
+	require.NoError(t, state.SetAllowedShells([]string{"bash"}))
+	_, err = state.shellArgs(bashOptions{Shell: "sh"}, "echo hi")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not allowed")
+}
+
+func TestBash_Shell(t *testing.T) {
+	state := NewState()
+	ctx := context.Background()
+
+	t.Run("sh", func(t *testing.T) {
+		result, err := state.executeBashCommand(ctx, "echo $0", "", 0, false, bashOptions{Shell: "sh"})
+		require.NoError(t, err)
+		assert.Contains(t, result.Stdout, "sh")
+	})
+
+	t.Run("python", func(t *testing.T) {
+		if _, err := exec.LookPath("python3"); err != nil {
+			t.Skip("python3 not installed")
+		}
+		result, err := state.executeBashCommand(ctx, "print(6 * 7)", "", 0, false, bashOptions{Shell: "python"})
+		require.NoError(t, err)
+		assert.Equal(t, "42\n", result.Stdout)
+	})
+
+	t.Run("disallowed shells never run", func(t *testing.T) {
+		require.NoError(t, state.SetAllowedShells([]string{"bash"}))
+		_, err := state.executeBashCommand(ctx, "echo hi", "", 0, true, bashOptions{Shell: "sh"})
+		require.Error(t, err)
+		assert.Empty(t, state.BackgroundShells)
+	})
+}
+
+func TestShellEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "it's init.sh")
+	require.NoError(t, os.WriteFile(script, []byte("export FROM_INIT=loaded\n"), 0o644))
+
+	state := NewState()
+	require.Error(t, state.SetShellEnvironment(false, "relative/init.sh"))
+	require.Error(t, state.SetShellEnvironment(false, filepath.Join(dir, "missing.sh")))
+	require.NoError(t, state.SetShellEnvironment(true, script))
+
+	args, err := state.shellArgs(bashOptions{}, "echo hi")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bash", "-l", "-c"}, args[:3])
+
+	notLogin := false
+	args, err = state.shellArgs(bashOptions{Login: &notLogin}, "echo hi")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bash", "-c"}, args[:2])
+
+	t.Run("init script is sourced first", func(t *testing.T) {
+		result, err := state.executeBashCommand(context.Background(), "echo $FROM_INIT", "", 0, false, bashOptions{Login: &notLogin})
+		require.NoError(t, err)
+		assert.Equal(t, "loaded\n", result.Stdout)
+	})
+
+	t.Run("shells without a login mode", func(t *testing.T) {
+		if _, err := exec.LookPath("python3"); err != nil {
+			t.Skip("python3 not installed")
+		}
+		// The server default quietly falls back, but an explicit request is refused
+		_, err := state.shellArgs(bashOptions{Shell: "python"}, "print(1)")
+		require.NoError(t, err)
+		login := true
+		_, err = state.shellArgs(bashOptions{Shell: "python", Login: &login}, "print(1)")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// maxSummaryString bounds each string in a slow call's argument summary.
+	maxSummaryString = 200
+	// maxSummaryItems bounds the items of each list in a slow call's argument summary.
+	maxSummaryItems = 20
+)
+
+// callTiming accumulates where a tool call's time went, in nanoseconds: waiting for approval,
+// confirmation, or file locks, and running the tool.
+type callTiming struct {
+	queue atomic.Int64
+	exec  atomic.Int64
+}
+
+type callTimingKey struct{}
+
+// addQueueTime counts d, spent waiting rather than working, against the tool call running under
+// ctx.
+func addQueueTime(ctx context.Context, d time.Duration) {
+	if timing, ok := ctx.Value(callTimingKey{}).(*callTiming); ok {
+		timing.queue.Add(int64(d))
+	}
+}
+
+// TimeExecution wraps a tool handler to time its execution for CallLogMiddleware. Time the handler
+// spends queued, such as waiting for a file lock, is not counted as execution.
+func TimeExecution[In, Out any](handler sdk.ToolHandlerFor[In, Out]) sdk.ToolHandlerFor[In, Out] {
+	return func(ctx context.Context, req *sdk.CallToolRequest, input In) (*sdk.CallToolResult, Out, error) {
+		timing, ok := ctx.Value(callTimingKey{}).(*callTiming)
+		if !ok {
+			return handler(ctx, req, input)
+		}
+		queued := timing.queue.Load()
+		start := time.Now()
+		result, output, err := handler(ctx, req, input)
+		timing.exec.Add(int64(time.Since(start)) - (timing.queue.Load() - queued))
+		return result, output, err
+	}
+}
+
+// SetSlowCallLog makes CallLogMiddleware write each tool call taking threshold or longer to out,
+// as a JSON line with a summary of its arguments and the breakdown of its duration. A zero
+// threshold turns the log off.
+func (s *State) SetSlowCallLog(threshold time.Duration, out io.Writer) error {
+	if threshold < 0 {
+		return fmt.Errorf("slow call threshold cannot be negative")
+	}
+	s.Mu.Lock()
+	s.SlowCallThreshold = threshold
+	s.SlowCallLog = out
+	s.Mu.Unlock()
+	return nil
+}
+
+// slowCallEntry is one line of the slow-call log.
+type slowCallEntry struct {
+	Time time.Time `json:"time"`
+	CallRecord
+	Arguments any `json:"arguments,omitempty"`
+}
+
+// logSlowCall writes record to the slow-call log if the call took at least the threshold.
+func (s *State) logSlowCall(record CallRecord, duration time.Duration, arguments json.RawMessage) {
+	s.Mu.RLock()
+	threshold, out := s.SlowCallThreshold, s.SlowCallLog
+	s.Mu.RUnlock()
+	if threshold == 0 || out == nil || duration < threshold {
+		return
+	}
+	entry := slowCallEntry{Time: time.Now(), CallRecord: record}
+	var decoded any
+	if json.Unmarshal(arguments, &decoded) == nil {
+		entry.Arguments = summarizeArgument(decoded)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	s.slowCallMu.Lock()
+	defer s.slowCallMu.Unlock()
+	_, _ = out.Write(append(line, '\n'))
+}
+
+// summarizeArgument shortens long strings and lists within a decoded argument value, so that a
+// slow write logs the start of its content rather than the whole file.
+func summarizeArgument(value any) any {
+	switch v := value.(type) {
+	case string:
+		if len(v) > maxSummaryString {
+			return fmt.Sprintf("%s... (%d bytes)", strings.ToValidUTF8(v[:maxSummaryString], ""), len(v))
+		}
+	case []any:
+		items := make([]any, 0, min(len(v), maxSummaryItems+1))
+		for _, item := range v[:min(len(v), maxSummaryItems)] {
+			items = append(items, summarizeArgument(item))
+		}
+		if len(v) > maxSummaryItems {
+			items = append(items, fmt.Sprintf("... (%d items)", len(v)))
+		}
+		return items
+	case map[string]any:
+		for key, item := range v {
+			v[key] = summarizeArgument(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowCallLog(t *testing.T) {
+	state := NewState()
+	var out bytes.Buffer
+	require.NoError(t, state.SetSlowCallLog(50*time.Millisecond, &out))
+	path := filepath.Join(t.TempDir(), "file.txt")
+
+	// The tool waits for the path's lock, then works for 30ms.
+	tool := TimeExecution(func(ctx context.Context, req *sdk.CallToolRequest, input map[string]any) (*sdk.CallToolResult, any, error) {
+		defer state.lockPaths(ctx, path)()
+		time.Sleep(30 * time.Millisecond)
+		return &sdk.CallToolResult{}, nil, nil
+	})
+	handler := state.CallLogMiddleware(func(ctx context.Context, method string, req sdk.Request) (sdk.Result, error) {
+		result, _, err := tool(ctx, req.(*sdk.CallToolRequest), nil)
+		return result, err
+	})
+	call := func(arguments string) {
+		_, err := handler(context.Background(), "tools/call", &sdk.CallToolRequest{Params: &sdk.CallToolParamsRaw{Name: "write", Arguments: json.RawMessage(arguments)}})
+		require.NoError(t, err)
+	}
+
+	call(`{}`)
+	assert.Empty(t, out.String(), "calls under the threshold are not logged")
+
+	unlock := state.lockPaths(context.Background(), path)
+	time.AfterFunc(60*time.Millisecond, unlock)
+	call(`{"file_path":"/tmp/file.txt","content":"` + strings.Repeat("a", 500) + `"}`)
+
+	var entry slowCallEntry
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "write", entry.Tool)
+	assert.GreaterOrEqual(t, entry.QueueMs, int64(50))
+	assert.GreaterOrEqual(t, entry.ExecMs, int64(25))
+	assert.Less(t, entry.ExecMs, entry.QueueMs+30)
+	assert.GreaterOrEqual(t, entry.DurationMs, entry.QueueMs+entry.ExecMs)
+	arguments := entry.Arguments.(map[string]any)
+	assert.Equal(t, "/tmp/file.txt", arguments["file_path"])
+	assert.Equal(t, strings.Repeat("a", maxSummaryString)+"... (500 bytes)", arguments["content"])
+
+	// The breakdown is kept for the dashboard too.
+	require.Len(t, state.RecentCalls, 2)
+	assert.Equal(t, entry.QueueMs, state.RecentCalls[1].QueueMs)
+
+	require.Error(t, state.SetSlowCallLog(-time.Second, &out))
+}
+
+func TestSummarizeArgument(t *testing.T) {
+	var files []any
+	for range maxSummaryItems + 5 {
+		files = append(files, map[string]any{"path": "a.go", "content": strings.Repeat("x", maxSummaryString+1)})
+	}
+	summary := summarizeArgument(map[string]any{"files": files, "limit": 10.0}).(map[string]any)
+	assert.Equal(t, 10.0, summary["limit"])
+	items := summary["files"].([]any)
+	require.Len(t, items, maxSummaryItems+1)
+	assert.Equal(t, strings.Repeat("x", maxSummaryString)+"... (201 bytes)", items[0].(map[string]any)["content"])
+	assert.Equal(t, "... (25 items)", items[maxSummaryItems])
+}
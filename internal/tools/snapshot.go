@@ -0,0 +1,365 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// WorkspaceSnapshot is a saved copy of the files under Root matching Pattern, taken by the snapshot
+// tool, which restore_snapshot puts back.
+type WorkspaceSnapshot struct {
+	ID             string
+	Root           string
+	Pattern        string
+	IncludeSkipped bool
+	Method         string
+	Note           string
+	Created        time.Time
+
+	// Dir holds the saved files at their paths relative to Root, and Files lists those paths,
+	// slash-separated and sorted.
+	Dir   string
+	Files []string
+	Bytes int64
+}
+
+// SetSnapshotDir sets where the snapshot tool saves files. An empty dir uses a directory under the
+// system temp directory.
+func (s *State) SetSnapshotDir(dir string) error {
+	if dir != "" {
+		resolved, err := resolvePath(dir)
+		if err != nil {
+			return fmt.Errorf("snapshot directory must be absolute, not relative")
+		}
+		dir = resolved
+	}
+	s.Mu.Lock()
+	s.SnapshotDir = dir
+	s.Mu.Unlock()
+	return nil
+}
+
+// snapshotFiles lists the files under root matching pattern, with the same skipped directories and
+// denylist as glob.
+func (s *State) snapshotFiles(ctx context.Context, root, pattern string, includeSkipped bool) ([]string, error) {
+	walker := newGlobWalker(vfs.OS{}, root, pattern, maxGlobMatches, includeSkipped)
+	s.Mu.RLock()
+	walker.denied = s.DeniedPaths
+	s.Mu.RUnlock()
+	matches, truncated := walker.run(ctx)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if truncated {
+		return nil, fmt.Errorf("more than %d files match %s under %s; narrow the pattern", maxGlobMatches, pattern, root)
+	}
+	files := make([]string, len(matches))
+	for i, m := range matches {
+		files[i] = m.path
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (s *State) executeSnapshot(ctx context.Context, path, pattern, method, note string, includeSkipped bool) (string, error) {
+	if pattern == "" {
+		pattern = "**"
+	}
+	if strings.Contains(pattern, "\x00") || !doublestar.ValidatePattern(pattern) {
+		return "", fmt.Errorf("Invalid glob pattern.")
+	}
+	if method == "" {
+		method = "copy"
+	}
+	if method != "copy" && method != "hardlink" {
+		return "", fmt.Errorf("Invalid method: %s. Must be one of: copy, hardlink.", method)
+	}
+	root, err := s.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(root); err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("path is not a directory: %s", root)
+	}
+	files, err := s.snapshotFiles(ctx, root, pattern, includeSkipped)
+	if err != nil {
+		return "", err
+	}
+
+	s.Mu.Lock()
+	id := fmt.Sprintf("snap_%d", s.NextSnapshotID)
+	s.NextSnapshotID++
+	base := s.SnapshotDir
+	s.Mu.Unlock()
+	if base == "" {
+		base = filepath.Join(os.TempDir(), "claude-tools-snapshots")
+	}
+	dir := filepath.Join(base, spoolRunID+"-"+id)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("Cannot create snapshot directory: %s", err)
+	}
+
+	snapshot := &WorkspaceSnapshot{ID: id, Root: root, Pattern: pattern, IncludeSkipped: includeSkipped, Method: method, Note: note, Created: time.Now(), Dir: dir}
+	for _, rel := range files {
+		src := filepath.Join(root, filepath.FromSlash(rel))
+		dst := filepath.Join(dir, filepath.FromSlash(rel))
+		info, err := os.Lstat(src)
+		if err != nil {
+			// Deleted since the walk found it; it is simply not part of the snapshot.
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+			_ = os.RemoveAll(dir)
+			return "", fmt.Errorf("Cannot create snapshot directory: %s", err)
+		}
+		// Hardlinks fall back to copies where the filesystem cannot link, e.g. across devices.
+		if method != "hardlink" || !info.Mode().IsRegular() || os.Link(src, dst) != nil {
+			if err := copyEntry(src, dst, info); err != nil {
+				_ = os.RemoveAll(dir)
+				return "", err
+			}
+		}
+		snapshot.Files = append(snapshot.Files, rel)
+		snapshot.Bytes += info.Size()
+	}
+
+	s.Mu.Lock()
+	s.Snapshots[id] = snapshot
+	s.Mu.Unlock()
+	return fmt.Sprintf("Created snapshot %s of %d files (%d bytes) matching %s under %s", id, len(snapshot.Files), snapshot.Bytes, pattern, root), nil
+}
+
+// listSnapshots describes the snapshots taken so far, oldest first.
+func (s *State) listSnapshots() string {
+	s.Mu.RLock()
+	snapshots := make([]*WorkspaceSnapshot, 0, len(s.Snapshots))
+	for _, snap := range s.Snapshots {
+		snapshots = append(snapshots, snap)
+	}
+	s.Mu.RUnlock()
+	if len(snapshots) == 0 {
+		return "No snapshots."
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Created.Before(snapshots[j].Created) })
+	var b strings.Builder
+	b.WriteString("Snapshots:")
+	for _, snap := range snapshots {
+		fmt.Fprintf(&b, "\n- %s: %d files matching %s under %s, taken %s", snap.ID, len(snap.Files), snap.Pattern, snap.Root, snap.Created.Format(time.RFC3339))
+		if snap.Note != "" {
+			b.WriteString(" (" + snap.Note + ")")
+		}
+	}
+	return b.String()
+}
+
+// snapshotRestore is a change restore_snapshot makes to one file.
+type snapshotRestore struct {
+	rel    string
+	delete bool
+}
+
+func (s *State) executeRestoreSnapshot(ctx context.Context, id string, dryRun, deleteNew bool) (string, error) {
+	if id == "" {
+		return s.listSnapshots(), nil
+	}
+	if !dryRun {
+		if err := s.checkOverlay("restore_snapshot"); err != nil {
+			return "", err
+		}
+	}
+	s.Mu.RLock()
+	snapshot := s.Snapshots[id]
+	s.Mu.RUnlock()
+	if snapshot == nil {
+		return "", fmt.Errorf("no snapshot with id %s. %s", id, s.listSnapshots())
+	}
+
+	var changes []snapshotRestore
+	for _, rel := range snapshot.Files {
+		if !sameEntry(filepath.Join(snapshot.Dir, filepath.FromSlash(rel)), filepath.Join(snapshot.Root, filepath.FromSlash(rel))) {
+			changes = append(changes, snapshotRestore{rel: rel})
+		}
+	}
+	if deleteNew {
+		current, err := s.snapshotFiles(ctx, snapshot.Root, snapshot.Pattern, snapshot.IncludeSkipped)
+		if err != nil {
+			return "", err
+		}
+		saved := make(map[string]bool, len(snapshot.Files))
+		for _, rel := range snapshot.Files {
+			saved[rel] = true
+		}
+		for _, rel := range current {
+			if !saved[rel] {
+				changes = append(changes, snapshotRestore{rel: rel, delete: true})
+			}
+		}
+	}
+	if len(changes) == 0 {
+		return fmt.Sprintf("Nothing to restore: the files under %s match snapshot %s.", snapshot.Root, id), nil
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].rel < changes[j].rel })
+
+	var summary strings.Builder
+	for _, c := range changes {
+		action := "restore"
+		if c.delete {
+			action = "delete"
+		}
+		fmt.Fprintf(&summary, "\n- %s %s", action, filepath.Join(snapshot.Root, filepath.FromSlash(c.rel)))
+	}
+	if dryRun {
+		return fmt.Sprintf("Restoring snapshot %s would make %d changes:%s", id, len(changes), summary.String()), nil
+	}
+
+	paths := make([]string, len(changes))
+	for i, c := range changes {
+		paths[i] = filepath.Join(snapshot.Root, filepath.FromSlash(c.rel))
+	}
+	defer s.lockPaths(ctx, paths...)()
+	if err := s.confirmWrite(ctx, "restore_snapshot", paths...); err != nil {
+		return "", err
+	}
+	for i, c := range changes {
+		path := paths[i]
+		s.noteChange(ctx, "restore_snapshot", path)
+		var err error
+		if c.delete {
+			if err = os.Remove(path); os.IsNotExist(err) {
+				err = nil
+			}
+		} else {
+			err = restoreEntry(filepath.Join(snapshot.Dir, filepath.FromSlash(c.rel)), path)
+		}
+		if err != nil {
+			return "", fmt.Errorf("Restored %d of %d changes; cannot restore %s: %s", i, len(changes), path, err)
+		}
+		// The client's view of the file predates the restore; require a fresh read before edits.
+		s.forgetPath(path)
+	}
+	return fmt.Sprintf("Restored snapshot %s with %d changes:%s", id, len(changes), summary.String()), nil
+}
+
+// sameEntry reports whether the file or symlink at path matches the saved one.
+func sameEntry(saved, path string) bool {
+	savedInfo, err := os.Lstat(saved)
+	if err != nil {
+		return false
+	}
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode().Type() != savedInfo.Mode().Type() {
+		return false
+	}
+	if savedInfo.Mode()&fs.ModeSymlink != 0 {
+		savedTarget, _ := os.Readlink(saved)
+		target, err := os.Readlink(path)
+		return err == nil && target == savedTarget
+	}
+	if info.Mode().Perm() != savedInfo.Mode().Perm() || info.Size() != savedInfo.Size() {
+		return false
+	}
+	if os.SameFile(info, savedInfo) {
+		return true
+	}
+	savedData, err := os.ReadFile(saved)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	return err == nil && bytes.Equal(data, savedData)
+}
+
+// restoreEntry puts the saved file or symlink back at path, replacing whatever file is there.
+func restoreEntry(saved, path string) error {
+	info, err := os.Lstat(saved)
+	if err != nil {
+		return fmt.Errorf("Cannot read snapshot: %s", err)
+	}
+	if current, err := os.Lstat(path); err == nil && current.IsDir() {
+		return fmt.Errorf("a directory now exists at the path")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("Cannot create parent directory: %s", err)
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return copyEntry(saved, path, info)
+	}
+	data, err := os.ReadFile(saved)
+	if err != nil {
+		return fmt.Errorf("Cannot read snapshot: %s", err)
+	}
+	// Replacing the file rather than writing into it keeps hardlinked snapshots intact.
+	_ = os.Remove(path)
+	return writeFileAtomic(vfs.OS{}, path, data, info.Mode().Perm(), true)
+}
+
+var SnapshotTool = sdk.Tool{
+	Name:        "snapshot",
+	Description: "Saves the current content of the files under a directory, so that restore_snapshot can roll them back later.\n\nUsage:\n- Take a snapshot before a long or risky series of changes, as a coarse-grained undo for everything at once, including changes made by bash commands.\n- path (absolute) is the directory to snapshot. Pass pattern (e.g. \"src/**/*.go\") to save only matching files; by default all files are saved.\n- Like glob, dependency, build, and VCS directories (node_modules, target, .git, and similar) are left out unless the pattern names them or include_skipped_dirs is set.\n- method \"copy\" (default) copies each file. \"hardlink\" links them instead, which is fast and takes no space, but a file changed in place (rather than replaced, as write and edit do) changes its snapshot too.\n- Returns the snapshot's id for restore_snapshot.",
+}
+
+type SnapshotInput struct {
+	Path               string `json:"path" jsonschema:"The absolute path of the directory to snapshot"`
+	Pattern            string `json:"pattern,omitempty" jsonschema:"Glob of the files to save, relative to path. Defaults to all files"`
+	Method             string `json:"method,omitempty" jsonschema:"How files are saved: 'copy' (default) or 'hardlink'"`
+	Note               string `json:"note,omitempty" jsonschema:"A description shown when listing snapshots"`
+	IncludeSkippedDirs bool   `json:"include_skipped_dirs,omitempty" jsonschema:"Also save node_modules, target, .git, and other directories skipped by default"`
+}
+type SnapshotOutput struct {
+	Result string `json:"result"`
+}
+
+func Snapshot(ctx context.Context, req *sdk.CallToolRequest, args SnapshotInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeSnapshot(ctx, args.Path, args.Pattern, args.Method, args.Note, args.IncludeSkippedDirs)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &SnapshotOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
+
+var RestoreSnapshotTool = sdk.Tool{
+	Name:        "restore_snapshot",
+	Description: "Rolls the files saved by the snapshot tool back to their state when the snapshot was taken.\n\nUsage:\n- Without snapshot_id, lists the snapshots taken so far.\n- Files that changed or were deleted since the snapshot are restored. Files matching the snapshot's pattern that were created since are deleted, unless delete_new is false.\n- Set dry_run to true to list the changes without making them.\n- The snapshot is kept, so it can be restored again.\n- Read restored files again before editing them.",
+}
+
+type RestoreSnapshotInput struct {
+	SnapshotID string `json:"snapshot_id,omitempty" jsonschema:"The id returned by snapshot. Omit to list snapshots"`
+	DryRun     bool   `json:"dry_run,omitempty" jsonschema:"List the files that would be restored or deleted without changing them"`
+	DeleteNew  *bool  `json:"delete_new,omitempty" jsonschema:"Delete files matching the snapshot's pattern that were created after it. Defaults to true"`
+}
+type RestoreSnapshotOutput struct {
+	Result string `json:"result"`
+}
+
+func RestoreSnapshot(ctx context.Context, req *sdk.CallToolRequest, args RestoreSnapshotInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	deleteNew := args.DeleteNew == nil || *args.DeleteNew
+	result, err := server.executeRestoreSnapshot(withSession(ctx, req), args.SnapshotID, args.DryRun, deleteNew)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &RestoreSnapshotOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
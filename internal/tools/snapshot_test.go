@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	for _, method := range []string{"copy", "hardlink"} {
+		t.Run(method, func(t *testing.T) {
+			state := NewState()
+			require.NoError(t, state.SetSnapshotDir(t.TempDir()))
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			changed := filepath.Join(dir, "changed.txt")
+			removed := filepath.Join(dir, "sub", "removed.txt")
+			unmatched := filepath.Join(dir, "notes.md")
+			require.NoError(t, os.WriteFile(changed, []byte("before\n"), 0o644))
+			require.NoError(t, os.MkdirAll(filepath.Dir(removed), 0o755))
+			require.NoError(t, os.WriteFile(removed, []byte("keep me\n"), 0o600))
+			require.NoError(t, os.WriteFile(unmatched, []byte("notes\n"), 0o644))
+
+			result, err := state.executeSnapshot(ctx, dir, "**/*.txt", method, "", false)
+			require.NoError(t, err)
+			assert.Contains(t, result, "snap_1 of 2 files")
+
+			_, err = state.executeRead(ctx, changed, 0, 0)
+			require.NoError(t, err)
+			_, err = callWrite(t, state, WriteInput{FilePath: changed, Content: "after\n"})
+			require.NoError(t, err)
+			require.NoError(t, os.Remove(removed))
+			created := filepath.Join(dir, "created.txt")
+			require.NoError(t, os.WriteFile(created, []byte("new\n"), 0o644))
+			require.NoError(t, os.WriteFile(unmatched, []byte("edited notes\n"), 0o644))
+
+			result, err = state.executeRestoreSnapshot(ctx, "snap_1", true, true)
+			require.NoError(t, err)
+			assert.Contains(t, result, "would make 3 changes")
+			assert.FileExists(t, created)
+
+			result, err = state.executeRestoreSnapshot(ctx, "snap_1", false, true)
+			require.NoError(t, err)
+			assert.Contains(t, result, "with 3 changes")
+			content, _ := os.ReadFile(changed)
+			assert.Equal(t, "before\n", string(content))
+			content, _ = os.ReadFile(removed)
+			assert.Equal(t, "keep me\n", string(content))
+			info, err := os.Stat(removed)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+			assert.NoFileExists(t, created)
+			// Files outside the snapshot's pattern are left alone.
+			content, _ = os.ReadFile(unmatched)
+			assert.Equal(t, "edited notes\n", string(content))
+
+			result, err = state.executeRestoreSnapshot(ctx, "snap_1", false, true)
+			require.NoError(t, err)
+			assert.Contains(t, result, "Nothing to restore")
+		})
+	}
+}
+
+func TestRestoreSnapshotList(t *testing.T) {
+	state := NewState()
+	require.NoError(t, state.SetSnapshotDir(t.TempDir()))
+	ctx := context.Background()
+
+	result, err := state.executeRestoreSnapshot(ctx, "", false, true)
+	require.NoError(t, err)
+	assert.Equal(t, "No snapshots.", result)
+
+	_, err = state.executeSnapshot(ctx, t.TempDir(), "", "", "before refactor", false)
+	require.NoError(t, err)
+	result, err = state.executeRestoreSnapshot(ctx, "", false, true)
+	require.NoError(t, err)
+	assert.Contains(t, result, "snap_1: 0 files")
+	assert.Contains(t, result, "(before refactor)")
+
+	_, err = state.executeRestoreSnapshot(ctx, "snap_9", false, true)
+	assert.ErrorContains(t, err, "no snapshot with id snap_9")
+	_, err = state.executeSnapshot(ctx, t.TempDir(), "", "rsync", "", false)
+	assert.ErrorContains(t, err, "Invalid method")
+}
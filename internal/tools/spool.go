@@ -0,0 +1,141 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// spoolRunID prefixes spool file names so that shells of different server runs, which reuse
+// shell IDs, never overwrite each other's output.
+var spoolRunID = fmt.Sprintf("%s-%d", time.Now().Format("20060102T150405"), os.Getpid())
+
+// ConfigureSpool sets whether background shells write their output to files under dir instead of
+// keeping it in memory, unless a call chooses otherwise. An empty dir uses a directory under the
+// system temp directory.
+func (s *State) ConfigureSpool(enabled bool, dir string) error {
+	if dir != "" {
+		resolved, err := resolvePath(dir)
+		if err != nil {
+			return fmt.Errorf("spool directory must be absolute, not relative")
+		}
+		dir = resolved
+	}
+	s.Mu.Lock()
+	s.SpoolByDefault = enabled
+	s.SpoolDir = dir
+	s.Mu.Unlock()
+	return nil
+}
+
+// useSpool resolves a per-call spool option against the server-wide default.
+func (s *State) useSpool(requested *bool) bool {
+	if requested != nil {
+		return *requested
+	}
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.SpoolByDefault
+}
+
+// spoolShell moves a shell's output to files in the spool directory. Output already captured is
+// copied over, so it may be called after the process has started.
+func (s *State) spoolShell(shell *BackgroundShell) error {
+	s.Mu.RLock()
+	dir := s.SpoolDir
+	s.Mu.RUnlock()
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "claude-tools-spool")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("Failed to create spool directory: %s", err)
+	}
+
+	base := filepath.Join(dir, spoolRunID+"-"+shell.ID)
+	if err := shell.Stdout.SpoolTo(base + ".stdout"); err != nil {
+		return fmt.Errorf("Failed to spool output: %s", err)
+	}
+	if err := shell.Stderr.SpoolTo(base + ".stderr"); err != nil {
+		return fmt.Errorf("Failed to spool output: %s", err)
+	}
+	s.Mu.Lock()
+	shell.StdoutFile = base + ".stdout"
+	shell.StderrFile = base + ".stderr"
+	s.Mu.Unlock()
+	return nil
+}
+
+// SpoolTo moves the buffer's contents to a new file at path, where later writes are appended.
+func (sb *SyncBuffer) SpoolTo(path string) error {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if sb.spool != "" {
+		return fmt.Errorf("output is already spooled to %s", sb.spool)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	n, err := f.Write(sb.buf.Bytes())
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	sb.spool, sb.spoolFile, sb.spoolSize = path, f, n
+	sb.buf = bytes.Buffer{}
+	if sb.closed {
+		// The process finished before spooling began, so nothing more will be written.
+		sb.spoolFile = nil
+		return f.Close()
+	}
+	return nil
+}
+
+// Close stops a spooled buffer from accepting writes, releasing its file. The output can still be
+// read. An in-memory buffer keeps accepting writes, but if it is spooled afterwards the file is
+// closed as soon as the output has been copied to it.
+func (sb *SyncBuffer) Close() error {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.closed = true
+	if sb.spoolFile == nil {
+		return nil
+	}
+	err := sb.spoolFile.Close()
+	sb.spoolFile = nil
+	return err
+}
+
+// removeSpool deletes the spool files of a shell whose record has been dropped, since nothing can
+// read them through the tools anymore. Shells that are not spooled are left alone.
+func (s *State) removeSpool(shell *BackgroundShell) {
+	s.Mu.RLock()
+	stdoutFile, stderrFile := shell.StdoutFile, shell.StderrFile
+	s.Mu.RUnlock()
+	if stdoutFile == "" {
+		return
+	}
+	_ = shell.Stdout.Close()
+	_ = shell.Stderr.Close()
+	_ = os.Remove(stdoutFile)
+	_ = os.Remove(stderrFile)
+}
+
+// readSpool reads the bytes of a spool file from offset up to end, which must not pass the length
+// written so far. Spool files are only appended to, so the range can be read while writes go on.
+func readSpool(path string, offset, end int) string {
+	if offset >= end {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	data := make([]byte, end-offset)
+	n, _ := f.ReadAt(data, int64(offset))
+	return string(data[:n])
+}
@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncBuffer_Spool(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+	sb := &SyncBuffer{}
+	_, _ = sb.Write([]byte("before "))
+	require.NoError(t, sb.SpoolTo(path))
+	_, _ = sb.Write([]byte("after"))
+
+	assert.Equal(t, "before after", sb.String())
+	assert.Equal(t, "after", sb.From(7))
+	assert.Equal(t, "", sb.From(100))
+	assert.Equal(t, "bef", sb.Range(0, 3))
+	assert.Equal(t, "after", sb.Range(7, 100))
+	assert.Equal(t, "", sb.Range(12, 5))
+	assert.Equal(t, 12, sb.Len())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "before after", string(data))
+
+	require.Error(t, sb.SpoolTo(path))
+	require.NoError(t, sb.Close())
+	_, err = sb.Write([]byte("late"))
+	require.Error(t, err)
+	assert.Equal(t, "before after", sb.String(), "output stays readable after Close")
+}
+
+func TestSyncBuffer_SpoolAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+	sb := &SyncBuffer{}
+	_, _ = sb.Write([]byte("done"))
+	require.NoError(t, sb.Close())
+	require.NoError(t, sb.SpoolTo(path))
+
+	assert.Nil(t, sb.spoolFile, "the file is closed at once when the buffer was already closed")
+	assert.Equal(t, "done", sb.String())
+	_, err := sb.Write([]byte("late"))
+	require.Error(t, err)
+}
+
+func TestBash_SpoolFastExit(t *testing.T) {
+	state := NewState()
+	require.NoError(t, state.ConfigureSpool(false, t.TempDir()))
+	spool := true
+
+	// The shell may finish, and close its buffers, before spooling starts.
+	result, err := state.executeBashCommand(context.Background(), "echo hi", "", 0, true, bashOptions{Spool: &spool})
+	require.NoError(t, err)
+	shell := waitShell(t, state, extractShellID(result.Result))
+	require.NotEmpty(t, shell.StdoutFile)
+	data, err := os.ReadFile(shell.StdoutFile)
+	require.NoError(t, err)
+	assert.Equal(t, "hi\n", string(data))
+
+	for _, buf := range []*SyncBuffer{shell.Stdout, shell.Stderr} {
+		buf.mu.Lock()
+		assert.Nil(t, buf.spoolFile, "no spool file is left open once the shell has exited")
+		buf.mu.Unlock()
+	}
+}
+
+func TestBash_SpoolOutput(t *testing.T) {
+	state := NewState()
+	dir := t.TempDir()
+	require.NoError(t, state.ConfigureSpool(false, dir))
+	ctx := context.Background()
+	spool := true
+
+	result, err := state.executeBashCommand(ctx, "seq 1 5000; echo done >&2", "", 0, true, bashOptions{Spool: &spool})
+	require.NoError(t, err)
+	assert.Contains(t, result.Result, "Output is spooled to "+dir)
+	shellID := extractShellID(result.Result)
+	assert.Equal(t, "shell_1", shellID)
+
+	shell := waitShell(t, state, shellID)
+	require.NotEmpty(t, shell.StdoutFile)
+	data, err := os.ReadFile(shell.StdoutFile)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(data), "4999\n5000\n"))
+	assert.Equal(t, len(data), shell.Stdout.Len())
+
+	output, err := state.executeBashOutput(ctx, shellID, "", 0, "", bashOutputOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, output, "5000")
+	assert.Contains(t, output, "done")
+
+	t.Run("capped read", func(t *testing.T) {
+		zero := 0
+		output, err := state.executeBashOutput(ctx, shellID, "", 0, "", bashOutputOptions{StdoutFrom: &zero, MaxBytes: 10})
+		require.NoError(t, err)
+		var result bashOutputResult
+		require.NoError(t, json.Unmarshal([]byte(output), &result))
+		assert.Equal(t, "1\n2\n3\n4\n5\n", result.Stdout)
+		assert.Equal(t, 10, result.StdoutNextByte)
+		assert.True(t, result.Truncated)
+	})
+
+	t.Run("kill removes the files", func(t *testing.T) {
+		result, err := state.executeBashCommand(ctx, "sleep 30", "", 0, true, bashOptions{Spool: &spool})
+		require.NoError(t, err)
+		shellID := extractShellID(result.Result)
+		state.Mu.RLock()
+		files := []string{state.BackgroundShells[shellID].StdoutFile, state.BackgroundShells[shellID].StderrFile}
+		state.Mu.RUnlock()
+		assert.FileExists(t, files[0])
+
+		_, err = state.executeKillShell(ctx, shellID)
+		require.NoError(t, err)
+		assert.NoFileExists(t, files[0])
+		assert.NoFileExists(t, files[1])
+	})
+
+	t.Run("server default", func(t *testing.T) {
+		require.NoError(t, state.ConfigureSpool(true, dir))
+		result, err := state.executeBashCommand(ctx, "echo hi", "", 0, true, bashOptions{})
+		require.NoError(t, err)
+		shell := waitShell(t, state, extractShellID(result.Result))
+		assert.NotEmpty(t, shell.StdoutFile)
+
+		off := false
+		result, err = state.executeBashCommand(ctx, "echo hi", "", 0, true, bashOptions{Spool: &off})
+		require.NoError(t, err)
+		shell = waitShell(t, state, extractShellID(result.Result))
+		assert.Empty(t, shell.StdoutFile)
+		assert.Equal(t, "hi\n", shell.Stdout.String())
+	})
+
+	t.Run("relative directory rejected", func(t *testing.T) {
+		require.Error(t, NewState().ConfigureSpool(true, "spool"))
+	})
+}
@@ -0,0 +1,272 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// astGrepBinary is the ast-grep command. Its short alias, sg, is not used because it collides with
+// the shadow-utils command of the same name on most Linux systems.
+const astGrepBinary = "ast-grep"
+
+type structuralMatch struct {
+	File     string              `json:"file"`
+	Line     int                 `json:"line"`
+	Column   int                 `json:"column"`
+	EndLine  int                 `json:"end_line"`
+	Text     string              `json:"text"`
+	Captures map[string]string   `json:"captures,omitempty"`
+	Multi    map[string][]string `json:"multi_captures,omitempty"`
+}
+
+type structuralSearchResult struct {
+	Matches    []structuralMatch `json:"matches"`
+	Total      int               `json:"total"`
+	NextOffset int               `json:"next_offset,omitempty"`
+}
+
+// astGrepMatch is one match in ast-grep's JSON output. Lines and columns are zero-based.
+type astGrepMatch struct {
+	Text  string `json:"text"`
+	File  string `json:"file"`
+	Range struct {
+		Start struct {
+			Line   int `json:"line"`
+			Column int `json:"column"`
+		} `json:"start"`
+		End struct {
+			Line int `json:"line"`
+		} `json:"end"`
+	} `json:"range"`
+	MetaVariables struct {
+		Single map[string]struct {
+			Text string `json:"text"`
+		} `json:"single"`
+		Multi map[string][]struct {
+			Text string `json:"text"`
+		} `json:"multi"`
+	} `json:"metaVariables"`
+}
+
+func (s *State) executeStructuralSearch(ctx context.Context, pattern, language, path string, globs []string, timeoutMs int64, headLimit, offset int) (string, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return "", fmt.Errorf("pattern is required.")
+	}
+	if offset < 0 {
+		return "", fmt.Errorf("offset cannot be negative.")
+	}
+	if timeoutMs < 0 || timeoutMs > maxTimeout {
+		return "", fmt.Errorf("timeout_ms must be between 0 and %d milliseconds (10 minutes).", maxTimeout)
+	}
+	headLimit = resultLimit(ctx, headLimit)
+
+	searchPath := s.workDir(ctx)
+	if searchPath == "" {
+		return "", fmt.Errorf("Cannot determine working directory")
+	}
+	var err error
+	if path != "" {
+		if searchPath, err = s.resolvePath(path); err != nil {
+			return "", err
+		}
+	}
+	if err := s.checkPathAllowed(searchPath); err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(searchPath); err != nil {
+		return "", fmt.Errorf("path does not exist: %s", searchPath)
+	}
+
+	args := []string{"run", "--pattern", pattern, "--json=stream"}
+	if language != "" {
+		args = append(args, "--lang", language)
+	}
+	for _, glob := range globs {
+		args = append(args, "--globs", glob)
+	}
+	// Exclusions come after the caller's globs so that they take precedence over them
+	s.Mu.RLock()
+	denied := s.DeniedPaths
+	s.Mu.RUnlock()
+	cwd, _ := os.Getwd()
+	for _, glob := range ripgrepDenyGlobs(denied, cwd) {
+		args = append(args, "--globs", glob)
+	}
+	args = append(args, "--", searchPath)
+
+	searchCtx := ctx
+	if timeoutMs > 0 {
+		var cancel context.CancelFunc
+		searchCtx, cancel = context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+	output, err := execAstGrep(searchCtx, args...)
+	if err != nil {
+		if searchCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return "", fmt.Errorf("Search timed out after %dms. Narrow the path, add globs or a language, or increase timeout_ms.", timeoutMs)
+		}
+		return "", err
+	}
+
+	matches, err := parseAstGrepOutput(output)
+	if err != nil {
+		return "", err
+	}
+	// The globs keep ast-grep out of denied directories; this also drops anything they missed.
+	allowed := matches[:0]
+	for _, m := range matches {
+		if s.checkPathAllowed(m.File) == nil {
+			allowed = append(allowed, m)
+		}
+	}
+	matches = allowed
+	if len(matches) == 0 {
+		return "No matches found", nil
+	}
+
+	result := structuralSearchResult{Total: len(matches)}
+	start := min(offset, len(matches))
+	end := min(start+headLimit, len(matches))
+	result.Matches = matches[start:end]
+	if end < len(matches) {
+		result.NextOffset = end
+	}
+	if result.Matches == nil {
+		result.Matches = []structuralMatch{}
+	}
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format matches: %s", err)
+	}
+	formatted := string(jsonBytes)
+	if err := checkOutputSize(ctx, formatted, "structural_search"); err != nil {
+		return "", err
+	}
+	return formatted, nil
+}
+
+// execAstGrep runs ast-grep and returns its standard output. ast-grep exits with code 1 when
+// nothing matched, which is not an error.
+func execAstGrep(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, astGrepBinary, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err == nil {
+		return output, nil
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("ast-grep is not installed. Install it with `npm install -g @ast-grep/cli`, `cargo install ast-grep`, or `brew install ast-grep` to use structural_search.")
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		message := strings.TrimSpace(stderr.String())
+		if exitErr.ExitCode() == 1 && message == "" {
+			return output, nil
+		}
+		return nil, fmt.Errorf("ast-grep exited with code %d:\n%s", exitErr.ExitCode(), message)
+	}
+	return nil, fmt.Errorf("Failed to execute ast-grep: %s", err)
+}
+
+// parseAstGrepOutput converts ast-grep's streamed JSON, one match per line, into matches sorted by
+// file and position with one-based lines and columns.
+func parseAstGrepOutput(output []byte) ([]structuralMatch, error) {
+	var matches []structuralMatch
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var raw astGrepMatch
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return nil, fmt.Errorf("Cannot parse ast-grep output: %s", err)
+		}
+		match := structuralMatch{
+			File:    raw.File,
+			Line:    raw.Range.Start.Line + 1,
+			Column:  raw.Range.Start.Column + 1,
+			EndLine: raw.Range.End.Line + 1,
+			Text:    raw.Text,
+		}
+		for name, capture := range raw.MetaVariables.Single {
+			if match.Captures == nil {
+				match.Captures = map[string]string{}
+			}
+			match.Captures[name] = capture.Text
+		}
+		for name, nodes := range raw.MetaVariables.Multi {
+			// Multi-node captures include the separators between the nodes, which carry no meaning
+			// on their own.
+			texts := []string{}
+			for _, node := range nodes {
+				if node.Text != "," && node.Text != ";" {
+					texts = append(texts, node.Text)
+				}
+			}
+			if match.Multi == nil {
+				match.Multi = map[string][]string{}
+			}
+			match.Multi[name] = texts
+		}
+		matches = append(matches, match)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Cannot read ast-grep output: %s", err)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].File != matches[j].File {
+			return matches[i].File < matches[j].File
+		}
+		if matches[i].Line != matches[j].Line {
+			return matches[i].Line < matches[j].Line
+		}
+		return matches[i].Column < matches[j].Column
+	})
+	return matches, nil
+}
+
+var StructuralSearchTool = sdk.Tool{
+	Name:        "structural_search",
+	Description: "Searches code by syntax rather than text, using ast-grep's tree-sitter patterns. Use it for queries regex cannot express reliably, such as every call with particular arguments regardless of formatting.\n\nUsage:\n- pattern is code in the target language with metavariables: $NAME matches one node, $$$NAME matches any number (e.g. `fmt.Errorf($MSG)`, `useEffect($FN, [])`, `if err != nil { return $$$ }`)\n- Set lang (e.g. go, ts, tsx, python, rust) when the pattern could parse differently across languages; otherwise each file is matched in its own language\n- path defaults to the working directory; globs (e.g. \"**/*.go\", \"!vendor/**\") narrow the files searched\n- Each match has its file, one-based line, column and end_line, the matched text, and the text captured by each metavariable\n- Results are paged with head_limit and offset; next_offset is set when more matches remain\n- Requires the ast-grep command to be installed",
+}
+
+type StructuralSearchInput struct {
+	Pattern   string   `json:"pattern" jsonschema:"The ast-grep pattern to match, written as code with $METAVARIABLES"`
+	Lang      string   `json:"lang,omitempty" jsonschema:"The language to parse the pattern and files as (e.g. go, ts, python). Defaults to each file's language"`
+	Path      string   `json:"path,omitempty" jsonschema:"File or directory to search in. Defaults to working directory"`
+	Globs     []string `json:"globs,omitempty" jsonschema:"Glob patterns selecting the files to search; prefix with ! to exclude"`
+	TimeoutMs int64    `json:"timeout_ms,omitempty" jsonschema:"Abort the search after this many milliseconds (max 600000). Default: no limit"`
+	HeadLimit int      `json:"head_limit,omitempty" jsonschema:"Return at most this many matches"`
+	Offset    int      `json:"offset,omitempty" jsonschema:"Skip the first N matches, for fetching subsequent pages"`
+}
+type StructuralSearchOutput struct {
+	Result string `json:"result"`
+}
+
+func StructuralSearch(ctx context.Context, req *sdk.CallToolRequest, args StructuralSearchInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeStructuralSearch(ctx, args.Pattern, args.Lang, args.Path, args.Globs, args.TimeoutMs, args.HeadLimit, args.Offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &StructuralSearchOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
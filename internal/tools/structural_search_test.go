@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAstGrepOutput(t *testing.T) {
+	output := `{"text":"fmt.Errorf(\"b\")","range":{"start":{"line":9,"column":1},"end":{"line":9,"column":16}},"file":"/src/b.go","metaVariables":{"single":{"MSG":{"text":"\"b\""}},"multi":{},"transformed":{}}}
+{"text":"call(x, y)","range":{"start":{"line":2,"column":4},"end":{"line":3,"column":0}},"file":"/src/a.go","metaVariables":{"single":{},"multi":{"ARGS":[{"text":"x"},{"text":","},{"text":"y"}]},"transformed":{}}}
+`
+	matches, err := parseAstGrepOutput([]byte(output))
+	require.NoError(t, err)
+	assert.Equal(t, []structuralMatch{
+		{File: "/src/a.go", Line: 3, Column: 5, EndLine: 4, Text: "call(x, y)", Multi: map[string][]string{"ARGS": {"x", "y"}}},
+		{File: "/src/b.go", Line: 10, Column: 2, EndLine: 10, Text: `fmt.Errorf("b")`, Captures: map[string]string{"MSG": `"b"`}},
+	}, matches)
+
+	_, err = parseAstGrepOutput([]byte("not json\n"))
+	assert.ErrorContains(t, err, "Cannot parse ast-grep output")
+}
+
+func TestStructuralSearch_Validation(t *testing.T) {
+	state := NewState()
+	_, err := state.executeStructuralSearch(context.Background(), " ", "", "", nil, 0, 0, 0)
+	assert.ErrorContains(t, err, "pattern is required")
+
+	_, err = state.executeStructuralSearch(context.Background(), "f($A)", "", "", nil, 0, 0, -1)
+	assert.ErrorContains(t, err, "offset cannot be negative")
+
+	_, err = state.executeStructuralSearch(context.Background(), "f($A)", "", filepath.Join(t.TempDir(), "missing"), nil, 0, 0, 0)
+	assert.ErrorContains(t, err, "does not exist")
+}
+
+func TestStructuralSearch_AstGrep(t *testing.T) {
+	if _, err := exec.LookPath(astGrepBinary); err != nil {
+		t.Skip("ast-grep is not installed")
+	}
+	state := NewState()
+	dir := t.TempDir()
+	src := "package demo\n\nimport \"fmt\"\n\nfunc f(err error) error {\n\treturn fmt.Errorf(\"wrap: %w\", err)\n}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "demo.go"), []byte(src), 0o644))
+
+	result, err := state.executeStructuralSearch(context.Background(), "fmt.Errorf($FMT, $ERR)", "go", dir, nil, 0, 0, 0)
+	require.NoError(t, err)
+	var parsed structuralSearchResult
+	require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+	require.Len(t, parsed.Matches, 1)
+	assert.Equal(t, 6, parsed.Matches[0].Line)
+	assert.Equal(t, "err", parsed.Matches[0].Captures["ERR"])
+
+	result, err = state.executeStructuralSearch(context.Background(), "errors.New($MSG)", "go", dir, nil, 0, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "No matches found", result)
+}
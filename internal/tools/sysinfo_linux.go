@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// systemMemory reports total and available memory in bytes from /proc/meminfo.
+func systemMemory() (total, available int64, ok bool) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, 0, false
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Lines look like "MemTotal:       16318480 kB".
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total = kb * 1024
+		case "MemAvailable:":
+			available = kb * 1024
+		}
+	}
+	return total, available, total > 0
+}
+
+// osRelease reports the distribution name from /etc/os-release and the kernel release.
+func osRelease() (distro, kernel string) {
+	if data, err := os.ReadFile("/etc/os-release"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if value, ok := strings.CutPrefix(line, "PRETTY_NAME="); ok {
+				distro = strings.Trim(value, `"'`)
+				break
+			}
+		}
+	}
+	if data, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		kernel = strings.TrimSpace(string(data))
+	}
+	return distro, kernel
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package tools
+
+// systemMemory is only implemented on Linux, where /proc/meminfo exposes memory counters.
+func systemMemory() (total, available int64, ok bool) {
+	return 0, 0, false
+}
+
+// osRelease is only implemented on Linux, where the distribution and kernel are readable from files.
+func osRelease() (distro, kernel string) {
+	return "", ""
+}
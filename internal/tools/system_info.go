@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// systemInfoBinaries are the tools whose versions system_info reports by default.
+var systemInfoBinaries = []string{"bash", "rg", "git", "node", "python3", "python"}
+
+// binaryVersionTimeout bounds each `--version` probe so a binary that ignores the flag and waits
+// for input cannot hold up the report.
+const binaryVersionTimeout = 5 * time.Second
+
+type memoryInfo struct {
+	Total     int64 `json:"total"`
+	Available int64 `json:"available"`
+}
+
+type diskInfo struct {
+	Path      string `json:"path"`
+	Total     int64  `json:"total"`
+	Free      int64  `json:"free"`
+	Available int64  `json:"available"`
+}
+
+type binaryInfo struct {
+	Path    string `json:"path,omitempty"`
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type systemInfo struct {
+	OS         string                `json:"os"`
+	Arch       string                `json:"arch"`
+	Distro     string                `json:"distro,omitempty"`
+	Kernel     string                `json:"kernel,omitempty"`
+	Hostname   string                `json:"hostname,omitempty"`
+	CPUs       int                   `json:"cpus"`
+	Memory     *memoryInfo           `json:"memory,omitempty"`
+	Disk       *diskInfo             `json:"disk,omitempty"`
+	WorkingDir string                `json:"working_dir,omitempty"`
+	Binaries   map[string]binaryInfo `json:"binaries"`
+}
+
+func (s *State) executeSystemInfo(ctx context.Context, path string, binaries []string) (string, error) {
+	if path == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("Cannot determine working directory: %s", err)
+		}
+		path = wd
+	}
+	resolved, err := s.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range binaries {
+		if name == "" || strings.ContainsAny(name, `/\`) {
+			return "", fmt.Errorf("Invalid binary name: %q. Must be a command name looked up on PATH, not a path.", name)
+		}
+	}
+	if len(binaries) == 0 {
+		binaries = systemInfoBinaries
+	}
+
+	info := systemInfo{
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		CPUs:     runtime.NumCPU(),
+		Binaries: make(map[string]binaryInfo, len(binaries)),
+	}
+	info.Distro, info.Kernel = osRelease()
+	info.Hostname, _ = os.Hostname()
+	info.WorkingDir, _ = os.Getwd()
+	if total, available, ok := systemMemory(); ok {
+		info.Memory = &memoryInfo{Total: total, Available: available}
+	}
+	if total, free, available, ok := diskSpace(resolved); ok {
+		info.Disk = &diskInfo{Path: resolved, Total: total, Free: free, Available: available}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range binaries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			binary := binaryVersion(ctx, name)
+			mu.Lock()
+			info.Binaries[name] = binary
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	jsonBytes, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format system info: %s", err)
+	}
+	return string(jsonBytes), nil
+}
+
+// binaryVersion locates name on PATH and reports the first non-empty line it prints for
+// --version, which is where every common tool puts its version.
+func binaryVersion(ctx context.Context, name string) binaryInfo {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return binaryInfo{Error: "not found"}
+	}
+	versionCtx, cancel := context.WithTimeout(ctx, binaryVersionTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(versionCtx, path, "--version")
+	out, err := cmd.CombinedOutput()
+	var first string
+	for _, line := range strings.Split(string(out), "\n") {
+		if first = strings.TrimSpace(line); first != "" {
+			break
+		}
+	}
+	if err != nil {
+		if first == "" {
+			first = err.Error()
+		}
+		return binaryInfo{Path: path, Error: "--version failed: " + first}
+	}
+	return binaryInfo{Path: path, Version: first}
+}
+
+var SystemInfoTool = sdk.Tool{
+	Name:        "system_info",
+	Description: "Reports the environment commands will run in, as JSON.\n\nIncludes:\n- OS, architecture, distribution and kernel (where available), hostname, and CPU count\n- Total and available memory in bytes (Linux only)\n- Total, free, and available disk space in bytes for the filesystem holding path (defaults to the working directory)\n- The path and version of key binaries: bash, rg, git, node, python3, and python by default, or the commands given in binaries\n\nUse this tool once to learn the environment instead of probing it with repeated Bash calls.",
+}
+
+type SystemInfoInput struct {
+	Path     string   `json:"path,omitempty" jsonschema:"Absolute path whose filesystem's free space to report. Defaults to the working directory"`
+	Binaries []string `json:"binaries,omitempty" jsonschema:"Command names to report versions for, replacing the default list"`
+}
+type SystemInfoOutput struct {
+	Result string `json:"result"`
+}
+
+func SystemInfo(ctx context.Context, req *sdk.CallToolRequest, args SystemInfoInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeSystemInfo(ctx, args.Path, args.Binaries)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &SystemInfoOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemInfo(t *testing.T) {
+	state := NewState()
+	dir := t.TempDir()
+
+	t.Run("reports platform and binaries", func(t *testing.T) {
+		result, err := state.executeSystemInfo(context.Background(), dir, []string{"git", "go", "definitely-not-a-real-binary"})
+		require.NoError(t, err)
+		var info systemInfo
+		require.NoError(t, json.Unmarshal([]byte(result), &info))
+		assert.Equal(t, runtime.GOOS, info.OS)
+		assert.Equal(t, runtime.GOARCH, info.Arch)
+		assert.Positive(t, info.CPUs)
+		assert.Len(t, info.Binaries, 3)
+		assert.Contains(t, info.Binaries["git"].Version, "git version")
+		assert.Contains(t, info.Binaries["go"].Error, "--version failed")
+		assert.Equal(t, "not found", info.Binaries["definitely-not-a-real-binary"].Error)
+		if runtime.GOOS == "linux" {
+			require.NotNil(t, info.Memory)
+			assert.Positive(t, info.Memory.Total)
+			require.NotNil(t, info.Disk)
+			assert.Equal(t, dir, info.Disk.Path)
+			assert.Positive(t, info.Disk.Total)
+		}
+	})
+
+	t.Run("defaults to the key binaries", func(t *testing.T) {
+		result, err := state.executeSystemInfo(context.Background(), "", nil)
+		require.NoError(t, err)
+		var info systemInfo
+		require.NoError(t, json.Unmarshal([]byte(result), &info))
+		for _, name := range systemInfoBinaries {
+			assert.Contains(t, info.Binaries, name)
+		}
+	})
+
+	t.Run("rejects paths as binary names", func(t *testing.T) {
+		_, err := state.executeSystemInfo(context.Background(), "", []string{"/bin/sh"})
+		assert.ErrorContains(t, err, "Invalid binary name")
+	})
+
+	t.Run("rejects relative paths", func(t *testing.T) {
+		_, err := state.executeSystemInfo(context.Background(), "relative", nil)
+		assert.ErrorContains(t, err, "must be absolute")
+	})
+}
@@ -0,0 +1,241 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// defaultTaskMaxTurns bounds the sub-agent loop when the caller doesn't specify a limit. Each
+	// turn is one sampling round trip to the client, so the default keeps cost predictable.
+	defaultTaskMaxTurns = 10
+	maxTaskMaxTurns     = 50
+
+	// taskMaxTokens caps each sampled reply. Replies are either a single tool call or the final
+	// report, neither of which needs more.
+	taskMaxTokens = 4096
+
+	// maxSubagentToolOutput truncates tool results fed back to the sub-agent so a single large
+	// Read or Grep can't crowd the rest of the conversation out of the client model's context.
+	maxSubagentToolOutput = 20_000
+)
+
+// subagentTool adapts one of this server's tool handlers for use inside the task loop, where tool
+// inputs arrive as raw JSON emitted by the sampled model.
+type subagentTool struct {
+	tool   *sdk.Tool
+	params string
+	call   func(ctx context.Context, input json.RawMessage) (string, error)
+}
+
+func newSubagentTool[In any](tool *sdk.Tool, handler func(context.Context, *sdk.CallToolRequest, In) (*sdk.CallToolResult, any, error)) subagentTool {
+	var zero In
+	return subagentTool{
+		tool:   tool,
+		params: describeParams(reflect.TypeOf(zero)),
+		call: func(ctx context.Context, input json.RawMessage) (string, error) {
+			var args In
+			if len(input) > 0 {
+				if err := json.Unmarshal(input, &args); err != nil {
+					return "", fmt.Errorf("invalid input for %s: %s", tool.Name, err)
+				}
+			}
+			result, _, err := handler(ctx, &sdk.CallToolRequest{}, args)
+			if err != nil {
+				return "", err
+			}
+			var texts []string
+			for _, content := range result.Content {
+				if text, ok := content.(*sdk.TextContent); ok {
+					texts = append(texts, text.Text)
+				}
+			}
+			return strings.Join(texts, "\n"), nil
+		},
+	}
+}
+
+// describeParams renders the JSON fields of an input struct, with their jsonschema descriptions,
+// so the sub-agent's system prompt can document each tool's parameters.
+func describeParams(t reflect.Type) string {
+	var lines []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		required := ""
+		if !strings.Contains(opts, "omitempty") {
+			required = " (required)"
+		}
+		lines = append(lines, fmt.Sprintf("    - %s (%s)%s: %s", name, field.Type.Kind(), required, field.Tag.Get("jsonschema")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// subagentTools returns the tools available to task sub-agents. The set is deliberately
+// read-only: the sub-agent runs autonomously, so anything that mutates files or runs commands
+// stays with the calling client where it remains subject to the user's oversight.
+func subagentTools() map[string]subagentTool {
+	tools := []subagentTool{
+		newSubagentTool(&ReadTool, Read),
+		newSubagentTool(&GlobTool, Glob),
+		newSubagentTool(&GrepTool, Grep),
+		newSubagentTool(&LsTool, Ls),
+	}
+	byName := make(map[string]subagentTool, len(tools))
+	for _, tool := range tools {
+		byName[tool.tool.Name] = tool
+	}
+	return byName
+}
+
+func taskSystemPrompt(tools map[string]subagentTool) string {
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("You are a sub-agent that completes a single task autonomously by using tools, then reports back.\n\n")
+	b.WriteString("To call a tool, reply with ONLY a JSON object of the form {\"tool\": \"<name>\", \"input\": {<parameters>}} and nothing else. ")
+	b.WriteString("The tool result will be sent back to you in the next message. Call one tool per reply.\n")
+	b.WriteString("When you have finished, reply with {\"final\": \"<your complete answer>\"}. ")
+	b.WriteString("Your final answer is the only thing the caller will see, so include every relevant detail, such as absolute file paths and line numbers.\n\n")
+	b.WriteString("Available tools:\n")
+	for _, name := range names {
+		tool := tools[name]
+		firstLine, _, _ := strings.Cut(strings.TrimLeft(tool.tool.Description, "- "), "\n")
+		fmt.Fprintf(&b, "\n- %s: %s\n  Parameters:\n%s\n", name, firstLine, tool.params)
+	}
+	return b.String()
+}
+
+// subagentReply is the JSON protocol spoken by the sampled model: either a tool call or a final
+// answer. Replies that don't parse as JSON are treated as a plain-text final answer.
+type subagentReply struct {
+	Tool  string          `json:"tool"`
+	Input json.RawMessage `json:"input"`
+	Final string          `json:"final"`
+}
+
+func parseSubagentReply(text string) subagentReply {
+	trimmed := strings.TrimSpace(text)
+	// Models often wrap JSON in a Markdown code fence despite instructions not to.
+	if strings.HasPrefix(trimmed, "```") {
+		trimmed = strings.TrimPrefix(trimmed, "```json")
+		trimmed = strings.TrimPrefix(trimmed, "```")
+		trimmed = strings.TrimSuffix(strings.TrimSpace(trimmed), "```")
+		trimmed = strings.TrimSpace(trimmed)
+	}
+	var reply subagentReply
+	if err := json.Unmarshal([]byte(trimmed), &reply); err != nil || (reply.Tool == "" && reply.Final == "") {
+		return subagentReply{Final: strings.TrimSpace(text)}
+	}
+	return reply
+}
+
+func (s *State) executeTask(ctx context.Context, session *sdk.ServerSession, description, prompt string, maxTurns int) (*TaskOutput, error) {
+	if strings.TrimSpace(prompt) == "" {
+		return nil, fmt.Errorf("prompt cannot be empty.")
+	}
+	if session == nil {
+		return nil, fmt.Errorf("The task tool requires a client session that supports sampling.")
+	}
+	if params := session.InitializeParams(); params != nil && params.Capabilities != nil && params.Capabilities.Sampling == nil {
+		return nil, fmt.Errorf("The connected client does not support sampling, which the task tool requires.")
+	}
+
+	if maxTurns <= 0 {
+		maxTurns = defaultTaskMaxTurns
+	}
+	if maxTurns > maxTaskMaxTurns {
+		return nil, fmt.Errorf("max_turns cannot exceed %d.", maxTaskMaxTurns)
+	}
+
+	tools := subagentTools()
+	systemPrompt := taskSystemPrompt(tools)
+	messages := []*sdk.SamplingMessage{{
+		Role:    "user",
+		Content: &sdk.TextContent{Text: prompt},
+	}}
+
+	for turn := 1; turn <= maxTurns; turn++ {
+		result, err := session.CreateMessage(ctx, &sdk.CreateMessageParams{
+			Messages:     messages,
+			SystemPrompt: systemPrompt,
+			MaxTokens:    taskMaxTokens,
+			Metadata:     map[string]string{"task": description},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Sampling request failed: %s", err)
+		}
+		text, ok := result.Content.(*sdk.TextContent)
+		if !ok {
+			return nil, fmt.Errorf("Sampling returned non-text content.")
+		}
+
+		reply := parseSubagentReply(text.Text)
+		if reply.Tool == "" {
+			return &TaskOutput{Result: reply.Final, Turns: turn}, nil
+		}
+
+		var toolResult string
+		if tool, ok := tools[reply.Tool]; !ok {
+			toolResult = fmt.Sprintf("Error: unknown tool %q.", reply.Tool)
+		} else if output, err := tool.call(ctx, reply.Input); err != nil {
+			toolResult = "Error: " + err.Error()
+		} else {
+			toolResult = output
+		}
+		if len(toolResult) > maxSubagentToolOutput {
+			toolResult = toolResult[:maxSubagentToolOutput] + "\n[output truncated]"
+		}
+
+		messages = append(messages,
+			&sdk.SamplingMessage{Role: "assistant", Content: &sdk.TextContent{Text: text.Text}},
+			&sdk.SamplingMessage{Role: "user", Content: &sdk.TextContent{Text: fmt.Sprintf("Result of %s:\n%s", reply.Tool, toolResult)}},
+		)
+	}
+
+	return nil, fmt.Errorf("Task did not finish within %d turns. Consider a narrower prompt or a larger max_turns.", maxTurns)
+}
+
+var TaskTool = sdk.Tool{
+	Name:        "task",
+	Description: "Launch a sub-agent to handle an open-ended search or research task autonomously.\n\nThe sub-agent runs on the client's own model via MCP sampling and can use the read, glob, grep, and ls tools over multiple rounds before returning a single summarized result. It cannot modify files or run commands.\n\nUsage notes:\n- Use this for searches that may require several rounds of globbing, grepping, and reading, e.g. \"where is retry logic handled?\"\n- The prompt should be a complete, self-contained description of the task, including exactly what information the result must contain, since the sub-agent sees nothing else from the conversation.\n- The sub-agent's result is not shown to the user; summarize it for them.\n- Requires a client that supports sampling.",
+}
+
+type TaskInput struct {
+	Description string `json:"description,omitempty" jsonschema:"A short (3-5 word) description of the task"`
+	Prompt      string `json:"prompt" jsonschema:"The task for the sub-agent to perform"`
+	MaxTurns    int    `json:"max_turns,omitempty" jsonschema:"Maximum number of sampling rounds (default 10, max 50)"`
+}
+type TaskOutput struct {
+	Result string `json:"result"`
+	Turns  int    `json:"turns"`
+}
+
+func Task(ctx context.Context, req *sdk.CallToolRequest, args TaskInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	var session *sdk.ServerSession
+	if req != nil {
+		session = req.Session
+	}
+	output, err := server.executeTask(ctx, session, args.Description, args.Prompt, args.MaxTurns)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: output.Result}},
+		StructuredContent: output,
+	}, output, nil
+}
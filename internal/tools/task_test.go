@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTask_ParseSubagentReply(t *testing.T) {
+	t.Run("tool call", func(t *testing.T) {
+		reply := parseSubagentReply(`{"tool": "glob", "input": {"pattern": "**/*.go"}}`)
+		assert.Equal(t, "glob", reply.Tool)
+		assert.JSONEq(t, `{"pattern": "**/*.go"}`, string(reply.Input))
+	})
+	t.Run("fenced tool call", func(t *testing.T) {
+		reply := parseSubagentReply("```json\n{\"tool\": \"ls\", \"input\": {\"path\": \"/tmp\"}}\n```")
+		assert.Equal(t, "ls", reply.Tool)
+	})
+	t.Run("final answer", func(t *testing.T) {
+		reply := parseSubagentReply(`{"final": "Found it in /src/retry.go:42"}`)
+		assert.Empty(t, reply.Tool)
+		assert.Equal(t, "Found it in /src/retry.go:42", reply.Final)
+	})
+	t.Run("plain text is final", func(t *testing.T) {
+		reply := parseSubagentReply("  The answer is 42.  ")
+		assert.Empty(t, reply.Tool)
+		assert.Equal(t, "The answer is 42.", reply.Final)
+	})
+}
+
+func TestTask_SubagentTools(t *testing.T) {
+	tools := subagentTools()
+	for _, name := range []string{"read", "glob", "grep", "ls"} {
+		assert.Contains(t, tools, name)
+	}
+	assert.NotContains(t, tools, "bash")
+	assert.NotContains(t, tools, "write")
+
+	t.Run("dispatches raw JSON input", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "notes.txt")
+		require.NoError(t, os.WriteFile(path, []byte("hello"), 0o644))
+		input, err := json.Marshal(map[string]string{"file_path": path})
+		require.NoError(t, err)
+		output, err := tools["read"].call(context.Background(), input)
+		require.NoError(t, err)
+		assert.Contains(t, output, "hello")
+	})
+	t.Run("invalid input", func(t *testing.T) {
+		_, err := tools["read"].call(context.Background(), json.RawMessage(`{"file_path": 5}`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid input")
+	})
+	t.Run("system prompt documents parameters", func(t *testing.T) {
+		prompt := taskSystemPrompt(tools)
+		assert.Contains(t, prompt, "file_path (string) (required)")
+		assert.Contains(t, prompt, `{"final":`)
+	})
+}
+
+func TestTask_Errors(t *testing.T) {
+	state := NewState()
+	t.Run("requires session", func(t *testing.T) {
+		_, err := state.executeTask(context.Background(), nil, "search", "find things", 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "sampling")
+	})
+	t.Run("empty prompt", func(t *testing.T) {
+		_, err := state.executeTask(context.Background(), nil, "search", "", 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "prompt cannot be empty")
+	})
+}
@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	lineEndingLF       = "lf"
+	lineEndingCRLF     = "crlf"
+	lineEndingPreserve = "preserve"
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// detectLineEnding reports "crlf" only when every newline in content is preceded by a carriage
+// return. Files with mixed endings are treated as "lf" so that callers leave them untouched rather
+// than rewriting lines the user never asked to change.
+func detectLineEnding(content string) string {
+	lf := strings.Count(content, "\n")
+	if lf > 0 && strings.Count(content, "\r\n") == lf {
+		return lineEndingCRLF
+	}
+	return lineEndingLF
+}
+
+// convertLineEndings rewrites every newline in content to the given style.
+func convertLineEndings(content, lineEnding string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if lineEnding == lineEndingCRLF {
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	}
+	return content
+}
+
+// normalizeEncoding maps accepted encoding names and aliases to a canonical name.
+func normalizeEncoding(encoding string) (string, error) {
+	switch strings.ToLower(strings.ReplaceAll(encoding, "_", "-")) {
+	case "", "utf-8", "utf8":
+		return "utf-8", nil
+	case "utf-8-bom", "utf8-bom":
+		return "utf-8-bom", nil
+	case "utf-16le", "utf16le":
+		return "utf-16le", nil
+	case "utf-16be", "utf16be":
+		return "utf-16be", nil
+	case "latin1", "latin-1", "iso-8859-1":
+		return "latin1", nil
+	case "ascii", "us-ascii":
+		return "ascii", nil
+	default:
+		return "", fmt.Errorf("Invalid encoding: %s. Must be one of: utf-8, utf-8-bom, utf-16le, utf-16be, latin1, ascii.", encoding)
+	}
+}
+
+// encodeText converts content to bytes in the given canonical encoding. UTF-16 output is prefixed
+// with a byte order mark, since most tools rely on it to recognize the encoding.
+func encodeText(content, encoding string) ([]byte, error) {
+	switch encoding {
+	case "utf-8":
+		return []byte(content), nil
+	case "utf-8-bom":
+		return append(append([]byte{}, utf8BOM...), content...), nil
+	case "utf-16le", "utf-16be":
+		var order binary.AppendByteOrder = binary.LittleEndian
+		bom := utf16LEBOM
+		if encoding == "utf-16be" {
+			order, bom = binary.BigEndian, utf16BEBOM
+		}
+		units := utf16.Encode([]rune(content))
+		out := make([]byte, len(bom), len(bom)+2*len(units))
+		copy(out, bom)
+		for _, u := range units {
+			out = order.AppendUint16(out, u)
+		}
+		return out, nil
+	case "latin1", "ascii":
+		limit := rune(0xFF)
+		if encoding == "ascii" {
+			limit = 0x7F
+		}
+		out := make([]byte, 0, len(content))
+		for i, r := range content {
+			if r > limit {
+				return nil, fmt.Errorf("content cannot be encoded as %s: character %q at byte offset %d", encoding, r, i)
+			}
+			out = append(out, byte(r))
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("Invalid encoding: %s", encoding)
+	}
+}
+
+// decodeText converts raw file bytes to a string, recognizing UTF-8 and UTF-16 byte order marks.
+// Content without a BOM is assumed to be UTF-8.
+func decodeText(raw []byte) string {
+	switch {
+	case bytes.HasPrefix(raw, utf8BOM):
+		return string(raw[len(utf8BOM):])
+	case bytes.HasPrefix(raw, utf16LEBOM), bytes.HasPrefix(raw, utf16BEBOM):
+		var order binary.ByteOrder = binary.LittleEndian
+		if bytes.HasPrefix(raw, utf16BEBOM) {
+			order = binary.BigEndian
+		}
+		raw = raw[2:]
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 0; i+1 < len(raw); i += 2 {
+			units = append(units, order.Uint16(raw[i:]))
+		}
+		return string(utf16.Decode(units))
+	default:
+		return string(raw)
+	}
+}
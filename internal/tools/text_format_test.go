@@ -0,0 +1,34 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectLineEnding(t *testing.T) {
+	assert.Equal(t, lineEndingCRLF, detectLineEnding("a\r\nb\r\n"))
+	assert.Equal(t, lineEndingLF, detectLineEnding("a\nb\n"))
+	assert.Equal(t, lineEndingLF, detectLineEnding("a\r\nb\n"), "mixed endings are left alone")
+	assert.Equal(t, lineEndingLF, detectLineEnding("no newline"))
+}
+
+func TestEncodeDecodeText(t *testing.T) {
+	for _, encoding := range []string{"utf-8", "utf-8-bom", "utf-16le", "utf-16be"} {
+		t.Run(encoding, func(t *testing.T) {
+			data, err := encodeText("héllo 🌍\n", encoding)
+			require.NoError(t, err)
+			assert.Equal(t, "héllo 🌍\n", decodeText(data))
+		})
+	}
+
+	t.Run("aliases", func(t *testing.T) {
+		encoding, err := normalizeEncoding("UTF_16LE")
+		require.NoError(t, err)
+		assert.Equal(t, "utf-16le", encoding)
+		encoding, err = normalizeEncoding("")
+		require.NoError(t, err)
+		assert.Equal(t, "utf-8", encoding)
+	})
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// TodoItem is a single entry of a session's task list.
+type TodoItem struct {
+	Content    string `json:"content" jsonschema:"The imperative description of the task, e.g. 'Run tests'"`
+	Status     string `json:"status" jsonschema:"The task status: pending, in_progress, or completed"`
+	ActiveForm string `json:"activeForm" jsonschema:"The present continuous form shown while the task is in progress, e.g. 'Running tests'"`
+}
+
+func (s *State) executeTodoWrite(ctx context.Context, session string, todos []TodoItem) ([]TodoItem, error) {
+	for i, todo := range todos {
+		if strings.TrimSpace(todo.Content) == "" {
+			return nil, fmt.Errorf("todo %d: content cannot be empty", i+1)
+		}
+		if strings.TrimSpace(todo.ActiveForm) == "" {
+			return nil, fmt.Errorf("todo %d: activeForm cannot be empty", i+1)
+		}
+		switch todo.Status {
+		case "pending", "in_progress", "completed":
+		default:
+			return nil, fmt.Errorf("todo %d: invalid status %q. Must be one of: pending, in_progress, completed.", i+1, todo.Status)
+		}
+	}
+
+	// Copy so later mutation of the caller's slice can't alter stored state.
+	stored := append([]TodoItem{}, todos...)
+	s.Mu.Lock()
+	if len(stored) == 0 {
+		delete(s.Todos, session)
+	} else {
+		s.Todos[session] = stored
+		s.useSession(session)
+	}
+	s.Mu.Unlock()
+	return stored, nil
+}
+
+// formatTodos renders a todo list as a checklist for the text content of the result.
+func formatTodos(todos []TodoItem) string {
+	if len(todos) == 0 {
+		return "Todo list cleared."
+	}
+	var b strings.Builder
+	b.WriteString("Todos have been modified successfully. Ensure that you continue to use the todo list to track your progress. Please proceed with the current tasks if applicable.\n")
+	for _, todo := range todos {
+		switch todo.Status {
+		case "completed":
+			b.WriteString("\n[x] " + todo.Content)
+		case "in_progress":
+			b.WriteString("\n[>] " + todo.ActiveForm)
+		default:
+			b.WriteString("\n[ ] " + todo.Content)
+		}
+	}
+	return b.String()
+}
+
+var TodoWriteTool = sdk.Tool{
+	Name:        "todo_write",
+	Description: "Use this tool to create and manage a structured task list for your current coding session. This helps you track progress, organize complex tasks, and demonstrate thoroughness to the user.\n\n## When to Use This Tool\n- Complex multi-step tasks that require 3 or more distinct steps\n- When the user provides multiple tasks to be done\n- After receiving new instructions, to capture requirements as todos\n- When you start working on a task, mark it as in_progress BEFORE beginning work. Ideally only one task should be in_progress at a time\n- After completing a task, mark it as completed immediately and add any new follow-up tasks discovered during implementation\n\n## When NOT to Use This Tool\n- There is only a single, straightforward task\n- The task is trivial or purely conversational\n\n## Task States\n- pending: Task not yet started\n- in_progress: Currently working on\n- completed: Task finished successfully\n\nEach call replaces the entire list for the session, so always send every task. Each task needs content (imperative form, e.g. \"Run tests\") and activeForm (present continuous form, e.g. \"Running tests\").",
+}
+
+type TodoWriteInput struct {
+	Todos []TodoItem `json:"todos" jsonschema:"The updated todo list"`
+}
+type TodoWriteOutput struct {
+	Todos []TodoItem `json:"todos"`
+}
+
+func TodoWrite(ctx context.Context, req *sdk.CallToolRequest, args TodoWriteInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	todos, err := server.executeTodoWrite(ctx, sessionID(req), args.Todos)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &TodoWriteOutput{Todos: todos}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: formatTodos(todos)}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTodoWrite_BasicFunctionality(t *testing.T) {
+	state := NewState()
+	todos := []TodoItem{
+		{Content: "Write code", Status: "completed", ActiveForm: "Writing code"},
+		{Content: "Run tests", Status: "in_progress", ActiveForm: "Running tests"},
+		{Content: "Open PR", Status: "pending", ActiveForm: "Opening PR"},
+	}
+	t.Run("stores list per session", func(t *testing.T) {
+		stored, err := state.executeTodoWrite(context.Background(), "session-a", todos)
+		require.NoError(t, err)
+		assert.Equal(t, todos, stored)
+		state.Mu.RLock()
+		defer state.Mu.RUnlock()
+		assert.Equal(t, todos, state.Todos["session-a"])
+		assert.Empty(t, state.Todos["session-b"])
+	})
+	t.Run("replaces previous list", func(t *testing.T) {
+		_, err := state.executeTodoWrite(context.Background(), "session-a", todos[:1])
+		require.NoError(t, err)
+		state.Mu.RLock()
+		defer state.Mu.RUnlock()
+		assert.Len(t, state.Todos["session-a"], 1)
+	})
+	t.Run("empty list clears", func(t *testing.T) {
+		_, err := state.executeTodoWrite(context.Background(), "session-a", nil)
+		require.NoError(t, err)
+		state.Mu.RLock()
+		defer state.Mu.RUnlock()
+		_, exists := state.Todos["session-a"]
+		assert.False(t, exists)
+	})
+	t.Run("formats checklist", func(t *testing.T) {
+		text := formatTodos(todos)
+		assert.Contains(t, text, "[x] Write code")
+		assert.Contains(t, text, "[>] Running tests")
+		assert.Contains(t, text, "[ ] Open PR")
+	})
+}
+
+func TestTodoWrite_Errors(t *testing.T) {
+	state := NewState()
+	t.Run("invalid status", func(t *testing.T) {
+		_, err := state.executeTodoWrite(context.Background(), "s", []TodoItem{{Content: "a", Status: "done", ActiveForm: "a"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid status")
+	})
+	t.Run("empty content", func(t *testing.T) {
+		_, err := state.executeTodoWrite(context.Background(), "s", []TodoItem{{Content: " ", Status: "pending", ActiveForm: "a"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "content cannot be empty")
+	})
+}
+
+func TestTodoWrite_MCPIntegration(t *testing.T) {
+	result, output, err := TodoWrite(context.Background(), &sdk.CallToolRequest{}, TodoWriteInput{
+		Todos: []TodoItem{{Content: "Task", Status: "pending", ActiveForm: "Doing task"}},
+	})
+	require.NoError(t, err)
+	assert.NotNil(t, result)
+	assert.Len(t, output.(*TodoWriteOutput).Todos, 1)
+}
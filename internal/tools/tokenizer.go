@@ -0,0 +1,161 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenizerTimeout bounds a single run of a --tokenizer-command.
+const tokenizerTimeout = 30 * time.Second
+
+// SetTokenizer sets the default tokenizer for count_tokens. command, if set, is run with the text
+// on stdin and must print its token count; it makes "command" the default when name is empty.
+func (s *State) SetTokenizer(name, command string) error {
+	fields := strings.Fields(command)
+	if name == "" {
+		name = "heuristic"
+		if len(fields) > 0 {
+			name = "command"
+		}
+	}
+	if !slices.Contains([]string{"chars", "heuristic", "command"}, name) {
+		return fmt.Errorf("Invalid tokenizer: %s. Must be one of: chars, heuristic, command.", name)
+	}
+	if name == "command" && len(fields) == 0 {
+		return fmt.Errorf("the command tokenizer requires --tokenizer-command")
+	}
+	s.Mu.Lock()
+	s.Tokenizer = name
+	s.TokenizerCommand = fields
+	s.Mu.Unlock()
+	return nil
+}
+
+// tokenCounter returns a function counting tokens with the named tokenizer, or the server's
+// default when name is empty.
+func (s *State) tokenCounter(name string) (string, func(ctx context.Context, text string) (int, error), error) {
+	s.Mu.RLock()
+	command := s.TokenizerCommand
+	if name == "" {
+		name = s.Tokenizer
+	}
+	s.Mu.RUnlock()
+	switch name {
+	case "chars":
+		return name, func(_ context.Context, text string) (int, error) { return (len(text) + 3) / 4, nil }, nil
+	case "heuristic", "":
+		return "heuristic", func(_ context.Context, text string) (int, error) { return heuristicTokens(text), nil }, nil
+	case "command":
+		if len(command) == 0 {
+			return "", nil, fmt.Errorf("The command tokenizer is not available. Start the server with --tokenizer-command to enable it.")
+		}
+		return name, func(ctx context.Context, text string) (int, error) { return commandTokens(ctx, command, text) }, nil
+	default:
+		return "", nil, fmt.Errorf("Invalid tokenizer: %s. Must be one of: chars, heuristic, command.", name)
+	}
+}
+
+// heuristicTokens estimates the token count of text as a BPE tokenizer would split it: a word is
+// one token per 8 letters, split where camelCase and snake_case parts meet, and a number one per 3
+// digits. A single space joins the token after it, as BPE vocabularies hold " word" as one token;
+// other whitespace runs and line breaks are a token each. Punctuation pairs up, since operators
+// such as := and == are single tokens, and characters of scripts written without spaces, such as
+// Chinese and Japanese, count one each.
+func heuristicTokens(text string) int {
+	tokens := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == ' ' && i+1 < len(text) && !unicode.IsSpace(rune(text[i+1])):
+			i++
+		case r == '\n' || r == '\r':
+			for i < len(text) && (text[i] == '\n' || text[i] == '\r') {
+				i++
+			}
+			tokens++
+		case unicode.IsSpace(r):
+			i += size
+			for i < len(text) && (text[i] == ' ' || text[i] == '\t') {
+				i++
+			}
+			tokens++
+		case r >= '0' && r <= '9':
+			j := i
+			for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+				j++
+			}
+			tokens += (j - i + 2) / 3
+			i = j
+		case isUnspacedScript(r):
+			tokens++
+			i += size
+		case unicode.IsLetter(r):
+			letters, j := 0, i
+			prevLower := false
+			for j < len(text) {
+				r, size := utf8.DecodeRuneInString(text[j:])
+				if !(unicode.IsLetter(r) || unicode.IsMark(r)) || isUnspacedScript(r) {
+					break
+				}
+				if prevLower && unicode.IsUpper(r) {
+					break
+				}
+				prevLower = unicode.IsLower(r)
+				letters++
+				j += size
+			}
+			tokens += (letters + 7) / 8
+			i = j
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			j, n := i, 0
+			for j < len(text) {
+				r, size := utf8.DecodeRuneInString(text[j:])
+				if !(unicode.IsPunct(r) || unicode.IsSymbol(r)) || r == '_' && n > 0 {
+					break
+				}
+				n++
+				j += size
+			}
+			tokens += (n + 1) / 2
+			i = j
+		default:
+			tokens++
+			i += size
+		}
+	}
+	return tokens
+}
+
+func isUnspacedScript(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai)
+}
+
+// commandTokens runs the tokenizer command with text on stdin and parses the count it prints.
+func commandTokens(ctx context.Context, command []string, text string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, tokenizerTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return 0, fmt.Errorf("tokenizer command failed: %s", message)
+		}
+		return 0, fmt.Errorf("tokenizer command failed: %s", err)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(stdout.String()))
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("tokenizer command printed %q; it must print the token count as a single number", strings.TrimSpace(stdout.String()))
+	}
+	return count, nil
+}
@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func (s *State) executeUndoEdit(ctx context.Context, session string, editID int, force bool) (string, error) {
+	if err := s.checkOverlay("undo_edit"); err != nil {
+		return "", err
+	}
+	s.Mu.RLock()
+	history := s.EditHistory[session]
+	index := -1
+	if editID == 0 {
+		index = len(history) - 1
+	} else {
+		for i, r := range history {
+			if r.ID == editID {
+				index = i
+				break
+			}
+		}
+	}
+	var record EditRecord
+	if index >= 0 {
+		record = history[index]
+	}
+	s.Mu.RUnlock()
+
+	if index < 0 {
+		if editID == 0 {
+			return "", fmt.Errorf("no edits to undo in this session")
+		}
+		return "", fmt.Errorf("no edit with id %d in this session's history", editID)
+	}
+
+	defer s.lockPaths(ctx, record.Path)()
+	fsys := s.filesystem()
+
+	// Only revert when the file still holds exactly what the edit wrote; otherwise a later change
+	// would be silently discarded.
+	if !force {
+		current, err := fsys.ReadFile(record.Path)
+		if err != nil || !bytes.Equal(current, record.NewContent) {
+			return "", fmt.Errorf("%s has changed since edit %d. Undo later edits to it first, or set force to true to overwrite the current content", record.Path, record.ID)
+		}
+	}
+
+	s.noteChange(ctx, "undo_edit", record.Path)
+	var message string
+	if record.Existed {
+		if err := fsys.MkdirAll(filepath.Dir(record.Path), 0o750); err != nil {
+			return "", fmt.Errorf("Cannot create parent directory: %s", err)
+		}
+		if err := s.writeFile(record.Path, record.OldContent, 0); err != nil {
+			return "", err
+		}
+		message = fmt.Sprintf("Reverted edit %d (%s) of %s.", record.ID, record.Tool, record.Path)
+	} else {
+		if err := fsys.Remove(record.Path); err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("Cannot delete: %s", err)
+		}
+		message = fmt.Sprintf("Reverted edit %d: deleted %s, which it created.", record.ID, record.Path)
+	}
+
+	s.Mu.Lock()
+	history = s.EditHistory[session]
+	for i, r := range history {
+		if r.ID == record.ID {
+			s.EditHistory[session] = append(history[:i:i], history[i+1:]...)
+			break
+		}
+	}
+	s.Mu.Unlock()
+
+	// The client's view of the file predates the undo; require a fresh read before further edits.
+	s.forgetPath(record.Path)
+
+	return message, nil
+}
+
+var UndoEditTool = sdk.Tool{
+	Name:        "undo_edit",
+	Description: "Reverts a change made by the write or edit tool in this session, restoring the file's previous content.\n\nUsage:\n- With no edit_id, the most recent change is undone. Use list_edits to find the id of an earlier one.\n- Undoing a write that created a file deletes the file.\n- The undo fails if the file has changed since that edit, for example through a later edit; undo those first, or set force to true to discard the current content.\n- Read the file again before editing it after an undo.",
+}
+
+type UndoEditInput struct {
+	EditID int  `json:"edit_id,omitempty" jsonschema:"The id of the edit to revert, from list_edits. Defaults to the most recent edit"`
+	Force  bool `json:"force,omitempty" jsonschema:"Revert even if the file has changed since the edit, discarding the current content"`
+}
+type UndoEditOutput struct {
+	Message string `json:"message"`
+}
+
+func UndoEdit(ctx context.Context, req *sdk.CallToolRequest, args UndoEditInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeUndoEdit(withSession(ctx, req), sessionID(req), args.EditID, args.Force)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &UndoEditOutput{Message: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUndoEdit(t *testing.T) {
+	t.Run("reverts most recent edit", func(t *testing.T) {
+		state, path := setupFileForEdit(t, "hello world")
+		_, err := callEdit(t, state, EditInput{FilePath: path, OldString: "world", NewString: "there"})
+		require.NoError(t, err)
+
+		result, err := state.executeUndoEdit(context.Background(), defaultSessionID, 0, false)
+		require.NoError(t, err)
+		assert.Contains(t, result, "Reverted edit")
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", string(content))
+		assert.Empty(t, state.EditHistory[defaultSessionID])
+
+		_, err = state.executeUndoEdit(context.Background(), defaultSessionID, 0, false)
+		assert.Error(t, err, "nothing left to undo")
+	})
+
+	t.Run("undoing a create deletes the file", func(t *testing.T) {
+		state := NewState()
+		path := filepath.Join(t.TempDir(), "new.txt")
+		_, err := callWrite(t, state, WriteInput{FilePath: path, Content: "x"})
+		require.NoError(t, err)
+		_, err = state.executeUndoEdit(context.Background(), defaultSessionID, 0, false)
+		require.NoError(t, err)
+		assert.NoFileExists(t, path)
+	})
+
+	t.Run("refuses when file changed since edit", func(t *testing.T) {
+		state, path := setupFileForEdit(t, "a b c")
+		_, err := callEdit(t, state, EditInput{FilePath: path, OldString: "a", NewString: "x"})
+		require.NoError(t, err)
+		first := state.EditHistory[defaultSessionID][0].ID
+		_, err = callEdit(t, state, EditInput{FilePath: path, OldString: "b", NewString: "y"})
+		require.NoError(t, err)
+
+		_, err = state.executeUndoEdit(context.Background(), defaultSessionID, first, false)
+		assert.Error(t, err)
+
+		_, err = state.executeUndoEdit(context.Background(), defaultSessionID, first, true)
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "a b c", string(content))
+	})
+
+	t.Run("requires a fresh read afterwards", func(t *testing.T) {
+		state, path := setupFileForEdit(t, "hello world")
+		_, err := callEdit(t, state, EditInput{FilePath: path, OldString: "world", NewString: "there"})
+		require.NoError(t, err)
+		_, err = state.executeUndoEdit(context.Background(), defaultSessionID, 0, false)
+		require.NoError(t, err)
+		_, err = callEdit(t, state, EditInput{FilePath: path, OldString: "world", NewString: "again"})
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown id", func(t *testing.T) {
+		_, err := NewState().executeUndoEdit(context.Background(), defaultSessionID, 42, false)
+		assert.Error(t, err)
+	})
+}
+
+func TestUndoEdit_MCPIntegration(t *testing.T) {
+	_, _, err := UndoEdit(context.Background(), &sdk.CallToolRequest{}, UndoEditInput{EditID: -1})
+	assert.Error(t, err)
+}
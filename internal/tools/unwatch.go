@@ -0,0 +1,46 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func (s *State) executeUnwatch(ctx context.Context, watchID string) (string, error) {
+	watch, err := s.lookupWatch(watchID)
+	if err != nil {
+		return "", err
+	}
+	s.Mu.Lock()
+	delete(s.Watches, watchID)
+	s.Mu.Unlock()
+	watch.stop()
+
+	return fmt.Sprintf("Stopped watching %s: %s", watch.Path, watchID), nil
+}
+
+var UnwatchTool = sdk.Tool{
+	Name:        "unwatch",
+	Description: "Stops a watch started with watch_path.\n\nUsage:\n- Any events not yet collected with watch_events are discarded.",
+}
+
+type UnwatchInput struct {
+	WatchID string `json:"watch_id" jsonschema:"The ID of the watch to stop"`
+}
+type UnwatchOutput struct {
+	Message string `json:"message"`
+}
+
+func Unwatch(ctx context.Context, req *sdk.CallToolRequest, args UnwatchInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeUnwatch(ctx, args.WatchID)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &UnwatchOutput{Message: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
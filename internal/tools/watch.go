@@ -0,0 +1,345 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/fsnotify/fsnotify"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// maxWatches bounds how many watches may be active at once across all sessions.
+	maxWatches = 64
+
+	// maxWatchedDirs bounds how many directories one recursive watch subscribes to, since each
+	// costs a kernel watch descriptor and those are a limited per-user resource.
+	maxWatchedDirs = 10_000
+
+	// maxWatchEvents bounds the events a watch keeps between watch_events calls. When it is full
+	// the oldest events are dropped and counted.
+	maxWatchEvents = 1000
+
+	// watchNotifyDelay batches the notifications of a watch, so a build writing hundreds of files
+	// sends one message rather than hundreds.
+	watchNotifyDelay = 500 * time.Millisecond
+)
+
+// WatchEvent is one change to a watched path.
+type WatchEvent struct {
+	Time string `json:"time"`
+	Op   string `json:"op"`
+	Path string `json:"path"`
+}
+
+// Watch records the file changes under a path for the session that created it with watch_path,
+// until unwatch stops it.
+type Watch struct {
+	ID        string
+	SessionID string
+	Path      string
+	Recursive bool
+	Pattern   string
+	Notify    bool
+	CreatedAt time.Time
+
+	watcher *fsnotify.Watcher
+	// session receives change notifications when Notify is set.
+	session *sdk.ServerSession
+	done    chan struct{}
+
+	// mu guards the fields below. events holds the changes not yet returned by watch_events, and
+	// dropped counts those discarded because events was full. changed is closed when an event
+	// arrives, waking watch_events calls that wait for one. unnotified holds the paths changed since
+	// the last notification, which is sent when notifyTimer fires. err is the latest watcher error.
+	mu          sync.Mutex
+	events      []WatchEvent
+	dropped     int
+	changed     chan struct{}
+	unnotified  []string
+	notifyTimer *time.Timer
+	watchedDirs int
+	err         string
+}
+
+func (s *State) executeWatchPath(ctx context.Context, path string, recursive bool, pattern string, notify bool) (string, error) {
+	resolved, err := s.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("path does not exist")
+	}
+	if pattern != "" && !doublestar.ValidatePattern(pattern) {
+		return "", fmt.Errorf("Invalid pattern: %s", pattern)
+	}
+	if recursive && !info.IsDir() {
+		return "", fmt.Errorf("recursive requires a directory")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return "", fmt.Errorf("Cannot start watcher: %s", err)
+	}
+	watch := &Watch{
+		SessionID: sessionIDFromContext(ctx),
+		Path:      resolved,
+		Recursive: recursive,
+		Pattern:   pattern,
+		Notify:    notify,
+		CreatedAt: time.Now(),
+		watcher:   watcher,
+		session:   sessionFromContext(ctx),
+		done:      make(chan struct{}),
+	}
+	if recursive {
+		err = watch.addTree(resolved)
+	} else {
+		err = watcher.Add(resolved)
+		watch.watchedDirs = 1
+	}
+	if err != nil {
+		_ = watcher.Close()
+		return "", fmt.Errorf("Cannot watch %s: %s", resolved, err)
+	}
+
+	s.Mu.Lock()
+	if len(s.Watches) >= maxWatches {
+		s.Mu.Unlock()
+		_ = watcher.Close()
+		return "", fmt.Errorf("Too many active watches (limit %d). Stop unneeded ones with unwatch.", maxWatches)
+	}
+	watch.ID = fmt.Sprintf("watch_%d", s.NextWatchID)
+	s.NextWatchID++
+	s.Watches[watch.ID] = watch
+	s.Mu.Unlock()
+
+	go s.runWatch(watch)
+
+	what := "Watching"
+	if recursive {
+		what = fmt.Sprintf("Watching %d directories under", watch.watchedDirs)
+	}
+	return fmt.Sprintf("%s %s with ID: %s", what, resolved, watch.ID), nil
+}
+
+// addTree subscribes to dir and every directory beneath it, since inotify and its peers only
+// report changes to a directory's immediate children. Unreadable subdirectories are skipped.
+func (w *Watch) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		w.mu.Lock()
+		full := w.watchedDirs >= maxWatchedDirs
+		w.mu.Unlock()
+		if full {
+			return fmt.Errorf("more than %d directories to watch. Watch a narrower directory.", maxWatchedDirs)
+		}
+		if err := w.watcher.Add(path); err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		w.mu.Lock()
+		w.watchedDirs++
+		w.mu.Unlock()
+		return nil
+	})
+}
+
+// runWatch records the watcher's events until the watch is stopped.
+func (s *State) runWatch(w *Watch) {
+	defer close(w.done)
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			// Directories created under a recursive watch are watched in turn, so files written
+			// into them are seen too.
+			if w.Recursive && event.Has(fsnotify.Create) {
+				if info, err := os.Lstat(event.Name); err == nil && info.IsDir() {
+					if err := w.addTree(event.Name); err != nil {
+						w.mu.Lock()
+						w.err = err.Error()
+						w.mu.Unlock()
+					}
+				}
+			}
+			if s.checkPathAllowed(event.Name) != nil || !w.matches(event.Name) {
+				continue
+			}
+			w.record(WatchEvent{
+				Time: time.Now().Format(time.RFC3339Nano),
+				Op:   strings.ToLower(event.Op.String()),
+				Path: event.Name,
+			})
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.mu.Lock()
+			w.err = err.Error()
+			w.mu.Unlock()
+		}
+	}
+}
+
+// matches reports whether a changed path passes the watch's pattern, which is matched against the
+// path relative to the watched directory.
+func (w *Watch) matches(path string) bool {
+	if w.Pattern == "" {
+		return true
+	}
+	rel, err := filepath.Rel(w.Path, path)
+	if err != nil || rel == "." {
+		rel = filepath.Base(path)
+	}
+	match, _ := doublestar.Match(w.Pattern, filepath.ToSlash(rel))
+	return match
+}
+
+func (w *Watch) record(event WatchEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.events) >= maxWatchEvents {
+		w.events = w.events[1:]
+		w.dropped++
+	}
+	w.events = append(w.events, event)
+	if w.changed != nil {
+		close(w.changed)
+		w.changed = nil
+	}
+	if w.Notify && w.session != nil {
+		w.unnotified = append(w.unnotified, event.Path)
+		if w.notifyTimer == nil {
+			w.notifyTimer = time.AfterFunc(watchNotifyDelay, w.notify)
+		}
+	}
+}
+
+// watchNotification is the payload of the log notification sent when watched files change.
+type watchNotification struct {
+	Event   string   `json:"event"`
+	WatchID string   `json:"watch_id"`
+	Count   int      `json:"count"`
+	Paths   []string `json:"paths"`
+}
+
+// notify pushes a notifications/message summarizing the changes since the last one. Like shell
+// completion notifications it is best-effort, and clients can always poll watch_events instead.
+func (w *Watch) notify() {
+	w.mu.Lock()
+	count := len(w.unnotified)
+	paths := uniqueStrings(w.unnotified)
+	w.unnotified = nil
+	w.notifyTimer = nil
+	w.mu.Unlock()
+	if count == 0 {
+		return
+	}
+	const maxNotifiedPaths = 20
+	if len(paths) > maxNotifiedPaths {
+		paths = paths[:maxNotifiedPaths]
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = w.session.Log(ctx, &sdk.LoggingMessageParams{
+		Level:  "info",
+		Logger: "watch",
+		Data: watchNotification{
+			Event:   "files_changed",
+			WatchID: w.ID,
+			Count:   count,
+			Paths:   paths,
+		},
+	})
+}
+
+// uniqueStrings returns values without repeats, in order of first appearance.
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// stop closes the watcher and waits for its event loop to exit.
+func (w *Watch) stop() {
+	_ = w.watcher.Close()
+	<-w.done
+	w.mu.Lock()
+	if w.notifyTimer != nil {
+		w.notifyTimer.Stop()
+		w.notifyTimer = nil
+	}
+	w.mu.Unlock()
+}
+
+// lookupWatch returns the watch with the given ID.
+func (s *State) lookupWatch(watchID string) (*Watch, error) {
+	if watchID == "" {
+		return nil, fmt.Errorf("watch_id is required.")
+	}
+	s.Mu.RLock()
+	watch, ok := s.Watches[watchID]
+	s.Mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Watch with ID '%s' not found.", watchID)
+	}
+	return watch, nil
+}
+
+var WatchPathTool = sdk.Tool{
+	Name:        "watch_path",
+	Description: "Starts recording changes to a file or directory, so you can react to generated files or rebuilt outputs without polling with Bash.\n\nUsage:\n- path must be absolute. A directory watch sees changes to its immediate children; set recursive to also watch every subdirectory, including ones created later.\n- Set pattern to a glob (e.g. \"**/*.go\"), matched against paths relative to the watched directory, to record only matching changes.\n- Returns a watch ID. Use watch_events to collect the recorded changes and unwatch to stop.\n- Set notify to also push a notifications/message (logger \"watch\") when changes arrive, batched every half second. Clients receive it only if they enabled logging and the session is still connected.",
+}
+
+type WatchPathInput struct {
+	Path      string `json:"path" jsonschema:"The absolute path of the file or directory to watch"`
+	Recursive bool   `json:"recursive,omitempty" jsonschema:"Also watch every subdirectory of the directory"`
+	Pattern   string `json:"pattern,omitempty" jsonschema:"Only record changes to paths matching this glob, relative to the watched directory"`
+	Notify    bool   `json:"notify,omitempty" jsonschema:"Push a log notification when changes arrive"`
+}
+type WatchPathOutput struct {
+	Message string `json:"message"`
+}
+
+func WatchPath(ctx context.Context, req *sdk.CallToolRequest, args WatchPathInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeWatchPath(withSession(ctx, req), args.Path, args.Recursive, args.Pattern, args.Notify)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &WatchPathOutput{Message: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxWatchWait bounds how long watch_events may wait for a change.
+const maxWatchWait = 60 * time.Second
+
+type watchEventsEntry struct {
+	WatchID string       `json:"watch_id"`
+	Path    string       `json:"path"`
+	Events  []WatchEvent `json:"events"`
+	Pending int          `json:"pending,omitempty"`
+	Dropped int          `json:"dropped,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+type watchEventsResult struct {
+	Watches []watchEventsEntry `json:"watches"`
+}
+
+func (s *State) executeWatchEvents(ctx context.Context, watchID string, waitMs int64) (string, error) {
+	var watches []*Watch
+	if watchID != "" {
+		watch, err := s.lookupWatch(watchID)
+		if err != nil {
+			return "", err
+		}
+		watches = []*Watch{watch}
+	} else {
+		sessionID := sessionIDFromContext(ctx)
+		s.Mu.RLock()
+		for _, watch := range s.Watches {
+			if watch.SessionID == sessionID {
+				watches = append(watches, watch)
+			}
+		}
+		s.Mu.RUnlock()
+		if len(watches) == 0 {
+			return "", fmt.Errorf("No active watches. Start one with watch_path.")
+		}
+		sort.Slice(watches, func(i, j int) bool { return watches[i].CreatedAt.Before(watches[j].CreatedAt) })
+	}
+
+	wait := time.Duration(waitMs) * time.Millisecond
+	if wait > maxWatchWait {
+		return "", fmt.Errorf("wait_ms cannot exceed %d milliseconds.", maxWatchWait.Milliseconds())
+	}
+	if wait > 0 {
+		if err := waitForWatchEvents(ctx, watches, wait); err != nil {
+			return "", err
+		}
+	}
+
+	// Events beyond the result limit stay queued for the next call.
+	remaining := limitsFromContext(ctx).maxResults
+	result := watchEventsResult{Watches: []watchEventsEntry{}}
+	for _, watch := range watches {
+		watch.mu.Lock()
+		n := min(len(watch.events), remaining)
+		entry := watchEventsEntry{
+			WatchID: watch.ID,
+			Path:    watch.Path,
+			Events:  append([]WatchEvent{}, watch.events[:n]...),
+			Pending: len(watch.events) - n,
+			Dropped: watch.dropped,
+			Error:   watch.err,
+		}
+		watch.events = watch.events[n:]
+		watch.dropped = 0
+		watch.err = ""
+		watch.mu.Unlock()
+		remaining -= n
+		result.Watches = append(result.Watches, entry)
+	}
+
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to format watch events: %s", err)
+	}
+	return string(jsonBytes), nil
+}
+
+// waitForWatchEvents returns once any of watches has an event, or after wait.
+func waitForWatchEvents(ctx context.Context, watches []*Watch, wait time.Duration) error {
+	woken := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	for _, watch := range watches {
+		watch.mu.Lock()
+		if len(watch.events) > 0 {
+			watch.mu.Unlock()
+			return nil
+		}
+		if watch.changed == nil {
+			watch.changed = make(chan struct{})
+		}
+		changed := watch.changed
+		watch.mu.Unlock()
+		go func() {
+			select {
+			case <-changed:
+				select {
+				case woken <- struct{}{}:
+				default:
+				}
+			case <-stop:
+			}
+		}()
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-woken:
+	case <-timer.C:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+var WatchEventsTool = sdk.Tool{
+	Name:        "watch_events",
+	Description: "Returns the file changes recorded by watch_path since the last call, oldest first.\n\nUsage:\n- Pass watch_id to read one watch, or omit it to read every watch started in this session.\n- Each event has its time, operation (create, write, remove, rename, chmod), and absolute path.\n- Set wait_ms to wait up to that long (max 60000) for a change when none has been recorded yet.\n- Each watch keeps at most 1000 unread events; older ones are dropped and counted in dropped. Events beyond the result limit are left for the next call and counted in pending.",
+}
+
+type WatchEventsInput struct {
+	WatchID string `json:"watch_id,omitempty" jsonschema:"The ID of the watch to read. Defaults to every watch in this session"`
+	WaitMs  int64  `json:"wait_ms,omitempty" jsonschema:"How long to wait for a change if none has been recorded, in milliseconds (max 60000)"`
+}
+type WatchEventsOutput struct {
+	Result string `json:"result"`
+}
+
+func WatchEvents(ctx context.Context, req *sdk.CallToolRequest, args WatchEventsInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeWatchEvents(withSession(ctx, req), args.WatchID, args.WaitMs)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &WatchEventsOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collectWatchEvents waits for the watch to record events and returns the paths that changed.
+func collectWatchEvents(t *testing.T, state *State, watchID string) []string {
+	t.Helper()
+	var paths []string
+	deadline := time.Now().Add(5 * time.Second)
+	for len(paths) == 0 && time.Now().Before(deadline) {
+		result, err := state.executeWatchEvents(context.Background(), watchID, 1000)
+		require.NoError(t, err)
+		var parsed watchEventsResult
+		require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+		for _, entry := range parsed.Watches {
+			for _, event := range entry.Events {
+				paths = append(paths, event.Path)
+			}
+		}
+	}
+	return paths
+}
+
+func startWatch(t *testing.T, state *State, path string, recursive bool, pattern string) string {
+	t.Helper()
+	result, err := state.executeWatchPath(context.Background(), path, recursive, pattern, false)
+	require.NoError(t, err)
+	state.Mu.RLock()
+	defer state.Mu.RUnlock()
+	for id, watch := range state.Watches {
+		if watch.Path == path {
+			assert.Contains(t, result, id)
+			return id
+		}
+	}
+	t.Fatalf("watch for %s not registered", path)
+	return ""
+}
+
+func TestWatch_RecordsChanges(t *testing.T) {
+	state := NewState()
+	dir := t.TempDir()
+	watchID := startWatch(t, state, dir, false, "")
+	defer state.executeUnwatch(context.Background(), watchID)
+
+	file := filepath.Join(dir, "out.txt")
+	require.NoError(t, os.WriteFile(file, []byte("built"), 0o644))
+	assert.Contains(t, collectWatchEvents(t, state, watchID), file)
+
+	t.Run("events are drained once returned", func(t *testing.T) {
+		// Let any trailing write events for the file arrive and be collected.
+		time.Sleep(100 * time.Millisecond)
+		_, err := state.executeWatchEvents(context.Background(), watchID, 0)
+		require.NoError(t, err)
+		result, err := state.executeWatchEvents(context.Background(), watchID, 0)
+		require.NoError(t, err)
+		var parsed watchEventsResult
+		require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+		assert.Empty(t, parsed.Watches[0].Events)
+	})
+}
+
+func TestWatch_Recursive(t *testing.T) {
+	state := NewState()
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "b"), 0o755))
+	watchID := startWatch(t, state, dir, true, "**/*.go")
+	defer state.executeUnwatch(context.Background(), watchID)
+
+	ignored := filepath.Join(dir, "a", "b", "notes.txt")
+	nested := filepath.Join(dir, "a", "b", "main.go")
+	require.NoError(t, os.WriteFile(ignored, []byte("x"), 0o644))
+	require.NoError(t, os.WriteFile(nested, []byte("package main"), 0o644))
+	paths := collectWatchEvents(t, state, watchID)
+	assert.Contains(t, paths, nested)
+	assert.NotContains(t, paths, ignored)
+
+	t.Run("watches directories created later", func(t *testing.T) {
+		created := filepath.Join(dir, "new")
+		require.NoError(t, os.Mkdir(created, 0o755))
+		// Give the watcher a moment to subscribe to the new directory.
+		time.Sleep(200 * time.Millisecond)
+		file := filepath.Join(created, "gen.go")
+		require.NoError(t, os.WriteFile(file, []byte("package gen"), 0o644))
+		// Events for main.go may still trail in, so collect until the new file shows up.
+		var paths []string
+		deadline := time.Now().Add(5 * time.Second)
+		for !slices.Contains(paths, file) && time.Now().Before(deadline) {
+			paths = append(paths, collectWatchEvents(t, state, watchID)...)
+		}
+		assert.Contains(t, paths, file)
+	})
+}
+
+func TestWatch_SessionEventsAndUnwatch(t *testing.T) {
+	state := NewState()
+	_, err := state.executeWatchEvents(context.Background(), "", 0)
+	assert.ErrorContains(t, err, "No active watches")
+
+	first := startWatch(t, state, t.TempDir(), false, "")
+	second := startWatch(t, state, t.TempDir(), false, "")
+
+	result, err := state.executeWatchEvents(context.Background(), "", 0)
+	require.NoError(t, err)
+	var parsed watchEventsResult
+	require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+	require.Len(t, parsed.Watches, 2)
+	assert.Equal(t, first, parsed.Watches[0].WatchID)
+	assert.Equal(t, second, parsed.Watches[1].WatchID)
+
+	_, err = state.executeUnwatch(context.Background(), first)
+	require.NoError(t, err)
+	_, err = state.executeUnwatch(context.Background(), first)
+	assert.ErrorContains(t, err, "not found")
+	_, err = state.executeUnwatch(context.Background(), second)
+	require.NoError(t, err)
+	assert.Empty(t, state.Watches)
+}
+
+func TestWatch_Validation(t *testing.T) {
+	state := NewState()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0o644))
+
+	_, err := state.executeWatchPath(context.Background(), "relative", false, "", false)
+	assert.ErrorContains(t, err, "must be absolute")
+	_, err = state.executeWatchPath(context.Background(), filepath.Join(dir, "missing"), false, "", false)
+	assert.ErrorContains(t, err, "does not exist")
+	_, err = state.executeWatchPath(context.Background(), file, true, "", false)
+	assert.ErrorContains(t, err, "recursive requires a directory")
+	_, err = state.executeWatchPath(context.Background(), dir, false, "[", false)
+	assert.ErrorContains(t, err, "Invalid pattern")
+	_, err = state.executeWatchEvents(context.Background(), "watch_99", 0)
+	assert.ErrorContains(t, err, "not found")
+}
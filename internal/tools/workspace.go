@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+)
+
+// workspaceScheme prefixes paths given relative to a named workspace, as in
+// workspace://name/relative/path.
+const workspaceScheme = "workspace://"
+
+var workspaceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// SetWorkspaces registers named workspace roots from name=/path specs. Tools then accept
+// workspace://name/relative/path wherever they take an absolute path, and glob and grep can search
+// a workspace by name.
+func (s *State) SetWorkspaces(specs []string) error {
+	fsys := s.filesystem()
+	workspaces := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		name, root, ok := strings.Cut(spec, "=")
+		if !ok || !workspaceNamePattern.MatchString(name) {
+			return fmt.Errorf("Invalid workspace %q. Must be name=/absolute/path, the name made of letters, digits, '_', '.', and '-'.", spec)
+		}
+		if _, exists := workspaces[name]; exists {
+			return fmt.Errorf("Workspace %s is defined more than once.", name)
+		}
+		resolved, err := resolvePath(root)
+		if err != nil {
+			return fmt.Errorf("Invalid workspace %s: %s", name, err)
+		}
+		// Symlinks are resolved once here so that containment checks compare real paths.
+		if resolved, err = fsys.EvalSymlinks(resolved); err != nil {
+			return fmt.Errorf("Invalid workspace %s: %s", name, err)
+		}
+		if info, err := fsys.Stat(resolved); err != nil || !info.IsDir() {
+			return fmt.Errorf("Invalid workspace %s: %s is not a directory", name, root)
+		}
+		workspaces[name] = resolved
+	}
+	s.Mu.Lock()
+	s.Workspaces = workspaces
+	s.Mu.Unlock()
+	return nil
+}
+
+// resolvePath is resolvePath extended with workspace URIs: workspace://name/relative/path resolves
+// to the path under the root of workspace name, and may not leave it, even through a symlink.
+func (s *State) resolvePath(filePath string) (string, error) {
+	rest, ok := strings.CutPrefix(filePath, workspaceScheme)
+	if !ok {
+		return resolvePath(filePath)
+	}
+	name, relative, _ := strings.Cut(rest, "/")
+	root, err := s.workspaceRoot(name)
+	if err != nil {
+		return "", err
+	}
+	if relative == "" {
+		return root, nil
+	}
+	if !filepath.IsLocal(relative) {
+		return "", fmt.Errorf("Invalid path %s: the path after the workspace name must be relative and stay inside the workspace.", filePath)
+	}
+	resolved := filepath.Join(root, relative)
+	if !withinRoot(s.filesystem(), root, resolved) {
+		return "", fmt.Errorf("Invalid path %s: it leads outside workspace %s through a symlink.", filePath, name)
+	}
+	return resolved, nil
+}
+
+// workspaceRoot returns the root of the named workspace.
+func (s *State) workspaceRoot(name string) (string, error) {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	if root, ok := s.Workspaces[name]; ok {
+		return root, nil
+	}
+	if len(s.Workspaces) == 0 {
+		return "", fmt.Errorf("Unknown workspace %q: no workspaces are configured.", name)
+	}
+	names := make([]string, 0, len(s.Workspaces))
+	for known := range s.Workspaces {
+		names = append(names, known)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("Unknown workspace %q. Must be one of: %s", name, strings.Join(names, ", "))
+}
+
+// workspacePath combines the workspace and path arguments of a search tool: with a workspace, path
+// is relative to its root, which is searched when path is empty.
+func workspacePath(workspace, path string) (string, error) {
+	if workspace == "" {
+		return path, nil
+	}
+	if filepath.IsAbs(path) || strings.HasPrefix(path, workspaceScheme) {
+		return "", errors.New("path must be relative to the workspace when workspace is set")
+	}
+	return workspaceScheme + workspace + "/" + filepath.ToSlash(path), nil
+}
+
+// withinRoot reports whether path, with the symlinks along its existing part on fsys resolved, is
+// root or lies beneath it. root must already be free of symlinks.
+func withinRoot(fsys vfs.FS, root, path string) bool {
+	existing, missing := path, ""
+	for {
+		real, err := fsys.EvalSymlinks(existing)
+		if err == nil {
+			existing = filepath.Join(real, missing)
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return false
+		}
+		missing = filepath.Join(filepath.Base(existing), missing)
+		existing = parent
+	}
+	relative, err := filepath.Rel(root, existing)
+	return err == nil && filepath.IsLocal(relative)
+}
@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspacePaths(t *testing.T) {
+	state := NewState()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	outside := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "src"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "src", "main.go"), []byte("package main\n"), 0o644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "escape")))
+	require.NoError(t, state.SetWorkspaces([]string{"app=" + root}))
+
+	resolved, err := state.resolvePath("workspace://app/src/main.go")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "src", "main.go"), resolved)
+	resolved, err = state.resolvePath("workspace://app")
+	require.NoError(t, err)
+	assert.Equal(t, root, resolved)
+	resolved, err = state.resolvePath("workspace://app/src/..")
+	require.NoError(t, err)
+	assert.Equal(t, root, resolved)
+	// Paths that do not exist yet resolve, so that files can be created.
+	_, err = state.resolvePath("workspace://app/new/file.txt")
+	require.NoError(t, err)
+
+	_, err = state.resolvePath("workspace://app/../etc/passwd")
+	assert.ErrorContains(t, err, "stay inside the workspace")
+	_, err = state.resolvePath("workspace://app/escape/secret")
+	assert.ErrorContains(t, err, "through a symlink")
+	_, err = state.resolvePath("workspace://other/file")
+	assert.ErrorContains(t, err, "Must be one of: app")
+
+	result, err := state.executeRead(context.Background(), "workspace://app/src/main.go", 0, 0)
+	require.NoError(t, err)
+	assert.Contains(t, result, "package main")
+
+	path, err := workspacePath("app", "src")
+	require.NoError(t, err)
+	result, err = state.executeGlob(context.Background(), "*.go", path, 0, 0, false)
+	require.NoError(t, err)
+	assert.Contains(t, result, `"path": "main.go"`)
+	_, err = workspacePath("app", "/etc")
+	assert.Error(t, err)
+}
+
+func TestSetWorkspaces(t *testing.T) {
+	state := NewState()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+
+	assert.ErrorContains(t, state.SetWorkspaces([]string{dir}), "Must be name=/absolute/path")
+	assert.ErrorContains(t, state.SetWorkspaces([]string{"a/b=" + dir}), "Must be name=/absolute/path")
+	assert.ErrorContains(t, state.SetWorkspaces([]string{"app=relative"}), "must be absolute")
+	assert.ErrorContains(t, state.SetWorkspaces([]string{"app=" + file}), "not a directory")
+	assert.ErrorContains(t, state.SetWorkspaces([]string{"app=" + dir, "app=" + dir}), "more than once")
+	require.NoError(t, state.SetWorkspaces([]string{"app=" + dir}))
+}
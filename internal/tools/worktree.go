@@ -0,0 +1,287 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type gitWorktree struct {
+	Path     string `json:"path"`
+	Head     string `json:"head,omitempty"`
+	Branch   string `json:"branch,omitempty"`
+	Detached bool   `json:"detached,omitempty"`
+	Bare     bool   `json:"bare,omitempty"`
+	Locked   bool   `json:"locked,omitempty"`
+	Prunable bool   `json:"prunable,omitempty"`
+	// Current marks the worktree holding the calling session's working directory.
+	Current bool `json:"current,omitempty"`
+}
+
+func (s *State) executeWorktree(ctx context.Context, operation, repo, path, branch, ref string, use, force bool) (string, error) {
+	switch operation {
+	case "list":
+		dir, err := s.gitWorkDir(ctx, repo)
+		if err != nil {
+			return "", err
+		}
+		worktrees, err := s.listWorktrees(ctx, dir)
+		if err != nil {
+			return "", err
+		}
+		jsonBytes, err := json.MarshalIndent(worktrees, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("Failed to format git output: %s", err)
+		}
+		output := string(jsonBytes)
+		if err := checkOutputSize(ctx, output, "worktree"); err != nil {
+			return "", err
+		}
+		return output, nil
+	case "add":
+		return s.addWorktree(ctx, repo, path, branch, ref, use)
+	case "remove":
+		return s.removeWorktree(ctx, path, force)
+	case "use":
+		return s.useWorkDir(ctx, path)
+	default:
+		return "", fmt.Errorf("Invalid operation: %s. Must be one of: list, add, remove, use.", operation)
+	}
+}
+
+// listWorktrees parses `git worktree list --porcelain`, which gives one block of attribute lines
+// per worktree, separated by blank lines.
+func (s *State) listWorktrees(ctx context.Context, dir string) ([]gitWorktree, error) {
+	output, err := runGit(ctx, dir, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	current := s.workDir(ctx)
+	worktrees := []gitWorktree{}
+	for _, block := range strings.Split(strings.TrimSpace(output), "\n\n") {
+		var w gitWorktree
+		for _, line := range strings.Split(block, "\n") {
+			key, value, _ := strings.Cut(line, " ")
+			switch key {
+			case "worktree":
+				w.Path = value
+			case "HEAD":
+				w.Head = value
+			case "branch":
+				w.Branch = strings.TrimPrefix(value, "refs/heads/")
+			case "detached":
+				w.Detached = true
+			case "bare":
+				w.Bare = true
+			case "locked":
+				w.Locked = true
+			case "prunable":
+				w.Prunable = true
+			}
+		}
+		if w.Path == "" {
+			continue
+		}
+		w.Current = current != "" && isWithin(vfs.OS{}, w.Path, current)
+		worktrees = append(worktrees, w)
+	}
+	// A session inside a nested worktree is also within the main one; only the innermost counts.
+	for i := range worktrees {
+		for j := range worktrees {
+			if i != j && worktrees[i].Current && worktrees[j].Current && isWithin(vfs.OS{}, worktrees[i].Path, worktrees[j].Path) {
+				worktrees[i].Current = false
+			}
+		}
+	}
+	return worktrees, nil
+}
+
+// addWorktree creates a worktree at path checking out branch, which is created from ref (default
+// HEAD) if it does not exist yet. Without a branch the worktree gets a detached HEAD at ref.
+func (s *State) addWorktree(ctx context.Context, repo, path, branch, ref string, use bool) (string, error) {
+	dir, err := s.gitWorkDir(ctx, repo)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := s.worktreePath(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(branch, "-") || strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("branch and ref cannot start with '-'")
+	}
+
+	args := []string{"worktree", "add"}
+	var what string
+	switch {
+	case branch == "":
+		args = append(args, "--detach", "--", resolved)
+		if ref != "" {
+			args = append(args, ref)
+		}
+		what = "a detached HEAD at " + defaultString(ref, "HEAD")
+	case gitBranchExists(ctx, dir, branch):
+		if ref != "" {
+			return "", fmt.Errorf("branch %s already exists; ref can only be given when creating a new branch", branch)
+		}
+		args = append(args, "--", resolved, branch)
+		what = "branch " + branch
+	default:
+		args = append(args, "-b", branch, "--", resolved)
+		if ref != "" {
+			args = append(args, ref)
+		}
+		what = fmt.Sprintf("new branch %s from %s", branch, defaultString(ref, "HEAD"))
+	}
+	if _, err := runGit(ctx, dir, args...); err != nil {
+		return "", err
+	}
+
+	message := fmt.Sprintf("Created worktree at %s with %s", resolved, what)
+	if use {
+		s.setWorkDir(ctx, resolved)
+		message += "; it is now this session's working directory"
+	}
+	return message, nil
+}
+
+// removeWorktree deletes the worktree at path. Uncommitted changes, including untracked files,
+// make it refuse unless force is set, since removing the worktree deletes them.
+func (s *State) removeWorktree(ctx context.Context, path string, force bool) (string, error) {
+	resolved, err := s.worktreePath(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if !force {
+		status, err := gitStatus(ctx, resolved)
+		if err != nil {
+			return "", err
+		}
+		if !status.Clean {
+			changed := make([]string, len(status.Files))
+			for i, f := range status.Files {
+				changed[i] = f.Path
+			}
+			return "", fmt.Errorf("the worktree has uncommitted changes to %s; commit them first, or set force to remove it anyway", strings.Join(changed, ", "))
+		}
+	}
+	args := []string{"worktree", "remove"}
+	if force {
+		args = append(args, "--force")
+	}
+	if _, err := runGit(ctx, resolved, append(args, "--", resolved)...); err != nil {
+		return "", err
+	}
+
+	// Sessions working inside the removed worktree go back to the server's working directory.
+	message := "Removed worktree at " + resolved
+	s.Mu.Lock()
+	for session, dir := range s.WorkDirs {
+		if isWithin(vfs.OS{}, resolved, dir) {
+			delete(s.WorkDirs, session)
+			if session == sessionIDFromContext(ctx) {
+				message += "; this session's working directory was reset"
+			}
+		}
+	}
+	s.Mu.Unlock()
+	return message, nil
+}
+
+// useWorkDir sets the calling session's working directory, or resets it to the server's when path
+// is empty.
+func (s *State) useWorkDir(ctx context.Context, path string) (string, error) {
+	if path == "" {
+		s.Mu.Lock()
+		delete(s.WorkDirs, sessionIDFromContext(ctx))
+		s.Mu.Unlock()
+		return "Working directory reset to " + s.workDir(ctx), nil
+	}
+	resolved, err := s.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("directory does not exist")
+	}
+	s.setWorkDir(ctx, resolved)
+	return "Working directory set to " + resolved, nil
+}
+
+func (s *State) setWorkDir(ctx context.Context, dir string) {
+	session := sessionIDFromContext(ctx)
+	s.Mu.Lock()
+	s.WorkDirs[session] = dir
+	s.useSession(session)
+	s.Mu.Unlock()
+}
+
+// worktreePath resolves and checks the path of a worktree to create or remove.
+func (s *State) worktreePath(ctx context.Context, path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	resolved, err := s.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+	if err := s.confirmWrite(ctx, "worktree", resolved); err != nil {
+		return "", err
+	}
+	return filepath.Clean(resolved), nil
+}
+
+func gitBranchExists(ctx context.Context, dir, branch string) bool {
+	_, err := runGit(ctx, dir, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	return err == nil
+}
+
+func defaultString(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+var WorktreeTool = sdk.Tool{
+	Name:        "worktree",
+	Description: "Manages git worktrees, so work on several branches can proceed side by side in separate directories, and sets the directory this session works in.\n\nOperations:\n- list: the repository's worktrees as JSON, with path, HEAD, branch, and whether each is detached, locked, or holds this session's working directory\n- add: creates a worktree at path checking out branch, creating the branch from ref (default HEAD) if it does not exist; without branch the worktree gets a detached HEAD at ref. Set use to true to make it this session's working directory\n- remove: deletes the worktree at path; refuses when it has uncommitted changes or untracked files unless force is true, which DISCARDS them\n- use: makes path this session's working directory, or resets it to the server's when path is empty\n\nUsage notes:\n- The session's working directory is where bash, git, and repl_start run when no other directory is given; other sessions are unaffected.\n- repo is the repository directory for list and add; defaults to the working directory.\n- To switch branches within one worktree, use the git tool's switch operation.",
+}
+
+type WorktreeInput struct {
+	Operation string `json:"operation" jsonschema:"The operation: list, add, remove, or use"`
+	Repo      string `json:"repo,omitempty" jsonschema:"For list and add: absolute path of the repository directory. Defaults to the working directory"`
+	Path      string `json:"path,omitempty" jsonschema:"The absolute path of the worktree to add or remove, or of the directory to use"`
+	Branch    string `json:"branch,omitempty" jsonschema:"For add: the branch to check out, created from ref if it does not exist"`
+	Ref       string `json:"ref,omitempty" jsonschema:"For add: the commit or branch to start from (default HEAD)"`
+	Use       bool   `json:"use,omitempty" jsonschema:"For add: make the new worktree this session's working directory"`
+	Force     bool   `json:"force,omitempty" jsonschema:"For remove: remove the worktree even with uncommitted changes, discarding them"`
+}
+type WorktreeOutput struct {
+	Result string `json:"result"`
+}
+
+func Worktree(ctx context.Context, req *sdk.CallToolRequest, args WorktreeInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeWorktree(withSession(ctx, req), args.Operation, args.Repo, args.Path, args.Branch, args.Ref, args.Use, args.Force)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &WorktreeOutput{Result: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorktree(t *testing.T) {
+	state, dir := setupGitRepo(t)
+	ctx := context.Background()
+	// Compare against git's own view of the paths, which resolves symlinked temp directories.
+	dir, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+	tree := filepath.Join(filepath.Dir(dir), "feature-tree")
+	t.Cleanup(func() { os.RemoveAll(tree) })
+
+	result, err := state.executeWorktree(ctx, "add", dir, tree, "feature", "", true, false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "new branch feature from HEAD")
+	assert.Contains(t, result, "this session's working directory")
+	assert.FileExists(t, filepath.Join(tree, "hello.txt"))
+
+	t.Run("session working directory", func(t *testing.T) {
+		assert.Equal(t, tree, state.workDir(ctx))
+		out, err := callBash(t, state, BashInput{Command: "pwd"})
+		require.NoError(t, err)
+		assert.Contains(t, out, tree)
+
+		// git defaults to the session's directory too.
+		var status gitStatusResult
+		callGit(t, state, GitInput{Operation: "status"}, &status)
+		assert.Contains(t, status.Branch, "feature")
+	})
+
+	t.Run("list", func(t *testing.T) {
+		result, err := state.executeWorktree(ctx, "list", dir, "", "", "", false, false)
+		require.NoError(t, err)
+		var worktrees []gitWorktree
+		require.NoError(t, json.Unmarshal([]byte(result), &worktrees))
+		require.Len(t, worktrees, 2)
+		assert.Equal(t, dir, worktrees[0].Path)
+		assert.Equal(t, "main", worktrees[0].Branch)
+		assert.False(t, worktrees[0].Current)
+		assert.Equal(t, tree, worktrees[1].Path)
+		assert.Equal(t, "feature", worktrees[1].Branch)
+		assert.True(t, worktrees[1].Current)
+	})
+
+	t.Run("existing branch and detached", func(t *testing.T) {
+		_, err := state.executeWorktree(ctx, "add", dir, filepath.Join(t.TempDir(), "x"), "feature", "main", false, false)
+		assert.ErrorContains(t, err, "already exists")
+
+		detached := filepath.Join(t.TempDir(), "detached")
+		result, err := state.executeWorktree(ctx, "add", dir, detached, "", "", false, false)
+		require.NoError(t, err)
+		assert.Contains(t, result, "detached HEAD at HEAD")
+		_, err = state.executeWorktree(ctx, "remove", "", detached, "", "", false, false)
+		require.NoError(t, err)
+		assert.Equal(t, tree, state.workDir(ctx))
+	})
+
+	t.Run("remove refuses dirty worktrees", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(tree, "new.txt"), []byte("wip"), 0o644))
+		_, err := state.executeWorktree(ctx, "remove", "", tree, "", "", false, false)
+		assert.ErrorContains(t, err, "uncommitted changes to new.txt")
+		assert.DirExists(t, tree)
+
+		result, err := state.executeWorktree(ctx, "remove", "", tree, "", "", false, true)
+		require.NoError(t, err)
+		assert.Contains(t, result, "working directory was reset")
+		assert.NoDirExists(t, tree)
+		assert.NotEqual(t, tree, state.workDir(ctx))
+	})
+
+	t.Run("use", func(t *testing.T) {
+		other := t.TempDir()
+		result, err := state.executeWorktree(ctx, "use", "", other, "", "", false, false)
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(result, other))
+		assert.Equal(t, other, state.workDir(ctx))
+		_, err = state.executeWorktree(ctx, "use", "", "", "", "", false, false)
+		require.NoError(t, err)
+		wd, _ := os.Getwd()
+		assert.Equal(t, wd, state.workDir(ctx))
+
+		_, err = state.executeWorktree(ctx, "use", "", filepath.Join(other, "missing"), "", "", false, false)
+		assert.ErrorContains(t, err, "does not exist")
+		_, err = state.executeWorktree(ctx, "prune", "", "", "", "", false, false)
+		assert.ErrorContains(t, err, "Invalid operation")
+	})
+}
@@ -2,6 +2,8 @@ package tools
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,67 +11,262 @@ import (
 	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
-func (s *State) executeWrite(ctx context.Context, filePath, content string) (string, error) {
-	resolved, err := resolvePath(filePath)
+// defaultFileMode is the permission set for new files unless overridden with --default-file-mode.
+const defaultFileMode os.FileMode = 0o644
+
+// writeOptions holds the optional settings of a write call.
+type writeOptions struct {
+	// ContentEncoding is how the content is given: "text" (or empty), or "base64" for bytes
+	// written as they are.
+	ContentEncoding string
+
+	// Encoding and LineEndings choose the text encoding and newline style text content is
+	// stored with; empty means UTF-8 and the existing file's style.
+	Encoding    string
+	LineEndings string
+
+	// Mode is the octal permissions to set; empty keeps an existing file's and gives a new file
+	// DefaultFileMode.
+	Mode string
+
+	// IfNotExists only creates the file, failing if anything already exists at the path.
+	IfNotExists bool
+
+	// Backup saves the existing content before it is overwritten, restorable with restore_backup.
+	Backup bool
+}
+
+func (s *State) executeWrite(ctx context.Context, filePath, content string, opts writeOptions) (string, error) {
+	return s.writeAs(ctx, "write", filePath, content, opts)
+}
+
+// writeAs performs a write on behalf of tool, under whose name the change is confirmed and
+// recorded in the edit history.
+func (s *State) writeAs(ctx context.Context, tool, filePath, content string, opts writeOptions) (string, error) {
+	resolved, err := s.resolvePath(filePath)
 	if err != nil {
 		return "", err
 	}
-
-	// For existing files, enforce a read-before-write constraint to prevent accidental overwrites
-	// of files the user hasn't explicitly read first. This safeguard requires that either:
-	// (1) the file was previously read in this session, or (2) the file is being created new.
-	// Additionally, detect if the file has been modified externally since it was last read,
-	// which would indicate stale state and require a fresh read before proceeding.
-	if fileInfo, err := os.Stat(resolved); err == nil {
-		s.Mu.RLock()
-		readTime, wasRead := s.ReadFiles[resolved]
-		s.Mu.RUnlock()
-
-		if !wasRead {
-			return "", fmt.Errorf("file exists, you must read it first before writing")
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return "", err
+	}
+	defer s.lockPaths(ctx, resolved)()
+	if s.overlayEnabled() {
+		return s.stageWrite(ctx, resolved, content, opts)
+	}
+	// In create-only mode an existing file is an error rather than something to check and
+	// overwrite, so the read-first requirement does not apply.
+	fsys := s.filesystem()
+	if _, err := fsys.Lstat(resolved); err == nil && opts.IfNotExists {
+		return "", fileExistsError(resolved)
+	}
+	// Refuse stale or unread files before running the formatter or asking the user.
+	if err := s.validateFileForWrite(resolved); err != nil {
+		return "", err
+	}
+	var perm os.FileMode
+	if opts.Mode != "" {
+		if perm, err = parseFileMode(opts.Mode); err != nil {
+			return "", err
 		}
+	}
+	if err := s.confirmWrite(ctx, tool, resolved); err != nil {
+		return "", err
+	}
 
-		if fileInfo.ModTime().After(readTime) {
-			return "", fmt.Errorf("file has been modified since last read, please read again before writing")
+	existing, readErr := fsys.ReadFile(resolved)
+	data, formatNote, err := s.prepareWriteData(ctx, resolved, content, opts, existing, readErr == nil)
+	if err != nil {
+		return "", err
+	}
+
+	var backupPath string
+	if opts.Backup {
+		if backupPath, err = s.backupFile(ctx, resolved, true); err != nil {
+			return "", err
 		}
 	}
 
 	// Create parent directories if they don't exist to support writing to nested paths
-	_ = os.MkdirAll(filepath.Dir(resolved), 0o750)
+	_ = fsys.MkdirAll(filepath.Dir(resolved), 0o750)
 
-	err = os.WriteFile(resolved, []byte(content), 0o600)
-	if err != nil {
-		return "", fmt.Errorf("Cannot write file: %s", err)
+	if opts.IfNotExists {
+		if err := s.createFile(resolved, data, perm); errors.Is(err, os.ErrExist) {
+			return "", fileExistsError(resolved)
+		} else if err != nil {
+			return "", err
+		}
+	} else if err := s.writeFile(resolved, data, perm); err != nil {
+		return "", err
 	}
+	s.recordEdit(ctx, tool, resolved, readErr == nil, existing, data)
 
 	// Determine whether this is a new file or an update to generate appropriate user feedback
 	message := "File created successfully at: " + resolved
 	s.Mu.RLock()
 	_, wasRead := s.ReadFiles[resolved]
 	s.Mu.RUnlock()
-	if wasRead {
+	if wasRead && !opts.IfNotExists {
 		message = "File updated successfully at: " + resolved
 	}
+	if backupPath != "" {
+		message += " (previous content backed up to " + backupPath + ")"
+	}
+	if formatNote != "" {
+		message += "\n" + formatNote
+	}
 
 	// Update the cached modification time for this file to establish the current state.
 	// This enables future write operations to detect external changes via timestamp comparison.
 	s.Mu.Lock()
-	if fileInfo, err := os.Stat(resolved); err == nil {
+	if fileInfo, err := fsys.Stat(resolved); err == nil {
 		s.ReadFiles[resolved] = fileInfo.ModTime()
+		s.rememberContent(resolved, fileInfo.ModTime(), data)
 	}
 	s.Mu.Unlock()
 
 	return message, nil
 }
 
+// prepareWriteData turns write content into the bytes to store at resolved: decoded from base64,
+// or converted to the line endings and encoding in opts and run through the formatter.
+func (s *State) prepareWriteData(ctx context.Context, resolved, content string, opts writeOptions, existing []byte, exists bool) ([]byte, string, error) {
+	contentEncoding, encoding, lineEndings := opts.ContentEncoding, opts.Encoding, opts.LineEndings
+	var data []byte
+	var err error
+	switch contentEncoding {
+	case "", "text":
+		if data, err = prepareTextContent(content, encoding, lineEndings, existing, exists); err != nil {
+			return nil, "", err
+		}
+	case "base64":
+		// Binary content is written byte for byte; text transformations would corrupt it.
+		if encoding != "" || lineEndings != "" {
+			return nil, "", fmt.Errorf("encoding and line_endings cannot be used with base64 content")
+		}
+		if data, err = base64.StdEncoding.DecodeString(content); err != nil {
+			return nil, "", fmt.Errorf("content is not valid base64: %s", err)
+		}
+	default:
+		return nil, "", fmt.Errorf("Invalid content_encoding: %s. Must be one of: text, base64.", contentEncoding)
+	}
+	// Only UTF-8 text is formatted; formatters would mangle binary content and other encodings.
+	var formatNote string
+	if normalized, _ := normalizeEncoding(encoding); contentEncoding != "base64" && normalized == "utf-8" {
+		data, formatNote = s.formatBeforeWrite(ctx, resolved, data)
+	}
+	return data, formatNote, nil
+}
+
+// prepareTextContent applies the requested line ending style and encoding to text content.
+func prepareTextContent(content, encoding, lineEndings string, existing []byte, exists bool) ([]byte, error) {
+	encoding, err := normalizeEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+	switch lineEndings {
+	case "", lineEndingPreserve:
+		// Keep an existing file's line ending style so that rewriting a CRLF file with LF content
+		// does not silently convert every line. New files are written exactly as given.
+		if exists && detectLineEnding(decodeText(existing)) == lineEndingCRLF {
+			content = convertLineEndings(content, lineEndingCRLF)
+		}
+	case lineEndingLF, lineEndingCRLF:
+		content = convertLineEndings(content, lineEndings)
+	default:
+		return nil, fmt.Errorf("Invalid line_endings: %s. Must be one of: lf, crlf, preserve.", lineEndings)
+	}
+	return encodeText(content, encoding)
+}
+
+// writeFile writes data to resolved atomically. A non-zero perm is applied exactly, bypassing the
+// umask. Otherwise an existing file keeps its current permissions and a new file gets
+// DefaultFileMode.
+func (s *State) writeFile(resolved string, data []byte, perm os.FileMode) error {
+	// Write through symlinks rather than replacing the link itself with a regular file.
+	fsys := s.filesystem()
+	target := resolved
+	if real, err := fsys.EvalSymlinks(resolved); err == nil {
+		target = real
+	}
+
+	createPerm, exact := perm, perm != 0
+	if !exact {
+		if info, err := fsys.Stat(target); err == nil {
+			createPerm, exact = info.Mode().Perm(), true
+		} else {
+			s.Mu.RLock()
+			createPerm = s.DefaultFileMode
+			s.Mu.RUnlock()
+		}
+	}
+	if err := writeFileAtomic(fsys, target, data, createPerm, exact); err != nil {
+		return err
+	}
+	s.dropLineIndex(resolved, target)
+	return nil
+}
+
+// createFile writes data to resolved, which must not exist, with perm or else DefaultFileMode.
+// Unlike writeFile it never replaces a file, even one created concurrently.
+func (s *State) createFile(resolved string, data []byte, perm os.FileMode) error {
+	createPerm, exact := perm, perm != 0
+	if !exact {
+		s.Mu.RLock()
+		createPerm = s.DefaultFileMode
+		s.Mu.RUnlock()
+	}
+	if err := createFileExclusive(s.filesystem(), resolved, data, createPerm, exact); err != nil {
+		return err
+	}
+	s.dropLineIndex(resolved, resolved)
+	return nil
+}
+
+// fileExistsError reports that a create-only write found resolved already present.
+func fileExistsError(resolved string) error {
+	return fmt.Errorf("file already exists at %s; it was left unchanged because if_not_exists is set", resolved)
+}
+
+// validateFileForWrite checks that overwriting resolved is safe. For existing files, enforce a
+// read-before-write constraint to prevent accidental overwrites of files the user hasn't explicitly
+// read first. This safeguard requires that either: (1) the file was previously read in this session,
+// or (2) the file is being created new. Additionally, detect if the file has been modified externally
+// since it was last read, which would indicate stale state and require a fresh read before proceeding.
+func (s *State) validateFileForWrite(resolved string) error {
+	fileInfo, err := s.filesystem().Stat(resolved)
+	if err != nil {
+		return nil
+	}
+
+	s.Mu.RLock()
+	readTime, wasRead := s.ReadFiles[resolved]
+	s.Mu.RUnlock()
+
+	if !wasRead {
+		return fmt.Errorf("file exists, you must read it first before writing")
+	}
+
+	if fileInfo.ModTime().After(readTime) {
+		return fmt.Errorf("file has been modified since last read, please read again before writing")
+	}
+	return nil
+}
+
 var WriteTool = sdk.Tool{
 	Name:        "write",
-	Description: "Writes a file to the local filesystem.\n\nUsage:\n- This tool will overwrite the existing file if there is one at the provided path.\n- If this is an existing file, you MUST use the Read tool first to read the file's contents. This tool will fail if you did not read the file first.\n- ALWAYS prefer editing existing files in the codebase. NEVER write new files unless explicitly required.\n- NEVER proactively create documentation files (*.md) or README files. Only create documentation files if explicitly requested by the User.\n- Only use emojis if the user explicitly requests it. Avoid writing emojis to files unless asked.",
+	Description: "Writes a file to the local filesystem.\n\nUsage:\n- This tool will overwrite the existing file if there is one at the provided path.\n- If this is an existing file, you MUST use the Read tool first to read the file's contents. This tool will fail if you did not read the file first.\n- ALWAYS prefer editing existing files in the codebase. NEVER write new files unless explicitly required.\n- NEVER proactively create documentation files (*.md) or README files. Only create documentation files if explicitly requested by the User.\n- Only use emojis if the user explicitly requests it. Avoid writing emojis to files unless asked.\n- Content is written as UTF-8 by default; set encoding to write utf-8-bom, utf-16le, utf-16be, latin1, or ascii instead.\n- line_endings controls newlines: lf, crlf, or preserve (default), which keeps an existing file's CRLF endings and writes new files as given.\n- Existing files keep their permissions. New files are created with the server's default mode (normally 644, minus the umask); pass mode (e.g. \"755\") to set permissions explicitly.\n- Set if_not_exists to true to only create a new file: the call fails, leaving the file untouched, if anything already exists at the path. Existing files need not be read first in this mode, which suits scaffolding that must never overwrite the user's files.\n- Set backup to true to save the previous content so it can be reverted with restore_backup.\n- To write binary files (images, fonts, archives), pass the bytes base64-encoded with content_encoding set to \"base64\".",
 }
 
 type WriteInput struct {
-	FilePath string `json:"file_path" jsonschema:"The absolute path to the file to write (must be absolute, not relative)"`
-	Content  string `json:"content" jsonschema:"The content to write to the file"`
+	FilePath        string `json:"file_path" jsonschema:"The absolute path to the file to write (must be absolute, not relative)"`
+	Content         string `json:"content" jsonschema:"The content to write to the file"`
+	ContentEncoding string `json:"content_encoding,omitempty" jsonschema:"How content is encoded: 'text' (default) or 'base64' for binary data, which is decoded and written byte for byte"`
+	Encoding        string `json:"encoding,omitempty" jsonschema:"Text encoding for the file: utf-8 (default), utf-8-bom, utf-16le, utf-16be, latin1, or ascii"`
+	LineEndings     string `json:"line_endings,omitempty" jsonschema:"Line ending style: lf, crlf, or preserve (default) to keep an existing file's style"`
+	Mode            string `json:"mode,omitempty" jsonschema:"Octal permissions to set on the file (e.g. 755). Defaults to the existing file's mode, or the server default for new files"`
+	IfNotExists     bool   `json:"if_not_exists,omitempty" jsonschema:"Only create the file: fail without changing anything if the path already exists"`
+	Backup          *bool  `json:"backup,omitempty" jsonschema:"Save the existing file's content before overwriting it, restorable with restore_backup. Defaults to the server setting"`
 }
 type WriteOutput struct {
 	Message string `json:"message"`
@@ -77,7 +274,14 @@ type WriteOutput struct {
 
 func Write(ctx context.Context, req *sdk.CallToolRequest, args WriteInput) (*sdk.CallToolResult, any, error) {
 	server := GetState()
-	result, err := server.executeWrite(ctx, args.FilePath, args.Content)
+	result, err := server.executeWrite(withSession(ctx, req), args.FilePath, args.Content, writeOptions{
+		ContentEncoding: args.ContentEncoding,
+		Encoding:        args.Encoding,
+		LineEndings:     args.LineEndings,
+		Mode:            args.Mode,
+		IfNotExists:     args.IfNotExists,
+		Backup:          server.useBackup(args.Backup),
+	})
 	if err != nil {
 		return nil, nil, err
 	}
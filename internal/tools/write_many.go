@@ -0,0 +1,232 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// plannedWrite is one file of a write_many call, checked and prepared before anything is written.
+type plannedWrite struct {
+	path       string
+	data       []byte
+	perm       os.FileMode
+	existed    bool
+	oldContent []byte
+	oldPerm    os.FileMode
+	formatNote string
+	backupPath string
+	createdDir []string
+}
+
+func (s *State) executeWriteMany(ctx context.Context, files []WriteManyFile, backup bool) (string, error) {
+	if err := s.checkOverlay("write_many"); err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("files must contain at least one file")
+	}
+	// Lock the destinations before checking them, so no other call changes them in between.
+	var targets []string
+	for _, f := range files {
+		if resolved, err := s.resolvePath(f.FilePath); err == nil {
+			targets = append(targets, resolved)
+		}
+	}
+	defer s.lockPaths(ctx, targets...)()
+
+	// Every file is checked and prepared up front, so a bad entry fails the call before any file
+	// is touched.
+	plan := make([]*plannedWrite, len(files))
+	seen := make(map[string]int, len(files))
+	paths := make([]string, len(files))
+	for i, f := range files {
+		w, err := s.planWrite(ctx, f)
+		if err != nil {
+			return "", fmt.Errorf("files[%d] (%s): %s", i, f.FilePath, err)
+		}
+		if j, dup := seen[w.path]; dup {
+			return "", fmt.Errorf("files[%d] and files[%d] both write %s", j, i, w.path)
+		}
+		seen[w.path] = i
+		plan[i], paths[i] = w, w.path
+	}
+	if err := s.confirmWrite(ctx, "write_many", paths...); err != nil {
+		return "", err
+	}
+
+	if backup {
+		for _, w := range plan {
+			if !w.existed {
+				continue
+			}
+			var err error
+			if w.backupPath, err = s.backupFile(ctx, w.path, true); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	fsys := s.filesystem()
+	for i, w := range plan {
+		w.createdDir = missingDirs(fsys, filepath.Dir(w.path))
+		_ = fsys.MkdirAll(filepath.Dir(w.path), 0o750)
+		if err := s.writeFile(w.path, w.data, w.perm); err != nil {
+			message := fmt.Sprintf("Cannot write %s: %s", w.path, err)
+			// Writes are atomic, so the failed file is untouched; only the directories made for it
+			// and the files before it need undoing.
+			removeDirs(fsys, w.createdDir)
+			if rollbackErr := s.rollbackWrites(plan[:i]); rollbackErr != nil {
+				return "", fmt.Errorf("%s; rolling back the files already written also failed: %s", message, rollbackErr)
+			}
+			return "", fmt.Errorf("%s; no files were changed", message)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Wrote %d files:", len(plan))
+	for _, w := range plan {
+		s.recordEdit(ctx, "write_many", w.path, w.existed, w.oldContent, w.data)
+		s.Mu.Lock()
+		if info, err := fsys.Stat(w.path); err == nil {
+			s.ReadFiles[w.path] = info.ModTime()
+		}
+		s.Mu.Unlock()
+
+		status := "created"
+		if w.existed {
+			status = "updated"
+		}
+		if w.backupPath != "" {
+			status += ", previous content backed up to " + w.backupPath
+		}
+		fmt.Fprintf(&b, "\n- %s (%s)", w.path, status)
+		if w.formatNote != "" {
+			b.WriteString("\n  " + w.formatNote)
+		}
+	}
+	return b.String(), nil
+}
+
+// planWrite runs the checks write makes on a single file and prepares the bytes to write.
+func (s *State) planWrite(ctx context.Context, f WriteManyFile) (*plannedWrite, error) {
+	resolved, err := s.resolvePath(f.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkPathAllowed(resolved); err != nil {
+		return nil, err
+	}
+	w := &plannedWrite{path: resolved}
+	fsys := s.filesystem()
+	if info, err := fsys.Stat(resolved); err == nil {
+		if info.IsDir() {
+			return nil, fmt.Errorf("path is a directory, not a file")
+		}
+		if w.oldContent, err = fsys.ReadFile(resolved); err != nil {
+			return nil, fmt.Errorf("Cannot read file: %s", err)
+		}
+		w.existed, w.oldPerm = true, info.Mode().Perm()
+	}
+	if err := s.validateFileForWrite(resolved); err != nil {
+		return nil, err
+	}
+	if f.Mode != "" {
+		if w.perm, err = parseFileMode(f.Mode); err != nil {
+			return nil, err
+		}
+	}
+	opts := writeOptions{ContentEncoding: f.ContentEncoding, Encoding: f.Encoding, LineEndings: f.LineEndings}
+	if w.data, w.formatNote, err = s.prepareWriteData(ctx, resolved, f.Content, opts, w.oldContent, w.existed); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// rollbackWrites undoes the given writes, most recent first: files that existed get their previous
+// content and mode back, and new files are removed along with the directories created for them.
+func (s *State) rollbackWrites(plan []*plannedWrite) error {
+	fsys := s.filesystem()
+	var failed []string
+	for i := len(plan) - 1; i >= 0; i-- {
+		w := plan[i]
+		var err error
+		if w.existed {
+			err = s.writeFile(w.path, w.oldContent, w.oldPerm)
+		} else if err = fsys.Remove(w.path); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", w.path, err))
+		}
+		removeDirs(fsys, w.createdDir)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// missingDirs lists dir and those of its ancestors that do not exist on fsys yet, deepest first, so
+// that they can be removed in order if they were created for nothing.
+func missingDirs(fsys vfs.FS, dir string) []string {
+	var missing []string
+	for {
+		if _, err := fsys.Lstat(dir); err == nil {
+			return missing
+		}
+		missing = append(missing, dir)
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return missing
+		}
+		dir = parent
+	}
+}
+
+// removeDirs removes the given directories in order, leaving any that are no longer empty.
+func removeDirs(fsys vfs.FS, dirs []string) {
+	for _, dir := range dirs {
+		_ = fsys.Remove(dir)
+	}
+}
+
+var WriteManyTool = sdk.Tool{
+	Name:        "write_many",
+	Description: "Writes several files in one call, all or nothing, e.g. to scaffold a new package's source, test, and fixture files in one step.\n\nUsage:\n- Each file is checked as write checks it: existing files MUST have been read first and not changed since, and paths must be allowed. If any file fails a check, nothing is written.\n- If writing a file fails partway through, the files already written are restored to their previous content (or removed, if they were new), so the call never leaves a partial result.\n- Each file takes the same options as write: content_encoding, encoding, line_endings, and mode.\n- Set backup to true to save the previous content of existing files so they can be reverted with restore_backup.",
+}
+
+type WriteManyFile struct {
+	FilePath        string `json:"file_path" jsonschema:"The absolute path to the file to write (must be absolute, not relative)"`
+	Content         string `json:"content" jsonschema:"The content to write to the file"`
+	ContentEncoding string `json:"content_encoding,omitempty" jsonschema:"How content is encoded: 'text' (default) or 'base64' for binary data, which is decoded and written byte for byte"`
+	Encoding        string `json:"encoding,omitempty" jsonschema:"Text encoding for the file: utf-8 (default), utf-8-bom, utf-16le, utf-16be, latin1, or ascii"`
+	LineEndings     string `json:"line_endings,omitempty" jsonschema:"Line ending style: lf, crlf, or preserve (default) to keep an existing file's style"`
+	Mode            string `json:"mode,omitempty" jsonschema:"Octal permissions to set on the file (e.g. 755). Defaults to the existing file's mode, or the server default for new files"`
+}
+
+type WriteManyInput struct {
+	Files  []WriteManyFile `json:"files" jsonschema:"The files to write, each with a file_path and content"`
+	Backup *bool           `json:"backup,omitempty" jsonschema:"Save the content of existing files before overwriting them, restorable with restore_backup. Defaults to the server setting"`
+}
+type WriteManyOutput struct {
+	Message string `json:"message"`
+}
+
+func WriteMany(ctx context.Context, req *sdk.CallToolRequest, args WriteManyInput) (*sdk.CallToolResult, any, error) {
+	server := GetState()
+	result, err := server.executeWriteMany(withSession(ctx, req), args.Files, server.useBackup(args.Backup))
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &WriteManyOutput{Message: result}
+	return &sdk.CallToolResult{
+		Content:           []sdk.Content{&sdk.TextContent{Text: result}},
+		StructuredContent: output,
+	}, output, nil
+}
@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMany(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("writes every file", func(t *testing.T) {
+		state := NewState()
+		dir := t.TempDir()
+		existing := filepath.Join(dir, "go.mod")
+		require.NoError(t, os.WriteFile(existing, []byte("module old\n"), 0o644))
+		_, err := state.executeRead(ctx, existing, 0, 0)
+		require.NoError(t, err)
+
+		result, err := state.executeWriteMany(ctx, []WriteManyFile{
+			{FilePath: existing, Content: "module new\n"},
+			{FilePath: filepath.Join(dir, "pkg", "pkg.go"), Content: "package pkg\n"},
+			{FilePath: filepath.Join(dir, "pkg", "testdata", "in.bin"), Content: "AAE=", ContentEncoding: "base64"},
+		}, false)
+		require.NoError(t, err)
+		assert.Contains(t, result, "Wrote 3 files:")
+		assert.Contains(t, result, existing+" (updated)")
+		assert.Contains(t, result, filepath.Join(dir, "pkg", "pkg.go")+" (created)")
+
+		content, err := os.ReadFile(existing)
+		require.NoError(t, err)
+		assert.Equal(t, "module new\n", string(content))
+		content, err = os.ReadFile(filepath.Join(dir, "pkg", "testdata", "in.bin"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0, 1}, content)
+
+		// The new files count as read, so they can be overwritten right away.
+		_, err = callWrite(t, state, WriteInput{FilePath: filepath.Join(dir, "pkg", "pkg.go"), Content: "package pkg // v2\n"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("a failed check writes nothing", func(t *testing.T) {
+		state := NewState()
+		dir := t.TempDir()
+		unread := filepath.Join(dir, "unread.txt")
+		require.NoError(t, os.WriteFile(unread, []byte("original"), 0o644))
+		created := filepath.Join(dir, "new", "file.txt")
+
+		_, err := state.executeWriteMany(ctx, []WriteManyFile{
+			{FilePath: created, Content: "new"},
+			{FilePath: unread, Content: "changed"},
+		}, false)
+		assert.ErrorContains(t, err, "files[1]")
+		assert.ErrorContains(t, err, "must read it first")
+		assert.NoDirExists(t, filepath.Join(dir, "new"))
+
+		_, err = state.executeWriteMany(ctx, []WriteManyFile{
+			{FilePath: created, Content: "a"},
+			{FilePath: filepath.Join(dir, "new", "..", "new", "file.txt"), Content: "b"},
+		}, false)
+		assert.ErrorContains(t, err, "files[0] and files[1] both write")
+
+		_, err = state.executeWriteMany(ctx, []WriteManyFile{{FilePath: created, Content: "x", LineEndings: "cr"}}, false)
+		assert.ErrorContains(t, err, "Invalid line_endings")
+
+		_, err = state.executeWriteMany(ctx, nil, false)
+		assert.ErrorContains(t, err, "at least one file")
+		assert.NoFileExists(t, created)
+	})
+
+	t.Run("a failed write rolls back the others", func(t *testing.T) {
+		state := NewState()
+		dir := t.TempDir()
+		existing := filepath.Join(dir, "existing.txt")
+		require.NoError(t, os.WriteFile(existing, []byte("original"), 0o600))
+		_, err := state.executeRead(ctx, existing, 0, 0)
+		require.NoError(t, err)
+		// A regular file where a directory is needed makes the last write fail after the others
+		// have passed every check.
+		blocker := filepath.Join(dir, "blocker")
+		require.NoError(t, os.WriteFile(blocker, nil, 0o644))
+
+		_, err = state.executeWriteMany(ctx, []WriteManyFile{
+			{FilePath: existing, Content: "changed", Mode: "644"},
+			{FilePath: filepath.Join(dir, "a", "b", "new.txt"), Content: "new"},
+			{FilePath: filepath.Join(blocker, "file.txt"), Content: "never"},
+		}, false)
+		assert.ErrorContains(t, err, "no files were changed")
+
+		content, err := os.ReadFile(existing)
+		require.NoError(t, err)
+		assert.Equal(t, "original", string(content))
+		info, err := os.Stat(existing)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		assert.NoDirExists(t, filepath.Join(dir, "a"))
+		assert.Empty(t, state.EditHistory[defaultSessionID])
+	})
+}
@@ -2,12 +2,14 @@ package tools
 
 import (
 	"context"
+	"encoding/base64"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/brwse/claude-tools-mcp/internal/vfs"
 	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -15,7 +17,14 @@ import (
 
 func callWrite(t *testing.T, state *State, input WriteInput) (string, error) {
 	t.Helper()
-	return state.executeWrite(context.Background(), input.FilePath, input.Content)
+	return state.executeWrite(context.Background(), input.FilePath, input.Content, writeOptions{
+		ContentEncoding: input.ContentEncoding,
+		Encoding:        input.Encoding,
+		LineEndings:     input.LineEndings,
+		Mode:            input.Mode,
+		IfNotExists:     input.IfNotExists,
+		Backup:          state.useBackup(input.Backup),
+	})
 }
 
 func TestWrite_BasicFunctionality(t *testing.T) {
@@ -229,3 +238,183 @@ func TestWrite_MCPIntegration(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "test content", string(content))
 }
+
+func TestWrite_EncodingAndLineEndings(t *testing.T) {
+	state := NewState()
+
+	t.Run("crlf conversion", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "crlf.txt")
+		_, err := callWrite(t, state, WriteInput{FilePath: path, Content: "a\nb\n", LineEndings: "crlf"})
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "a\r\nb\r\n", string(content))
+	})
+
+	t.Run("preserve keeps existing crlf", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "existing.txt")
+		require.NoError(t, os.WriteFile(path, []byte("old\r\nlines\r\n"), 0o644))
+		_, err := state.executeRead(context.Background(), path, 0, 0)
+		require.NoError(t, err)
+		_, err = callWrite(t, state, WriteInput{FilePath: path, Content: "new\nlines\n"})
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "new\r\nlines\r\n", string(content))
+	})
+
+	t.Run("lf conversion", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "lf.txt")
+		_, err := callWrite(t, state, WriteInput{FilePath: path, Content: "a\r\nb", LineEndings: "lf"})
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "a\nb", string(content))
+	})
+
+	t.Run("utf-16le with bom", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "utf16.txt")
+		_, err := callWrite(t, state, WriteInput{FilePath: path, Content: "hi", Encoding: "utf-16le"})
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0xFF, 0xFE, 'h', 0, 'i', 0}, content)
+	})
+
+	t.Run("latin1", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "latin1.txt")
+		_, err := callWrite(t, state, WriteInput{FilePath: path, Content: "café", Encoding: "latin1"})
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{'c', 'a', 'f', 0xE9}, content)
+	})
+
+	t.Run("unencodable character", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ascii.txt")
+		_, err := callWrite(t, state, WriteInput{FilePath: path, Content: "café", Encoding: "ascii"})
+		assert.Error(t, err)
+		assert.NoFileExists(t, path)
+	})
+
+	t.Run("base64 binary content", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "blob.bin")
+		data := []byte{0x00, 0xFF, 0x10, '\r', '\n', 0x80}
+		_, err := callWrite(t, state, WriteInput{
+			FilePath:        path,
+			Content:         base64.StdEncoding.EncodeToString(data),
+			ContentEncoding: "base64",
+		})
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, data, content)
+	})
+
+	t.Run("invalid base64", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "blob.bin")
+		_, err := callWrite(t, state, WriteInput{FilePath: path, Content: "!!!", ContentEncoding: "base64"})
+		assert.Error(t, err)
+		_, err = callWrite(t, state, WriteInput{FilePath: path, Content: "AA==", ContentEncoding: "base64", LineEndings: "crlf"})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "x.txt")
+		_, err := callWrite(t, state, WriteInput{FilePath: path, Content: "x", Encoding: "ebcdic"})
+		assert.Error(t, err)
+		_, err = callWrite(t, state, WriteInput{FilePath: path, Content: "x", LineEndings: "cr"})
+		assert.Error(t, err)
+	})
+}
+
+func TestWrite_Permissions(t *testing.T) {
+	state := NewState()
+
+	t.Run("preserves existing mode", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "script.sh")
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755))
+		require.NoError(t, os.Chmod(path, 0o755))
+		_, err := state.executeRead(context.Background(), path, 0, 0)
+		require.NoError(t, err)
+		_, err = callWrite(t, state, WriteInput{FilePath: path, Content: "#!/bin/sh\necho hi\n"})
+		require.NoError(t, err)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+	})
+
+	t.Run("explicit mode", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "run.sh")
+		_, err := callWrite(t, state, WriteInput{FilePath: path, Content: "echo hi\n", Mode: "750"})
+		require.NoError(t, err)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o750), info.Mode().Perm())
+	})
+
+	t.Run("configured default", func(t *testing.T) {
+		custom := NewState()
+		require.NoError(t, custom.SetDefaultFileMode("600"))
+		path := filepath.Join(t.TempDir(), "secret.txt")
+		_, err := callWrite(t, custom, WriteInput{FilePath: path, Content: "x"})
+		require.NoError(t, err)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "x.txt")
+		_, err := callWrite(t, state, WriteInput{FilePath: path, Content: "x", Mode: "999"})
+		assert.Error(t, err)
+		assert.Error(t, state.SetDefaultFileMode("rw-r--r--"))
+	})
+}
+
+func TestWrite_IfNotExists(t *testing.T) {
+	state := NewState()
+
+	t.Run("creates new files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "pkg", "new.go")
+		result, err := callWrite(t, state, WriteInput{FilePath: path, Content: "package pkg\n", Mode: "640", IfNotExists: true})
+		require.NoError(t, err)
+		assert.Contains(t, result, "created successfully")
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "package pkg\n", string(content))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	})
+
+	t.Run("never overwrites", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "user.txt")
+		require.NoError(t, os.WriteFile(path, []byte("mine"), 0o644))
+		// Even a file that was read, which write would otherwise overwrite, is left alone.
+		_, err := state.executeRead(context.Background(), path, 0, 0)
+		require.NoError(t, err)
+		_, err = callWrite(t, state, WriteInput{FilePath: path, Content: "theirs", IfNotExists: true})
+		assert.ErrorContains(t, err, "already exists")
+
+		link := filepath.Join(dir, "dangling")
+		require.NoError(t, os.Symlink(filepath.Join(dir, "missing"), link))
+		_, err = callWrite(t, state, WriteInput{FilePath: link, Content: "x", IfNotExists: true})
+		assert.ErrorContains(t, err, "already exists")
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "mine", string(content))
+		assert.NoFileExists(t, filepath.Join(dir, "missing"))
+	})
+
+	t.Run("exclusive create", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "raced.txt")
+		require.NoError(t, os.WriteFile(path, []byte("first"), 0o644))
+		assert.ErrorIs(t, createFileExclusive(vfs.OS{}, path, []byte("second"), 0o644, false), os.ErrExist)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "first", string(content))
+	})
+}
@@ -0,0 +1,467 @@
+package vfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Memory is a filesystem held entirely in memory, starting out as an empty root directory. Paths
+// are slash-separated and relative paths are taken from the root. It has no symlinks, owners, or
+// umask: files and directories get exactly the permissions they are created with.
+//
+// Memory is safe for concurrent use. Reads through an open file see writes made to the file
+// after it was opened, as they would on disk.
+type Memory struct {
+	mu   sync.RWMutex
+	root *node
+}
+
+// node is a file or directory of a Memory filesystem.
+type node struct {
+	mode    fs.FileMode
+	modTime time.Time
+	data    []byte
+	// children holds a directory's entries by name.
+	children map[string]*node
+}
+
+// NewMemory returns an empty in-memory filesystem.
+func NewMemory() *Memory {
+	return &Memory{root: newDir(0o755)}
+}
+
+func newDir(perm fs.FileMode) *node {
+	return &node{mode: fs.ModeDir | perm.Perm(), modTime: time.Now(), children: map[string]*node{}}
+}
+
+// clean returns the absolute, slash-separated form of name.
+func clean(name string) string {
+	return path.Join("/", filepath.ToSlash(name))
+}
+
+// split returns the path elements of a cleaned name; the root has none.
+func split(name string) []string {
+	if name == "/" {
+		return nil
+	}
+	return strings.Split(name[1:], "/")
+}
+
+// lookup returns the node at the cleaned name. The caller must hold m.mu.
+func (m *Memory) lookup(op, name string) (*node, error) {
+	n := m.root
+	for _, elem := range split(name) {
+		if !n.mode.IsDir() {
+			return nil, &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+		}
+		child, ok := n.children[elem]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		n = child
+	}
+	return n, nil
+}
+
+// parent returns the directory holding the cleaned name, and the name's last element. The caller
+// must hold m.mu.
+func (m *Memory) parent(op, name string) (*node, string, error) {
+	if name == "/" {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	dir, base := path.Split(name)
+	n, err := m.lookup(op, path.Clean(dir))
+	if err != nil {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: err.(*fs.PathError).Err}
+	}
+	if !n.mode.IsDir() {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+	return n, base, nil
+}
+
+func (m *Memory) Open(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (m *Memory) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	name = clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	n, err := m.lookup("open", name)
+	switch {
+	case err == nil && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case err == nil && n.mode.IsDir() && writable:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	case err == nil:
+		if flag&os.O_TRUNC != 0 && writable {
+			n.data, n.modTime = nil, time.Now()
+		}
+	case flag&os.O_CREATE == 0:
+		return nil, err
+	default:
+		dir, base, err := m.parent("open", name)
+		if err != nil {
+			return nil, err
+		}
+		n = &node{mode: perm.Perm(), modTime: time.Now()}
+		dir.children[base] = n
+		dir.modTime = n.modTime
+	}
+	f := &memFile{fs: m, name: name, node: n, readable: flag&os.O_WRONLY == 0, writable: writable, append: flag&os.O_APPEND != 0}
+	return f, nil
+}
+
+func (m *Memory) Stat(name string) (fs.FileInfo, error) {
+	return m.stat("stat", name)
+}
+
+// Lstat is Stat, there being no symlinks.
+func (m *Memory) Lstat(name string) (fs.FileInfo, error) {
+	return m.stat("lstat", name)
+}
+
+func (m *Memory) stat(op, name string) (fs.FileInfo, error) {
+	name = clean(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, err := m.lookup(op, name)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(path.Base(name)), nil
+}
+
+func (m *Memory) ReadFile(name string) ([]byte, error) {
+	name = clean(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	}
+	return slices.Clone(n.data), nil
+}
+
+// ReadDir returns the entries of the directory name sorted by name, like os.ReadDir.
+func (m *Memory) ReadDir(name string) ([]fs.DirEntry, error) {
+	name = clean(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if !n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdirent", Path: name, Err: syscall.ENOTDIR}
+	}
+	entries := make([]fs.DirEntry, 0, len(n.children))
+	for childName, child := range n.children {
+		entries = append(entries, fs.FileInfoToDirEntry(child.info(childName)))
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+func (m *Memory) Mkdir(name string, perm fs.FileMode) error {
+	name = clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, base, err := m.parent("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if _, exists := dir.children[base]; exists {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	dir.children[base] = newDir(perm)
+	dir.modTime = time.Now()
+	return nil
+}
+
+func (m *Memory) MkdirAll(name string, perm fs.FileMode) error {
+	name = clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := m.root
+	for _, elem := range split(name) {
+		child, ok := n.children[elem]
+		if !ok {
+			child = newDir(perm)
+			n.children[elem] = child
+			n.modTime = child.modTime
+		} else if !child.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+		n = child
+	}
+	return nil
+}
+
+// Remove removes the file or empty directory name.
+func (m *Memory) Remove(name string) error {
+	name = clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, base, err := m.parent("remove", name)
+	if err != nil {
+		return err
+	}
+	n, ok := dir.children[base]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if n.mode.IsDir() && len(n.children) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
+	delete(dir.children, base)
+	dir.modTime = time.Now()
+	return nil
+}
+
+// RemoveAll removes name and everything beneath it. Like os.RemoveAll, it succeeds if name does
+// not exist.
+func (m *Memory) RemoveAll(name string) error {
+	name = clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, base, err := m.parent("unlinkat", name)
+	if err != nil {
+		if pathErr := err.(*fs.PathError); pathErr.Err == fs.ErrNotExist {
+			return nil
+		}
+		return err
+	}
+	if _, ok := dir.children[base]; ok {
+		delete(dir.children, base)
+		dir.modTime = time.Now()
+	}
+	return nil
+}
+
+// Rename moves oldname to newname, replacing a file or empty directory there as a rename on disk
+// would.
+func (m *Memory) Rename(oldname, newname string) error {
+	oldname, newname = clean(oldname), clean(newname)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fail := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	srcDir, srcBase, err := m.parent("rename", oldname)
+	if err != nil {
+		return fail(err.(*fs.PathError).Err)
+	}
+	src, ok := srcDir.children[srcBase]
+	if !ok {
+		return fail(fs.ErrNotExist)
+	}
+	if oldname == newname {
+		return nil
+	}
+	if src.mode.IsDir() && strings.HasPrefix(newname, oldname+"/") {
+		return fail(fs.ErrInvalid)
+	}
+	dstDir, dstBase, err := m.parent("rename", newname)
+	if err != nil {
+		return fail(err.(*fs.PathError).Err)
+	}
+	if dst, exists := dstDir.children[dstBase]; exists {
+		switch {
+		case src.mode.IsDir() && !dst.mode.IsDir():
+			return fail(syscall.ENOTDIR)
+		case !src.mode.IsDir() && dst.mode.IsDir():
+			return fail(syscall.EISDIR)
+		case dst.mode.IsDir() && len(dst.children) > 0:
+			return fail(syscall.ENOTEMPTY)
+		}
+	}
+	delete(srcDir.children, srcBase)
+	dstDir.children[dstBase] = src
+	now := time.Now()
+	srcDir.modTime, dstDir.modTime = now, now
+	return nil
+}
+
+func (m *Memory) Chmod(name string, mode fs.FileMode) error {
+	name = clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.lookup("chmod", name)
+	if err != nil {
+		return err
+	}
+	n.mode = n.mode.Type() | mode.Perm()
+	return nil
+}
+
+// EvalSymlinks returns the cleaned name, which must exist, there being no symlinks to resolve.
+func (m *Memory) EvalSymlinks(name string) (string, error) {
+	name = clean(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, err := m.lookup("lstat", name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func (n *node) info(name string) fs.FileInfo {
+	return &fileInfo{name: name, size: int64(len(n.data)), mode: n.mode, modTime: n.modTime}
+}
+
+// fileInfo is a snapshot of a node's metadata.
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() any           { return nil }
+
+// memFile is an open file of a Memory filesystem.
+type memFile struct {
+	fs       *Memory
+	name     string
+	node     *node
+	offset   int64
+	readable bool
+	writable bool
+	append   bool
+	closed   bool
+}
+
+func (f *memFile) Name() string { return f.name }
+
+// check returns the error an operation on f fails with, if any, given whether it writes.
+func (f *memFile) check(op string, write bool) error {
+	switch {
+	case f.closed:
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	case write && !f.writable, !write && !f.readable:
+		return &fs.PathError{Op: op, Path: f.name, Err: syscall.EBADF}
+	case !write && f.node.mode.IsDir():
+		return &fs.PathError{Op: op, Path: f.name, Err: syscall.EISDIR}
+	}
+	return nil
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.check("read", false); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if err := f.check("write", true); err != nil {
+		return 0, err
+	}
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.append {
+		f.offset = int64(len(f.node.data))
+	}
+	end := f.offset + int64(len(p))
+	if grow := end - int64(len(f.node.data)); grow > 0 {
+		f.node.data = append(f.node.data, make([]byte, grow)...)
+	}
+	copy(f.node.data[f.offset:], p)
+	f.offset = end
+	f.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		f.fs.mu.RLock()
+		offset += int64(len(f.node.data))
+		f.fs.mu.RUnlock()
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	return f.node.info(path.Base(f.name)), nil
+}
+
+// Sync does nothing, there being nowhere to flush to.
+func (f *memFile) Sync() error {
+	if f.closed {
+		return &fs.PathError{Op: "sync", Path: f.name, Err: fs.ErrClosed}
+	}
+	return nil
+}
+
+func (f *memFile) Chmod(mode fs.FileMode) error {
+	if f.closed {
+		return &fs.PathError{Op: "chmod", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	f.node.mode = f.node.mode.Type() | mode.Perm()
+	return nil
+}
+
+func (f *memFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
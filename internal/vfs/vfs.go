@@ -0,0 +1,75 @@
+// Package vfs abstracts the file operations of the file tools, so that they can work on the real
+// disk or on an in-memory filesystem that keeps demos, sandboxes, and tests of MCP clients away
+// from the host's files.
+package vfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FS is a filesystem the file tools work on. Its methods behave like the functions of package os
+// of the same name, and report missing and existing files with errors that os.IsNotExist,
+// os.IsExist, and errors.Is recognize.
+type FS interface {
+	Open(name string) (File, error)
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+	Stat(name string) (fs.FileInfo, error)
+	Lstat(name string) (fs.FileInfo, error)
+	ReadFile(name string) ([]byte, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	Mkdir(name string, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldname, newname string) error
+	Chmod(name string, mode fs.FileMode) error
+	// EvalSymlinks behaves like filepath.EvalSymlinks.
+	EvalSymlinks(name string) (string, error)
+}
+
+// File is an open file of an FS, with the methods of *os.File the file tools use.
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.Seeker
+	io.Closer
+	Name() string
+	Stat() (fs.FileInfo, error)
+	Sync() error
+	Chmod(mode fs.FileMode) error
+}
+
+// OS is the real filesystem, through package os.
+type OS struct{}
+
+func (OS) Open(name string) (File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (OS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (OS) Stat(name string) (fs.FileInfo, error)        { return os.Stat(name) }
+func (OS) Lstat(name string) (fs.FileInfo, error)       { return os.Lstat(name) }
+func (OS) ReadFile(name string) ([]byte, error)         { return os.ReadFile(name) }
+func (OS) ReadDir(name string) ([]fs.DirEntry, error)   { return os.ReadDir(name) }
+func (OS) Mkdir(name string, perm fs.FileMode) error    { return os.Mkdir(name, perm) }
+func (OS) MkdirAll(name string, perm fs.FileMode) error { return os.MkdirAll(name, perm) }
+func (OS) Remove(name string) error                     { return os.Remove(name) }
+func (OS) RemoveAll(name string) error                  { return os.RemoveAll(name) }
+func (OS) Rename(oldname, newname string) error         { return os.Rename(oldname, newname) }
+func (OS) Chmod(name string, mode fs.FileMode) error    { return os.Chmod(name, mode) }
+func (OS) EvalSymlinks(name string) (string, error)     { return filepath.EvalSymlinks(name) }
@@ -0,0 +1,172 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFS runs the same operations on the real disk and in memory, so that the in-memory
+// filesystem is held to the behavior the file tools rely on from the real one.
+func TestFS(t *testing.T) {
+	for name, fsys := range map[string]FS{"os": OS{}, "memory": NewMemory()} {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			if name == "memory" {
+				require.NoError(t, fsys.MkdirAll(root, 0o755))
+			}
+			testFS(t, fsys, root)
+		})
+	}
+}
+
+func testFS(t *testing.T, fsys FS, root string) {
+	dir := filepath.Join(root, "a", "b")
+	file := filepath.Join(dir, "file.txt")
+
+	_, err := fsys.Stat(file)
+	assert.True(t, os.IsNotExist(err))
+	assert.True(t, errors.Is(fsys.Mkdir(dir, 0o755), fs.ErrNotExist), "the parent must exist")
+	require.NoError(t, fsys.MkdirAll(dir, 0o755))
+	require.NoError(t, fsys.MkdirAll(dir, 0o755))
+	assert.True(t, os.IsExist(fsys.Mkdir(dir, 0o755)))
+
+	f, err := fsys.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("hello world"))
+	require.NoError(t, err)
+	require.NoError(t, f.Chmod(0o640))
+	require.NoError(t, f.Sync())
+	require.NoError(t, f.Close())
+	_, err = fsys.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	assert.True(t, errors.Is(err, fs.ErrExist))
+
+	info, err := fsys.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, "file.txt", info.Name())
+	assert.Equal(t, int64(11), info.Size())
+	assert.Equal(t, fs.FileMode(0o640), info.Mode())
+	info, err = fsys.Lstat(dir)
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	data, err := fsys.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+	_, err = fsys.ReadFile(dir)
+	assert.Error(t, err)
+	_, err = fsys.ReadFile(filepath.Join(file, "child"))
+	assert.True(t, errors.Is(err, syscall.ENOTDIR))
+
+	f, err = fsys.Open(file)
+	require.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = f.ReadAt(buf, 6)
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(buf))
+	_, err = f.Seek(-5, io.SeekEnd)
+	require.NoError(t, err)
+	rest, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(rest))
+	_, err = f.Write([]byte("x"))
+	assert.Error(t, err, "a file opened for reading cannot be written")
+	require.NoError(t, f.Close())
+
+	f, err = fsys.OpenFile(file, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("!"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	data, _ = fsys.ReadFile(file)
+	assert.Equal(t, "hello world!", string(data))
+	f, err = fsys.OpenFile(file, os.O_WRONLY|os.O_TRUNC, 0)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	data, _ = fsys.ReadFile(file)
+	assert.Empty(t, data)
+
+	require.NoError(t, fsys.Mkdir(filepath.Join(dir, "sub"), 0o755))
+	entries, err := fsys.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "file.txt", entries[0].Name())
+	assert.False(t, entries[0].IsDir())
+	assert.Equal(t, "sub", entries[1].Name())
+	assert.True(t, entries[1].IsDir())
+
+	real, err := fsys.EvalSymlinks(file)
+	require.NoError(t, err)
+	assert.Equal(t, file, real)
+	_, err = fsys.EvalSymlinks(filepath.Join(dir, "missing"))
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, fsys.Chmod(file, 0o600))
+	info, _ = fsys.Stat(file)
+	assert.Equal(t, fs.FileMode(0o600), info.Mode())
+
+	// Renames replace files and empty directories only, and a directory cannot move into itself.
+	moved := filepath.Join(root, "moved.txt")
+	require.NoError(t, fsys.Rename(file, moved))
+	_, err = fsys.Stat(file)
+	assert.True(t, os.IsNotExist(err))
+	assert.Error(t, fsys.Rename(moved, filepath.Join(dir, "sub")))
+	assert.Error(t, fsys.Rename(dir, filepath.Join(dir, "sub", "inside")))
+	assert.Error(t, fsys.Rename(filepath.Join(root, "missing"), moved))
+	require.NoError(t, fsys.Rename(filepath.Join(dir, "sub"), filepath.Join(root, "sub")))
+	entries, _ = fsys.ReadDir(root)
+	assert.Len(t, entries, 3)
+
+	assert.Error(t, fsys.Remove(root), "a directory must be empty to be removed")
+	require.NoError(t, fsys.Remove(moved))
+	assert.True(t, os.IsNotExist(fsys.Remove(moved)))
+	require.NoError(t, fsys.RemoveAll(filepath.Join(root, "a")))
+	require.NoError(t, fsys.RemoveAll(filepath.Join(root, "a")))
+	entries, _ = fsys.ReadDir(root)
+	assert.Len(t, entries, 1)
+}
+
+func TestMemory_RelativePaths(t *testing.T) {
+	fsys := NewMemory()
+	require.NoError(t, fsys.MkdirAll("dir/sub", 0o700))
+	info, err := fsys.Stat("/dir/sub")
+	require.NoError(t, err)
+	assert.Equal(t, fs.ModeDir|0o700, info.Mode())
+	info, err = fsys.Stat("/")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+	assert.Error(t, fsys.RemoveAll("/"))
+}
+
+func TestMemory_OpenFileSeesLaterWrites(t *testing.T) {
+	fsys := NewMemory()
+	writer, err := fsys.OpenFile("/log", os.O_WRONLY|os.O_CREATE, 0o644)
+	require.NoError(t, err)
+	reader, err := fsys.Open("/log")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			_, _ = fsys.ReadFile("/log")
+			_, _ = fsys.ReadDir("/")
+		})
+	}
+	_, err = writer.Write([]byte("line\n"))
+	require.NoError(t, err)
+	wg.Wait()
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "line\n", string(data))
+	require.NoError(t, reader.Close())
+	assert.ErrorIs(t, reader.Close(), fs.ErrClosed)
+}
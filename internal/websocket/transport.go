@@ -0,0 +1,159 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// pingInterval is how often idle connections are pinged, well inside the idle timeouts common
+// proxies apply.
+const pingInterval = 30 * time.Second
+
+// Transport is an MCP transport over an upgraded WebSocket connection, carrying one JSON-RPC
+// message per text message.
+type Transport struct {
+	conn      *Conn
+	sessionID string
+	// extra is attached to every request read from the connection, so middleware sees the bearer
+	// token and headers of the upgrade request as it would for streamable HTTP.
+	extra *sdk.RequestExtra
+}
+
+// NewTransport returns a transport for conn. Each connection is its own session, identified by a
+// random ID so per-session state such as todo lists stays separate.
+func NewTransport(conn *Conn) *Transport {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return &Transport{conn: conn, sessionID: hex.EncodeToString(id)}
+}
+
+func (t *Transport) Connect(ctx context.Context) (sdk.Connection, error) {
+	return &connection{conn: t.conn, sessionID: t.sessionID, extra: t.extra}, nil
+}
+
+type connection struct {
+	conn      *Conn
+	sessionID string
+	extra     *sdk.RequestExtra
+}
+
+func (c *connection) Read(ctx context.Context) (jsonrpc.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, err := c.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := jsonrpc.DecodeMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	if req, ok := msg.(*jsonrpc.Request); ok && c.extra != nil {
+		req.Extra = c.extra
+	}
+	return msg, nil
+}
+
+func (c *connection) Write(ctx context.Context, msg jsonrpc.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := jsonrpc.EncodeMessage(msg)
+	if err != nil {
+		return err
+	}
+	return c.conn.WriteMessage(data)
+}
+
+func (c *connection) Close() error {
+	return c.conn.Close()
+}
+
+func (c *connection) SessionID() string {
+	return c.sessionID
+}
+
+// Handler upgrades requests to WebSocket connections and serves an MCP session on each.
+type Handler struct {
+	getServer func(*http.Request) *sdk.Server
+
+	mu    sync.Mutex
+	conns map[*Conn]struct{}
+}
+
+// NewHandler returns a handler serving the server that getServer returns for each connection.
+func NewHandler(getServer func(*http.Request) *sdk.Server) *Handler {
+	return &Handler{getServer: getServer, conns: make(map[*Conn]struct{})}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server := h.getServer(r)
+	if server == nil {
+		http.Error(w, "no MCP server for this request", http.StatusNotFound)
+		return
+	}
+	conn, err := Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	h.mu.Lock()
+	h.conns[conn] = struct{}{}
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.conns, conn)
+		h.mu.Unlock()
+		conn.Close()
+	}()
+
+	// The session outlives the upgrade request, so it must not inherit its context.
+	transport := NewTransport(conn)
+	transport.extra = &sdk.RequestExtra{TokenInfo: auth.TokenInfoFromContext(r.Context()), Header: r.Header.Clone()}
+	session, err := server.Connect(context.Background(), transport, nil)
+	if err != nil {
+		return
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go keepAlive(conn, done)
+	_ = session.Wait()
+}
+
+// keepAlive pings conn until done is closed or a ping fails.
+func keepAlive(conn *Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if conn.Ping() != nil {
+				return
+			}
+		}
+	}
+}
+
+// CloseAll closes every open connection. Upgraded connections are invisible to
+// http.Server.Shutdown, so servers should register this with RegisterOnShutdown.
+func (h *Handler) CloseAll() {
+	h.mu.Lock()
+	conns := make([]*Conn, 0, len(h.conns))
+	for conn := range h.conns {
+		conns = append(conns, conn)
+	}
+	h.mu.Unlock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
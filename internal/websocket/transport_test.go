@@ -0,0 +1,76 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echoInput struct {
+	Text string `json:"text"`
+}
+
+func TestHandler_ServesMCP(t *testing.T) {
+	server := sdk.NewServer(&sdk.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	var sessionIDs, protocols []string
+	sdk.AddTool(server, &sdk.Tool{Name: "echo"}, func(ctx context.Context, req *sdk.CallToolRequest, in echoInput) (*sdk.CallToolResult, any, error) {
+		sessionIDs = append(sessionIDs, req.Session.ID())
+		protocols = append(protocols, req.Extra.Header.Get("Sec-WebSocket-Protocol"))
+		return &sdk.CallToolResult{Content: []sdk.Content{&sdk.TextContent{Text: in.Text}}}, nil, nil
+	})
+	handler := NewHandler(func(*http.Request) *sdk.Server { return server })
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+
+	// call sends a JSON-RPC request over the client and decodes the matching response
+	call := func(client *testClient, id int, method string, params any) map[string]any {
+		request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+		require.NoError(t, err)
+		client.send(t, true, opText, request, true)
+		for {
+			opcode, payload := client.receive(t)
+			require.Equal(t, byte(opText), opcode)
+			var response map[string]any
+			require.NoError(t, json.Unmarshal(payload, &response))
+			if response["id"] == float64(id) {
+				return response
+			}
+		}
+	}
+	connect := func() *testClient {
+		client, _ := dial(t, httpServer.URL, "Sec-WebSocket-Protocol: mcp\r\n")
+		response := call(client, 1, "initialize", map[string]any{
+			"protocolVersion": "2025-06-18",
+			"capabilities":    map[string]any{},
+			"clientInfo":      map[string]any{"name": "ws-test", "version": "1.0.0"},
+		})
+		require.Contains(t, response, "result", response)
+		client.send(t, true, opText, []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`), true)
+		return client
+	}
+
+	first := connect()
+	response := call(first, 2, "tools/call", map[string]any{"name": "echo", "arguments": map[string]any{"text": "over websocket"}})
+	result := response["result"].(map[string]any)
+	content := result["content"].([]any)[0].(map[string]any)
+	assert.Equal(t, "over websocket", content["text"])
+	// Requests carry the upgrade request's headers
+	assert.Equal(t, []string{"mcp"}, protocols)
+
+	// Each connection is its own session
+	second := connect()
+	call(second, 2, "tools/call", map[string]any{"name": "echo", "arguments": map[string]any{"text": "again"}})
+	require.Len(t, sessionIDs, 2)
+	assert.NotEmpty(t, sessionIDs[0])
+	assert.NotEqual(t, sessionIDs[0], sessionIDs[1])
+
+	handler.CloseAll()
+	opcode, _ := first.receive(t)
+	assert.Equal(t, byte(opClose), opcode)
+}
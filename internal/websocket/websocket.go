@@ -0,0 +1,285 @@
+// Package websocket carries MCP sessions over WebSocket connections, for clients behind proxies
+// that buffer or cut off the streaming responses the streamable HTTP transport relies on.
+//
+// It implements the server side of RFC 6455 that MCP needs: text messages, fragmentation, and the
+// ping, pong, and close control frames. Extensions such as compression are not negotiated.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// acceptGUID is the fixed suffix RFC 6455 appends to the client's key to prove the handshake was
+// understood.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Subprotocol is the Sec-WebSocket-Protocol value MCP clients offer. It is echoed when offered,
+// since browsers reject a handshake that ignores the protocols they asked for.
+const Subprotocol = "mcp"
+
+// maxMessageSize bounds a single incoming message, including all of its fragments.
+const maxMessageSize = 32 * 1024 * 1024
+
+// writeTimeout bounds how long a write may block on a slow or vanished client.
+const writeTimeout = 30 * time.Second
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Close status codes used by the server.
+const (
+	closeNormal          = 1000
+	closeProtocolError   = 1002
+	closeMessageTooLarge = 1009
+)
+
+// Conn is a server-side WebSocket connection. Reads must come from a single goroutine; writes may
+// come from any number.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	writeMu sync.Mutex
+	closed  bool
+}
+
+// Upgrade completes the WebSocket handshake for r and takes over its connection. On failure it
+// responds with an HTTP error and returns it.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	fail := func(status int, message string) (*Conn, error) {
+		http.Error(w, message, status)
+		return nil, errors.New(message)
+	}
+	if r.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "WebSocket handshake must use GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return fail(http.StatusUpgradeRequired, "expected a WebSocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "unsupported WebSocket version, expected 13")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return fail(http.StatusInternalServerError, "connection does not support WebSocket upgrades")
+	}
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, fmt.Sprintf("cannot take over connection: %s", err))
+	}
+	// The handshake's deadlines no longer apply to the long-lived connection.
+	_ = netConn.SetDeadline(time.Time{})
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n"
+	if headerContains(r.Header, "Sec-WebSocket-Protocol", Subprotocol) {
+		response += "Sec-WebSocket-Protocol: " + Subprotocol + "\r\n"
+	}
+	response += "\r\n"
+	if _, err := netConn.Write([]byte(response)); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("cannot complete WebSocket handshake: %w", err)
+	}
+	return &Conn{conn: netConn, br: rw.Reader}, nil
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains reports whether any comma-separated token of the named header equals token,
+// ignoring case.
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next complete data message, answering pings along the way. It returns
+// io.EOF once the client closes the connection.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	started := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			code := closeNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			_ = c.closeWith(code, "")
+			return nil, io.EOF
+		case opText, opBinary:
+			if started {
+				return nil, c.fail(closeProtocolError, "new message started before the previous one finished")
+			}
+			started = true
+		case opContinuation:
+			if !started {
+				return nil, c.fail(closeProtocolError, "continuation frame without a message")
+			}
+		default:
+			return nil, c.fail(closeProtocolError, fmt.Sprintf("unknown opcode %d", opcode))
+		}
+		if len(message)+len(payload) > maxMessageSize {
+			return nil, c.fail(closeMessageTooLarge, "message exceeds the size limit")
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// readFrame reads one frame and unmasks its payload.
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(closeProtocolError, "reserved bits set without a negotiated extension")
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, c.fail(closeProtocolError, "client frames must be masked")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= opClose && (length > 125 || !fin) {
+		return false, 0, nil, c.fail(closeProtocolError, "invalid control frame")
+	}
+	if length > maxMessageSize {
+		return false, 0, nil, c.fail(closeMessageTooLarge, "message exceeds the size limit")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage sends data as a single text message.
+func (c *Conn) WriteMessage(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// Ping sends a ping, which also keeps idle connections open through proxies.
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, 0x80|opcode)
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	frame = append(frame, payload...)
+	_ = c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// fail closes the connection after a protocol violation and returns the reason as an error.
+func (c *Conn) fail(code int, reason string) error {
+	_ = c.closeWith(code, reason)
+	return fmt.Errorf("websocket: %s", reason)
+}
+
+// Close sends a normal close frame and closes the connection.
+func (c *Conn) Close() error {
+	return c.closeWith(closeNormal, "")
+}
+
+func (c *Conn) closeWith(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason...)
+	// The close frame is a courtesy; the connection is closed either way.
+	_ = c.writeFrame(opClose, payload)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.conn.Close()
+}
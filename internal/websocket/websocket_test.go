@@ -0,0 +1,203 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient speaks just enough of the client side of the protocol to exercise the server.
+type testClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dial(t *testing.T, url string, extraHeaders string) (*testClient, string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	request := "GET / HTTP/1.1\r\nHost: test\r\nConnection: keep-alive, Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" + extraHeaders + "\r\n"
+	_, err = conn.Write([]byte(request))
+	require.NoError(t, err)
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	return &testClient{conn: conn, br: br}, resp.Header.Get("Sec-WebSocket-Protocol")
+}
+
+func (c *testClient) send(t *testing.T, fin bool, opcode byte, payload []byte, masked bool) {
+	t.Helper()
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	default:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	}
+	body := append([]byte(nil), payload...)
+	if masked {
+		var mask [4]byte
+		_, _ = rand.Read(mask[:])
+		frame = append(frame, mask[:]...)
+		for i := range body {
+			body[i] ^= mask[i%4]
+		}
+	}
+	_, err := c.conn.Write(append(frame, body...))
+	require.NoError(t, err)
+}
+
+func (c *testClient) receive(t *testing.T) (byte, []byte) {
+	t.Helper()
+	var header [2]byte
+	_, err := io.ReadFull(c.br, header[:])
+	require.NoError(t, err)
+	length := int(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		_, err = io.ReadFull(c.br, ext[:])
+		require.NoError(t, err)
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err = io.ReadFull(c.br, ext[:])
+		require.NoError(t, err)
+		length = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(c.br, payload)
+	require.NoError(t, err)
+	return header[0] & 0x0F, payload
+}
+
+// echoServer upgrades every request and echoes each message back until the client leaves.
+func echoServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(message); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The worked example from RFC 6455, section 1.3
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+func TestUpgrade_RejectsInvalidHandshakes(t *testing.T) {
+	server := echoServer(t)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"plain request", nil, http.StatusUpgradeRequired},
+		{"wrong version", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "8", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ=="}, http.StatusUpgradeRequired},
+		{"bad key", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "short"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			require.NoError(t, err)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
+
+func TestConn_Messages(t *testing.T) {
+	server := echoServer(t)
+
+	t.Run("subprotocol is echoed when offered", func(t *testing.T) {
+		_, protocol := dial(t, server.URL, "Sec-WebSocket-Protocol: mcp\r\n")
+		assert.Equal(t, Subprotocol, protocol)
+		_, protocol = dial(t, server.URL, "")
+		assert.Empty(t, protocol)
+	})
+
+	t.Run("echo", func(t *testing.T) {
+		client, _ := dial(t, server.URL, "")
+		client.send(t, true, opText, []byte(`{"jsonrpc":"2.0"}`), true)
+		opcode, payload := client.receive(t)
+		assert.Equal(t, byte(opText), opcode)
+		assert.Equal(t, `{"jsonrpc":"2.0"}`, string(payload))
+
+		// Messages needing an extended length round-trip too
+		large := strings.Repeat("x", 70000)
+		client.send(t, true, opText, []byte(large[:300]), true)
+		_, payload = client.receive(t)
+		assert.Equal(t, large[:300], string(payload))
+	})
+
+	t.Run("fragments are reassembled around pings", func(t *testing.T) {
+		client, _ := dial(t, server.URL, "")
+		client.send(t, false, opText, []byte("hello "), true)
+		client.send(t, true, opPing, []byte("are you there"), true)
+		client.send(t, true, opContinuation, []byte("world"), true)
+
+		opcode, payload := client.receive(t)
+		assert.Equal(t, byte(opPong), opcode)
+		assert.Equal(t, "are you there", string(payload))
+		opcode, payload = client.receive(t)
+		assert.Equal(t, byte(opText), opcode)
+		assert.Equal(t, "hello world", string(payload))
+	})
+
+	t.Run("close is answered", func(t *testing.T) {
+		client, _ := dial(t, server.URL, "")
+		client.send(t, true, opClose, binary.BigEndian.AppendUint16(nil, closeNormal), true)
+		opcode, payload := client.receive(t)
+		assert.Equal(t, byte(opClose), opcode)
+		assert.Equal(t, uint16(closeNormal), binary.BigEndian.Uint16(payload))
+	})
+
+	t.Run("unmasked frames are a protocol error", func(t *testing.T) {
+		client, _ := dial(t, server.URL, "")
+		client.send(t, true, opText, []byte("hi"), false)
+		opcode, payload := client.receive(t)
+		assert.Equal(t, byte(opClose), opcode)
+		assert.Equal(t, uint16(closeProtocolError), binary.BigEndian.Uint16(payload))
+	})
+}